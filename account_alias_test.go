@@ -0,0 +1,77 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockIAMAPI struct {
+	iamiface.IAMAPI
+	aliases []string
+	err     error
+}
+
+func (m *MockIAMAPI) ListAccountAliases(*iam.ListAccountAliasesInput) (*iam.ListAccountAliasesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	output := &iam.ListAccountAliasesOutput{}
+	for _, alias := range m.aliases {
+		output.AccountAliases = append(output.AccountAliases, Ptr(alias))
+	}
+	return output, nil
+}
+
+// TestAccountAlias tests that the alias of the account is returned, if it has one.
+func TestAccountAlias(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		api     *MockIAMAPI
+		want    string
+		wantErr string
+	}{
+		{name: "alias", api: &MockIAMAPI{aliases: []string{"payments-prod"}}, want: "payments-prod"},
+		{name: "no alias", api: &MockIAMAPI{}},
+		{
+			name:    "access denied",
+			api:     &MockIAMAPI{err: errors.New("AccessDenied")},
+			wantErr: "failed to list account aliases; AccessDenied",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			alias, err := accountAlias(tt.api)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, alias)
+		})
+	}
+}
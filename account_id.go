@@ -0,0 +1,61 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// accountIDLabel is the label holding the ID of the AWS account of the series.
+const accountIDLabel = "account_id"
+
+// callerAccount returns the ID of the account of the credentials of the STS client.
+func callerAccount(api stsiface.STSAPI) (string, error) {
+	output, err := api.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity; %w", err)
+	}
+	return aws.StringValue(output.Account), nil
+}
+
+// account returns the ID of the AWS account of the clients, resolved when the Scopes were created, or the account of
+// their role, or "default" for the default credentials, if it could not be.
+func (c *Config) account() string {
+	if c.AccountID != "" {
+		return c.AccountID
+	}
+	return accountOfRole(c.RoleARN)
+}
+
+// accountID returns the ID of the account scanned, which groups the metrics pushed to a Pushgateway, or an empty string
+// if several accounts are scanned or if its ID could not be resolved.
+func (s *Scopes) accountID() string {
+	if len(s.Accounts) != 1 {
+		return ""
+	}
+	return s.Accounts[0].ID
+}
@@ -0,0 +1,260 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// acknowledgement mutes a known deprecated RDS resource until it expires: it is counted by the acknowledged metric
+// rather than the deprecated one, so that its alerts can be routed differently. The region and the account, "default"
+// for the account of the default credentials, match any resource when empty.
+type acknowledgement struct {
+	Identifier string    `yaml:"identifier" json:"identifier"`
+	Region     string    `yaml:"region" json:"region,omitempty"`
+	Account    string    `yaml:"account" json:"account,omitempty"`
+	Expires    time.Time `yaml:"expires" json:"expires"`
+	Reason     string    `yaml:"reason" json:"reason"`
+}
+
+// validate checks that the acknowledgement has an identifier, an expiry and a reason.
+func (a acknowledgement) validate() error {
+	var missing []string
+	if a.Identifier == "" {
+		missing = append(missing, "identifier")
+	}
+	if a.Expires.IsZero() {
+		missing = append(missing, "expires")
+	}
+	if a.Reason == "" {
+		missing = append(missing, "reason")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s should be set", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// sameResources returns true if both acknowledgements select the same RDS resources.
+func (a acknowledgement) sameResources(b acknowledgement) bool {
+	return a.Identifier == b.Identifier && a.Region == b.Region && a.Account == b.Account
+}
+
+// matches returns true if the acknowledgement selects an RDS resource.
+func (a acknowledgement) matches(rdsInfo RDSInfo) bool {
+	return a.Identifier == rdsInfo.ClusterIdentifier &&
+		(a.Region == "" || a.Region == rdsInfo.Region) &&
+		(a.Account == "" || a.Account == rdsInfo.Account)
+}
+
+// acknowledgements holds the acknowledgements of the configuration file and of the API. The ones added with the API
+// are not persisted. It is safe for concurrent use, and nil acknowledgements acknowledge nothing.
+type acknowledgements struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	items []acknowledgement
+
+	// configured holds the acknowledgements of the configuration file last loaded.
+	configured []acknowledgement
+}
+
+// newAcknowledgements returns the acknowledgements of the options, or nil if there are none and neither the API, served
+// on the admin listener, nor the reload of the configuration file can add them.
+func newAcknowledgements(options *Options) *acknowledgements {
+	if len(options.Acknowledgements) == 0 && options.AdminAddress == "" && !options.ConfigReload {
+		return nil
+	}
+	a := &acknowledgements{now: time.Now}
+	a.reconfigure(options.Acknowledgements)
+	return a
+}
+
+// reconfigure replaces the acknowledgements of the configuration file previously loaded with the given ones. The ones
+// added or replaced with the API are kept. Nothing is done if a is nil.
+func (a *acknowledgements) reconfigure(configured []acknowledgement) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	items := make([]acknowledgement, 0, len(a.items))
+	for _, ack := range a.items {
+		if !containsAcknowledgement(a.configured, ack) {
+			items = append(items, ack)
+		}
+	}
+	a.items, a.configured = items, configured
+	a.mu.Unlock()
+	for _, ack := range configured {
+		a.put(ack)
+	}
+}
+
+// containsAcknowledgement returns true if acks holds ack.
+func containsAcknowledgement(acks []acknowledgement, ack acknowledgement) bool {
+	for _, a := range acks {
+		if a == ack {
+			return true
+		}
+	}
+	return false
+}
+
+// put adds an acknowledgement, replacing the one selecting the same resources, if any.
+func (a *acknowledgements) put(ack acknowledgement) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.items {
+		if a.items[i].sameResources(ack) {
+			a.items[i] = ack
+			return
+		}
+	}
+	a.items = append(a.items, ack)
+}
+
+// remove removes the acknowledgement selecting the same resources as ack, and returns false if there is none.
+func (a *acknowledgements) remove(ack acknowledgement) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i := range a.items {
+		if a.items[i].sameResources(ack) {
+			a.items = append(a.items[:i], a.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// active returns the acknowledgements that have not expired, sorted by identifier, region and account.
+func (a *acknowledgements) active() []acknowledgement {
+	if a == nil {
+		return nil
+	}
+	now := a.now()
+	a.mu.Lock()
+	items := make([]acknowledgement, 0, len(a.items))
+	for _, ack := range a.items {
+		if now.Before(ack.Expires) {
+			items = append(items, ack)
+		}
+	}
+	a.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Identifier != items[j].Identifier {
+			return items[i].Identifier < items[j].Identifier
+		}
+		if items[i].Region != items[j].Region {
+			return items[i].Region < items[j].Region
+		}
+		return items[i].Account < items[j].Account
+	})
+	return items
+}
+
+// lookup returns the active acknowledgement of an RDS resource, if any.
+func (a *acknowledgements) lookup(rdsInfo RDSInfo) (acknowledgement, bool) {
+	for _, ack := range a.active() {
+		if ack.matches(rdsInfo) {
+			return ack, true
+		}
+	}
+	return acknowledgement{}, false
+}
+
+// acknowledgementsHandler returns the handler of the /acknowledgements endpoint of the admin listener:
+//   - GET lists the active acknowledgements in JSON;
+//   - POST adds the acknowledgement of the JSON body, replacing the one selecting the same resources, if any;
+//   - DELETE removes the acknowledgement selecting the resources of the identifier, region and account query
+//     parameters.
+//
+// The changes apply from the next snapshot.
+func acknowledgementsHandler(a *acknowledgements) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(a.active())
+		case http.MethodPost:
+			var ack acknowledgement
+			if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+				http.Error(w, fmt.Sprintf("invalid acknowledgement: %s", err), http.StatusBadRequest)
+				return
+			}
+			if err := ack.validate(); err != nil {
+				http.Error(w, fmt.Sprintf("invalid acknowledgement: %s", err), http.StatusBadRequest)
+				return
+			}
+			a.put(ack)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			query := r.URL.Query()
+			ack := acknowledgement{
+				Identifier: query.Get("identifier"),
+				Region:     query.Get("region"),
+				Account:    query.Get("account"),
+			}
+			if !a.remove(ack) {
+				http.Error(w, "acknowledgement not found", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// deprecationValues returns the values of the DeprecatedGauge, the GraceGauge and the AcknowledgedGauge of an RDS
+// resource whose version is valid or not. If the version flipped to deprecated less than the grace period of the
+// Deprecations ago, the resource is in grace rather than deprecated. Likewise, the deprecated resources muted by the
+// Acknowledgements are acknowledged rather than deprecated, and the AcknowledgementExpiryGauge is set to the expiry of
+// their acknowledgement.
+func deprecationValues(metrics *Metrics, rdsInfo RDSInfo, valid bool) (deprecated, grace, acknowledged float64) {
+	ack, isAcknowledged := metrics.Acknowledgements.lookup(rdsInfo)
+	switch {
+	case valid:
+	case metrics.Deprecations.inGrace(rdsInfo):
+		grace = 1
+	case isAcknowledged:
+		acknowledged = 1
+		metrics.AcknowledgementExpiryGauge.With(prometheus.Labels{
+			"cluster_identifier": rdsInfo.ClusterIdentifier,
+			"account_id":         rdsInfo.Account,
+			"region":             rdsInfo.Region,
+			"reason":             ack.Reason,
+		}).Set(float64(ack.Expires.Unix()))
+	default:
+		deprecated = 1
+	}
+	return deprecated, grace, acknowledged
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAcknowledgementsHandler tests that acknowledgements are added, listed while they have not expired, replaced and
+// removed through the API.
+func TestAcknowledgementsHandler(t *testing.T) {
+	now := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	acks := newAcknowledgements(&Options{Acknowledgements: []acknowledgement{
+		{Identifier: "expired", Expires: now.Add(-time.Hour), Reason: "migrated"},
+	}})
+	acks.now = func() time.Time { return now }
+	handler := acknowledgementsHandler(acks)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "list nothing active", method: http.MethodGet, wantStatus: http.StatusOK, wantBody: "[]\n"},
+		{
+			name: "add", method: http.MethodPost, wantStatus: http.StatusCreated,
+			body: `{"identifier":"legacy-cms","region":"eu-west-1","expires":"2023-06-01T00:00:00Z","reason":"decommissioned in May"}`,
+		},
+		{
+			name: "replace", method: http.MethodPost, wantStatus: http.StatusCreated,
+			body: `{"identifier":"legacy-cms","region":"eu-west-1","expires":"2023-07-01T00:00:00Z","reason":"decommissioned in June"}`,
+		},
+		{
+			name: "invalid", method: http.MethodPost, body: `{"identifier":"billing"}`,
+			wantStatus: http.StatusBadRequest, wantBody: "invalid acknowledgement: expires, reason should be set\n",
+		},
+		{
+			name: "list", method: http.MethodGet, wantStatus: http.StatusOK,
+			wantBody: `[{"identifier":"legacy-cms","region":"eu-west-1","expires":"2023-07-01T00:00:00Z","reason":"decommissioned in June"}]` + "\n",
+		},
+		{
+			name: "remove unknown", method: http.MethodDelete, target: "?identifier=legacy-cms",
+			wantStatus: http.StatusNotFound, wantBody: "acknowledgement not found\n",
+		},
+		{
+			name: "remove", method: http.MethodDelete, target: "?identifier=legacy-cms&region=eu-west-1",
+			wantStatus: http.StatusNoContent,
+		},
+		{name: "list removed", method: http.MethodGet, wantStatus: http.StatusOK, wantBody: "[]\n"},
+		{
+			name: "method not allowed", method: http.MethodPut,
+			wantStatus: http.StatusMethodNotAllowed, wantBody: "method not allowed\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.method, "/acknowledgements"+tt.target, tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
+
+// TestExportAcknowledged tests that the acknowledged deprecated resources are counted by the acknowledged metric rather
+// than the deprecated one, and that acknowledgements only match their region and account, if set.
+func TestExportAcknowledged(t *testing.T) {
+	var options Options
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+acknowledgements:
+  - identifier: legacy-cms
+    region: eu-west-1
+    expires: 2099-01-01T00:00:00Z
+    reason: decommissioned in June
+  - identifier: billing
+    account: "123456789012"
+    expires: 2099-01-01T00:00:00Z
+    reason: upgraded with orders
+`), &options))
+	metrics := NewMetrics()
+	metrics.Acknowledgements = newAcknowledgements(&options)
+	m := engineVersions{"mysql": {"5.7.38": {Status: "deprecated"}}}
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "legacy-cms", Engine: "mysql", EngineVersion: "5.7.38", Region: "eu-west-1", Account: "default"},
+		{ClusterIdentifier: "legacy-cms", Engine: "mysql", EngineVersion: "5.7.38", Region: "us-east-1", Account: "default"},
+		{ClusterIdentifier: "billing", Engine: "mysql", EngineVersion: "5.7.38", Region: "eu-west-1", Account: "default"},
+	} {
+		assert.NoError(t, export(metrics, rdsInfo, m))
+	}
+
+	want := `# HELP aws_custom_rds_version_deprecated_acknowledged Number of instances whose version is deprecated, muted by an acknowledgement
+# TYPE aws_custom_rds_version_deprecated_acknowledged gauge
+aws_custom_rds_version_deprecated_acknowledged{account_id="default",babelfish="false",cluster_identifier="billing",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 0
+aws_custom_rds_version_deprecated_acknowledged{account_id="default",babelfish="false",cluster_identifier="legacy-cms",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 1
+aws_custom_rds_version_deprecated_acknowledged{account_id="default",babelfish="false",cluster_identifier="legacy-cms",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="us-east-1",role=""} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.AcknowledgedGauge, strings.NewReader(want)))
+	want = `# HELP aws_custom_rds_version_deprecated Number of instances whose Version is deprecated
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="billing",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 1
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="legacy-cms",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 0
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="legacy-cms",edition="",engine="mysql",engine_version="5.7.38",engine_version_major="5.7",engine_version_minor="38",license_model="",maintenance_window="",rds_custom="false",region="us-east-1",role=""} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.DeprecatedGauge, strings.NewReader(want)))
+	want = `# HELP aws_custom_rds_acknowledgement_expiry_timestamp_seconds Time the acknowledgement of a deprecated resource expires, with its reason
+# TYPE aws_custom_rds_acknowledgement_expiry_timestamp_seconds gauge
+aws_custom_rds_acknowledgement_expiry_timestamp_seconds{account_id="default",cluster_identifier="legacy-cms",reason="decommissioned in June",region="eu-west-1"} 4.0709088e+09
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.AcknowledgementExpiryGauge, strings.NewReader(want)))
+}
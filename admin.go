@@ -0,0 +1,51 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofRoutes returns the endpoints of the Go profiler, under /debug/pprof/. They are only served on the admin
+// listener, as they expose the internals of the exporter and can be costly.
+func pprofRoutes() []route {
+	return []route{
+		{"/debug/pprof/", http.HandlerFunc(pprof.Index)},
+		{"/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline)},
+		{"/debug/pprof/profile", http.HandlerFunc(pprof.Profile)},
+		{"/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol)},
+		{"/debug/pprof/trace", http.HandlerFunc(pprof.Trace)},
+	}
+}
+
+// initAdminServer initializes the HTTP server of the admin listener, serving the operational endpoints given as routes
+// and the Go profiler on addr, distinct from the metrics listener. This way, the metrics port can be exposed to
+// Prometheus while the admin endpoints stay internal.
+func initAdminServer(addr string, routes ...route) *http.Server {
+	serveMux := http.NewServeMux()
+	for _, r := range append(routes, pprofRoutes()...) {
+		serveMux.Handle(r.pattern, r.handler)
+	}
+	return &http.Server{Addr: addr, Handler: serveMux}
+}
@@ -0,0 +1,54 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInitAdminServer tests that the admin listener serves its routes and the Go profiler, which the metrics listener
+// does not serve.
+func TestInitAdminServer(t *testing.T) {
+	ok := route{"/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+	admin := initAdminServer(":0", ok)
+	metrics := initHttpServer(http.NotFoundHandler(), defaultMetricsPath, &readiness{}, ":0")
+
+	for _, tt := range []struct {
+		server *http.Server
+		path   string
+		want   int
+	}{
+		{admin, "/healthz", http.StatusOK},
+		{admin, "/debug/pprof/", http.StatusOK},
+		{admin, "/metrics", http.StatusNotFound},
+		{metrics, "/debug/pprof/", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		tt.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		assert.Equal(t, tt.want, rec.Code, tt.path)
+	}
+}
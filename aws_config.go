@@ -0,0 +1,151 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// maxPutEvaluations is the maximum number of evaluations of a PutEvaluations call.
+	maxPutEvaluations = 100
+
+	// configTestModeToken is the result token of the test invocations of AWS Config rules, whose evaluations are not
+	// recorded.
+	configTestModeToken = "TESTMODE"
+)
+
+// configRuleEvent is the event of an AWS Config custom rule invocation.
+type configRuleEvent struct {
+	// ResultToken associates the evaluations with the rule and the invocation.
+	ResultToken string `json:"resultToken"`
+
+	// InvokingEvent is the JSON document of the notification that triggered the invocation.
+	InvokingEvent string `json:"invokingEvent"`
+}
+
+// orderingTimestamp returns the creation time of the notification that triggered the invocation, or now if it has none.
+func (e configRuleEvent) orderingTimestamp(now time.Time) time.Time {
+	var invokingEvent struct {
+		NotificationCreationTime time.Time `json:"notificationCreationTime"`
+	}
+	if err := json.Unmarshal([]byte(e.InvokingEvent), &invokingEvent); err != nil ||
+		invokingEvent.NotificationCreationTime.IsZero() {
+		return now
+	}
+	return invokingEvent.NotificationCreationTime
+}
+
+// readConfigRuleEvent reads the JSON event of an AWS Config custom rule invocation from a file, or from r if the path is
+// "-".
+func readConfigRuleEvent(path string, r io.Reader) (configRuleEvent, error) {
+	var event configRuleEvent
+	b, err := os.ReadFile(path)
+	if path == "-" {
+		b, err = io.ReadAll(r)
+	}
+	if err != nil {
+		return event, fmt.Errorf("failed to read AWS Config rule event; %w", err)
+	}
+	if err := json.Unmarshal(b, &event); err != nil {
+		return event, fmt.Errorf("failed to parse AWS Config rule event; %w", err)
+	}
+	if event.ResultToken == "" {
+		return event, fmt.Errorf("AWS Config rule event has no result token")
+	}
+	return event, nil
+}
+
+// configEvaluations returns the AWS Config evaluations of the inventory items: NON_COMPLIANT if their engine version is
+// deprecated, and COMPLIANT otherwise. The items without a resource ID, e.g. the synthetic ones of the demo, are skipped.
+func configEvaluations(items []inventoryItem, timestamp time.Time) []*configservice.Evaluation {
+	var evaluations []*configservice.Evaluation
+	for _, item := range items {
+		if item.ResourceID == "" {
+			continue
+		}
+		compliance := configservice.ComplianceTypeCompliant
+		if item.Deprecated {
+			compliance = configservice.ComplianceTypeNonCompliant
+		}
+		evaluations = append(evaluations, &configservice.Evaluation{
+			ComplianceResourceType: Ptr(item.ResourceType),
+			ComplianceResourceId:   Ptr(item.ResourceID),
+			ComplianceType:         Ptr(compliance),
+			Annotation: Ptr(fmt.Sprintf("%s engine version %s of %s is %s",
+				item.Engine, item.EngineVersion, item.ClusterIdentifier, item.Status)),
+			OrderingTimestamp: Ptr(timestamp),
+		})
+	}
+	return evaluations
+}
+
+// putConfigEvaluations puts the evaluations with the result token of the event, in batches of at most 100. The
+// evaluations of test invocations are not recorded by AWS Config.
+func putConfigEvaluations(client configserviceiface.ConfigServiceAPI, event configRuleEvent,
+	evaluations []*configservice.Evaluation) error {
+	for len(evaluations) > 0 {
+		n := len(evaluations)
+		if n > maxPutEvaluations {
+			n = maxPutEvaluations
+		}
+		output, err := client.PutEvaluations(&configservice.PutEvaluationsInput{
+			ResultToken: Ptr(event.ResultToken),
+			Evaluations: evaluations[:n],
+			TestMode:    Ptr(event.ResultToken == configTestModeToken),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put evaluations; %w", err)
+		}
+		if failed := len(output.FailedEvaluations); failed > 0 {
+			return fmt.Errorf("failed to put %d of %d evaluations", failed, n)
+		}
+		evaluations = evaluations[n:]
+	}
+	return nil
+}
+
+// evaluateConfigRule snapshots every account and region, and puts the evaluation of each RDS resource for the AWS Config
+// custom rule invocation event read from the given file, or from r if the path is "-". The resources of the scopes that
+// succeeded in a partial snapshot are evaluated, and its error is returned afterwards.
+func evaluateConfigRule(options *Options, scopes *Scopes, r io.Reader) error {
+	event, err := readConfigRuleEvent(options.AwsConfigEvent, r)
+	if err != nil {
+		return err
+	}
+	items, start, err := snapshotInventory(scopes)
+	if err != nil && !partialSnapshot(err) {
+		return err
+	}
+	client := configservice.New(newSession(options, nil))
+	if putErr := putConfigEvaluations(client, event, configEvaluations(items, event.orderingTimestamp(start))); putErr != nil {
+		return putErr
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/configservice/configserviceiface"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockConfigServiceAPI struct {
+	configserviceiface.ConfigServiceAPI
+	inputs []*configservice.PutEvaluationsInput
+	failed int
+}
+
+func (m *MockConfigServiceAPI) PutEvaluations(input *configservice.PutEvaluationsInput) (*configservice.PutEvaluationsOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &configservice.PutEvaluationsOutput{FailedEvaluations: input.Evaluations[:m.failed]}, nil
+}
+
+// TestReadConfigRuleEvent tests the readConfigRuleEvent function and the orderingTimestamp method of configRuleEvent.
+func TestReadConfigRuleEvent(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		event         string
+		wantToken     string
+		wantTimestamp time.Time
+		wantErr       string
+	}{
+		{
+			name: "periodic",
+			event: `{"resultToken": "token", "configRuleName": "rds-engine-versions", "invokingEvent": ` +
+				`"{\"messageType\":\"ScheduledNotification\",\"notificationCreationTime\":\"2023-05-10T12:00:00.000Z\"}"}`,
+			wantToken:     "token",
+			wantTimestamp: time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{name: "test mode", event: `{"resultToken": "TESTMODE"}`, wantToken: "TESTMODE", wantTimestamp: now},
+		{name: "missing token", event: `{"invokingEvent": "{}"}`, wantErr: "AWS Config rule event has no result token"},
+		{
+			name:    "invalid",
+			event:   `resultToken`,
+			wantErr: "failed to parse AWS Config rule event; invalid character 'r' looking for beginning of value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := readConfigRuleEvent("-", strings.NewReader(tt.event))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantToken, event.ResultToken)
+			assert.Equal(t, tt.wantTimestamp, event.orderingTimestamp(now))
+		})
+	}
+}
+
+// TestPutConfigEvaluations tests the configEvaluations and putConfigEvaluations functions.
+func TestPutConfigEvaluations(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	items := []inventoryItem{
+		{RDSInfo: RDSInfo{ClusterIdentifier: "demo"}},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+			ResourceType: resourceTypeInstance, ResourceID: "db-ORDERS"}, Status: "deprecated", Deprecated: true},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "billing", Engine: "aurora-postgresql", EngineVersion: "15.2",
+			ResourceType: resourceTypeCluster, ResourceID: "cluster-BILLING"}, Status: "available"},
+	}
+	for i := 0; i < 150; i++ {
+		items = append(items, inventoryItem{RDSInfo: RDSInfo{ResourceType: resourceTypeInstance,
+			ResourceID: fmt.Sprintf("db-%03d", i)}})
+	}
+	evaluations := configEvaluations(items, now)
+	assert.Len(t, evaluations, 152)
+	assert.Equal(t, &configservice.Evaluation{
+		ComplianceResourceType: Ptr("AWS::RDS::DBInstance"),
+		ComplianceResourceId:   Ptr("db-ORDERS"),
+		ComplianceType:         Ptr("NON_COMPLIANT"),
+		Annotation:             Ptr("postgres engine version 11.4 of orders is deprecated"),
+		OrderingTimestamp:      Ptr(now),
+	}, evaluations[0])
+	assert.Equal(t, "COMPLIANT", *evaluations[1].ComplianceType)
+
+	tests := []struct {
+		name         string
+		token        string
+		failed       int
+		wantBatches  []int
+		wantTestMode bool
+		wantErr      string
+	}{
+		{name: "batches", token: "token", wantBatches: []int{100, 52}},
+		{name: "test mode", token: "TESTMODE", wantBatches: []int{100, 52}, wantTestMode: true},
+		{name: "failed evaluations", token: "token", failed: 2, wantBatches: []int{100},
+			wantErr: "failed to put 2 of 100 evaluations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &MockConfigServiceAPI{failed: tt.failed}
+			err := putConfigEvaluations(client, configRuleEvent{ResultToken: tt.token}, evaluations)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			var batches []int
+			for _, input := range client.inputs {
+				batches = append(batches, len(input.Evaluations))
+				assert.Equal(t, tt.token, *input.ResultToken)
+				assert.Equal(t, tt.wantTestMode, *input.TestMode)
+			}
+			assert.Equal(t, tt.wantBatches, batches)
+		})
+	}
+}
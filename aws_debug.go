@@ -0,0 +1,95 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// The log levels of the exporter. On the debug level, every AWS API call is logged along with its request ID.
+const (
+	logLevelInfo  = "info"
+	logLevelDebug = "debug"
+)
+
+// redactedFields are the fields of the AWS API responses that are redacted from the dumps: the credentials returned by
+// STS, and the endpoints, the master usernames, the database names and the master user secrets of the RDS resources.
+var redactedFields = []string{
+	"AccessKeyId", "SecretAccessKey", "SessionToken",
+	"Address", "MasterUsername", "DBName", "SecretArn",
+}
+
+// redactedXMLFields and redactedJSONFields match the redactedFields in the XML and JSON responses of the AWS APIs.
+var redactedXMLFields, redactedJSONFields = func() ([]*regexp.Regexp, []*regexp.Regexp) {
+	var xmlFields, jsonFields []*regexp.Regexp
+	for _, field := range redactedFields {
+		xmlFields = append(xmlFields, regexp.MustCompile(fmt.Sprintf(`<%s>[^<]*</%s>`, field, field)))
+		jsonFields = append(jsonFields, regexp.MustCompile(fmt.Sprintf(`"%s"\s*:\s*"(?:[^"\\]|\\.)*"`, field)))
+	}
+	return xmlFields, jsonFields
+}()
+
+// redact returns an AWS API response body without the values of its redactedFields.
+func redact(body string) string {
+	for i, field := range redactedFields {
+		body = redactedXMLFields[i].ReplaceAllString(body, fmt.Sprintf("<%s>REDACTED</%s>", field, field))
+		body = redactedJSONFields[i].ReplaceAllString(body, fmt.Sprintf(`"%s":"REDACTED"`, field))
+	}
+	return body
+}
+
+// logAWSCall logs a completed AWS API call with its request ID, which AWS support asks for, its status, retries and
+// duration, and its error if any. It is meant to be pushed on the Complete handlers of the AWS session on the debug
+// log level.
+func logAWSCall(req *request.Request) {
+	status := 0
+	if req.HTTPResponse != nil {
+		status = req.HTTPResponse.StatusCode
+	}
+	message := fmt.Sprintf("aws call %s.%s: request ID %s, status %d, %d retries, %s", req.ClientInfo.ServiceName,
+		req.Operation.Name, req.RequestID, status, req.RetryCount, time.Since(req.Time).Round(time.Millisecond))
+	if req.Error != nil {
+		message += fmt.Sprintf("; %v", req.Error)
+	}
+	log.Print(message)
+}
+
+// dumpSnapshot takes a single snapshot of every account and region, while the raw responses of its AWS API calls are
+// dumped into the dump directory, redacted. The responses of a partial snapshot are dumped too, the failed ones
+// included.
+func dumpSnapshot(options *Options, scopes *Scopes) error {
+	items, _, err := snapshotInventory(scopes)
+	if err != nil && !partialSnapshot(err) {
+		return fmt.Errorf("failed to take the snapshot to dump; %w", err)
+	}
+	log.Printf("dumped the AWS API responses of a snapshot of %d resources into %s", len(items), options.DumpDir)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot some scopes of the dump; %w", err)
+	}
+	return nil
+}
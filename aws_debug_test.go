@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRedact tests that the secrets of the XML and JSON responses of the AWS APIs are redacted, and nothing else.
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "xml",
+			body: "<Endpoint><Address>db-1.abc.eu-west-1.rds.amazonaws.com</Address><Port>5432</Port></Endpoint>" +
+				"<MasterUsername>admin</MasterUsername><EngineVersion>14.7</EngineVersion>",
+			want: "<Endpoint><Address>REDACTED</Address><Port>5432</Port></Endpoint>" +
+				"<MasterUsername>REDACTED</MasterUsername><EngineVersion>14.7</EngineVersion>",
+		},
+		{
+			name: "json",
+			body: `{"Credentials": {"AccessKeyId": "ASIA", "SecretAccessKey": "s3\"cr3t", "Expiration": 1}}`,
+			want: `{"Credentials": {"AccessKeyId":"REDACTED", "SecretAccessKey":"REDACTED", "Expiration": 1}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redact(tt.body))
+		})
+	}
+}
+
+// TestDumpTransport tests that the AWS API responses are dumped redacted, while the clients get them untouched.
+func TestDumpTransport(t *testing.T) {
+	t.Setenv("AWS_CA_BUNDLE", "")
+	dir := t.TempDir()
+	response := strings.Replace(describeDBInstancesResponse, "<Engine>",
+		"<Endpoint><Address>db-1.abc.eu-west-1.rds.amazonaws.com</Address></Endpoint><Engine>", 1)
+	fakeAWS := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/xml"}},
+			Body:       io.NopCloser(strings.NewReader(response)),
+			Request:    req,
+		}, nil
+	})
+	sess := session.Must(session.NewSession(&aws.Config{
+		HTTPClient:  &http.Client{Transport: fakeAWS},
+		Region:      Ptr("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+
+	config := NewConfig(&Options{DumpDir: dir}, sess, "", "")
+	output, err := config.RDS.DescribeDBInstances(&rds.DescribeDBInstancesInput{})
+	assert.NoError(t, err)
+	assert.Equal(t, "db-1.abc.eu-west-1.rds.amazonaws.com", *output.DBInstances[0].Endpoint.Address)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	b, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	var dumped fixture
+	assert.NoError(t, json.Unmarshal(b, &dumped))
+	assert.Contains(t, dumped.Body, "<Address>REDACTED</Address>")
+	assert.Contains(t, dumped.Body, "<RequestId>request-1</RequestId>")
+}
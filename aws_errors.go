@@ -0,0 +1,111 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The classes of the AWS API errors other than the permission errors, which are counted on their own.
+const (
+	awsErrorClassThrottling = "throttling"
+	awsErrorClassOther      = "other"
+)
+
+// accessDeniedCodes are the error codes of the AWS APIs telling that the credentials lack a permission.
+var accessDeniedCodes = []string{
+	"AccessDenied",
+	"AccessDeniedException",
+	"AuthorizationError",
+	"NotAuthorized",
+	"UnauthorizedOperation",
+}
+
+// iamPrefixes maps the signing names of the AWS APIs to the prefix of their IAM actions, when they differ.
+var iamPrefixes = map[string]string{
+	"tagging": "tag",
+}
+
+// isAccessDenied returns true if err is an AWS API error telling that the credentials lack a permission.
+func isAccessDenied(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && contains(accessDeniedCodes, awsErr.Code())
+}
+
+// awsOperation returns the IAM action of an AWS API call, e.g. "rds:DescribeDBClusters", which the IAM policy of the
+// exporter should allow.
+func awsOperation(req *request.Request) string {
+	prefix := req.ClientInfo.SigningName
+	if prefix == "" {
+		prefix = req.ClientInfo.ServiceName
+	}
+	if iamPrefix, ok := iamPrefixes[prefix]; ok {
+		prefix = iamPrefix
+	}
+	return prefix + ":" + req.Operation.Name
+}
+
+// awsErrorScope counts the failed AWS API calls of the clients of a Config on the metrics of the snapshots. A nil
+// awsErrorScope counts nothing, and so does one whose metrics are not set yet.
+type awsErrorScope struct {
+	metrics atomic.Pointer[Metrics]
+
+	// account returns the ID of the account of the Config, which is resolved after its clients are created.
+	account func() string
+}
+
+// set sets the metrics the failed calls are counted on.
+func (s *awsErrorScope) set(metrics *Metrics) {
+	if s == nil {
+		return
+	}
+	s.metrics.Store(metrics)
+}
+
+// recordAWSCall counts a failed AWS API call, once its retries are over: on the AccessDeniedCounter if the credentials
+// lack the permission of its operation, and on the AWSErrorsCounter with the class of its error otherwise. It is meant
+// to be pushed on the Complete handlers of the session of a Config.
+func (s *awsErrorScope) recordAWSCall(req *request.Request) {
+	if req.Error == nil {
+		return
+	}
+	metrics := s.metrics.Load()
+	if metrics == nil {
+		return
+	}
+	labels := prometheus.Labels{"operation": awsOperation(req), "account_id": s.account()}
+	if isAccessDenied(req.Error) {
+		metrics.AccessDeniedCounter.With(labels).Inc()
+		return
+	}
+	labels["error_class"] = awsErrorClassOther
+	if req.IsErrorThrottle() {
+		labels["error_class"] = awsErrorClassThrottling
+	}
+	metrics.AWSErrorsCounter.With(labels).Inc()
+}
@@ -0,0 +1,73 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAWSErrorScope tests that the failed AWS API calls are counted by operation and account, the permission errors
+// apart from the other ones.
+func TestAWSErrorScope(t *testing.T) {
+	newRequest := func(signingName, operation string, err error, statusCode int) *request.Request {
+		return &request.Request{
+			ClientInfo:   metadata.ClientInfo{ServiceName: signingName, SigningName: signingName},
+			Operation:    &request.Operation{Name: operation},
+			Error:        err,
+			HTTPResponse: &http.Response{StatusCode: statusCode},
+		}
+	}
+	s := &awsErrorScope{account: func() string { return "123456789012" }}
+
+	// nothing is counted until the metrics are set
+	s.recordAWSCall(newRequest("rds", "DescribeDBClusters", awserr.New("AccessDenied", "denied", nil), 403))
+	metrics := NewMetrics()
+	s.set(metrics)
+
+	s.recordAWSCall(newRequest("rds", "DescribeDBClusters", nil, 200))
+	s.recordAWSCall(newRequest("rds", "DescribeDBClusters", awserr.New("AccessDenied", "denied", nil), 403))
+	s.recordAWSCall(newRequest("tagging", "GetResources", awserr.New("AccessDeniedException", "denied", nil), 400))
+	s.recordAWSCall(newRequest("rds", "DescribeDBInstances", awserr.New("Throttling", "Rate exceeded", nil), 400))
+	s.recordAWSCall(newRequest("rds", "DescribeDBInstances", awserr.New("InternalFailure", "oops", nil), 500))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.AccessDeniedCounter))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AccessDeniedCounter.WithLabelValues(
+		"rds:DescribeDBClusters", "123456789012")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AccessDeniedCounter.WithLabelValues(
+		"tag:GetResources", "123456789012")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AWSErrorsCounter.WithLabelValues(
+		"rds:DescribeDBInstances", "123456789012", "throttling")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AWSErrorsCounter.WithLabelValues(
+		"rds:DescribeDBInstances", "123456789012", "other")))
+
+	// a nil awsErrorScope counts nothing
+	var nilScope *awsErrorScope
+	nilScope.set(metrics)
+}
@@ -0,0 +1,130 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// The retry modes of the AWS API calls. The standard mode retries the failed calls with an exponential backoff; the
+// adaptive mode also slows the calls down client-side once AWS throttles them, like the adaptive mode of the other AWS
+// SDKs, which the AWS SDK for Go v1 lacks.
+const (
+	awsRetryModeStandard = "standard"
+	awsRetryModeAdaptive = "adaptive"
+)
+
+const (
+	// adaptiveBackoffFactor is the factor applied to the rate of the adaptive limiter when a call is throttled.
+	adaptiveBackoffFactor = 0.7
+
+	// adaptiveRateIncrease is the rate, in calls per second, the adaptive limiter gains with each call that succeeds.
+	adaptiveRateIncrease = 0.1
+
+	// minAdaptiveRate is the lowest rate of the adaptive limiter, in calls per second.
+	minAdaptiveRate = 0.5
+)
+
+// newRetryer returns the retryer of the AWS API calls: up to maxAttempts attempts per call, with an exponential
+// backoff capped to maxBackoff, whether they were throttled or not.
+func newRetryer(maxAttempts int, maxBackoff time.Duration) request.Retryer {
+	return client.DefaultRetryer{
+		NumMaxRetries:    maxAttempts - 1,
+		MaxRetryDelay:    maxBackoff,
+		MaxThrottleDelay: maxBackoff,
+	}
+}
+
+// adaptiveLimiter is the client-side rate limiter of the adaptive retry mode. It lets every call through until one is
+// throttled, from then on it spaces the calls out at its rate, which starts at the rate the calls were sent at during
+// the last second. Its rate is multiplied by adaptiveBackoffFactor each time a call is throttled, and increases by
+// adaptiveRateIncrease with each call that succeeds.
+type adaptiveLimiter struct {
+	mu sync.Mutex
+
+	// rate is the number of calls per second let through, or 0 until a call is throttled.
+	rate float64
+
+	// next is the earliest time the next call is let through.
+	next time.Time
+
+	// window is the start of the current second, calls the number of calls sent since then, and measured the rate the
+	// calls were sent at during the previous one.
+	window   time.Time
+	calls    int
+	measured float64
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// newAdaptiveLimiter returns an adaptiveLimiter that lets every call through until one is throttled.
+func newAdaptiveLimiter() *adaptiveLimiter {
+	return &adaptiveLimiter{now: time.Now, sleep: time.Sleep}
+}
+
+// wait blocks until the caller is allowed to send its call. It is a Send handler of the AWS API calls, so every attempt
+// waits, retries included.
+func (l *adaptiveLimiter) wait(*request.Request) {
+	l.mu.Lock()
+	now := l.now()
+	if elapsed := now.Sub(l.window); elapsed >= time.Second {
+		l.measured = float64(l.calls) / elapsed.Seconds()
+		l.window, l.calls = now, 0
+	}
+	l.calls++
+	if l.rate == 0 {
+		l.mu.Unlock()
+		return
+	}
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+	l.sleep(at.Sub(now))
+}
+
+// observe adjusts the rate of the limiter to the outcome of an attempt. It is a CompleteAttempt handler of the AWS API
+// calls.
+func (l *adaptiveLimiter) observe(r *request.Request) {
+	throttled := r.Error != nil && r.IsErrorThrottle()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case throttled:
+		rate := l.rate
+		if rate == 0 {
+			rate = l.measured
+		}
+		l.rate = math.Max(rate*adaptiveBackoffFactor, minAdaptiveRate)
+	case r.Error == nil && l.rate > 0:
+		l.rate += adaptiveRateIncrease
+	}
+}
@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewRetryer tests that the retryer attempts the calls up to the max attempts.
+func TestNewRetryer(t *testing.T) {
+	retryer := newRetryer(4, 20*time.Second)
+	assert.Equal(t, 3, retryer.MaxRetries())
+	assert.Equal(t, 20*time.Second, retryer.(client.DefaultRetryer).MaxThrottleDelay)
+}
+
+// TestAdaptiveLimiter tests that the adaptive limiter lets every call through until one is throttled, then spaces the
+// calls out at the rate they were sent at, slowed down, and speeds up again as the calls succeed.
+func TestAdaptiveLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	l := newAdaptiveLimiter()
+	l.now = func() time.Time { return now }
+	l.sleep = func(d time.Duration) { slept = append(slept, d) }
+	l.window = now
+
+	// 10 calls during the first second, none of which waits
+	for i := 0; i < 10; i++ {
+		l.wait(nil)
+		now = now.Add(100 * time.Millisecond)
+	}
+	assert.Empty(t, slept)
+	l.wait(nil)
+	assert.InDelta(t, 10.0, l.measured, 1e-9)
+
+	l.observe(&request.Request{Error: awserr.New("ThrottlingException", "Rate exceeded", nil)})
+	assert.InDelta(t, 7.0, l.rate, 1e-9)
+
+	// the calls are now spaced out by 1/7s
+	l.wait(nil)
+	l.wait(nil)
+	assert.Equal(t, []time.Duration{0, time.Second / 7}, slept)
+
+	l.observe(&request.Request{})
+	assert.InDelta(t, 7.1, l.rate, 1e-9)
+
+	// the rate never drops below the minimum
+	for i := 0; i < 20; i++ {
+		l.observe(&request.Request{Error: awserr.New("Throttling", "Rate exceeded", nil)})
+	}
+	assert.Equal(t, minAdaptiveRate, l.rate)
+
+	// other errors leave the rate alone
+	l.observe(&request.Request{Error: awserr.New("AccessDenied", "denied", nil)})
+	assert.Equal(t, minAdaptiveRate, l.rate)
+}
@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseTLSVersion tests the parseTLSVersion function.
+func TestParseTLSVersion(t *testing.T) {
+	v, err := parseTLSVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+
+	_, err = parseTLSVersion("TLS1.2")
+	assert.EqualError(t, err, `minimum TLS version should be one of 1.0, 1.1, 1.2 or 1.3, got "TLS1.2"`)
+}
+
+// TestNewAWSHTTPClient tests that the AWS HTTP client trusts the certificates of the CA bundle, and enforces the
+// minimum TLS version.
+func TestNewAWSHTTPClient(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca-bundle.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(bundle, certificate, 0o600))
+	empty := filepath.Join(dir, "empty.pem")
+	assert.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	_, err := loadCABundle(empty)
+	assert.Error(t, err)
+	_, err = loadCABundle(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	rootCAs, err := loadCABundle(bundle)
+	assert.NoError(t, err)
+
+	// the certificate of the server is trusted
+	_, err = newAWSHTTPClient(time.Second, tls.VersionTLS12, rootCAs).Get(server.URL)
+	assert.NoError(t, err)
+
+	// the server does not support TLS 1.3
+	_, err = newAWSHTTPClient(time.Second, tls.VersionTLS13, rootCAs).Get(server.URL)
+	assert.Error(t, err)
+
+	// the certificate of the server is not trusted without the CA bundle
+	_, err = newAWSHTTPClient(time.Second, tls.VersionTLS12, nil).Get(server.URL)
+	assert.Error(t, err)
+}
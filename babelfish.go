@@ -0,0 +1,109 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// babelfishEngine is the engine of the clusters that can enable Babelfish, which lets Aurora PostgreSQL understand
+// the T-SQL of SQL Server. Their upgrade path differs, as Babelfish only supports some of the engine versions.
+const babelfishEngine = "aurora-postgresql"
+
+// babelfishParameter is the cluster parameter enabling Babelfish, which is "on" when it is enabled.
+const babelfishParameter = "rds.babelfish_status"
+
+// defaultParameterGroupPrefix prefixes the default parameter groups, which cannot enable Babelfish.
+const defaultParameterGroupPrefix = "default."
+
+// babelfishGroups caches whether the cluster parameter groups enable Babelfish, within a snapshot.
+type babelfishGroups map[string]bool
+
+// resolve sets Babelfish on the Aurora PostgreSQL clusters among the given RDSInfos, and on their member instances,
+// whose cluster parameter group enables it. The parameter groups that are not yet known are described with
+// DescribeDBClusterParameters, limited to the parameters set by the user. The parameter groups of the clusters of the
+// member instances are the ones of the clusterMembership, which should be resolved first. The babelfishGroups and the
+// clusterMembership are only read and written with the given lock held, which is released while the parameter groups
+// are described.
+func (g babelfishGroups) resolve(config *Config, rdsInfos []RDSInfo, membership *clusterMembership,
+	mu sync.Locker) error {
+	for i, rdsInfo := range rdsInfos {
+		if rdsInfo.Engine != babelfishEngine {
+			continue
+		}
+		mu.Lock()
+		group := rdsInfo.ParameterGroup
+		if len(rdsInfo.MemberOf) > 0 {
+			group = membership.parameterGroups[rdsInfo.MemberOf]
+		}
+		enabled, ok := g[group]
+		mu.Unlock()
+		if group == "" || strings.HasPrefix(group, defaultParameterGroupPrefix) {
+			continue
+		}
+		if !ok {
+			var err error
+			enabled, err = describeBabelfish(config, group)
+			if err != nil {
+				return fmt.Errorf("failed to check Babelfish; %w", err)
+			}
+			mu.Lock()
+			g[group] = enabled
+			mu.Unlock()
+		}
+		rdsInfos[i].Babelfish = enabled
+	}
+	return nil
+}
+
+// describeBabelfish reports whether a cluster parameter group enables Babelfish, page by page.
+func describeBabelfish(config *Config, group string) (bool, error) {
+	var nextMarker *string
+	condition := true
+	for condition {
+		parameters, err := config.RDS.DescribeDBClusterParameters(&rds.DescribeDBClusterParametersInput{
+			DBClusterParameterGroupName: aws.String(group),
+			Source:                      aws.String("user"),
+			Marker:                      nextMarker,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to describe DB cluster parameters of %s; %w", group, err)
+		}
+		if parameters == nil {
+			break
+		}
+		for _, parameter := range parameters.Parameters {
+			if aws.StringValue(parameter.ParameterName) == babelfishParameter {
+				return aws.StringValue(parameter.ParameterValue) == "on", nil
+			}
+		}
+		nextMarker = parameters.Marker
+		condition = nextMarker != nil
+	}
+	return false, nil
+}
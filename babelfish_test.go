@@ -0,0 +1,92 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// babelfishRDSAPI is a MockRDSAPI whose cluster parameter groups set the given Babelfish status, and which counts
+// their descriptions.
+type babelfishRDSAPI struct {
+	*MockRDSAPI
+	statuses  map[string]string
+	described map[string]int
+}
+
+func (m babelfishRDSAPI) DescribeDBClusterParameters(input *rds.DescribeDBClusterParametersInput) (*rds.DescribeDBClusterParametersOutput, error) {
+	group := aws.StringValue(input.DBClusterParameterGroupName)
+	m.described[group]++
+	output := &rds.DescribeDBClusterParametersOutput{Parameters: []*rds.Parameter{
+		{ParameterName: Ptr("log_min_duration_statement"), ParameterValue: Ptr("1000")},
+	}}
+	if status, ok := m.statuses[group]; ok {
+		output.Parameters = append(output.Parameters,
+			&rds.Parameter{ParameterName: Ptr(babelfishParameter), ParameterValue: Ptr(status)})
+	}
+	return output, nil
+}
+
+// TestBabelfishGroupsResolve tests that Babelfish is set on the Aurora PostgreSQL clusters whose parameter group
+// enables it, and on their member instances, that each parameter group is described once, and that the default
+// parameter groups are not described.
+func TestBabelfishGroupsResolve(t *testing.T) {
+	api := babelfishRDSAPI{
+		MockRDSAPI: &MockRDSAPI{clustersOutput: []*rds.DescribeDBClustersOutput{{DBClusters: []*rds.DBCluster{{
+			DBClusterIdentifier:     Ptr("migrated"),
+			Engine:                  Ptr("aurora-postgresql"),
+			EngineVersion:           Ptr("14.6"),
+			DBClusterParameterGroup: Ptr("sqlserver-migration"),
+			DBClusterMembers:        []*rds.DBClusterMember{{DBInstanceIdentifier: Ptr("migrated-1")}},
+		}}}}},
+		statuses:  map[string]string{"sqlserver-migration": "on", "datatypes": "datatypeonly"},
+		described: make(map[string]int),
+	}
+	config := &Config{RDS: api}
+	rdsInfos := []RDSInfo{
+		{ClusterIdentifier: "migrated", Engine: "aurora-postgresql", ParameterGroup: "sqlserver-migration"},
+		{ClusterIdentifier: "migrated-copy", Engine: "aurora-postgresql", ParameterGroup: "sqlserver-migration"},
+		{ClusterIdentifier: "migrated-1", Engine: "aurora-postgresql", MemberOf: "migrated"},
+		{ClusterIdentifier: "types", Engine: "aurora-postgresql", ParameterGroup: "datatypes"},
+		{ClusterIdentifier: "orders", Engine: "aurora-postgresql", ParameterGroup: "default.aurora-postgresql14"},
+		{ClusterIdentifier: "catalog", Engine: "aurora-mysql", ParameterGroup: "sqlserver-migration"},
+	}
+	membership := newClusterMembership()
+	assert.NoError(t, membership.resolve(config, rdsInfos, &sync.Mutex{}))
+
+	groups := make(babelfishGroups)
+	assert.NoError(t, groups.resolve(config, rdsInfos, membership, &sync.Mutex{}))
+	var enabled []string
+	for _, rdsInfo := range rdsInfos {
+		if rdsInfo.Babelfish {
+			enabled = append(enabled, rdsInfo.ClusterIdentifier)
+		}
+	}
+	assert.Equal(t, []string{"migrated", "migrated-copy", "migrated-1"}, enabled)
+	assert.Equal(t, map[string]int{"sqlserver-migration": 1, "datatypes": 1}, api.described)
+}
@@ -0,0 +1,199 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// benchEngines are the engines of the synthetic resources of the benchmark. Instances of Aurora engines are members of
+// clusters of two instances.
+var benchEngines = []string{"postgres", "mysql", "aurora-postgresql", "aurora-mysql"}
+
+// benchPageSize is the number of resources per page, as the default MaxRecords of the RDS API.
+const benchPageSize = 100
+
+// benchRDSAPI serves a generated inventory, page by page, to measure how the snapshots scale.
+type benchRDSAPI struct {
+	rdsiface.RDSAPI
+	clusters  []*rds.DBCluster
+	instances []*rds.DBInstance
+	versions  map[string][]*rds.DBEngineVersion
+}
+
+// newBenchRDSAPI generates an inventory of the given number of instances, and of engine versions split between
+// benchEngines. One version out of five is deprecated.
+func newBenchRDSAPI(resources, engineVersions int) *benchRDSAPI {
+	api := &benchRDSAPI{versions: make(map[string][]*rds.DBEngineVersion)}
+	perEngine := engineVersions / len(benchEngines)
+	if perEngine < 1 {
+		perEngine = 1
+	}
+	for _, engine := range benchEngines {
+		for v := 0; v < perEngine; v++ {
+			status := "available"
+			if v%5 == 0 {
+				status = "deprecated"
+			}
+			api.versions[engine] = append(api.versions[engine], &rds.DBEngineVersion{
+				Engine:        aws.String(engine),
+				EngineVersion: aws.String(fmt.Sprintf("%d.%d", 10+v/10, v%10)),
+				Status:        aws.String(status),
+			})
+		}
+	}
+
+	clusters := make(map[string]*rds.DBCluster)
+	for i := 0; i < resources; i++ {
+		engine := benchEngines[i%len(benchEngines)]
+		// the members of a cluster share its version
+		version := api.versions[engine][(i/len(benchEngines)/2)%perEngine].EngineVersion
+		instance := &rds.DBInstance{
+			DBInstanceIdentifier:       aws.String(fmt.Sprintf("instance-%d", i)),
+			DBInstanceClass:            aws.String("db.r6g.large"),
+			Engine:                     aws.String(engine),
+			EngineVersion:              version,
+			PreferredMaintenanceWindow: aws.String("sun:05:00-sun:06:00"),
+		}
+		if strings.HasPrefix(engine, "aurora") {
+			identifier := fmt.Sprintf("%s-cluster-%d", engine, i/len(benchEngines)/2)
+			cluster, ok := clusters[identifier]
+			if !ok {
+				cluster = &rds.DBCluster{
+					DBClusterIdentifier:        aws.String(identifier),
+					Engine:                     aws.String(engine),
+					EngineVersion:              version,
+					PreferredMaintenanceWindow: aws.String("sun:05:00-sun:06:00"),
+				}
+				clusters[identifier] = cluster
+				api.clusters = append(api.clusters, cluster)
+			}
+			cluster.DBClusterMembers = append(cluster.DBClusterMembers, &rds.DBClusterMember{
+				DBInstanceIdentifier: instance.DBInstanceIdentifier,
+				IsClusterWriter:      aws.Bool(len(cluster.DBClusterMembers) == 0),
+			})
+			instance.DBClusterIdentifier = aws.String(identifier)
+		}
+		api.instances = append(api.instances, instance)
+	}
+	return api
+}
+
+// page returns the bounds of the page starting at marker, and the marker of the next page, if any.
+func page(marker *string, total int) (start, end int, next *string) {
+	start, _ = strconv.Atoi(aws.StringValue(marker))
+	end = start + benchPageSize
+	if end >= total {
+		return start, total, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end))
+}
+
+func (b *benchRDSAPI) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	if len(input.Filters) > 0 {
+		// the clusters of member instances are resolved by identifier
+		output := &rds.DescribeDBClustersOutput{}
+		for _, cluster := range b.clusters {
+			if contains(aws.StringValueSlice(input.Filters[0].Values), *cluster.DBClusterIdentifier) {
+				output.DBClusters = append(output.DBClusters, cluster)
+			}
+		}
+		return output, nil
+	}
+	start, end, next := page(input.Marker, len(b.clusters))
+	return &rds.DescribeDBClustersOutput{DBClusters: b.clusters[start:end], Marker: next}, nil
+}
+
+func (b *benchRDSAPI) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	start, end, next := page(input.Marker, len(b.instances))
+	return &rds.DescribeDBInstancesOutput{DBInstances: b.instances[start:end], Marker: next}, nil
+}
+
+func (b *benchRDSAPI) DescribeDBEngineVersions(input *rds.DescribeDBEngineVersionsInput) (*rds.DescribeDBEngineVersionsOutput, error) {
+	versions := b.versions[aws.StringValue(input.Engine)]
+	start, end, next := page(input.Marker, len(versions))
+	return &rds.DescribeDBEngineVersionsOutput{DBEngineVersions: versions[start:end], Marker: next}, nil
+}
+
+// runBench implements the "bench" command. It generates an inventory of synthetic resources and engine versions, then
+// measures, over several iterations, the duration and the allocations of a snapshot, as well as the time to render
+// the /metrics endpoint. The engine catalogs are fetched again at each iteration.
+func runBench(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	resources := fs.Int("resources", 1000, "the number of synthetic RDS instances")
+	engineVersions := fs.Int("engine-versions", 200, "the number of synthetic engine versions")
+	iterations := fs.Int("iterations", 3, "the number of snapshots to measure")
+	concurrency := fs.Int("aws-api-concurrency", defaultAwsApiConcurrency,
+		"the maximum number of paginated AWS API listings performed in parallel")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *resources < 1 || *engineVersions < 1 || *iterations < 1 {
+		return fmt.Errorf("resources, engine versions and iterations should be positive")
+	}
+
+	api := newBenchRDSAPI(*resources, *engineVersions)
+	scopes := &Scopes{
+		Accounts:             []AccountScope{{Regions: []*Config{{RDS: api, Concurrency: *concurrency}}}},
+		MaxAccountsInFlight:  1,
+		MaxRegionsPerAccount: 1,
+	}
+	metrics := NewMetrics()
+	handler := initPromHandler(metrics, nil, nil)
+	fmt.Fprintf(w, "inventory: %d instances, %d clusters, %d engine versions\n",
+		len(api.instances), len(api.clusters), *engineVersions)
+
+	for i := 1; i <= *iterations; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		if err := snapshotScopes(scopes, metrics, newCatalogs(scopes)); err != nil {
+			return err
+		}
+		snapshotDuration := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		rec := httptest.NewRecorder()
+		start = time.Now()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		renderDuration := time.Since(start)
+
+		fmt.Fprintf(w, "iteration %d: snapshot %s, %d allocations (%.1f MiB), /metrics %s (%.1f KiB)\n",
+			i, snapshotDuration.Round(time.Microsecond), after.Mallocs-before.Mallocs,
+			float64(after.TotalAlloc-before.TotalAlloc)/(1<<20), renderDuration.Round(time.Microsecond),
+			float64(rec.Body.Len())/(1<<10))
+	}
+	return nil
+}
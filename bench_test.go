@@ -0,0 +1,60 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// TestNewBenchRDSAPI tests that the generated inventory is served page by page, with two-instance Aurora clusters.
+func TestNewBenchRDSAPI(t *testing.T) {
+	api := newBenchRDSAPI(250, 8)
+	assert.Len(t, api.instances, 250)
+	assert.Len(t, api.versions["postgres"], 2)
+	for _, cluster := range api.clusters {
+		assert.Len(t, cluster.DBClusterMembers, 2, *cluster.DBClusterIdentifier)
+	}
+
+	pages := make([]int, 0)
+	err := getRDSInstances(&Config{RDS: api}, nil, func(rdsInfos []RDSInfo) error {
+		pages = append(pages, len(rdsInfos))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{100, 100, 50}, pages)
+}
+
+// TestRunBench tests the bench command.
+func TestRunBench(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, runBench([]string{"-resources", "40", "-engine-versions", "8", "-iterations", "2"}, &b))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, "inventory: 40 instances, 10 clusters, 8 engine versions", lines[0])
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[2], "iteration 2: snapshot "), lines[2])
+
+	assert.Error(t, runBench([]string{"-resources", "0"}, &b))
+}
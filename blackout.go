@@ -0,0 +1,120 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// blackoutWindow is a window during which the exporter makes no AWS API call, e.g. during a change freeze of a region.
+// It is either a weekly window in UTC, like the RDS maintenance windows, e.g. "fri:18:00-mon:06:00", or a window
+// between two RFC 3339 times, e.g. "2023-12-22T00:00:00Z/2024-01-02T00:00:00Z".
+type blackoutWindow struct {
+	weekly     string
+	start, end time.Time
+}
+
+// blackoutWindows are the blackout windows of the options. The zero value has none.
+type blackoutWindows []blackoutWindow
+
+// parseBlackoutWindows parses comma-separated blackout windows, each of them weekly or between two times.
+func parseBlackoutWindows(s string) (blackoutWindows, error) {
+	var windows blackoutWindows
+	if len(strings.TrimSpace(s)) == 0 {
+		return windows, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		startString, endString, ok := strings.Cut(item, "/")
+		if !ok {
+			// the maintenance windows have the same format, and are validated the same way
+			if _, err := untilMaintenanceWindow(item, time.Now()); err != nil {
+				return nil, fmt.Errorf("invalid blackout window %q; %w", item, err)
+			}
+			windows = append(windows, blackoutWindow{weekly: item})
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, startString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window %q; %w", item, err)
+		}
+		end, err := time.Parse(time.RFC3339, endString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window %q; %w", item, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("invalid blackout window %q: the end should be after the start", item)
+		}
+		windows = append(windows, blackoutWindow{start: start, end: end})
+	}
+	return windows, nil
+}
+
+// contains reports whether the blackout window is open at now.
+func (w blackoutWindow) contains(now time.Time) bool {
+	if w.weekly != "" {
+		until, err := untilMaintenanceWindow(w.weekly, now)
+		return err == nil && until == 0
+	}
+	return !now.Before(w.start) && now.Before(w.end)
+}
+
+// active reports whether one of the blackout windows is open at now.
+func (b blackoutWindows) active(now time.Time) bool {
+	for _, window := range b {
+		if window.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// blackoutState follows the blackout windows from a snapshot to the next, logs when they open and close, and sets the
+// BlackoutGauge. It is not safe for concurrent use.
+type blackoutState struct {
+	windows blackoutWindows
+	metrics *Metrics
+	open    bool
+}
+
+// check reports whether a blackout window is open at now, in which case the snapshot should be skipped.
+func (s *blackoutState) check(now time.Time) bool {
+	open := s.windows.active(now)
+	if open != s.open {
+		if open {
+			log.Print("blackout window open, skipping the snapshots until it closes")
+		} else {
+			log.Print("blackout window closed, resuming the snapshots")
+		}
+		s.open = open
+	}
+	if open {
+		s.metrics.BlackoutGauge.Set(1)
+	} else {
+		s.metrics.BlackoutGauge.Set(0)
+	}
+	return open
+}
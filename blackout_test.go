@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseBlackoutWindows tests the parsing of the weekly blackout windows and of the ones between two times.
+func TestParseBlackoutWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    blackoutWindows
+		wantErr string
+	}{
+		{name: "empty", s: ""},
+		{
+			name: "weekly and between two times",
+			s:    "fri:18:00-mon:06:00, 2023-12-22T00:00:00Z/2024-01-02T00:00:00Z",
+			want: blackoutWindows{
+				{weekly: "fri:18:00-mon:06:00"},
+				{
+					start: time.Date(2023, 12, 22, 0, 0, 0, 0, time.UTC),
+					end:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:    "invalid weekly window",
+			s:       "friday:18:00-mon:06:00",
+			wantErr: "invalid blackout window \"friday:18:00-mon:06:00\"; invalid weekly time \"friday:18:00\": unknown day \"friday\"",
+		},
+		{
+			name:    "invalid time",
+			s:       "2023-12-22/2024-01-02T00:00:00Z",
+			wantErr: "invalid blackout window \"2023-12-22/2024-01-02T00:00:00Z\"; parsing time \"2023-12-22\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"\" as \"T\"",
+		},
+		{
+			name:    "end before start",
+			s:       "2024-01-02T00:00:00Z/2023-12-22T00:00:00Z",
+			wantErr: "invalid blackout window \"2024-01-02T00:00:00Z/2023-12-22T00:00:00Z\": the end should be after the start",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBlackoutWindows(tt.s)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestBlackoutState tests that the snapshots are skipped while a blackout window is open, and that the BlackoutGauge
+// follows it.
+func TestBlackoutState(t *testing.T) {
+	windows, err := parseBlackoutWindows("fri:18:00-mon:06:00,2023-12-22T00:00:00Z/2024-01-02T00:00:00Z")
+	assert.NoError(t, err)
+	metrics := NewMetrics()
+	state := &blackoutState{windows: windows, metrics: metrics}
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{name: "weekday", now: time.Date(2023, 12, 13, 12, 0, 0, 0, time.UTC), want: false},
+		{name: "weekend", now: time.Date(2023, 12, 16, 12, 0, 0, 0, time.UTC), want: true},
+		{name: "end of the weekend", now: time.Date(2023, 12, 18, 6, 0, 0, 0, time.UTC), want: false},
+		{name: "change freeze", now: time.Date(2023, 12, 27, 12, 0, 0, 0, time.UTC), want: true},
+		{name: "end of the change freeze", now: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, state.check(tt.now))
+			want := 0.0
+			if tt.want {
+				want = 1
+			}
+			assert.Equal(t, want, testutil.ToFloat64(metrics.BlackoutGauge))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// versionLabels are the labels of the AvailableGauge and the DeprecatedGauge.
+var versionLabels = []string{"cluster_identifier", "engine", "engine_version", "engine_version_major",
+	"engine_version_minor", "role", "license_model", "edition", "rds_custom", "babelfish", "maintenance_window",
+	"account_id", "region"}
+
+// resourceLabels are the labels identifying a resource, e.g. an instance or a snapshot: the series that have any of
+// them are per-resource series.
+var resourceLabels = []string{"cluster_identifier", "instance_identifier", "snapshot_identifier",
+	"reserved_instance_id", "resource_id"}
+
+// seriesGuard protects Prometheus from the cardinality of the per-resource metrics in huge fleets. It drops labels,
+// which are then exported empty, i.e. absent, hashes the values of others, and caps the number of series of each
+// per-resource metric within a snapshot. The metrics of a group count together against the cap, e.g. the version
+// metrics, which share their labels. A nil seriesGuard leaves the labels untouched and exports every series.
+type seriesGuard struct {
+	drop      map[string]bool
+	hash      map[string]bool
+	maxSeries int
+
+	// series holds the series admitted in the snapshot, and overflow the ones left out, by group.
+	series   map[int]map[string]bool
+	overflow map[int]map[string]bool
+}
+
+// newSeriesGuard returns the seriesGuard of the labels to drop or hash and of the series cap of the options, or nil if
+// none is set.
+func newSeriesGuard(options *Options) *seriesGuard {
+	if len(options.dropLabels) == 0 && len(options.hashLabels) == 0 && options.MaxSeries == 0 {
+		return nil
+	}
+	g := &seriesGuard{
+		drop:      make(map[string]bool),
+		hash:      make(map[string]bool),
+		maxSeries: options.MaxSeries,
+	}
+	g.reset()
+	for _, label := range options.dropLabels {
+		g.drop[label] = true
+	}
+	for _, label := range options.hashLabels {
+		g.hash[label] = true
+	}
+	return g
+}
+
+// reset forgets the series of the previous snapshot.
+func (g *seriesGuard) reset() {
+	if g == nil {
+		return
+	}
+	g.series = make(map[int]map[string]bool)
+	g.overflow = make(map[int]map[string]bool)
+}
+
+// overflowed returns the number of series left out of the snapshot by the series cap.
+func (g *seriesGuard) overflowed() int {
+	if g == nil {
+		return 0
+	}
+	n := 0
+	for _, keys := range g.overflow {
+		n += len(keys)
+	}
+	return n
+}
+
+// dropsLabels reports whether any label is dropped, in which case several resources may share the same series.
+func (g *seriesGuard) dropsLabels() bool {
+	return g != nil && len(g.drop) > 0
+}
+
+// admit returns the labels of a series of a metric of the given group with the labels dropped and hashed, and reports
+// whether the series can be exported without exceeding the series cap of the group. The series already exported in
+// the snapshot are always admitted, and so are the series that are not per-resource, e.g. the totals, untouched.
+// The labels are copied, not modified.
+func (g *seriesGuard) admit(group int, labels prometheus.Labels) (prometheus.Labels, bool) {
+	if g == nil || !isPerResource(labels) {
+		return labels, true
+	}
+	guarded := make(prometheus.Labels, len(labels))
+	names := make([]string, 0, len(labels))
+	for name, value := range labels {
+		switch {
+		case g.drop[name]:
+			value = ""
+		case g.hash[name] && value != "":
+			value = hashLabelValue(value)
+		}
+		guarded[name] = value
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%q,", name, guarded[name])
+	}
+	key := b.String()
+
+	series := g.series[group]
+	if series == nil {
+		series = make(map[string]bool)
+		g.series[group] = series
+	}
+	if series[key] {
+		return guarded, true
+	}
+	if g.maxSeries > 0 && len(series) >= g.maxSeries {
+		if g.overflow[group] == nil {
+			g.overflow[group] = make(map[string]bool)
+		}
+		g.overflow[group][key] = true
+		return guarded, false
+	}
+	series[key] = true
+	return guarded, true
+}
+
+// isPerResource reports whether a series is per-resource, i.e. has a label identifying a resource.
+func isPerResource(labels prometheus.Labels) bool {
+	for _, name := range resourceLabels {
+		if _, ok := labels[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hashLabelValue returns the FNV-1a hash of a label value, in hexadecimal. It is stable across restarts, so that a
+// hashed identifier can still be looked up in the inventory reports.
+func hashLabelValue(value string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(value))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,129 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSeriesGuardExport tests that the series guard drops and hashes the labels of the version metrics, adds up the
+// resources sharing a series once labels are dropped, and leaves the resources beyond the series cap out.
+func TestSeriesGuardExport(t *testing.T) {
+	m := engineVersions{"postgres": {"11.19": {Status: "deprecated"}, "14.7": {Status: "available"}}}
+	resources := []RDSInfo{
+		{ClusterIdentifier: "db-1", Engine: "postgres", EngineVersion: "11.19", Region: "eu-west-1"},
+		{ClusterIdentifier: "db-2", Engine: "postgres", EngineVersion: "11.19", Region: "eu-west-1"},
+		{ClusterIdentifier: "db-3", Engine: "postgres", EngineVersion: "14.7", Region: "eu-west-1"},
+		{ClusterIdentifier: "db-4", Engine: "postgres", EngineVersion: "14.7", Region: "us-east-1"},
+	}
+
+	tests := []struct {
+		name         string
+		options      Options
+		want         map[string]float64
+		wantOverflow float64
+	}{
+		{
+			name:    "hash",
+			options: Options{hashLabels: []string{"cluster_identifier"}},
+			want: map[string]float64{
+				hashLabelValue("db-1") + "/11.19/eu-west-1": 1,
+				hashLabelValue("db-2") + "/11.19/eu-west-1": 1,
+				hashLabelValue("db-3") + "/14.7/eu-west-1":  0,
+				hashLabelValue("db-4") + "/14.7/us-east-1":  0,
+			},
+		},
+		{
+			name:    "drop",
+			options: Options{dropLabels: []string{"cluster_identifier"}},
+			want:    map[string]float64{"/11.19/eu-west-1": 2, "/14.7/eu-west-1": 0, "/14.7/us-east-1": 0},
+		},
+		{
+			name:         "cap",
+			options:      Options{dropLabels: []string{"cluster_identifier"}, MaxSeries: 2},
+			want:         map[string]float64{"/11.19/eu-west-1": 2, "/14.7/eu-west-1": 0},
+			wantOverflow: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := NewMetrics()
+			metrics.SeriesGuard = newSeriesGuard(&tt.options)
+			scratch := metrics.scratch()
+			for _, resource := range resources {
+				assert.NoError(t, export(scratch, resource, m))
+				recordInventory(&Config{}, scratch, resource, m, newSnapshotState())
+			}
+			key := scopeKey{collector: scopeCollectorRDS, account: "111111111111"}
+			metrics.Staleness.record(key, scratch, nil, stalenessNow)
+			metrics.Staleness.apply(metrics, []scopeKey{key})
+
+			assert.Equal(t, len(tt.want), testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_deprecated"))
+			assert.Equal(t, len(tt.want), testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_available"))
+			for key, want := range tt.want {
+				parts := strings.SplitN(key, "/", 3)
+				major, minor, _ := strings.Cut(parts[1], ".")
+				assert.Equal(t, want, servedValue(metrics, metrics.DeprecatedGauge,
+					parts[0], "postgres", parts[1], major, minor, "", "", "", "false", "false", "", "", parts[2]), key)
+			}
+			assert.Equal(t, tt.wantOverflow, testutil.ToFloat64(metrics.SeriesOverflowGauge))
+			// the inventory and the totals are complete anyway
+			assert.Len(t, metrics.Inventory.list(), len(resources))
+			assert.Equal(t, 1.0, servedValue(metrics, metrics.AvailableTotalGauge, "postgres", "", "", "us-east-1"))
+			assert.Equal(t, 2.0, servedValue(metrics, metrics.DeprecatedTotalGauge, "postgres", "", "", "eu-west-1"))
+		})
+	}
+}
+
+// TestSeriesGuardPerResource tests that the series guard drops and hashes the labels of every per-resource metric, caps
+// each of them, and leaves the other metrics untouched.
+func TestSeriesGuardPerResource(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.SeriesGuard = newSeriesGuard(&Options{hashLabels: []string{"cluster_identifier"}, MaxSeries: 2})
+	scratch := metrics.scratch()
+	for _, identifier := range []string{"db-1", "db-2", "db-3"} {
+		scratch.StoppedGauge.WithLabelValues(identifier, "111111111111", "eu-west-1").Set(1)
+		scratch.MaintenanceWindowGauge.WithLabelValues(identifier, "sun:05:00-sun:06:00", "111111111111", "eu-west-1").
+			Set(3600)
+	}
+	scratch.DiscoveredGauge.WithLabelValues("postgres", "111111111111", "eu-west-1").Set(3)
+	key := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "eu-west-1"}
+	metrics.Staleness.record(key, scratch, nil, stalenessNow)
+	metrics.Staleness.apply(metrics, []scopeKey{key})
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_stopped"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_maintenance_window_seconds_until"))
+	assert.Equal(t, 1.0, servedValue(metrics, metrics.StoppedGauge, hashLabelValue("db-1"), "111111111111",
+		"eu-west-1"))
+	assert.Equal(t, 3.0, servedValue(metrics, metrics.DiscoveredGauge, "postgres", "111111111111", "eu-west-1"))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.SeriesOverflowGauge))
+
+	// the series recorded are not hashed twice when they are applied again
+	metrics.Staleness.apply(metrics, []scopeKey{key})
+	assert.Equal(t, 1.0, servedValue(metrics, metrics.StoppedGauge, hashLabelValue("db-1"), "111111111111",
+		"eu-west-1"))
+}
@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+const (
+	memberRoleWriter = "writer"
+	memberRoleReader = "reader"
+)
+
+// clusterMembership caches the writer/reader role of Aurora cluster member instances during a snapshot. Roles change
+// on failover, so a clusterMembership must not outlive a snapshot.
+type clusterMembership struct {
+	// roles maps the identifiers of member instances to their role.
+	roles map[string]string
+
+	// clusters is the set of clusters whose members are known.
+	clusters map[string]bool
+
+	// versions maps the identifiers of the clusters whose members are known to their engine version, and
+	// parameterGroups to their cluster parameter group.
+	versions        map[string]string
+	parameterGroups map[string]string
+}
+
+// newClusterMembership returns an empty clusterMembership.
+func newClusterMembership() *clusterMembership {
+	return &clusterMembership{
+		roles:           make(map[string]string),
+		clusters:        make(map[string]bool),
+		versions:        make(map[string]string),
+		parameterGroups: make(map[string]string),
+	}
+}
+
+// add records the member roles of the clusters among the given RDSInfos.
+func (c *clusterMembership) add(rdsInfos []RDSInfo) {
+	for _, rdsInfo := range rdsInfos {
+		if rdsInfo.Members == nil {
+			continue
+		}
+		c.clusters[rdsInfo.ClusterIdentifier] = true
+		c.versions[rdsInfo.ClusterIdentifier] = rdsInfo.EngineVersion
+		c.parameterGroups[rdsInfo.ClusterIdentifier] = rdsInfo.ParameterGroup
+		for instance, role := range rdsInfo.Members {
+			c.roles[instance] = role
+		}
+	}
+}
+
+// resolve describes the clusters of the member instances among the given RDSInfos whose members are not yet known, so
+// that every member instance gets a role. Clusters are described with DescribeDBClusters filtered on their
+// identifiers. The clusterMembership is only read and written with the given lock held, which is released while the
+// clusters are described.
+func (c *clusterMembership) resolve(config *Config, rdsInfos []RDSInfo, mu sync.Locker) error {
+	mu.Lock()
+	missing := make([]*string, 0)
+	seen := make(map[string]bool)
+	for _, rdsInfo := range rdsInfos {
+		if len(rdsInfo.MemberOf) == 0 || c.clusters[rdsInfo.MemberOf] || seen[rdsInfo.MemberOf] {
+			continue
+		}
+		seen[rdsInfo.MemberOf] = true
+		missing = append(missing, aws.String(rdsInfo.MemberOf))
+	}
+	mu.Unlock()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	filters := []*rds.Filter{{Name: Ptr("db-cluster-id"), Values: missing}}
+	err := getRDSClusters(config, filters, func(clusterInfos []RDSInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+		c.add(clusterInfos)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve cluster membership; %w", err)
+	}
+	return nil
+}
+
+// setRoles sets the Role of the member instances among the given RDSInfos.
+func (c *clusterMembership) setRoles(rdsInfos []RDSInfo) {
+	for i := range rdsInfos {
+		if len(rdsInfos[i].MemberOf) > 0 {
+			rdsInfos[i].Role = c.roles[rdsInfos[i].ClusterIdentifier]
+		}
+	}
+}
+
+// clusterMembers returns the role of each member instance of an Aurora cluster, keyed by instance identifier.
+func clusterMembers(rdsCluster *rds.DBCluster) map[string]string {
+	members := make(map[string]string, len(rdsCluster.DBClusterMembers))
+	for _, member := range rdsCluster.DBClusterMembers {
+		role := memberRoleReader
+		if aws.BoolValue(member.IsClusterWriter) {
+			role = memberRoleWriter
+		}
+		members[aws.StringValue(member.DBInstanceIdentifier)] = role
+	}
+	return members
+}
+
+// exportClusterMembers sets the ClusterMemberCountGauge to the number of members of an RDS cluster, and the
+// ClusterMemberInfoGauge for each of them, with the engine version of the cluster. Nothing is exported for instances.
+func exportClusterMembers(metrics *Metrics, rdsInfo RDSInfo) {
+	if rdsInfo.Members == nil {
+		return
+	}
+	metrics.ClusterMemberCountGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"engine":             rdsInfo.Engine,
+		"engine_version":     rdsInfo.EngineVersion,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(float64(len(rdsInfo.Members)))
+	for instance, role := range rdsInfo.Members {
+		metrics.ClusterMemberInfoGauge.With(prometheus.Labels{
+			"cluster_identifier":  rdsInfo.ClusterIdentifier,
+			"instance_identifier": instance,
+			"role":                role,
+			"engine":              rdsInfo.Engine,
+			"engine_version":      rdsInfo.EngineVersion,
+			"account_id":          rdsInfo.Account,
+			"region":              rdsInfo.Region,
+		}).Set(1)
+	}
+}
+
+// exportClusterVersionMismatch sets the ClusterVersionMismatchGauge of a member instance of an Aurora cluster to 1 if its
+// engine version differs from the one of its cluster, e.g. when a reader failed to be patched along the cluster, and
+// to 0 otherwise. Nothing is exported for the clusters, the instances that are not cluster members, and the members of
+// clusters whose engine version is unknown.
+func exportClusterVersionMismatch(metrics *Metrics, rdsInfo RDSInfo, membership *clusterMembership) {
+	clusterVersion, ok := membership.versions[rdsInfo.MemberOf]
+	if len(rdsInfo.MemberOf) == 0 || !ok {
+		return
+	}
+	mismatch := 0.0
+	if rdsInfo.EngineVersion != clusterVersion {
+		mismatch = 1
+	}
+	metrics.ClusterVersionMismatchGauge.With(prometheus.Labels{
+		"cluster_identifier":     rdsInfo.MemberOf,
+		"instance_identifier":    rdsInfo.ClusterIdentifier,
+		"engine":                 rdsInfo.Engine,
+		"cluster_engine_version": clusterVersion,
+		"engine_version":         rdsInfo.EngineVersion,
+		"account_id":             rdsInfo.Account,
+		"region":                 rdsInfo.Region,
+	}).Set(mismatch)
+}
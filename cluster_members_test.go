@@ -0,0 +1,145 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestClusterMembership tests the clusterMembership type.
+func TestClusterMembership(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{
+		clustersOutput: []*rds.DescribeDBClustersOutput{{
+			DBClusters: []*rds.DBCluster{
+				{
+					DBClusterIdentifier: Ptr("cluster-1"),
+					Engine:              Ptr("aurora-mysql"),
+					EngineVersion:       Ptr("8.0.mysql_aurora.3.02.0"),
+					DBClusterMembers: []*rds.DBClusterMember{
+						{DBInstanceIdentifier: Ptr("cluster-1-a"), IsClusterWriter: Ptr(true)},
+						{DBInstanceIdentifier: Ptr("cluster-1-b"), IsClusterWriter: Ptr(false)},
+					},
+				},
+				{
+					DBClusterIdentifier: Ptr("cluster-2"),
+					Engine:              Ptr("aurora-mysql"),
+					EngineVersion:       Ptr("8.0.mysql_aurora.3.02.0"),
+					DBClusterMembers: []*rds.DBClusterMember{
+						{DBInstanceIdentifier: Ptr("cluster-2-a"), IsClusterWriter: Ptr(true)},
+					},
+				},
+			},
+		}},
+	}}
+
+	membership := newClusterMembership()
+	// cluster-2 is known from a cluster page, cluster-1 must be resolved
+	membership.add([]RDSInfo{{
+		ClusterIdentifier: "cluster-2",
+		Members:           map[string]string{"cluster-2-a": "reader"},
+	}})
+
+	rdsInfos := []RDSInfo{
+		{ClusterIdentifier: "cluster-1-a", MemberOf: "cluster-1"},
+		{ClusterIdentifier: "cluster-1-b", MemberOf: "cluster-1"},
+		{ClusterIdentifier: "cluster-2-a", MemberOf: "cluster-2"},
+		{ClusterIdentifier: "standalone"},
+	}
+	assert.NoError(t, membership.resolve(config, rdsInfos, &sync.Mutex{}))
+	membership.setRoles(rdsInfos)
+
+	assert.Equal(t, "writer", rdsInfos[0].Role)
+	assert.Equal(t, "reader", rdsInfos[1].Role)
+	// the role recorded from the cluster page is not described again
+	assert.Equal(t, "reader", rdsInfos[2].Role)
+	assert.Equal(t, "", rdsInfos[3].Role)
+}
+
+// TestExportClusterMembers tests that exportClusterMembers exports the member count and the members of clusters, and
+// nothing for instances.
+func TestExportClusterMembers(t *testing.T) {
+	metrics := NewMetrics()
+	exportClusterMembers(metrics, RDSInfo{
+		ClusterIdentifier: "cluster-1",
+		Engine:            "aurora-postgresql",
+		EngineVersion:     "11.9",
+		Region:            "eu-west-1",
+		Members:           map[string]string{"cluster-1-a": "writer", "cluster-1-b": "reader"},
+	})
+	exportClusterMembers(metrics, RDSInfo{
+		ClusterIdentifier: "cluster-1-a",
+		Engine:            "aurora-postgresql",
+		EngineVersion:     "11.9",
+		Region:            "eu-west-1",
+		MemberOf:          "cluster-1",
+	})
+
+	want := `# HELP aws_custom_rds_cluster_member_count Number of member instances of RDS clusters
+# TYPE aws_custom_rds_cluster_member_count gauge
+aws_custom_rds_cluster_member_count{account_id="",cluster_identifier="cluster-1",engine="aurora-postgresql",engine_version="11.9",region="eu-west-1"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.ClusterMemberCountGauge, strings.NewReader(want)))
+	want = `# HELP aws_custom_rds_cluster_member_info Member instances of RDS clusters, with their writer or reader role
+# TYPE aws_custom_rds_cluster_member_info gauge
+aws_custom_rds_cluster_member_info{account_id="",cluster_identifier="cluster-1",engine="aurora-postgresql",engine_version="11.9",instance_identifier="cluster-1-a",region="eu-west-1",role="writer"} 1
+aws_custom_rds_cluster_member_info{account_id="",cluster_identifier="cluster-1",engine="aurora-postgresql",engine_version="11.9",instance_identifier="cluster-1-b",region="eu-west-1",role="reader"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.ClusterMemberInfoGauge, strings.NewReader(want)))
+}
+
+// TestExportClusterVersionMismatch tests that the member instances whose engine version differs from the one of their
+// cluster are flagged, and that nothing is exported for the other resources.
+func TestExportClusterVersionMismatch(t *testing.T) {
+	membership := newClusterMembership()
+	membership.add([]RDSInfo{{
+		ClusterIdentifier: "cluster-1",
+		EngineVersion:     "13.9",
+		Members:           map[string]string{"cluster-1-a": "writer", "cluster-1-b": "reader"},
+	}})
+
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "cluster-1", Engine: "aurora-postgresql", EngineVersion: "13.9", Region: "eu-west-1"},
+		{ClusterIdentifier: "cluster-1-a", Engine: "aurora-postgresql", EngineVersion: "13.9", Region: "eu-west-1",
+			MemberOf: "cluster-1"},
+		{ClusterIdentifier: "cluster-1-b", Engine: "aurora-postgresql", EngineVersion: "13.7", Region: "eu-west-1",
+			MemberOf: "cluster-1"},
+		{ClusterIdentifier: "cluster-2-a", Engine: "aurora-postgresql", EngineVersion: "13.7", Region: "eu-west-1",
+			MemberOf: "cluster-2"},
+		{ClusterIdentifier: "standalone", Engine: "postgres", EngineVersion: "13.7", Region: "eu-west-1"},
+	} {
+		exportClusterVersionMismatch(metrics, rdsInfo, membership)
+	}
+
+	want := `# HELP aws_custom_rds_cluster_version_mismatch Member instances of RDS clusters whose engine version differs from the one of their cluster
+# TYPE aws_custom_rds_cluster_version_mismatch gauge
+aws_custom_rds_cluster_version_mismatch{account_id="",cluster_engine_version="13.9",cluster_identifier="cluster-1",engine="aurora-postgresql",engine_version="13.7",instance_identifier="cluster-1-b",region="eu-west-1"} 1
+aws_custom_rds_cluster_version_mismatch{account_id="",cluster_engine_version="13.9",cluster_identifier="cluster-1",engine="aurora-postgresql",engine_version="13.9",instance_identifier="cluster-1-a",region="eu-west-1"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.ClusterVersionMismatchGauge, strings.NewReader(want)))
+}
@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The collectors that can be polled less often than the inventory, in the collector intervals.
+const (
+	collectorForcedUpgrades    = "forced_upgrades"
+	collectorReservedInstances = "reserved_instances"
+	collectorDBSnapshots       = "db_snapshots"
+)
+
+// minCollectorInterval and maxCollectorInterval bound the collector intervals.
+const (
+	minCollectorInterval = time.Minute
+	maxCollectorInterval = 7 * 24 * time.Hour
+)
+
+// parseCollectorIntervals parses comma-separated collector intervals, e.g.
+// "db_snapshots=6h,reserved_instances=24h", into the interval of each collector.
+func parseCollectorIntervals(s string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	if len(strings.TrimSpace(s)) == 0 {
+		return intervals, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		collector, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid collector interval %q: should be collector=interval", item)
+		}
+		switch collector {
+		case collectorForcedUpgrades, collectorReservedInstances, collectorDBSnapshots:
+		default:
+			return nil, fmt.Errorf("invalid collector interval %q: collector should be one of %q, %q or %q", item,
+				collectorForcedUpgrades, collectorReservedInstances, collectorDBSnapshots)
+		}
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collector interval %q; %w", item, err)
+		}
+		if interval < minCollectorInterval || interval > maxCollectorInterval {
+			return nil, fmt.Errorf("invalid collector interval %q: interval should be between %s and %s", item,
+				minCollectorInterval, maxCollectorInterval)
+		}
+		intervals[collector] = interval
+	}
+	return intervals, nil
+}
+
+// listingCache caches the listing of a collector of a Config, e.g. its reserved DB instances, between the snapshots
+// for the interval of the collector, so that the heavy collectors are polled less often than the inventory. A nil
+// listingCache lists at every snapshot.
+type listingCache[T any] struct {
+	interval time.Duration
+
+	mu        sync.Mutex
+	listedAt  time.Time
+	listing   T
+	populated bool
+}
+
+// newListingCache returns a listingCache of the given interval, or nil if it is 0.
+func newListingCache[T any](interval time.Duration) *listingCache[T] {
+	if interval == 0 {
+		return nil
+	}
+	return &listingCache[T]{interval: interval}
+}
+
+// get returns the cached listing if it was listed less than the interval before now, otherwise it lists it again with
+// list and caches it. A failed listing is not cached.
+func (c *listingCache[T]) get(now time.Time, list func() (T, error)) (T, error) {
+	if c == nil {
+		return list()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.populated && now.Sub(c.listedAt) < c.interval {
+		return c.listing, nil
+	}
+	listing, err := list()
+	if err != nil {
+		return listing, err
+	}
+	c.listing, c.listedAt, c.populated = listing, now, true
+	return listing, nil
+}
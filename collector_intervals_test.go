@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestParseCollectorIntervals tests the parseCollectorIntervals function.
+func TestParseCollectorIntervals(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]time.Duration
+		wantErr string
+	}{
+		{name: "empty", want: map[string]time.Duration{}},
+		{
+			name: "intervals",
+			s:    "db_snapshots=6h, reserved_instances=24h",
+			want: map[string]time.Duration{collectorDBSnapshots: 6 * time.Hour, collectorReservedInstances: 24 * time.Hour},
+		},
+		{
+			name:    "unknown collector",
+			s:       "inventory=1m",
+			wantErr: `invalid collector interval "inventory=1m": collector should be one of "forced_upgrades", "reserved_instances" or "db_snapshots"`,
+		},
+		{
+			name:    "missing interval",
+			s:       "db_snapshots",
+			wantErr: `invalid collector interval "db_snapshots": should be collector=interval`,
+		},
+		{
+			name:    "interval out of bounds",
+			s:       "db_snapshots=10s",
+			wantErr: `invalid collector interval "db_snapshots=10s": interval should be between 1m0s and 168h0m0s`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCollectorIntervals(tt.s)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestListingCache tests that the listing is only listed again once the interval is over, and that the failed
+// listings are not cached.
+func TestListingCache(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	calls := 0
+	var err error
+	list := func() (int, error) {
+		calls++
+		return calls, err
+	}
+
+	cache := newListingCache[int](time.Hour)
+	got, _ := cache.get(now, list)
+	assert.Equal(t, 1, got)
+	got, _ = cache.get(now.Add(30*time.Minute), list)
+	assert.Equal(t, 1, got)
+
+	err = errors.New("Throttling")
+	_, gotErr := cache.get(now.Add(time.Hour), list)
+	assert.Error(t, gotErr)
+	err = nil
+	got, _ = cache.get(now.Add(time.Hour), list)
+	assert.Equal(t, 3, got)
+
+	// a nil cache lists at every call
+	var uncached *listingCache[int]
+	got, _ = uncached.get(now, list)
+	assert.Equal(t, 4, got)
+}
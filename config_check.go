@@ -0,0 +1,88 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// deprecatedEnvs maps the deprecated environment variables, still accepted as aliases, to their replacement.
+var deprecatedEnvs = map[string]string{
+	AwsApiIntervalEnvName: PollIntervalEnvName,
+}
+
+// checkConfig checks the configuration file at path as the exporter would load it at startup, merged with the
+// environment variables read with lookupEnv. Unlike the exporter, it rejects the unknown keys and the values of the
+// wrong type, e.g. a misspelled option that would otherwise be ignored. The references to secrets are parsed, but not
+// resolved, so that the check needs neither AWS credentials nor network access. It returns the effective Options, and
+// a warning for each deprecated option in use.
+func checkConfig(path string, lookupEnv func(string) (string, bool)) (*Options, []string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read configuration file; %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, defaultOptions()); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse configuration file %s; %w", path, err)
+	}
+	o := defaultOptions()
+	o.checkOnly = true
+	o, err = o.load([]string{"-config-file", path}, lookupEnv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for name, replacement := range deprecatedEnvs {
+		if value, ok := lookupEnv(name); ok && value != "" {
+			warnings = append(warnings, fmt.Sprintf("environment variable %s is deprecated, use %s instead",
+				name, replacement))
+		}
+	}
+	sort.Strings(warnings)
+	return o, warnings, nil
+}
+
+// runConfig is the "config" subcommand. "config check <file>" checks a configuration file with checkConfig, then writes
+// the warnings and the effective configuration to w, with the values of secret options redacted. It returns an error,
+// hence exits non-zero, if the configuration is not valid, so that CI can verify it before deployment.
+func runConfig(args []string, w io.Writer) error {
+	if len(args) != 2 || args[0] != "check" {
+		return fmt.Errorf("usage: %s config check <file>", exporterName)
+	}
+	o, warnings, err := checkConfig(args[1], os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		if _, err := fmt.Fprintf(w, "warning: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "configuration file %s is valid:\n%s", args[1], o)
+	return err
+}
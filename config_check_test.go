@@ -0,0 +1,116 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheckConfig tests that checkConfig rejects the unknown keys and the invalid values of a configuration file, and
+// warns about the deprecated options in use.
+func TestCheckConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       string
+		env          map[string]string
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name:   "valid",
+			config: "server_port: 2112\npoll_interval: 1m\nsentry_dsn: https://key@sentry.example.com/1\n",
+		},
+		{
+			name:         "deprecated environment variable",
+			config:       "server_port: 2112\n",
+			env:          map[string]string{AwsApiIntervalEnvName: "60"},
+			wantWarnings: []string{"environment variable EXPORTER_AWS_API_INTERVAL_SECONDS is deprecated, use EXPORTER_POLL_INTERVAL instead"},
+		},
+		{
+			name:    "unknown key",
+			config:  "server_port: 2112\npoll_intervall: 1m\n",
+			wantErr: "field poll_intervall not found in type main.Options",
+		},
+		{
+			name:    "wrong type",
+			config:  "server_port: many\n",
+			wantErr: "cannot unmarshal !!str `many` into int",
+		},
+		{
+			name: "secret references, not resolved",
+			config: "server_port: 2112\nsentry_dsn: secretsmanager://exporter/sentry\nnats_url: ssm:///exporter/nats-url\n" +
+				"sinks:\n  - type: graphite\n    url: secretsmanager://exporter/graphite#address\n",
+		},
+		{
+			name:    "invalid secret reference",
+			config:  "server_port: 2112\nsentry_dsn: secretsmanager://exporter/sentry#\n",
+			wantErr: "sentry-dsn could not be resolved: secretsmanager://exporter/sentry# should name the key of the secret after #",
+		},
+		{
+			name:    "invalid value",
+			config:  "server_port: 2112\ndiscovery_backend: scan\n",
+			wantErr: `invalid configuration: discovery backend should be either "describe" or "tagging", got "scan"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.config), 0o600))
+			lookupEnv := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+			o, warnings, err := checkConfig(path, lookupEnv)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 2112, o.ServerPort)
+			assert.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+// TestRunConfig tests that the config check subcommand prints the effective configuration, with the secrets redacted.
+func TestRunConfig(t *testing.T) {
+	// set by TestMain
+	t.Setenv(AwsApiIntervalEnvName, "")
+	t.Setenv(ServerPortEnvName, "")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("server_port: 2112\nsentry_dsn: https://key@sentry.example.com/1\n"), 0o600))
+	var b bytes.Buffer
+	assert.NoError(t, runConfig([]string{"check", path}, &b))
+	assert.Contains(t, b.String(), "is valid:\n  server-port: 2112\n")
+	assert.Contains(t, b.String(), "  sentry-dsn: <redacted>\n")
+
+	assert.EqualError(t, runConfig([]string{"lint", path}, &b),
+		"usage: prometheus-exporter-aws-rds-engine-version config check <file>")
+}
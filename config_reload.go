@@ -0,0 +1,229 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// configReloadInterval is the interval between two checks of the configuration file. The file is read rather than
+// watched for events, as the files of the Kubernetes ConfigMaps are replaced by swapping a symbolic link of their
+// parent directory.
+const configReloadInterval = 10 * time.Second
+
+// reloadableOptions are the options applied by a reload, through the Scopes built from them: the accounts, the regions
+// and the AWS clients, the filters, the collectors and the remediation policy. The acknowledgements and the API filters
+// of the configuration file are applied as well.
+var reloadableOptions = map[string]bool{
+	"collector-intervals": true, "aws-api-timeout": true, "aws-api-concurrency": true, "aws-api-rate-limit": true,
+	"aws-retry-mode": true, "aws-max-attempts": true, "aws-max-backoff": true, "regions": true, "exclude-regions": true,
+	"assume-roles": true, "max-accounts-in-flight": true, "max-regions-per-account": true, "aws-ca-bundle": true,
+	"aws-min-tls-version": true, "discovery-backend": true, "tag-filters": true, "global-clusters": true,
+	"instance-classes": true, "babelfish": true, "catalog-info": true, "db-snapshots": true,
+	"forced-upgrade-deadlines": true, "reserved-instances": true, "health-events": true, "trusted-advisor": true,
+	"support-calendar": true, "support-calendar-file": true, "policy-url": true, "policy-refresh-interval": true,
+	"extended-support-cost": true, "upgrade-recommendations": true, "upgrade-preference": true,
+	"prefer-default-version": true, "auto-minor-remediation": true, "remediation-apply": true,
+	"remediation-tag-filters": true, "owners-file": true, "exclude-stopped": true, "log-level": true, "demo": true,
+	"record-dir": true, "replay-dir": true, "sentry-dsn": true, "otel-endpoint": true, "otel-headers": true,
+	"user-agent-suffix": true,
+}
+
+// configReloader loads the options again when the content of the configuration file changes, hands the Scopes of
+// the new options over to the snapshot loop, and applies their acknowledgements. The previous options are kept if the
+// new ones are not valid. The other options, e.g. the listeners, the intervals and the outputs of the exporter, keep
+// their startup values: their changes are logged, as they only apply after a restart.
+// A nil configReloader does nothing.
+type configReloader struct {
+	// args and lookupEnv are the command-line flags and the environment the options are loaded from, and started the
+	// options loaded at startup.
+	args       []string
+	lookupEnv  func(string) (string, bool)
+	started    *Options
+	path       string
+	metrics    *Metrics
+	controller *kubernetesController
+
+	// mu serializes the reloads, and guards the checksum of the content of the configuration file last loaded.
+	mu       sync.Mutex
+	checksum [sha256.Size]byte
+
+	// pending holds the Scopes of the last reload, until the snapshot loop takes them.
+	pending chan *Scopes
+}
+
+// newConfigReloader returns the configReloader of the options, or nil if the reload of the configuration file is not
+// enabled. The Scopes of the new options are built by the controller, if any, which applies its spec on top of them.
+func newConfigReloader(options *Options, args []string, lookupEnv func(string) (string, bool), metrics *Metrics,
+	controller *kubernetesController) *configReloader {
+	if !options.ConfigReload {
+		return nil
+	}
+	r := &configReloader{
+		args:       args,
+		lookupEnv:  lookupEnv,
+		started:    options,
+		path:       options.configFile,
+		metrics:    metrics,
+		controller: controller,
+		pending:    make(chan *Scopes, 1),
+	}
+	if b, err := os.ReadFile(r.path); err == nil {
+		r.checksum = sha256.Sum256(b)
+	}
+	r.setResult(nil)
+	return r
+}
+
+// run checks the configuration file every configReloadInterval. It never returns.
+func (r *configReloader) run() {
+	for range time.Tick(configReloadInterval) {
+		if err := r.reload(false); err != nil {
+			log.Print(err)
+		}
+	}
+}
+
+// reload loads the options again if the content of the configuration file changed since it was last loaded, or in
+// any case if force is set. A content that cannot be loaded is not retried until it changes again.
+func (r *configReloader) reload(force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		err = fmt.Errorf("failed to reload the configuration, keeping the previous one; %w", err)
+		r.setResult(err)
+		return err
+	}
+	checksum := sha256.Sum256(b)
+	if !force && checksum == r.checksum {
+		return nil
+	}
+	r.checksum = checksum
+
+	options, err := loadOptions(r.args, r.lookupEnv)
+	if err == nil {
+		if r.controller != nil {
+			err = r.controller.reconfigure(options)
+		} else {
+			var scopes *Scopes
+			if scopes, err = NewScopes(options); err == nil {
+				select {
+				case <-r.pending:
+				default:
+				}
+				r.pending <- scopes
+			}
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to reload the configuration, keeping the previous one; %w", err)
+	} else {
+		r.metrics.Acknowledgements.reconfigure(options.Acknowledgements)
+		log.Printf("reloaded configuration:\n%s", options)
+		if changed := restartChanges(r.started, options); len(changed) > 0 {
+			log.Printf("the changes of %s only apply after a restart", strings.Join(changed, ", "))
+		}
+	}
+	r.setResult(err)
+	return err
+}
+
+// restartChanges returns the keys of the options of the configuration file that differ between the startup options
+// and the reloaded ones, but are not applied by a reload.
+func restartChanges(started, reloaded *Options) []string {
+	var changed []string
+	specs := started.specs()
+	for i, spec := range reloaded.specs() {
+		if !reloadableOptions[spec.flag] && spec.value.String() != specs[i].value.String() {
+			changed = append(changed, strings.ReplaceAll(spec.flag, "-", "_"))
+		}
+	}
+	for _, setting := range []struct {
+		key               string
+		started, reloaded interface{}
+	}{
+		{"relabel_configs", started.RelabelConfigs, reloaded.RelabelConfigs},
+		{"sinks", started.Sinks, reloaded.Sinks},
+		{"tenants", started.Tenants, reloaded.Tenants},
+	} {
+		before, _ := yaml.Marshal(setting.started)
+		after, _ := yaml.Marshal(setting.reloaded)
+		if !bytes.Equal(before, after) {
+			changed = append(changed, setting.key)
+		}
+	}
+	return changed
+}
+
+// setResult exports the outcome of a reload.
+func (r *configReloader) setResult(err error) {
+	labels := prometheus.Labels{"config_file": r.path}
+	if err != nil {
+		r.metrics.ConfigReloadSuccessGauge.With(labels).Set(0)
+		return
+	}
+	r.metrics.ConfigReloadSuccessGauge.With(labels).Set(1)
+	r.metrics.ConfigReloadTimestampGauge.With(labels).SetToCurrentTime()
+}
+
+// updates returns the channel of the Scopes of the reloaded options, which never receives anything if r is nil. When
+// the controller builds the Scopes, it hands them over itself.
+func (r *configReloader) updates() <-chan *Scopes {
+	if r == nil {
+		return nil
+	}
+	return r.pending
+}
+
+// reloadHandler reloads the configuration file on POST, whether its content changed or not, and responds with the
+// error of the reload if any.
+func reloadHandler(reloader *configReloader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if reloader == nil {
+			http.Error(w, "config reload is not enabled", http.StatusNotFound)
+			return
+		}
+		if err := reloader.reload(true); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
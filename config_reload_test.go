@@ -0,0 +1,122 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConfigReloader tests that the Scopes of the configuration file are handed over when its content changes, and
+// that the previous configuration is kept when the new one is not valid.
+func TestConfigReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(config string) {
+		assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	}
+	write("server_port: 2112\ndemo: true\nconfig_reload: true\nregions: eu-west-1\n")
+	args := []string{"-config-file", path}
+	noEnv := func(string) (string, bool) { return "", false }
+	options, err := loadOptions(args, noEnv)
+	assert.NoError(t, err)
+	metrics := NewMetrics()
+	reloader := newConfigReloader(options, args, noEnv, metrics, nil)
+	success := metrics.ConfigReloadSuccessGauge.WithLabelValues(path)
+	assert.Equal(t, 1.0, testutil.ToFloat64(success))
+
+	// unchanged
+	assert.NoError(t, reloader.reload(false))
+	assert.Len(t, reloader.updates(), 0)
+
+	write("server_port: 2112\ndemo: true\nconfig_reload: true\nregions: us-east-1\n")
+	assert.NoError(t, reloader.reload(false))
+	scopes := <-reloader.updates()
+	assert.Equal(t, "us-east-1", scopes.configs()[0].Region)
+
+	write("server_port: 2112\ndemo: true\nconfig_reload: true\nregions: us-east-1\nexclude_regions: eu-west-1\n")
+	assert.EqualError(t, reloader.reload(false), `failed to reload the configuration, keeping the previous one; `+
+		`invalid configuration: excluded regions require regions to be "all"`)
+	assert.Len(t, reloader.updates(), 0)
+	assert.Equal(t, 0.0, testutil.ToFloat64(success))
+	// the same content is not loaded again, unless forced
+	assert.NoError(t, reloader.reload(false))
+	assert.Error(t, reloader.reload(true))
+
+	recorder := httptest.NewRecorder()
+	reloadHandler(reloader).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	write("server_port: 2112\ndemo: true\nconfig_reload: true\n")
+	recorder = httptest.NewRecorder()
+	reloadHandler(reloader).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Len(t, (<-reloader.updates()).configs(), len(demoRegions))
+	assert.Equal(t, 1.0, testutil.ToFloat64(success))
+
+	recorder = httptest.NewRecorder()
+	reloadHandler(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+// TestConfigReloaderAcknowledgements tests that the acknowledgements of the configuration file are replaced on reload
+// while the ones of the API are kept, and that the changes needing a restart are reported.
+func TestConfigReloaderAcknowledgements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(config string) {
+		assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+	}
+	const base = "server_port: 2112\ndemo: true\nconfig_reload: true\n"
+	write(base + "acknowledgements:\n  - identifier: legacy-cms\n    expires: 2099-01-01T00:00:00Z\n" +
+		"    reason: decommissioned\n")
+	args := []string{"-config-file", path}
+	noEnv := func(string) (string, bool) { return "", false }
+	options, err := loadOptions(args, noEnv)
+	assert.NoError(t, err)
+	metrics := NewMetrics()
+	metrics.Acknowledgements = newAcknowledgements(options)
+	reloader := newConfigReloader(options, args, noEnv, metrics, nil)
+	metrics.Acknowledgements.put(acknowledgement{Identifier: "billing", Expires: time.Date(2099, 1, 1, 0, 0, 0, 0,
+		time.UTC), Reason: "migrated"})
+
+	write(base + "acknowledgements:\n  - identifier: reporting\n    expires: 2099-01-01T00:00:00Z\n" +
+		"    reason: decommissioned\n")
+	assert.NoError(t, reloader.reload(false))
+	var identifiers []string
+	for _, ack := range metrics.Acknowledgements.items {
+		identifiers = append(identifiers, ack.Identifier)
+	}
+	assert.Equal(t, []string{"billing", "reporting"}, identifiers)
+
+	reloaded := *options
+	reloaded.Regions = "us-east-1"
+	assert.Empty(t, restartChanges(options, &reloaded))
+	reloaded.ServerPort = 2113
+	reloaded.Tenants = []tenantConfig{{Name: "payments"}}
+	assert.Equal(t, []string{"server_port", "tenants"}, restartChanges(options, &reloaded))
+}
@@ -0,0 +1,208 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField describes a field of a cron expression: its name, its range of values, and the names of its values, if any,
+// starting from min. In the day of week field, sunday is set, and a range ending on Sunday, 0, ends on 7, e.g.
+// "MON-SUN".
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+	sunday   bool
+}
+
+// cronFields are the 5 fields of a cron expression. Sunday is both 0 and 7 in the day of week field.
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+		sunday: true},
+}
+
+// cronSchedule is a parsed cron expression of 5 fields: minute, hour, day of month, month and day of week, e.g.
+// "0 8 * * MON" for every monday at 8:00. Each field is a set of values, one bit per value. Schedules are evaluated in
+// UTC, like the RDS maintenance windows.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+
+	// anyDay and anyWeekday are true when the day of month, resp. the day of week, covers its whole range, e.g. "*" or
+	// "*/1". As in standard cron, a day matches when it matches either field if both are restricted.
+	anyDay, anyWeekday bool
+}
+
+// parseCronSchedule parses a cron expression of 5 space separated fields. Each field is "*", a value, a range of values
+// "a-b", or a comma separated list of them, optionally followed by a step "/n", e.g. "*/15 8-18 * * MON-FRI". Months and
+// days of week may be given by their 3-letter english names.
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected %d fields, got %d", expr, len(cronFields), len(fields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, field := range cronFields {
+		set, err := parseCronField(fields[i], field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s %w", expr, field.name, err)
+		}
+		sets[i] = set
+	}
+	// sunday is both 0 and 7
+	if sets[4]&(1<<7|1) != 0 {
+		sets[4] |= 1<<7 | 1
+	}
+	return &cronSchedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     sets[2] == cronFields[2].all(),
+		anyWeekday: sets[4] == cronFields[4].all(),
+	}, nil
+}
+
+// all returns the set of every value of the field.
+func (f cronField) all() uint64 {
+	return (1<<(f.max+1) - 1) &^ (1<<f.min - 1)
+}
+
+// parseCronField parses a field of a cron expression into the set of its values.
+func parseCronField(s string, field cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		values, stepString, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepString); err != nil || step < 1 {
+				return 0, fmt.Errorf("has an invalid step %q", stepString)
+			}
+		}
+
+		lo, hi := field.min, field.max
+		if values != "*" {
+			loString, hiString, isRange := strings.Cut(values, "-")
+			var err error
+			if lo, err = parseCronValue(loString, field); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if hi, err = parseCronValue(hiString, field); err != nil {
+					return 0, err
+				}
+				if field.sunday && hi == 0 && lo > 0 {
+					hi = 7
+				}
+			case !hasStep:
+				// "5/10" starts at 5 up to the end of the range, "5" is a single value
+				hi = lo
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("has an empty range %q", values)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a value of a field of a cron expression, given as a number or as a name.
+func parseCronValue(s string, field cronField) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(s, name) {
+			return field.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("value %q should be between %d and %d", s, field.min, field.max)
+	}
+	return v, nil
+}
+
+// next returns the first minute strictly after t matching the schedule, or the zero time if no minute matches within 5
+// years, e.g. for "0 0 30 2 *".
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ticks returns a channel receiving the minutes matching the schedule, from now on, like the channel of a
+// time.Ticker: a tick is dropped if the previous one was not received yet, and none is sent once no minute matches.
+func (c *cronSchedule) ticks() <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	go func() {
+		for {
+			now := time.Now()
+			next := c.next(now)
+			if next.IsZero() {
+				return
+			}
+			time.Sleep(next.Sub(now))
+			select {
+			case ch <- next:
+			default:
+			}
+		}
+	}()
+	return ch
+}
+
+// matchDay returns true if the day of t matches the day of month and the day of week of the schedule.
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	day := c.days&(1<<uint(t.Day())) != 0
+	weekday := c.weekdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,88 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestCronScheduleNext tests the parseCronSchedule function and the next method of cronSchedule.
+func TestCronScheduleNext(t *testing.T) {
+	// a wednesday
+	now := time.Date(2023, 5, 10, 14, 37, 12, 0, time.UTC)
+	tests := []struct {
+		name    string
+		expr    string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "every minute", expr: "* * * * *", want: time.Date(2023, 5, 10, 14, 38, 0, 0, time.UTC)},
+		{name: "step", expr: "*/15 * * * *", want: time.Date(2023, 5, 10, 14, 45, 0, 0, time.UTC)},
+		{name: "next day", expr: "0 8 * * *", want: time.Date(2023, 5, 11, 8, 0, 0, 0, time.UTC)},
+		{name: "weekday names", expr: "0 8 * * MON", want: time.Date(2023, 5, 15, 8, 0, 0, 0, time.UTC)},
+		{name: "business hours", expr: "*/10 8-20 * * MON-FRI", want: time.Date(2023, 5, 10, 14, 40, 0, 0, time.UTC)},
+		{name: "sunday as 7", expr: "30 6 * * 7", want: time.Date(2023, 5, 14, 6, 30, 0, 0, time.UTC)},
+		{name: "month names", expr: "0 0 1 jan,jul *", want: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "day of month or day of week", expr: "0 0 13 * FRI", want: time.Date(2023, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "any day of week step", expr: "0 0 13 * */1", want: time.Date(2023, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "any day of month step", expr: "0 0 */1 * FRI", want: time.Date(2023, 5, 12, 0, 0, 0, 0, time.UTC)},
+		{name: "any day of week range", expr: "0 0 13 * SUN-SAT", want: time.Date(2023, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "range ending on sunday", expr: "0 9 * * SAT-SUN", want: time.Date(2023, 5, 13, 9, 0, 0, 0, time.UTC)},
+		{name: "any day of week to sunday", expr: "0 0 13 * MON-SUN", want: time.Date(2023, 5, 13, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", expr: "0 0 29 2 *", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 30 2 *", want: time.Time{}},
+		{
+			name:    "missing field",
+			expr:    "0 8 * *",
+			wantErr: `invalid cron expression "0 8 * *": expected 5 fields, got 4`,
+		},
+		{
+			name:    "out of range",
+			expr:    "0 24 * * *",
+			wantErr: `invalid cron expression "0 24 * * *": hour value "24" should be between 0 and 23`,
+		},
+		{
+			name:    "invalid step",
+			expr:    "*/0 * * * *",
+			wantErr: `invalid cron expression "*/0 * * * *": minute has an invalid step "0"`,
+		},
+		{
+			name:    "empty range",
+			expr:    "0 0 * * FRI-MON",
+			wantErr: `invalid cron expression "0 0 * * FRI-MON": day of week has an empty range "FRI-MON"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.expr)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, schedule.next(now))
+		})
+	}
+}
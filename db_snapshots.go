@@ -0,0 +1,152 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The types of DB snapshots, the snapshots of clusters or of instances.
+const (
+	dbSnapshotTypeCluster  = "cluster"
+	dbSnapshotTypeInstance = "instance"
+)
+
+// dbSnapshotTypeManual is the SnapshotType filter of the manual DB snapshots, which outlive their source and may be
+// restored long after their engine version was deprecated.
+const dbSnapshotTypeManual = "manual"
+
+// DBSnapshotInfo represents a manual snapshot of an RDS cluster or instance.
+type DBSnapshotInfo struct {
+	// SnapshotIdentifier is the identifier of the snapshot, and SourceIdentifier the one of the cluster or instance it
+	// was taken from, which may no longer exist.
+	SnapshotIdentifier string
+	SourceIdentifier   string
+
+	// Type is either dbSnapshotTypeCluster or dbSnapshotTypeInstance.
+	Type string
+
+	// Engine and EngineVersion are the engine and version of the snapshot, which a restore starts from.
+	Engine        string
+	EngineVersion string
+}
+
+// getDBSnapshots lists the manual snapshots of the RDS clusters with DescribeDBClusterSnapshots, then the ones of the
+// RDS instances with DescribeDBSnapshots, for the current AWS account and region.
+// An error is returned if the function fails to retrieve snapshot information.
+func getDBSnapshots(config *Config) ([]DBSnapshotInfo, error) {
+	snapshots := make([]DBSnapshotInfo, 0)
+	var nextMarker *string
+	condition := true
+	for condition {
+		clusterSnapshots, err := config.RDS.DescribeDBClusterSnapshots(&rds.DescribeDBClusterSnapshotsInput{
+			SnapshotType: Ptr(dbSnapshotTypeManual),
+			Marker:       nextMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe DB cluster snapshots; %w", err)
+		}
+		if clusterSnapshots == nil {
+			break
+		}
+		for _, s := range clusterSnapshots.DBClusterSnapshots {
+			snapshots = append(snapshots, DBSnapshotInfo{
+				SnapshotIdentifier: aws.StringValue(s.DBClusterSnapshotIdentifier),
+				SourceIdentifier:   aws.StringValue(s.DBClusterIdentifier),
+				Type:               dbSnapshotTypeCluster,
+				Engine:             aws.StringValue(s.Engine),
+				EngineVersion:      aws.StringValue(s.EngineVersion),
+			})
+		}
+		nextMarker = clusterSnapshots.Marker
+		condition = nextMarker != nil
+	}
+
+	nextMarker = nil
+	condition = true
+	for condition {
+		instanceSnapshots, err := config.RDS.DescribeDBSnapshots(&rds.DescribeDBSnapshotsInput{
+			SnapshotType: Ptr(dbSnapshotTypeManual),
+			Marker:       nextMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe DB snapshots; %w", err)
+		}
+		if instanceSnapshots == nil {
+			break
+		}
+		for _, s := range instanceSnapshots.DBSnapshots {
+			snapshots = append(snapshots, DBSnapshotInfo{
+				SnapshotIdentifier: aws.StringValue(s.DBSnapshotIdentifier),
+				SourceIdentifier:   aws.StringValue(s.DBInstanceIdentifier),
+				Type:               dbSnapshotTypeInstance,
+				Engine:             aws.StringValue(s.Engine),
+				EngineVersion:      aws.StringValue(s.EngineVersion),
+			})
+		}
+		nextMarker = instanceSnapshots.Marker
+		condition = nextMarker != nil
+	}
+	return snapshots, nil
+}
+
+// exportDBSnapshots fetches the engine catalogs of the engines of the snapshots that are not yet known, adds them to
+// the engineVersions map, and sets the DBSnapshotDeprecatedGauge to 1 for each snapshot whose engine version is
+// deprecated, and to 0 otherwise. Versions missing from the catalog, which AWS removes some time after deprecating
+// them, are reported as deprecated: restoring such a snapshot fails or forces an upgrade. The engineVersions map and
+// the gauge are shared with the pages of the snapshot, and only accessed with the given lock held.
+func exportDBSnapshots(config *Config, metrics *Metrics, snapshots []DBSnapshotInfo, m engineVersions,
+	mu sync.Locker) error {
+	rdsInfos := make([]RDSInfo, 0, len(snapshots))
+	for _, s := range snapshots {
+		rdsInfos = append(rdsInfos, RDSInfo{Engine: s.Engine, EngineVersion: s.EngineVersion})
+	}
+	// the versions missing from the catalog are expected, so the catalogs are not fetched again for them
+	if err := addMissingEngineVersions(config, metrics, rdsInfos, m, nil, mu); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, s := range snapshots {
+		value := 1.0
+		if valid, err := validateEngineVersion(rdsInfos[i], m); err == nil && valid {
+			value = 0
+		}
+		metrics.DBSnapshotDeprecatedGauge.With(prometheus.Labels{
+			"snapshot_identifier": s.SnapshotIdentifier,
+			"source_identifier":   s.SourceIdentifier,
+			"snapshot_type":       s.Type,
+			"engine":              s.Engine,
+			"engine_version":      s.EngineVersion,
+			"account_id":          config.account(),
+			"region":              config.Region,
+		}).Set(value)
+	}
+	return nil
+}
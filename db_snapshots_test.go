@@ -0,0 +1,82 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshotDBSnapshots tests that snapshot flags the manual snapshots whose engine version is deprecated or no
+// longer in the catalog, fetching the catalogs of the engines that are not in use.
+func TestSnapshotDBSnapshots(t *testing.T) {
+	config := &Config{Region: "eu-west-1", Concurrency: 1, DBSnapshots: true, RDS: &MockRDSAPI{
+		clustersOutput:  []*rds.DescribeDBClustersOutput{{}},
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{}},
+		clusterSnapshotsOutput: []*rds.DescribeDBClusterSnapshotsOutput{{
+			DBClusterSnapshots: []*rds.DBClusterSnapshot{{
+				DBClusterSnapshotIdentifier: Ptr("billing-before-upgrade"),
+				DBClusterIdentifier:         Ptr("billing"),
+				Engine:                      Ptr("aurora-postgresql"),
+				EngineVersion:               Ptr("10.14"),
+			}},
+		}},
+		snapshotsOutput: []*rds.DescribeDBSnapshotsOutput{{
+			DBSnapshots: []*rds.DBSnapshot{
+				{
+					DBSnapshotIdentifier: Ptr("legacy-cms-archive"),
+					DBInstanceIdentifier: Ptr("legacy-cms"),
+					Engine:               Ptr("mysql"),
+					EngineVersion:        Ptr("5.7.38"),
+				},
+				{
+					DBSnapshotIdentifier: Ptr("users-weekly"),
+					DBInstanceIdentifier: Ptr("users"),
+					Engine:               Ptr("mysql"),
+					EngineVersion:        Ptr("8.0.33"),
+				},
+			},
+		}},
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+			DBEngineVersions: []*rds.DBEngineVersion{
+				{Engine: Ptr("aurora-postgresql"), EngineVersion: Ptr("11.9"), Status: Ptr("available")},
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.38"), Status: Ptr("deprecated")},
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.33"), Status: Ptr("available")},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+	assert.NoError(t, snapshot(config, metrics, make(engineVersions)))
+
+	want := `# HELP aws_custom_rds_db_snapshot_version_deprecated Whether the engine version of a manual snapshot of a cluster or an instance is deprecated
+# TYPE aws_custom_rds_db_snapshot_version_deprecated gauge
+aws_custom_rds_db_snapshot_version_deprecated{account_id="default",engine="aurora-postgresql",engine_version="10.14",region="eu-west-1",snapshot_identifier="billing-before-upgrade",snapshot_type="cluster",source_identifier="billing"} 1
+aws_custom_rds_db_snapshot_version_deprecated{account_id="default",engine="mysql",engine_version="5.7.38",region="eu-west-1",snapshot_identifier="legacy-cms-archive",snapshot_type="instance",source_identifier="legacy-cms"} 1
+aws_custom_rds_db_snapshot_version_deprecated{account_id="default",engine="mysql",engine_version="8.0.33",region="eu-west-1",snapshot_identifier="users-weekly",snapshot_type="instance",source_identifier="users"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.DBSnapshotDeprecatedGauge, strings.NewReader(want)))
+}
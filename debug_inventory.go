@@ -0,0 +1,188 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Filter reasons of the resources that are discovered but not exported.
+const (
+	filterReasonTags    = "tag filters"
+	filterReasonStopped = "stopped"
+	// filterReasonUnknownVersion is the reason of the resources whose engine or version is missing from the catalogs.
+	filterReasonUnknownVersion = "unknown engine version"
+)
+
+// catalogSummary summarizes the engine catalog of an engine in a region: its number of versions, by status.
+type catalogSummary struct {
+	Versions int            `json:"versions"`
+	Statuses map[string]int `json:"statuses"`
+}
+
+// filteredResource is a resource discovered by a snapshot, but not exported, and why.
+type filteredResource struct {
+	Account           string `json:"account"`
+	Region            string `json:"region"`
+	ClusterIdentifier string `json:"cluster_identifier"`
+	Engine            string `json:"engine"`
+	EngineVersion     string `json:"engine_version"`
+	Reason            string `json:"reason"`
+}
+
+// collectorError is the last error of a collector, e.g. the snapshot of a region or the email digest.
+type collectorError struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// debugInventory holds the in-memory view of the exporter beyond the exported resources, served on
+// /debug/inventory to find out why a resource is missing from the metrics: the engine catalogs of the last snapshot,
+// the resources it filtered out, and the last error of each collector. It is safe for concurrent use, and a nil
+// debugInventory records nothing.
+type debugInventory struct {
+	mu       sync.Mutex
+	catalogs map[string]map[string]catalogSummary
+	filtered []filteredResource
+	errors   map[string]collectorError
+}
+
+// newDebugInventory returns an empty debugInventory.
+func newDebugInventory() *debugInventory {
+	return &debugInventory{
+		catalogs: make(map[string]map[string]catalogSummary),
+		errors:   make(map[string]collectorError),
+	}
+}
+
+// reset forgets the catalogs and the filtered resources, before a new snapshot. The last errors are kept.
+func (d *debugInventory) reset() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.catalogs = make(map[string]map[string]catalogSummary)
+	d.filtered = nil
+}
+
+// recordCatalogs records the summary of the engine catalogs of a scope.
+func (d *debugInventory) recordCatalogs(scope string, m engineVersions) {
+	if d == nil {
+		return
+	}
+	summaries := make(map[string]catalogSummary, len(m))
+	for engine, catalog := range m {
+		summary := catalogSummary{Versions: len(catalog), Statuses: make(map[string]int)}
+		for _, info := range catalog {
+			summary.Statuses[info.Status]++
+		}
+		summaries[engine] = summary
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.catalogs[scope] = summaries
+}
+
+// recordFiltered records the resources among discovered that are missing from kept, filtered out for the given reason.
+func (d *debugInventory) recordFiltered(discovered, kept []RDSInfo, reason string) {
+	if d == nil || len(discovered) == len(kept) {
+		return
+	}
+	keptIdentifiers := make(map[string]bool, len(kept))
+	for _, rdsInfo := range kept {
+		keptIdentifiers[rdsInfo.ClusterIdentifier] = true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, rdsInfo := range discovered {
+		if keptIdentifiers[rdsInfo.ClusterIdentifier] {
+			continue
+		}
+		d.filtered = append(d.filtered, filteredResource{
+			Account:           rdsInfo.Account,
+			Region:            rdsInfo.Region,
+			ClusterIdentifier: rdsInfo.ClusterIdentifier,
+			Engine:            rdsInfo.Engine,
+			EngineVersion:     rdsInfo.EngineVersion,
+			Reason:            reason,
+		})
+	}
+}
+
+// recordError records the error of a collector, if err is not nil.
+func (d *debugInventory) recordError(collector string, err error) {
+	if d == nil || err == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.errors[collector] = collectorError{Error: err.Error(), Time: time.Now().UTC()}
+}
+
+// debugInventoryView is the JSON document served on /debug/inventory.
+type debugInventoryView struct {
+	Resources []inventoryItem                      `json:"resources"`
+	Filtered  []filteredResource                   `json:"filtered"`
+	Catalogs  map[string]map[string]catalogSummary `json:"catalogs"`
+	Errors    map[string]collectorError            `json:"errors"`
+}
+
+// debugInventoryHandler serves the exported resources of the last snapshot, the resources it filtered out, its engine
+// catalogs, and the last error of each collector, as JSON. The resources are narrowed down to the one of the
+// identifier query parameter, if any, e.g. /debug/inventory?identifier=db-1.
+func debugInventoryHandler(metrics *Metrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identifier := r.URL.Query().Get("identifier")
+		view := debugInventoryView{Resources: []inventoryItem{}, Filtered: []filteredResource{}}
+		for _, item := range metrics.Inventory.list() {
+			if identifier == "" || item.ClusterIdentifier == identifier {
+				view.Resources = append(view.Resources, item)
+			}
+		}
+
+		d := metrics.Debug
+		d.mu.Lock()
+		for _, resource := range d.filtered {
+			if identifier == "" || resource.ClusterIdentifier == identifier {
+				view.Filtered = append(view.Filtered, resource)
+			}
+		}
+		sort.SliceStable(view.Filtered, func(a, b int) bool {
+			return view.Filtered[a].ClusterIdentifier < view.Filtered[b].ClusterIdentifier
+		})
+		view.Catalogs, view.Errors = d.catalogs, d.errors
+		b, err := json.MarshalIndent(view, "", "  ")
+		d.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	})
+}
@@ -0,0 +1,85 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDebugInventoryHandler tests that the debug inventory serves the exported and filtered resources of the snapshot,
+// narrowed down to an identifier, along with the engine catalogs and the last errors.
+func TestDebugInventoryHandler(t *testing.T) {
+	metrics := NewMetrics()
+	discovered := []RDSInfo{
+		{ClusterIdentifier: "db-1", Engine: "postgres", EngineVersion: "13.7", Region: "eu-west-1"},
+		{ClusterIdentifier: "db-2", Engine: "postgres", EngineVersion: "13.7", Region: "eu-west-1"},
+		{ClusterIdentifier: "db-3", Engine: "mysql", EngineVersion: "8.0.32", Region: "eu-west-1"},
+	}
+	metrics.Debug.recordFiltered(discovered, discovered[:1], filterReasonTags)
+	metrics.Inventory.add(inventoryItem{RDSInfo: discovered[0], Status: "available"})
+	metrics.Debug.recordCatalogs(`region "eu-west-1"`, engineVersions{"postgres": {
+		"11.19": {Status: "deprecated"}, "13.7": {Status: "available"}, "14.7": {Status: "available"},
+	}})
+	metrics.Debug.recordError("digest", errors.New("connection refused"))
+	metrics.Debug.recordError("reports", nil)
+
+	get := func(query string) debugInventoryView {
+		rec := httptest.NewRecorder()
+		debugInventoryHandler(metrics).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/inventory"+query, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		var view debugInventoryView
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&view))
+		return view
+	}
+
+	view := get("")
+	assert.Len(t, view.Resources, 1)
+	assert.Equal(t, []filteredResource{
+		{Region: "eu-west-1", ClusterIdentifier: "db-2", Engine: "postgres", EngineVersion: "13.7", Reason: "tag filters"},
+		{Region: "eu-west-1", ClusterIdentifier: "db-3", Engine: "mysql", EngineVersion: "8.0.32", Reason: "tag filters"},
+	}, view.Filtered)
+	assert.Equal(t, map[string]map[string]catalogSummary{`region "eu-west-1"`: {
+		"postgres": {Versions: 3, Statuses: map[string]int{"available": 2, "deprecated": 1}},
+	}}, view.Catalogs)
+	assert.Len(t, view.Errors, 1)
+	assert.Equal(t, "connection refused", view.Errors["digest"].Error)
+
+	view = get("?identifier=db-3")
+	assert.Empty(t, view.Resources)
+	assert.Len(t, view.Filtered, 1)
+	assert.Equal(t, "db-3", view.Filtered[0].ClusterIdentifier)
+
+	// the catalogs and the filtered resources are forgotten by the next snapshot, but not the errors
+	metrics.Debug.reset()
+	view = get("")
+	assert.Empty(t, view.Filtered)
+	assert.Empty(t, view.Catalogs)
+	assert.Len(t, view.Errors, 1)
+}
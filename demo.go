@@ -0,0 +1,308 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"strings"
+	"time"
+)
+
+// demoRegions are the regions of the demo, unless other ones are configured.
+var demoRegions = []string{"eu-west-1", "us-east-1"}
+
+// demoEngineVersions is the synthetic engine catalog of the demo: engine, version and status.
+var demoEngineVersions = [][3]string{
+	{"aurora-postgresql", "11.9", "deprecated"},
+	{"aurora-postgresql", "13.7", "available"},
+	{"aurora-postgresql", "15.3", "available"},
+	{"aurora-mysql", "5.7.mysql_aurora.2.07.2", "deprecated"},
+	{"aurora-mysql", "8.0.mysql_aurora.3.04.0", "available"},
+	{"mysql", "5.7.38", "deprecated"},
+	{"mysql", "8.0.33", "available"},
+	{"postgres", "12.15", "available"},
+	{"postgres", "15.3", "available"},
+	{"oracle-ee", "19.0.0.0.ru-2023-04.rur-2023-04.r1", "available"},
+	{"sqlserver-se", "14.00.3421.10.v1", "deprecated"},
+	{"custom-oracle-ee", "19.my_cev1", "inactive"},
+}
+
+// demoInstance is a synthetic RDS instance of the demo.
+type demoInstance struct {
+	identifier, cluster, engine, version, class, licenseModel, env string
+	writer, stopped                                                bool
+}
+
+// demoInstances are the synthetic RDS instances of the demo, in each region.
+var demoInstances = []demoInstance{
+	{identifier: "orders-1", cluster: "orders", engine: "aurora-postgresql", version: "13.7", class: "db.r6g.large", env: "prod", writer: true},
+	{identifier: "orders-2", cluster: "orders", engine: "aurora-postgresql", version: "13.7", class: "db.r6g.large", env: "prod"},
+	{identifier: "orders-3", cluster: "orders", engine: "aurora-postgresql", version: "13.7", class: "db.r6g.large", env: "prod"},
+	{identifier: "billing-1", cluster: "billing", engine: "aurora-postgresql", version: "11.9", class: "db.r5.large", env: "prod", writer: true},
+	{identifier: "billing-2", cluster: "billing", engine: "aurora-postgresql", version: "11.9", class: "db.r5.large", env: "prod"},
+	{identifier: "catalog-1", cluster: "catalog", engine: "aurora-mysql", version: "5.7.mysql_aurora.2.07.2", class: "db.t2.medium", env: "staging", writer: true},
+	{identifier: "users", engine: "postgres", version: "15.3", class: "db.m6g.large", env: "prod"},
+	{identifier: "analytics", engine: "postgres", version: "12.15", class: "db.m4.xlarge", env: "staging", stopped: true},
+	{identifier: "legacy-cms", engine: "mysql", version: "5.7.38", class: "db.t2.small", env: "staging"},
+	{identifier: "sessions", engine: "mysql", version: "8.0.33", class: "db.t3.medium", env: "prod"},
+	{identifier: "erp", engine: "oracle-ee", version: "19.0.0.0.ru-2023-04.rur-2023-04.r1", class: "db.r5.xlarge", licenseModel: "bring-your-own-license", env: "prod"},
+	{identifier: "reporting", engine: "sqlserver-se", version: "14.00.3421.10.v1", class: "db.m5.large", licenseModel: "license-included", env: "prod"},
+	{identifier: "erp-custom", engine: "custom-oracle-ee", version: "19.my_cev1", class: "db.m5.xlarge", licenseModel: "bring-your-own-license", env: "staging"},
+}
+
+// demoRDSAPI serves the synthetic RDS resources of the demo in a region, so that the exporter and its dashboards can
+// be tried without AWS credentials. It honors the filters used by the exporter.
+type demoRDSAPI struct {
+	rdsiface.RDSAPI
+	region string
+}
+
+func (d demoRDSAPI) arn(resourceType, identifier string) string {
+	return fmt.Sprintf("arn:aws:rds:%s:123456789012:%s:%s", d.region, resourceType, identifier)
+}
+
+func (d demoRDSAPI) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	clusters := make(map[string]*rds.DBCluster)
+	output := &rds.DescribeDBClustersOutput{}
+	for _, instance := range demoInstances {
+		if instance.cluster == "" || !demoFilterMatch(input.Filters, "db-cluster-id", instance.cluster) ||
+			!demoFilterMatch(input.Filters, "engine", instance.engine) {
+			continue
+		}
+		cluster, ok := clusters[instance.cluster]
+		if !ok {
+			cluster = &rds.DBCluster{
+				DBClusterIdentifier:        aws.String(instance.cluster),
+				DBClusterArn:               aws.String(d.arn("cluster", instance.cluster)),
+				Engine:                     aws.String(instance.engine),
+				EngineVersion:              aws.String(instance.version),
+				PreferredMaintenanceWindow: aws.String("sun:05:00-sun:06:00"),
+				TagList:                    []*rds.Tag{{Key: aws.String("env"), Value: aws.String(instance.env)}},
+			}
+			clusters[instance.cluster] = cluster
+			output.DBClusters = append(output.DBClusters, cluster)
+		}
+		cluster.DBClusterMembers = append(cluster.DBClusterMembers, &rds.DBClusterMember{
+			DBInstanceIdentifier: aws.String(instance.identifier),
+			IsClusterWriter:      aws.Bool(instance.writer),
+		})
+	}
+	return output, nil
+}
+
+func (d demoRDSAPI) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	output := &rds.DescribeDBInstancesOutput{}
+	for _, instance := range demoInstances {
+		if !demoFilterMatch(input.Filters, "db-instance-id", instance.identifier) ||
+			!demoFilterMatch(input.Filters, "engine", instance.engine) {
+			continue
+		}
+		dbInstance := &rds.DBInstance{
+			DBInstanceIdentifier:       aws.String(instance.identifier),
+			DBInstanceArn:              aws.String(d.arn("db", instance.identifier)),
+			DBInstanceClass:            aws.String(instance.class),
+			DBInstanceStatus:           aws.String("available"),
+			Engine:                     aws.String(instance.engine),
+			EngineVersion:              aws.String(instance.version),
+			PreferredMaintenanceWindow: aws.String("sat:03:00-sat:03:30"),
+			TagList:                    []*rds.Tag{{Key: aws.String("env"), Value: aws.String(instance.env)}},
+		}
+		if instance.stopped {
+			dbInstance.DBInstanceStatus = aws.String(statusStopped)
+		}
+		if instance.cluster != "" {
+			dbInstance.DBClusterIdentifier = aws.String(instance.cluster)
+		}
+		if instance.licenseModel != "" {
+			dbInstance.LicenseModel = aws.String(instance.licenseModel)
+		}
+		output.DBInstances = append(output.DBInstances, dbInstance)
+	}
+	return output, nil
+}
+
+func (d demoRDSAPI) DescribeDBEngineVersions(input *rds.DescribeDBEngineVersionsInput) (*rds.DescribeDBEngineVersionsOutput, error) {
+	output := &rds.DescribeDBEngineVersionsOutput{}
+	for _, v := range demoEngineVersions {
+		if input.Engine != nil && *input.Engine != v[0] {
+			continue
+		}
+		output.DBEngineVersions = append(output.DBEngineVersions, &rds.DBEngineVersion{
+			Engine:                             aws.String(v[0]),
+			EngineVersion:                      aws.String(v[1]),
+			Status:                             aws.String(v[2]),
+			SupportsReadReplica:                aws.Bool(true),
+			SupportsLogExportsToCloudwatchLogs: aws.Bool(true),
+			SupportedEngineModes:               aws.StringSlice([]string{"provisioned"}),
+		})
+	}
+	return output, nil
+}
+
+// DescribeDBClusterSnapshots serves a manual snapshot of the billing cluster, taken before it was upgraded from a
+// version since removed from the catalog.
+func (d demoRDSAPI) DescribeDBClusterSnapshots(*rds.DescribeDBClusterSnapshotsInput) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	return &rds.DescribeDBClusterSnapshotsOutput{DBClusterSnapshots: []*rds.DBClusterSnapshot{{
+		DBClusterSnapshotIdentifier: aws.String("billing-before-upgrade"),
+		DBClusterIdentifier:         aws.String("billing"),
+		Engine:                      aws.String("aurora-postgresql"),
+		EngineVersion:               aws.String("10.14"),
+	}}}, nil
+}
+
+// DescribeDBSnapshots serves a manual snapshot of the legacy-cms and of the users instances.
+func (d demoRDSAPI) DescribeDBSnapshots(*rds.DescribeDBSnapshotsInput) (*rds.DescribeDBSnapshotsOutput, error) {
+	return &rds.DescribeDBSnapshotsOutput{DBSnapshots: []*rds.DBSnapshot{
+		{
+			DBSnapshotIdentifier: aws.String("legacy-cms-archive"),
+			DBInstanceIdentifier: aws.String("legacy-cms"),
+			Engine:               aws.String("mysql"),
+			EngineVersion:        aws.String("5.7.38"),
+		},
+		{
+			DBSnapshotIdentifier: aws.String("users-weekly"),
+			DBInstanceIdentifier: aws.String("users"),
+			Engine:               aws.String("postgres"),
+			EngineVersion:        aws.String("15.3"),
+		},
+	}}, nil
+}
+
+// DescribePendingMaintenanceActions serves an engine upgrade of the billing cluster, applied automatically before the
+// end of support of its version.
+func (d demoRDSAPI) DescribePendingMaintenanceActions(*rds.DescribePendingMaintenanceActionsInput) (*rds.DescribePendingMaintenanceActionsOutput, error) {
+	return &rds.DescribePendingMaintenanceActionsOutput{PendingMaintenanceActions: []*rds.ResourcePendingMaintenanceActions{{
+		ResourceIdentifier: aws.String(d.arn("cluster", "billing")),
+		PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{{
+			Action:               aws.String(pendingActionUpgrade),
+			AutoAppliedAfterDate: aws.Time(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)),
+			Description:          aws.String("Upgrade to Aurora PostgreSQL 11.21"),
+		}},
+	}}}, nil
+}
+
+func (d demoRDSAPI) DescribeGlobalClusters(*rds.DescribeGlobalClustersInput) (*rds.DescribeGlobalClustersOutput, error) {
+	return &rds.DescribeGlobalClustersOutput{GlobalClusters: []*rds.GlobalCluster{{
+		GlobalClusterIdentifier: aws.String("orders-global"),
+		Engine:                  aws.String("aurora-postgresql"),
+		EngineVersion:           aws.String("13.7"),
+		GlobalClusterMembers: []*rds.GlobalClusterMember{
+			{DBClusterArn: aws.String("arn:aws:rds:eu-west-1:123456789012:cluster:orders"), IsWriter: aws.Bool(true)},
+			{DBClusterArn: aws.String("arn:aws:rds:us-east-1:123456789012:cluster:orders"), IsWriter: aws.Bool(false)},
+		},
+	}}}, nil
+}
+
+// DescribeReservedDBInstances reports no reserved DB instance.
+func (d demoRDSAPI) DescribeReservedDBInstances(*rds.DescribeReservedDBInstancesInput) (*rds.DescribeReservedDBInstancesOutput, error) {
+	return &rds.DescribeReservedDBInstancesOutput{}, nil
+}
+
+// DescribeDBClusterParameters reports no parameter, hence Babelfish disabled.
+func (d demoRDSAPI) DescribeDBClusterParameters(*rds.DescribeDBClusterParametersInput) (*rds.DescribeDBClusterParametersOutput, error) {
+	return &rds.DescribeDBClusterParametersOutput{}, nil
+}
+
+// ModifyDBInstance modifies nothing, so that the remediations can be tried in demo mode, and returns the instance.
+func (d demoRDSAPI) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	identifier := aws.StringValue(input.DBInstanceIdentifier)
+	return &rds.ModifyDBInstanceOutput{DBInstance: &rds.DBInstance{
+		DBInstanceIdentifier: aws.String(identifier),
+		DBInstanceArn:        aws.String(d.arn("db", identifier)),
+	}}, nil
+}
+
+// DescribeOrderableDBInstanceOptions reports the previous generation classes, e.g. db.t2 and db.m4, as not orderable.
+func (d demoRDSAPI) DescribeOrderableDBInstanceOptions(input *rds.DescribeOrderableDBInstanceOptionsInput) (*rds.DescribeOrderableDBInstanceOptionsOutput, error) {
+	class := aws.StringValue(input.DBInstanceClass)
+	output := &rds.DescribeOrderableDBInstanceOptionsOutput{}
+	if !strings.HasPrefix(class, "db.t2.") && !strings.HasPrefix(class, "db.m4.") {
+		output.OrderableDBInstanceOptions = []*rds.OrderableDBInstanceOption{{
+			DBInstanceClass: input.DBInstanceClass,
+			Engine:          input.Engine,
+			EngineVersion:   input.EngineVersion,
+		}}
+	}
+	return output, nil
+}
+
+// demoFilterMatch returns true if there is no filter with the given name, or if one of its values is the identifier
+// or ends with ":<identifier>", as ARNs do.
+func demoFilterMatch(filters []*rds.Filter, name, identifier string) bool {
+	for _, filter := range filters {
+		if aws.StringValue(filter.Name) != name {
+			continue
+		}
+		for _, value := range filter.Values {
+			if v := aws.StringValue(value); v == identifier || strings.HasSuffix(v, ":"+identifier) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// newDemoScopes returns Scopes serving the synthetic RDS resources of the demo in each configured region, or in
+// demoRegions, but the excluded ones, with the describe discovery backend. No AWS session is created.
+func newDemoScopes(options *Options) *Scopes {
+	regions := options.regions
+	if len(regions) == 0 {
+		regions = excludeRegions(demoRegions, options.excludeRegions)
+	}
+	account := AccountScope{}
+	policy := newPolicySource(options)
+	for _, region := range regions {
+		account.Regions = append(account.Regions, &Config{
+			RDS:              demoRDSAPI{region: region},
+			Region:           region,
+			Concurrency:      options.AwsApiConcurrency,
+			DiscoveryBackend: discoveryBackendDescribe,
+			TagFilters:       options.tagFilters,
+			ClusterFilters:   toRDSFilters(options.APIFilters.Clusters),
+			InstanceFilters:  toRDSFilters(options.APIFilters.Instances),
+			GlobalClusters:   options.GlobalClusters,
+			InstanceClasses:  options.InstanceClasses,
+			CatalogInfo:      options.CatalogInfo,
+			DBSnapshots:      options.DBSnapshots,
+			ForcedUpgrades:   options.ForcedUpgradeDeadlines,
+			StandardSupport:  options.SupportCalendar,
+			ExtendedSupport:  options.ExtendedSupportCost,
+			Recommendations:  recommendations(options),
+			Remediation:      newRemediationPolicy(options),
+			Calendar:         options.supportCalendar,
+			Policy:           policy,
+			Owners:           options.owners,
+			ExcludeStopped:   options.ExcludeStopped,
+		})
+	}
+	return &Scopes{
+		Accounts:             []AccountScope{account},
+		MaxAccountsInFlight:  options.MaxAccountsInFlight,
+		MaxRegionsPerAccount: options.MaxRegionsPerAccount,
+		Policy:               policy,
+	}
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestDemo tests that the demo mode exports the synthetic RDS resources in every demo region, without AWS.
+func TestDemo(t *testing.T) {
+	options := defaultOptions()
+	options.Demo, options.ServerPort, options.GlobalClusters, options.InstanceClasses = true, 2112, true, true
+	options.ReservedInstances, options.Babelfish = true, true
+	assert.NoError(t, options.validate())
+	scopes, err := NewScopes(options)
+	assert.NoError(t, err)
+	metrics := NewMetrics()
+
+	assert.NoError(t, snapshotScopes(scopes, metrics, newCatalogs(scopes)))
+
+	// 13 instances and 3 clusters, in 2 regions
+	assert.Equal(t, 32, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_deprecated"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_global_cluster_member_info"))
+	for _, region := range demoRegions {
+		assert.Equal(t, 1.0, servedValue(metrics, metrics.DeprecatedGauge,
+			"billing", "aurora-postgresql", "11.9", "11", "9", "", "", "", "false", "false", "sun:05:00-sun:06:00",
+			"default", region))
+		assert.Equal(t, 1.0, servedValue(metrics, metrics.AvailableGauge,
+			"orders-1", "aurora-postgresql", "13.7", "13", "7", "writer", "", "", "false", "false", "sat:03:00-sat:03:30",
+			"default", region))
+		assert.Equal(t, 1.0, servedValue(metrics, metrics.DeprecatedGauge,
+			"erp-custom", "custom-oracle-ee", "19.my_cev1", "19", "my_cev1", "", "bring-your-own-license", "enterprise",
+			"true", "false", "sat:03:00-sat:03:30", "default", region))
+		assert.Equal(t, 1.0, servedValue(metrics, metrics.InstanceClassDeprecatedGauge,
+			"legacy-cms", "mysql", "5.7.38", "db.t2.small", "default", region))
+	}
+}
+
+// TestDemoRemediation tests that the remediations can be applied in demo mode, without modifying anything.
+func TestDemoRemediation(t *testing.T) {
+	options := defaultOptions()
+	options.Demo, options.ServerPort, options.AutoMinorRemediation, options.RemediationApply = true, 2112, true, true
+	options.RemediationTagFilters = "team"
+	assert.NoError(t, options.validate())
+	scopes, err := NewScopes(options)
+	assert.NoError(t, err)
+	config := scopes.configs()[0]
+	metrics := NewMetrics()
+
+	remediateAutoMinorUpgrades(config, metrics, []RDSInfo{{ClusterIdentifier: "legacy-cms",
+		ResourceType: resourceTypeInstance, Status: statusAvailable, Region: config.Region}})
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RemediationsCounter.WithLabelValues(
+		remediationActionAutoMinorUpgrade, remediationResultApplied, "", config.Region)))
+}
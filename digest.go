@@ -0,0 +1,222 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	digestTransportSMTP = "smtp"
+	digestTransportSES  = "ses"
+)
+
+// defaultDigestSupportEndDays is the default number of days before the end of the standard support of their version
+// from which the resources are listed as soon deprecated in the digest.
+const defaultDigestSupportEndDays = 90
+
+// digest emails a summary of the RDS resources running a deprecated engine version, and of the ones soon deprecated,
+// on a cron schedule, grouped by account and by the value of a tag, e.g. "team", for the readers who do not use the
+// dashboards. The resources soon deprecated are the ones in grace, the ones AWS will upgrade at a known date, and the
+// ones whose standard support ends within the support end window.
+type digest struct {
+	schedule         *cronSchedule
+	next             time.Time
+	from             string
+	to               []string
+	groupByTag       string
+	supportEndWindow time.Duration
+
+	// send delivers an RFC 5322 message, with SMTP or SES.
+	send func(msg []byte) error
+}
+
+// newDigest returns the digest configured by the options, whose first sending is scheduled after now, or nil if no
+// digest schedule is configured.
+func newDigest(options *Options, now time.Time) *digest {
+	if options.digestSchedule == nil {
+		return nil
+	}
+	d := &digest{
+		schedule:         options.digestSchedule,
+		next:             options.digestSchedule.next(now),
+		from:             options.DigestFrom,
+		to:               options.digestTo,
+		groupByTag:       options.DigestGroupByTag,
+		supportEndWindow: time.Duration(options.DigestSupportEndDays) * 24 * time.Hour,
+	}
+	if options.DigestTransport == digestTransportSES {
+		config := aws.NewConfig()
+		if options.DigestSESRegion != "" {
+			config = config.WithRegion(options.DigestSESRegion)
+		}
+		d.send = sesSender(ses.New(newSession(options, nil), config), d.from, d.to)
+	} else {
+		d.send = smtpSender(options.DigestSMTPAddress, options.DigestSMTPUsername, options.DigestSMTPPassword, d.from, d.to)
+	}
+	return d
+}
+
+// smtpSender returns a function sending messages through the SMTP server at addr, e.g. "smtp.example.com:587", which
+// upgrades the connection with STARTTLS when the server supports it. PLAIN authentication is used if a username is
+// given.
+func smtpSender(addr, username, password, from string, to []string) func(msg []byte) error {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return func(msg []byte) error {
+		return smtp.SendMail(addr, auth, from, to, msg)
+	}
+}
+
+// sesSender returns a function sending messages with the SES SendRawEmail API.
+func sesSender(client sesiface.SESAPI, from string, to []string) func(msg []byte) error {
+	return func(msg []byte) error {
+		_, err := client.SendRawEmail(&ses.SendRawEmailInput{
+			Source:       Ptr(from),
+			Destinations: aws.StringSlice(to),
+			RawMessage:   &ses.RawMessage{Data: msg},
+		})
+		return err
+	}
+}
+
+// maybeSend sends the digest of the inventory if its scheduled time has passed, and schedules the next one. It is called
+// after each successful snapshot, so that the digest always covers a complete inventory: it is sent at most one poll
+// interval after its scheduled time. Nothing is done if d is nil.
+func (d *digest) maybeSend(now time.Time, inv *inventory) error {
+	if d == nil || d.next.IsZero() || now.Before(d.next) {
+		return nil
+	}
+	d.next = d.schedule.next(now)
+	if err := d.send(d.message(inv.list(), now)); err != nil {
+		return fmt.Errorf("failed to send the digest; %w", err)
+	}
+	return nil
+}
+
+// message returns the RFC 5322 message of the digest of the given inventory items. The resources in grace are listed
+// as soon deprecated rather than deprecated, like in the metrics.
+func (d *digest) message(items []inventoryItem, now time.Time) []byte {
+	var deprecated, soon []inventoryItem
+	for _, item := range items {
+		switch {
+		case item.Deprecated && !item.InGrace:
+			deprecated = append(deprecated, item)
+		case item.InGrace || !item.ForcedUpgrade.IsZero() ||
+			!item.StandardSupportEnd.IsZero() && item.StandardSupportEnd.Before(now.Add(d.supportEndWindow)):
+			soon = append(soon, item)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", d.from)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(d.to, ", "))
+	fmt.Fprintf(&sb, "Subject: RDS engine versions: %d of %d resources deprecated, %d soon\r\n",
+		len(deprecated), len(items), len(soon))
+	fmt.Fprintf(&sb, "Date: %s\r\n", now.UTC().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	if len(deprecated) == 0 {
+		fmt.Fprintf(&sb, "None of the %d RDS resources runs a deprecated engine version.\r\n", len(items))
+	} else {
+		fmt.Fprintf(&sb, "%d of the %d RDS resources run a deprecated engine version.\r\n", len(deprecated), len(items))
+		d.writeGroups(&sb, deprecated, func(item inventoryItem) string {
+			return item.Status
+		})
+	}
+	if len(soon) == 0 {
+		return []byte(sb.String())
+	}
+	fmt.Fprintf(&sb, "\r\n%d of the %d RDS resources will soon run a deprecated engine version, or be upgraded by "+
+		"AWS.\r\n", len(soon), len(items))
+	d.writeGroups(&sb, soon, func(item inventoryItem) string {
+		var reasons []string
+		if item.InGrace {
+			reasons = append(reasons, "deprecated, in grace")
+		}
+		if !item.ForcedUpgrade.IsZero() {
+			reasons = append(reasons, "upgraded by AWS after "+item.ForcedUpgrade.UTC().Format("2006-01-02"))
+		}
+		if !item.StandardSupportEnd.IsZero() {
+			reasons = append(reasons, "standard support ends "+item.StandardSupportEnd.UTC().Format("2006-01-02"))
+		}
+		return strings.Join(reasons, ", ")
+	})
+	return []byte(sb.String())
+}
+
+// writeGroups writes the groups of the inventory items, one line per item, ending with its description.
+func (d *digest) writeGroups(sb *strings.Builder, items []inventoryItem, describe func(inventoryItem) string) {
+	for _, group := range d.groups(items) {
+		fmt.Fprintf(sb, "\r\n%s (%d):\r\n", group.name, len(group.items))
+		w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+		for _, item := range group.items {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\r\n",
+				item.ClusterIdentifier, item.Region, item.Engine, item.EngineVersion, describe(item))
+		}
+		_ = w.Flush()
+	}
+}
+
+// digestGroup is a group of inventory items of the digest.
+type digestGroup struct {
+	name  string
+	items []inventoryItem
+}
+
+// groups groups the inventory items by account and, if a tag is configured, by the value of that tag, in order of first
+// appearance. The items are expected to be sorted by account.
+func (d *digest) groups(items []inventoryItem) []digestGroup {
+	var groups []digestGroup
+	index := make(map[string]int)
+	for _, item := range items {
+		name := fmt.Sprintf("account %s", item.Account)
+		if d.groupByTag != "" {
+			if value, ok := item.Tags[d.groupByTag]; ok {
+				name = fmt.Sprintf("%s, %s %q", name, d.groupByTag, value)
+			} else {
+				name = fmt.Sprintf("%s, without %s tag", name, d.groupByTag)
+			}
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, digestGroup{name: name})
+		}
+		groups[i].items = append(groups[i].items, item)
+	}
+	return groups
+}
@@ -0,0 +1,137 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestDigestMaybeSend tests the maybeSend method of digest, its schedule and the message it sends.
+func TestDigestMaybeSend(t *testing.T) {
+	// a wednesday
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	schedule, err := parseCronSchedule("0 8 * * MON")
+	assert.NoError(t, err)
+
+	inv := &inventory{}
+	for _, item := range []inventoryItem{
+		{RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "aurora-mysql", EngineVersion: "5.7.mysql_aurora.2.07.2",
+			Region: "eu-west-1", Account: "111111111111", Tags: map[string]string{"team": "checkout"}},
+			Status: "deprecated", Deprecated: true},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "billing", Engine: "postgres", EngineVersion: "10.21",
+			Region: "us-east-1", Account: "222222222222"},
+			Status: "deprecated", Deprecated: true},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "catalog", Engine: "postgres", EngineVersion: "15.2",
+			Region: "eu-west-1", Account: "111111111111", Tags: map[string]string{"team": "checkout"}},
+			Status: "available", StandardSupportEnd: time.Date(2027, 11, 30, 0, 0, 0, 0, time.UTC)},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "search", Engine: "postgres", EngineVersion: "11.19",
+			Region: "eu-west-1", Account: "111111111111", Tags: map[string]string{"team": "checkout"}},
+			Status: "deprecated", Deprecated: true, InGrace: true,
+			StandardSupportEnd: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "inventory", Engine: "postgres", EngineVersion: "12.14",
+			Region: "eu-west-1", Account: "111111111111"},
+			Status: "available", ForcedUpgrade: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "ledger", Engine: "mysql", EngineVersion: "5.7.42",
+			Region: "us-east-1", Account: "222222222222"},
+			Status: "available", StandardSupportEnd: time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		inv.add(item)
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		sendErr  error
+		wantSent bool
+		wantErr  string
+	}{
+		{name: "before the schedule", at: now.Add(time.Hour)},
+		{name: "after the schedule", at: time.Date(2023, 5, 15, 8, 5, 0, 0, time.UTC), wantSent: true},
+		{
+			name:     "failure",
+			at:       time.Date(2023, 5, 15, 8, 5, 0, 0, time.UTC),
+			sendErr:  errors.New("connection refused"),
+			wantSent: true,
+			wantErr:  "failed to send the digest; connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []byte
+			d := &digest{
+				schedule:         schedule,
+				next:             schedule.next(now),
+				from:             "exporter@example.com",
+				to:               []string{"management@example.com", "sre@example.com"},
+				groupByTag:       "team",
+				supportEndWindow: 90 * 24 * time.Hour,
+				send: func(msg []byte) error {
+					sent = msg
+					return tt.sendErr
+				},
+			}
+			err := d.maybeSend(tt.at, inv)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			if !tt.wantSent {
+				assert.Nil(t, sent)
+				return
+			}
+			assert.Equal(t, time.Date(2023, 5, 22, 8, 0, 0, 0, time.UTC), d.next)
+			assert.Equal(t, "From: exporter@example.com\r\n"+
+				"To: management@example.com, sre@example.com\r\n"+
+				"Subject: RDS engine versions: 2 of 6 resources deprecated, 3 soon\r\n"+
+				"Date: Mon, 15 May 2023 08:05:00 +0000\r\n"+
+				"MIME-Version: 1.0\r\n"+
+				"Content-Type: text/plain; charset=utf-8\r\n\r\n"+
+				"2 of the 6 RDS resources run a deprecated engine version.\r\n"+
+				"\r\n"+
+				"account 111111111111, team \"checkout\" (1):\r\n"+
+				"  orders  eu-west-1  aurora-mysql  5.7.mysql_aurora.2.07.2  deprecated\r\n"+
+				"\r\n"+
+				"account 222222222222, without team tag (1):\r\n"+
+				"  billing  us-east-1  postgres  10.21  deprecated\r\n"+
+				"\r\n"+
+				"3 of the 6 RDS resources will soon run a deprecated engine version, or be upgraded by AWS.\r\n"+
+				"\r\n"+
+				"account 111111111111, without team tag (1):\r\n"+
+				"  inventory  eu-west-1  postgres  12.14  upgraded by AWS after 2023-06-01\r\n"+
+				"\r\n"+
+				"account 111111111111, team \"checkout\" (1):\r\n"+
+				"  search  eu-west-1  postgres  11.19  deprecated, in grace, standard support ends 2024-02-29\r\n"+
+				"\r\n"+
+				"account 222222222222, without team tag (1):\r\n"+
+				"  ledger  us-east-1  mysql  5.7.42  standard support ends 2023-07-31\r\n",
+				string(sent))
+		})
+	}
+
+	// nil digests are never sent
+	assert.NoError(t, (*digest)(nil).maybeSend(now, inv))
+}
@@ -0,0 +1,231 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"strings"
+)
+
+const (
+	// discoveryBackendDescribe discovers RDS resources by listing every cluster and instance with DescribeDBClusters and
+	// DescribeDBInstances.
+	discoveryBackendDescribe = "describe"
+	// discoveryBackendTagging discovers RDS resources matching the tag filters with the Resource Groups Tagging API,
+	// and only then describes them.
+	discoveryBackendTagging = "tagging"
+)
+
+// tagFilter selects RDS resources carrying the tag Key with one of the Values, or with any value if Values is empty.
+type tagFilter struct {
+	Key    string
+	Values []string
+}
+
+// apiFilters are the native API filters of the DescribeDBClusters and DescribeDBInstances calls listing RDS resources,
+// which filter server-side, e.g. on the engine, instead of listing every resource of huge accounts.
+type apiFilters struct {
+	Clusters  []apiFilter `yaml:"clusters"`
+	Instances []apiFilter `yaml:"instances"`
+}
+
+// apiFilter selects the RDS resources whose Name attribute is one of the Values.
+type apiFilter struct {
+	Name   string   `yaml:"name"`
+	Values []string `yaml:"values"`
+}
+
+var (
+	// clusterFilterNames and instanceFilterNames are the filters supported by DescribeDBClusters and
+	// DescribeDBInstances respectively.
+	clusterFilterNames  = []string{"clone-group-id", "db-cluster-id", "db-cluster-resource-id", "domain", "engine"}
+	instanceFilterNames = []string{"db-cluster-id", "db-instance-id", "dbi-resource-id", "domain", "engine"}
+)
+
+// validate checks that every filter is supported by its API and has values.
+func (f apiFilters) validate() []string {
+	var problems []string
+	check := func(api string, filters []apiFilter, names []string) {
+		for _, filter := range filters {
+			if !contains(names, filter.Name) {
+				problems = append(problems, fmt.Sprintf("%s API filter should be one of %s, got %q", api,
+					strings.Join(names, ", "), filter.Name))
+			}
+			if len(filter.Values) == 0 {
+				problems = append(problems, fmt.Sprintf("%s API filter %q should have values", api, filter.Name))
+			}
+		}
+	}
+	check("cluster", f.Clusters, clusterFilterNames)
+	check("instance", f.Instances, instanceFilterNames)
+	return problems
+}
+
+// toRDSFilters converts API filters to their RDS API representation, or returns nil if there are none.
+func toRDSFilters(filters []apiFilter) []*rds.Filter {
+	if len(filters) == 0 {
+		return nil
+	}
+	rdsFilters := make([]*rds.Filter, 0, len(filters))
+	for _, filter := range filters {
+		rdsFilters = append(rdsFilters, &rds.Filter{Name: Ptr(filter.Name), Values: aws.StringSlice(filter.Values)})
+	}
+	return rdsFilters
+}
+
+// parseTagFilters parses a comma separated list of tag filters, such as "env=prod,team=data|platform,backup".
+// Each filter is made of a tag key and optional values separated by "|". A resource must match every filter, and
+// matches a filter if it carries its key with one of its values, or with any value if no value is given.
+func parseTagFilters(s string) ([]tagFilter, error) {
+	filters := make([]tagFilter, 0)
+	if len(strings.TrimSpace(s)) == 0 {
+		return filters, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		key, values, hasValues := strings.Cut(strings.TrimSpace(item), "=")
+		if len(key) == 0 {
+			return nil, fmt.Errorf("invalid tag filter %q: tag key should not be empty", item)
+		}
+		filter := tagFilter{Key: key}
+		if hasValues {
+			filter.Values = strings.Split(values, "|")
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// matchTagFilters returns true if the given tags match every tag filter.
+func matchTagFilters(tags map[string]string, filters []tagFilter) bool {
+	for _, filter := range filters {
+		value, ok := tags[filter.Key]
+		if !ok {
+			return false
+		}
+		if len(filter.Values) > 0 && !contains(filter.Values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterRDSInfos returns the RDSInfos whose tags match every tag filter.
+func filterRDSInfos(rdsInfos []RDSInfo, filters []tagFilter) []RDSInfo {
+	if len(filters) == 0 {
+		return rdsInfos
+	}
+	filtered := make([]RDSInfo, 0, len(rdsInfos))
+	for _, rdsInfo := range rdsInfos {
+		if matchTagFilters(rdsInfo.Tags, filters) {
+			filtered = append(filtered, rdsInfo)
+		}
+	}
+	return filtered
+}
+
+// getTaggedRDSResources lists the ARNs of the RDS clusters and instances matching the tag filters with the Resource
+// Groups Tagging API GetResources, which filters on tags server-side. Each page of ARNs is then described with
+// DescribeDBClusters and DescribeDBInstances, filtered on these ARNs and on the API filters, to fetch the details of
+// the resources; handle is called with the RDSInfos of each described page.
+// An error is returned if the function fails to retrieve resource information or if handle returns an error.
+func getTaggedRDSResources(config *Config, handle func([]RDSInfo) error) error {
+	var paginationToken *string
+	condition := true
+	for condition {
+		resources, err := config.Tagging.GetResources(&resourcegroupstaggingapi.GetResourcesInput{
+			PaginationToken:     paginationToken,
+			ResourceTypeFilters: []*string{Ptr("rds:cluster"), Ptr("rds:db")},
+			ResourcesPerPage:    Ptr(int64(100)),
+			TagFilters:          toTaggingAPITagFilters(config.TagFilters),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get tagged resources; %w", err)
+		}
+		if resources == nil {
+			break
+		}
+
+		clusterArns, instanceArns := splitRDSArns(resources.ResourceTagMappingList)
+		if len(clusterArns) > 0 {
+			filters := append([]*rds.Filter{{Name: Ptr("db-cluster-id"), Values: clusterArns}}, config.ClusterFilters...)
+			if err := getRDSClusters(config, filters, handle); err != nil {
+				return err
+			}
+		}
+		if len(instanceArns) > 0 {
+			filters := append([]*rds.Filter{{Name: Ptr("db-instance-id"), Values: instanceArns}}, config.InstanceFilters...)
+			if err := getRDSInstances(config, filters, handle); err != nil {
+				return err
+			}
+		}
+
+		paginationToken = resources.PaginationToken
+		condition = len(aws.StringValue(paginationToken)) > 0
+	}
+	return nil
+}
+
+// toTaggingAPITagFilters converts tag filters to their Resource Groups Tagging API representation.
+func toTaggingAPITagFilters(filters []tagFilter) []*resourcegroupstaggingapi.TagFilter {
+	tagFilters := make([]*resourcegroupstaggingapi.TagFilter, 0, len(filters))
+	for _, filter := range filters {
+		tagFilters = append(tagFilters, &resourcegroupstaggingapi.TagFilter{
+			Key:    Ptr(filter.Key),
+			Values: aws.StringSlice(filter.Values),
+		})
+	}
+	return tagFilters
+}
+
+// splitRDSArns splits the ARNs of tagged RDS resources into cluster ARNs and instance ARNs. ARNs of other RDS resource
+// types, or ARNs that cannot be parsed, are ignored.
+func splitRDSArns(mappings []*resourcegroupstaggingapi.ResourceTagMapping) ([]*string, []*string) {
+	clusterArns := make([]*string, 0)
+	instanceArns := make([]*string, 0)
+	for _, mapping := range mappings {
+		parsed, err := arn.Parse(aws.StringValue(mapping.ResourceARN))
+		if err != nil {
+			continue
+		}
+		switch resourceType, _, _ := strings.Cut(parsed.Resource, ":"); resourceType {
+		case "cluster":
+			clusterArns = append(clusterArns, mapping.ResourceARN)
+		case "db":
+			instanceArns = append(instanceArns, mapping.ResourceARN)
+		}
+	}
+	return clusterArns, instanceArns
+}
+
+// tagsToMap converts a list of RDS tags to a map of tag keys to tag values.
+func tagsToMap(tagList []*rds.Tag) map[string]string {
+	tags := make(map[string]string, len(tagList))
+	for _, tag := range tagList {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags
+}
@@ -0,0 +1,184 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestParseTagFilters tests the parseTagFilters function.
+func TestParseTagFilters(t *testing.T) {
+	tests := []struct {
+		desc    string
+		input   string
+		want    []tagFilter
+		wantErr bool
+	}{
+		{desc: "empty", input: "", want: []tagFilter{}},
+		{
+			desc:  "keys and values",
+			input: "env=prod, team=data|platform,backup",
+			want: []tagFilter{
+				{Key: "env", Values: []string{"prod"}},
+				{Key: "team", Values: []string{"data", "platform"}},
+				{Key: "backup"},
+			},
+		},
+		{desc: "empty key", input: "env=prod,=foo", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := parseTagFilters(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestMatchTagFilters tests the matchTagFilters function.
+func TestMatchTagFilters(t *testing.T) {
+	filters := []tagFilter{
+		{Key: "team", Values: []string{"data", "platform"}},
+		{Key: "backup"},
+	}
+
+	assert.True(t, matchTagFilters(map[string]string{"team": "data", "backup": ""}, filters))
+	assert.True(t, matchTagFilters(map[string]string{"team": "platform", "backup": "daily", "env": "prod"}, filters))
+	assert.False(t, matchTagFilters(map[string]string{"team": "web", "backup": "daily"}, filters))
+	assert.False(t, matchTagFilters(map[string]string{"team": "data"}, filters))
+	assert.True(t, matchTagFilters(nil, nil))
+}
+
+// TestGetTaggedRDSResources tests the getTaggedRDSResources function.
+func TestGetTaggedRDSResources(t *testing.T) {
+	tagging := &MockTaggingAPI{
+		resourcesOutput: []*resourcegroupstaggingapi.GetResourcesOutput{
+			{
+				ResourceTagMappingList: []*resourcegroupstaggingapi.ResourceTagMapping{
+					{ResourceARN: Ptr("arn:aws:rds:eu-west-1:123456789012:cluster:cluster-1")},
+					{ResourceARN: Ptr("arn:aws:rds:eu-west-1:123456789012:db:db-1")},
+					{ResourceARN: Ptr("arn:aws:rds:eu-west-1:123456789012:snapshot:snap-1")},
+				},
+				PaginationToken: Ptr("token"),
+			},
+			{
+				ResourceTagMappingList: []*resourcegroupstaggingapi.ResourceTagMapping{
+					{ResourceARN: Ptr("arn:aws:rds:eu-west-1:123456789012:db:db-2")},
+				},
+				PaginationToken: Ptr(""),
+			},
+		},
+	}
+	config := &Config{
+		RDS: &MockRDSAPI{
+			clustersOutput: []*rds.DescribeDBClustersOutput{{
+				DBClusters: []*rds.DBCluster{
+					{
+						DBClusterArn:        Ptr("arn:aws:rds:eu-west-1:123456789012:cluster:cluster-1"),
+						DBClusterIdentifier: Ptr("cluster-1"),
+						Engine:              Ptr("aurora-postgresql"),
+						EngineVersion:       Ptr("13.7"),
+						TagList:             []*rds.Tag{{Key: Ptr("team"), Value: Ptr("data")}},
+					},
+				},
+			}},
+			instancesOutput: []*rds.DescribeDBInstancesOutput{{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceArn:        Ptr("arn:aws:rds:eu-west-1:123456789012:db:db-1"),
+						DBInstanceIdentifier: Ptr("db-1"),
+						Engine:               Ptr("mysql"),
+						EngineVersion:        Ptr("8.0.25"),
+						TagList:              []*rds.Tag{{Key: Ptr("team"), Value: Ptr("data")}},
+					},
+					{
+						DBInstanceArn:        Ptr("arn:aws:rds:eu-west-1:123456789012:db:db-2"),
+						DBInstanceIdentifier: Ptr("db-2"),
+						Engine:               Ptr("mysql"),
+						EngineVersion:        Ptr("5.7.34"),
+						TagList:              []*rds.Tag{{Key: Ptr("team"), Value: Ptr("data")}},
+					},
+					{
+						DBInstanceArn:        Ptr("arn:aws:rds:eu-west-1:123456789012:db:untagged"),
+						DBInstanceIdentifier: Ptr("untagged"),
+						Engine:               Ptr("mysql"),
+						EngineVersion:        Ptr("5.7.34"),
+					},
+				},
+			}},
+		},
+		Tagging:    tagging,
+		TagFilters: []tagFilter{{Key: "team", Values: []string{"data"}}},
+	}
+
+	var got []string
+	err := getTaggedRDSResources(config, func(rdsInfos []RDSInfo) error {
+		for _, rdsInfo := range rdsInfos {
+			got = append(got, rdsInfo.ClusterIdentifier)
+			assert.Equal(t, map[string]string{"team": "data"}, rdsInfo.Tags)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster-1", "db-1", "db-2"}, got)
+
+	assert.Len(t, tagging.inputs, 2)
+	assert.Equal(t, []*string{Ptr("rds:cluster"), Ptr("rds:db")}, tagging.inputs[0].ResourceTypeFilters)
+	assert.Equal(t, []*resourcegroupstaggingapi.TagFilter{{Key: Ptr("team"), Values: []*string{Ptr("data")}}},
+		tagging.inputs[0].TagFilters)
+
+	config.Tagging = &MockTaggingAPI{err: errors.New("access denied")}
+	err = getTaggedRDSResources(config, func([]RDSInfo) error { return nil })
+	assert.EqualError(t, err, "failed to get tagged resources; access denied")
+}
+
+// TestAPIFilters tests the validation of API filters and their conversion to the RDS API representation.
+func TestAPIFilters(t *testing.T) {
+	filters := apiFilters{
+		Clusters: []apiFilter{{Name: "engine", Values: []string{"aurora-postgresql"}}, {Name: "dbi-resource-id"}},
+		Instances: []apiFilter{
+			{Name: "db-cluster-id", Values: []string{"orders", "billing"}},
+			{Name: "instance-class", Values: []string{"db.t2.micro"}},
+		},
+	}
+	assert.Equal(t, []string{
+		`cluster API filter should be one of clone-group-id, db-cluster-id, db-cluster-resource-id, domain, engine, ` +
+			`got "dbi-resource-id"`,
+		`cluster API filter "dbi-resource-id" should have values`,
+		`instance API filter should be one of db-cluster-id, db-instance-id, dbi-resource-id, domain, engine, ` +
+			`got "instance-class"`,
+	}, filters.validate())
+
+	assert.Nil(t, toRDSFilters(nil))
+	assert.Equal(t, []*rds.Filter{{Name: Ptr("db-cluster-id"), Values: []*string{Ptr("orders"), Ptr("billing")}}},
+		toRDSFilters(filters.Instances[:1]))
+}
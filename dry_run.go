@@ -0,0 +1,209 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"io"
+	"strings"
+)
+
+// plannedCall is an AWS API operation that a snapshot would perform.
+type plannedCall struct {
+	// Operation is the IAM action of the operation, e.g. "rds:DescribeDBInstances".
+	Operation string
+
+	// Purpose tells why and how often the operation is called.
+	Purpose string
+}
+
+// planStartup returns the AWS API operations that the exporter performs once per account at startup, to resolve the
+// regions to scan, if every region is, and the ID and the alias of the account.
+func planStartup(options *Options, account AccountScope) []plannedCall {
+	var calls []plannedCall
+	if account.RoleARN != "" {
+		calls = append(calls, plannedCall{"sts:AssumeRole", "obtain credentials for " + account.RoleARN})
+	}
+	if options.allRegions {
+		regions := "list the regions enabled in the account"
+		if len(options.excludeRegions) > 0 {
+			regions += ", but " + strings.Join(options.excludeRegions, ", ")
+		}
+		calls = append(calls, plannedCall{"ec2:DescribeRegions", regions})
+	}
+	return append(calls,
+		plannedCall{"sts:GetCallerIdentity", "resolve the ID of the account"},
+		plannedCall{"iam:ListAccountAliases", "resolve the alias of the account"},
+	)
+}
+
+// planSnapshot returns the AWS API operations that a snapshot of the account and region of the config would perform,
+// following its discovery backend and optional collectors, in the order they are first called.
+func planSnapshot(config *Config) []plannedCall {
+	var calls []plannedCall
+	if config.RoleARN != "" {
+		calls = append(calls, plannedCall{"sts:AssumeRole", "obtain credentials for " + config.RoleARN})
+	}
+	if config.DiscoveryBackend == discoveryBackendTagging {
+		calls = append(calls,
+			plannedCall{"tag:GetResources", fmt.Sprintf("list the clusters and instances tagged %s, 100 per page",
+				describeTagFilters(config.TagFilters))},
+			plannedCall{"rds:DescribeDBClusters", "describe the tagged clusters" +
+				describeAPIFilters(config.ClusterFilters) + " of each page"},
+			plannedCall{"rds:DescribeDBInstances", "describe the tagged instances" +
+				describeAPIFilters(config.InstanceFilters) + " of each page"},
+		)
+	} else {
+		clusters, instances := "list every cluster", "list every instance"
+		if len(config.ClusterFilters) > 0 {
+			clusters = "list the clusters" + describeAPIFilters(config.ClusterFilters)
+		}
+		if len(config.InstanceFilters) > 0 {
+			instances = "list the instances" + describeAPIFilters(config.InstanceFilters)
+		}
+		calls = append(calls,
+			plannedCall{"rds:DescribeDBClusters", clusters + ", page by page"},
+			plannedCall{"rds:DescribeDBInstances", instances + ", page by page"},
+		)
+		if len(config.TagFilters) > 0 {
+			calls[len(calls)-1].Purpose += fmt.Sprintf(", then keep the ones tagged %s",
+				describeTagFilters(config.TagFilters))
+		}
+	}
+	catalogs := plannedCall{"rds:DescribeDBEngineVersions", "fetch the catalog of each engine in use, until it is refreshed"}
+	if config.CatalogInfo {
+		catalogs.Purpose = "fetch the catalogs of every engine at once, until they are refreshed"
+	}
+	calls = append(calls,
+		catalogs,
+		plannedCall{"rds:DescribeDBClusters", "describe the clusters of member instances whose role is unknown"},
+	)
+	if config.GlobalClusters {
+		calls = append(calls, plannedCall{"rds:DescribeGlobalClusters", "list every Aurora Global Database"})
+	}
+	if config.ForcedUpgrades {
+		calls = append(calls, plannedCall{"rds:DescribePendingMaintenanceActions",
+			"list the pending engine upgrades, page by page"})
+	}
+	if config.DBSnapshots {
+		calls = append(calls,
+			plannedCall{"rds:DescribeDBClusterSnapshots", "list every manual cluster snapshot, page by page"},
+			plannedCall{"rds:DescribeDBSnapshots", "list every manual instance snapshot, page by page"},
+		)
+	}
+	if config.Reservations {
+		calls = append(calls, plannedCall{"rds:DescribeReservedDBInstances",
+			"list the reserved DB instances, page by page"})
+	}
+	if config.HealthEvents {
+		calls = append(calls, plannedCall{"health:DescribeEvents",
+			"list the upcoming and open scheduled changes of RDS, page by page"})
+	}
+	if config.TrustedAdvisor {
+		calls = append(calls,
+			plannedCall{"support:DescribeTrustedAdvisorChecks", "list the Trusted Advisor checks, once per account"},
+			plannedCall{"support:DescribeTrustedAdvisorCheckResult", "read the result of each check of RDS"},
+		)
+	}
+	if config.InstanceClasses {
+		calls = append(calls, plannedCall{"rds:DescribeOrderableDBInstanceOptions",
+			"check each instance class in use, once per engine"})
+	}
+	if config.Babelfish {
+		calls = append(calls, plannedCall{"rds:DescribeDBClusterParameters",
+			"check the custom parameter group of each Aurora PostgreSQL cluster, once per parameter group"})
+	}
+	if config.Remediation != nil && config.Remediation.Apply {
+		calls = append(calls, plannedCall{"rds:ModifyDBInstance", fmt.Sprintf(
+			"enable the automatic minor version upgrades of the available instances tagged %s without them",
+			describeTagFilters(config.Remediation.TagFilters))})
+	}
+	return calls
+}
+
+// describeAPIFilters formats API filters for humans, e.g. " matching engine=postgres|mysql", or returns an empty string
+// if there are none.
+func describeAPIFilters(filters []*rds.Filter) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	descriptions := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		descriptions = append(descriptions,
+			aws.StringValue(filter.Name)+"="+strings.Join(aws.StringValueSlice(filter.Values), "|"))
+	}
+	return " matching " + strings.Join(descriptions, " and ")
+}
+
+// describeTagFilters formats tag filters for humans, e.g. "env=prod and team=a|b".
+func describeTagFilters(filters []tagFilter) string {
+	if len(filters) == 0 {
+		return "with anything"
+	}
+	descriptions := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		description := filter.Key
+		if len(filter.Values) > 0 {
+			description += "=" + strings.Join(filter.Values, "|")
+		}
+		descriptions = append(descriptions, description)
+	}
+	return strings.Join(descriptions, " and ")
+}
+
+// printDryRun prints the accounts and regions resolved from the configuration, the AWS API operations that the exporter
+// would perform at startup in each account, and the ones that each snapshot would perform in them, without calling AWS.
+// The regions of the accounts are not discovered when scanning all regions, so they are printed as a single one. It lets operators validate the IAM permissions and the scope of
+// the exporter before granting it real credentials.
+func printDryRun(w io.Writer, options *Options, scopes *Scopes) {
+	fmt.Fprintf(w, "dry run: every %s, the exporter would perform the following AWS API calls\n", options.PollInterval)
+	for _, account := range scopes.Accounts {
+		alias := ""
+		if account.Alias != "" {
+			alias = fmt.Sprintf(" (%s)", account.Alias)
+		}
+		if account.RoleARN == "" {
+			fmt.Fprintf(w, "account of the default credentials%s:\n", alias)
+		} else {
+			fmt.Fprintf(w, "account of role %s%s:\n", account.RoleARN, alias)
+		}
+		fmt.Fprintf(w, "  at startup:\n")
+		for _, call := range planStartup(options, account) {
+			fmt.Fprintf(w, "    %-40s %s\n", call.Operation, call.Purpose)
+		}
+		for _, config := range account.Regions {
+			region := config.Region
+			if region == "" && options.allRegions {
+				region = "<each enabled region>"
+			} else if region == "" {
+				region = "<no region configured>"
+			}
+			fmt.Fprintf(w, "  region %s:\n", region)
+			for _, call := range planSnapshot(config) {
+				fmt.Fprintf(w, "    %-40s %s\n", call.Operation, call.Purpose)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestPrintDryRun tests that printDryRun prints the AWS API calls of each account and region, following the discovery
+// backend and the optional collectors.
+func TestPrintDryRun(t *testing.T) {
+	roleARN := "arn:aws:iam::123456789012:role/exporter"
+	tagFilters := []tagFilter{{Key: "env", Values: []string{"prod"}}, {Key: "backup"}}
+	scopes := &Scopes{Accounts: []AccountScope{
+		{Regions: []*Config{{Region: "eu-west-1", DiscoveryBackend: discoveryBackendDescribe, TagFilters: tagFilters,
+			InstanceClasses: true, ClusterFilters: toRDSFilters([]apiFilter{
+				{Name: "engine", Values: []string{"aurora-mysql", "aurora-postgresql"}}})}}},
+		{RoleARN: roleARN, Regions: []*Config{{Region: "us-east-1", RoleARN: roleARN,
+			DiscoveryBackend: discoveryBackendTagging, TagFilters: tagFilters, GlobalClusters: true}}},
+	}}
+
+	var b bytes.Buffer
+	printDryRun(&b, &Options{PollInterval: 5 * time.Minute}, scopes)
+	assert.Equal(t, `dry run: every 5m0s, the exporter would perform the following AWS API calls
+account of the default credentials:
+  at startup:
+    sts:GetCallerIdentity                    resolve the ID of the account
+    iam:ListAccountAliases                   resolve the alias of the account
+  region eu-west-1:
+    rds:DescribeDBClusters                   list the clusters matching engine=aurora-mysql|aurora-postgresql, page by page
+    rds:DescribeDBInstances                  list every instance, page by page, then keep the ones tagged env=prod and backup
+    rds:DescribeDBEngineVersions             fetch the catalog of each engine in use, until it is refreshed
+    rds:DescribeDBClusters                   describe the clusters of member instances whose role is unknown
+    rds:DescribeOrderableDBInstanceOptions   check each instance class in use, once per engine
+account of role arn:aws:iam::123456789012:role/exporter:
+  at startup:
+    sts:AssumeRole                           obtain credentials for arn:aws:iam::123456789012:role/exporter
+    sts:GetCallerIdentity                    resolve the ID of the account
+    iam:ListAccountAliases                   resolve the alias of the account
+  region us-east-1:
+    sts:AssumeRole                           obtain credentials for arn:aws:iam::123456789012:role/exporter
+    tag:GetResources                         list the clusters and instances tagged env=prod and backup, 100 per page
+    rds:DescribeDBClusters                   describe the tagged clusters of each page
+    rds:DescribeDBInstances                  describe the tagged instances of each page
+    rds:DescribeDBEngineVersions             fetch the catalog of each engine in use, until it is refreshed
+    rds:DescribeDBClusters                   describe the clusters of member instances whose role is unknown
+    rds:DescribeGlobalClusters               list every Aurora Global Database
+`, b.String())
+}
+
+// TestDryRunAllRegions tests that a dry run scanning every region does not call AWS to discover the regions, nor to
+// resolve the accounts, and plans these calls instead.
+func TestDryRunAllRegions(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	options := &Options{PollInterval: time.Minute, DryRun: true, allRegions: true, excludeRegions: []string{"us-west-1"},
+		roleARNs: []string{"arn:aws:iam::123456789012:role/exporter"}}
+	// without credentials, discovering the regions would fail
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/credentials")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	scopes, err := NewScopes(options)
+	assert.Nil(t, err)
+
+	var b bytes.Buffer
+	printDryRun(&b, options, scopes)
+	assert.Equal(t, `dry run: every 1m0s, the exporter would perform the following AWS API calls
+account of role arn:aws:iam::123456789012:role/exporter:
+  at startup:
+    sts:AssumeRole                           obtain credentials for arn:aws:iam::123456789012:role/exporter
+    ec2:DescribeRegions                      list the regions enabled in the account, but us-west-1
+    sts:GetCallerIdentity                    resolve the ID of the account
+    iam:ListAccountAliases                   resolve the alias of the account
+  region <each enabled region>:
+    sts:AssumeRole                           obtain credentials for arn:aws:iam::123456789012:role/exporter
+    rds:DescribeDBClusters                   list every cluster, page by page
+    rds:DescribeDBInstances                  list every instance, page by page
+    rds:DescribeDBEngineVersions             fetch the catalog of each engine in use, until it is refreshed
+    rds:DescribeDBClusters                   describe the clusters of member instances whose role is unknown
+`, b.String())
+}
@@ -0,0 +1,55 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+)
+
+// commercialEditions maps the edition suffixes of commercial RDS engine names, such as "oracle-se2" or
+// "sqlserver-ex", to the name of the edition.
+var commercialEditions = map[string]string{
+	"ee":  "enterprise",
+	"se":  "standard",
+	"se1": "standard-one",
+	"se2": "standard-two",
+	"ex":  "express",
+	"web": "web",
+}
+
+// commercialEngineLabels returns the license model and the edition of an RDS resource running a commercial engine,
+// i.e. Oracle or SQL Server, including RDS Custom and multitenant (CDB) variants. The edition is parsed from the engine
+// name, e.g. "oracle-se2-cdb" runs the "standard-two" edition. Empty strings are returned for other engines, whose
+// license model does not change the deprecation impact nor the upgrade path.
+func commercialEngineLabels(engine, licenseModel string) (string, string) {
+	name := strings.TrimSuffix(strings.TrimPrefix(engine, customEnginePrefix), "-cdb")
+	product, suffix, ok := strings.Cut(name, "-")
+	if !ok || (product != "oracle" && product != "sqlserver") {
+		return "", ""
+	}
+	edition, ok := commercialEditions[suffix]
+	if !ok {
+		edition = suffix
+	}
+	return licenseModel, edition
+}
@@ -0,0 +1,55 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestCommercialEngineLabels tests the commercialEngineLabels function.
+func TestCommercialEngineLabels(t *testing.T) {
+	tests := []struct {
+		engine           string
+		licenseModel     string
+		wantLicenseModel string
+		wantEdition      string
+	}{
+		{"oracle-ee", "bring-your-own-license", "bring-your-own-license", "enterprise"},
+		{"oracle-se2-cdb", "license-included", "license-included", "standard-two"},
+		{"custom-oracle-ee", "bring-your-own-license", "bring-your-own-license", "enterprise"},
+		{"sqlserver-ex", "license-included", "license-included", "express"},
+		{"sqlserver-web", "license-included", "license-included", "web"},
+		{"sqlserver-xx", "license-included", "license-included", "xx"},
+		{"mysql", "general-public-license", "", ""},
+		{"aurora-postgresql", "postgresql-license", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine, func(t *testing.T) {
+			licenseModel, edition := commercialEngineLabels(tt.engine, tt.licenseModel)
+			assert.Equal(t, tt.wantLicenseModel, licenseModel)
+			assert.Equal(t, tt.wantEdition, edition)
+		})
+	}
+}
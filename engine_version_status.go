@@ -24,68 +24,168 @@ package main
 
 import (
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
-// versionDeprecations is mapping RDS engine versions to their deprecation status. A version will be mapped to true if
-// it's deprecated.
-type versionDeprecations map[string]bool
+// statusDeprecated is the DescribeDBEngineVersions status of an engine version that is deprecated.
+const statusDeprecated = "deprecated"
 
-// engineVersions is mapping an RDS Engine to its available versionDeprecations
-type engineVersions map[string]versionDeprecations
+// The classifications of the catalog statuses: the statuses the exporter does not know, e.g. introduced by AWS after
+// its release, are "unknown", and handled like the available ones.
+const (
+	classificationAvailable  = "available"
+	classificationDeprecated = "deprecated"
+	classificationUnknown    = "unknown"
+)
+
+// customEnginePrefix prefixes the names of the RDS Custom engines, e.g. "custom-oracle-ee".
+const customEnginePrefix = "custom-"
+
+// deprecatedStatuses is the set of DescribeDBEngineVersions statuses of engine versions that can no longer be used to
+// create new databases. Besides "deprecated", the Custom Engine Versions (CEV) of RDS Custom are deactivated by their
+// owner with the "inactive" and "inactive-except-restore" statuses, and the version policy bans versions with the
+// "banned" status.
+var deprecatedStatuses = map[string]bool{
+	statusDeprecated:          true,
+	statusBanned:              true,
+	"inactive":                true,
+	"inactive-except-restore": true,
+}
+
+// versionInfo represents information about an RDS engine version, as listed in the RDS engine version catalog.
+type versionInfo struct {
+	// Status is the raw catalog status of the engine version.
+	// Examples of statuses include "available" and "deprecated".
+	Status string
+
+	// CatalogStatus is the status of the catalog when the version policy replaced Status with "banned", and is empty
+	// otherwise.
+	CatalogStatus string
+
+	// UpgradeTargets are the engine versions the version can be upgraded to, in the order of the catalog.
+	UpgradeTargets []upgradeTarget
+
+	// SupportsReadReplica and SupportsLogExports tell whether the version supports read replicas and exporting its logs
+	// to CloudWatch Logs.
+	SupportsReadReplica bool
+	SupportsLogExports  bool
+
+	// EngineModes are the sorted engine modes the version supports, e.g. "provisioned" or "serverless".
+	EngineModes []string
+
+	// ParameterGroupFamily is the family of the parameter groups of the version, e.g. "postgres13".
+	ParameterGroupFamily string
+}
+
+// upgradeTarget is an engine version an RDS engine version can be upgraded to.
+type upgradeTarget struct {
+	EngineVersion string
+
+	// Major is true if the upgrade is a major version upgrade.
+	Major bool
+
+	// Default is true if the target is the default minor version, which RDS applies to the resources with automatic
+	// minor version upgrades.
+	Default bool
+}
 
-// getEngineVersions() returns a map of RDS engine versions and their deprecation status, represented by a nested
-// map of engineVersions and versionDeprecations.
+// versionCatalog is mapping RDS engine versions to their versionInfo.
+type versionCatalog map[string]versionInfo
+
+// engineVersions is mapping an RDS Engine to its available versionCatalog
+type engineVersions map[string]versionCatalog
+
+// getEngineVersions() returns a map of RDS engine versions and their catalog information, represented by a nested
+// map of engineVersions and versionCatalog.
 //
-// The engineVersions is a map of RDS engine names to versionDeprecations, which is another map of RDS engine versions
-// to boolean values representing whether that version is deprecated or not.
+// The engineVersions is a map of RDS engine names to versionCatalog, which is another map of RDS engine versions
+// to versionInfo structs holding the status of that version.
 //
-// The function populates this map by calling queryEngineVersions() twice with false as the first parameter,
-// passing in the engineVersions map as the second parameter. If an error occurs during either of the calls to
-// queryEngineVersions(), an error is returned.
-func getEngineVersions(config *Config) (engineVersions, error) {
-	m := make(engineVersions)
-
-	if err := queryEngineVersions(config, false, m); err != nil {
-		return nil, fmt.Errorf("error while querying rds deprecated engine version; %w", err)
+// Only the catalogs of the given engines are fetched: the function calls queryEngineVersions() once per engine, which
+// lists every version of that engine regardless of its status. Engines are queried in parallel on a worker pool bounded
+// by the Config concurrency. If an error occurs during any of the calls to queryEngineVersions(), an error is returned.
+// The versions banned by the version policy of the Config are "banned".
+func getEngineVersions(config *Config, engines []string) (engineVersions, error) {
+	catalogs := make([]versionCatalog, len(engines))
+	tasks := make([]func() error, len(engines))
+	for i, engine := range engines {
+		i, engine := i, engine
+		tasks[i] = func() error {
+			catalog, err := queryEngineVersions(config, engine)
+			if err != nil {
+				return fmt.Errorf("error while querying rds engine versions for engine %s; %w", engine, err)
+			}
+			catalogs[i] = catalog
+			return nil
+		}
 	}
-	if err := queryEngineVersions(config, true, m); err != nil {
-		return nil, fmt.Errorf("error while querying rds available engine version; %w", err)
+	if err := runPool(config.Concurrency, tasks); err != nil {
+		return nil, err
 	}
 
+	m := make(engineVersions)
+	for i, engine := range engines {
+		if len(catalogs[i]) > 0 {
+			m[engine] = catalogs[i]
+		}
+	}
+	config.Policy.ban(m)
 	return m, nil
 }
 
-// queryEngineVersions() queries the AWS RDS API to get information about the deprecation status of engine
-// versions, as determined by the deprecatedVersion boolean parameter.
-//
-// The function takes in a map of engineVersions as a second parameter, which is used to store the deprecation status
-// of each RDS engine version.
+// queryEngineVersions() queries the AWS RDS API to get information about the status of every version of an engine,
+// and returns them as a versionCatalog.
 //
-// The function creates an AWS session and RDS client using the AWS SDK for Go. It then loops over all pages of the RDS
-// engine versions using the DescribeDBEngineVersions API method with a filter on the status field set to either
-// "available" or "deprecated", depending on the deprecatedVersion parameter.
+// The function loops over all pages of the RDS engine versions using the DescribeDBEngineVersions API method filtered
+// on the given engine, with IncludeAll set to true, so that versions are listed whatever their status is ("available",
+// "deprecated", ...) in a single paginated pass. The valid upgrade targets of each version are kept along its status.
 //
-// For each RDS engine version, the function updates the engineVersions map with the deprecation status of that version.
-// If the RDS engine is not already in the map, it creates a new versionDeprecations map to store the deprecation
-// status of that engine's versions.
-//
-// If any error occurs while querying the RDS API or updating the engineVersions map, an error is returned.
-//
-// Overall, this function is responsible for populating the engineVersions map with deprecation status information
-// retrieved from the AWS RDS API.
-func queryEngineVersions(config *Config, deprecatedVersion bool, m engineVersions) error {
-	status := evalStatus(deprecatedVersion)
+// If any error occurs while querying the RDS API, an error is returned.
+func queryEngineVersions(config *Config, engine string) (versionCatalog, error) {
+	catalog := make(versionCatalog)
+	err := describeEngineVersions(config, &engine, func(dbEngineVersion *rds.DBEngineVersion) {
+		catalog[*dbEngineVersion.EngineVersion] = newVersionInfo(dbEngineVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
 
+// queryAllEngineVersions queries the catalogs of every engine at once, like queryEngineVersions does for a single
+// engine, whether the engines are in use or not. The versions banned by the version policy of the Config are "banned".
+func queryAllEngineVersions(config *Config) (engineVersions, error) {
+	m := make(engineVersions)
+	err := describeEngineVersions(config, nil, func(dbEngineVersion *rds.DBEngineVersion) {
+		engine := aws.StringValue(dbEngineVersion.Engine)
+		if m[engine] == nil {
+			m[engine] = make(versionCatalog)
+		}
+		m[engine][*dbEngineVersion.EngineVersion] = newVersionInfo(dbEngineVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.Policy.ban(m)
+	return m, nil
+}
+
+// describeEngineVersions calls add with every version of the given engine, or of every engine if nil, whatever its
+// status is, page by page.
+func describeEngineVersions(config *Config, engine *string, add func(*rds.DBEngineVersion)) error {
 	var nextMarker *string
 	cond := true
 	for cond {
 		dbEngineVersions, err := config.RDS.DescribeDBEngineVersions(&rds.DescribeDBEngineVersionsInput{
-			Filters: []*rds.Filter{{
-				Name:   Ptr("status"),
-				Values: []*string{&status},
-			}},
-			Marker: nextMarker,
+			Engine:     engine,
+			IncludeAll: Ptr(true),
+			Marker:     nextMarker,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to describe db engine versions; %w", err)
@@ -94,13 +194,7 @@ func queryEngineVersions(config *Config, deprecatedVersion bool, m engineVersion
 			break
 		}
 		for _, dbEngineVersion := range dbEngineVersions.DBEngineVersions {
-			if deprecationMap, ok := m[*dbEngineVersion.Engine]; ok {
-				deprecationMap[*dbEngineVersion.EngineVersion] = deprecatedVersion
-			} else {
-				deprecationMap := make(versionDeprecations)
-				deprecationMap[*dbEngineVersion.EngineVersion] = deprecatedVersion
-				m[*dbEngineVersion.Engine] = deprecationMap
-			}
+			add(dbEngineVersion)
 		}
 		nextMarker = dbEngineVersions.Marker
 		cond = nextMarker != nil
@@ -108,28 +202,193 @@ func queryEngineVersions(config *Config, deprecatedVersion bool, m engineVersion
 	return nil
 }
 
-func evalStatus(deprecated bool) string {
-	if deprecated {
-		return "deprecated"
-	} else {
-		return "available"
+// newVersionInfo returns the versionInfo of an engine version of the catalog.
+func newVersionInfo(dbEngineVersion *rds.DBEngineVersion) versionInfo {
+	info := versionInfo{
+		Status:              aws.StringValue(dbEngineVersion.Status),
+		SupportsReadReplica: aws.BoolValue(dbEngineVersion.SupportsReadReplica),
+		SupportsLogExports:  aws.BoolValue(dbEngineVersion.SupportsLogExportsToCloudwatchLogs),
+
+		ParameterGroupFamily: aws.StringValue(dbEngineVersion.DBParameterGroupFamily),
 	}
+	if len(dbEngineVersion.SupportedEngineModes) > 0 {
+		info.EngineModes = aws.StringValueSlice(dbEngineVersion.SupportedEngineModes)
+		sort.Strings(info.EngineModes)
+	}
+	for _, target := range dbEngineVersion.ValidUpgradeTarget {
+		info.UpgradeTargets = append(info.UpgradeTargets, upgradeTarget{
+			EngineVersion: aws.StringValue(target.EngineVersion),
+			Major:         aws.BoolValue(target.IsMajorVersionUpgrade),
+			Default:       aws.BoolValue(target.AutoUpgrade),
+		})
+	}
+	return info
+}
+
+// exportUpgradeTargets sets the UpgradeTargetsGauge to the number of minor and major upgrade targets of the engine
+// version of an RDSInfo, according to its catalog. Nothing is exported for versions missing from the catalog.
+func exportUpgradeTargets(metrics *Metrics, rdsInfo RDSInfo, m engineVersions) {
+	info, ok := m[rdsInfo.Engine][rdsInfo.EngineVersion]
+	if !ok {
+		return
+	}
+	counts := map[string]int{"minor": 0, "major": 0}
+	for _, target := range info.UpgradeTargets {
+		if target.Major {
+			counts["major"]++
+		} else {
+			counts["minor"]++
+		}
+	}
+	for upgrade, count := range counts {
+		metrics.UpgradeTargetsGauge.With(prometheus.Labels{
+			"engine":         rdsInfo.Engine,
+			"engine_version": rdsInfo.EngineVersion,
+			"upgrade":        upgrade,
+			"account_id":     rdsInfo.Account,
+			"region":         rdsInfo.Region,
+		}).Set(float64(count))
+	}
+}
+
+// exportCatalogInfo sets the EngineVersionInfoGauge to 1 for every version of the engineVersions map of an account and
+// region, with its catalog status, and the EngineCapabilitiesGauge to 1 with its capabilities.
+func exportCatalogInfo(metrics *Metrics, account, region string, m engineVersions) {
+	for engine, catalog := range m {
+		for engineVersion, info := range catalog {
+			metrics.EngineVersionInfoGauge.With(prometheus.Labels{
+				"engine":         engine,
+				"engine_version": engineVersion,
+				"status":         info.Status,
+				"account_id":     account,
+				"region":         region,
+			}).Set(1)
+			metrics.EngineCapabilitiesGauge.With(prometheus.Labels{
+				"engine":                engine,
+				"engine_version":        engineVersion,
+				"supports_read_replica": strconv.FormatBool(info.SupportsReadReplica),
+				"supports_log_exports":  strconv.FormatBool(info.SupportsLogExports),
+				"engine_modes":          strings.Join(info.EngineModes, ","),
+				"account_id":            account,
+				"region":                region,
+			}).Set(1)
+		}
+	}
+}
+
+// catalogStatus returns the raw status of the catalog, even if the version policy replaced Status with "banned".
+func (info versionInfo) catalogStatus() string {
+	if info.CatalogStatus != "" {
+		return info.CatalogStatus
+	}
+	return info.Status
+}
+
+// classifyStatus returns how the exporter handles a status: "deprecated", "available", or "unknown".
+func classifyStatus(status string) string {
+	switch {
+	case deprecatedStatuses[status]:
+		return classificationDeprecated
+	case status == statusAvailable:
+		return classificationAvailable
+	default:
+		return classificationUnknown
+	}
+}
+
+// exportVersionStatus sets the VersionStatusInfoGauge to 1 for the engine version of an RDSInfo, with its raw catalog
+// status and its classification, so that the statuses the exporter does not know are not silently handled like the
+// available ones. Nothing is exported for versions missing from the catalog.
+func exportVersionStatus(metrics *Metrics, rdsInfo RDSInfo, m engineVersions) {
+	info, ok := m[rdsInfo.Engine][rdsInfo.EngineVersion]
+	if !ok {
+		return
+	}
+	metrics.VersionStatusInfoGauge.With(prometheus.Labels{
+		"engine":         rdsInfo.Engine,
+		"engine_version": rdsInfo.EngineVersion,
+		"status":         info.catalogStatus(),
+		"classification": classifyStatus(info.Status),
+		"account_id":     rdsInfo.Account,
+		"region":         rdsInfo.Region,
+	}).Set(1)
+}
+
+// missingEngines returns the distinct engines used by the given RDSInfos whose catalog is not yet present in the
+// engineVersions map, in order of first appearance. If refetched is not nil, the engines whose catalog lacks the
+// version of one of the RDSInfos, e.g. released since the catalog was fetched, are returned as well, unless they are in
+// refetched, to which they are added, so that a catalog is fetched again at most once per snapshot.
+func missingEngines(rdsInfos []RDSInfo, m engineVersions, refetched map[string]bool) []string {
+	engines := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, rdsInfo := range rdsInfos {
+		if seen[rdsInfo.Engine] {
+			continue
+		}
+		if catalog, ok := m[rdsInfo.Engine]; ok {
+			if _, known := catalog[rdsInfo.EngineVersion]; known || refetched == nil || refetched[rdsInfo.Engine] {
+				continue
+			}
+			refetched[rdsInfo.Engine] = true
+		}
+		seen[rdsInfo.Engine] = true
+		engines = append(engines, rdsInfo.Engine)
+	}
+	return engines
+}
+
+// addMissingEngineVersions fetches the catalogs of the engines used by the given RDSInfos that are not yet present in
+// the engineVersions map, and the ones lacking their version if refetched is not nil, as missingEngines does, and adds
+// them to it. The map is shared by concurrent pages: it is only read and written with the given lock held, which is
+// released while the catalogs are fetched. An engine fetched meanwhile by another page is kept as is, and the
+// deprecations are only observed for the catalogs actually added.
+func addMissingEngineVersions(config *Config, metrics *Metrics, rdsInfos []RDSInfo, m engineVersions,
+	refetched map[string]bool, mu sync.Locker) error {
+	mu.Lock()
+	engines := missingEngines(rdsInfos, m, refetched)
+	stale := make(map[string]bool)
+	for _, engine := range engines {
+		_, stale[engine] = m[engine]
+	}
+	mu.Unlock()
+	if len(engines) == 0 {
+		return nil
+	}
+
+	catalogs, err := getEngineVersions(config, engines)
+	if err != nil {
+		return fmt.Errorf("failed to read RDS Engine versions; %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	added := make(engineVersions, len(catalogs))
+	for engine, catalog := range catalogs {
+		if _, ok := m[engine]; ok && !stale[engine] {
+			continue
+		}
+		m[engine] = catalog
+		added[engine] = catalog
+	}
+	metrics.Deprecations.observeAll(config.Region, added)
+	return nil
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
 
 // validateEngineVersion() takes in an RDSInfo struct that contains information about an RDS engine, and an
-// engineVersions map that contains deprecation status information for all RDS engines and versions.
+// engineVersions map that contains catalog information for all RDS engines and versions.
 //
 // The function first checks if the RDS engine in the RDSInfo struct is present in the engineVersions map. If it is not,
 // the function returns false and an error indicating that the engine is unknown.
 //
 // If the engine is present in the engineVersions map, the function then checks if the version of the RDS engine in the
-// RDSInfo struct is present in the versionDeprecations map for that engine. If it is not, the function returns false
+// RDSInfo struct is present in the versionCatalog map for that engine. If it is not, the function returns false
 // and an error indicating that the version is unknown.
 //
 // If the engine and version are present in the engineVersions map, the function returns a boolean indicating whether
-// the version is deprecated or not, based on the deprecation status value stored in the versionDeprecations map.
+// the version is deprecated or not, based on the status stored in the versionCatalog map. The Custom Engine Versions
+// of RDS Custom engines are listed in the catalog of their engine as well, and are validated the same way.
 //
 // Overall, this function is responsible for validating an RDS engine and version by checking if they are present in the
 // engineVersions map and returning whether the version is deprecated or not.
@@ -142,5 +401,10 @@ func validateEngineVersion(rdsInfo RDSInfo, m engineVersions) (bool, error) {
 	if _, ok := versions[rdsInfo.EngineVersion]; !ok {
 		return false, fmt.Errorf("unknown version: %s; failed to validate RDS Engine version", rdsInfo.EngineVersion)
 	}
-	return !versions[rdsInfo.EngineVersion], nil
+	return !deprecatedStatuses[versions[rdsInfo.EngineVersion].Status], nil
+}
+
+// isRDSCustom returns true if the engine is an RDS Custom engine, whose versions are Custom Engine Versions (CEV).
+func isRDSCustom(engine string) bool {
+	return strings.HasPrefix(engine, customEnginePrefix)
 }
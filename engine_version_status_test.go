@@ -25,7 +25,9 @@ package main
 import (
 	"errors"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -46,8 +48,8 @@ func TestValidateEngineVersion(t *testing.T) {
 				EngineVersion: "5.1.1",
 			},
 			m: engineVersions{
-				"mysql": versionDeprecations{
-					"5.1.1": true,
+				"mysql": versionCatalog{
+					"5.1.1": {Status: "deprecated"},
 				},
 			},
 			want:    false,
@@ -60,8 +62,38 @@ func TestValidateEngineVersion(t *testing.T) {
 				EngineVersion: "5.5.5",
 			},
 			m: engineVersions{
-				"mysql": versionDeprecations{
-					"5.5.5": false,
+				"mysql": versionCatalog{
+					"5.5.5": {Status: "available"},
+				},
+			},
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "custom engine version; inactive",
+			rdsInfo: RDSInfo{
+				Engine:        "custom-oracle-ee",
+				EngineVersion: "19.my_cev1",
+			},
+			m: engineVersions{
+				"custom-oracle-ee": versionCatalog{
+					"19.my_cev1": {Status: "inactive"},
+					"19.my_cev2": {Status: "available"},
+				},
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "custom engine version; available",
+			rdsInfo: RDSInfo{
+				Engine:        "custom-oracle-ee",
+				EngineVersion: "19.my_cev2",
+			},
+			m: engineVersions{
+				"custom-oracle-ee": versionCatalog{
+					"19.my_cev1": {Status: "inactive"},
+					"19.my_cev2": {Status: "available"},
 				},
 			},
 			want:    true,
@@ -84,8 +116,8 @@ func TestValidateEngineVersion(t *testing.T) {
 				EngineVersion: "foo",
 			},
 			m: engineVersions{
-				"mysql": versionDeprecations{
-					"5.5.5": false,
+				"mysql": versionCatalog{
+					"5.5.5": {Status: "available"},
 				},
 			},
 			want:    false,
@@ -116,6 +148,7 @@ func TestGetEngineVersions(t *testing.T) {
 	tests := []struct {
 		desc    string
 		config  *Config
+		engines []string
 		want    engineVersions
 		wantErr error
 	}{
@@ -129,10 +162,16 @@ func TestGetEngineVersions(t *testing.T) {
 								{
 									Engine:        Ptr("engine1"),
 									EngineVersion: Ptr("1.0"),
+									Status:        Ptr("deprecated"),
+									ValidUpgradeTarget: []*rds.UpgradeTarget{
+										{EngineVersion: Ptr("1.1"), IsMajorVersionUpgrade: Ptr(false)},
+										{EngineVersion: Ptr("2.0"), IsMajorVersionUpgrade: Ptr(true)},
+									},
 								},
 								{
 									Engine:        Ptr("engine2"),
 									EngineVersion: Ptr("2.0"),
+									Status:        Ptr("available"),
 								},
 							},
 							Marker: Ptr("yolo"),
@@ -142,6 +181,7 @@ func TestGetEngineVersions(t *testing.T) {
 								{
 									Engine:        Ptr("engine3"),
 									EngineVersion: Ptr("3.0"),
+									Status:        Ptr("preview"),
 								},
 							},
 							Marker: nil,
@@ -149,15 +189,16 @@ func TestGetEngineVersions(t *testing.T) {
 					},
 				},
 			},
+			engines: []string{"engine1", "engine3"},
 			want: engineVersions{
 				"engine1": {
-					"1.0": true,
-				},
-				"engine2": {
-					"2.0": true,
+					"1.0": {Status: "deprecated", UpgradeTargets: []upgradeTarget{
+						{EngineVersion: "1.1"},
+						{EngineVersion: "2.0", Major: true},
+					}},
 				},
 				"engine3": {
-					"3.0": true,
+					"3.0": {Status: "preview"},
 				},
 			},
 			wantErr: nil,
@@ -169,8 +210,9 @@ func TestGetEngineVersions(t *testing.T) {
 					err: errors.New("failed to describe db engine versions"),
 				},
 			},
+			engines: []string{"engine1"},
 			want:    nil,
-			wantErr: errors.New("error while querying rds deprecated engine version; failed to describe db engine versions; failed to describe db engine versions"),
+			wantErr: errors.New("error while querying rds engine versions for engine engine1; failed to describe db engine versions; failed to describe db engine versions"),
 		},
 	}
 
@@ -178,7 +220,7 @@ func TestGetEngineVersions(t *testing.T) {
 		t.Run(tt.desc, func(t *testing.T) {
 			t.Logf("testing: %s", tt.desc)
 
-			got, err := getEngineVersions(tt.config)
+			got, err := getEngineVersions(tt.config, tt.engines)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -189,3 +231,172 @@ func TestGetEngineVersions(t *testing.T) {
 		})
 	}
 }
+
+// TestMissingEngines tests the missingEngines function.
+func TestMissingEngines(t *testing.T) {
+	rdsInfos := []RDSInfo{
+		{ClusterIdentifier: "a", Engine: "mysql"},
+		{ClusterIdentifier: "b", Engine: "postgres"},
+		{ClusterIdentifier: "c", Engine: "mysql"},
+		{ClusterIdentifier: "d", Engine: "mariadb"},
+	}
+	m := engineVersions{"postgres": versionCatalog{}}
+
+	assert.Equal(t, []string{"mysql", "mariadb"}, missingEngines(rdsInfos, m, nil))
+	assert.Equal(t, []string{}, missingEngines(nil, m, nil))
+
+	refetched := make(map[string]bool)
+	assert.Equal(t, []string{"mysql", "postgres", "mariadb"}, missingEngines(rdsInfos, m, refetched))
+	assert.Equal(t, map[string]bool{"postgres": true}, refetched)
+	assert.Equal(t, []string{"mysql", "mariadb"}, missingEngines(rdsInfos, m, refetched))
+}
+
+// TestSnapshotRefetchesCatalog tests that the catalog of an engine is fetched again when it lacks the version of a
+// resource, e.g. released since it was fetched, rather than failing the snapshot until it is refreshed.
+func TestSnapshotRefetchesCatalog(t *testing.T) {
+	config := &Config{Region: "eu-west-1", Concurrency: 1, RDS: &MockRDSAPI{
+		clustersOutput: []*rds.DescribeDBClustersOutput{{}},
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{
+			DBInstances: []*rds.DBInstance{
+				{DBInstanceIdentifier: Ptr("orders"), Engine: Ptr("postgres"), EngineVersion: Ptr("15.4")},
+			},
+		}},
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+			DBEngineVersions: []*rds.DBEngineVersion{
+				{Engine: Ptr("postgres"), EngineVersion: Ptr("15.2"), Status: Ptr("available")},
+				{Engine: Ptr("postgres"), EngineVersion: Ptr("15.4"), Status: Ptr("available")},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+	m := engineVersions{"postgres": {"15.2": {Status: "available"}}}
+	assert.NoError(t, snapshot(config, metrics, m))
+
+	assert.Equal(t, engineVersions{"postgres": {"15.2": {Status: "available"}, "15.4": {Status: "available"}}}, m)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AvailableGauge.WithLabelValues("orders", "postgres", "15.4",
+		"15", "4", "", "", "", "false", "false", "", "default", "eu-west-1")))
+}
+
+// TestSnapshotSkipsUnknownVersions tests that a resource whose version is missing from the catalog even once fetched
+// again is counted and skipped, and that the rest of its page is exported.
+func TestSnapshotSkipsUnknownVersions(t *testing.T) {
+	config := &Config{Region: "eu-west-1", Concurrency: 1, RDS: &MockRDSAPI{
+		clustersOutput: []*rds.DescribeDBClustersOutput{{}},
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{
+			DBInstances: []*rds.DBInstance{
+				{DBInstanceIdentifier: Ptr("legacy"), Engine: Ptr("postgres"), EngineVersion: Ptr("9.6.1")},
+				{DBInstanceIdentifier: Ptr("orders"), Engine: Ptr("postgres"), EngineVersion: Ptr("15.4")},
+			},
+		}},
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+			DBEngineVersions: []*rds.DBEngineVersion{
+				{Engine: Ptr("postgres"), EngineVersion: Ptr("15.4"), Status: Ptr("available")},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+	assert.NoError(t, snapshot(config, metrics, make(engineVersions)))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SkippedResourcesCounter.WithLabelValues("postgres", "default",
+		"eu-west-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AvailableGauge.WithLabelValues("orders", "postgres", "15.4",
+		"15", "4", "", "", "", "false", "false", "", "default", "eu-west-1")))
+	assert.Len(t, metrics.Inventory.list(), 1)
+}
+
+// TestSnapshotCatalogInfo tests that snapshot fetches and exports the catalogs of every engine when CatalogInfo is
+// enabled, whether the engines are in use or not.
+func TestSnapshotCatalogInfo(t *testing.T) {
+	config := &Config{Region: "eu-west-1", Concurrency: 1, CatalogInfo: true, RDS: &MockRDSAPI{
+		clustersOutput:  []*rds.DescribeDBClustersOutput{{}},
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{}},
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+			DBEngineVersions: []*rds.DBEngineVersion{
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.41"), Status: Ptr("deprecated")},
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.32"), Status: Ptr("available")},
+				{
+					Engine: Ptr("postgres"), EngineVersion: Ptr("15.2"), Status: Ptr("available"),
+					SupportsReadReplica: Ptr(true), SupportsLogExportsToCloudwatchLogs: Ptr(true),
+					SupportedEngineModes: []*string{Ptr("provisioned"), Ptr("multimaster")},
+				},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+	m := make(engineVersions)
+	assert.NoError(t, snapshot(config, metrics, m))
+
+	assert.Equal(t, engineVersions{
+		"mysql": {"5.7.41": {Status: "deprecated"}, "8.0.32": {Status: "available"}},
+		"postgres": {"15.2": {
+			Status: "available", SupportsReadReplica: true, SupportsLogExports: true,
+			EngineModes: []string{"multimaster", "provisioned"},
+		}},
+	}, m)
+	want := `# HELP aws_custom_rds_engine_version_info Versions of the engine catalogs, with their status, whether they are in use or not
+# TYPE aws_custom_rds_engine_version_info gauge
+aws_custom_rds_engine_version_info{account_id="default",engine="mysql",engine_version="5.7.41",region="eu-west-1",status="deprecated"} 1
+aws_custom_rds_engine_version_info{account_id="default",engine="mysql",engine_version="8.0.32",region="eu-west-1",status="available"} 1
+aws_custom_rds_engine_version_info{account_id="default",engine="postgres",engine_version="15.2",region="eu-west-1",status="available"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.EngineVersionInfoGauge, strings.NewReader(want)))
+
+	want = `# HELP aws_custom_rds_engine_version_capabilities_info Capabilities of the versions of the engine catalogs, whether they are in use or not
+# TYPE aws_custom_rds_engine_version_capabilities_info gauge
+aws_custom_rds_engine_version_capabilities_info{account_id="default",engine="mysql",engine_modes="",engine_version="5.7.41",region="eu-west-1",supports_log_exports="false",supports_read_replica="false"} 1
+aws_custom_rds_engine_version_capabilities_info{account_id="default",engine="mysql",engine_modes="",engine_version="8.0.32",region="eu-west-1",supports_log_exports="false",supports_read_replica="false"} 1
+aws_custom_rds_engine_version_capabilities_info{account_id="default",engine="postgres",engine_modes="multimaster,provisioned",engine_version="15.2",region="eu-west-1",supports_log_exports="true",supports_read_replica="true"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.EngineCapabilitiesGauge, strings.NewReader(want)))
+}
+
+// TestExportUpgradeTargets tests that exportUpgradeTargets counts the minor and major upgrade targets of the engine
+// version of an RDSInfo, and exports nothing for versions missing from the catalog.
+func TestExportUpgradeTargets(t *testing.T) {
+	m := engineVersions{"postgres": {
+		"11.19": {Status: "deprecated", UpgradeTargets: []upgradeTarget{
+			{EngineVersion: "12.14", Major: true}, {EngineVersion: "13.10", Major: true},
+		}},
+		"13.7": {Status: "available", UpgradeTargets: []upgradeTarget{
+			{EngineVersion: "13.10"}, {EngineVersion: "14.7", Major: true},
+		}},
+	}}
+	metrics := NewMetrics()
+	for _, version := range []string{"11.19", "13.7", "9.6.24"} {
+		exportUpgradeTargets(metrics, RDSInfo{Engine: "postgres", EngineVersion: version, Region: "eu-west-1"}, m)
+	}
+
+	want := `# HELP aws_custom_rds_upgrade_targets Number of valid minor or major upgrade targets of the engine versions in use
+# TYPE aws_custom_rds_upgrade_targets gauge
+aws_custom_rds_upgrade_targets{account_id="",engine="postgres",engine_version="11.19",region="eu-west-1",upgrade="major"} 2
+aws_custom_rds_upgrade_targets{account_id="",engine="postgres",engine_version="11.19",region="eu-west-1",upgrade="minor"} 0
+aws_custom_rds_upgrade_targets{account_id="",engine="postgres",engine_version="13.7",region="eu-west-1",upgrade="major"} 1
+aws_custom_rds_upgrade_targets{account_id="",engine="postgres",engine_version="13.7",region="eu-west-1",upgrade="minor"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.UpgradeTargetsGauge, strings.NewReader(want)))
+}
+
+// TestExportVersionStatus tests that exportVersionStatus exports the raw catalog status of the engine version of an
+// RDSInfo with its classification, the catalog status of the banned versions, and nothing for versions missing from the
+// catalog.
+func TestExportVersionStatus(t *testing.T) {
+	m := engineVersions{"postgres": {
+		"11.19": {Status: "deprecated"},
+		"12.15": {Status: statusBanned, CatalogStatus: "available"},
+		"13.7":  {Status: "available"},
+		"16.0":  {Status: "preview"},
+	}}
+	metrics := NewMetrics()
+	for _, version := range []string{"11.19", "12.15", "13.7", "16.0", "9.6.24"} {
+		exportVersionStatus(metrics, RDSInfo{Engine: "postgres", EngineVersion: version, Region: "eu-west-1"}, m)
+	}
+
+	want := `# HELP aws_custom_rds_version_status_info Raw catalog status of the engine versions in use, and whether it is classified as available, deprecated or unknown
+# TYPE aws_custom_rds_version_status_info gauge
+aws_custom_rds_version_status_info{account_id="",classification="available",engine="postgres",engine_version="13.7",region="eu-west-1",status="available"} 1
+aws_custom_rds_version_status_info{account_id="",classification="deprecated",engine="postgres",engine_version="11.19",region="eu-west-1",status="deprecated"} 1
+aws_custom_rds_version_status_info{account_id="",classification="deprecated",engine="postgres",engine_version="12.15",region="eu-west-1",status="available"} 1
+aws_custom_rds_version_status_info{account_id="",classification="unknown",engine="postgres",engine_version="16.0",region="eu-west-1",status="preview"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.VersionStatusInfoGauge, strings.NewReader(want)))
+}
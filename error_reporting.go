@@ -0,0 +1,236 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBreadcrumbs is the number of most recent AWS API calls sent along with each error report.
+	maxBreadcrumbs = 30
+
+	errorReportTimeout = 5 * time.Second
+)
+
+// sentryDSN is a parsed Sentry DSN, e.g. "https://<public_key>@o0.ingest.sentry.io/<project_id>".
+type sentryDSN struct {
+	// storeURL is the URL of the store endpoint of the project.
+	storeURL  string
+	publicKey string
+	secretKey string
+}
+
+// parseSentryDSN parses a Sentry DSN. An error is returned if the DSN has no public key or no project ID.
+func parseSentryDSN(dsn string) (*sentryDSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Sentry DSN; %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.User == nil || u.User.Username() == "" {
+		return nil, errors.New("Sentry DSN should look like https://<public_key>@<host>/<project_id>")
+	}
+	i := strings.LastIndex(u.Path, "/")
+	path, projectID := u.Path[:i+1], u.Path[i+1:]
+	if projectID == "" {
+		return nil, errors.New("Sentry DSN should end with a project ID")
+	}
+	secretKey, _ := u.User.Password()
+	return &sentryDSN{
+		storeURL:  fmt.Sprintf("%s://%s%sapi/%s/store/", u.Scheme, u.Host, path, projectID),
+		publicKey: u.User.Username(),
+		secretKey: secretKey,
+	}, nil
+}
+
+// sentryEvent is the subset of the Sentry event payload sent by the errorReporter.
+type sentryEvent struct {
+	EventID     string                        `json:"event_id"`
+	Timestamp   string                        `json:"timestamp"`
+	Level       string                        `json:"level"`
+	Platform    string                        `json:"platform"`
+	Release     string                        `json:"release"`
+	Logger      string                        `json:"logger"`
+	ServerName  string                        `json:"server_name,omitempty"`
+	Exception   []sentryException             `json:"exception"`
+	Tags        map[string]string             `json:"tags,omitempty"`
+	Extra       map[string]string             `json:"extra,omitempty"`
+	Breadcrumbs map[string][]sentryBreadcrumb `json:"breadcrumbs,omitempty"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryBreadcrumb struct {
+	Timestamp string            `json:"timestamp"`
+	Type      string            `json:"type"`
+	Category  string            `json:"category"`
+	Message   string            `json:"message"`
+	Level     string            `json:"level"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+// errorReporter reports snapshot failures and panics to Sentry, so that crashes of deployments whose logs we cannot
+// access are not missed. Each report carries the most recent AWS API calls as breadcrumbs, and the code and request ID
+// of the AWS API error that caused the failure, if any. A nil errorReporter reports nothing.
+type errorReporter struct {
+	dsn    *sentryDSN
+	client *http.Client
+
+	mu          sync.Mutex
+	breadcrumbs []sentryBreadcrumb
+}
+
+// newErrorReporter returns an errorReporter sending the reports to the project of the given DSN, or nil if dsn is nil.
+func newErrorReporter(dsn *sentryDSN) *errorReporter {
+	if dsn == nil {
+		return nil
+	}
+	return &errorReporter{dsn: dsn, client: &http.Client{Timeout: errorReportTimeout}}
+}
+
+// recordAWSCall records a completed AWS API call as a breadcrumb. It is meant to be pushed on the Complete handlers of
+// the AWS session.
+func (r *errorReporter) recordAWSCall(req *request.Request) {
+	if r == nil {
+		return
+	}
+	breadcrumb := sentryBreadcrumb{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Type:      "http",
+		Category:  "aws",
+		Message:   fmt.Sprintf("%s.%s", req.ClientInfo.ServiceName, req.Operation.Name),
+		Level:     "info",
+		Data:      map[string]string{"request_id": req.RequestID},
+	}
+	if req.HTTPResponse != nil {
+		breadcrumb.Data["status_code"] = fmt.Sprint(req.HTTPResponse.StatusCode)
+	}
+	if req.Error != nil {
+		breadcrumb.Level = "error"
+		breadcrumb.Data["error"] = req.Error.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breadcrumbs = append(r.breadcrumbs, breadcrumb)
+	if len(r.breadcrumbs) > maxBreadcrumbs {
+		r.breadcrumbs = r.breadcrumbs[len(r.breadcrumbs)-maxBreadcrumbs:]
+	}
+}
+
+// report sends err to Sentry. Panics are reported as fatal, with their stack. Failing to report an error is only logged.
+func (r *errorReporter) report(err error) {
+	if r == nil {
+		return
+	}
+	if sendErr := r.send(r.newEvent(err)); sendErr != nil {
+		log.Printf("failed to report error to Sentry; %v", sendErr)
+	}
+}
+
+// newEvent returns the Sentry event reporting err.
+func (r *errorReporter) newEvent(err error) *sentryEvent {
+	eventID := make([]byte, 16)
+	_, _ = rand.Read(eventID)
+	hostname, _ := os.Hostname()
+	event := &sentryEvent{
+		EventID:    hex.EncodeToString(eventID),
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Level:      "error",
+		Platform:   "go",
+		Release:    version,
+		Logger:     "snapshot",
+		ServerName: hostname,
+		Exception:  []sentryException{{Type: "snapshot error", Value: err.Error()}},
+		Tags:       map[string]string{},
+		Extra:      map[string]string{},
+	}
+
+	var p *panicError
+	if errors.As(err, &p) {
+		event.Level = "fatal"
+		event.Exception[0].Type = "panic"
+		event.Extra["stack"] = string(p.stack)
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		event.Tags["aws_error_code"] = awsErr.Code()
+	}
+	var requestFailure awserr.RequestFailure
+	if errors.As(err, &requestFailure) {
+		event.Tags["aws_request_id"] = requestFailure.RequestID()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.breadcrumbs) > 0 {
+		event.Breadcrumbs = map[string][]sentryBreadcrumb{"values": append([]sentryBreadcrumb(nil), r.breadcrumbs...)}
+	}
+	return event
+}
+
+// send posts the event to the store endpoint of the Sentry project.
+func (r *errorReporter) send(event *sentryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.dsn.storeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s/%s, sentry_key=%s", exporterName, version,
+		r.dsn.publicKey)
+	if r.dsn.secretKey != "" {
+		auth += ", sentry_secret=" + r.dsn.secretKey
+	}
+	req.Header.Set("X-Sentry-Auth", auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
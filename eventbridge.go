@@ -0,0 +1,159 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"time"
+)
+
+const (
+	// eventDetailType is the detail type of the events published on EventBridge.
+	eventDetailType = "RDS Engine Version Status Change"
+
+	// maxPutEventsEntries is the maximum number of entries of a PutEvents call.
+	maxPutEventsEntries = 10
+)
+
+// statusChangeEvent is the detail of the event published on EventBridge for a transition. The previous fields are
+// empty for the resources that appeared, and the current ones for the resources that disappeared.
+type statusChangeEvent struct {
+	Account               string   `json:"account"`
+	Region                string   `json:"region"`
+	Identifier            string   `json:"identifier"`
+	Engine                string   `json:"engine"`
+	PreviousEngineVersion string   `json:"previous_engine_version"`
+	EngineVersion         string   `json:"engine_version"`
+	PreviousStatus        string   `json:"previous_status"`
+	Status                string   `json:"status"`
+	Deprecated            bool     `json:"deprecated"`
+	UpgradeTargets        []string `json:"upgrade_targets"`
+}
+
+// timedStatusChange is the detail of the event of a transition along with the time of its snapshot, for the outputs
+// whose messages have no envelope, e.g. the Kafka records and the NATS messages.
+type timedStatusChange struct {
+	Time string `json:"time"`
+	statusChangeEvent
+}
+
+// newStatusChangeEvent returns the detail of the event of a transition. Its upgrade targets are never nil, so that
+// they are an empty array in JSON.
+func newStatusChangeEvent(t transition) statusChangeEvent {
+	event := statusChangeEvent{UpgradeTargets: []string{}}
+	if t.Previous != nil {
+		event.Account, event.Region, event.Identifier, event.Engine =
+			t.Previous.Account, t.Previous.Region, t.Previous.ClusterIdentifier, t.Previous.Engine
+		event.PreviousEngineVersion, event.PreviousStatus = t.Previous.EngineVersion, t.Previous.Status
+	}
+	if t.Current != nil {
+		event.Account, event.Region, event.Identifier, event.Engine =
+			t.Current.Account, t.Current.Region, t.Current.ClusterIdentifier, t.Current.Engine
+		event.EngineVersion, event.Status, event.Deprecated = t.Current.EngineVersion, t.Current.Status, t.Current.Deprecated
+		for _, target := range t.Current.UpgradeTargets {
+			event.UpgradeTargets = append(event.UpgradeTargets, target.EngineVersion)
+		}
+	}
+	return event
+}
+
+// eventPublisher publishes an event on an EventBridge bus for each transition between successive snapshots, so that
+// AWS-native automation, e.g. Step Functions upgrade workflows, can react to them.
+type eventPublisher struct {
+	client  eventbridgeiface.EventBridgeAPI
+	bus     string
+	tracker transitionTracker
+}
+
+// newEventPublisher returns the eventPublisher configured by the options, or nil if no EventBridge bus is configured.
+func newEventPublisher(options *Options) *eventPublisher {
+	if options.EventBridgeBus == "" {
+		return nil
+	}
+	return &eventPublisher{
+		client: eventbridge.New(newSession(options, nil)),
+		bus:    options.EventBridgeBus,
+	}
+}
+
+// publish publishes an event for each transition between the inventory of the previous call and the given one, in
+// batches of at most 10 events. The first call publishes nothing. The transitions whose events could not be put are
+// published again by the next call, unless the resources changed since. Nothing is done if p is nil.
+func (p *eventPublisher) publish(inv *inventory, now time.Time) error {
+	if p == nil {
+		return nil
+	}
+	transitions := p.tracker.update(inv.list())
+	var entries []*eventbridge.PutEventsRequestEntry
+	for _, t := range transitions {
+		detail, err := json.Marshal(newStatusChangeEvent(t))
+		if err != nil {
+			p.tracker.revert(transitions)
+			return fmt.Errorf("failed to marshal event; %w", err)
+		}
+		entries = append(entries, &eventbridge.PutEventsRequestEntry{
+			EventBusName: Ptr(p.bus),
+			Source:       Ptr(exporterName),
+			DetailType:   Ptr(eventDetailType),
+			Detail:       Ptr(string(detail)),
+			Time:         Ptr(now),
+		})
+	}
+
+	var failed []transition
+	total := len(entries)
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > maxPutEventsEntries {
+			n = maxPutEventsEntries
+		}
+		output, err := p.client.PutEvents(&eventbridge.PutEventsInput{Entries: entries[:n]})
+		if err != nil {
+			p.tracker.revert(append(failed, transitions...))
+			return fmt.Errorf("failed to put events; %w", err)
+		}
+		if aws.Int64Value(output.FailedEntryCount) > 0 {
+			// the result entries are in the order of the request entries
+			batchFailed := 0
+			for i, result := range output.Entries {
+				if i < n && result.ErrorCode != nil {
+					failed = append(failed, transitions[i])
+					batchFailed++
+				}
+			}
+			if batchFailed == 0 {
+				failed = append(failed, transitions[:n]...)
+			}
+		}
+		entries, transitions = entries[n:], transitions[n:]
+	}
+	if len(failed) > 0 {
+		p.tracker.revert(failed)
+		return fmt.Errorf("failed to put %d of %d events, published again at the next snapshot", len(failed), total)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type MockEventBridgeAPI struct {
+	eventbridgeiface.EventBridgeAPI
+	inputs []*eventbridge.PutEventsInput
+	failed int64
+	err    error
+}
+
+// PutEvents fails the last failed entries of every call.
+func (m *MockEventBridgeAPI) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	m.inputs = append(m.inputs, input)
+	output := &eventbridge.PutEventsOutput{FailedEntryCount: Ptr(m.failed)}
+	for i := range input.Entries {
+		entry := &eventbridge.PutEventsResultEntry{EventId: Ptr(fmt.Sprint(i))}
+		if i >= len(input.Entries)-int(m.failed) {
+			entry = &eventbridge.PutEventsResultEntry{ErrorCode: Ptr("InternalFailure")}
+		}
+		output.Entries = append(output.Entries, entry)
+	}
+	return output, m.err
+}
+
+// TestEventPublisherPublish tests the publish method of eventPublisher.
+func TestEventPublisherPublish(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	available := func(n int) *inventory {
+		inv := &inventory{}
+		for i := 0; i < n; i++ {
+			inv.add(inventoryItem{
+				RDSInfo: RDSInfo{ClusterIdentifier: fmt.Sprintf("db-%02d", i), Engine: "postgres", EngineVersion: "11.4",
+					Region: "eu-west-1", Account: "111111111111"},
+				Status: "available",
+			})
+		}
+		return inv
+	}
+	deprecated := func(n int) *inventory {
+		inv := &inventory{}
+		for _, item := range available(n).list() {
+			item.Status, item.Deprecated = "deprecated", true
+			item.UpgradeTargets = []upgradeTarget{{EngineVersion: "11.22"}, {EngineVersion: "15.2", Major: true}}
+			inv.add(item)
+		}
+		return inv
+	}
+
+	tests := []struct {
+		name        string
+		client      *MockEventBridgeAPI
+		resources   int
+		wantBatches []int
+		wantErr     string
+		wantRetried int
+	}{
+		{name: "batches", client: &MockEventBridgeAPI{}, resources: 12, wantBatches: []int{10, 2}},
+		{
+			name:        "failed entries",
+			client:      &MockEventBridgeAPI{failed: 1},
+			resources:   3,
+			wantBatches: []int{3},
+			wantErr:     "failed to put 1 of 3 events, published again at the next snapshot",
+			wantRetried: 1,
+		},
+		{
+			name:        "failure",
+			client:      &MockEventBridgeAPI{err: errors.New("AccessDeniedException")},
+			resources:   3,
+			wantBatches: []int{3},
+			wantErr:     "failed to put events; AccessDeniedException",
+			wantRetried: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &eventPublisher{client: tt.client, bus: "rds"}
+			assert.NoError(t, p.publish(available(tt.resources), now))
+			assert.Empty(t, tt.client.inputs, "first snapshot")
+
+			err := p.publish(deprecated(tt.resources), now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			var batches []int
+			for _, input := range tt.client.inputs {
+				batches = append(batches, len(input.Entries))
+			}
+			assert.Equal(t, tt.wantBatches, batches)
+
+			entry := tt.client.inputs[0].Entries[0]
+			assert.Equal(t, "rds", *entry.EventBusName)
+			assert.Equal(t, exporterName, *entry.Source)
+			assert.Equal(t, eventDetailType, *entry.DetailType)
+			assert.Equal(t, now, *entry.Time)
+			assert.JSONEq(t, `{"account": "111111111111", "region": "eu-west-1", "identifier": "db-00",
+				"engine": "postgres", "previous_engine_version": "11.4", "engine_version": "11.4",
+				"previous_status": "available", "status": "deprecated", "deprecated": true,
+				"upgrade_targets": ["11.22", "15.2"]}`, *entry.Detail)
+
+			// the events that could not be put are published again with the next snapshot
+			tt.client.inputs, tt.client.failed, tt.client.err = nil, 0, nil
+			assert.NoError(t, p.publish(deprecated(tt.resources), now))
+			if tt.wantRetried == 0 {
+				assert.Empty(t, tt.client.inputs)
+			} else {
+				assert.Len(t, tt.client.inputs, 1)
+				assert.Len(t, tt.client.inputs[0].Entries, tt.wantRetried)
+			}
+		})
+	}
+
+	// nil publishers publish nothing
+	assert.NoError(t, (*eventPublisher)(nil).publish(available(1), now))
+}
@@ -0,0 +1,261 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// explanation is why an RDS resource is flagged, or not: the catalog status of its engine version and the recommended
+// upgrade, the policies of the exporter applying to it, its pending maintenance and the end of the standard support of
+// its version.
+type explanation struct {
+	item inventoryItem
+
+	// filtered holds why the resource is not exported, e.g. "tag filters", if it is not.
+	filtered []string
+
+	// acknowledgement is the acknowledgement selecting the resource, if any.
+	acknowledgement *acknowledgement
+
+	// owner is the owner the resource is mapped to, if any.
+	owner *resourceOwner
+
+	// pending is the date from which a pending engine upgrade of the resource, or of its cluster, is applied
+	// automatically, if any.
+	pending *time.Time
+
+	// supportEnd is the end of the standard support of the engine version, if the calendar has it.
+	supportEnd *time.Time
+
+	// recommended is the upgrade target recommended by the upgrade preferences, if any.
+	recommended *upgradeTarget
+}
+
+// describeResourceType describes the resource type of an RDS resource, e.g. "cluster" or "instance".
+func describeResourceType(rdsInfo RDSInfo) string {
+	if rdsInfo.ResourceType == resourceTypeCluster {
+		return "cluster"
+	}
+	return "instance"
+}
+
+// write writes the explanation to w, computed at the given time.
+func (e explanation) write(w io.Writer, now time.Time) error {
+	item := e.item
+	lines := []string{
+		fmt.Sprintf("%s %s in account %s, region %s", describeResourceType(item.RDSInfo), item.ClusterIdentifier,
+			item.Account, item.Region),
+		fmt.Sprintf("  engine: %s %s", item.Engine, item.EngineVersion),
+		fmt.Sprintf("  catalog status: %s", item.Status),
+	}
+
+	targets := make([]string, 0, len(item.UpgradeTargets))
+	for _, target := range item.UpgradeTargets {
+		if target.Major {
+			targets = append(targets, target.EngineVersion+" (major)")
+		} else {
+			targets = append(targets, target.EngineVersion)
+		}
+	}
+	if len(targets) == 0 {
+		targets = append(targets, "none")
+	}
+	lines = append(lines, "  upgrade targets: "+strings.Join(targets, ", "))
+	switch {
+	case e.recommended == nil:
+		lines = append(lines, "  recommended upgrade: none")
+	case e.recommended.Major:
+		lines = append(lines, "  recommended upgrade: "+e.recommended.EngineVersion+" (major)")
+	default:
+		lines = append(lines, "  recommended upgrade: "+e.recommended.EngineVersion)
+	}
+
+	switch {
+	case e.supportEnd == nil:
+		lines = append(lines, "  standard support end: unknown")
+	case now.Before(*e.supportEnd):
+		lines = append(lines, fmt.Sprintf("  standard support end: %s, in %d days",
+			e.supportEnd.Format("2006-01-02"), int(e.supportEnd.Sub(now).Hours()/24)))
+	default:
+		lines = append(lines, fmt.Sprintf("  standard support end: %s, over, in Extended Support if not upgraded",
+			e.supportEnd.Format("2006-01-02")))
+	}
+
+	if e.pending == nil {
+		lines = append(lines, "  pending maintenance: none")
+	} else {
+		lines = append(lines, "  pending maintenance: engine upgrade applied automatically after "+
+			e.pending.Format("2006-01-02"))
+	}
+
+	if len(e.filtered) == 0 {
+		lines = append(lines, "  filters: exported")
+	} else {
+		lines = append(lines, "  filters: not exported, filtered out by "+strings.Join(e.filtered, ", "))
+	}
+	switch {
+	case e.acknowledgement == nil:
+		lines = append(lines, "  acknowledgement: none")
+	case !now.Before(e.acknowledgement.Expires):
+		lines = append(lines, fmt.Sprintf("  acknowledgement: expired on %s, %q",
+			e.acknowledgement.Expires.Format("2006-01-02"), e.acknowledgement.Reason))
+	default:
+		lines = append(lines, fmt.Sprintf("  acknowledgement: until %s, %q",
+			e.acknowledgement.Expires.Format("2006-01-02"), e.acknowledgement.Reason))
+	}
+	if e.owner == nil {
+		lines = append(lines, "  owner: none")
+	} else {
+		lines = append(lines, fmt.Sprintf("  owner: team %q, owner %q, slack channel %q", e.owner.Team, e.owner.Owner,
+			e.owner.SlackChannel))
+	}
+
+	switch {
+	case len(e.filtered) > 0:
+		lines = append(lines, "  verdict: not flagged, as it is not exported")
+	case !item.Deprecated:
+		lines = append(lines, "  verdict: not flagged, its engine version is available")
+	case e.acknowledgement != nil && now.Before(e.acknowledgement.Expires):
+		lines = append(lines, "  verdict: acknowledged, its engine version is deprecated")
+	default:
+		lines = append(lines, "  verdict: flagged, its engine version is deprecated")
+	}
+
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// explainScope explains the RDS clusters and instances with the given identifier in the account and region of the
+// config. They are fetched alone, with the identifier as API filter, and with neither the tag filters nor the stopped
+// exclusion, which are evaluated by the explanation instead. The optional collectors are disabled.
+func explainScope(config *Config, options *Options, identifier string, now time.Time) ([]explanation, error) {
+	scoped := *config
+	scoped.DiscoveryBackend = discoveryBackendDescribe
+	scoped.ClusterFilters = []*rds.Filter{
+		{Name: aws.String("db-cluster-id"), Values: aws.StringSlice([]string{identifier})},
+	}
+	scoped.InstanceFilters = []*rds.Filter{
+		{Name: aws.String("db-instance-id"), Values: aws.StringSlice([]string{identifier})},
+	}
+	scoped.TagFilters, scoped.ExcludeStopped = nil, false
+	scoped.GlobalClusters, scoped.DBSnapshots, scoped.CatalogInfo = false, false, false
+	scoped.ForcedUpgrades, scoped.Reservations, scoped.HealthEvents = false, false, false
+
+	metrics, m := NewMetrics(), make(engineVersions)
+	if err := snapshot(&scoped, metrics, m); err != nil {
+		return nil, err
+	}
+	items := metrics.Inventory.list()
+	if len(items) == 0 {
+		return nil, nil
+	}
+	pending, err := getPendingUpgrades(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RDS pending maintenance actions; %w", err)
+	}
+
+	explanations := make([]explanation, 0, len(items))
+	for _, item := range items {
+		e := explanation{item: item}
+		if len(filterRDSInfos([]RDSInfo{item.RDSInfo}, config.TagFilters)) == 0 {
+			e.filtered = append(e.filtered, filterReasonTags)
+		}
+		if config.ExcludeStopped && item.RDSInfo.Status == statusStopped {
+			e.filtered = append(e.filtered, filterReasonStopped)
+		}
+		for i := range options.Acknowledgements {
+			if options.Acknowledgements[i].matches(item.RDSInfo) {
+				e.acknowledgement = &options.Acknowledgements[i]
+				break
+			}
+		}
+		if owner, ok := config.Owners.resolve(item.RDSInfo); ok {
+			e.owner = &owner
+		}
+		for _, key := range pendingUpgradeKeys(item.RDSInfo) {
+			if date, ok := pending[key]; ok && (e.pending == nil || date.Before(*e.pending)) {
+				date := date
+				e.pending = &date
+			}
+		}
+		if end, ok := config.calendar().standardSupportEnd(item.Engine, item.EngineVersion); ok {
+			e.supportEnd = &end
+		}
+		info := m[item.Engine][item.EngineVersion]
+		if target, ok := recommendUpgrade(info, m[item.Engine], newUpgradePreferences(options)); ok {
+			e.recommended = &target
+		}
+		explanations = append(explanations, e)
+	}
+	return explanations, nil
+}
+
+// runExplain is the "explain" subcommand. It fetches the RDS clusters and instances with the given identifier in the
+// accounts and regions configured by the args, the environment variables and the configuration file, like the
+// exporter, and writes to w why each of them is flagged, or not.
+func runExplain(args []string, w io.Writer) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: %s explain <identifier> [flags]", exporterName)
+	}
+	identifier := args[0]
+	options, err := loadCommandOptions("explain", args[1:], os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	scopes, err := NewScopes(options)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	found := false
+	for _, config := range scopes.configs() {
+		explanations, err := explainScope(config, options, identifier, now)
+		if err != nil {
+			return fmt.Errorf("failed to explain %q in %s; %w", identifier, describeScope(config), err)
+		}
+		for _, e := range explanations {
+			if found {
+				if _, err := fmt.Fprintln(w); err != nil {
+					return err
+				}
+			}
+			found = true
+			if err := e.write(w, now); err != nil {
+				return err
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("no RDS cluster nor instance %q found", identifier)
+	}
+	return nil
+}
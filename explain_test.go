@@ -0,0 +1,121 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestExplanationWrite tests that an explanation tells why an RDS resource is flagged, or not.
+func TestExplanationWrite(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	supportEnd := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	pending := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	item := inventoryItem{
+		RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4", Region: "eu-west-1",
+			Account: "111111111111", ResourceType: resourceTypeInstance},
+		Status:         "deprecated",
+		Deprecated:     true,
+		UpgradeTargets: []upgradeTarget{{EngineVersion: "11.22"}, {EngineVersion: "15.2", Major: true}},
+	}
+	tests := []struct {
+		name        string
+		explanation explanation
+		want        string
+	}{
+		{
+			name: "flagged",
+			explanation: explanation{item: item, recommended: &item.UpgradeTargets[0], pending: &pending,
+				supportEnd: &supportEnd, owner: &resourceOwner{Team: "checkout", Owner: "jane", SlackChannel: "#checkout"}},
+			want: "instance orders in account 111111111111, region eu-west-1\n" +
+				"  engine: postgres 11.4\n" +
+				"  catalog status: deprecated\n" +
+				"  upgrade targets: 11.22, 15.2 (major)\n" +
+				"  recommended upgrade: 11.22\n" +
+				"  standard support end: 2024-02-29, in 59 days\n" +
+				"  pending maintenance: engine upgrade applied automatically after 2024-01-15\n" +
+				"  filters: exported\n" +
+				"  acknowledgement: none\n" +
+				`  owner: team "checkout", owner "jane", slack channel "#checkout"` + "\n" +
+				"  verdict: flagged, its engine version is deprecated\n",
+		},
+		{
+			name: "acknowledged",
+			explanation: explanation{item: item, acknowledgement: &acknowledgement{Identifier: "orders",
+				Expires: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Reason: "migrating to Aurora"}},
+			want: "instance orders in account 111111111111, region eu-west-1\n" +
+				"  engine: postgres 11.4\n" +
+				"  catalog status: deprecated\n" +
+				"  upgrade targets: 11.22, 15.2 (major)\n" +
+				"  recommended upgrade: none\n" +
+				"  standard support end: unknown\n" +
+				"  pending maintenance: none\n" +
+				"  filters: exported\n" +
+				`  acknowledgement: until 2024-06-01, "migrating to Aurora"` + "\n" +
+				"  owner: none\n" +
+				"  verdict: acknowledged, its engine version is deprecated\n",
+		},
+		{
+			name:        "filtered",
+			explanation: explanation{item: item, filtered: []string{filterReasonTags, filterReasonStopped}},
+			want: "instance orders in account 111111111111, region eu-west-1\n" +
+				"  engine: postgres 11.4\n" +
+				"  catalog status: deprecated\n" +
+				"  upgrade targets: 11.22, 15.2 (major)\n" +
+				"  recommended upgrade: none\n" +
+				"  standard support end: unknown\n" +
+				"  pending maintenance: none\n" +
+				"  filters: not exported, filtered out by tag filters, stopped\n" +
+				"  acknowledgement: none\n" +
+				"  owner: none\n" +
+				"  verdict: not flagged, as it is not exported\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			assert.NoError(t, tt.explanation.write(&b, now))
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+// TestRunExplain tests the explain subcommand, in demo mode.
+func TestRunExplain(t *testing.T) {
+	// set by TestMain
+	t.Setenv(AwsApiIntervalEnvName, "")
+
+	var b bytes.Buffer
+	assert.NoError(t, runExplain([]string{"billing", "-demo", "-regions", "eu-west-1", "-tag-filters", "env=dev"}, &b))
+	assert.Contains(t, b.String(), "cluster billing in account default, region eu-west-1\n")
+	assert.Contains(t, b.String(), "  pending maintenance: engine upgrade applied automatically after 2024-01-15\n")
+	assert.Contains(t, b.String(), "  filters: not exported, filtered out by tag filters\n")
+
+	err := runExplain([]string{"-demo", "billing"}, &b)
+	assert.EqualError(t, err, "usage: prometheus-exporter-aws-rds-engine-version explain <identifier> [flags]")
+	err = runExplain([]string{"unknown", "-demo", "-regions", "eu-west-1"}, &b)
+	assert.EqualError(t, err, `no RDS cluster nor instance "unknown" found`)
+}
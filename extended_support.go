@@ -0,0 +1,135 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hoursPerMonth is the average number of hours in a month used by the AWS pricing pages.
+const hoursPerMonth = 730
+
+// extendedSupportRate is the published price of RDS Extended Support per vCPU-hour of an instance, during the first two
+// years after the end of the standard support, and during the third one.
+type extendedSupportRate struct {
+	Years1And2 float64
+	Year3      float64
+}
+
+// extendedSupportRates are the prices of RDS Extended Support in us-east-1 of the engines it covers, in US dollars. The
+// other regions are priced within a few percent of them.
+var extendedSupportRates = map[string]extendedSupportRate{
+	"aurora-mysql":      {Years1And2: 0.100, Year3: 0.200},
+	"aurora-postgresql": {Years1And2: 0.100, Year3: 0.200},
+	"mysql":             {Years1And2: 0.100, Year3: 0.200},
+	"postgres":          {Years1And2: 0.100, Year3: 0.200},
+}
+
+// instanceSizeVCPUs maps the sizes of the instance classes, e.g. the "xlarge" of "db.r6g.xlarge", to their number of
+// vCPUs, which is the same across the instance families.
+var instanceSizeVCPUs = map[string]int{
+	"micro":    2,
+	"small":    2,
+	"medium":   2,
+	"large":    2,
+	"xlarge":   4,
+	"2xlarge":  8,
+	"4xlarge":  16,
+	"8xlarge":  32,
+	"12xlarge": 48,
+	"16xlarge": 64,
+	"24xlarge": 96,
+	"32xlarge": 128,
+	"48xlarge": 192,
+}
+
+// singleVCPUClasses are the previous generation instance classes with a single vCPU, unlike their size tells.
+var singleVCPUClasses = []string{"db.t1.micro", "db.m1.small", "db.t2.micro", "db.t2.small"}
+
+// instanceClassVCPUs returns the number of vCPUs of an instance class, e.g. 4 for "db.r6g.xlarge", from its size. It
+// returns false for the classes without a size, e.g. "db.serverless", and the unknown sizes.
+func instanceClassVCPUs(instanceClass string) (int, bool) {
+	if contains(singleVCPUClasses, instanceClass) {
+		return 1, true
+	}
+	// the sizes of the Oracle classes with optimized CPUs are followed by their options, e.g. db.r5.large.tpc2.mem2x
+	parts := strings.Split(instanceClass, ".")
+	if len(parts) < 3 || parts[0] != "db" {
+		return 0, false
+	}
+	vcpus, ok := instanceSizeVCPUs[parts[2]]
+	return vcpus, ok
+}
+
+// extendedSupportHourlyRate returns the price per vCPU-hour of the Extended Support of an engine at the given time,
+// given the end of the standard support of the version, and whether the version is in Extended Support at that time.
+// Before the end of the standard support, the price of the first year is returned, which is what the upgrade saves.
+func extendedSupportHourlyRate(rate extendedSupportRate, standardSupportEnd, now time.Time) (float64, bool) {
+	if now.Before(standardSupportEnd) {
+		return rate.Years1And2, false
+	}
+	if now.Before(standardSupportEnd.AddDate(2, 0, 0)) {
+		return rate.Years1And2, true
+	}
+	return rate.Year3, true
+}
+
+// exportExtendedSupportCost sets the ExtendedSupportCostGauge of an RDS instance to its estimated monthly cost of
+// Extended Support: its number of vCPUs times the price per vCPU-hour of its engine at the given time, over a month.
+// The cost is added to the ExtendedSupportAccountCostGauge of its account as well. The in_extended_support label of
+// both tells whether the version is already in Extended Support, or whether the cost is the one of its first year.
+// Nothing is exported for the clusters, the engines that Extended Support does not cover, the versions missing from
+// the calendar, and the instance classes whose number of vCPUs is unknown.
+func exportExtendedSupportCost(metrics *Metrics, rdsInfo RDSInfo, calendar supportCalendar, now time.Time) {
+	rate, ok := extendedSupportRates[rdsInfo.Engine]
+	if !ok || rdsInfo.InstanceClass == "" {
+		return
+	}
+	end, ok := calendar.standardSupportEnd(rdsInfo.Engine, rdsInfo.EngineVersion)
+	if !ok {
+		return
+	}
+	vcpus, ok := instanceClassVCPUs(rdsInfo.InstanceClass)
+	if !ok {
+		return
+	}
+	hourlyRate, inExtendedSupport := extendedSupportHourlyRate(rate, end, now)
+	cost := float64(vcpus) * hourlyRate * hoursPerMonth
+	metrics.ExtendedSupportCostGauge.With(prometheus.Labels{
+		"cluster_identifier":  rdsInfo.ClusterIdentifier,
+		"engine":              rdsInfo.Engine,
+		"engine_version":      rdsInfo.EngineVersion,
+		"instance_class":      rdsInfo.InstanceClass,
+		"in_extended_support": strconv.FormatBool(inExtendedSupport),
+		"account_id":          rdsInfo.Account,
+		"region":              rdsInfo.Region,
+	}).Set(cost)
+	metrics.ExtendedSupportAccountCostGauge.With(prometheus.Labels{
+		"account_id":          rdsInfo.Account,
+		"in_extended_support": strconv.FormatBool(inExtendedSupport),
+	}).Add(cost)
+}
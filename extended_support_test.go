@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInstanceClassVCPUs tests that the number of vCPUs of the instance classes is derived from their size.
+func TestInstanceClassVCPUs(t *testing.T) {
+	for _, tt := range []struct {
+		instanceClass string
+		want          int
+		wantOK        bool
+	}{
+		{instanceClass: "db.r6g.xlarge", want: 4, wantOK: true},
+		{instanceClass: "db.t3.medium", want: 2, wantOK: true},
+		{instanceClass: "db.t2.small", want: 1, wantOK: true},
+		{instanceClass: "db.r5.large.tpc2.mem2x", want: 2, wantOK: true},
+		{instanceClass: "db.serverless"},
+		{instanceClass: "db.x2g.metal"},
+	} {
+		t.Run(tt.instanceClass, func(t *testing.T) {
+			vcpus, ok := instanceClassVCPUs(tt.instanceClass)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, vcpus)
+		})
+	}
+}
+
+// TestExportExtendedSupportCost tests that the monthly cost of Extended Support is exported for the covered instances,
+// at the price of its year, and summed over their account.
+func TestExportExtendedSupportCost(t *testing.T) {
+	calendar := supportCalendar{
+		{Engines: []string{"mysql"}, Version: "5.7", StandardSupportEnd: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{Engines: []string{"postgres"}, Version: "11", StandardSupportEnd: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{Engines: []string{"postgres"}, Version: "16", StandardSupportEnd: time.Date(2029, 2, 28, 0, 0, 0, 0, time.UTC)},
+	}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "legacy-cms", Engine: "mysql", EngineVersion: "5.7.38", InstanceClass: "db.t3.medium",
+			Account: "123456789012", Region: "eu-west-1"},
+		{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "11.22", InstanceClass: "db.r6g.xlarge",
+			Account: "123456789012", Region: "eu-west-1"},
+		{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "16.1", InstanceClass: "db.r6g.large",
+			Account: "123456789012", Region: "eu-west-1"},
+		// clusters, uncovered engines and serverless instances are left out
+		{ClusterIdentifier: "billing", Engine: "aurora-postgresql", EngineVersion: "11.9", Account: "123456789012"},
+		{ClusterIdentifier: "erp", Engine: "oracle-ee", EngineVersion: "12.1.0.2", InstanceClass: "db.r5.large"},
+		{ClusterIdentifier: "reporting", Engine: "mysql", EngineVersion: "5.7.44", InstanceClass: "db.serverless"},
+	} {
+		exportExtendedSupportCost(metrics, rdsInfo, calendar, now)
+	}
+
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.ExtendedSupportCostGauge))
+	// in its third year of Extended Support
+	assert.InDelta(t, 2*0.200*730, testutil.ToFloat64(metrics.ExtendedSupportCostGauge.WithLabelValues(
+		"legacy-cms", "mysql", "5.7.38", "db.t3.medium", "true", "123456789012", "eu-west-1")), 1e-9)
+	assert.InDelta(t, 4*0.200*730, testutil.ToFloat64(metrics.ExtendedSupportCostGauge.WithLabelValues(
+		"users", "postgres", "11.22", "db.r6g.xlarge", "true", "123456789012", "eu-west-1")), 1e-9)
+	// still in standard support, at the price of the first year
+	assert.InDelta(t, 2*0.100*730, testutil.ToFloat64(metrics.ExtendedSupportCostGauge.WithLabelValues(
+		"orders", "postgres", "16.1", "db.r6g.large", "false", "123456789012", "eu-west-1")), 1e-9)
+	assert.InDelta(t, 6*0.200*730, testutil.ToFloat64(metrics.ExtendedSupportAccountCostGauge.WithLabelValues(
+		"123456789012", "true")), 1e-9)
+}
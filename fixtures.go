@@ -0,0 +1,163 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// unsafeFileNameChars matches the characters replaced in the names of fixture files.
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixture is a recorded AWS API exchange.
+type fixture struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	RequestBody string `json:"request_body"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+// fixtureTransport records the raw AWS API exchanges of one account into a directory, one JSON file per distinct
+// request, or replays them from it without calling AWS. Replaying a recorded snapshot reproduces its metrics, which
+// lets us investigate reported anomalies and build regression tests without access to the AWS accounts.
+type fixtureTransport struct {
+	dir string
+
+	// account prefixes the names of the fixture files, so that the accounts sharing a region do not collide.
+	account string
+
+	// replay is true to replay the fixtures, and false to record them.
+	replay bool
+
+	// redact is true to redact the secrets of the recorded responses, which can then no longer be replayed faithfully.
+	redact bool
+
+	// next performs the requests being recorded.
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	path := filepath.Join(t.dir, t.fileName(req, body))
+
+	if t.replay {
+		return t.load(req, path)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recorded := fixture{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		RequestBody: string(body),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(respBody),
+	}
+	if t.redact {
+		recorded.Body = redact(recorded.Body)
+	}
+	b, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to record AWS API response; %w", err)
+	}
+	return resp, nil
+}
+
+// load returns the recorded response of a request.
+func (t *fixtureTransport) load(req *http.Request, path string) (*http.Response, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s; %w", req.Method, req.URL, err)
+	}
+	var f fixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s; %w", path, err)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{f.ContentType}},
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
+
+// fileName returns the name of the fixture file of a request: the account, the endpoint and the operation, followed by
+// a hash of the request, e.g. "default_rds.eu-west-1.amazonaws.com_DescribeDBInstances_0a1b2c3d4e5f.json". Requests
+// only differing by their signature share the same file.
+func (t *fixtureTransport) fileName(req *http.Request, body []byte) string {
+	operation := req.Header.Get("X-Amz-Target")
+	if values, err := url.ParseQuery(string(body)); err == nil && values.Get("Action") != "" {
+		operation = values.Get("Action")
+	}
+	hash := sha256.Sum256(append([]byte(req.Method+" "+req.URL.String()+"\n"), body...))
+	name := fmt.Sprintf("%s_%s_%s_%x.json", t.account, req.URL.Host, operation, hash[:6])
+	return unsafeFileNameChars.ReplaceAllString(name, "-")
+}
+
+// accountOfRole returns the account ID of an IAM role ARN, or "default" for the default credentials.
+func accountOfRole(roleARN string) string {
+	parts := strings.Split(roleARN, ":")
+	if roleARN == "" || len(parts) < 5 {
+		return "default"
+	}
+	return parts[4]
+}
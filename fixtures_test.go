@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+const describeDBInstancesResponse = `<DescribeDBInstancesResponse xmlns="http://rds.amazonaws.com/doc/2014-10-31/">
+  <DescribeDBInstancesResult>
+    <DBInstances>
+      <DBInstance>
+        <DBInstanceIdentifier>db-1</DBInstanceIdentifier>
+        <Engine>postgres</Engine>
+        <EngineVersion>14.7</EngineVersion>
+      </DBInstance>
+    </DBInstances>
+  </DescribeDBInstancesResult>
+  <ResponseMetadata><RequestId>request-1</RequestId></ResponseMetadata>
+</DescribeDBInstancesResponse>`
+
+// TestFixtureTransport tests that the AWS API responses recorded by a Config are replayed by another one, without
+// calling AWS.
+func TestFixtureTransport(t *testing.T) {
+	// the SDK cannot add a CA bundle to a custom transport
+	t.Setenv("AWS_CA_BUNDLE", "")
+	dir := t.TempDir()
+	calls := 0
+	fakeAWS := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/xml"}},
+			Body:       io.NopCloser(strings.NewReader(describeDBInstancesResponse)),
+			Request:    req,
+		}, nil
+	})
+	newSessionWith := func(transport http.RoundTripper) *session.Session {
+		return session.Must(session.NewSession(&aws.Config{
+			HTTPClient:  &http.Client{Transport: transport},
+			Region:      Ptr("eu-west-1"),
+			Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		}))
+	}
+	describe := func(config *Config) (*rds.DescribeDBInstancesOutput, error) {
+		return config.RDS.DescribeDBInstances(&rds.DescribeDBInstancesInput{MaxRecords: Ptr(int64(100))})
+	}
+
+	recorder := NewConfig(&Options{RecordDir: dir}, newSessionWith(fakeAWS), "", "")
+	recorded, err := describe(recorder)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.True(t, strings.HasPrefix(files[0].Name(), "default_rds.eu-west-1.amazonaws.com_DescribeDBInstances_"),
+		files[0].Name())
+
+	// AWS is not called when replaying, whatever the credentials
+	replayer := NewConfig(&Options{ReplayDir: dir}, newSessionWith(fakeAWS), "", "")
+	replayed, err := describe(replayer)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, recorded.DBInstances, replayed.DBInstances)
+	assert.Equal(t, "db-1", *replayed.DBInstances[0].DBInstanceIdentifier)
+
+	// requests that were not recorded fail
+	_, err = replayer.RDS.DescribeDBInstances(&rds.DescribeDBInstancesInput{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no recorded response for POST https://rds.eu-west-1.amazonaws.com/")
+
+	// another account does not share the recordings
+	other := NewConfig(&Options{ReplayDir: dir}, newSessionWith(fakeAWS), "", "arn:aws:iam::123456789012:role/exporter")
+	_, err = describe(other)
+	assert.Error(t, err)
+}
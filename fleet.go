@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fleetKey identifies the resources of an engine in an account and region.
+type fleetKey struct {
+	engine, account, region string
+}
+
+// fleetCount is the number of resources of a fleetKey, and the number of them running a deprecated engine version.
+type fleetCount struct {
+	total, deprecated int
+}
+
+// fleetCounts counts the resources per engine, account and region within a snapshot, to compute their deprecated
+// ratio.
+type fleetCounts struct {
+	mu     sync.Mutex
+	counts map[fleetKey]*fleetCount
+}
+
+// newFleetCounts returns empty fleetCounts.
+func newFleetCounts() *fleetCounts {
+	return &fleetCounts{counts: make(map[fleetKey]*fleetCount)}
+}
+
+// reset forgets the resources of the previous snapshot.
+func (f *fleetCounts) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts = make(map[fleetKey]*fleetCount)
+}
+
+// add counts a resource, and returns the ratio of the resources of its key running a deprecated engine version.
+func (f *fleetCounts) add(key fleetKey, deprecated bool) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count, ok := f.counts[key]
+	if !ok {
+		count = &fleetCount{}
+		f.counts[key] = count
+	}
+	count.total++
+	if deprecated {
+		count.deprecated++
+	}
+	return float64(count.deprecated) / float64(count.total)
+}
+
+// exportFleetCounts counts an RDS resource in the DeprecatedTotalGauge or the AvailableTotalGauge of its engine,
+// account and region, and updates their DeprecatedRatioGauge. The deprecated resources are counted whether they are in
+// grace or acknowledged or not.
+func exportFleetCounts(metrics *Metrics, rdsInfo RDSInfo, deprecated bool) {
+	labels := prometheus.Labels{
+		"engine":        rdsInfo.Engine,
+		"account_id":    rdsInfo.Account,
+		"account_alias": rdsInfo.AccountAlias,
+		"region":        rdsInfo.Region,
+	}
+	total := metrics.AvailableTotalGauge
+	if deprecated {
+		total = metrics.DeprecatedTotalGauge
+	}
+	total.With(labels).Inc()
+	ratio := metrics.Fleet.add(fleetKey{engine: rdsInfo.Engine, account: rdsInfo.Account, region: rdsInfo.Region},
+		deprecated)
+	metrics.DeprecatedRatioGauge.With(labels).Set(ratio)
+}
@@ -0,0 +1,66 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportFleetCounts tests that the resources are counted per engine, account and region, labeled with the alias
+// of their account, and that the deprecated ratio of each of them is kept up to date, until the counts are reset.
+func TestExportFleetCounts(t *testing.T) {
+	metrics := NewMetrics()
+	for _, tt := range []struct {
+		rdsInfo    RDSInfo
+		deprecated bool
+	}{
+		{RDSInfo{Engine: "postgres", Account: "default", Region: "eu-west-1"}, true},
+		{RDSInfo{Engine: "postgres", Account: "default", Region: "eu-west-1"}, false},
+		{RDSInfo{Engine: "postgres", Account: "default", Region: "eu-west-1"}, false},
+		{RDSInfo{Engine: "postgres", Account: "default", Region: "eu-west-1"}, false},
+		{RDSInfo{Engine: "postgres", Account: "123456789012", AccountAlias: "payments-prod", Region: "eu-west-1"},
+			false},
+		{RDSInfo{Engine: "mysql", Account: "default", Region: "us-east-1"}, true},
+	} {
+		exportFleetCounts(metrics, tt.rdsInfo, tt.deprecated)
+	}
+
+	want := `# HELP aws_custom_rds_deprecated_ratio Ratio of the resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_ratio gauge
+aws_custom_rds_deprecated_ratio{account_alias="payments-prod",account_id="123456789012",engine="postgres",region="eu-west-1"} 0
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="mysql",region="us-east-1"} 1
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="postgres",region="eu-west-1"} 0.25
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.DeprecatedRatioGauge, strings.NewReader(want)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.AvailableTotalGauge.WithLabelValues(
+		"postgres", "default", "", "eu-west-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.DeprecatedTotalGauge.WithLabelValues(
+		"postgres", "default", "", "eu-west-1")))
+
+	metrics.Fleet.reset()
+	assert.Equal(t, 0.0, metrics.Fleet.add(fleetKey{engine: "mysql", account: "default", region: "us-east-1"}, false))
+}
@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const (
+	// deadlineSourceEndOfSupport and deadlineSourcePendingMaintenance tell where a forced upgrade deadline comes from:
+	// the support calendar or the pending maintenance actions of the resource.
+	deadlineSourceEndOfSupport       = "end_of_support"
+	deadlineSourcePendingMaintenance = "pending_maintenance"
+
+	// pendingActionUpgrade is the pending maintenance action of engine upgrades.
+	pendingActionUpgrade = "db-upgrade"
+)
+
+// pendingUpgradeKeys returns the keys of an RDS resource in the map of pending upgrades: the resource of its ARN, e.g.
+// "cluster:orders" or "db:orders-1", followed by the one of its cluster for the cluster members, which are upgraded
+// along with their cluster.
+func pendingUpgradeKeys(rdsInfo RDSInfo) []string {
+	if rdsInfo.ResourceType == resourceTypeCluster {
+		return []string{"cluster:" + rdsInfo.ClusterIdentifier}
+	}
+	keys := []string{"db:" + rdsInfo.ClusterIdentifier}
+	if rdsInfo.MemberOf != "" {
+		keys = append(keys, "cluster:"+rdsInfo.MemberOf)
+	}
+	return keys
+}
+
+// getPendingUpgrades lists the pending maintenance actions of the account and region of the config with
+// DescribePendingMaintenanceActions, and returns the date from which the engine upgrades are applied automatically,
+// the earliest of their auto-applied-after and forced apply dates, keyed by the resource of their ARN. The upgrades that are
+// only applied when requested are left out.
+func getPendingUpgrades(config *Config) (map[string]time.Time, error) {
+	pending := make(map[string]time.Time)
+	var nextMarker *string
+	condition := true
+	for condition {
+		output, err := config.RDS.DescribePendingMaintenanceActions(&rds.DescribePendingMaintenanceActionsInput{
+			Marker: nextMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe pending maintenance actions; %w", err)
+		}
+		if output == nil {
+			break
+		}
+		for _, resource := range output.PendingMaintenanceActions {
+			parsed, err := arn.Parse(aws.StringValue(resource.ResourceIdentifier))
+			if err != nil {
+				continue
+			}
+			for _, action := range resource.PendingMaintenanceActionDetails {
+				if aws.StringValue(action.Action) != pendingActionUpgrade {
+					continue
+				}
+				for _, date := range []*time.Time{action.AutoAppliedAfterDate, action.ForcedApplyDate} {
+					if date == nil {
+						continue
+					}
+					if current, ok := pending[parsed.Resource]; !ok || date.Before(current) {
+						pending[parsed.Resource] = *date
+					}
+				}
+			}
+		}
+		nextMarker = output.Marker
+		condition = nextMarker != nil
+	}
+	return pending, nil
+}
+
+// forcedUpgradeDeadline returns the time after which AWS upgrades an RDS resource, and its source: the earliest of the
+// end of the standard support of its version, if it is deprecated, and the date from which a pending upgrade of the
+// resource, or of its cluster, is applied automatically. It returns false if there is neither.
+func forcedUpgradeDeadline(rdsInfo RDSInfo, deprecated bool, calendar supportCalendar,
+	pending map[string]time.Time) (time.Time, string, bool) {
+	var deadline time.Time
+	hasDeadline := false
+	if deprecated {
+		deadline, hasDeadline = calendar.standardSupportEnd(rdsInfo.Engine, rdsInfo.EngineVersion)
+	}
+	source := deadlineSourceEndOfSupport
+	for _, key := range pendingUpgradeKeys(rdsInfo) {
+		if date, ok := pending[key]; ok && (!hasDeadline || date.Before(deadline)) {
+			deadline, hasDeadline, source = date, true, deadlineSourcePendingMaintenance
+		}
+	}
+	return deadline, source, hasDeadline
+}
+
+// exportForcedUpgradeDeadline sets the ForcedUpgradeDeadlineGauge of an RDS resource whose engine version is
+// deprecated to the time after which AWS upgrades it: the earliest of the end of the standard support of its version,
+// according to the support calendar, and the date from which a pending upgrade of the resource, or of its cluster, is
+// applied automatically. Nothing is exported for the versions that are not deprecated, nor for the ones without
+// deadline.
+func exportForcedUpgradeDeadline(metrics *Metrics, rdsInfo RDSInfo, m engineVersions, calendar supportCalendar,
+	pending map[string]time.Time) {
+	info, ok := m[rdsInfo.Engine][rdsInfo.EngineVersion]
+	if !ok || !deprecatedStatuses[info.Status] {
+		return
+	}
+	deadline, source, ok := forcedUpgradeDeadline(rdsInfo, true, calendar, pending)
+	if !ok {
+		return
+	}
+	metrics.ForcedUpgradeDeadlineGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"engine":             rdsInfo.Engine,
+		"engine_version":     rdsInfo.EngineVersion,
+		"source":             source,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(float64(deadline.Unix()))
+}
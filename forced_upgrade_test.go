@@ -0,0 +1,104 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSnapshotForcedUpgrades tests that snapshot exports the earliest of the end of support deadline and the automatic
+// upgrade date of the resources running a deprecated engine version, or of their cluster, and nothing for the other
+// ones.
+func TestSnapshotForcedUpgrades(t *testing.T) {
+	config := &Config{Region: "eu-west-1", Concurrency: 1, ForcedUpgrades: true, Calendar: embeddedSupportCalendar,
+		RDS: &MockRDSAPI{
+			clustersOutput: []*rds.DescribeDBClustersOutput{{
+				DBClusters: []*rds.DBCluster{{
+					DBClusterIdentifier: Ptr("billing"),
+					Engine:              Ptr("aurora-postgresql"),
+					EngineVersion:       Ptr("11.9"),
+					DBClusterMembers: []*rds.DBClusterMember{
+						{DBInstanceIdentifier: Ptr("billing-1"), IsClusterWriter: Ptr(true)},
+					},
+				}},
+			}},
+			instancesOutput: []*rds.DescribeDBInstancesOutput{{
+				DBInstances: []*rds.DBInstance{
+					{DBInstanceIdentifier: Ptr("billing-1"), DBClusterIdentifier: Ptr("billing"),
+						Engine: Ptr("aurora-postgresql"), EngineVersion: Ptr("11.9")},
+					{DBInstanceIdentifier: Ptr("legacy-cms"), Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.38")},
+					{DBInstanceIdentifier: Ptr("sessions"), Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.33")},
+					{DBInstanceIdentifier: Ptr("wiki"), Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.11")},
+				},
+			}},
+			engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+				DBEngineVersions: []*rds.DBEngineVersion{
+					{Engine: Ptr("aurora-postgresql"), EngineVersion: Ptr("11.9"), Status: Ptr("deprecated")},
+					{Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.38"), Status: Ptr("deprecated")},
+					{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.11"), Status: Ptr("deprecated")},
+					{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.33"), Status: Ptr("available")},
+				},
+			}},
+			pendingOutput: []*rds.DescribePendingMaintenanceActionsOutput{{
+				PendingMaintenanceActions: []*rds.ResourcePendingMaintenanceActions{
+					{
+						ResourceIdentifier: Ptr("arn:aws:rds:eu-west-1:123456789012:cluster:billing"),
+						PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+							{Action: Ptr("system-update"), AutoAppliedAfterDate: Ptr(time.Unix(1600000000, 0))},
+							{Action: Ptr("db-upgrade"), ForcedApplyDate: Ptr(time.Unix(1705000000, 0)),
+								AutoAppliedAfterDate: Ptr(time.Unix(1704000000, 0))},
+						},
+					},
+					{
+						ResourceIdentifier: Ptr("arn:aws:rds:eu-west-1:123456789012:db:wiki"),
+						PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+							{Action: Ptr("db-upgrade"), ForcedApplyDate: Ptr(time.Unix(1710000000, 0))},
+						},
+					},
+					{
+						// applied after the end of support deadline
+						ResourceIdentifier: Ptr("arn:aws:rds:eu-west-1:123456789012:db:legacy-cms"),
+						PendingMaintenanceActionDetails: []*rds.PendingMaintenanceAction{
+							{Action: Ptr("db-upgrade"), AutoAppliedAfterDate: Ptr(time.Unix(1800000000, 0))},
+						},
+					},
+				},
+			}},
+		}}
+	metrics := NewMetrics()
+	assert.NoError(t, snapshot(config, metrics, make(engineVersions)))
+
+	want := `# HELP aws_custom_rds_forced_upgrade_deadline_timestamp_seconds Time after which AWS upgrades the resources running a deprecated engine version
+# TYPE aws_custom_rds_forced_upgrade_deadline_timestamp_seconds gauge
+aws_custom_rds_forced_upgrade_deadline_timestamp_seconds{account_id="default",cluster_identifier="billing",engine="aurora-postgresql",engine_version="11.9",region="eu-west-1",source="pending_maintenance"} 1.704e+09
+aws_custom_rds_forced_upgrade_deadline_timestamp_seconds{account_id="default",cluster_identifier="billing-1",engine="aurora-postgresql",engine_version="11.9",region="eu-west-1",source="pending_maintenance"} 1.704e+09
+aws_custom_rds_forced_upgrade_deadline_timestamp_seconds{account_id="default",cluster_identifier="legacy-cms",engine="mysql",engine_version="5.7.38",region="eu-west-1",source="end_of_support"} 1.7091648e+09
+aws_custom_rds_forced_upgrade_deadline_timestamp_seconds{account_id="default",cluster_identifier="wiki",engine="mysql",engine_version="8.0.11",region="eu-west-1",source="pending_maintenance"} 1.71e+09
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.ForcedUpgradeDeadlineGauge, strings.NewReader(want)))
+}
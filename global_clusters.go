@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+)
+
+const (
+	globalClusterRolePrimary   = "primary"
+	globalClusterRoleSecondary = "secondary"
+)
+
+// GlobalClusterMemberInfo represents the membership of an Aurora cluster to an Aurora Global Database.
+type GlobalClusterMemberInfo struct {
+	// GlobalClusterIdentifier is the identifier of the Aurora Global Database.
+	GlobalClusterIdentifier string
+
+	// ClusterIdentifier is the identifier of the member cluster.
+	ClusterIdentifier string
+
+	// Region is the AWS region of the member cluster.
+	Region string
+
+	// Role is "primary" for the writer cluster of the global database, and "secondary" for the read-only clusters.
+	// Secondary clusters must be upgraded before the primary cluster.
+	Role string
+
+	// Engine and EngineVersion are the engine and version of the global database.
+	Engine        string
+	EngineVersion string
+}
+
+// getGlobalClusterMembers lists the members of every Aurora Global Database with DescribeGlobalClusters. Members are
+// listed whatever their region is, so that the whole topology of a global database is visible from any region.
+// An error is returned if the function fails to retrieve global cluster information.
+func getGlobalClusterMembers(config *Config) ([]GlobalClusterMemberInfo, error) {
+	members := make([]GlobalClusterMemberInfo, 0)
+	var nextMarker *string
+	condition := true
+	for condition {
+		globalClusters, err := config.RDS.DescribeGlobalClusters(&rds.DescribeGlobalClustersInput{
+			Marker: nextMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe global clusters; %w", err)
+		}
+		if globalClusters == nil {
+			break
+		}
+		members = append(members, handleGlobalClusters(globalClusters)...)
+		nextMarker = globalClusters.Marker
+		condition = nextMarker != nil
+	}
+	return members, nil
+}
+
+// handleGlobalClusters converts the members of the global clusters of a DescribeGlobalClusters page into
+// GlobalClusterMemberInfos. Members whose ARN cannot be parsed are ignored.
+func handleGlobalClusters(globalClusters *rds.DescribeGlobalClustersOutput) []GlobalClusterMemberInfo {
+	members := make([]GlobalClusterMemberInfo, 0)
+	for _, globalCluster := range globalClusters.GlobalClusters {
+		for _, member := range globalCluster.GlobalClusterMembers {
+			parsed, err := arn.Parse(aws.StringValue(member.DBClusterArn))
+			if err != nil {
+				continue
+			}
+			role := globalClusterRoleSecondary
+			if aws.BoolValue(member.IsWriter) {
+				role = globalClusterRolePrimary
+			}
+			members = append(members, GlobalClusterMemberInfo{
+				GlobalClusterIdentifier: aws.StringValue(globalCluster.GlobalClusterIdentifier),
+				ClusterIdentifier:       strings.TrimPrefix(parsed.Resource, "cluster:"),
+				Region:                  parsed.Region,
+				Role:                    role,
+				Engine:                  aws.StringValue(globalCluster.Engine),
+				EngineVersion:           aws.StringValue(globalCluster.EngineVersion),
+			})
+		}
+	}
+	return members
+}
+
+// exportGlobalClusters sets the GlobalClusterMemberGauge to 1 for every member of every Aurora Global Database.
+func exportGlobalClusters(config *Config, metrics *Metrics) error {
+	members, err := getGlobalClusterMembers(config)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		metrics.GlobalClusterMemberGauge.With(prometheus.Labels{
+			"global_cluster_identifier": member.GlobalClusterIdentifier,
+			"cluster_identifier":        member.ClusterIdentifier,
+			"account_id":                config.account(),
+			"region":                    member.Region,
+			"role":                      member.Role,
+			"engine":                    member.Engine,
+			"engine_version":            member.EngineVersion,
+		}).Set(1)
+	}
+	return nil
+}
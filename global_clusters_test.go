@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestGetGlobalClusterMembers tests the getGlobalClusterMembers function.
+func TestGetGlobalClusterMembers(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{
+		globalClustersOutput: []*rds.DescribeGlobalClustersOutput{{
+			GlobalClusters: []*rds.GlobalCluster{{
+				GlobalClusterIdentifier: Ptr("global-1"),
+				Engine:                  Ptr("aurora-postgresql"),
+				EngineVersion:           Ptr("13.7"),
+				GlobalClusterMembers: []*rds.GlobalClusterMember{
+					{
+						DBClusterArn: Ptr("arn:aws:rds:eu-west-1:123456789012:cluster:cluster-1"),
+						IsWriter:     Ptr(true),
+					},
+					{
+						DBClusterArn: Ptr("arn:aws:rds:us-east-1:123456789012:cluster:cluster-2"),
+						IsWriter:     Ptr(false),
+					},
+					{
+						DBClusterArn: Ptr("not an arn"),
+					},
+				},
+			}},
+		}},
+	}}
+
+	got, err := getGlobalClusterMembers(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []GlobalClusterMemberInfo{
+		{
+			GlobalClusterIdentifier: "global-1",
+			ClusterIdentifier:       "cluster-1",
+			Region:                  "eu-west-1",
+			Role:                    "primary",
+			Engine:                  "aurora-postgresql",
+			EngineVersion:           "13.7",
+		},
+		{
+			GlobalClusterIdentifier: "global-1",
+			ClusterIdentifier:       "cluster-2",
+			Region:                  "us-east-1",
+			Role:                    "secondary",
+			Engine:                  "aurora-postgresql",
+			EngineVersion:           "13.7",
+		},
+	}, got)
+}
@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// deprecationKey identifies an engine version in a region.
+type deprecationKey struct {
+	Region        string
+	Engine        string
+	EngineVersion string
+}
+
+// deprecationState is the status of an engine version at its last observation, and the time it was first observed
+// deprecated after being observed available, zero otherwise.
+type deprecationState struct {
+	deprecated bool
+	since      time.Time
+}
+
+// deprecationTracker tells the engine versions that flipped to deprecated less than a grace period ago, according to
+// the catalogs fetched by the exporter. A version flips when a catalog lists it as deprecated while the previous one
+// listed it as available: the versions already deprecated in the first catalog of their engine are out of grace, as the
+// exporter cannot tell when they flipped. It is safe for concurrent use, and a nil deprecationTracker has no grace
+// period.
+type deprecationTracker struct {
+	grace time.Duration
+	now   func() time.Time
+
+	mu     sync.Mutex
+	states map[deprecationKey]deprecationState
+}
+
+// newDeprecationTracker returns the deprecationTracker of the deprecation grace period of the options, or nil if it is
+// not set.
+func newDeprecationTracker(options *Options) *deprecationTracker {
+	if options.DeprecationGraceDays == 0 {
+		return nil
+	}
+	return &deprecationTracker{
+		grace:  time.Duration(options.DeprecationGraceDays) * 24 * time.Hour,
+		now:    time.Now,
+		states: make(map[deprecationKey]deprecationState),
+	}
+}
+
+// observe records the status of every version of the catalog of an engine in a region.
+func (t *deprecationTracker) observe(region, engine string, catalog versionCatalog) {
+	if t == nil {
+		return
+	}
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for engineVersion, info := range catalog {
+		key := deprecationKey{Region: region, Engine: engine, EngineVersion: engineVersion}
+		deprecated := deprecatedStatuses[info.Status]
+		state, ok := t.states[key]
+		switch {
+		case !ok || !deprecated:
+			t.states[key] = deprecationState{deprecated: deprecated}
+		case !state.deprecated:
+			t.states[key] = deprecationState{deprecated: true, since: now}
+		}
+	}
+}
+
+// observeAll records the status of every version of the catalogs of a region.
+func (t *deprecationTracker) observeAll(region string, m engineVersions) {
+	for engine, catalog := range m {
+		t.observe(region, engine, catalog)
+	}
+}
+
+// inGrace returns true if the engine version of an RDS resource flipped to deprecated less than the grace period ago.
+func (t *deprecationTracker) inGrace(rdsInfo RDSInfo) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	state := t.states[deprecationKey{Region: rdsInfo.Region, Engine: rdsInfo.Engine, EngineVersion: rdsInfo.EngineVersion}]
+	t.mu.Unlock()
+	return state.deprecated && !state.since.IsZero() && t.now().Before(state.since.Add(t.grace))
+}
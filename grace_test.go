@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDeprecationTracker tests that only the versions observed available, then deprecated, are in grace, until the
+// grace period is over.
+func TestDeprecationTracker(t *testing.T) {
+	now := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newDeprecationTracker(&Options{DeprecationGraceDays: 30})
+	tracker.now = func() time.Time { return now }
+	rdsInfo := func(engineVersion string) RDSInfo {
+		return RDSInfo{Region: "eu-west-1", Engine: "postgres", EngineVersion: engineVersion}
+	}
+
+	tracker.observe("eu-west-1", "postgres", versionCatalog{
+		"11.4": {Status: "deprecated"}, "11.22": {Status: "available"}, "12.15": {Status: "available"},
+	})
+	assert.False(t, tracker.inGrace(rdsInfo("11.4")), "already deprecated at the first observation")
+	assert.False(t, tracker.inGrace(rdsInfo("11.22")), "available")
+
+	now = now.Add(24 * time.Hour)
+	tracker.observe("eu-west-1", "postgres", versionCatalog{
+		"11.4": {Status: "deprecated"}, "11.22": {Status: "deprecated"}, "12.15": {Status: "available"},
+	})
+	assert.True(t, tracker.inGrace(rdsInfo("11.22")), "flipped")
+	assert.False(t, tracker.inGrace(RDSInfo{Region: "us-east-1", Engine: "postgres", EngineVersion: "11.22"}),
+		"not observed in the region")
+
+	now = now.Add(29 * 24 * time.Hour)
+	tracker.observe("eu-west-1", "postgres", versionCatalog{"11.22": {Status: "deprecated"}})
+	assert.True(t, tracker.inGrace(rdsInfo("11.22")), "grace period not over")
+	now = now.Add(24 * time.Hour)
+	assert.False(t, tracker.inGrace(rdsInfo("11.22")), "grace period over")
+
+	var nilTracker *deprecationTracker
+	nilTracker.observe("eu-west-1", "postgres", versionCatalog{"11.22": {Status: "available"}})
+	assert.False(t, nilTracker.inGrace(rdsInfo("11.22")))
+	assert.Nil(t, newDeprecationTracker(&Options{}))
+}
+
+// TestSnapshotGrace tests that a resource whose version flipped to deprecated between two catalog refreshes is counted
+// in grace rather than deprecated.
+func TestSnapshotGrace(t *testing.T) {
+	instances := []*rds.DescribeDBInstancesOutput{{DBInstances: []*rds.DBInstance{
+		{DBInstanceIdentifier: Ptr("users"), Engine: Ptr("postgres"), EngineVersion: Ptr("11.22")},
+		{DBInstanceIdentifier: Ptr("legacy"), Engine: Ptr("postgres"), EngineVersion: Ptr("11.4")},
+	}}}
+	catalog := func(status string) []*rds.DescribeDBEngineVersionsOutput {
+		return []*rds.DescribeDBEngineVersionsOutput{{DBEngineVersions: []*rds.DBEngineVersion{
+			{Engine: Ptr("postgres"), EngineVersion: Ptr("11.4"), Status: Ptr("deprecated")},
+			{Engine: Ptr("postgres"), EngineVersion: Ptr("11.22"), Status: Ptr(status)},
+		}}}
+	}
+	metrics := NewMetrics()
+	metrics.Deprecations = newDeprecationTracker(&Options{DeprecationGraceDays: 30})
+
+	for _, status := range []string{"available", "deprecated"} {
+		config := &Config{Region: "eu-west-1", Concurrency: 1, RDS: &MockRDSAPI{
+			clustersOutput:       []*rds.DescribeDBClustersOutput{{}},
+			instancesOutput:      instances,
+			engineVersionsOutput: catalog(status),
+		}}
+		assert.NoError(t, snapshot(config, metrics, make(engineVersions)))
+	}
+
+	want := `# HELP aws_custom_rds_version_grace Number of instances whose version flipped to deprecated less than the grace period ago
+# TYPE aws_custom_rds_version_grace gauge
+aws_custom_rds_version_grace{account_id="default",babelfish="false",cluster_identifier="legacy",edition="",engine="postgres",engine_version="11.4",engine_version_major="11",engine_version_minor="4",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 0
+aws_custom_rds_version_grace{account_id="default",babelfish="false",cluster_identifier="users",edition="",engine="postgres",engine_version="11.22",engine_version_major="11",engine_version_minor="22",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.GraceGauge, strings.NewReader(want)))
+	want = `# HELP aws_custom_rds_version_deprecated Number of instances whose Version is deprecated
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="legacy",edition="",engine="postgres",engine_version="11.4",engine_version_major="11",engine_version_minor="4",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 1
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="users",edition="",engine="postgres",engine_version="11.22",engine_version_major="11",engine_version_minor="22",license_model="",maintenance_window="",rds_custom="false",region="eu-west-1",role=""} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.DeprecatedGauge, strings.NewReader(want)))
+}
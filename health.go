@@ -0,0 +1,138 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/health"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// healthServiceRDS is the service of the AWS Health events of RDS.
+const healthServiceRDS = "RDS"
+
+// healthRegions maps the partitions to the region of the global endpoint of the AWS Health API, which only serves the
+// organizational and account views from there.
+var healthRegions = map[string]string{
+	endpoints.AwsPartitionID:      "us-east-1",
+	endpoints.AwsCnPartitionID:    "cn-northwest-1",
+	endpoints.AwsUsGovPartitionID: "us-gov-west-1",
+}
+
+// globalRegion returns the region of the global endpoint of an AWS API in the partition of the given region, among the
+// regions of its global endpoint keyed by partition, defaulting to the one of the aws partition.
+func globalRegion(regions map[string]string, region string) string {
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		if globalRegion, ok := regions[partition.ID()]; ok {
+			return globalRegion
+		}
+	}
+	return regions[endpoints.AwsPartitionID]
+}
+
+// HealthEventInfo represents an AWS Health event of RDS, e.g. an upcoming end of support or a mandatory upgrade.
+type HealthEventInfo struct {
+	// Arn is the ARN of the event, and EventTypeCode its type, e.g. AWS_RDS_PLANNED_LIFECYCLE_EVENT.
+	Arn           string
+	EventTypeCode string
+
+	// StatusCode is either "upcoming" or "open".
+	StatusCode string
+
+	// Region is the AWS region of the event.
+	Region string
+
+	// StartTime and EndTime are the start and the end of the event. EndTime is zero if the event has no end yet.
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// getHealthEvents lists the upcoming and open scheduled changes of RDS in the region of the config with the
+// DescribeEvents of the AWS Health API, which announces the end of support of engine versions and the mandatory
+// upgrades. The AWS Health API requires a Business, Enterprise On-Ramp or Enterprise support plan.
+// An error is returned if the function fails to retrieve the events.
+func getHealthEvents(config *Config) ([]HealthEventInfo, error) {
+	events := make([]HealthEventInfo, 0)
+	var nextToken *string
+	condition := true
+	for condition {
+		output, err := config.Health.DescribeEvents(&health.DescribeEventsInput{
+			Filter: &health.EventFilter{
+				Services:            aws.StringSlice([]string{healthServiceRDS}),
+				Regions:             aws.StringSlice([]string{config.Region}),
+				EventTypeCategories: aws.StringSlice([]string{health.EventTypeCategoryScheduledChange}),
+				EventStatusCodes: aws.StringSlice([]string{health.EventStatusCodeUpcoming,
+					health.EventStatusCodeOpen}),
+			},
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe health events; %w", err)
+		}
+		if output == nil {
+			break
+		}
+		for _, event := range output.Events {
+			events = append(events, HealthEventInfo{
+				Arn:           aws.StringValue(event.Arn),
+				EventTypeCode: aws.StringValue(event.EventTypeCode),
+				StatusCode:    aws.StringValue(event.StatusCode),
+				Region:        aws.StringValue(event.Region),
+				StartTime:     aws.TimeValue(event.StartTime),
+				EndTime:       aws.TimeValue(event.EndTime),
+			})
+		}
+		nextToken = output.NextToken
+		condition = nextToken != nil
+	}
+	return events, nil
+}
+
+// exportHealthEvents sets the HealthEventStartGauge to the start time of every upcoming and open scheduled change of
+// RDS, and the HealthEventEndGauge to its end time, if it has one.
+func exportHealthEvents(config *Config, metrics *Metrics) error {
+	events, err := getHealthEvents(config)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		labels := prometheus.Labels{
+			"event_arn":       event.Arn,
+			"event_type_code": event.EventTypeCode,
+			"status":          event.StatusCode,
+			"account_id":      config.account(),
+			"region":          event.Region,
+		}
+		if !event.StartTime.IsZero() {
+			metrics.HealthEventStartGauge.With(labels).Set(float64(event.StartTime.Unix()))
+		}
+		if !event.EndTime.IsZero() {
+			metrics.HealthEventEndGauge.With(labels).Set(float64(event.EndTime.Unix()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/health"
+	"github.com/aws/aws-sdk-go/service/health/healthiface"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockHealthAPI struct {
+	healthiface.HealthAPI
+	outputs []*health.DescribeEventsOutput
+	inputs  []*health.DescribeEventsInput
+	err     error
+}
+
+func (m *MockHealthAPI) DescribeEvents(input *health.DescribeEventsInput) (*health.DescribeEventsOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.inputs = append(m.inputs, input)
+	output := m.outputs[0]
+	m.outputs = m.outputs[1:]
+	return output, nil
+}
+
+// TestExportHealthEvents tests that the upcoming and open scheduled changes of RDS of the region are listed page by
+// page, and that their start and end times are exported.
+func TestExportHealthEvents(t *testing.T) {
+	start := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	api := &MockHealthAPI{outputs: []*health.DescribeEventsOutput{
+		{
+			Events: []*health.Event{{
+				Arn:           Ptr("arn:aws:health:eu-west-1::event/RDS/AWS_RDS_PLANNED_LIFECYCLE_EVENT/1"),
+				EventTypeCode: Ptr("AWS_RDS_PLANNED_LIFECYCLE_EVENT"),
+				StatusCode:    Ptr(health.EventStatusCodeUpcoming),
+				Region:        Ptr("eu-west-1"),
+				StartTime:     &start,
+				EndTime:       &end,
+			}},
+			NextToken: Ptr("page-2"),
+		},
+		{
+			Events: []*health.Event{{
+				Arn:           Ptr("arn:aws:health:eu-west-1::event/RDS/AWS_RDS_MAINTENANCE_SCHEDULED/2"),
+				EventTypeCode: Ptr("AWS_RDS_MAINTENANCE_SCHEDULED"),
+				StatusCode:    Ptr(health.EventStatusCodeOpen),
+				Region:        Ptr("eu-west-1"),
+				StartTime:     &start,
+			}},
+		},
+	}}
+	config := &Config{Health: api, Region: "eu-west-1"}
+	metrics := NewMetrics()
+
+	assert.NoError(t, exportHealthEvents(config, metrics))
+	assert.Len(t, api.inputs, 2)
+	assert.Equal(t, []*string{Ptr("RDS")}, api.inputs[0].Filter.Services)
+	assert.Equal(t, []*string{Ptr("eu-west-1")}, api.inputs[0].Filter.Regions)
+	assert.Equal(t, Ptr("page-2"), api.inputs[1].NextToken)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.HealthEventStartGauge))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.HealthEventEndGauge))
+	assert.Equal(t, float64(end.Unix()), testutil.ToFloat64(metrics.HealthEventEndGauge.WithLabelValues(
+		"arn:aws:health:eu-west-1::event/RDS/AWS_RDS_PLANNED_LIFECYCLE_EVENT/1", "AWS_RDS_PLANNED_LIFECYCLE_EVENT",
+		"upcoming", "default", "eu-west-1")))
+	assert.Equal(t, float64(start.Unix()), testutil.ToFloat64(metrics.HealthEventStartGauge.WithLabelValues(
+		"arn:aws:health:eu-west-1::event/RDS/AWS_RDS_MAINTENANCE_SCHEDULED/2", "AWS_RDS_MAINTENANCE_SCHEDULED",
+		"open", "default", "eu-west-1")))
+
+	api = &MockHealthAPI{err: errors.New("SubscriptionRequiredException")}
+	assert.EqualError(t, exportHealthEvents(&Config{Health: api}, NewMetrics()),
+		"failed to describe health events; SubscriptionRequiredException")
+}
+
+// TestGlobalRegion tests that the global AWS APIs are called in the region of their global endpoint in each partition.
+func TestGlobalRegion(t *testing.T) {
+	assert.Equal(t, "us-east-1", globalRegion(healthRegions, "eu-west-1"))
+	assert.Equal(t, "cn-northwest-1", globalRegion(healthRegions, "cn-north-1"))
+	assert.Equal(t, "us-gov-west-1", globalRegion(healthRegions, "us-gov-east-1"))
+	assert.Equal(t, "us-east-1", globalRegion(healthRegions, ""))
+	assert.Equal(t, "cn-north-1", globalRegion(supportRegions, "cn-northwest-1"))
+}
@@ -0,0 +1,127 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"net/http"
+	"time"
+)
+
+const (
+	healthStatusOK      = "ok"
+	healthStatusFailed  = "failed"
+	healthStatusSkipped = "skipped"
+)
+
+// healthCheck is the result of a check of the deep health check.
+type healthCheck struct {
+	// Name is the checked AWS API operation, e.g. "sts:GetCallerIdentity".
+	Name string `json:"name"`
+
+	// Scope is the checked account and region.
+	Scope string `json:"scope"`
+
+	// Status is "ok", "failed" or "skipped".
+	Status string `json:"status"`
+
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// healthReport is the response of the health endpoint.
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks,omitempty"`
+}
+
+// healthzHandler returns the handler of the /healthz endpoint. It responds 200 as long as the exporter runs. With the
+// "deep=1" query parameter, it also checks, in every account and region of the scopes, that the credentials are valid
+// with sts:GetCallerIdentity, and that RDS can be queried with a DescribeDBEngineVersions call limited to a single
+// page of default versions. Each check is reported in JSON, and the endpoint responds 503 if any of them failed, so
+// that expired credentials show up as unhealthy rather than as stale metrics.
+func healthzHandler(scopes *Scopes) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := healthReport{Status: healthStatusOK}
+		if r.URL.Query().Get("deep") == "1" && scopes != nil {
+			report.Checks = checkHealth(scopes)
+		}
+		for _, check := range report.Checks {
+			if check.Status == healthStatusFailed {
+				report.Status = healthStatusFailed
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != healthStatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// checkHealth runs the checks of the deep health check in every account and region of the scopes, as many at once as
+// the scopes allow. Checks whose client is not configured, e.g. in demo mode, are skipped.
+func checkHealth(scopes *Scopes) []healthCheck {
+	configs := scopes.configs()
+	checks := make([]healthCheck, 2*len(configs))
+	tasks := make([]func() error, 0, len(configs))
+	for i, config := range configs {
+		i, config := i, config
+		scope := describeScope(config)
+		tasks = append(tasks, func() error {
+			checks[2*i] = runHealthCheck("sts:GetCallerIdentity", scope, config.STS == nil, func() error {
+				_, err := config.STS.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+				return err
+			})
+			checks[2*i+1] = runHealthCheck("rds:DescribeDBEngineVersions", scope, config.RDS == nil, func() error {
+				_, err := config.RDS.DescribeDBEngineVersions(&rds.DescribeDBEngineVersionsInput{
+					DefaultOnly: aws.Bool(true),
+					MaxRecords:  aws.Int64(20),
+				})
+				return err
+			})
+			return nil
+		})
+	}
+	_ = runPool(scopes.MaxAccountsInFlight*scopes.MaxRegionsPerAccount, tasks)
+	return checks
+}
+
+// runHealthCheck runs a check and returns its result.
+func runHealthCheck(name, scope string, skip bool, check func() error) healthCheck {
+	result := healthCheck{Name: name, Scope: scope, Status: healthStatusOK}
+	if skip {
+		result.Status = healthStatusSkipped
+		return result
+	}
+	start := time.Now()
+	if err := runTask(check); err != nil {
+		result.Status, result.Error = healthStatusFailed, err.Error()
+	}
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
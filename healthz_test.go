@@ -0,0 +1,103 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockSTSAPI struct {
+	stsiface.STSAPI
+	err error
+}
+
+func (m MockSTSAPI) GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: Ptr("123456789012")}, m.err
+}
+
+// TestHealthzHandler tests the healthzHandler function, with and without the deep health check.
+func TestHealthzHandler(t *testing.T) {
+	rdsAPI := &MockRDSAPI{engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{}}}
+	tests := []struct {
+		name       string
+		query      string
+		scopes     *Scopes
+		wantCode   int
+		wantReport healthReport
+	}{
+		{
+			name:       "shallow",
+			scopes:     testScopes(&Config{Region: "eu-west-1", STS: MockSTSAPI{err: errors.New("ExpiredToken")}}),
+			wantCode:   http.StatusOK,
+			wantReport: healthReport{Status: "ok"},
+		},
+		{
+			name:     "deep",
+			query:    "?deep=1",
+			scopes:   testScopes(&Config{Region: "eu-west-1", STS: MockSTSAPI{}, RDS: rdsAPI}),
+			wantCode: http.StatusOK,
+			wantReport: healthReport{Status: "ok", Checks: []healthCheck{
+				{Name: "sts:GetCallerIdentity", Scope: `region "eu-west-1"`, Status: "ok"},
+				{Name: "rds:DescribeDBEngineVersions", Scope: `region "eu-west-1"`, Status: "ok"},
+			}},
+		},
+		{
+			name:  "deep with expired credentials",
+			query: "?deep=1",
+			scopes: testScopes(
+				&Config{Region: "eu-west-1", STS: MockSTSAPI{err: errors.New("ExpiredToken")}, RDS: rdsAPI},
+				&Config{Region: "us-east-1", RDS: rdsAPI},
+			),
+			wantCode: http.StatusServiceUnavailable,
+			wantReport: healthReport{Status: "failed", Checks: []healthCheck{
+				{Name: "sts:GetCallerIdentity", Scope: `region "eu-west-1"`, Status: "failed", Error: "ExpiredToken"},
+				{Name: "rds:DescribeDBEngineVersions", Scope: `region "eu-west-1"`, Status: "ok"},
+				{Name: "sts:GetCallerIdentity", Scope: `region "us-east-1"`, Status: "skipped"},
+				{Name: "rds:DescribeDBEngineVersions", Scope: `region "us-east-1"`, Status: "ok"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			healthzHandler(tt.scopes).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz"+tt.query, nil))
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var report healthReport
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			for i := range report.Checks {
+				report.Checks[i].DurationMs = 0
+			}
+			assert.Equal(t, tt.wantReport, report)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statusIncompatiblePrefix prefixes the statuses of the RDS instances that RDS cannot manage in their current
+// configuration, e.g. "incompatible-parameters" or "incompatible-restore", often caused by a parameter group or an
+// option group mismatching their engine version. They cannot be patched nor upgraded until the state is resolved.
+const statusIncompatiblePrefix = "incompatible-"
+
+// isIncompatible reports whether a status is an incompatible state.
+func isIncompatible(status string) bool {
+	return strings.HasPrefix(status, statusIncompatiblePrefix)
+}
+
+// exportIncompatible sets the IncompatibleStateGauge of an RDS resource to 1 if it is in an incompatible state,
+// labelled by the state. Nothing is exported for the other ones.
+func exportIncompatible(metrics *Metrics, rdsInfo RDSInfo) {
+	if !isIncompatible(rdsInfo.Status) {
+		return
+	}
+	metrics.IncompatibleStateGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"status":             rdsInfo.Status,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(1)
+}
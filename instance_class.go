@@ -0,0 +1,106 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sync"
+)
+
+// orderableKey identifies an instance class for an engine in the orderable options cache.
+type orderableKey struct {
+	Engine        string
+	InstanceClass string
+}
+
+// isInstanceClassOrderable returns true if new instances of the given class can still be created for the engine of an
+// RDS instance, with any of its versions, according to DescribeOrderableDBInstanceOptions. Classes of old instance
+// families such as db.t2 or db.r3 are eventually removed from the orderable options, and block engine upgrades.
+// The engine version of the instance is not queried: a deprecated version has no orderable options at all, whatever
+// the class. Results are cached in the given map, keyed by engine and instance class, which is only read and written
+// with the given lock held.
+func isInstanceClassOrderable(config *Config, rdsInfo RDSInfo, cache map[orderableKey]bool,
+	mu sync.Locker) (bool, error) {
+	key := orderableKey{
+		Engine:        rdsInfo.Engine,
+		InstanceClass: rdsInfo.InstanceClass,
+	}
+	mu.Lock()
+	orderable, ok := cache[key]
+	mu.Unlock()
+	if ok {
+		return orderable, nil
+	}
+
+	options, err := config.RDS.DescribeOrderableDBInstanceOptions(&rds.DescribeOrderableDBInstanceOptionsInput{
+		DBInstanceClass: Ptr(key.InstanceClass),
+		Engine:          Ptr(key.Engine),
+		MaxRecords:      Ptr(int64(20)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe orderable DB instance options; %w", err)
+	}
+
+	orderable = options != nil && len(options.OrderableDBInstanceOptions) > 0
+	mu.Lock()
+	cache[key] = orderable
+	mu.Unlock()
+	return orderable, nil
+}
+
+// exportInstanceClass sets the InstanceClassDeprecatedGauge to 1 if the class of an RDS instance is no longer
+// orderable for its engine, and to 0 otherwise. Clusters and RDS Custom instances, whose Custom Engine Versions have no
+// orderable options, are skipped. The check is optional: if the orderable options cannot be described, the failure is
+// logged, and counted by the AWSErrorsCounter like any other AWS API error, and the instance is skipped rather than
+// failing the snapshot. The orderable options are described without the given lock, which is held while the gauge is
+// set.
+func exportInstanceClass(config *Config, metrics *Metrics, rdsInfo RDSInfo, cache map[orderableKey]bool,
+	mu sync.Locker) {
+	if len(rdsInfo.InstanceClass) == 0 || isRDSCustom(rdsInfo.Engine) {
+		return
+	}
+
+	orderable, err := isInstanceClassOrderable(config, rdsInfo, cache, mu)
+	if err != nil {
+		log.Printf("skip: instance class of %s %q; %v", rdsInfo.Engine, rdsInfo.ClusterIdentifier, err)
+		return
+	}
+
+	value := 0.0
+	if !orderable {
+		value = 1
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	metrics.InstanceClassDeprecatedGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"engine":             rdsInfo.Engine,
+		"engine_version":     rdsInfo.EngineVersion,
+		"instance_class":     rdsInfo.InstanceClass,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(value)
+}
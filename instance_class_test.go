@@ -0,0 +1,91 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestExportInstanceClass tests the exportInstanceClass function.
+func TestExportInstanceClass(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{
+		orderableOutput: []*rds.DescribeOrderableDBInstanceOptionsOutput{{
+			OrderableDBInstanceOptions: []*rds.OrderableDBInstanceOption{
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.32"), DBInstanceClass: Ptr("db.r6g.large")},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+	cache := make(map[orderableKey]bool)
+
+	rdsInfos := []RDSInfo{
+		{ClusterIdentifier: "db-1", Engine: "mysql", EngineVersion: "8.0.32", InstanceClass: "db.r6g.large"},
+		{ClusterIdentifier: "db-2", Engine: "mysql", EngineVersion: "8.0.32", InstanceClass: "db.t2.small"},
+		{ClusterIdentifier: "custom-1", Engine: "custom-oracle-ee", EngineVersion: "19.my_cev1", InstanceClass: "db.m5.xlarge"},
+		{ClusterIdentifier: "cluster-1", Engine: "aurora-mysql", EngineVersion: "8.0.mysql_aurora.3.02.0"},
+	}
+	for _, rdsInfo := range rdsInfos {
+		exportInstanceClass(config, metrics, rdsInfo, cache, &sync.Mutex{})
+	}
+
+	want := `# HELP aws_custom_rds_instance_class_deprecated Whether the class of an instance is no longer orderable for its engine
+# TYPE aws_custom_rds_instance_class_deprecated gauge
+aws_custom_rds_instance_class_deprecated{account_id="",cluster_identifier="db-1",engine="mysql",engine_version="8.0.32",instance_class="db.r6g.large",region=""} 0
+aws_custom_rds_instance_class_deprecated{account_id="",cluster_identifier="db-2",engine="mysql",engine_version="8.0.32",instance_class="db.t2.small",region=""} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.InstanceClassDeprecatedGauge, strings.NewReader(want)))
+	assert.Len(t, cache, 2)
+}
+
+// TestExportInstanceClassDeprecatedVersion tests that the class of an instance on a deprecated engine version, which has
+// no orderable options, is not reported as deprecated if the class is still orderable for the engine.
+func TestExportInstanceClassDeprecatedVersion(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{
+		orderableOutput: []*rds.DescribeOrderableDBInstanceOptionsOutput{{
+			OrderableDBInstanceOptions: []*rds.OrderableDBInstanceOption{
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.32"), DBInstanceClass: Ptr("db.r6g.large")},
+			},
+		}},
+	}}
+	metrics := NewMetrics()
+
+	exportInstanceClass(config, metrics, RDSInfo{ClusterIdentifier: "db-1", Engine: "mysql", EngineVersion: "5.7.38",
+		InstanceClass: "db.r6g.large"}, make(map[orderableKey]bool), &sync.Mutex{})
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.InstanceClassDeprecatedGauge))
+}
+
+// TestExportInstanceClassError tests that an instance whose orderable options cannot be described is skipped.
+func TestExportInstanceClassError(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{err: errors.New("throttled")}}
+	metrics := NewMetrics()
+
+	exportInstanceClass(config, metrics, RDSInfo{ClusterIdentifier: "db-1", Engine: "mysql", EngineVersion: "8.0.32",
+		InstanceClass: "db.r6g.large"}, make(map[orderableKey]bool), &sync.Mutex{})
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.InstanceClassDeprecatedGauge))
+}
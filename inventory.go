@@ -0,0 +1,197 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// inventoryItem is an exported RDS resource, with the catalog status and the upgrade targets of its engine version.
+type inventoryItem struct {
+	RDSInfo
+
+	// Status is the raw catalog status of the engine version, e.g. "available" or "deprecated".
+	Status string
+
+	// UpgradeTargets are the engine versions the resource can be upgraded to.
+	UpgradeTargets []upgradeTarget
+
+	// Deprecated is true if the engine version can no longer be used to create new databases, and InGrace if it was
+	// deprecated less than the grace period ago.
+	Deprecated bool
+	InGrace    bool
+
+	// ForcedUpgrade is the time after which AWS upgrades the resource, if the forced upgrades are checked and it has
+	// one, and StandardSupportEnd the end of the standard support of its engine version, if the support calendar has
+	// it. They are zero otherwise.
+	ForcedUpgrade      time.Time
+	StandardSupportEnd time.Time
+}
+
+// recordInventory records an RDS resource exported by a snapshot in the Inventory of the metrics, along with the
+// catalog status and the upgrade targets of its version, and the upcoming deadlines of its version.
+func recordInventory(config *Config, metrics *Metrics, rdsInfo RDSInfo, m engineVersions, state *snapshotState) {
+	info := m[rdsInfo.Engine][rdsInfo.EngineVersion]
+	deprecated := deprecatedStatuses[info.Status]
+	item := inventoryItem{
+		RDSInfo:        rdsInfo,
+		Status:         info.Status,
+		UpgradeTargets: info.UpgradeTargets,
+		Deprecated:     deprecated,
+		InGrace:        deprecated && metrics.Deprecations.inGrace(rdsInfo),
+	}
+	calendar := config.calendar()
+	if config.ForcedUpgrades {
+		item.ForcedUpgrade, _, _ = forcedUpgradeDeadline(rdsInfo, deprecated, calendar, state.pendingUpgrades)
+	}
+	item.StandardSupportEnd, _ = calendar.standardSupportEnd(rdsInfo.Engine, rdsInfo.EngineVersion)
+	metrics.Inventory.add(item)
+}
+
+// inventory holds the RDS resources exported by the current snapshot, for the outputs that are not metrics, e.g. the
+// email digest. It is safe for concurrent use.
+type inventory struct {
+	mu    sync.Mutex
+	items []inventoryItem
+}
+
+// add records an exported RDS resource.
+func (i *inventory) add(item inventoryItem) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.items = append(i.items, item)
+}
+
+// reset forgets every recorded RDS resource.
+func (i *inventory) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.items = nil
+}
+
+// replace replaces every recorded RDS resource with items at once, after a snapshot.
+func (i *inventory) replace(items []inventoryItem) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.items = items
+}
+
+// list returns a copy of the recorded RDS resources, sorted by account, region and identifier.
+func (i *inventory) list() []inventoryItem {
+	i.mu.Lock()
+	items := append([]inventoryItem(nil), i.items...)
+	i.mu.Unlock()
+	sortInventoryItems(items)
+	return items
+}
+
+// sortInventoryItems sorts inventory items by account, region and identifier.
+func sortInventoryItems(items []inventoryItem) {
+	sort.Slice(items, func(a, b int) bool {
+		if items[a].Account != items[b].Account {
+			return items[a].Account < items[b].Account
+		}
+		if items[a].Region != items[b].Region {
+			return items[a].Region < items[b].Region
+		}
+		return items[a].ClusterIdentifier < items[b].ClusterIdentifier
+	})
+}
+
+// inventoryKey identifies an RDS resource across snapshots. An instance and a cluster may have the same identifier.
+type inventoryKey struct {
+	Account           string
+	Region            string
+	ResourceType      string
+	ClusterIdentifier string
+}
+
+// key returns the inventoryKey of an inventory item.
+func (i inventoryItem) key() inventoryKey {
+	return inventoryKey{i.Account, i.Region, i.ResourceType, i.ClusterIdentifier}
+}
+
+// transition is a change of the engine version or of its status of an RDS resource between two snapshots. Previous is
+// nil for the resources that appeared, and Current is nil for the ones that disappeared.
+type transition struct {
+	Previous *inventoryItem
+	Current  *inventoryItem
+}
+
+// transitionTracker compares the inventories of successive snapshots. It is not safe for concurrent use.
+type transitionTracker struct {
+	previous map[inventoryKey]inventoryItem
+}
+
+// update returns the transitions between the inventory items of the previous call and the given ones, sorted like the
+// items, with the disappeared resources last. The first call returns no transition, as every resource would appear.
+func (t *transitionTracker) update(items []inventoryItem) []transition {
+	current := make(map[inventoryKey]inventoryItem, len(items))
+	for _, item := range items {
+		current[item.key()] = item
+	}
+	previous := t.previous
+	t.previous = current
+	if previous == nil {
+		return nil
+	}
+
+	var transitions []transition
+	for _, item := range items {
+		item := item
+		old, ok := previous[item.key()]
+		switch {
+		case !ok:
+			transitions = append(transitions, transition{Current: &item})
+		case old.EngineVersion != item.EngineVersion || old.Status != item.Status:
+			old := old
+			transitions = append(transitions, transition{Previous: &old, Current: &item})
+		}
+	}
+	var gone []inventoryItem
+	for key, old := range previous {
+		if _, ok := current[key]; !ok {
+			gone = append(gone, old)
+		}
+	}
+	sortInventoryItems(gone)
+	for i := range gone {
+		transitions = append(transitions, transition{Previous: &gone[i]})
+	}
+	return transitions
+}
+
+// revert forgets transitions returned by the last call to update, e.g. the ones that could not be published, so that
+// the next call returns them again, unless the resources changed since.
+func (t *transitionTracker) revert(transitions []transition) {
+	for _, tr := range transitions {
+		if tr.Current != nil {
+			delete(t.previous, tr.Current.key())
+		}
+		if tr.Previous != nil {
+			t.previous[tr.Previous.key()] = *tr.Previous
+		}
+	}
+}
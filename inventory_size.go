@@ -0,0 +1,54 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// exportDiscovered counts the RDS resources of a page in the DiscoveredGauge of their engine, account and region, before
+// the exporter filters them, so that a discovery returning far fewer resources than usual, e.g. after a change of the
+// IAM policy or of the API filters, is told apart from resources excluded on purpose.
+func exportDiscovered(metrics *Metrics, rdsInfos []RDSInfo) {
+	for _, rdsInfo := range rdsInfos {
+		metrics.DiscoveredGauge.With(prometheus.Labels{
+			"engine":     rdsInfo.Engine,
+			"account_id": rdsInfo.Account,
+			"region":     rdsInfo.Region,
+		}).Inc()
+	}
+}
+
+// exportCatalogSizes exports the number of engines of the catalogs of a region in the CatalogEnginesGauge, and the
+// number of versions of each of them in the CatalogVersionsGauge. Only the catalogs of the engines in use are fetched,
+// unless the catalog info is exported.
+func exportCatalogSizes(metrics *Metrics, config *Config, m engineVersions) {
+	account := config.account()
+	metrics.CatalogEnginesGauge.With(prometheus.Labels{"account_id": account, "region": config.Region}).
+		Set(float64(len(m)))
+	for engine, catalog := range m {
+		metrics.CatalogVersionsGauge.With(prometheus.Labels{
+			"engine":     engine,
+			"account_id": account,
+			"region":     config.Region,
+		}).Set(float64(len(catalog)))
+	}
+}
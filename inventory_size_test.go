@@ -0,0 +1,55 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportInventorySize tests that the resources are counted as discovered even if they are filtered, and that the
+// sizes of the catalogs of the region are exported.
+func TestExportInventorySize(t *testing.T) {
+	api := stalenessRDSAPI("orders", "users")
+	api.instancesOutput[0].DBInstances = append(api.instancesOutput[0].DBInstances, &rds.DBInstance{
+		DBInstanceIdentifier: Ptr("legacy"),
+		Engine:               Ptr("postgres"),
+		EngineVersion:        Ptr("14.7"),
+		DBInstanceStatus:     Ptr(statusStopped),
+	})
+	config := &Config{Region: "eu-west-1", Concurrency: 1, ExcludeStopped: true, RDS: api}
+	metrics := NewMetrics()
+
+	assert.NoError(t, snapshot(config, metrics, make(engineVersions)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.DiscoveredGauge.WithLabelValues(
+		"postgres", config.account(), "eu-west-1")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.AvailableTotalGauge.WithLabelValues(
+		"postgres", config.account(), "", "eu-west-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CatalogEnginesGauge.WithLabelValues(
+		config.account(), "eu-west-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CatalogVersionsGauge.WithLabelValues(
+		"postgres", config.account(), "eu-west-1")))
+}
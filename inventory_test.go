@@ -0,0 +1,96 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestTransitionTrackerUpdate tests the update method of transitionTracker.
+func TestTransitionTrackerUpdate(t *testing.T) {
+	orders := inventoryItem{RDSInfo: RDSInfo{ClusterIdentifier: "orders", EngineVersion: "5.7", Region: "eu-west-1"},
+		Status: "available"}
+	ordersDeprecated := orders
+	ordersDeprecated.Status, ordersDeprecated.Deprecated = "deprecated", true
+	ordersUpgraded := orders
+	ordersUpgraded.EngineVersion = "8.0"
+	billing := inventoryItem{RDSInfo: RDSInfo{ClusterIdentifier: "billing", EngineVersion: "15.2", Region: "eu-west-1"},
+		Status: "available"}
+	catalog := inventoryItem{RDSInfo: RDSInfo{ClusterIdentifier: "catalog", EngineVersion: "15.2", Region: "us-east-1"},
+		Status: "available"}
+
+	tracker := transitionTracker{}
+	assert.Nil(t, tracker.update([]inventoryItem{orders, catalog}), "first snapshot")
+	assert.Nil(t, tracker.update([]inventoryItem{orders, catalog}), "no change")
+	assert.Equal(t, []transition{
+		{Current: &billing},
+		{Previous: &orders, Current: &ordersDeprecated},
+		{Previous: &catalog},
+	}, tracker.update([]inventoryItem{billing, ordersDeprecated}), "status change, appearance and disappearance")
+	assert.Equal(t, []transition{
+		{Previous: &ordersDeprecated, Current: &ordersUpgraded},
+	}, tracker.update([]inventoryItem{billing, ordersUpgraded}), "upgrade")
+
+	// an instance and a cluster may have the same identifier
+	billingCluster := billing
+	billingCluster.ResourceType = resourceTypeCluster
+	assert.Equal(t, []transition{
+		{Current: &billingCluster},
+	}, tracker.update([]inventoryItem{billing, billingCluster, ordersUpgraded}), "same identifier")
+
+	// the reverted transitions are returned again
+	transitions := tracker.update([]inventoryItem{billing, ordersUpgraded})
+	assert.Equal(t, []transition{{Previous: &billingCluster}}, transitions)
+	tracker.revert(transitions)
+	assert.Equal(t, transitions, tracker.update([]inventoryItem{billing, ordersUpgraded}), "reverted")
+}
+
+// TestRecordInventory tests that recordInventory records the upcoming deadlines of the version of a resource: the end
+// of its standard support, and the forced upgrade of the deprecated versions or of the pending upgrades.
+func TestRecordInventory(t *testing.T) {
+	m := engineVersions{"postgres": {"11.19": {Status: "deprecated"}, "12.14": {Status: "available"}}}
+	end11 := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	end12 := time.Date(2024, 11, 14, 0, 0, 0, 0, time.UTC)
+	pending := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := &Config{ForcedUpgrades: true, Calendar: supportCalendar{
+		{Engines: []string{"postgres"}, Version: "11", StandardSupportEnd: end11},
+		{Engines: []string{"postgres"}, Version: "12", StandardSupportEnd: end12},
+	}}
+	state := newSnapshotState()
+	state.pendingUpgrades = map[string]time.Time{"db:inventory": pending}
+	metrics := NewMetrics()
+
+	search := RDSInfo{ClusterIdentifier: "search", Engine: "postgres", EngineVersion: "11.19"}
+	inventory := RDSInfo{ClusterIdentifier: "inventory", Engine: "postgres", EngineVersion: "12.14"}
+	catalog := RDSInfo{ClusterIdentifier: "catalog", Engine: "postgres", EngineVersion: "12.14"}
+	for _, rdsInfo := range []RDSInfo{search, inventory, catalog} {
+		recordInventory(config, metrics, rdsInfo, m, state)
+	}
+	assert.Equal(t, []inventoryItem{
+		{RDSInfo: catalog, Status: "available", StandardSupportEnd: end12},
+		{RDSInfo: inventory, Status: "available", ForcedUpgrade: pending, StandardSupportEnd: end12},
+		{RDSInfo: search, Status: "deprecated", Deprecated: true, ForcedUpgrade: end11, StandardSupportEnd: end11},
+	}, metrics.Inventory.list())
+}
@@ -0,0 +1,283 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The formats of the records published on Kafka: JSON, or Avro, encoded by the REST Proxy with the schemas of the
+// exporter.
+const (
+	kafkaFormatJSON = "json"
+	kafkaFormatAvro = "avro"
+)
+
+// kafkaMaxRecords is the maximum number of records produced by each request to the REST Proxy.
+const kafkaMaxRecords = 500
+
+// kafkaResourceSchema and kafkaStatusChangeSchema are the Avro schemas of the records of the inventory and of the
+// transitions, registered by the REST Proxy in the Schema Registry of the cluster.
+const (
+	kafkaResourceSchema = `{"type":"record","name":"Resource","namespace":"rds_engine_version","fields":[` +
+		`{"name":"time","type":"string"},{"name":"account","type":"string"},{"name":"region","type":"string"},` +
+		`{"name":"identifier","type":"string"},{"name":"engine","type":"string"},` +
+		`{"name":"engine_version","type":"string"},{"name":"status","type":"string"},` +
+		`{"name":"deprecated","type":"boolean"},{"name":"role","type":"string"},{"name":"member_of","type":"string"},` +
+		`{"name":"instance_class","type":"string"},{"name":"maintenance_window","type":"string"},` +
+		`{"name":"upgrade_targets","type":{"type":"array","items":"string"}},` +
+		`{"name":"tags","type":{"type":"map","values":"string"}}]}`
+	kafkaStatusChangeSchema = `{"type":"record","name":"StatusChange","namespace":"rds_engine_version","fields":[` +
+		`{"name":"time","type":"string"},{"name":"account","type":"string"},{"name":"region","type":"string"},` +
+		`{"name":"identifier","type":"string"},{"name":"engine","type":"string"},` +
+		`{"name":"previous_engine_version","type":"string"},{"name":"engine_version","type":"string"},` +
+		`{"name":"previous_status","type":"string"},{"name":"status","type":"string"},` +
+		`{"name":"deprecated","type":"boolean"},{"name":"upgrade_targets","type":{"type":"array","items":"string"}}]}`
+)
+
+// kafkaResource is the record of a resource of the inventory. Unlike the rows of the reports, its fields are never
+// omitted, as the Avro records require all of them.
+type kafkaResource struct {
+	Time              string            `json:"time"`
+	Account           string            `json:"account"`
+	Region            string            `json:"region"`
+	Identifier        string            `json:"identifier"`
+	Engine            string            `json:"engine"`
+	EngineVersion     string            `json:"engine_version"`
+	Status            string            `json:"status"`
+	Deprecated        bool              `json:"deprecated"`
+	Role              string            `json:"role"`
+	MemberOf          string            `json:"member_of"`
+	InstanceClass     string            `json:"instance_class"`
+	MaintenanceWindow string            `json:"maintenance_window"`
+	UpgradeTargets    []string          `json:"upgrade_targets"`
+	Tags              map[string]string `json:"tags"`
+}
+
+// kafkaRecord is a record produced through the REST Proxy, keyed by account, region and identifier, so that the
+// records of a resource are kept in order in a partition.
+type kafkaRecord struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// kafkaPublisher publishes the inventory of each snapshot, and the transitions between successive snapshots, on Kafka
+// topics through a Confluent REST Proxy, or a compatible HTTP proxy, e.g. the one of Redpanda, to feed the CMDB and the
+// data warehouse pipelines.
+type kafkaPublisher struct {
+	client           *http.Client
+	url              string
+	inventoryTopic   string
+	transitionsTopic string
+	format           string
+	tracker          transitionTracker
+}
+
+// newKafkaPublisher returns the kafkaPublisher configured by the options, or nil if no REST Proxy is configured.
+func newKafkaPublisher(options *Options) *kafkaPublisher {
+	if options.KafkaRESTURL == "" {
+		return nil
+	}
+	return &kafkaPublisher{
+		client:           &http.Client{Timeout: options.AwsApiTimeout},
+		url:              options.KafkaRESTURL,
+		inventoryTopic:   options.KafkaInventoryTopic,
+		transitionsTopic: options.KafkaTransitionsTopic,
+		format:           options.KafkaFormat,
+	}
+}
+
+// validateKafka checks that the REST Proxy URL is an HTTP(S) URL, that a topic is set, and that the format is
+// supported.
+func (o *Options) validateKafka() []string {
+	if o.KafkaRESTURL == "" {
+		if o.KafkaInventoryTopic != "" || o.KafkaTransitionsTopic != "" {
+			return []string{"kafka topics require a kafka REST URL"}
+		}
+		return nil
+	}
+	var problems []string
+	if u, err := url.Parse(o.KafkaRESTURL); !isSecretReference(o.KafkaRESTURL) &&
+		(err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		problems = append(problems, fmt.Sprintf("kafka REST URL should be an http or https URL, got %q",
+			describePolicyURL(o.KafkaRESTURL)))
+	}
+	if o.KafkaInventoryTopic == "" && o.KafkaTransitionsTopic == "" {
+		problems = append(problems, "kafka REST URL requires an inventory or a transitions topic")
+	}
+	if o.KafkaFormat != kafkaFormatJSON && o.KafkaFormat != kafkaFormatAvro {
+		problems = append(problems, fmt.Sprintf("kafka format should be either %q or %q, got %q", kafkaFormatJSON,
+			kafkaFormatAvro, o.KafkaFormat))
+	}
+	return problems
+}
+
+// publish produces a record per resource of the inventory on the inventory topic, and a record per transition between
+// the inventory of the previous call and the given one on the transitions topic, if they are set. The first call
+// produces no transition. The transitions that could not be produced are produced again by the next call, unless the
+// resources changed since, and the inventory topic is produced even if they could not be. Nothing is done if p is nil.
+func (p *kafkaPublisher) publish(inv *inventory, now time.Time) error {
+	if p == nil {
+		return nil
+	}
+	items := inv.list()
+	timestamp := now.UTC().Format(time.RFC3339)
+	var failures []string
+	if p.transitionsTopic != "" {
+		transitions := p.tracker.update(items)
+		var records []kafkaRecord
+		for _, t := range transitions {
+			event := newStatusChangeEvent(t)
+			records = append(records, kafkaRecord{
+				Key:   event.Account + "/" + event.Region + "/" + event.Identifier,
+				Value: timedStatusChange{Time: timestamp, statusChangeEvent: event},
+			})
+		}
+		if produced, err := p.produce(p.transitionsTopic, kafkaStatusChangeSchema, records); err != nil {
+			p.tracker.revert(transitions[produced:])
+			failures = append(failures, err.Error())
+		}
+	}
+	if p.inventoryTopic != "" {
+		records := make([]kafkaRecord, 0, len(items))
+		for _, item := range items {
+			row := newReportRow(item)
+			tags := row.Tags
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			records = append(records, kafkaRecord{
+				Key: row.Account + "/" + row.Region + "/" + row.Identifier,
+				Value: kafkaResource{
+					Time:              timestamp,
+					Account:           row.Account,
+					Region:            row.Region,
+					Identifier:        row.Identifier,
+					Engine:            row.Engine,
+					EngineVersion:     row.EngineVersion,
+					Status:            row.Status,
+					Deprecated:        row.Deprecated,
+					Role:              row.Role,
+					MemberOf:          row.MemberOf,
+					InstanceClass:     row.InstanceClass,
+					MaintenanceWindow: row.MaintenanceWindow,
+					UpgradeTargets:    row.UpgradeTargets,
+					Tags:              tags,
+				},
+			})
+		}
+		if _, err := p.produce(p.inventoryTopic, kafkaResourceSchema, records); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// produce sends the records to a topic through the REST Proxy, in batches of at most 500 records, along with the Avro
+// schema of their values in the Avro format. It returns the number of records produced before the first batch that
+// failed, whose records may have been partly produced.
+func (p *kafkaPublisher) produce(topic, schema string, records []kafkaRecord) (int, error) {
+	produced := 0
+	for len(records) > 0 {
+		n := len(records)
+		if n > kafkaMaxRecords {
+			n = kafkaMaxRecords
+		}
+		if err := p.produceBatch(topic, schema, records[:n]); err != nil {
+			return produced, err
+		}
+		records, produced = records[n:], produced+n
+	}
+	return produced, nil
+}
+
+// produceBatch sends a batch of records to a topic, and checks that each of them was produced.
+func (p *kafkaPublisher) produceBatch(topic, schema string, records []kafkaRecord) error {
+	request := struct {
+		KeySchema   string        `json:"key_schema,omitempty"`
+		ValueSchema string        `json:"value_schema,omitempty"`
+		Records     []kafkaRecord `json:"records"`
+	}{Records: records}
+	contentType := "application/vnd.kafka.json.v2+json"
+	if p.format == kafkaFormatAvro {
+		request.KeySchema, request.ValueSchema = `"string"`, schema
+		contentType = "application/vnd.kafka.avro.v2+json"
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Kafka records; %w", err)
+	}
+	endpoint, err := url.JoinPath(p.url, "topics", topic)
+	if err != nil {
+		return fmt.Errorf("failed to produce records to Kafka topic %s; %w", topic, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka REST Proxy request; %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce records to Kafka topic %s; %w", topic, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to produce records to Kafka topic %s; unexpected status %s: %s", topic, resp.Status,
+			bytes.TrimSpace(data))
+	}
+	// the records that could not be produced, e.g. too large, have an error in the offsets of the response
+	var response struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("failed to parse Kafka REST Proxy response; %w", err)
+	}
+	failed, first := 0, ""
+	for _, offset := range response.Offsets {
+		if offset.Error != "" {
+			if failed == 0 {
+				first = offset.Error
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to produce %d of %d records to Kafka topic %s; %s", failed, len(records), topic, first)
+	}
+	return nil
+}
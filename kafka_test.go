@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// kafkaRequest is a request received by the fake REST Proxy.
+type kafkaRequest struct {
+	path        string
+	contentType string
+	body        map[string]interface{}
+}
+
+// TestKafkaPublisherPublish tests the records of the inventory and of the transitions, in the JSON and Avro formats,
+// and that the transitions that could not be produced are reported and produced again by the next call, without holding
+// back the inventory.
+func TestKafkaPublisherPublish(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	item := inventoryItem{
+		RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+			Region: "eu-west-1", Account: "111111111111"},
+		Status: "available",
+	}
+	tests := []struct {
+		name            string
+		format          string
+		response        string
+		failTransitions bool
+		wantContentType string
+		wantErr         string
+	}{
+		{
+			name:            "json",
+			format:          kafkaFormatJSON,
+			response:        `{"offsets": [{"partition": 0, "offset": 1}]}`,
+			wantContentType: "application/vnd.kafka.json.v2+json",
+		},
+		{
+			name:            "avro",
+			format:          kafkaFormatAvro,
+			response:        `{"key_schema_id": 1, "value_schema_id": 2, "offsets": [{"partition": 0, "offset": 1}]}`,
+			wantContentType: "application/vnd.kafka.avro.v2+json",
+		},
+		{
+			name:            "failed records",
+			format:          kafkaFormatJSON,
+			response:        `{"offsets": [{"partition": 0, "offset": 1}]}`,
+			failTransitions: true,
+			wantContentType: "application/vnd.kafka.json.v2+json",
+			wantErr:         "failed to produce 1 of 1 records to Kafka topic rds.transitions; record too large",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []kafkaRequest
+			failTransitions := tt.failTransitions
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				request := kafkaRequest{path: r.URL.Path, contentType: r.Header.Get("Content-Type")}
+				_ = json.Unmarshal(data, &request.body)
+				requests = append(requests, request)
+				if failTransitions && r.URL.Path == "/kafka/topics/rds.transitions" {
+					_, _ = w.Write([]byte(`{"offsets": [{"error_code": 50002, "error": "record too large"}]}`))
+					return
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			p := &kafkaPublisher{client: server.Client(), url: server.URL + "/kafka", inventoryTopic: "rds.inventory",
+				transitionsTopic: "rds.transitions", format: tt.format}
+			inv := &inventory{}
+			inv.add(item)
+			assert.NoError(t, p.publish(inv, now))
+			if assert.Len(t, requests, 1, "first snapshot") {
+				assert.Equal(t, "/kafka/topics/rds.inventory", requests[0].path)
+				assert.Equal(t, tt.wantContentType, requests[0].contentType)
+				records, _ := json.Marshal(requests[0].body["records"])
+				assert.JSONEq(t, `[{"key": "111111111111/eu-west-1/orders", "value": {"time": "2023-05-10T14:37:00Z",
+					"account": "111111111111", "region": "eu-west-1", "identifier": "orders", "engine": "postgres",
+					"engine_version": "11.4", "status": "available", "deprecated": false, "role": "",
+					"member_of": "", "instance_class": "", "maintenance_window": "", "upgrade_targets": [],
+					"tags": {}}}]`, string(records))
+				if tt.format == kafkaFormatAvro {
+					assert.Equal(t, kafkaResourceSchema, requests[0].body["value_schema"])
+				} else {
+					assert.NotContains(t, requests[0].body, "value_schema")
+				}
+			}
+
+			requests = nil
+			deprecated := item
+			deprecated.Status, deprecated.Deprecated = "deprecated", true
+			inv.reset()
+			inv.add(deprecated)
+			err := p.publish(inv, now.Add(time.Hour))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				if assert.Len(t, requests, 2) {
+					assert.Equal(t, "/kafka/topics/rds.inventory", requests[1].path)
+				}
+				requests, failTransitions = nil, false
+				err = p.publish(inv, now.Add(time.Hour))
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, requests, 2) {
+				assert.Equal(t, "/kafka/topics/rds.transitions", requests[0].path)
+				records, _ := json.Marshal(requests[0].body["records"])
+				assert.JSONEq(t, `[{"key": "111111111111/eu-west-1/orders", "value": {"time": "2023-05-10T15:37:00Z",
+					"account": "111111111111", "region": "eu-west-1", "identifier": "orders", "engine": "postgres",
+					"previous_engine_version": "11.4", "engine_version": "11.4", "previous_status": "available",
+					"status": "deprecated", "deprecated": true, "upgrade_targets": []}}]`, string(records))
+				if tt.format == kafkaFormatAvro {
+					assert.Equal(t, kafkaStatusChangeSchema, requests[0].body["value_schema"])
+					assert.Equal(t, `"string"`, requests[0].body["key_schema"])
+				}
+			}
+		})
+	}
+}
+
+// TestKafkaSchemas tests that the Avro schemas are valid JSON, with a field per JSON field of the records.
+func TestKafkaSchemas(t *testing.T) {
+	for schema, record := range map[string]interface{}{
+		kafkaResourceSchema:     kafkaResource{},
+		kafkaStatusChangeSchema: timedStatusChange{},
+	} {
+		var parsed struct {
+			Fields []struct {
+				Name string `json:"name"`
+			} `json:"fields"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(schema), &parsed))
+		data, err := json.Marshal(record)
+		assert.NoError(t, err)
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &fields))
+		assert.Len(t, parsed.Fields, len(fields))
+		for _, field := range parsed.Fields {
+			assert.Contains(t, fields, field.Name)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// kubernetesGroupVersion and kubernetesResource identify the RDSVersionExporterConfig custom resources.
+	kubernetesGroupVersion = "rds-exporter.alexandremahdhaoui.io/v1alpha1"
+	kubernetesResource     = "rdsversionexporterconfigs"
+
+	// serviceAccountDir holds the credentials of the service account of the pod.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// kubernetesWatchTimeout bounds each watch, after which the custom resource is read and watched again, and
+	// kubernetesRetryDelay is the delay before reading it again after a failure.
+	kubernetesWatchTimeout   = 5 * time.Minute
+	kubernetesRetryDelay     = 10 * time.Second
+	kubernetesRequestTimeout = 30 * time.Second
+)
+
+// The phases of an RDSVersionExporterConfig.
+const (
+	kubernetesPhasePending = "Pending"
+	kubernetesPhaseReady   = "Ready"
+	kubernetesPhaseError   = "Error"
+)
+
+// exporterConfigSpec is the spec of an RDSVersionExporterConfig: the accounts, regions and filters to scan. It
+// replaces the Regions, ExcludeRegions, AssumeRoles, TagFilters and APIFilters of the options.
+type exporterConfigSpec struct {
+	Regions        []string   `json:"regions"`
+	ExcludeRegions []string   `json:"excludeRegions"`
+	AssumeRoles    []string   `json:"assumeRoles"`
+	TagFilters     string     `json:"tagFilters"`
+	APIFilters     apiFilters `json:"apiFilters"`
+}
+
+// exporterConfigStatus is the status of an RDSVersionExporterConfig: whether its spec is applied, and the outcome of
+// the last snapshot.
+type exporterConfigStatus struct {
+	ObservedGeneration int64      `json:"observedGeneration"`
+	Phase              string     `json:"phase"`
+	Message            string     `json:"message"`
+	Accounts           int        `json:"accounts"`
+	Regions            int        `json:"regions"`
+	Resources          int        `json:"resources"`
+	LastSnapshotTime   *time.Time `json:"lastSnapshotTime,omitempty"`
+}
+
+// exporterConfig is an RDSVersionExporterConfig custom resource.
+type exporterConfig struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+		Generation      int64  `json:"generation"`
+	} `json:"metadata"`
+	Spec exporterConfigSpec `json:"spec"`
+}
+
+// kubeClient calls the Kubernetes API with the credentials of a service account.
+type kubeClient struct {
+	baseURL string
+
+	// tokenFile holds the bearer token of the service account. It is read before each request, as the projected tokens
+	// are rotated. No token is sent if it is empty.
+	tokenFile string
+
+	client *http.Client
+}
+
+// newInClusterKubeClient returns a kubeClient calling the Kubernetes API of the cluster the exporter runs in, with the
+// service account of its pod.
+func newInClusterKubeClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and " +
+			"KUBERNETES_SERVICE_PORT should be set")
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Kubernetes CA; %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificate found in the Kubernetes CA")
+	}
+	return &kubeClient{
+		baseURL:   "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+		client: &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}},
+	}, nil
+}
+
+// resourcePath returns the path of the RDSVersionExporterConfigs of a namespace.
+func resourcePath(namespace string) string {
+	return fmt.Sprintf("/apis/%s/namespaces/%s/%s", kubernetesGroupVersion, url.PathEscape(namespace),
+		kubernetesResource)
+}
+
+// do sends a request to the Kubernetes API, and returns its response if its status is 2xx.
+func (c *kubeClient) do(method, path string, body []byte, contentType string, timeout time.Duration) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the service account token; %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	client := *c.client
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+// get reads an RDSVersionExporterConfig.
+func (c *kubeClient) get(namespace, name string) (*exporterConfig, error) {
+	resp, err := c.do(http.MethodGet, resourcePath(namespace)+"/"+url.PathEscape(name), nil, "",
+		kubernetesRequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s/%s; %w", kubernetesResource, namespace, name, err)
+	}
+	defer resp.Body.Close()
+	var config exporterConfig
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s %s/%s; %w", kubernetesResource, namespace, name, err)
+	}
+	return &config, nil
+}
+
+// watch calls handle with the RDSVersionExporterConfig each time it is added or modified after the given resource
+// version, until the watch times out, in which case it returns nil, or fails.
+func (c *kubeClient) watch(namespace, name, resourceVersion string, handle func(*exporterConfig)) error {
+	query := url.Values{
+		"watch":           {"1"},
+		"fieldSelector":   {"metadata.name=" + name},
+		"resourceVersion": {resourceVersion},
+		"timeoutSeconds":  {fmt.Sprint(int(kubernetesWatchTimeout.Seconds()))},
+	}
+	resp, err := c.do(http.MethodGet, resourcePath(namespace)+"?"+query.Encode(), nil, "", 0)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s %s/%s; %w", kubernetesResource, namespace, name, err)
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to watch %s %s/%s; %w", kubernetesResource, namespace, name, err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			var config exporterConfig
+			if err := json.Unmarshal(event.Object, &config); err != nil {
+				return fmt.Errorf("failed to parse %s %s/%s; %w", kubernetesResource, namespace, name, err)
+			}
+			handle(&config)
+		case "DELETED":
+			log.Printf("%s %s/%s was deleted, keeping its last configuration", kubernetesResource, namespace, name)
+		case "ERROR":
+			// e.g. the resource version is too old: read the resource again
+			return fmt.Errorf("failed to watch %s %s/%s; %s", kubernetesResource, namespace, name, event.Object)
+		}
+	}
+}
+
+// patchStatus replaces the status of an RDSVersionExporterConfig.
+func (c *kubeClient) patchStatus(namespace, name string, status exporterConfigStatus) error {
+	body, err := json.Marshal(map[string]exporterConfigStatus{"status": status})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPatch, resourcePath(namespace)+"/"+url.PathEscape(name)+"/status", body,
+		"application/merge-patch+json", kubernetesRequestTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to update the status of %s %s/%s; %w", kubernetesResource, namespace, name, err)
+	}
+	return resp.Body.Close()
+}
+
+// kubernetesController follows an RDSVersionExporterConfig: it builds the Scopes of its spec, sends them to the
+// snapshot loop each time its generation changes, and writes whether its spec is applied and the outcome of the
+// snapshots to its status. A nil kubernetesController does nothing.
+type kubernetesController struct {
+	client          *kubeClient
+	namespace, name string
+
+	// applyMu serializes the changes of the spec and of the options, guarded by it along with the last applied spec.
+	applyMu sync.Mutex
+	options *Options
+	spec    exporterConfigSpec
+
+	// pending holds the Scopes of the last generation, until the snapshot loop takes them.
+	pending chan *Scopes
+
+	mu     sync.Mutex
+	status exporterConfigStatus
+	// configErr is the error of the last generation, whose Scopes could not be built. It is reported until a later
+	// generation is applied.
+	configErr error
+}
+
+// newKubernetesController returns the kubernetesController of the RDSVersionExporterConfig of the options, or nil if
+// there is none.
+func newKubernetesController(options *Options) (*kubernetesController, error) {
+	if options.KubernetesConfig == "" {
+		return nil, nil
+	}
+	client, err := newInClusterKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace, name := options.kubernetesNamespace, options.kubernetesName
+	if namespace == "" {
+		b, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the namespace of the pod; %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+	return &kubernetesController{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		options:   options,
+		pending:   make(chan *Scopes, 1),
+	}, nil
+}
+
+// specScopes builds the Scopes of a spec, with the other options unchanged.
+func specScopes(o *Options, spec exporterConfigSpec) (*Scopes, error) {
+	options := *o
+	options.Regions = strings.Join(spec.Regions, ",")
+	options.ExcludeRegions = strings.Join(spec.ExcludeRegions, ",")
+	options.AssumeRoles = strings.Join(spec.AssumeRoles, ",")
+	options.TagFilters = spec.TagFilters
+	options.APIFilters = spec.APIFilters
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	return NewScopes(&options)
+}
+
+// load reads the RDSVersionExporterConfig and returns the Scopes of its spec, for the first snapshots.
+func (c *kubernetesController) load() (*Scopes, error) {
+	config, err := c.client.get(c.namespace, c.name)
+	if err != nil {
+		return nil, err
+	}
+	c.applyMu.Lock()
+	scopes, err := specScopes(c.options, config.Spec)
+	if err == nil {
+		c.spec = config.Spec
+	}
+	c.applyMu.Unlock()
+	c.setConfig(config.Metadata.Generation, scopes, err)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s/%s; %w", kubernetesResource, c.namespace, c.name, err)
+	}
+	return scopes, nil
+}
+
+// run watches the RDSVersionExporterConfig, and applies each new generation of its spec. It never returns.
+func (c *kubernetesController) run() {
+	for {
+		config, err := c.client.get(c.namespace, c.name)
+		if err == nil {
+			c.apply(config)
+			err = c.client.watch(c.namespace, c.name, config.Metadata.ResourceVersion, c.apply)
+		}
+		if err != nil {
+			log.Print(err)
+			time.Sleep(kubernetesRetryDelay)
+		}
+	}
+}
+
+// apply builds the Scopes of a new generation of the RDSVersionExporterConfig, and hands them over to the snapshot
+// loop, replacing the ones it has not taken yet. The Scopes in use are kept if they cannot be built.
+func (c *kubernetesController) apply(config *exporterConfig) {
+	c.mu.Lock()
+	applied := config.Metadata.Generation == c.status.ObservedGeneration
+	c.mu.Unlock()
+	if applied {
+		return
+	}
+	c.applyMu.Lock()
+	scopes, err := specScopes(c.options, config.Spec)
+	if err != nil {
+		log.Printf("invalid %s %s/%s, keeping the previous configuration; %v", kubernetesResource, c.namespace,
+			c.name, err)
+	} else {
+		c.spec = config.Spec
+		c.handOver(scopes)
+	}
+	c.applyMu.Unlock()
+	c.setConfig(config.Metadata.Generation, scopes, err)
+}
+
+// reconfigure applies the last applied spec to new options, e.g. those of a reloaded configuration file, and hands
+// the Scopes over to the snapshot loop. The options are kept unchanged if the Scopes cannot be built.
+func (c *kubernetesController) reconfigure(options *Options) error {
+	c.applyMu.Lock()
+	defer c.applyMu.Unlock()
+	scopes, err := specScopes(options, c.spec)
+	if err != nil {
+		return err
+	}
+	c.options = options
+	c.handOver(scopes)
+	return nil
+}
+
+// handOver hands Scopes over to the snapshot loop, replacing the ones it has not taken yet.
+func (c *kubernetesController) handOver(scopes *Scopes) {
+	select {
+	case <-c.pending:
+	default:
+	}
+	c.pending <- scopes
+}
+
+// setConfig records the outcome of applying a generation, and writes it to the status.
+func (c *kubernetesController) setConfig(generation int64, scopes *Scopes, err error) {
+	c.mu.Lock()
+	c.status.ObservedGeneration = generation
+	c.configErr = err
+	if err == nil {
+		c.status.Phase, c.status.Message = kubernetesPhasePending, ""
+		c.status.Accounts, c.status.Regions = len(scopes.Accounts), len(scopes.configs())
+	} else {
+		c.status.Phase, c.status.Message = kubernetesPhaseError, err.Error()
+	}
+	status := c.status
+	c.mu.Unlock()
+	c.writeStatus(status)
+}
+
+// updates returns the channel of the Scopes of the new generations of the spec, which never receives anything if c is
+// nil.
+func (c *kubernetesController) updates() <-chan *Scopes {
+	if c == nil {
+		return nil
+	}
+	return c.pending
+}
+
+// report writes the outcome of a snapshot started at the given time to the status, unless the last generation of the
+// spec could not be applied.
+func (c *kubernetesController) report(start time.Time, resources int, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.status.Resources = resources
+	c.status.LastSnapshotTime = &start
+	switch {
+	case c.configErr != nil:
+	case err != nil:
+		c.status.Phase, c.status.Message = kubernetesPhaseError, err.Error()
+	default:
+		c.status.Phase, c.status.Message = kubernetesPhaseReady, ""
+	}
+	status := c.status
+	c.mu.Unlock()
+	c.writeStatus(status)
+}
+
+// writeStatus writes the status of the RDSVersionExporterConfig. Failures are logged only.
+func (c *kubernetesController) writeStatus(status exporterConfigStatus) {
+	if err := c.client.patchStatus(c.namespace, c.name, status); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,154 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKubernetesAPI serves an RDSVersionExporterConfig, the events of its watch, and records the patches of its status.
+type fakeKubernetesAPI struct {
+	config string
+	events []string
+
+	mu       sync.Mutex
+	statuses []exporterConfigStatus
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := resourcePath("monitoring")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == path+"/exporter":
+		_, _ = io.WriteString(w, f.config)
+	case r.Method == http.MethodGet && r.URL.Path == path && r.URL.Query().Get("watch") == "1":
+		for _, event := range f.events {
+			_, _ = io.WriteString(w, event+"\n")
+		}
+	case r.Method == http.MethodPatch && r.URL.Path == path+"/exporter/status" &&
+		r.Header.Get("Content-Type") == "application/merge-patch+json":
+		var patch struct {
+			Status exporterConfigStatus `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.statuses = append(f.statuses, patch.Status)
+		f.mu.Unlock()
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// lastStatus returns the last status written.
+func (f *fakeKubernetesAPI) lastStatus() exporterConfigStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statuses[len(f.statuses)-1]
+}
+
+// newTestKubernetesController returns a kubernetesController of the demo, following the "monitoring/exporter"
+// RDSVersionExporterConfig of the fake API.
+func newTestKubernetesController(t *testing.T, api *fakeKubernetesAPI) *kubernetesController {
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	options := defaultOptions()
+	options.Demo, options.ServerPort, options.KubernetesConfig = true, 2112, "monitoring/exporter"
+	assert.NoError(t, options.validate())
+	return &kubernetesController{
+		client:    &kubeClient{baseURL: server.URL, client: server.Client()},
+		namespace: options.kubernetesNamespace,
+		name:      options.kubernetesName,
+		options:   options,
+		pending:   make(chan *Scopes, 1),
+	}
+}
+
+// exporterConfigJSON returns an RDSVersionExporterConfig of the given generation and spec.
+func exporterConfigJSON(generation int, spec string) string {
+	return fmt.Sprintf(`{"metadata":{"name":"exporter","namespace":"monitoring","resourceVersion":"%d",`+
+		`"generation":%d},"spec":%s}`, 100+generation, generation, spec)
+}
+
+// TestKubernetesController tests that the spec of the RDSVersionExporterConfig replaces the regions of the options,
+// that each new generation is handed over to the snapshot loop unless it is invalid, and that the outcome is written
+// to the status.
+func TestKubernetesController(t *testing.T) {
+	api := &fakeKubernetesAPI{
+		config: exporterConfigJSON(1, `{"regions":["us-east-1"]}`),
+		events: []string{
+			`{"type":"MODIFIED","object":` + exporterConfigJSON(1, `{"regions":["us-east-1"]}`) + `}`,
+			`{"type":"MODIFIED","object":` + exporterConfigJSON(2,
+				`{"regions":["all"],"excludeRegions":["us-east-1"]}`) + `}`,
+			`{"type":"MODIFIED","object":` + exporterConfigJSON(3, `{"excludeRegions":["us-east-1"]}`) + `}`,
+		},
+	}
+	controller := newTestKubernetesController(t, api)
+
+	scopes, err := controller.load()
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", scopes.configs()[0].Region)
+	assert.Equal(t, exporterConfigStatus{ObservedGeneration: 1, Phase: kubernetesPhasePending, Accounts: 1,
+		Regions: 1}, api.lastStatus())
+
+	start := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	controller.report(start, 16, nil)
+	assert.Equal(t, exporterConfigStatus{ObservedGeneration: 1, Phase: kubernetesPhaseReady, Accounts: 1, Regions: 1,
+		Resources: 16, LastSnapshotTime: &start}, api.lastStatus())
+
+	assert.NoError(t, controller.client.watch("monitoring", "exporter", "101", controller.apply))
+	// the second generation is pending, the third one is invalid
+	scopes = <-controller.updates()
+	assert.Len(t, scopes.configs(), 1)
+	assert.Equal(t, "eu-west-1", scopes.configs()[0].Region)
+	assert.Len(t, controller.updates(), 0)
+	status := api.lastStatus()
+	assert.Equal(t, int64(3), status.ObservedGeneration)
+	assert.Equal(t, kubernetesPhaseError, status.Phase)
+	assert.True(t, strings.Contains(status.Message, "excluded regions require regions"), status.Message)
+
+	// the snapshots of the previous generation do not hide the error
+	controller.report(start, 8, nil)
+	assert.Equal(t, kubernetesPhaseError, api.lastStatus().Phase)
+	assert.Equal(t, 8, api.lastStatus().Resources)
+}
+
+// TestKubernetesControllerNil tests that a nil kubernetesController does nothing.
+func TestKubernetesControllerNil(t *testing.T) {
+	controller, err := newKubernetesController(defaultOptions())
+	assert.NoError(t, err)
+	assert.Nil(t, controller)
+	assert.Nil(t, controller.updates())
+	controller.report(time.Now(), 0, nil)
+}
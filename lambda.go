@@ -0,0 +1,231 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lambdaRuntimeAPIEnvName is the environment variable holding the host and port of the Lambda Runtime API, set by AWS
+// Lambda in the execution environments of the custom runtimes.
+const lambdaRuntimeAPIEnvName = "AWS_LAMBDA_RUNTIME_API"
+
+// lambdaRuntime is a client of the Lambda Runtime API, which the custom runtimes poll for the invocations of the
+// function, and to which they send their response or their error.
+type lambdaRuntime struct {
+	base   string
+	client *http.Client
+}
+
+// newLambdaRuntime returns the lambdaRuntime of the Lambda Runtime API at api, e.g. "127.0.0.1:9001". Its client has no
+// timeout, as polling for the next invocation blocks until there is one.
+func newLambdaRuntime(api string) *lambdaRuntime {
+	return &lambdaRuntime{base: "http://" + api + "/2018-06-01/runtime", client: &http.Client{}}
+}
+
+// lambdaError is the error of an invocation, or of the initialization, sent to the Lambda Runtime API.
+type lambdaError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+// next waits for the next invocation, and returns its request ID. The event of the invocation, e.g. the one of an
+// EventBridge schedule, is ignored.
+func (r *lambdaRuntime) next() (string, error) {
+	resp, err := r.client.Get(r.base + "/invocation/next")
+	if err != nil {
+		return "", fmt.Errorf("failed to get the next Lambda invocation; %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the next Lambda invocation; unexpected status %s", resp.Status)
+	}
+	return resp.Header.Get("Lambda-Runtime-Aws-Request-Id"), nil
+}
+
+// respond sends the response of an invocation.
+func (r *lambdaRuntime) respond(requestID string, response interface{}) error {
+	return r.post("/invocation/"+requestID+"/response", response)
+}
+
+// fail reports the error of an invocation.
+func (r *lambdaRuntime) fail(requestID string, err error) error {
+	return r.post("/invocation/"+requestID+"/error", lambdaError{ErrorMessage: err.Error(), ErrorType: "SnapshotError"})
+}
+
+// initError reports an error of the initialization, after which the execution environment is discarded.
+func (r *lambdaRuntime) initError(err error) error {
+	return r.post("/init/error", lambdaError{ErrorMessage: err.Error(), ErrorType: "InitError"})
+}
+
+// post sends v as JSON to the Lambda Runtime API, which should accept it.
+func (r *lambdaRuntime) post(path string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Lambda Runtime API request; %w", err)
+	}
+	resp, err := r.client.Post(r.base+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to call the Lambda Runtime API; %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed to call the Lambda Runtime API %s; unexpected status %s", path, resp.Status)
+	}
+	return nil
+}
+
+// lambdaResponse is the response of an invocation: the number of resources exported, whether some scopes failed and
+// their last known good data was pushed instead, and whether a blackout window was open, in which case nothing was
+// pushed.
+type lambdaResponse struct {
+	Resources int  `json:"resources"`
+	Stale     bool `json:"stale"`
+	Blackout  bool `json:"blackout,omitempty"`
+}
+
+// lambdaHandler takes a snapshot of the scopes at each invocation and pushes its metrics to the sink. The Metrics and
+// the catalogs are kept between the invocations served by the same execution environment, the catalogs until they are
+// refreshed.
+type lambdaHandler struct {
+	scopes         *Scopes
+	metrics        *Metrics
+	gatherer       prometheus.Gatherer
+	sink           metricsSink
+	catalogRefresh time.Duration
+	blackout       *blackoutState
+
+	catalogs         map[*Config]engineVersions
+	catalogFetchedAt time.Time
+}
+
+// newLambdaHandler returns the lambdaHandler of the scopes of the options, which pushes to their sink.
+func newLambdaHandler(options *Options, scopes *Scopes) *lambdaHandler {
+	metrics := NewMetrics()
+	metrics.SeriesGuard = newSeriesGuard(options)
+	metrics.Deprecations = newDeprecationTracker(options)
+	metrics.Acknowledgements = newAcknowledgements(options)
+	return &lambdaHandler{
+		scopes:         scopes,
+		metrics:        metrics,
+		gatherer:       newGatherer(metrics, nil, options.RelabelConfigs),
+		sink:           newMetricsSink(options, scopes.accountID(), metrics),
+		catalogRefresh: options.CatalogRefreshInterval,
+		blackout:       &blackoutState{windows: options.blackouts, metrics: metrics},
+	}
+}
+
+// invoke takes a snapshot and pushes its metrics. The scopes that failed are logged, and their last known good data
+// is pushed, flagged as stale, like the exporter serves it; the invocation only fails if the metrics cannot be pushed.
+// Nothing is done while a blackout window is open: the sink keeps the last metrics pushed, and the CloudWatch one would
+// call the AWS API.
+func (h *lambdaHandler) invoke(now time.Time) (*lambdaResponse, error) {
+	if h.blackout.check(now) {
+		return &lambdaResponse{Resources: len(h.metrics.Inventory.list()), Blackout: true}, nil
+	}
+	if h.catalogs == nil || now.Sub(h.catalogFetchedAt) >= h.catalogRefresh {
+		h.catalogs, h.catalogFetchedAt = newCatalogs(h.scopes), now
+	}
+	err := snapshotScopes(h.scopes, h.metrics, h.catalogs)
+	var staleErr *staleScopesError
+	if err != nil && !errors.As(err, &staleErr) {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("failed to take snapshot, pushing the last known good data of the failed scopes; %v", err)
+		h.scopes.Reporter.report(err)
+	}
+	if pushErr := h.sink.push(h.gatherer, now); pushErr != nil {
+		return nil, pushErr
+	}
+	return &lambdaResponse{Resources: len(h.metrics.Inventory.list()), Stale: err != nil}, nil
+}
+
+// serveLambda serves the invocations of the Lambda Runtime API with invoke, until the next invocation cannot be
+// fetched.
+func serveLambda(runtime *lambdaRuntime, invoke func(now time.Time) (*lambdaResponse, error)) error {
+	for {
+		requestID, err := runtime.next()
+		if err != nil {
+			return err
+		}
+		response, err := invoke(time.Now())
+		if err != nil {
+			log.Printf("failed to serve Lambda invocation %s; %v", requestID, err)
+			err = runtime.fail(requestID, err)
+		} else {
+			err = runtime.respond(requestID, response)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runLambda is the "lambda" subcommand, which the exporter also runs when started by AWS Lambda without arguments. It
+// runs the exporter as the handler of a function of a custom runtime, e.g. invoked by an EventBridge schedule, which
+// takes a snapshot at each invocation and pushes its metrics to the sink configured by the args, the environment
+// variables and the configuration file, for the accounts where long-lived containers cannot run.
+func runLambda(args []string, _ io.Writer) error {
+	api, ok := os.LookupEnv(lambdaRuntimeAPIEnvName)
+	if !ok {
+		return fmt.Errorf("the lambda command runs in AWS Lambda, which sets %s", lambdaRuntimeAPIEnvName)
+	}
+	runtime := newLambdaRuntime(api)
+	handler, err := loadLambdaHandler(args)
+	if err != nil {
+		if initErr := runtime.initError(err); initErr != nil {
+			log.Print(initErr)
+		}
+		return err
+	}
+	return serveLambda(runtime, handler.invoke)
+}
+
+// loadLambdaHandler returns the lambdaHandler of the options of the lambda subcommand, which require a sink.
+func loadLambdaHandler(args []string) (*lambdaHandler, error) {
+	options, err := loadCommandOptions("lambda", args, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	if options.Sink == "" && len(options.Sinks) == 0 {
+		return nil, errors.New("the lambda command requires a sink")
+	}
+	log.Printf("effective configuration:\n%s", options)
+	scopes, err := NewScopes(options)
+	if err != nil {
+		return nil, err
+	}
+	return newLambdaHandler(options, scopes), nil
+}
@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLambdaRuntime is a Lambda Runtime API serving a request ID per invocation, then failing, and recording the
+// responses and errors posted.
+type fakeLambdaRuntime struct {
+	mu        sync.Mutex
+	requests  []string
+	responses map[string]string
+}
+
+func (f *fakeLambdaRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodGet && r.URL.Path == "/2018-06-01/runtime/invocation/next" {
+		if len(f.requests) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Lambda-Runtime-Aws-Request-Id", f.requests[0])
+		f.requests = f.requests[1:]
+		_, _ = w.Write([]byte(`{"source":"aws.events"}`))
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	f.responses[r.URL.Path] = string(body)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// TestServeLambda tests that each invocation is answered with its response or its error, until the next invocation
+// cannot be fetched.
+func TestServeLambda(t *testing.T) {
+	fake := &fakeLambdaRuntime{requests: []string{"req-1", "req-2"}, responses: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	invocations := 0
+	err := serveLambda(newLambdaRuntime(strings.TrimPrefix(server.URL, "http://")),
+		func(time.Time) (*lambdaResponse, error) {
+			invocations++
+			if invocations == 2 {
+				return nil, errors.New("failed to push the metrics to the Pushgateway")
+			}
+			return &lambdaResponse{Resources: 3}, nil
+		})
+	assert.EqualError(t, err, "failed to get the next Lambda invocation; unexpected status 500 Internal Server Error")
+	assert.Equal(t, map[string]string{
+		"/2018-06-01/runtime/invocation/req-1/response": `{"resources":3,"stale":false}`,
+		"/2018-06-01/runtime/invocation/req-2/error": `{"errorMessage":"failed to push the metrics to the ` +
+			`Pushgateway","errorType":"SnapshotError"}`,
+	}, fake.responses)
+}
+
+// fakeSink is a metricsSink recording the number of series pushed.
+type fakeSink struct {
+	series []int
+	err    error
+}
+
+func (s *fakeSink) push(gatherer prometheus.Gatherer, _ time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, family := range families {
+		n += len(family.GetMetric())
+	}
+	s.series = append(s.series, n)
+	return s.err
+}
+
+// TestLambdaHandlerInvoke tests that the metrics are pushed even if a scope failed, and that the invocation fails if
+// they cannot be pushed.
+func TestLambdaHandlerInvoke(t *testing.T) {
+	euWest1 := &Config{Region: "eu-west-1", Concurrency: 1, RDS: stalenessRDSAPI("orders", "users")}
+	scopes := testScopes(euWest1)
+	options := defaultOptions()
+	sink := &fakeSink{}
+	handler := newLambdaHandler(options, scopes)
+	handler.sink = sink
+
+	response, err := handler.invoke(stalenessNow)
+	assert.NoError(t, err)
+	assert.Equal(t, &lambdaResponse{Resources: 2}, response)
+
+	euWest1.RDS = MockRDSAPI{err: errors.New("Throttling")}
+	response, err = handler.invoke(stalenessNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, &lambdaResponse{Resources: 2, Stale: true}, response)
+	assert.Len(t, sink.series, 2)
+
+	// nothing is pushed while a blackout window is open
+	handler.blackout.windows = blackoutWindows{{start: stalenessNow, end: stalenessNow.Add(time.Hour)}}
+	sink.series = nil
+	response, err = handler.invoke(stalenessNow.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, &lambdaResponse{Resources: 2, Blackout: true}, response)
+	assert.Empty(t, sink.series)
+	handler.blackout.windows = nil
+
+	sink.err = errors.New("failed to push the metrics to the Pushgateway")
+	_, err = handler.invoke(stalenessNow.Add(2 * time.Minute))
+	assert.EqualError(t, err, "failed to push the metrics to the Pushgateway")
+}
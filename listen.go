@@ -0,0 +1,133 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostName is the pattern of the host names of the listen addresses, e.g. "localhost".
+var hostName = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// reservedPaths are the paths of the endpoints the server may serve besides the metrics, which the metrics path
+// should not take over. The paths ending with "/" are reserved with every path under them.
+var reservedPaths = []string{"/readyz", "/healthz", "/acknowledgements", "/-/reload", "/debug/", tenantsPath}
+
+// serverAddress returns the address the server listens on: the listen address if set, e.g. "[::]:2112" on the
+// IPv6-only clusters, which takes precedence over the server port, or else every address of the host, in both IPv4 and
+// IPv6, on the server port, 2112 by default.
+func (o *Options) serverAddress() string {
+	if o.ListenAddress != "" {
+		return o.ListenAddress
+	}
+	return fmt.Sprintf(":%d", o.ServerPort)
+}
+
+// validateListen checks the addresses the server and the admin server listen on: the server port, unless the listen
+// address replaces it, and the listen and admin addresses, which should not overlap, as well as the metrics path.
+func (o *Options) validateListen() []string {
+	var problems []string
+	serving := !o.DryRun && o.DumpDir == "" && o.AwsConfigEvent == "" && o.command == ""
+	switch {
+	case o.ListenAddress != "":
+		if err := validateHostPort("listen address", o.ListenAddress); err != nil {
+			problems = append(problems, err.Error())
+		}
+	// the server port is only required when serving the metrics
+	case serving && (o.ServerPort < minServerPort || o.ServerPort > maxServerPort):
+		problems = append(problems, fmt.Sprintf("server port should be between %d and %d, got %d",
+			minServerPort, maxServerPort, o.ServerPort))
+	}
+	if o.AdminAddress != "" {
+		if err := validateHostPort("admin address", o.AdminAddress); err != nil {
+			problems = append(problems, err.Error())
+		} else if overlap(o.AdminAddress, o.serverAddress()) {
+			problems = append(problems, fmt.Sprintf("admin address should not overlap the server address %q, got %q",
+				o.serverAddress(), o.AdminAddress))
+		}
+	}
+	if err := validateMetricsPath(o.MetricsPath); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// validateMetricsPath checks that the metrics path is an absolute path, e.g. "/prometheus/metrics", which does not
+// take over the other endpoints of the server.
+func validateMetricsPath(path string) error {
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, "?# ") {
+		return fmt.Errorf("metrics path should be an absolute path, got %q", path)
+	}
+	for _, reserved := range reservedPaths {
+		if path == reserved || path == strings.TrimSuffix(reserved, "/") ||
+			(strings.HasSuffix(reserved, "/") && strings.HasPrefix(path, reserved)) {
+			return fmt.Errorf("metrics path should not be the one of another endpoint, got %q", path)
+		}
+	}
+	return nil
+}
+
+// validateHostPort checks that an address is host:port, whose host is either empty, an IPv4 or IPv6 address, the
+// latter in brackets, or a host name, and whose port is a valid port number.
+func validateHostPort(name, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%s should be host:port, got %q", name, address)
+	}
+	if host != "" && net.ParseIP(host) == nil && !hostName.MatchString(host) {
+		return fmt.Errorf("%s should have an IP address or a host name, got %q", name, address)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < minServerPort || n > maxServerPort {
+		return fmt.Errorf("%s port should be between %d and %d, got %q", name, minServerPort, maxServerPort,
+			address)
+	}
+	return nil
+}
+
+// overlap reports whether two host:port addresses cannot both be listened on: they have the same port, and either the
+// same host or a host that is empty or unspecified, e.g. "0.0.0.0" or "::", which listens on every address.
+func overlap(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || portA != portB {
+		return false
+	}
+	if anyHost(hostA) || anyHost(hostB) {
+		return true
+	}
+	ipA, ipB := net.ParseIP(hostA), net.ParseIP(hostB)
+	if ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return strings.EqualFold(hostA, hostB)
+}
+
+// anyHost reports whether the host of a listen address listens on every address: it is empty or unspecified.
+func anyHost(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || ip != nil && ip.IsUnspecified()
+}
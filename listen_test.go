@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateHostPort tests the host:port addresses accepted as listen and admin addresses.
+func TestValidateHostPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr string
+	}{
+		{name: "any host", address: ":2112"},
+		{name: "IPv4", address: "127.0.0.1:2112"},
+		{name: "IPv6", address: "[::1]:2112"},
+		{name: "host name", address: "localhost:2112"},
+		{name: "no port", address: "127.0.0.1", wantErr: `listen address should be host:port, got "127.0.0.1"`},
+		{name: "IPv6 without brackets", address: "::1:2112",
+			wantErr: `listen address should be host:port, got "::1:2112"`},
+		{name: "invalid host", address: "exporter_host:2112",
+			wantErr: `listen address should have an IP address or a host name, got "exporter_host:2112"`},
+		{name: "port out of range", address: "127.0.0.1:0",
+			wantErr: `listen address port should be between 1 and 65535, got "127.0.0.1:0"`},
+		{name: "port name", address: "127.0.0.1:http",
+			wantErr: `listen address port should be between 1 and 65535, got "127.0.0.1:http"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHostPort("listen address", tt.address)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestOverlap tests that two addresses overlap when they have the same port and either the same host or a host
+// listening on every address.
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{name: "other port", a: "127.0.0.1:9100", b: "127.0.0.1:2112"},
+		{name: "other address", a: "127.0.0.1:9100", b: "10.0.0.5:9100"},
+		{name: "other family", a: "127.0.0.1:9100", b: "[::1]:9100"},
+		{name: "other host name", a: "localhost:9100", b: "exporter:9100"},
+		{name: "same address", a: "10.0.0.5:9100", b: "10.0.0.5:9100", want: true},
+		{name: "same IPv6 address", a: "[::1]:9100", b: "[0:0:0:0:0:0:0:1]:9100", want: true},
+		{name: "same host name", a: "localhost:9100", b: "LOCALHOST:9100", want: true},
+		{name: "empty host", a: ":9100", b: "127.0.0.1:9100", want: true},
+		{name: "unspecified IPv4", a: "10.0.0.5:9100", b: "0.0.0.0:9100", want: true},
+		{name: "unspecified IPv6", a: "[::]:9100", b: "localhost:9100", want: true},
+		{name: "invalid address", a: "127.0.0.1", b: "127.0.0.1:9100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, overlap(tt.a, tt.b))
+			assert.Equal(t, tt.want, overlap(tt.b, tt.a))
+		})
+	}
+}
+
+// TestValidateListen tests that the admin address is only rejected when it overlaps the address of the server.
+func TestValidateListen(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+	}{
+		{
+			name:    "admin address on another address",
+			options: Options{ListenAddress: "10.0.0.5:9100", AdminAddress: "127.0.0.1:9100"},
+		},
+		{
+			name:    "admin address on another port",
+			options: Options{ServerPort: 2112, AdminAddress: "127.0.0.1:2113"},
+		},
+		{
+			name:    "admin address on the server port",
+			options: Options{ServerPort: 2112, AdminAddress: "127.0.0.1:2112"},
+			want:    []string{`admin address should not overlap the server address ":2112", got "127.0.0.1:2112"`},
+		},
+		{
+			name:    "admin address on every address",
+			options: Options{ListenAddress: "10.0.0.5:9100", AdminAddress: "0.0.0.0:9100"},
+			want: []string{
+				`admin address should not overlap the server address "10.0.0.5:9100", got "0.0.0.0:9100"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.MetricsPath = defaultMetricsPath
+			assert.Equal(t, tt.want, tt.options.validateListen())
+		})
+	}
+}
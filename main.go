@@ -24,35 +24,59 @@
 // exports them in Prometheus format. It uses the AWS SDK for Go and the Prometheus Go client library to perform these
 // operations.
 //
-// The program reads two environment variables: EXPORTER_AWS_API & INTERVAL_SECONDS, which specifies the time interval
-// in seconds for fetching the data, and EXPORTER_SERVER_PORT, which specifies the port number for serving the
-// Prometheus metrics.
+// The program is configured with a YAML configuration file, environment variables and command-line flags, loaded by
+// loadOptions() in this order of increasing precedence, over conventional defaults, so that the exporter runs without
+// any option. EXPORTER_POLL_INTERVAL (or the legacy EXPORTER_AWS_API_INTERVAL_SECONDS) specifies the time interval for
+// fetching the data as a Go duration string, 5m by default, EXPORTER_SERVER_PORT specifies the port number for serving
+// the Prometheus metrics, 2112 by default, and EXPORTER_METRICS_PATH their path, /metrics by default. The optional
+// EXPORTER_AWS_API_CONCURRENCY and EXPORTER_AWS_API_RATE_LIMIT variables bound the number of AWS API listings performed
+// in parallel and the number of AWS API calls per second.
 //
 // The program defines two main types: Config, which holds the AWS RDS API client, and Metrics, which holds the
 // Prometheus metrics. The program also defines a struct RDSInfo to represent information about an Amazon RDS cluster.
 //
 // The main() function initializes the program by setting up the configuration, metrics, and HTTP server, and then
 // starts a goroutine that periodically fetches RDS cluster and instance data and exports the metrics. The goroutine
-// uses the snapshot() function to fetch the data and export the metrics.
+// uses the snapshot() function to fetch the data and export the metrics, recovering from its panics so that an
+// unexpected API response does not take the exporter down.
 //
-// The snapshot() function fetches RDS cluster and instance data, merges them into a single slice of RDSInfos, and
-// then exports the metrics for each RDSInfo. If any error occurs during the metric exporting process, the function
-// will skip the problematic RDSInfo and continue exporting other RDSInfos.
+// The snapshot() function lists RDS clusters and instances page by page, fetches the engine version catalogs of the
+// engines actually in use, and exports the metrics for each RDSInfo of a page as soon as it arrives. If any error occurs
+// during the metric exporting process, the function will skip the problematic RDSInfo and continue exporting other
+// RDSInfos.
 //
 // The export() function collects RDS info and validates its engine version against a map of allowed engine versions.
 // If the version is deprecated, it will set the deprecatedGauge Prometheus metric to 1 and the availableGauge metric
 // to 0, and vice versa if the version is available.
 //
-// The program also defines two helper functions: getEnvInteger() to read integer environment variables, and
-// initHttpServer() to initialize the HTTP server.
+// The program also defines the initHttpServer() helper function to initialize the HTTP server.
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/health"
+	"github.com/aws/aws-sdk-go/service/health/healthiface"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/aws/aws-sdk-go/service/support"
+	"github.com/aws/aws-sdk-go/service/support/supportiface"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -61,50 +85,541 @@ import (
 )
 
 const (
-	AwsApiIntervalEnvName = "EXPORTER_AWS_API_INTERVAL_SECONDS"
-	ServerPortEnvName     = "EXPORTER_SERVER_PORT"
+	PollIntervalEnvName         = "EXPORTER_POLL_INTERVAL"
+	PollBackoffMaxEnvName       = "EXPORTER_POLL_BACKOFF_MAX"
+	PollScheduleEnvName         = "EXPORTER_POLL_SCHEDULE"
+	BlackoutWindowsEnvName      = "EXPORTER_BLACKOUT_WINDOWS"
+	AwsApiIntervalEnvName       = "EXPORTER_AWS_API_INTERVAL_SECONDS"
+	CatalogRefreshEnvName       = "EXPORTER_CATALOG_REFRESH_INTERVAL"
+	CollectorIntervalsEnvName   = "EXPORTER_COLLECTOR_INTERVALS"
+	AwsApiTimeoutEnvName        = "EXPORTER_AWS_API_TIMEOUT"
+	AwsApiConcurrencyEnvName    = "EXPORTER_AWS_API_CONCURRENCY"
+	AwsApiRateLimitEnvName      = "EXPORTER_AWS_API_RATE_LIMIT"
+	AwsRetryModeEnvName         = "EXPORTER_AWS_RETRY_MODE"
+	AwsMaxAttemptsEnvName       = "EXPORTER_AWS_MAX_ATTEMPTS"
+	AwsMaxBackoffEnvName        = "EXPORTER_AWS_MAX_BACKOFF"
+	DiscoveryBackendEnvName     = "EXPORTER_DISCOVERY_BACKEND"
+	GlobalClustersEnvName       = "EXPORTER_GLOBAL_CLUSTERS"
+	InstanceClassesEnvName      = "EXPORTER_INSTANCE_CLASSES"
+	BabelfishEnvName            = "EXPORTER_BABELFISH"
+	ReadinessGatingEnvName      = "EXPORTER_READINESS_GATING"
+	TagFiltersEnvName           = "EXPORTER_TAG_FILTERS"
+	ServerPortEnvName           = "EXPORTER_SERVER_PORT"
+	ListenAddressEnvName        = "EXPORTER_LISTEN_ADDRESS"
+	MetricsPathEnvName          = "EXPORTER_METRICS_PATH"
+	ConfigFileEnvName           = "EXPORTER_CONFIG_FILE"
+	SentryDSNEnvName            = "EXPORTER_SENTRY_DSN"
+	LogLevelEnvName             = "EXPORTER_LOG_LEVEL"
+	SignalDumpFileEnvName       = "EXPORTER_SIGNAL_DUMP_FILE"
+	OtelEndpointEnvName         = "EXPORTER_OTEL_ENDPOINT"
+	OtelHeadersEnvName          = "EXPORTER_OTEL_HEADERS"
+	SinkEnvName                 = "EXPORTER_SINK"
+	SinkURLEnvName              = "EXPORTER_SINK_URL"
+	SinkTokenEnvName            = "EXPORTER_SINK_TOKEN"
+	CloudWatchNamespaceEnvName  = "EXPORTER_CLOUDWATCH_NAMESPACE"
+	GraphitePrefixEnvName       = "EXPORTER_GRAPHITE_PREFIX"
+	GraphiteTagsEnvName         = "EXPORTER_GRAPHITE_TAGS"
+	DatadogTagMapEnvName        = "EXPORTER_DATADOG_TAG_MAP"
+	UserAgentSuffixEnvName      = "EXPORTER_USER_AGENT_SUFFIX"
+	AwsCABundleEnvName          = "EXPORTER_AWS_CA_BUNDLE"
+	AwsMinTLSVersionEnvName     = "EXPORTER_AWS_MIN_TLS_VERSION"
+	SampleTimestampsEnvName     = "EXPORTER_SAMPLE_TIMESTAMPS"
+	RegionsEnvName              = "EXPORTER_REGIONS"
+	ExcludeRegionsEnvName       = "EXPORTER_EXCLUDE_REGIONS"
+	AssumeRolesEnvName          = "EXPORTER_ASSUME_ROLES"
+	DemoEnvName                 = "EXPORTER_DEMO"
+	RecordDirEnvName            = "EXPORTER_RECORD_DIR"
+	ReplayDirEnvName            = "EXPORTER_REPLAY_DIR"
+	MaxAccountsInFlightEnvName  = "EXPORTER_MAX_ACCOUNTS_IN_FLIGHT"
+	MaxRegionsPerAccountEnvName = "EXPORTER_MAX_REGIONS_PER_ACCOUNT"
+	CatalogInfoEnvName          = "EXPORTER_CATALOG_INFO"
+	DBSnapshotsEnvName          = "EXPORTER_DB_SNAPSHOTS"
+	ForcedUpgradesEnvName       = "EXPORTER_FORCED_UPGRADE_DEADLINES"
+	ReservedInstancesEnvName    = "EXPORTER_RESERVED_INSTANCES"
+	HealthEventsEnvName         = "EXPORTER_HEALTH_EVENTS"
+	TrustedAdvisorEnvName       = "EXPORTER_TRUSTED_ADVISOR"
+	SupportCalendarEnvName      = "EXPORTER_SUPPORT_CALENDAR"
+	SupportCalendarFileEnvName  = "EXPORTER_SUPPORT_CALENDAR_FILE"
+	PolicyURLEnvName            = "EXPORTER_POLICY_URL"
+	PolicyRefreshEnvName        = "EXPORTER_POLICY_REFRESH_INTERVAL"
+	ExtendedSupportCostEnvName  = "EXPORTER_EXTENDED_SUPPORT_COST"
+	RecommendationsEnvName      = "EXPORTER_UPGRADE_RECOMMENDATIONS"
+	UpgradePreferenceEnvName    = "EXPORTER_UPGRADE_PREFERENCE"
+	PreferDefaultEnvName        = "EXPORTER_PREFER_DEFAULT_VERSION"
+	PlanFormatEnvName           = "EXPORTER_PLAN_FORMAT"
+	AutoMinorRemediationEnvName = "EXPORTER_AUTO_MINOR_REMEDIATION"
+	RemediationApplyEnvName     = "EXPORTER_REMEDIATION_APPLY"
+	RemediationFiltersEnvName   = "EXPORTER_REMEDIATION_TAG_FILTERS"
+	OwnersFileEnvName           = "EXPORTER_OWNERS_FILE"
+	ExcludeStoppedEnvName       = "EXPORTER_EXCLUDE_STOPPED"
+	KubernetesConfigEnvName     = "EXPORTER_KUBERNETES_CONFIG"
+	ConfigReloadEnvName         = "EXPORTER_CONFIG_RELOAD"
+	DeprecationGraceEnvName     = "EXPORTER_DEPRECATION_GRACE_DAYS"
+	RDSEventsQueueURLEnvName    = "EXPORTER_RDS_EVENTS_QUEUE_URL"
+	DigestScheduleEnvName       = "EXPORTER_DIGEST_SCHEDULE"
+	DigestTransportEnvName      = "EXPORTER_DIGEST_TRANSPORT"
+	DigestFromEnvName           = "EXPORTER_DIGEST_FROM"
+	DigestToEnvName             = "EXPORTER_DIGEST_TO"
+	DigestGroupByTagEnvName     = "EXPORTER_DIGEST_GROUP_BY_TAG"
+	DigestSMTPAddressEnvName    = "EXPORTER_DIGEST_SMTP_ADDRESS"
+	DigestSMTPUsernameEnvName   = "EXPORTER_DIGEST_SMTP_USERNAME"
+	DigestSMTPPasswordEnvName   = "EXPORTER_DIGEST_SMTP_PASSWORD"
+	DigestSESRegionEnvName      = "EXPORTER_DIGEST_SES_REGION"
+	DigestSupportEndDaysEnvName = "EXPORTER_DIGEST_SUPPORT_END_DAYS"
+	EventBridgeBusEnvName       = "EXPORTER_EVENTBRIDGE_BUS"
+	KafkaRESTURLEnvName         = "EXPORTER_KAFKA_REST_URL"
+	KafkaInventoryEnvName       = "EXPORTER_KAFKA_INVENTORY_TOPIC"
+	KafkaTransitionsEnvName     = "EXPORTER_KAFKA_TRANSITIONS_TOPIC"
+	KafkaFormatEnvName          = "EXPORTER_KAFKA_FORMAT"
+	NATSURLEnvName              = "EXPORTER_NATS_URL"
+	NATSSubjectEnvName          = "EXPORTER_NATS_SUBJECT"
+	NATSCredsFileEnvName        = "EXPORTER_NATS_CREDS_FILE"
+	NATSJetStreamEnvName        = "EXPORTER_NATS_JETSTREAM"
+	ReportFormatsEnvName        = "EXPORTER_REPORT_FORMATS"
+	ReportScheduleEnvName       = "EXPORTER_REPORT_SCHEDULE"
+	ReportS3BucketEnvName       = "EXPORTER_REPORT_S3_BUCKET"
+	ReportS3KeyEnvName          = "EXPORTER_REPORT_S3_KEY"
+	ReportS3RegionEnvName       = "EXPORTER_REPORT_S3_REGION"
+	ReportS3SSEEnvName          = "EXPORTER_REPORT_S3_SSE"
+	ReportS3KMSKeyIDEnvName     = "EXPORTER_REPORT_S3_KMS_KEY_ID"
+	AwsConfigEventEnvName       = "EXPORTER_AWS_CONFIG_EVENT"
+	AdminAddressEnvName         = "EXPORTER_ADMIN_ADDRESS"
+	DropLabelsEnvName           = "EXPORTER_DROP_LABELS"
+	HashLabelsEnvName           = "EXPORTER_HASH_LABELS"
+	MaxSeriesEnvName            = "EXPORTER_MAX_SERIES"
 )
 
-// Config holds the AWS RDS API client used to make calls to the Amazon RDS API.
-// The NewConfig function creates a new Config struct with a pre-initialized RDSAPI client. The client is created with
-// the AWS session shared configuration state enabled. If the AWS session shared configuration cannot be enabled, the
-// function will panic.
+// resourceTypeCluster and resourceTypeInstance are the AWS resource types of RDS clusters and instances.
+const (
+	resourceTypeCluster  = "AWS::RDS::DBCluster"
+	resourceTypeInstance = "AWS::RDS::DBInstance"
+)
+
+// exporterName identifies the exporter in the User-Agent of AWS API calls and in error reports.
+const exporterName = "prometheus-exporter-aws-rds-engine-version"
+
+// version is the version of the exporter. It is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const (
+	defaultServerPort           = 2112
+	defaultMetricsPath          = "/metrics"
+	defaultPollInterval         = 5 * time.Minute
+	defaultPollBackoffMax       = time.Hour
+	defaultCatalogRefresh       = 24 * time.Hour
+	defaultPolicyRefresh        = 15 * time.Minute
+	defaultAwsApiTimeout        = 30 * time.Second
+	defaultAwsApiConcurrency    = 4
+	defaultAwsApiRateLimit      = 0
+	defaultAwsMaxAttempts       = 4
+	defaultAwsMaxBackoff        = 20 * time.Second
+	defaultAwsMinTLSVersion     = "1.2"
+	defaultMaxAccountsInFlight  = 2
+	defaultMaxRegionsPerAccount = 4
+)
+
+// Config holds the AWS RDS API client used to make calls to the Amazon RDS API, the Resource Groups Tagging API
+// client used by the "tagging" discovery backend, the STS client used by the deep health check, and the AWS Health API
+// and AWS Support API clients used by the health events and Trusted Advisor collectors, for one AWS account and region.
+// The NewConfig function creates a new Config struct with pre-initialized clients, from a session created by
+// newSession.
 type Config struct {
-	RDS rdsiface.RDSAPI
+	RDS     rdsiface.RDSAPI
+	Tagging resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	STS     stsiface.STSAPI
+	Health  healthiface.HealthAPI
+	Support supportiface.SupportAPI
+
+	// Region is the AWS region of the clients.
+	Region string
+
+	// RoleARN is the IAM role assumed by the clients. It is empty when the default credentials are used.
+	RoleARN string
+
+	// AccountID is the ID of the AWS account of the clients, and AccountAlias its alias, if it has one. AccountID is
+	// empty if it could not be resolved.
+	AccountID    string
+	AccountAlias string
+
+	// Concurrency is the maximum number of paginated AWS API listings performed in parallel.
+	Concurrency int
+
+	// DiscoveryBackend is the backend used to discover RDS resources: either "describe" or "tagging".
+	DiscoveryBackend string
+
+	// TagFilters restricts the exported RDS resources to those whose tags match every filter.
+	TagFilters []tagFilter
+
+	// ClusterFilters and InstanceFilters are the native API filters of the DescribeDBClusters and DescribeDBInstances
+	// calls listing RDS resources.
+	ClusterFilters  []*rds.Filter
+	InstanceFilters []*rds.Filter
+
+	// GlobalClusters enables the export of the Aurora Global Database topology.
+	GlobalClusters bool
+
+	// InstanceClasses enables checking whether the class of each RDS instance is still orderable.
+	InstanceClasses bool
+
+	// Babelfish enables checking whether Babelfish is enabled on the Aurora PostgreSQL clusters.
+	Babelfish bool
+
+	// CatalogInfo enables the export of the catalog of every engine, whether it is in use or not.
+	CatalogInfo bool
+
+	// DBSnapshots enables checking the engine version of the manual snapshots of clusters and instances.
+	DBSnapshots bool
+
+	// ForcedUpgrades enables the export of the time after which AWS upgrades the resources running a deprecated engine
+	// version.
+	ForcedUpgrades bool
+
+	// Reservations enables checking which instances on a deprecated engine version are covered by a reserved DB
+	// instance.
+	Reservations bool
+
+	// HealthEvents enables the export of the upcoming end of support and mandatory upgrade events of the AWS Health API.
+	HealthEvents bool
+
+	// TrustedAdvisor enables the export of the Trusted Advisor checks of RDS, once per account.
+	TrustedAdvisor bool
+
+	// StandardSupport enables the export of the number of days left until the end of the standard support of the engine
+	// version of each resource.
+	StandardSupport bool
+
+	// ExtendedSupport enables the export of the estimated monthly cost of the Extended Support of each instance and
+	// account.
+	ExtendedSupport bool
+
+	// Recommendations enables the export of the upgrade target recommended by these preferences for each resource
+	// running a deprecated engine version. It is nil unless they are exported.
+	Recommendations *upgradePreferences
+
+	// Remediation selects the instances whose automatic minor version upgrades are enabled by the exporter. It is nil
+	// unless the remediation is enabled.
+	Remediation *remediationPolicy
+
+	// Calendar lists the end dates of the standard support of the major versions of the engines.
+	Calendar supportCalendar
+
+	// Policy is the version policy banning engine versions and overriding the Calendar. It is nil unless a policy URL is
+	// configured.
+	Policy *policySource
+
+	// Reporter reports the errors that do not fail the snapshot, e.g. the failed remediations, to Sentry. It is nil
+	// unless a Sentry DSN is configured.
+	Reporter *errorReporter
+
+	// Owners maps the resources to their team, owner and Slack channel. Nothing is exported if it is empty.
+	Owners ownerMapping
+
+	// ExcludeStopped enables skipping the stopped clusters and instances.
+	ExcludeStopped bool
+
+	// trace holds the span of the snapshot of the region in progress, to trace the AWS API calls of the clients. It is
+	// nil unless tracing is enabled.
+	trace *traceScope
+
+	// apiErrors counts the failed AWS API calls of the clients. It is nil in demo mode.
+	apiErrors *awsErrorScope
+
+	// pendingCache, reservedCache and snapshotsCache keep the pending maintenance actions, the reserved DB instances and
+	// the manual snapshots between the snapshots, for the collector intervals. Each is nil, and listed again at every
+	// snapshot, unless its collector has an interval.
+	pendingCache   *listingCache[map[string]time.Time]
+	reservedCache  *listingCache[[]ReservedInstanceInfo]
+	snapshotsCache *listingCache[[]DBSnapshotInfo]
 }
 
-// NewConfig creates and returns a new Config struct with a pre-initialized RDSAPI client.
-// The client is created with the AWS session shared configuration state enabled.
+// newSession creates and returns the AWS session shared by the clients of every account and region.
+// The session is created with the AWS session shared configuration state enabled.
 // If the AWS session shared configuration cannot be enabled, the function will panic.
-// The returned Config struct can be used to make calls to the Amazon RDS API.
-func NewConfig() *Config {
+// If the AWS API rate limit is greater than 0, every AWS API call made through the session, including retries, waits
+// on a rate limiter letting through at most that many calls per second, whatever the concurrency is.
+// Every AWS API call is attempted up to the AWS max attempts, with an exponential backoff capped to the AWS max backoff.
+// In the adaptive retry mode, the calls are also slowed down client-side once AWS throttles them.
+// Each HTTP request to the AWS API times out after the AWS API timeout, and its TLS connection uses at least the
+// minimum TLS version and trusts the certificates of the CA bundle, if any, on top of the system ones.
+// The exporter name and version, followed by the User-Agent suffix if any, are appended to the User-Agent of every AWS
+// API call, so that CloudTrail events and AWS support can attribute them to the exporter.
+// If reporter is not nil, every AWS API call is recorded by it, to give context to its reports. On the debug log level,
+// every AWS API call is logged with its request ID.
+func newSession(options *Options, reporter *errorReporter) *session.Session {
 	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		Config: aws.Config{
+			HTTPClient: newAWSHTTPClient(options.AwsApiTimeout, options.awsMinTLSVersion, options.awsRootCAs),
+			Retryer:    newRetryer(options.AwsMaxAttempts, options.AwsMaxBackoff),
+		},
 		SharedConfigState: session.SharedConfigEnable,
 	}))
-	return &Config{
-		RDS: rds.New(sess),
+	sess.Handlers.Build.PushBack(userAgentHandler(options.UserAgentSuffix))
+	if reporter != nil {
+		sess.Handlers.Complete.PushBack(reporter.recordAWSCall)
+	}
+	if options.LogLevel == logLevelDebug {
+		sess.Handlers.Complete.PushBack(logAWSCall)
 	}
+	if options.AwsRetryMode == awsRetryModeAdaptive {
+		limiter := newAdaptiveLimiter()
+		sess.Handlers.Send.PushFront(limiter.wait)
+		sess.Handlers.CompleteAttempt.PushBack(limiter.observe)
+	}
+	if limiter := newRateLimiter(options.AwsApiRateLimit); limiter != nil {
+		sess.Handlers.Send.PushFront(func(*request.Request) {
+			limiter.Wait()
+		})
+	}
+	return sess
 }
 
-// Metrics defined to hold two Prometheus GaugeVecs, one for instances whose engine version is available, and the other
-// for those whose version is deprecated. These metrics are initialized using the NewGaugeVec function of the prometheus
-// package, and they include a namespace, subsystem, name, help string, and label names.
+// NewConfig creates and returns a new Config struct with pre-initialized clients for the given region, or the region of
+// the session if empty, on the session returned by scopedSession. The AWS Health API and AWS Support API clients use the
+// global endpoint of the partition of the region. The failed AWS API calls of the clients are counted on the metrics
+// set on its apiErrors, once a snapshot sets them.
+// The returned Config struct can be used to make calls to the Amazon RDS API.
+func NewConfig(options *Options, sess *session.Session, region, roleARN string) *Config {
+	scoped := scopedSession(options, sess, region, roleARN)
+	region = aws.StringValue(scoped.Config.Region)
+	var trace *traceScope
+	if options.otelEndpoint != "" {
+		trace = &traceScope{}
+		scoped.Handlers.Complete.PushBack(trace.recordAWSCall)
+	}
+	apiErrors := &awsErrorScope{}
+	scoped.Handlers.Complete.PushBack(apiErrors.recordAWSCall)
+	config := &Config{
+		RDS:              rds.New(scoped),
+		Tagging:          resourcegroupstaggingapi.New(scoped),
+		STS:              sts.New(scoped),
+		Health:           health.New(scoped, aws.NewConfig().WithRegion(globalRegion(healthRegions, region))),
+		Support:          support.New(scoped, aws.NewConfig().WithRegion(globalRegion(supportRegions, region))),
+		Region:           region,
+		RoleARN:          roleARN,
+		Concurrency:      options.AwsApiConcurrency,
+		DiscoveryBackend: options.DiscoveryBackend,
+		TagFilters:       options.tagFilters,
+		ClusterFilters:   toRDSFilters(options.APIFilters.Clusters),
+		InstanceFilters:  toRDSFilters(options.APIFilters.Instances),
+		GlobalClusters:   options.GlobalClusters,
+		InstanceClasses:  options.InstanceClasses,
+		Babelfish:        options.Babelfish,
+		CatalogInfo:      options.CatalogInfo,
+		DBSnapshots:      options.DBSnapshots,
+		ForcedUpgrades:   options.ForcedUpgradeDeadlines,
+		Reservations:     options.ReservedInstances,
+		HealthEvents:     options.HealthEvents,
+		TrustedAdvisor:   options.TrustedAdvisor,
+		StandardSupport:  options.SupportCalendar,
+		ExtendedSupport:  options.ExtendedSupportCost,
+		Recommendations:  recommendations(options),
+		Remediation:      newRemediationPolicy(options),
+		Calendar:         options.supportCalendar,
+		Owners:           options.owners,
+		ExcludeStopped:   options.ExcludeStopped,
+		trace:            trace,
+		apiErrors:        apiErrors,
+		pendingCache:     newListingCache[map[string]time.Time](options.collectorPeriods[collectorForcedUpgrades]),
+		reservedCache:    newListingCache[[]ReservedInstanceInfo](options.collectorPeriods[collectorReservedInstances]),
+		snapshotsCache:   newListingCache[[]DBSnapshotInfo](options.collectorPeriods[collectorDBSnapshots]),
+	}
+	apiErrors.account = config.account
+	return config
+}
+
+// scopedSession returns a copy of the session for the given region, or the region of the session if empty. If roleARN
+// is not empty, its clients assume this IAM role, with credentials obtained from STS with the credentials of the
+// session.
+// When recording, the raw AWS API exchanges of its clients are recorded into the record directory. When replaying, its
+// clients are served the exchanges recorded into the replay directory instead of calling AWS, with fake credentials
+// and without retries.
+func scopedSession(options *Options, sess *session.Session, region, roleARN string) *session.Session {
+	config := &aws.Config{}
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+	if roleARN != "" {
+		config.Credentials = stscreds.NewCredentials(sess, roleARN)
+	}
+	if options.RecordDir != "" || options.ReplayDir != "" || options.DumpDir != "" {
+		client := *sess.Config.HTTPClient
+		transport := &fixtureTransport{dir: options.RecordDir, account: accountOfRole(roleARN), next: client.Transport}
+		if options.DumpDir != "" {
+			transport.dir, transport.redact = options.DumpDir, true
+		}
+		if transport.next == nil {
+			transport.next = http.DefaultTransport
+		}
+		if options.ReplayDir != "" {
+			transport.dir, transport.replay = options.ReplayDir, true
+			config.Credentials = credentials.NewStaticCredentials("replay", "replay", "")
+			// replayed responses are the same at every attempt
+			config.Retryer = newRetryer(1, options.AwsMaxBackoff)
+		}
+		client.Transport = transport
+		config.HTTPClient = &client
+	}
+	return sess.Copy(config)
+}
+
+// userAgentHandler returns a request handler appending "<exporterName>/<version>", followed by suffix if any, to the
+// User-Agent of AWS API calls.
+func userAgentHandler(suffix string) func(*request.Request) {
+	userAgent := fmt.Sprintf("%s/%s", exporterName, version)
+	if suffix != "" {
+		userAgent += " " + suffix
+	}
+	return func(r *request.Request) {
+		request.AddToUserAgent(r, userAgent)
+	}
+}
+
+// Metrics holds the Prometheus metrics of the exporter, initialized by NewMetrics with a namespace, subsystem, name,
+// help string and label names, along with the state the snapshots export them from.
 type Metrics struct {
+	// AvailableGauge and DeprecatedGauge flag the resources whose engine version is available, resp. deprecated.
 	AvailableGauge  *prometheus.GaugeVec
 	DeprecatedGauge *prometheus.GaugeVec
+	// AvailableTotalGauge and DeprecatedTotalGauge count the resources per engine, account and region, and
+	// DeprecatedRatioGauge the ratio of them running a deprecated version, counted by the Fleet.
+	AvailableTotalGauge  *prometheus.GaugeVec
+	DeprecatedTotalGauge *prometheus.GaugeVec
+	DeprecatedRatioGauge *prometheus.GaugeVec
+	// GraceGauge flags the resources whose version turned deprecated less than the grace period of the Deprecations
+	// ago, if any.
+	GraceGauge *prometheus.GaugeVec
+	// AcknowledgedGauge flags the deprecated resources muted by the Acknowledgements, if any, and
+	// AcknowledgementExpiryGauge holds the reason and the expiry of each acknowledgement.
+	AcknowledgedGauge          *prometheus.GaugeVec
+	AcknowledgementExpiryGauge *prometheus.GaugeVec
+	// GlobalClusterMemberGauge describes the members of Aurora Global Databases and their primary or secondary role.
+	GlobalClusterMemberGauge *prometheus.GaugeVec
+	// InstanceClassDeprecatedGauge flags the instances whose class is no longer orderable for their engine.
+	InstanceClassDeprecatedGauge *prometheus.GaugeVec
+	// MaintenanceWindowGauge holds the number of seconds until the next preferred maintenance window of each resource.
+	MaintenanceWindowGauge *prometheus.GaugeVec
+	// EngineVersionInfoGauge lists the versions of the engine catalogs with their status, and EngineCapabilitiesGauge
+	// with their capabilities.
+	EngineVersionInfoGauge  *prometheus.GaugeVec
+	EngineCapabilitiesGauge *prometheus.GaugeVec
+	// UpgradeTargetsGauge holds the number of minor and major upgrade targets of each engine version in use, and
+	// VersionStatusInfoGauge its raw catalog status and how it is classified.
+	UpgradeTargetsGauge    *prometheus.GaugeVec
+	VersionStatusInfoGauge *prometheus.GaugeVec
+	// MajorDeprecatedGauge flags the resources whose major version is entirely deprecated.
+	MajorDeprecatedGauge *prometheus.GaugeVec
+	// ParameterGroupFamilyDeprecatedGauge flags the resources whose parameter group family has no available version
+	// left, which blocks their in-place upgrade.
+	ParameterGroupFamilyDeprecatedGauge *prometheus.GaugeVec
+	// StorageLegacyGauge flags the instances whose storage type is a legacy one, e.g. gp2 or magnetic.
+	StorageLegacyGauge *prometheus.GaugeVec
+	// ClusterMemberCountGauge holds the number of member instances of each cluster, ClusterMemberInfoGauge describes
+	// them with their writer or reader role, and ClusterVersionMismatchGauge flags the ones whose engine version
+	// differs from the one of their cluster.
+	ClusterMemberCountGauge     *prometheus.GaugeVec
+	ClusterMemberInfoGauge      *prometheus.GaugeVec
+	ClusterVersionMismatchGauge *prometheus.GaugeVec
+	// DBSnapshotDeprecatedGauge flags the manual snapshots whose engine version is deprecated.
+	DBSnapshotDeprecatedGauge *prometheus.GaugeVec
+	// ForcedUpgradeDeadlineGauge holds the time after which AWS upgrades the resources on a deprecated version.
+	ForcedUpgradeDeadlineGauge *prometheus.GaugeVec
+	// StandardSupportDaysGauge holds the number of days left until the end of the standard support of each resource.
+	StandardSupportDaysGauge *prometheus.GaugeVec
+	// ExtendedSupportCostGauge holds the estimated monthly cost of the Extended Support of each instance, and
+	// ExtendedSupportAccountCostGauge its sum over each account.
+	ExtendedSupportCostGauge        *prometheus.GaugeVec
+	ExtendedSupportAccountCostGauge *prometheus.GaugeVec
+	// RecommendedUpgradeGauge describes the upgrade target recommended for each resource on a deprecated version.
+	RecommendedUpgradeGauge *prometheus.GaugeVec
+	// ReservedInstanceEndGauge holds the time each active reserved DB instance expires, and
+	// ReservedInstanceDeprecatedGauge flags the instances on a deprecated version covered by one of them.
+	ReservedInstanceEndGauge        *prometheus.GaugeVec
+	ReservedInstanceDeprecatedGauge *prometheus.GaugeVec
+	// HealthEventStartGauge and HealthEventEndGauge hold the start and end times of the scheduled changes of RDS
+	// announced by the AWS Health API.
+	HealthEventStartGauge *prometheus.GaugeVec
+	HealthEventEndGauge   *prometheus.GaugeVec
+	// TrustedAdvisorCheckGauge holds the number of resources flagged by each Trusted Advisor check of RDS, and
+	// TrustedAdvisorFlaggedResourceGauge flags these resources.
+	TrustedAdvisorCheckGauge           *prometheus.GaugeVec
+	TrustedAdvisorFlaggedResourceGauge *prometheus.GaugeVec
+	// OwnerInfoGauge describes the team, owner and Slack channel the resources are mapped to.
+	OwnerInfoGauge *prometheus.GaugeVec
+	// StoppedGauge flags the stopped resources, and IncompatibleStateGauge the instances in an incompatible state.
+	StoppedGauge           *prometheus.GaugeVec
+	IncompatibleStateGauge *prometheus.GaugeVec
+	// DiscoveredGauge counts the resources discovered before they are filtered, and CatalogEnginesGauge and
+	// CatalogVersionsGauge the engines and the versions of the catalogs of each region.
+	DiscoveredGauge      *prometheus.GaugeVec
+	CatalogEnginesGauge  *prometheus.GaugeVec
+	CatalogVersionsGauge *prometheus.GaugeVec
+	// RDSEventsCounter counts the RDS events received from SQS, and MaintenanceAnnouncedGauge holds the time of the
+	// last maintenance event of each resource; neither is reset by snapshots.
+	RDSEventsCounter          *prometheus.CounterVec
+	MaintenanceAnnouncedGauge *prometheus.GaugeVec
+	// ConfigReloadSuccessGauge flags whether the last reload of the configuration file succeeded, and
+	// ConfigReloadTimestampGauge holds the time of the last successful one.
+	ConfigReloadSuccessGauge   *prometheus.GaugeVec
+	ConfigReloadTimestampGauge *prometheus.GaugeVec
+	// SnapshotPanicsCounter counts the panics recovered while taking snapshots.
+	SnapshotPanicsCounter prometheus.Counter
+	// RemediationsCounter counts the remediations performed, or logged in dry run.
+	RemediationsCounter *prometheus.CounterVec
+	// AccessDeniedCounter counts the AWS API calls denied for lack of permission, and AWSErrorsCounter the ones that
+	// failed otherwise.
+	AccessDeniedCounter *prometheus.CounterVec
+	AWSErrorsCounter    *prometheus.CounterVec
+	// SkippedResourcesCounter counts the resources skipped as their engine or version is missing from the catalogs.
+	SkippedResourcesCounter *prometheus.CounterVec
+	// MovedOffDeprecatedCounter counts the resources upgraded off a deprecated version, and
+	// CreatedOnDeprecatedCounter the ones created on one, since the exporter started.
+	MovedOffDeprecatedCounter  *prometheus.CounterVec
+	CreatedOnDeprecatedCounter *prometheus.CounterVec
+	// SeriesOverflowGauge counts the series of the last snapshot left out by the SeriesGuard, if any.
+	SeriesOverflowGauge prometheus.Gauge
+	// ScopeStaleGauge flags the scopes whose last snapshot failed, ScopeLastSuccessGauge holds the time of the last
+	// successful snapshot of each scope, and DataAge the number of seconds since then, at scrape time.
+	ScopeStaleGauge       *prometheus.GaugeVec
+	ScopeLastSuccessGauge *prometheus.GaugeVec
+	DataAge               *dataAgeCollector
+	// PollIntervalGauge holds the current interval between two snapshots, backed off after failed snapshots.
+	PollIntervalGauge prometheus.Gauge
+	// PolicyRefreshSuccessGauge and SinkPushSuccessGauge flag whether the last download of the version policy, resp.
+	// the last push to each sink, succeeded.
+	PolicyRefreshSuccessGauge *prometheus.GaugeVec
+	SinkPushSuccessGauge      *prometheus.GaugeVec
+	// BlackoutGauge flags whether a blackout window is open, during which the last known data is served.
+	BlackoutGauge prometheus.Gauge
+	// SeriesGuard caps the series of the per-resource metrics, if any.
+	SeriesGuard *seriesGuard
+	// Deprecations tracks when the versions turned deprecated, for the grace period, if any.
+	Deprecations *deprecationTracker
+	// Acknowledgements mutes the acknowledged deprecated resources, if any.
+	Acknowledgements *acknowledgements
+	// Inventory records the exported resources, for the outputs that are not metrics.
+	Inventory *inventory
+	// Debug records the catalogs and the filtered resources of the last snapshot, and the last errors.
+	Debug *debugInventory
+	// Fleet counts the resources per engine, account and region.
+	Fleet *fleetCounts
+	// Staleness holds the last successful result of each scope, from which the gauges and the Inventory are rebuilt
+	// after each snapshot.
+	Staleness *scopeResults
+	// Snapshot collects the series rebuilt after each snapshot, swapped at once.
+	Snapshot *snapshotCollector
 }
 
-// NewMetrics function returns a pointer to a new Metrics struct that includes the initialized AvailableGauge and
-// DeprecatedGauge.
+// NewMetrics returns new Metrics, with empty gauges and counters, Inventory, Debug, Fleet and Staleness, and the
+// Snapshot collector of its gauges. It has no SeriesGuard, Deprecations nor Acknowledgements.
 func NewMetrics() *Metrics {
-	return &Metrics{
+	metrics := &Metrics{
 		AvailableGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "aws_custom",
 			Subsystem: "rds",
 			Name:      "version_available",
 			Help:      "Number of instances whose version is available",
 		},
-			[]string{"cluster_identifier", "engine", "engine_version"},
+			[]string{"cluster_identifier", "engine", "engine_version", "engine_version_major", "engine_version_minor", "role", "license_model", "edition", "rds_custom", "babelfish", "maintenance_window", "account_id", "region"},
 		),
 		DeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "aws_custom",
@@ -112,9 +627,452 @@ func NewMetrics() *Metrics {
 			Name:      "version_deprecated",
 			Help:      "Number of instances whose Version is deprecated",
 		},
-			[]string{"cluster_identifier", "engine", "engine_version"},
+			[]string{"cluster_identifier", "engine", "engine_version", "engine_version_major", "engine_version_minor", "role", "license_model", "edition", "rds_custom", "babelfish", "maintenance_window", "account_id", "region"},
+		),
+		AvailableTotalGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "available_total",
+			Help:      "Number of resources whose version is available, per engine, account and region",
+		},
+			[]string{"engine", "account_id", "account_alias", "region"},
+		),
+		DeprecatedTotalGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "deprecated_total",
+			Help:      "Number of resources whose version is deprecated, per engine, account and region",
+		},
+			[]string{"engine", "account_id", "account_alias", "region"},
+		),
+		DeprecatedRatioGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "deprecated_ratio",
+			Help:      "Ratio of the resources whose version is deprecated, per engine, account and region",
+		},
+			[]string{"engine", "account_id", "account_alias", "region"},
+		),
+		GraceGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "version_grace",
+			Help:      "Number of instances whose version flipped to deprecated less than the grace period ago",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "engine_version_major", "engine_version_minor", "role", "license_model", "edition", "rds_custom", "babelfish", "maintenance_window", "account_id", "region"},
+		),
+		AcknowledgedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "version_deprecated_acknowledged",
+			Help:      "Number of instances whose version is deprecated, muted by an acknowledgement",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "engine_version_major", "engine_version_minor", "role", "license_model", "edition", "rds_custom", "babelfish", "maintenance_window", "account_id", "region"},
+		),
+		AcknowledgementExpiryGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "acknowledgement_expiry_timestamp_seconds",
+			Help:      "Time the acknowledgement of a deprecated resource expires, with its reason",
+		},
+			[]string{"cluster_identifier", "account_id", "region", "reason"},
+		),
+		GlobalClusterMemberGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "global_cluster_member_info",
+			Help:      "Members of Aurora Global Databases, with their primary or secondary role",
+		},
+			[]string{"global_cluster_identifier", "cluster_identifier", "account_id", "region", "role", "engine", "engine_version"},
+		),
+		InstanceClassDeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "instance_class_deprecated",
+			Help:      "Whether the class of an instance is no longer orderable for its engine",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "instance_class", "account_id", "region"},
+		),
+		MaintenanceWindowGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "maintenance_window_seconds_until",
+			Help:      "Number of seconds until the next preferred maintenance window opens, 0 if it is open",
+		},
+			[]string{"cluster_identifier", "maintenance_window", "account_id", "region"},
+		),
+		EngineVersionInfoGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "engine_version_info",
+			Help:      "Versions of the engine catalogs, with their status, whether they are in use or not",
+		},
+			[]string{"engine", "engine_version", "status", "account_id", "region"},
+		),
+		EngineCapabilitiesGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "engine_version_capabilities_info",
+			Help:      "Capabilities of the versions of the engine catalogs, whether they are in use or not",
+		},
+			[]string{"engine", "engine_version", "supports_read_replica", "supports_log_exports", "engine_modes", "account_id", "region"},
+		),
+		MajorDeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "major_version_deprecated",
+			Help:      "Resources whose major version has no available version left, which need a major version upgrade",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version_major", "account_id", "region"},
+		),
+		ParameterGroupFamilyDeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "parameter_group_family_deprecated",
+			Help:      "Resources whose parameter group family has no available version left, which blocks their in-place upgrade",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "parameter_group_family", "account_id", "region"},
+		),
+		StorageLegacyGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "storage_legacy",
+			Help:      "Instances whose storage type is a legacy one, e.g. gp2 or magnetic, with their storage",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "storage_type", "iops", "allocated_storage",
+				"account_id", "region"},
+		),
+		UpgradeTargetsGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "upgrade_targets",
+			Help:      "Number of valid minor or major upgrade targets of the engine versions in use",
+		},
+			[]string{"engine", "engine_version", "upgrade", "account_id", "region"},
+		),
+		VersionStatusInfoGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "version_status_info",
+			Help:      "Raw catalog status of the engine versions in use, and whether it is classified as available, deprecated or unknown",
+		},
+			[]string{"engine", "engine_version", "status", "classification", "account_id", "region"},
+		),
+		ClusterMemberCountGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "cluster_member_count",
+			Help:      "Number of member instances of RDS clusters",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "account_id", "region"},
+		),
+		ClusterMemberInfoGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "cluster_member_info",
+			Help:      "Member instances of RDS clusters, with their writer or reader role",
+		},
+			[]string{"cluster_identifier", "instance_identifier", "role", "engine", "engine_version", "account_id", "region"},
+		),
+		ClusterVersionMismatchGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "cluster_version_mismatch",
+			Help:      "Member instances of RDS clusters whose engine version differs from the one of their cluster",
+		},
+			[]string{"cluster_identifier", "instance_identifier", "engine", "cluster_engine_version", "engine_version",
+				"account_id", "region"},
+		),
+		DBSnapshotDeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "db_snapshot_version_deprecated",
+			Help:      "Whether the engine version of a manual snapshot of a cluster or an instance is deprecated",
+		},
+			[]string{"snapshot_identifier", "source_identifier", "snapshot_type", "engine", "engine_version", "account_id", "region"},
+		),
+		ForcedUpgradeDeadlineGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "forced_upgrade_deadline_timestamp_seconds",
+			Help:      "Time after which AWS upgrades the resources running a deprecated engine version",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "source", "account_id", "region"},
+		),
+		StandardSupportDaysGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "days_until_standard_support_end",
+			Help:      "Number of days left until the end of the standard support of the engine version, negative once over",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "account_id", "region"},
+		),
+		ExtendedSupportCostGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "extended_support_monthly_cost_dollars",
+			Help:      "Estimated monthly cost of the Extended Support of an instance, in US dollars",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "instance_class", "in_extended_support",
+				"account_id", "region"},
+		),
+		ExtendedSupportAccountCostGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "extended_support_account_monthly_cost_dollars",
+			Help:      "Estimated monthly cost of the Extended Support of the instances of an account, in US dollars",
+		},
+			[]string{"account_id", "in_extended_support"},
+		),
+		RecommendedUpgradeGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "recommended_upgrade",
+			Help:      "Upgrade target recommended for a resource running a deprecated engine version",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "recommended_version", "upgrade", "account_id", "region"},
+		),
+		ReservedInstanceEndGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "reserved_instance_end_timestamp_seconds",
+			Help:      "Time an active reserved DB instance expires",
+		},
+			[]string{"reserved_instance_id", "instance_class", "engine", "instance_count", "account_id", "region"},
+		),
+		ReservedInstanceDeprecatedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "reserved_instance_deprecated",
+			Help:      "Instances on a deprecated engine version covered by a reserved DB instance",
+		},
+			[]string{"cluster_identifier", "engine", "engine_version", "instance_class", "reserved_instance_id",
+				"account_id", "region"},
+		),
+		HealthEventStartGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "health_event_start_timestamp_seconds",
+			Help:      "Start time of the upcoming and open scheduled changes of RDS announced by the AWS Health API",
+		},
+			[]string{"event_arn", "event_type_code", "status", "account_id", "region"},
+		),
+		HealthEventEndGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "health_event_end_timestamp_seconds",
+			Help:      "End time of the upcoming and open scheduled changes of RDS announced by the AWS Health API",
+		},
+			[]string{"event_arn", "event_type_code", "status", "account_id", "region"},
+		),
+		TrustedAdvisorCheckGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "trusted_advisor",
+			Name:      "check_flagged_resources",
+			Help:      "Number of resources flagged by the Trusted Advisor checks of RDS",
+		},
+			[]string{"check_id", "check_name", "category", "status", "account_id"},
+		),
+		TrustedAdvisorFlaggedResourceGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "trusted_advisor",
+			Name:      "flagged_resource",
+			Help:      "Resources flagged by the Trusted Advisor checks of RDS, but the suppressed ones",
+		},
+			[]string{"check_id", "check_name", "resource_id", "resource", "status", "account_id", "region"},
+		),
+		OwnerInfoGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "owner_info",
+			Help:      "Team, owner and Slack channel the resources are mapped to by the owner mapping file",
+		},
+			[]string{"cluster_identifier", "team", "owner", "slack_channel", "account_id", "region"},
+		),
+		StoppedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "stopped",
+			Help:      "Stopped clusters and instances, whose engine version cannot be upgraded until they are started",
+		},
+			[]string{"cluster_identifier", "account_id", "region"},
+		),
+		IncompatibleStateGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "incompatible_state",
+			Help:      "Instances in an incompatible state, which cannot be patched until the state is resolved",
+		},
+			[]string{"cluster_identifier", "status", "account_id", "region"},
+		),
+		RDSEventsCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "events_total",
+			Help:      "Number of RDS events received from SQS",
+		},
+			[]string{"category", "source_type", "region"},
+		),
+		MovedOffDeprecatedCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "moved_off_deprecated_total",
+			Help:      "Number of resources upgraded off a deprecated engine version since the exporter started",
+		},
+			[]string{"engine", "account_id", "region"},
+		),
+		CreatedOnDeprecatedCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "created_on_deprecated_total",
+			Help:      "Number of resources created on a deprecated engine version since the exporter started",
+		},
+			[]string{"engine", "account_id", "region"},
 		),
+		MaintenanceAnnouncedGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "maintenance_announced_timestamp_seconds",
+			Help:      "Time of the last maintenance event of an RDS resource received from SQS",
+		},
+			[]string{"source_identifier", "source_type", "event_id", "region"},
+		),
+		ConfigReloadSuccessGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last reload of the configuration file succeeded",
+		},
+			[]string{"config_file"},
+		),
+		ConfigReloadTimestampGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Time of the last successful reload of the configuration file",
+		},
+			[]string{"config_file"},
+		),
+		SnapshotPanicsCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "snapshot_panics_total",
+			Help:      "Number of panics recovered while taking snapshots",
+		}),
+		RemediationsCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "remediations_total",
+			Help:      "Number of remediations performed, or logged in dry run, by the exporter",
+		},
+			[]string{"action", "result", "account_id", "region"},
+		),
+		AccessDeniedCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "access_denied_total",
+			Help:      "Number of AWS API calls denied for lack of permission",
+		},
+			[]string{"operation", "account_id"},
+		),
+		AWSErrorsCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "api_errors_total",
+			Help:      "Number of AWS API calls that failed after their retries, but the ones denied for lack of permission",
+		},
+			[]string{"operation", "account_id", "error_class"},
+		),
+		SkippedResourcesCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "skipped_resources_total",
+			Help:      "Number of resources skipped by the snapshots as their engine or engine version is missing from the engine catalogs",
+		},
+			[]string{"engine", "account_id", "region"},
+		),
+		SeriesOverflowGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "series_overflow",
+			Help:      "Number of series left out of the per-resource metrics by the series cap in the last snapshot",
+		}),
+		ScopeStaleGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "scope_stale",
+			Help:      "Whether the last snapshot of a scope failed, whose series are the ones of its last successful snapshot",
+		},
+			[]string{"collector", "account_id", "region"},
+		),
+		ScopeLastSuccessGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "scope_last_success_timestamp_seconds",
+			Help:      "Time of the last successful snapshot of a scope",
+		},
+			[]string{"collector", "account_id", "region"},
+		),
+		PollIntervalGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "poll_interval_seconds",
+			Help:      "Current interval between two snapshots, backed off after consecutive failed snapshots",
+		}),
+		DiscoveredGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "discovered_resources",
+			Help:      "Number of clusters and instances discovered, before they are filtered",
+		},
+			[]string{"engine", "account_id", "region"},
+		),
+		CatalogEnginesGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "catalog_engines",
+			Help:      "Number of engines of the engine catalogs of a region",
+		},
+			[]string{"account_id", "region"},
+		),
+		CatalogVersionsGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "catalog_versions",
+			Help:      "Number of versions of the engine catalog of a region",
+		},
+			[]string{"engine", "account_id", "region"},
+		),
+		PolicyRefreshSuccessGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "policy_last_refresh_successful",
+			Help:      "Whether the last download of the version policy succeeded",
+		},
+			[]string{"source"},
+		),
+		SinkPushSuccessGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "sink_last_push_successful",
+			Help:      "Whether the last push of the metrics to a sink succeeded",
+		},
+			[]string{"sink"},
+		),
+		BlackoutGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "aws_custom",
+			Subsystem: "rds",
+			Name:      "blackout",
+			Help:      "Whether a blackout window is open, during which no AWS API call is made and the last known data is served",
+		}),
+		Inventory: &inventory{},
+		Debug:     newDebugInventory(),
+		Fleet:     newFleetCounts(),
+		Staleness: newScopeResults(),
 	}
+	metrics.DataAge = newDataAgeCollector(metrics.Staleness)
+	metrics.Snapshot = newSnapshotCollector(metrics.servedGauges())
+	return metrics
 }
 
 // RDSInfo represents information about an Amazon RDS cluster.
@@ -129,95 +1087,644 @@ type RDSInfo struct {
 	// EngineVersion is the version of the database engine used by the RDS cluster.
 	// Examples of database engine versions include "5.7.34" and "13.2".
 	EngineVersion string
+
+	// Tags are the tags of the RDS cluster, mapping tag keys to tag values.
+	Tags map[string]string
+
+	// Members maps the identifiers of the member instances of an RDS cluster to their "writer" or "reader" role. It is
+	// only set for clusters.
+	Members map[string]string
+
+	// MemberOf is the identifier of the cluster an RDS instance is a member of. It is only set for cluster members.
+	MemberOf string
+
+	// Role is the "writer" or "reader" role of an RDS instance within its cluster. It is only set for cluster members.
+	Role string
+
+	// Region is the AWS region of the RDS resource.
+	Region string
+
+	// ResourceType is the AWS resource type of the RDS resource, either resourceTypeCluster or resourceTypeInstance, and
+	// ResourceID its immutable resource ID, e.g. "cluster-ABCDEFGHIJKLMNOP" or "db-ABCDEFGHIJKLMNOP", which identify it in
+	// AWS Config.
+	ResourceType string
+	ResourceID   string
+
+	// Account is the ID of the AWS account of the RDS resource, or "default" for the account of the default credentials
+	// if it could not be resolved, and AccountAlias its alias, if it has one.
+	Account      string
+	AccountAlias string
+
+	// MaintenanceWindow is the weekly preferred maintenance window of the RDS resource in UTC, e.g.
+	// "sun:05:00-sun:06:00".
+	MaintenanceWindow string
+
+	// InstanceClass is the class of an RDS instance, e.g. "db.r6g.large". It is only set for instances.
+	InstanceClass string
+
+	// AutoMinorVersionUpgrade is true if the minor version upgrades of an RDS instance are applied automatically during
+	// its maintenance window. It is only set for instances.
+	AutoMinorVersionUpgrade bool
+
+	// LicenseModel and Edition are the license model, e.g. "bring-your-own-license", and the edition, e.g.
+	// "enterprise", of an RDS instance. They are only set for commercial engines, i.e. Oracle and SQL Server.
+	LicenseModel string
+	Edition      string
+
+	// Status is the status of the RDS resource, e.g. "available" or "stopped".
+	Status string
+
+	// ParameterGroup is the cluster parameter group of an Aurora cluster. It is only set for clusters.
+	ParameterGroup string
+
+	// Babelfish is true if Babelfish is enabled on an Aurora PostgreSQL cluster, or on the cluster of a member
+	// instance. It is only checked when enabled.
+	Babelfish bool
+
+	// StorageType is the storage type of an RDS instance, e.g. "gp3" or "aurora", Iops its provisioned IOPS, if any, and
+	// AllocatedStorage its allocated storage in GiB. They are only set for instances.
+	StorageType      string
+	Iops             int64
+	AllocatedStorage int64
+}
+
+// commands are the subcommands of the exporter, run instead of serving the metrics, e.g.
+// "prometheus-exporter-aws-rds-engine-version bench -resources 20000".
+var commands = map[string]func(args []string, w io.Writer) error{
+	"bench":           runBench,
+	"config":          runConfig,
+	"explain":         runExplain,
+	"lambda":          runLambda,
+	"plan":            runPlan,
+	"recommend":       runRecommend,
+	"report":          runReport,
+	"update-calendar": runUpdateCalendar,
 }
 
 func main() {
-	interval, err := getEnvInteger(AwsApiIntervalEnvName)
+	// AWS Lambda starts the bootstrap of the custom runtimes without arguments
+	if _, ok := os.LookupEnv(lambdaRuntimeAPIEnvName); ok && len(os.Args) == 1 {
+		os.Args = append(os.Args, "lambda")
+	}
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			err := command(os.Args[2:], os.Stdout)
+			if err != nil && !errors.Is(err, flag.ErrHelp) {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	options, err := loadOptions(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	port, err := getEnvInteger(ServerPortEnvName)
+	log.Printf("effective configuration:\n%s", options)
+	// the RDSVersionExporterConfig, if any, sets the regions, accounts and filters
+	controller, err := newKubernetesController(options)
 	if err != nil {
 		log.Fatal(err)
 	}
-	addr := fmt.Sprintf(":%d", port)
-
-	config := NewConfig()
-	m, err := getEngineVersions(config)
+	var scopes *Scopes
+	if controller != nil {
+		scopes, err = controller.load()
+	} else {
+		scopes, err = NewScopes(options)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if options.DryRun {
+		printDryRun(os.Stdout, options, scopes)
+		return
+	}
+	if options.DumpDir != "" {
+		if err := dumpSnapshot(options, scopes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if options.AwsConfigEvent != "" {
+		if err := evaluateConfigRule(options, scopes, os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	interval := options.PollInterval
+	backoff := newPollBackoff(options)
+	catalogRefresh := options.CatalogRefreshInterval
+	addr := options.serverAddress()
+
+	catalogs := newCatalogs(scopes)
+	// live holds the scopes in use, which the controller may replace between two snapshots
+	var live atomic.Pointer[Scopes]
+	live.Store(scopes)
 
 	metrics := NewMetrics()
-	handler := initPromHandler(metrics)
-	server := initHttpServer(handler, addr)
+	metrics.SeriesGuard = newSeriesGuard(options)
+	metrics.Deprecations = newDeprecationTracker(options)
+	metrics.Acknowledgements = newAcknowledgements(options)
+	reloader := newConfigReloader(options, os.Args[1:], os.LookupEnv, metrics, controller)
+	ready := &readiness{}
+	var clock *snapshotClock
+	if options.SampleTimestamps {
+		clock = &snapshotClock{}
+		metrics.Snapshot.timestamps = true
+	}
+	handler := initPromHandler(metrics, clock, options.RelabelConfigs)
+	dumpMetricsOnSignal(handler, options.SignalDumpFile)
+	// the metrics are also pushed to the sink, if any, after each snapshot, and served to each tenant, if any
+	sink := newMetricsSink(options, scopes.accountID(), metrics)
+	gatherer := newGatherer(metrics, clock, options.RelabelConfigs)
+	if options.ReadinessGating {
+		handler = gateHandler(ready, handler)
+	}
+	// the operational endpoints move to the admin listener, if any
+	adminRoutes := []route{{"/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthzHandler(live.Load()).ServeHTTP(w, r)
+	})}}
+	var routes []route
+	if options.AdminAddress != "" {
+		admin := initAdminServer(options.AdminAddress,
+			append(adminRoutes, route{"/acknowledgements", acknowledgementsHandler(metrics.Acknowledgements)},
+				route{"/-/reload", reloadHandler(reloader)},
+				route{"/debug/inventory", debugInventoryHandler(metrics)})...)
+		go func() {
+			log.Fatal(admin.ListenAndServe())
+		}()
+	} else {
+		routes = adminRoutes
+	}
+	if len(options.Tenants) > 0 {
+		tenants := tenantsHandler(gatherer, metrics, options.Tenants)
+		if options.ReadinessGating {
+			tenants = gateHandler(ready, tenants)
+		}
+		routes = append(routes, route{tenantsPath, tenants})
+	}
+	server := initHttpServer(handler, options.MetricsPath, ready, addr, routes...)
+	digest := newDigest(options, time.Now())
+	events := newEventPublisher(options)
+	kafka := newKafkaPublisher(options)
+	nats := newNATSPublisher(options)
+	reports := newReportUploader(options, time.Now())
+	// RDS events trigger snapshots before the next tick, coalesced while one is pending
+	trigger := make(chan struct{}, 1)
+	go newRDSEventListener(options, metrics, trigger).run(scopes.Reporter)
+	if controller != nil {
+		go controller.run()
+	}
+	if reloader != nil {
+		go reloader.run()
+	}
 
 	go func() {
-		ticker := time.NewTicker(time.Duration(interval) * time.Second)
-		// register metrics as background
-		for range ticker.C {
-			err := snapshot(config, metrics, m)
-			if err != nil {
+		// the snapshots follow the poll schedule instead of the poll interval, if any, and are then neither taken at
+		// startup nor triggered by the RDS events, which are only counted
+		ticker := time.NewTicker(interval)
+		ticks := ticker.C
+		var triggers <-chan struct{} = trigger
+		if options.pollSchedule != nil {
+			ticker.Stop()
+			ticks = options.pollSchedule.ticks()
+			triggers = nil
+		}
+		catalogFetchedAt := time.Now()
+		velocity := &upgradeVelocity{}
+		blackout := &blackoutState{windows: options.blackouts, metrics: metrics}
+		// new scopes are snapshotted with their own catalogs, and their resources are not counted as new
+		swap := func(s *Scopes) {
+			scopes = s
+			live.Store(scopes)
+			catalogs = newCatalogs(scopes)
+			catalogFetchedAt = time.Now()
+			velocity = &upgradeVelocity{}
+		}
+		// new scopes are snapshotted right away, unless scheduled: they then wait for the next matching minute, so that
+		// no AWS API call is made outside of the schedule
+		next := func() {
+			for {
+				select {
+				case <-ticks:
+					return
+				case <-triggers:
+					return
+				case s := <-controller.updates():
+					swap(s)
+				case s := <-reloader.updates():
+					swap(s)
+				}
+				if options.pollSchedule == nil {
+					return
+				}
+			}
+		}
+		// register metrics as background, starting right away rather than after the first interval, unless scheduled
+		if options.pollSchedule != nil {
+			next()
+		}
+		for ; true; next() {
+			// no AWS API call is made during the blackout windows, and the last known data is served meanwhile
+			if blackout.check(time.Now()) {
+				continue
+			}
+			if time.Since(catalogFetchedAt) >= catalogRefresh {
+				// engine catalogs are fetched again lazily, by the next snapshot
+				catalogs = newCatalogs(scopes)
+				catalogFetchedAt = time.Now()
+			}
+			start := time.Now()
+			err := supervisedSnapshot(scopes, metrics, catalogs)
+			metrics.Debug.recordError("snapshot", err)
+			controller.report(start, len(metrics.Inventory.list()), err)
+			if options.pollSchedule == nil {
+				if next := backoff.next(err); next != interval {
+					if next > interval {
+						log.Printf("backing off the poll interval to %s after a failed snapshot", next)
+					}
+					interval = next
+					ticker.Reset(interval)
+				}
+				metrics.PollIntervalGauge.Set(interval.Seconds())
+			}
+			var p *panicError
+			if errors.As(err, &p) && !partialSnapshot(err) {
+				// already logged and counted: keep the loop alive, and export as usual if some scope succeeded
+				continue
+			}
+			var stale *staleScopesError
+			if errors.As(err, &stale) {
+				// the failed scopes keep their series, flagged as stale, until they succeed again
+				log.Print(err)
+			} else if err != nil {
 				log.Fatal(err)
 			}
+			if ready.observe(err) {
+				// ready once some scope succeeded, and the samples of the stale scopes keep the time of their last
+				// successful snapshot
+				clock.set(start)
+			}
+			velocity.observe(metrics, metrics.Inventory)
+			if err := digest.maybeSend(time.Now(), metrics.Inventory); err != nil {
+				log.Print(err)
+				metrics.Debug.recordError("digest", err)
+				scopes.Reporter.report(err)
+			}
+			if err := events.publish(metrics.Inventory, start); err != nil {
+				log.Print(err)
+				metrics.Debug.recordError("eventbridge", err)
+				scopes.Reporter.report(err)
+			}
+			if err := kafka.publish(metrics.Inventory, start); err != nil {
+				log.Print(err)
+				metrics.Debug.recordError("kafka", err)
+				scopes.Reporter.report(err)
+			}
+			if err := nats.publish(metrics.Inventory, start); err != nil {
+				log.Print(err)
+				metrics.Debug.recordError("nats", err)
+				scopes.Reporter.report(err)
+			}
+			if err := reports.maybeUpload(time.Now(), metrics.Inventory); err != nil {
+				log.Print(err)
+				metrics.Debug.recordError("reports", err)
+				scopes.Reporter.report(err)
+			}
+			if sink != nil {
+				if err := sink.push(gatherer, start); err != nil {
+					log.Print(err)
+					metrics.Debug.recordError("sink", err)
+					scopes.Reporter.report(err)
+				}
+			}
 		}
 	}()
 	log.Fatal(server.ListenAndServe())
 }
 
 // initPromHandler returns an HTTP handler that serves the Prometheus metrics defined in the Metrics struct. The handler
-// uses the promhttp.Handler() function to generate an HTTP handler that serves the metrics in the correct format for
-// Prometheus. The handler is wrapped with a logger to log requests to the metrics endpoint.
-func initPromHandler(metrics *Metrics) http.Handler {
+// uses the promhttp.Handler() function to generate an HTTP handler that serves the metrics gathered by newGatherer in
+// the correct format for Prometheus. The OpenMetrics format is served to the scrapers asking for it.
+func initPromHandler(metrics *Metrics, clock *snapshotClock, relabelConfigs []relabelConfig) http.Handler {
+	gatherer := newGatherer(metrics, clock, relabelConfigs)
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// newGatherer returns the Gatherer of the Prometheus metrics defined in the Metrics struct, served by the exporter or
+// pushed to a sink. If clock is not nil, the samples of the gauges are stamped with the start time of the last
+// successful snapshot. The relabeling rules, if any, are applied to every series.
+// The gauges whose series are rebuilt after each snapshot are registered through the Snapshot collector, so that they
+// are gathered as a whole.
+func newGatherer(metrics *Metrics, clock *snapshotClock, relabelConfigs []relabelConfig) prometheus.Gatherer {
 	r := prometheus.NewRegistry()
-	r.MustRegister(metrics.AvailableGauge)
-	r.MustRegister(metrics.DeprecatedGauge)
-	return promhttp.HandlerFor(r, promhttp.HandlerOpts{})
+	r.MustRegister(metrics.Snapshot)
+	r.MustRegister(metrics.RDSEventsCounter)
+	r.MustRegister(metrics.MaintenanceAnnouncedGauge)
+	r.MustRegister(metrics.ConfigReloadSuccessGauge)
+	r.MustRegister(metrics.ConfigReloadTimestampGauge)
+	r.MustRegister(metrics.SnapshotPanicsCounter)
+	r.MustRegister(metrics.RemediationsCounter)
+	r.MustRegister(metrics.AccessDeniedCounter)
+	r.MustRegister(metrics.AWSErrorsCounter)
+	r.MustRegister(metrics.SkippedResourcesCounter)
+	r.MustRegister(metrics.MovedOffDeprecatedCounter)
+	r.MustRegister(metrics.CreatedOnDeprecatedCounter)
+	r.MustRegister(metrics.SeriesOverflowGauge)
+	r.MustRegister(metrics.DataAge)
+	r.MustRegister(metrics.PollIntervalGauge)
+	r.MustRegister(metrics.PolicyRefreshSuccessGauge)
+	r.MustRegister(metrics.SinkPushSuccessGauge)
+	r.MustRegister(metrics.BlackoutGauge)
+	var gatherer prometheus.Gatherer = r
+	if len(relabelConfigs) > 0 {
+		gatherer = relabelGatherer{Gatherer: gatherer, configs: relabelConfigs}
+	}
+	if clock != nil {
+		gatherer = timestampGatherer{Gatherer: gatherer, clock: clock}
+	}
+	return gatherer
 }
 
-// initHttpServer initializes the HTTP server that serves the Prometheus metrics. It sets up a new router, registers
-// the Prometheus handler with the router, and then starts a new goroutine that listens for incoming HTTP requests
-// on the specified port. If any error occurs during the setup process, the function will log the error and return it.
-func initHttpServer(handler http.Handler, addr string) *http.Server {
+// initHttpServer initializes the HTTP server that serves the Prometheus metrics. It sets up a new router, registers the
+// Prometheus handler with the router on the metrics path, e.g. /metrics, as well as the /readyz endpoint reporting
+// whether the first snapshot completed, and any additional route, and then starts a new goroutine that listens for
+// incoming HTTP requests on the specified port. If any error occurs during the setup process, the function will log the
+// error and return it.
+func initHttpServer(handler http.Handler, metricsPath string, ready *readiness, addr string,
+	routes ...route) *http.Server {
 	serveMux := http.NewServeMux()
-	serveMux.Handle("/metrics", handler)
+	serveMux.Handle(metricsPath, handler)
+	serveMux.Handle("/readyz", readyzHandler(ready))
+	for _, r := range routes {
+		serveMux.Handle(r.pattern, r.handler)
+	}
 	return &http.Server{Addr: addr, Handler: serveMux}
 }
 
-// snapshot collects and exports metrics for all RDS instances and clusters.
-// It first resets availableGauge and deprecatedGauge to zero, then fetches
-// RDS cluster infos and RDS instance infos. It merges the infos into a single
-// slice of RDSInfos, and exports the metrics for each RDSInfo. If any error
-// occurs during the metric exporting process, the function will skip the
-// problematic RDSInfo and continue exporting other RDSInfos.
+// route is an additional endpoint of the HTTP server.
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// snapshot collects and exports metrics for all RDS instances and clusters of
+// the account and region of the config matching its API filters. The metrics are not reset: see
+// snapshotScopes. It lists RDS clusters and RDS instances in parallel, or with the Resource Groups
+// Tagging API when the "tagging" discovery backend is configured. Each page of
+// RDSInfos matching the tag filters is exported as soon as it arrives by
+// exportPage, so that memory usage does not grow with the size of the
+// inventory. The Aurora Global Database topology is exported alongside when
+// enabled, and so are the catalogs of every engine and the manual DB snapshots, and the pending maintenance actions
+// are listed beforehand for the forced upgrade deadlines. If any error occurs during the metric exporting process, the
+// function will skip the problematic RDSInfo and continue exporting other
+// RDSInfos.
 //
 // The function takes an argument of type engineVersions, which is a map
-// containing a list of engine versions for each RDS engine type. It returns
-// an error if any error occurs while reading the RDS cluster/instance info
-// or while exporting the metrics.
+// containing a list of engine versions for each RDS engine type. The map is
+// populated in place, so catalogs are only fetched once per engine. It returns
+// an error if any error occurs while reading the RDS cluster/instance info,
+// the engine catalogs, or while exporting the metrics.
 func snapshot(config *Config, metrics *Metrics, m engineVersions) error {
-	metrics.AvailableGauge.Reset()
-	metrics.DeprecatedGauge.Reset()
+	// pages of clusters and instances arrive concurrently, but share the engineVersions map and the snapshotState
+	state := newSnapshotState()
+	handle := func(rdsInfos []RDSInfo) error {
+		state.mu.Lock()
+		for i := range rdsInfos {
+			rdsInfos[i].Region = config.Region
+			rdsInfos[i].Account = config.account()
+			rdsInfos[i].AccountAlias = config.AccountAlias
+		}
+		state.membership.add(rdsInfos)
+		exportDiscovered(metrics, rdsInfos)
+		kept := filterRDSInfos(rdsInfos, config.TagFilters)
+		metrics.Debug.recordFiltered(rdsInfos, kept, filterReasonTags)
+		rdsInfos = kept
+		if config.ExcludeStopped {
+			kept = excludeStopped(rdsInfos)
+			metrics.Debug.recordFiltered(rdsInfos, kept, filterReasonStopped)
+			rdsInfos = kept
+		}
+		state.mu.Unlock()
+		return exportPage(config, metrics, rdsInfos, m, state)
+	}
 
-	clusterInfos, err := getRDSClusters(config)
-	if err != nil {
-		return fmt.Errorf("failed to read RDS Cluster infos; %w", err)
+	// the pending upgrades are listed before the pages arrive, which look them up
+	now := time.Now()
+	if config.ForcedUpgrades {
+		pending, err := config.pendingCache.get(now, func() (map[string]time.Time, error) {
+			return getPendingUpgrades(config)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read RDS pending maintenance actions; %w", err)
+		}
+		state.pendingUpgrades = pending
 	}
 
-	InstanceInfos, err := getRDSInstances(config)
-	if err != nil {
-		return fmt.Errorf("failed to read RDS Instance infos; %w", err)
+	// so are the reserved DB instances, which cover the instances of the pages
+	if config.Reservations {
+		reserved, err := config.reservedCache.get(now, func() ([]ReservedInstanceInfo, error) {
+			return getReservedInstances(config)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read RDS reserved DB instances; %w", err)
+		}
+		exportReservedInstances(metrics, config.account(), config.Region, reserved)
+		state.reservations = newReservations(reserved)
+	}
+
+	// the catalogs of every engine are fetched at once when they are exported, until they are refreshed
+	if config.CatalogInfo && len(m) == 0 {
+		catalogs, err := queryAllEngineVersions(config)
+		if err != nil {
+			return fmt.Errorf("failed to read RDS Engine versions; %w", err)
+		}
+		for engine, catalog := range catalogs {
+			m[engine] = catalog
+		}
+		metrics.Deprecations.observeAll(config.Region, catalogs)
+	}
+
+	tasks := make([]func() error, 0)
+	if config.DiscoveryBackend == discoveryBackendTagging {
+		tasks = append(tasks, func() error {
+			if err := getTaggedRDSResources(config, handle); err != nil {
+				return fmt.Errorf("failed to read RDS tagged resources; %w", err)
+			}
+			return nil
+		})
+	} else {
+		tasks = append(tasks,
+			func() error {
+				if err := getRDSClusters(config, config.ClusterFilters, handle); err != nil {
+					return fmt.Errorf("failed to read RDS Cluster infos; %w", err)
+				}
+				return nil
+			},
+			func() error {
+				if err := getRDSInstances(config, config.InstanceFilters, handle); err != nil {
+					return fmt.Errorf("failed to read RDS Instance infos; %w", err)
+				}
+				return nil
+			},
+		)
+	}
+
+	if config.GlobalClusters {
+		tasks = append(tasks, func() error {
+			if err := exportGlobalClusters(config, metrics); err != nil {
+				return fmt.Errorf("failed to read RDS Global Cluster infos; %w", err)
+			}
+			return nil
+		})
+	}
+
+	if config.DBSnapshots {
+		tasks = append(tasks, func() error {
+			snapshots, err := config.snapshotsCache.get(now, func() ([]DBSnapshotInfo, error) {
+				return getDBSnapshots(config)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to read RDS snapshot infos; %w", err)
+			}
+			return exportDBSnapshots(config, metrics, snapshots, m, &state.mu)
+		})
+	}
+
+	if config.HealthEvents {
+		tasks = append(tasks, func() error {
+			if err := exportHealthEvents(config, metrics); err != nil {
+				return fmt.Errorf("failed to read AWS Health events; %w", err)
+			}
+			return nil
+		})
+	}
+
+	if err := runPool(config.Concurrency, tasks); err != nil {
+		return err
+	}
+	if config.CatalogInfo {
+		exportCatalogInfo(metrics, config.account(), config.Region, m)
 	}
+	exportCatalogSizes(metrics, config, m)
+	if config.Remediation != nil {
+		remediateAutoMinorUpgrades(config, metrics, state.remediations)
+	}
+	return nil
+}
+
+// snapshotState holds the caches shared by the pages of a single snapshot.
+type snapshotState struct {
+	// mu guards the snapshotState, the engineVersions map and the metrics written by the pages. It is not held during
+	// the AWS calls, so that the pages are exported concurrently.
+	mu sync.Mutex
+
+	// membership caches the role of Aurora cluster members.
+	membership *clusterMembership
+
+	// babelfish caches whether the cluster parameter groups enable Babelfish.
+	babelfish babelfishGroups
+
+	// orderable caches whether instance classes are orderable for an engine version.
+	orderable map[orderableKey]bool
 
-	rdsInfos := clusterInfos
-	rdsInfos = append(rdsInfos, InstanceInfos...)
+	// pendingUpgrades holds the date from which the pending engine upgrades are applied automatically, keyed by the
+	// resource of their ARN.
+	pendingUpgrades map[string]time.Time
 
+	// reservations tracks the instances left to cover by the reserved DB instances. It is nil unless they are checked.
+	reservations *reservations
+
+	// remediations holds the instances selected by the remediation policy, remediated once every page is exported.
+	remediations []RDSInfo
+
+	// refetched holds the engines whose catalog was fetched again, as it lacked the version of a resource.
+	refetched map[string]bool
+}
+
+// newSnapshotState returns an empty snapshotState.
+func newSnapshotState() *snapshotState {
+	return &snapshotState{
+		membership: newClusterMembership(),
+		babelfish:  make(babelfishGroups),
+		orderable:  make(map[orderableKey]bool),
+		refetched:  make(map[string]bool),
+	}
+}
+
+// exportPage fetches the engine catalogs of the engines used by a page of RDSInfos that are not yet known, or that lack
+// the version of one of them, once per snapshot, adds them to the engineVersions map, resolves the role of the Aurora
+// cluster members of the page, and then exports the metrics for each RDSInfo of the page, including the upgrade targets
+// of its version, the members of clusters, and the forced upgrade deadline, the days until the end of standard support,
+// the reserved DB instance coverage, the cost of Extended Support, the recommended upgrade and the instance class check
+// when enabled, its owner if it is mapped to one, and whether it is stopped, and records it in the Inventory. The AWS
+// calls are made first, without the lock of the snapshotState, which is only held while the metrics are written.
+// An RDSInfo whose engine or version is still missing from the catalogs is logged, counted by the
+// SkippedResourcesCounter and skipped, and the rest of the page is exported.
+func exportPage(config *Config, metrics *Metrics, rdsInfos []RDSInfo, m engineVersions, state *snapshotState) error {
+	if err := addMissingEngineVersions(config, metrics, rdsInfos, m, state.refetched, &state.mu); err != nil {
+		return err
+	}
+	if err := state.membership.resolve(config, rdsInfos, &state.mu); err != nil {
+		return err
+	}
+	if config.Babelfish {
+		if err := state.babelfish.resolve(config, rdsInfos, state.membership, &state.mu); err != nil {
+			return err
+		}
+	}
+
+	if config.InstanceClasses {
+		for _, rdsInfo := range rdsInfos {
+			exportInstanceClass(config, metrics, rdsInfo, state.orderable, &state.mu)
+		}
+	}
+
+	// the AWS calls are done, the gauges are written with the lock held
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.membership.setRoles(rdsInfos)
 	for _, rdsInfo := range rdsInfos {
-		err := export(metrics, rdsInfo, m)
-		if err != nil {
-			return fmt.Errorf("skip: rdsInfo %#v; failed to export metric; %w", rdsInfo, err)
+		if err := export(metrics, rdsInfo, m); err != nil {
+			// the resource is skipped, rather than the rest of the page and the whole region
+			log.Print(err)
+			metrics.SkippedResourcesCounter.WithLabelValues(rdsInfo.Engine, rdsInfo.Account, rdsInfo.Region).Inc()
+			metrics.Debug.recordFiltered([]RDSInfo{rdsInfo}, nil, filterReasonUnknownVersion)
+			continue
+		}
+		recordInventory(config, metrics, rdsInfo, m, state)
+		exportUpgradeTargets(metrics, rdsInfo, m)
+		exportVersionStatus(metrics, rdsInfo, m)
+		exportMajorDeprecated(metrics, rdsInfo, m)
+		exportParameterGroupFamily(metrics, rdsInfo, m)
+		exportStorage(metrics, rdsInfo)
+		exportClusterMembers(metrics, rdsInfo)
+		exportClusterVersionMismatch(metrics, rdsInfo, state.membership)
+		if err := exportMaintenanceWindow(metrics, rdsInfo, time.Now()); err != nil {
+			return fmt.Errorf("skip: rdsInfo %#v; failed to export maintenance window metric; %w", rdsInfo, err)
+		}
+		if config.ForcedUpgrades {
+			exportForcedUpgradeDeadline(metrics, rdsInfo, m, config.calendar(), state.pendingUpgrades)
+		}
+		if config.StandardSupport {
+			exportStandardSupport(metrics, rdsInfo, config.calendar(), time.Now())
 		}
+		exportReservedCoverage(metrics, rdsInfo, m, state.reservations)
+		if config.ExtendedSupport {
+			exportExtendedSupportCost(metrics, rdsInfo, config.calendar(), time.Now())
+		}
+		if config.Recommendations != nil {
+			exportRecommendedUpgrade(metrics, rdsInfo, m, *config.Recommendations)
+		}
+		exportOwner(metrics, rdsInfo, config.Owners)
+		if config.Remediation != nil && config.Remediation.selects(rdsInfo) {
+			state.remediations = append(state.remediations, rdsInfo)
+		}
+		exportStopped(metrics, rdsInfo)
+		exportIncompatible(metrics, rdsInfo)
 	}
 
 	return nil
@@ -226,8 +1733,9 @@ func snapshot(config *Config, metrics *Metrics, m engineVersions) error {
 // export collects RDS info and validates its engine version against the
 // engineVersions struct that is provided. If the version is deprecated,
 // it will set the deprecatedGauge prometheus metric to 1 and the availableGauge
-// metric to 0. Otherwise, it sets the deprecatedGauge to 0 and the availableGauge
-// to 1. It returns an error if the validation process or metric setting process fails.
+// metric to 0, unless the resource is in grace or acknowledged. Otherwise, it sets the deprecatedGauge to 0 and the
+// availableGauge to 1. The resource is counted in the fleet totals as well. It returns an error, and exports nothing,
+// if the engine or the version of the resource is missing from the engineVersions.
 //
 // Example usage:
 //
@@ -241,44 +1749,61 @@ func export(metrics *Metrics, rdsInfo RDSInfo, m engineVersions) error {
 		return fmt.Errorf("failed to validate engine version: %w; skip rdsInfo: %#v", err, rdsInfo)
 	}
 
+	major, minor := splitEngineVersion(rdsInfo.Engine, rdsInfo.EngineVersion)
 	newLabels := prometheus.Labels{
-		"cluster_identifier": rdsInfo.ClusterIdentifier,
-		"engine":             rdsInfo.Engine,
-		"engine_version":     rdsInfo.EngineVersion,
+		"cluster_identifier":   rdsInfo.ClusterIdentifier,
+		"engine":               rdsInfo.Engine,
+		"engine_version":       rdsInfo.EngineVersion,
+		"engine_version_major": major,
+		"engine_version_minor": minor,
+		"role":                 rdsInfo.Role,
+		"license_model":        rdsInfo.LicenseModel,
+		"edition":              rdsInfo.Edition,
+		"rds_custom":           strconv.FormatBool(isRDSCustom(rdsInfo.Engine)),
+		"babelfish":            strconv.FormatBool(rdsInfo.Babelfish),
+		"maintenance_window":   rdsInfo.MaintenanceWindow,
+		"account_id":           rdsInfo.Account,
+		"region":               rdsInfo.Region,
 	}
 
-	if valid {
-		metrics.DeprecatedGauge.With(newLabels).Set(0)
-		metrics.AvailableGauge.With(newLabels).Set(1)
-	} else {
-		metrics.DeprecatedGauge.With(newLabels).Set(1)
-		metrics.AvailableGauge.With(newLabels).Set(0)
+	exportFleetCounts(metrics, rdsInfo, !valid)
+	deprecated, grace, acknowledged := deprecationValues(metrics, rdsInfo, valid)
+	metrics.DeprecatedGauge.With(newLabels).Set(deprecated)
+	metrics.AvailableGauge.With(newLabels).Set(1 - deprecated - grace - acknowledged)
+	if metrics.Deprecations != nil {
+		metrics.GraceGauge.With(newLabels).Set(grace)
+	}
+	if metrics.Acknowledgements != nil {
+		metrics.AcknowledgedGauge.With(newLabels).Set(acknowledged)
 	}
 	return nil
 }
 
-// getRDSClusters returns a slice of RDSInfo, which includes the identifiers and versions
-// of all Amazon RDS clusters for the current AWS account and region.
-// An error is returned if the function fails to retrieve cluster information.
-func getRDSClusters(config *Config) ([]RDSInfo, error) {
-	rdsInfos := make([]RDSInfo, 0)
+// getRDSClusters lists the identifiers and versions of all Amazon RDS clusters for the current AWS account and region
+// matching the given API filters, and calls handle with the RDSInfos of each page as soon as it is received, so that
+// pages are never accumulated.
+// An error is returned if the function fails to retrieve cluster information or if handle returns an error.
+func getRDSClusters(config *Config, filters []*rds.Filter, handle func([]RDSInfo) error) error {
 	var nextMarker *string
 	condition := true
 	for condition {
 		rdsClusters, err := config.RDS.DescribeDBClusters(&rds.DescribeDBClustersInput{
-			Marker: nextMarker,
+			Filters: filters,
+			Marker:  nextMarker,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to describe DB instances; %w", err)
+			return fmt.Errorf("failed to describe DB instances; %w", err)
 		}
 		if rdsClusters == nil {
 			break
 		}
-		rdsInfos = append(rdsInfos, handleRDSClusters(rdsClusters)...)
+		if err := handle(handleRDSClusters(rdsClusters)); err != nil {
+			return err
+		}
 		nextMarker = rdsClusters.Marker
 		condition = nextMarker != nil
 	}
-	return rdsInfos, nil
+	return nil
 }
 
 // handleRDSClusters receives a slice of RDSInfo structs representing Amazon RDS clusters and validates their engine
@@ -292,35 +1817,45 @@ func handleRDSClusters(rdsClusters *rds.DescribeDBClustersOutput) []RDSInfo {
 			ClusterIdentifier: *rdsCluster.DBClusterIdentifier,
 			Engine:            *rdsCluster.Engine,
 			EngineVersion:     *rdsCluster.EngineVersion,
+			Tags:              tagsToMap(rdsCluster.TagList),
+			Members:           clusterMembers(rdsCluster),
+			MaintenanceWindow: aws.StringValue(rdsCluster.PreferredMaintenanceWindow),
+			ResourceType:      resourceTypeCluster,
+			ResourceID:        aws.StringValue(rdsCluster.DbClusterResourceId),
+			Status:            aws.StringValue(rdsCluster.Status),
+			ParameterGroup:    aws.StringValue(rdsCluster.DBClusterParameterGroup),
 		}
 		rdsInfos = append(rdsInfos, RDSInfo)
 	}
 	return rdsInfos
 }
 
-// getRDSInstances retrieves information about all RDS instances in the AWS account
-// and returns a slice of RDSInfo objects containing the ClusterIdentifier, Engine and EngineVersion.
+// getRDSInstances retrieves information about all RDS instances in the AWS account matching the given API filters, and
+// calls handle with a slice of RDSInfo objects containing the ClusterIdentifier, Engine, EngineVersion and Tags of each
+// page as soon as it is received.
 // It uses the AWS SDK for Go to interact with the RDS service.
-// If the function fails to retrieve the information, it returns an error.
-func getRDSInstances(config *Config) ([]RDSInfo, error) {
-	rdsInfos := make([]RDSInfo, 0)
+// If the function fails to retrieve the information or if handle returns an error, it returns an error.
+func getRDSInstances(config *Config, filters []*rds.Filter, handle func([]RDSInfo) error) error {
 	var nextMarker *string
 	condition := true
 	for condition {
 		rdsInstances, err := config.RDS.DescribeDBInstances(&rds.DescribeDBInstancesInput{
-			Marker: nextMarker,
+			Filters: filters,
+			Marker:  nextMarker,
 		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to describe DB instances; %w", err)
+			return fmt.Errorf("failed to describe DB instances; %w", err)
 		}
 		if rdsInstances == nil {
 			break
 		}
-		rdsInfos = append(rdsInfos, handleRDSInstances(rdsInstances)...)
+		if err := handle(handleRDSInstances(rdsInstances)); err != nil {
+			return err
+		}
 		nextMarker = rdsInstances.Marker
 		condition = nextMarker != nil
 	}
-	return rdsInfos, nil
+	return nil
 }
 
 // handleRDSInstances receives a slice of RDSInfo structs representing Amazon RDS instances and validates their engine
@@ -330,10 +1865,25 @@ func getRDSInstances(config *Config) ([]RDSInfo, error) {
 func handleRDSInstances(rdsInstances *rds.DescribeDBInstancesOutput) []RDSInfo {
 	rdsInfos := make([]RDSInfo, 0)
 	for _, rdsInstance := range rdsInstances.DBInstances {
+		licenseModel, edition := commercialEngineLabels(*rdsInstance.Engine, aws.StringValue(rdsInstance.LicenseModel))
 		RDSInfo := RDSInfo{
 			ClusterIdentifier: *rdsInstance.DBInstanceIdentifier,
 			Engine:            *rdsInstance.Engine,
 			EngineVersion:     *rdsInstance.EngineVersion,
+			Tags:              tagsToMap(rdsInstance.TagList),
+			MemberOf:          aws.StringValue(rdsInstance.DBClusterIdentifier),
+			InstanceClass:     aws.StringValue(rdsInstance.DBInstanceClass),
+			MaintenanceWindow: aws.StringValue(rdsInstance.PreferredMaintenanceWindow),
+			LicenseModel:      licenseModel,
+			Edition:           edition,
+			ResourceType:      resourceTypeInstance,
+			ResourceID:        aws.StringValue(rdsInstance.DbiResourceId),
+			Status:            aws.StringValue(rdsInstance.DBInstanceStatus),
+			StorageType:       aws.StringValue(rdsInstance.StorageType),
+			Iops:              aws.Int64Value(rdsInstance.Iops),
+			AllocatedStorage:  aws.Int64Value(rdsInstance.AllocatedStorage),
+
+			AutoMinorVersionUpgrade: aws.BoolValue(rdsInstance.AutoMinorVersionUpgrade),
 		}
 		rdsInfos = append(rdsInfos, RDSInfo)
 	}
@@ -26,13 +26,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"testing"
+	"time"
 )
 
 const serverPort = "2112"
@@ -43,21 +48,120 @@ const metricsPath = "/metrics"
 
 type MockRDSAPI struct {
 	rdsiface.RDSAPI
-	instancesOutput      []*rds.DescribeDBInstancesOutput
-	clustersOutput       []*rds.DescribeDBClustersOutput
-	engineVersionsOutput []*rds.DescribeDBEngineVersionsOutput
-	err                  error
+	instancesOutput        []*rds.DescribeDBInstancesOutput
+	clustersOutput         []*rds.DescribeDBClustersOutput
+	engineVersionsOutput   []*rds.DescribeDBEngineVersionsOutput
+	globalClustersOutput   []*rds.DescribeGlobalClustersOutput
+	orderableOutput        []*rds.DescribeOrderableDBInstanceOptionsOutput
+	clusterSnapshotsOutput []*rds.DescribeDBClusterSnapshotsOutput
+	snapshotsOutput        []*rds.DescribeDBSnapshotsOutput
+	pendingOutput          []*rds.DescribePendingMaintenanceActionsOutput
+	reservedOutput         []*rds.DescribeReservedDBInstancesOutput
+	err                    error
 }
 
 func (m MockRDSAPI) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
-	return getSafe(m.instancesOutput, input.Marker, m.err)
+	output, err := getSafe(m.instancesOutput, input.Marker, m.err)
+	arns := filterValues(input.Filters, "db-instance-id")
+	if output == nil || arns == nil {
+		return output, err
+	}
+	// Emulate the server-side ARN filter
+	filtered := &rds.DescribeDBInstancesOutput{Marker: output.Marker}
+	for _, dbInstance := range output.DBInstances {
+		if contains(arns, aws.StringValue(dbInstance.DBInstanceArn)) {
+			filtered.DBInstances = append(filtered.DBInstances, dbInstance)
+		}
+	}
+	return filtered, nil
 }
 func (m MockRDSAPI) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
-	return getSafe(m.clustersOutput, input.Marker, m.err)
+	output, err := getSafe(m.clustersOutput, input.Marker, m.err)
+	arns := filterValues(input.Filters, "db-cluster-id")
+	if output == nil || arns == nil {
+		return output, err
+	}
+	// Emulate the server-side identifier or ARN filter
+	filtered := &rds.DescribeDBClustersOutput{Marker: output.Marker}
+	for _, dbCluster := range output.DBClusters {
+		if contains(arns, aws.StringValue(dbCluster.DBClusterArn)) || contains(arns, aws.StringValue(dbCluster.DBClusterIdentifier)) {
+			filtered.DBClusters = append(filtered.DBClusters, dbCluster)
+		}
+	}
+	return filtered, nil
+}
+
+func (m MockRDSAPI) DescribeDBClusterSnapshots(input *rds.DescribeDBClusterSnapshotsInput) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	return getSafe(m.clusterSnapshotsOutput, input.Marker, m.err)
+}
+
+func (m MockRDSAPI) DescribeDBSnapshots(input *rds.DescribeDBSnapshotsInput) (*rds.DescribeDBSnapshotsOutput, error) {
+	return getSafe(m.snapshotsOutput, input.Marker, m.err)
+}
+
+func (m MockRDSAPI) DescribePendingMaintenanceActions(input *rds.DescribePendingMaintenanceActionsInput) (*rds.DescribePendingMaintenanceActionsOutput, error) {
+	return getSafe(m.pendingOutput, input.Marker, m.err)
+}
+
+func (m MockRDSAPI) DescribeReservedDBInstances(input *rds.DescribeReservedDBInstancesInput) (*rds.DescribeReservedDBInstancesOutput, error) {
+	return getSafe(m.reservedOutput, input.Marker, m.err)
+}
+
+func (m MockRDSAPI) DescribeGlobalClusters(input *rds.DescribeGlobalClustersInput) (*rds.DescribeGlobalClustersOutput, error) {
+	return getSafe(m.globalClustersOutput, input.Marker, m.err)
+}
+
+func (m MockRDSAPI) DescribeOrderableDBInstanceOptions(input *rds.DescribeOrderableDBInstanceOptionsInput) (*rds.DescribeOrderableDBInstanceOptionsOutput, error) {
+	output, err := getSafe(m.orderableOutput, input.Marker, m.err)
+	if output == nil {
+		return output, err
+	}
+	// Emulate the server-side engine, engine version and instance class filters; the engine version is optional
+	filtered := &rds.DescribeOrderableDBInstanceOptionsOutput{Marker: output.Marker}
+	for _, option := range output.OrderableDBInstanceOptions {
+		if *option.Engine == *input.Engine && *option.DBInstanceClass == *input.DBInstanceClass &&
+			(input.EngineVersion == nil || *option.EngineVersion == *input.EngineVersion) {
+			filtered.OrderableDBInstanceOptions = append(filtered.OrderableDBInstanceOptions, option)
+		}
+	}
+	return filtered, nil
 }
 
 func (m MockRDSAPI) DescribeDBEngineVersions(input *rds.DescribeDBEngineVersionsInput) (*rds.DescribeDBEngineVersionsOutput, error) {
-	return getSafe(m.engineVersionsOutput, input.Marker, m.err)
+	output, err := getSafe(m.engineVersionsOutput, input.Marker, m.err)
+	if output == nil || input.Engine == nil {
+		return output, err
+	}
+	// Emulate the server-side engine filter
+	filtered := &rds.DescribeDBEngineVersionsOutput{Marker: output.Marker}
+	for _, dbEngineVersion := range output.DBEngineVersions {
+		if *dbEngineVersion.Engine == *input.Engine {
+			filtered.DBEngineVersions = append(filtered.DBEngineVersions, dbEngineVersion)
+		}
+	}
+	return filtered, nil
+}
+
+type MockTaggingAPI struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	resourcesOutput []*resourcegroupstaggingapi.GetResourcesOutput
+	inputs          []*resourcegroupstaggingapi.GetResourcesInput
+	err             error
+}
+
+func (m *MockTaggingAPI) GetResources(input *resourcegroupstaggingapi.GetResourcesInput) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return getSafe(m.resourcesOutput, input.PaginationToken, m.err)
+}
+
+// filterValues returns the values of the API filter with the given name, or nil if there is no such filter.
+func filterValues(filters []*rds.Filter, name string) []string {
+	for _, filter := range filters {
+		if aws.StringValue(filter.Name) == name {
+			return aws.StringValueSlice(filter.Values)
+		}
+	}
+	return nil
 }
 
 func getSafe[T []*Y, Y any](v T, inputMarker *string, err error) (*Y, error) {
@@ -101,10 +205,65 @@ func TestGetEnvInteger(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGetEnvIntegerWithDefault(t *testing.T) {
+	// Test with unset variable
+	i, err := getEnvIntegerWithDefault("TEST_UNSET_VAR", 42)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, i)
+
+	// Test with valid integer string
+	setEnv(t, "TEST_VAR", "123")
+	i, err = getEnvIntegerWithDefault("TEST_VAR", 42)
+	assert.NoError(t, err)
+	assert.Equal(t, 123, i)
+
+	// Test with invalid integer string
+	setEnv(t, "TEST_VAR", "foo")
+	_, err = getEnvIntegerWithDefault("TEST_VAR", 42)
+	assert.Error(t, err)
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		desc    string
+		env     map[string]string
+		want    time.Duration
+		wantErr bool
+	}{
+		{desc: "unset", env: map[string]string{}, want: time.Minute},
+		{desc: "duration string", env: map[string]string{"TEST_DURATION": "90s"}, want: 90 * time.Second},
+		{desc: "legacy integer seconds", env: map[string]string{"TEST_DURATION_SECONDS": "300"}, want: 5 * time.Minute},
+		{
+			desc: "first variable wins",
+			env:  map[string]string{"TEST_DURATION": "1h", "TEST_DURATION_SECONDS": "300"},
+			want: time.Hour,
+		},
+		{desc: "invalid", env: map[string]string{"TEST_DURATION": "5 minutes"}, wantErr: true},
+		{desc: "negative", env: map[string]string{"TEST_DURATION": "-5m"}, wantErr: true},
+		{desc: "zero", env: map[string]string{"TEST_DURATION_SECONDS": "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			for _, name := range []string{"TEST_DURATION", "TEST_DURATION_SECONDS"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			got, err := getEnvDuration(time.Minute, "TEST_DURATION", "TEST_DURATION_SECONDS")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestSnapshot(t *testing.T) {
 	m := engineVersions{
-		"MySQL":      {"5.7.34": true, "8.0.25": false},
-		"PostgreSQL": {"9.5.24": true, "13.2": false},
+		"MySQL":      {"5.7.34": {Status: "deprecated"}, "8.0.25": {Status: "available"}},
+		"PostgreSQL": {"9.5.24": {Status: "deprecated"}, "13.2": {Status: "available"}},
 	}
 	tests := []struct {
 		desc    string
@@ -148,25 +307,244 @@ func TestSnapshot(t *testing.T) {
 					},
 				},
 			}},
-			want: `# HELP aws_custom_rds_version_available Number of instances whose version is available
+			want: `# HELP aws_custom_rds_available_total Number of resources whose version is available, per engine, account and region
+# TYPE aws_custom_rds_available_total gauge
+aws_custom_rds_available_total{account_alias="",account_id="default",engine="MySQL",region=""} 1
+aws_custom_rds_available_total{account_alias="",account_id="default",engine="PostgreSQL",region=""} 1
+# HELP aws_custom_rds_blackout Whether a blackout window is open, during which no AWS API call is made and the last known data is served
+# TYPE aws_custom_rds_blackout gauge
+aws_custom_rds_blackout 0
+# HELP aws_custom_rds_catalog_engines Number of engines of the engine catalogs of a region
+# TYPE aws_custom_rds_catalog_engines gauge
+aws_custom_rds_catalog_engines{account_id="default",region=""} 2
+# HELP aws_custom_rds_catalog_versions Number of versions of the engine catalog of a region
+# TYPE aws_custom_rds_catalog_versions gauge
+aws_custom_rds_catalog_versions{account_id="default",engine="MySQL",region=""} 2
+aws_custom_rds_catalog_versions{account_id="default",engine="PostgreSQL",region=""} 2
+# HELP aws_custom_rds_deprecated_ratio Ratio of the resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_ratio gauge
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="MySQL",region=""} 0.5
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="PostgreSQL",region=""} 0.5
+# HELP aws_custom_rds_deprecated_total Number of resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_total gauge
+aws_custom_rds_deprecated_total{account_alias="",account_id="default",engine="MySQL",region=""} 1
+aws_custom_rds_deprecated_total{account_alias="",account_id="default",engine="PostgreSQL",region=""} 1
+# HELP aws_custom_rds_discovered_resources Number of clusters and instances discovered, before they are filtered
+# TYPE aws_custom_rds_discovered_resources gauge
+aws_custom_rds_discovered_resources{account_id="default",engine="MySQL",region=""} 2
+aws_custom_rds_discovered_resources{account_id="default",engine="PostgreSQL",region=""} 2
+# HELP aws_custom_rds_major_version_deprecated Resources whose major version has no available version left, which need a major version upgrade
+# TYPE aws_custom_rds_major_version_deprecated gauge
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="cluster-1",engine="MySQL",engine_version_major="5",region=""} 1
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="cluster-1",engine="MySQL",engine_version_major="8",region=""} 0
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="cluster-1",engine="PostgreSQL",engine_version_major="13",region=""} 0
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="cluster-1",engine="PostgreSQL",engine_version_major="9",region=""} 1
+# HELP aws_custom_rds_poll_interval_seconds Current interval between two snapshots, backed off after consecutive failed snapshots
+# TYPE aws_custom_rds_poll_interval_seconds gauge
+aws_custom_rds_poll_interval_seconds 0
+# HELP aws_custom_rds_series_overflow Number of series left out of the per-resource metrics by the series cap in the last snapshot
+# TYPE aws_custom_rds_series_overflow gauge
+aws_custom_rds_series_overflow 0
+# HELP aws_custom_rds_snapshot_panics_total Number of panics recovered while taking snapshots
+# TYPE aws_custom_rds_snapshot_panics_total counter
+aws_custom_rds_snapshot_panics_total 0
+# HELP aws_custom_rds_upgrade_targets Number of valid minor or major upgrade targets of the engine versions in use
+# TYPE aws_custom_rds_upgrade_targets gauge
+aws_custom_rds_upgrade_targets{account_id="default",engine="MySQL",engine_version="5.7.34",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="MySQL",engine_version="5.7.34",region="",upgrade="minor"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="MySQL",engine_version="8.0.25",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="MySQL",engine_version="8.0.25",region="",upgrade="minor"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="PostgreSQL",engine_version="13.2",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="PostgreSQL",engine_version="13.2",region="",upgrade="minor"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="PostgreSQL",engine_version="9.5.24",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="PostgreSQL",engine_version="9.5.24",region="",upgrade="minor"} 0
+# HELP aws_custom_rds_version_available Number of instances whose version is available
+# TYPE aws_custom_rds_version_available gauge
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="MySQL",engine_version="5.7.34",engine_version_major="5",engine_version_minor="7.34",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 0
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="MySQL",engine_version="8.0.25",engine_version_major="8",engine_version_minor="0.25",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 1
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="PostgreSQL",engine_version="13.2",engine_version_major="13",engine_version_minor="2",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 1
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="PostgreSQL",engine_version="9.5.24",engine_version_major="9",engine_version_minor="5.24",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 0
+# HELP aws_custom_rds_version_deprecated Number of instances whose Version is deprecated
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="MySQL",engine_version="5.7.34",engine_version_major="5",engine_version_minor="7.34",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 1
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="MySQL",engine_version="8.0.25",engine_version_major="8",engine_version_minor="0.25",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 0
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="PostgreSQL",engine_version="13.2",engine_version_major="13",engine_version_minor="2",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 0
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="cluster-1",edition="",engine="PostgreSQL",engine_version="9.5.24",engine_version_major="9",engine_version_minor="5.24",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 1
+# HELP aws_custom_rds_version_status_info Raw catalog status of the engine versions in use, and whether it is classified as available, deprecated or unknown
+# TYPE aws_custom_rds_version_status_info gauge
+aws_custom_rds_version_status_info{account_id="default",classification="available",engine="MySQL",engine_version="8.0.25",region="",status="available"} 1
+aws_custom_rds_version_status_info{account_id="default",classification="available",engine="PostgreSQL",engine_version="13.2",region="",status="available"} 1
+aws_custom_rds_version_status_info{account_id="default",classification="deprecated",engine="MySQL",engine_version="5.7.34",region="",status="deprecated"} 1
+aws_custom_rds_version_status_info{account_id="default",classification="deprecated",engine="PostgreSQL",engine_version="9.5.24",region="",status="deprecated"} 1
+`,
+			wantErr: nil,
+		},
+		{
+			desc: "successful snapshot fetching the catalog of an unknown engine",
+			config: &Config{RDS: &MockRDSAPI{
+				instancesOutput: []*rds.DescribeDBInstancesOutput{
+					{
+						DBInstances: []*rds.DBInstance{
+							{
+								DBInstanceIdentifier: Ptr("cluster-2"),
+								Engine:               Ptr("MariaDB"),
+								EngineVersion:        Ptr("10.6.5"),
+							},
+						},
+					},
+				},
+				engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{
+					{
+						DBEngineVersions: []*rds.DBEngineVersion{
+							{
+								Engine:        Ptr("MariaDB"),
+								EngineVersion: Ptr("10.6.5"),
+								Status:        Ptr("deprecated"),
+							},
+						},
+					},
+				},
+			}},
+			want: `# HELP aws_custom_rds_blackout Whether a blackout window is open, during which no AWS API call is made and the last known data is served
+# TYPE aws_custom_rds_blackout gauge
+aws_custom_rds_blackout 0
+# HELP aws_custom_rds_catalog_engines Number of engines of the engine catalogs of a region
+# TYPE aws_custom_rds_catalog_engines gauge
+aws_custom_rds_catalog_engines{account_id="default",region=""} 3
+# HELP aws_custom_rds_catalog_versions Number of versions of the engine catalog of a region
+# TYPE aws_custom_rds_catalog_versions gauge
+aws_custom_rds_catalog_versions{account_id="default",engine="MariaDB",region=""} 1
+aws_custom_rds_catalog_versions{account_id="default",engine="MySQL",region=""} 2
+aws_custom_rds_catalog_versions{account_id="default",engine="PostgreSQL",region=""} 2
+# HELP aws_custom_rds_deprecated_ratio Ratio of the resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_ratio gauge
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="MariaDB",region=""} 1
+# HELP aws_custom_rds_deprecated_total Number of resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_total gauge
+aws_custom_rds_deprecated_total{account_alias="",account_id="default",engine="MariaDB",region=""} 1
+# HELP aws_custom_rds_discovered_resources Number of clusters and instances discovered, before they are filtered
+# TYPE aws_custom_rds_discovered_resources gauge
+aws_custom_rds_discovered_resources{account_id="default",engine="MariaDB",region=""} 1
+# HELP aws_custom_rds_major_version_deprecated Resources whose major version has no available version left, which need a major version upgrade
+# TYPE aws_custom_rds_major_version_deprecated gauge
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="cluster-2",engine="MariaDB",engine_version_major="10",region=""} 1
+# HELP aws_custom_rds_poll_interval_seconds Current interval between two snapshots, backed off after consecutive failed snapshots
+# TYPE aws_custom_rds_poll_interval_seconds gauge
+aws_custom_rds_poll_interval_seconds 0
+# HELP aws_custom_rds_series_overflow Number of series left out of the per-resource metrics by the series cap in the last snapshot
+# TYPE aws_custom_rds_series_overflow gauge
+aws_custom_rds_series_overflow 0
+# HELP aws_custom_rds_snapshot_panics_total Number of panics recovered while taking snapshots
+# TYPE aws_custom_rds_snapshot_panics_total counter
+aws_custom_rds_snapshot_panics_total 0
+# HELP aws_custom_rds_upgrade_targets Number of valid minor or major upgrade targets of the engine versions in use
+# TYPE aws_custom_rds_upgrade_targets gauge
+aws_custom_rds_upgrade_targets{account_id="default",engine="MariaDB",engine_version="10.6.5",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="MariaDB",engine_version="10.6.5",region="",upgrade="minor"} 0
+# HELP aws_custom_rds_version_available Number of instances whose version is available
+# TYPE aws_custom_rds_version_available gauge
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="cluster-2",edition="",engine="MariaDB",engine_version="10.6.5",engine_version_major="10",engine_version_minor="6.5",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 0
+# HELP aws_custom_rds_version_deprecated Number of instances whose Version is deprecated
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="cluster-2",edition="",engine="MariaDB",engine_version="10.6.5",engine_version_major="10",engine_version_minor="6.5",license_model="",maintenance_window="",rds_custom="false",region="",role=""} 1
+# HELP aws_custom_rds_version_status_info Raw catalog status of the engine versions in use, and whether it is classified as available, deprecated or unknown
+# TYPE aws_custom_rds_version_status_info gauge
+aws_custom_rds_version_status_info{account_id="default",classification="deprecated",engine="MariaDB",engine_version="10.6.5",region="",status="deprecated"} 1
+`,
+			wantErr: nil,
+		},
+		{
+			desc: "successful snapshot of an RDS Custom instance running a Custom Engine Version",
+			config: &Config{RDS: &MockRDSAPI{
+				instancesOutput: []*rds.DescribeDBInstancesOutput{
+					{
+						DBInstances: []*rds.DBInstance{
+							{
+								DBInstanceIdentifier: Ptr("custom-1"),
+								Engine:               Ptr("custom-oracle-ee"),
+								EngineVersion:        Ptr("19.my_cev1"),
+								LicenseModel:         Ptr("bring-your-own-license"),
+							},
+						},
+					},
+				},
+				engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{
+					{
+						DBEngineVersions: []*rds.DBEngineVersion{
+							{
+								Engine:        Ptr("custom-oracle-ee"),
+								EngineVersion: Ptr("19.my_cev1"),
+								Status:        Ptr("inactive"),
+							},
+						},
+					},
+				},
+			}},
+			want: `# HELP aws_custom_rds_blackout Whether a blackout window is open, during which no AWS API call is made and the last known data is served
+# TYPE aws_custom_rds_blackout gauge
+aws_custom_rds_blackout 0
+# HELP aws_custom_rds_catalog_engines Number of engines of the engine catalogs of a region
+# TYPE aws_custom_rds_catalog_engines gauge
+aws_custom_rds_catalog_engines{account_id="default",region=""} 4
+# HELP aws_custom_rds_catalog_versions Number of versions of the engine catalog of a region
+# TYPE aws_custom_rds_catalog_versions gauge
+aws_custom_rds_catalog_versions{account_id="default",engine="MariaDB",region=""} 1
+aws_custom_rds_catalog_versions{account_id="default",engine="MySQL",region=""} 2
+aws_custom_rds_catalog_versions{account_id="default",engine="PostgreSQL",region=""} 2
+aws_custom_rds_catalog_versions{account_id="default",engine="custom-oracle-ee",region=""} 1
+# HELP aws_custom_rds_deprecated_ratio Ratio of the resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_ratio gauge
+aws_custom_rds_deprecated_ratio{account_alias="",account_id="default",engine="custom-oracle-ee",region=""} 1
+# HELP aws_custom_rds_deprecated_total Number of resources whose version is deprecated, per engine, account and region
+# TYPE aws_custom_rds_deprecated_total gauge
+aws_custom_rds_deprecated_total{account_alias="",account_id="default",engine="custom-oracle-ee",region=""} 1
+# HELP aws_custom_rds_discovered_resources Number of clusters and instances discovered, before they are filtered
+# TYPE aws_custom_rds_discovered_resources gauge
+aws_custom_rds_discovered_resources{account_id="default",engine="custom-oracle-ee",region=""} 1
+# HELP aws_custom_rds_major_version_deprecated Resources whose major version has no available version left, which need a major version upgrade
+# TYPE aws_custom_rds_major_version_deprecated gauge
+aws_custom_rds_major_version_deprecated{account_id="default",cluster_identifier="custom-1",engine="custom-oracle-ee",engine_version_major="19",region=""} 1
+# HELP aws_custom_rds_poll_interval_seconds Current interval between two snapshots, backed off after consecutive failed snapshots
+# TYPE aws_custom_rds_poll_interval_seconds gauge
+aws_custom_rds_poll_interval_seconds 0
+# HELP aws_custom_rds_series_overflow Number of series left out of the per-resource metrics by the series cap in the last snapshot
+# TYPE aws_custom_rds_series_overflow gauge
+aws_custom_rds_series_overflow 0
+# HELP aws_custom_rds_snapshot_panics_total Number of panics recovered while taking snapshots
+# TYPE aws_custom_rds_snapshot_panics_total counter
+aws_custom_rds_snapshot_panics_total 0
+# HELP aws_custom_rds_upgrade_targets Number of valid minor or major upgrade targets of the engine versions in use
+# TYPE aws_custom_rds_upgrade_targets gauge
+aws_custom_rds_upgrade_targets{account_id="default",engine="custom-oracle-ee",engine_version="19.my_cev1",region="",upgrade="major"} 0
+aws_custom_rds_upgrade_targets{account_id="default",engine="custom-oracle-ee",engine_version="19.my_cev1",region="",upgrade="minor"} 0
+# HELP aws_custom_rds_version_available Number of instances whose version is available
 # TYPE aws_custom_rds_version_available gauge
-aws_custom_rds_version_available{cluster_identifier="cluster-1",engine="MySQL",engine_version="5.7.34"} 0
-aws_custom_rds_version_available{cluster_identifier="cluster-1",engine="MySQL",engine_version="8.0.25"} 1
-aws_custom_rds_version_available{cluster_identifier="cluster-1",engine="PostgreSQL",engine_version="13.2"} 1
-aws_custom_rds_version_available{cluster_identifier="cluster-1",engine="PostgreSQL",engine_version="9.5.24"} 0
+aws_custom_rds_version_available{account_id="default",babelfish="false",cluster_identifier="custom-1",edition="enterprise",engine="custom-oracle-ee",engine_version="19.my_cev1",engine_version_major="19",engine_version_minor="my_cev1",license_model="bring-your-own-license",maintenance_window="",rds_custom="true",region="",role=""} 0
 # HELP aws_custom_rds_version_deprecated Number of instances whose Version is deprecated
 # TYPE aws_custom_rds_version_deprecated gauge
-aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="MySQL",engine_version="5.7.34"} 1
-aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="MySQL",engine_version="8.0.25"} 0
-aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="PostgreSQL",engine_version="13.2"} 0
-aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="PostgreSQL",engine_version="9.5.24"} 1
+aws_custom_rds_version_deprecated{account_id="default",babelfish="false",cluster_identifier="custom-1",edition="enterprise",engine="custom-oracle-ee",engine_version="19.my_cev1",engine_version_major="19",engine_version_minor="my_cev1",license_model="bring-your-own-license",maintenance_window="",rds_custom="true",region="",role=""} 1
+# HELP aws_custom_rds_version_status_info Raw catalog status of the engine versions in use, and whether it is classified as available, deprecated or unknown
+# TYPE aws_custom_rds_version_status_info gauge
+aws_custom_rds_version_status_info{account_id="default",classification="deprecated",engine="custom-oracle-ee",engine_version="19.my_cev1",region="",status="inactive"} 1
 `,
 			wantErr: nil,
 		},
 		{
-			desc:    "failed snapshot getRDSClusters returns error",
-			config:  &Config{&MockRDSAPI{err: fmt.Errorf("failed to get clusters")}},
-			want:    "",
+			desc:   "failed snapshot getRDSClusters returns error",
+			config: &Config{RDS: &MockRDSAPI{err: fmt.Errorf("failed to get clusters")}},
+			want: `# HELP aws_custom_rds_blackout Whether a blackout window is open, during which no AWS API call is made and the last known data is served
+# TYPE aws_custom_rds_blackout gauge
+aws_custom_rds_blackout 0
+# HELP aws_custom_rds_poll_interval_seconds Current interval between two snapshots, backed off after consecutive failed snapshots
+# TYPE aws_custom_rds_poll_interval_seconds gauge
+aws_custom_rds_poll_interval_seconds 0
+# HELP aws_custom_rds_series_overflow Number of series left out of the per-resource metrics by the series cap in the last snapshot
+# TYPE aws_custom_rds_series_overflow gauge
+aws_custom_rds_series_overflow 0
+# HELP aws_custom_rds_snapshot_panics_total Number of panics recovered while taking snapshots
+# TYPE aws_custom_rds_snapshot_panics_total counter
+aws_custom_rds_snapshot_panics_total 0
+`,
 			wantErr: errors.New("failed to read RDS Cluster infos; failed to describe DB instances; failed to get clusters"),
 		},
 	}
@@ -176,13 +554,17 @@ aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="Postgre
 			t.Logf("testing: %s", tt.desc)
 
 			metrics := NewMetrics()
-			handler := initPromHandler(metrics)
-			server := initHttpServer(handler, getAddr())
+			handler := initPromHandler(metrics, nil, nil)
+			server := initHttpServer(handler, defaultMetricsPath, &readiness{}, getAddr())
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				t.Fatal(err)
+			}
 			go func() {
-				_ = server.ListenAndServe()
+				_ = server.Serve(listener)
 			}()
 
-			err := snapshot(tt.config, metrics, m)
+			err = snapshot(tt.config, metrics, m)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
 			} else {
@@ -197,6 +579,52 @@ aws_custom_rds_version_deprecated{cluster_identifier="cluster-1",engine="Postgre
 	}
 }
 
+func TestGetRDSInstancesStreamsPages(t *testing.T) {
+	config := &Config{RDS: &MockRDSAPI{
+		instancesOutput: []*rds.DescribeDBInstancesOutput{
+			{
+				DBInstances: []*rds.DBInstance{
+					{DBInstanceIdentifier: Ptr("db-1"), Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.25")},
+					{DBInstanceIdentifier: Ptr("db-2"), Engine: Ptr("mysql"), EngineVersion: Ptr("8.0.25")},
+				},
+				Marker: Ptr("dummy marker"),
+			},
+			{
+				DBInstances: []*rds.DBInstance{
+					{DBInstanceIdentifier: Ptr("db-3"), Engine: Ptr("postgres"), EngineVersion: Ptr("13.2")},
+				},
+			},
+		},
+	}}
+
+	var pageSizes []int
+	err := getRDSInstances(config, nil, func(rdsInfos []RDSInfo) error {
+		pageSizes = append(pageSizes, len(rdsInfos))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, pageSizes)
+
+	err = getRDSInstances(config, nil, func(rdsInfos []RDSInfo) error {
+		return errors.New("handler failed")
+	})
+	assert.EqualError(t, err, "handler failed")
+}
+
+// TestNewConfigUserAgent tests that the exporter name, version and User-Agent suffix are added to the User-Agent of AWS
+// API calls.
+func TestNewConfigUserAgent(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	options := defaultOptions()
+	options.UserAgentSuffix = "team/platform"
+	config := NewConfig(options, newSession(options, nil), "", "")
+
+	req, _ := config.RDS.(*rds.RDS).DescribeDBInstancesRequest(&rds.DescribeDBInstancesInput{})
+	assert.NoError(t, req.Build())
+	assert.Contains(t, req.HTTPRequest.Header.Get("User-Agent"),
+		" prometheus-exporter-aws-rds-engine-version/dev team/platform")
+}
+
 func setEnv(t *testing.T, key, value string) {
 	err := os.Setenv(key, value)
 	assert.NoError(t, err)
@@ -0,0 +1,117 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"time"
+)
+
+const week = 7 * 24 * time.Hour
+
+// weekdays maps the day abbreviations used by RDS maintenance windows to their offset from the start of the week.
+var weekdays = map[string]time.Duration{
+	"sun": 0,
+	"mon": 1 * 24 * time.Hour,
+	"tue": 2 * 24 * time.Hour,
+	"wed": 3 * 24 * time.Hour,
+	"thu": 4 * 24 * time.Hour,
+	"fri": 5 * 24 * time.Hour,
+	"sat": 6 * 24 * time.Hour,
+}
+
+// parseWeeklyTime parses a "ddd:hh24:mi" time of the week, e.g. "sun:05:00", and returns its offset from the start of
+// the week (sunday at midnight UTC).
+func parseWeeklyTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.ToLower(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid weekly time %q: expected format ddd:hh24:mi", s)
+	}
+	day, ok := weekdays[parts[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid weekly time %q: unknown day %q", s, parts[0])
+	}
+	clock, err := time.Parse("15:04", parts[1]+":"+parts[2])
+	if err != nil {
+		return 0, fmt.Errorf("invalid weekly time %q: %w", s, err)
+	}
+	return day + time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// untilMaintenanceWindow returns the duration until the next opening of a "ddd:hh24:mi-ddd:hh24:mi" weekly
+// maintenance window, e.g. "sun:05:00-sun:06:00", which is expressed in UTC. A duration of 0 is returned if the window
+// is open at the given time.
+func untilMaintenanceWindow(window string, now time.Time) (time.Duration, error) {
+	startString, endString, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid maintenance window %q: expected format ddd:hh24:mi-ddd:hh24:mi", window)
+	}
+	start, err := parseWeeklyTime(startString)
+	if err != nil {
+		return 0, err
+	}
+	end, err := parseWeeklyTime(endString)
+	if err != nil {
+		return 0, err
+	}
+
+	now = now.UTC()
+	sunday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -int(now.Weekday()))
+	offset := now.Sub(sunday)
+
+	// the window may wrap around the end of the week, e.g. "sat:23:00-sun:01:00"
+	if end < start {
+		end += week
+	}
+	for _, shift := range []time.Duration{-week, 0} {
+		if offset >= start+shift && offset < end+shift {
+			return 0, nil
+		}
+	}
+	until := start - offset
+	if until < 0 {
+		until += week
+	}
+	return until, nil
+}
+
+// exportMaintenanceWindow sets the MaintenanceWindowGauge to the number of seconds until the next opening of the
+// preferred maintenance window of an RDS resource. Resources without a maintenance window are skipped.
+func exportMaintenanceWindow(metrics *Metrics, rdsInfo RDSInfo, now time.Time) error {
+	if len(rdsInfo.MaintenanceWindow) == 0 {
+		return nil
+	}
+	until, err := untilMaintenanceWindow(rdsInfo.MaintenanceWindow, now)
+	if err != nil {
+		return err
+	}
+	metrics.MaintenanceWindowGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"maintenance_window": rdsInfo.MaintenanceWindow,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(until.Seconds())
+	return nil
+}
@@ -0,0 +1,69 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestUntilMaintenanceWindow tests the untilMaintenanceWindow function.
+func TestUntilMaintenanceWindow(t *testing.T) {
+	// 2023-04-12 is a wednesday
+	now := time.Date(2023, 4, 12, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc    string
+		window  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{desc: "later this week", window: "fri:05:00-fri:05:30", want: 2*24*time.Hour - 5*time.Hour - 30*time.Minute},
+		{desc: "later today", window: "wed:12:00-wed:12:30", want: time.Hour + 30*time.Minute},
+		{desc: "next week", window: "mon:10:00-mon:10:30", want: 5*24*time.Hour - 30*time.Minute},
+		{desc: "open", window: "wed:10:00-wed:11:00", want: 0},
+		{desc: "upper case", window: "WED:10:00-WED:11:00", want: 0},
+		{desc: "wrapping around the week", window: "sat:23:00-sun:01:00", want: 3*24*time.Hour + 12*time.Hour + 30*time.Minute},
+		{desc: "invalid day", window: "xyz:10:00-wed:11:00", wantErr: true},
+		{desc: "invalid format", window: "wed:10:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := untilMaintenanceWindow(tt.window, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// a window wrapping around the week is open on both sides of midnight
+	sunday := time.Date(2023, 4, 16, 0, 30, 0, 0, time.UTC)
+	got, err := untilMaintenanceWindow("sat:23:00-sun:01:00", sunday)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), got)
+}
@@ -0,0 +1,59 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// majorDeprecated returns true if no version of the catalog of an engine in the given major version is available
+// anymore, i.e. if every version of the major version is deprecated. The resources running it need a major version
+// upgrade, rather than a minor one.
+func majorDeprecated(engine, major string, catalog versionCatalog) bool {
+	for version, info := range catalog {
+		versionMajor, _ := splitEngineVersion(engine, version)
+		if versionMajor == major && !deprecatedStatuses[info.Status] {
+			return false
+		}
+	}
+	return true
+}
+
+// exportMajorDeprecated sets the MajorDeprecatedGauge of an RDS resource to 1 if every version of its major version
+// is deprecated, and to 0 otherwise. Nothing is exported for engines missing from the catalogs.
+func exportMajorDeprecated(metrics *Metrics, rdsInfo RDSInfo, m engineVersions) {
+	catalog, ok := m[rdsInfo.Engine]
+	if !ok {
+		return
+	}
+	major, _ := splitEngineVersion(rdsInfo.Engine, rdsInfo.EngineVersion)
+	deprecated := 0.0
+	if majorDeprecated(rdsInfo.Engine, major, catalog) {
+		deprecated = 1
+	}
+	metrics.MajorDeprecatedGauge.With(prometheus.Labels{
+		"cluster_identifier":   rdsInfo.ClusterIdentifier,
+		"engine":               rdsInfo.Engine,
+		"engine_version_major": major,
+		"account_id":           rdsInfo.Account,
+		"region":               rdsInfo.Region,
+	}).Set(deprecated)
+}
@@ -0,0 +1,71 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportMajorDeprecated tests that the resources are flagged when every version of their major version is
+// deprecated, and only then.
+func TestExportMajorDeprecated(t *testing.T) {
+	m := engineVersions{"postgres": {
+		"11.18": {Status: "deprecated"},
+		"11.19": {Status: "deprecated"},
+		"13.7":  {Status: "deprecated"},
+		"13.10": {Status: "available"},
+	}}
+	tests := []struct {
+		name    string
+		rdsInfo RDSInfo
+		want    float64
+	}{
+		{
+			name:    "entirely deprecated major version",
+			rdsInfo: RDSInfo{ClusterIdentifier: "db-1", Engine: "postgres", EngineVersion: "11.19", Region: "eu-west-1"},
+			want:    1,
+		},
+		{
+			name:    "deprecated minor version of a supported major version",
+			rdsInfo: RDSInfo{ClusterIdentifier: "db-2", Engine: "postgres", EngineVersion: "13.7", Region: "eu-west-1"},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := NewMetrics()
+			exportMajorDeprecated(metrics, tt.rdsInfo, m)
+			major, _ := splitEngineVersion(tt.rdsInfo.Engine, tt.rdsInfo.EngineVersion)
+			assert.Equal(t, tt.want, testutil.ToFloat64(metrics.MajorDeprecatedGauge.WithLabelValues(
+				tt.rdsInfo.ClusterIdentifier, tt.rdsInfo.Engine, major, tt.rdsInfo.Account, tt.rdsInfo.Region)))
+		})
+	}
+
+	// nothing is exported for engines missing from the catalogs
+	metrics := NewMetrics()
+	exportMajorDeprecated(metrics, RDSInfo{ClusterIdentifier: "db-3", Engine: "mysql", EngineVersion: "8.0.32"}, m)
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.MajorDeprecatedGauge))
+}
@@ -0,0 +1,444 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultNATSSubject is the default template of the subjects of the status changes published on NATS, so that the
+// subscribers can select them by account, region, engine or status, e.g. "rds.engine_version.*.*.*.deprecated".
+const defaultNATSSubject = "rds.engine_version.{{.Account}}.{{.Region}}.{{.Engine}}.{{.Status}}"
+
+// natsSubjectData is the data of the template of the subjects of the status changes. Its fields are tokens of the
+// subject, whose dots, spaces and wildcards are replaced with underscores.
+type natsSubjectData struct {
+	// Account, Region and Engine are the ones of the resource, e.g. "111111111111", "eu-west-1" and "postgres".
+	Account string
+	Region  string
+	Engine  string
+
+	// Status is the status of the engine version of the resource, or "gone" if it disappeared.
+	Status string
+}
+
+// natsTokenEscaper replaces the characters that the tokens of the NATS subjects cannot hold with underscores.
+var natsTokenEscaper = strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_", "\t", "_", "\r", "_", "\n", "_")
+
+// parseNATSSubject parses the template of the subjects of the status changes, and checks that it can be executed.
+func parseNATSSubject(s string) (*template.Template, error) {
+	subject, err := template.New("nats_subject").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS subject template; %w", err)
+	}
+	if err := subject.Execute(&bytes.Buffer{}, natsSubjectData{}); err != nil {
+		return nil, fmt.Errorf("invalid NATS subject template; %w", err)
+	}
+	return subject, nil
+}
+
+// natsCredentials are the user JWT and the nkey seed of a NATS credentials file, with which the exporter
+// authenticates to the NATS servers of a decentralized authentication.
+type natsCredentials struct {
+	jwt string
+	key ed25519.PrivateKey
+}
+
+// loadNATSCredentials reads a NATS credentials file, e.g. generated by nsc, holding a user JWT and its nkey seed.
+func loadNATSCredentials(path string) (*natsCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NATS credentials; %w", err)
+	}
+	var jwt, seed string
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		switch {
+		case strings.Contains(lines[i], "BEGIN NATS USER JWT"):
+			jwt = strings.TrimSpace(lines[i+1])
+		case strings.Contains(lines[i], "BEGIN USER NKEY SEED"):
+			seed = strings.TrimSpace(lines[i+1])
+		}
+	}
+	if jwt == "" || seed == "" {
+		return nil, fmt.Errorf("invalid NATS credentials %s: a user JWT and an nkey seed should be set", path)
+	}
+	key, err := decodeNKeySeed(seed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS credentials %s; %w", path, err)
+	}
+	return &natsCredentials{jwt: jwt, key: key}, nil
+}
+
+// decodeNKeySeed decodes the private key of an nkey seed: the base32 encoding of a prefix of 2 bytes, telling it is a
+// seed and the type of its key, of the 32 bytes of the ed25519 seed, and of their CRC-16 on 2 bytes, little endian.
+func decodeNKeySeed(seed string) (ed25519.PrivateKey, error) {
+	raw, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(seed)
+	if err != nil || len(raw) != 2+ed25519.SeedSize+2 {
+		return nil, errors.New("invalid nkey seed")
+	}
+	payload, sum := raw[:len(raw)-2], uint16(raw[len(raw)-2])|uint16(raw[len(raw)-1])<<8
+	// the seeds start with an "S", i.e. 18 << 3 in the 5 higher bits of the first byte
+	if raw[0]&0xf8 != 18<<3 || crc16(payload) != sum {
+		return nil, errors.New("invalid nkey seed")
+	}
+	return ed25519.NewKeyFromSeed(payload[2:]), nil
+}
+
+// crc16 returns the CRC-16/XMODEM checksum of data, which the nkeys are checked with.
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// natsPublisher publishes a message on NATS for each transition between successive snapshots, so that lightweight
+// internal subscribers can trigger workflows, with the plain client protocol over TCP. The messages are acknowledged
+// by JetStream if the subjects are captured by a stream and jetStream is set.
+type natsPublisher struct {
+	url         *url.URL
+	subject     *template.Template
+	credentials *natsCredentials
+	jetStream   bool
+	timeout     time.Duration
+	tracker     transitionTracker
+}
+
+// newNATSPublisher returns the natsPublisher configured by the options, or nil if no NATS URL is configured.
+func newNATSPublisher(options *Options) *natsPublisher {
+	if options.NATSURL == "" {
+		return nil
+	}
+	u, _ := url.Parse(options.NATSURL)
+	return &natsPublisher{
+		url:         u,
+		subject:     options.natsSubject,
+		credentials: options.natsCredentials,
+		jetStream:   options.NATSJetStream,
+		timeout:     options.AwsApiTimeout,
+	}
+}
+
+// validateNATS checks that the NATS URL is a nats:// or tls:// URL with a port, and parses the subject template and
+// the credentials file.
+func (o *Options) validateNATS() []string {
+	if o.NATSURL == "" {
+		return nil
+	}
+	var problems []string
+	u, err := url.Parse(o.NATSURL)
+	if !isSecretReference(o.NATSURL) && (err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Port() == "") {
+		problems = append(problems, fmt.Sprintf("NATS URL should be nats://host:port or tls://host:port, got %q",
+			describePolicyURL(o.NATSURL)))
+	}
+	subject, err := parseNATSSubject(o.NATSSubject)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.natsSubject = subject
+	o.natsCredentials = nil
+	if o.NATSCredsFile != "" {
+		credentials, err := loadNATSCredentials(o.NATSCredsFile)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.natsCredentials = credentials
+	}
+	return problems
+}
+
+// publish publishes a message for each transition between the inventory of the previous call and the given one, over
+// a connection of its own. The first call publishes nothing. The transitions whose messages were not acknowledged, by
+// JetStream or else by the PONG of the server, are published again by the next call, unless the resources changed
+// since. Nothing is done if p is nil.
+func (p *natsPublisher) publish(inv *inventory, now time.Time) error {
+	if p == nil {
+		return nil
+	}
+	transitions := p.tracker.update(inv.list())
+	if len(transitions) == 0 {
+		return nil
+	}
+	acknowledged, err := p.send(transitions, now)
+	if err != nil {
+		p.tracker.revert(transitions[acknowledged:])
+	}
+	return err
+}
+
+// send publishes a message for each transition, and returns the number of the first transitions whose messages were
+// acknowledged.
+func (p *natsPublisher) send(transitions []transition, now time.Time) (int, error) {
+	conn, err := p.connect()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.close()
+	if err := conn.setDeadline(time.Now().Add(p.timeout)); err != nil {
+		return 0, err
+	}
+	inbox := "_INBOX." + strings.ReplaceAll(exporterName, "-", "_") + "." + fmt.Sprint(now.UnixNano())
+	if p.jetStream {
+		if err := conn.write("SUB %s 1\r\n", inbox); err != nil {
+			return 0, err
+		}
+	}
+	acknowledged := 0
+	for _, t := range transitions {
+		event := newStatusChangeEvent(t)
+		status := event.Status
+		if t.Current == nil {
+			status = "gone"
+		}
+		var subject bytes.Buffer
+		if err := p.subject.Execute(&subject, natsSubjectData{
+			Account: natsTokenEscaper.Replace(event.Account),
+			Region:  natsTokenEscaper.Replace(event.Region),
+			Engine:  natsTokenEscaper.Replace(event.Engine),
+			Status:  natsTokenEscaper.Replace(status),
+		}); err != nil {
+			return acknowledged, fmt.Errorf("failed to execute NATS subject template; %w", err)
+		}
+		payload, err := json.Marshal(timedStatusChange{Time: now.UTC().Format(time.RFC3339), statusChangeEvent: event})
+		if err != nil {
+			return acknowledged, fmt.Errorf("failed to marshal NATS message; %w", err)
+		}
+		if !p.jetStream {
+			if err := conn.write("PUB %s %d\r\n%s\r\n", subject.String(), len(payload), payload); err != nil {
+				return acknowledged, err
+			}
+			continue
+		}
+		if err := conn.write("PUB %s %s %d\r\n%s\r\n", subject.String(), inbox, len(payload), payload); err != nil {
+			return acknowledged, err
+		}
+		if err := conn.waitAck(); err != nil {
+			return acknowledged, fmt.Errorf("failed to publish on NATS subject %s; %w", subject.String(), err)
+		}
+		acknowledged++
+	}
+	// the server answers the PING once it processed the messages, or reports an error before, e.g. a permission denied
+	if err := conn.write("PING\r\n"); err != nil {
+		return acknowledged, err
+	}
+	if err := conn.waitPong(); err != nil {
+		return acknowledged, err
+	}
+	return len(transitions), nil
+}
+
+// natsConn is a connection to a NATS server.
+type natsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// natsInfo is the part of the INFO message of the server that the exporter needs.
+type natsInfo struct {
+	Nonce       string `json:"nonce"`
+	TLSRequired bool   `json:"tls_required"`
+}
+
+// connect opens a connection to the NATS server, upgraded to TLS if the URL or the server requires it, and
+// authenticates with the user info of the URL, either a user and a password or a token, or with the credentials.
+func (p *natsPublisher) connect() (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", p.url.Host, p.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS; %w", err)
+	}
+	c := &natsConn{conn: conn, reader: bufio.NewReader(conn)}
+	if err := c.setDeadline(time.Now().Add(p.timeout)); err != nil {
+		c.close()
+		return nil, err
+	}
+	if err := c.handshake(p); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake reads the INFO message of the server, and sends the CONNECT message of the exporter.
+func (c *natsConn) handshake(p *natsPublisher) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("failed to connect to NATS; unexpected message %q", line)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fmt.Errorf("failed to connect to NATS; invalid INFO message; %w", err)
+	}
+	secure := p.url.Scheme == "tls" || info.TLSRequired
+	if secure {
+		tlsConn := tls.Client(c.conn, &tls.Config{ServerName: p.url.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.Handshake(); err != nil {
+			return fmt.Errorf("failed to connect to NATS; %w", err)
+		}
+		c.conn, c.reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+	connect := map[string]interface{}{
+		"verbose": false, "pedantic": false, "echo": false, "tls_required": secure,
+		"lang": "go", "version": version, "name": exporterName, "protocol": 1,
+	}
+	if user := p.url.User; user != nil {
+		if password, ok := user.Password(); ok {
+			connect["user"], connect["pass"] = user.Username(), password
+		} else {
+			connect["auth_token"] = user.Username()
+		}
+	}
+	if p.credentials != nil {
+		connect["jwt"] = p.credentials.jwt
+		connect["sig"] = base64.RawURLEncoding.EncodeToString(ed25519.Sign(p.credentials.key, []byte(info.Nonce)))
+	}
+	data, err := json.Marshal(connect)
+	if err != nil {
+		return fmt.Errorf("failed to marshal NATS CONNECT message; %w", err)
+	}
+	// the server answers the PING once authenticated, or reports the authentication error
+	if err := c.write("CONNECT %s\r\nPING\r\n", data); err != nil {
+		return err
+	}
+	return c.waitPong()
+}
+
+// write sends a message to the server.
+func (c *natsConn) write(format string, args ...interface{}) error {
+	if _, err := fmt.Fprintf(c.conn, format, args...); err != nil {
+		return fmt.Errorf("failed to send to NATS; %w", err)
+	}
+	return nil
+}
+
+// readLine reads a message of the server, answering its PINGs, and returns the first one that is neither a PING nor
+// an +OK. The -ERR messages are returned as errors.
+func (c *natsConn) readLine() (string, error) {
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("failed to read from NATS; %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return "", err
+			}
+		case line == "+OK":
+		case strings.HasPrefix(line, "-ERR"):
+			return "", fmt.Errorf("NATS error %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		default:
+			return line, nil
+		}
+	}
+}
+
+// waitPong waits for the PONG of the server.
+func (c *natsConn) waitPong() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "PONG" {
+		return fmt.Errorf("failed to read from NATS; unexpected message %q", line)
+	}
+	return nil
+}
+
+// waitAck waits for the acknowledgement of a message by JetStream, i.e. the reply to the inbox of the exporter,
+// holding either the stream and the sequence of the message, or an error.
+func (c *natsConn) waitAck() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	// MSG <subject> <sid> <size>
+	fields := strings.Fields(line)
+	var size int
+	if len(fields) != 4 || fields[0] != "MSG" {
+		return fmt.Errorf("unexpected message %q", line)
+	}
+	if _, err := fmt.Sscan(fields[3], &size); err != nil {
+		return fmt.Errorf("unexpected message %q", line)
+	}
+	payload := make([]byte, size+2)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return fmt.Errorf("failed to read from NATS; %w", err)
+	}
+	var ack struct {
+		Stream string `json:"stream"`
+		Error  *struct {
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(payload[:size], &ack); err != nil {
+		return fmt.Errorf("invalid JetStream acknowledgement; %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("JetStream error %s", ack.Error.Description)
+	}
+	if ack.Stream == "" {
+		return errors.New("no JetStream stream captured the message")
+	}
+	return nil
+}
+
+// setDeadline sets the deadline of the reads and the writes of the connection.
+func (c *natsConn) setDeadline(t time.Time) error {
+	if err := c.conn.SetDeadline(t); err != nil {
+		return fmt.Errorf("failed to set NATS deadline; %w", err)
+	}
+	return nil
+}
+
+// close closes the connection.
+func (c *natsConn) close() {
+	_ = c.conn.Close()
+}
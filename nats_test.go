@@ -0,0 +1,266 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeNKeySeed encodes the seed of a user nkey, like nsc does.
+func encodeNKeySeed(seed []byte) string {
+	// the prefix of the seeds, "S", followed by the one of the users, "U", i.e. 20 << 3
+	payload := append([]byte{18<<3 | 20>>5, 20 & 31 << 3}, seed...)
+	sum := crc16(payload)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(append(payload, byte(sum), byte(sum>>8)))
+}
+
+// fakeNATSServer is a NATS server accepting a connection at a time, recording the CONNECT messages and the messages
+// published, and acknowledging them with ack if they have a reply subject. If fail is set, the next message is rejected
+// with it as an -ERR message instead.
+type fakeNATSServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	ack      string
+	fail     string
+	connects []map[string]interface{}
+	messages []string
+}
+
+func newFakeNATSServer(t *testing.T, ack string) *fakeNATSServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &fakeNATSServer{listener: listener, ack: ack}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATSServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	_, _ = fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"nonce\":\"nonce\"}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			var connect map[string]interface{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "CONNECT ")), &connect)
+			s.mu.Lock()
+			s.connects = append(s.connects, connect)
+			s.mu.Unlock()
+		case line == "PING\r\n":
+			_, _ = fmt.Fprint(conn, "PONG\r\n")
+		case fields[0] == "PUB":
+			var size int
+			_, _ = fmt.Sscan(fields[len(fields)-1], &size)
+			payload := make([]byte, size+2)
+			_, _ = io.ReadFull(reader, payload)
+			s.mu.Lock()
+			ack, fail := s.ack, s.fail
+			s.fail = ""
+			if fail == "" {
+				s.messages = append(s.messages, fields[1]+" "+string(payload[:size]))
+			}
+			s.mu.Unlock()
+			if fail != "" {
+				_, _ = fmt.Fprintf(conn, "-ERR '%s'\r\n", fail)
+				continue
+			}
+			if len(fields) == 4 {
+				_, _ = fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", fields[2], len(ack), ack)
+			}
+		}
+	}
+}
+
+// TestNATSPublisherPublish tests the subjects and the payloads of the messages, the authentication, and the JetStream
+// acknowledgements, and that the messages that were not acknowledged are published again by the next call.
+func TestNATSPublisherPublish(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 42
+	credentials := &natsCredentials{jwt: "eyJ0eXAiOiJKV1QifQ", key: ed25519.NewKeyFromSeed(seed)}
+	item := inventoryItem{
+		RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+			Region: "eu-west-1", Account: "111111111111"},
+		Status: "available",
+	}
+	subject, err := parseNATSSubject(defaultNATSSubject)
+	assert.NoError(t, err)
+	tests := []struct {
+		name        string
+		userInfo    string
+		credentials *natsCredentials
+		jetStream   bool
+		ack         string
+		fail        string
+		wantConnect map[string]interface{}
+		wantErr     string
+	}{
+		{
+			name:        "user and password",
+			userInfo:    "exporter:secret@",
+			wantConnect: map[string]interface{}{"user": "exporter", "pass": "secret"},
+		},
+		{
+			name:        "token",
+			userInfo:    "s3cr3t@",
+			wantConnect: map[string]interface{}{"auth_token": "s3cr3t"},
+		},
+		{
+			name:        "JetStream with credentials",
+			credentials: credentials,
+			jetStream:   true,
+			ack:         `{"stream":"RDS","seq":1}`,
+			wantConnect: map[string]interface{}{"jwt": credentials.jwt,
+				"sig": base64.RawURLEncoding.EncodeToString(ed25519.Sign(credentials.key, []byte("nonce")))},
+		},
+		{
+			name:      "JetStream error",
+			jetStream: true,
+			ack:       `{"error":{"code":503,"description":"stream is offline"}}`,
+			wantErr: "failed to publish on NATS subject rds.engine_version.111111111111.eu-west-1.postgres.deprecated; " +
+				"JetStream error stream is offline",
+		},
+		{
+			name: "permissions violation",
+			fail: "Permissions Violation for Publish to rds.engine_version.111111111111.eu-west-1.postgres.deprecated",
+			wantErr: "NATS error 'Permissions Violation for Publish to " +
+				"rds.engine_version.111111111111.eu-west-1.postgres.deprecated'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeNATSServer(t, tt.ack)
+			defer server.listener.Close()
+			server.fail = tt.fail
+			u, err := url.Parse("nats://" + tt.userInfo + server.listener.Addr().String())
+			assert.NoError(t, err)
+			p := &natsPublisher{url: u, subject: subject, credentials: tt.credentials, jetStream: tt.jetStream,
+				timeout: time.Second}
+
+			inv := &inventory{}
+			inv.add(item)
+			assert.NoError(t, p.publish(inv, now))
+			assert.Empty(t, server.connects, "first snapshot")
+
+			deprecated := item
+			deprecated.Status, deprecated.Deprecated = "deprecated", true
+			inv.reset()
+			inv.add(deprecated)
+			err = p.publish(inv, now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				server.mu.Lock()
+				server.ack, server.connects, server.messages = `{"stream":"RDS","seq":1}`, nil, nil
+				server.mu.Unlock()
+				err = p.publish(inv, now)
+			}
+			assert.NoError(t, err)
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if assert.Len(t, server.connects, 1) {
+				for key, value := range tt.wantConnect {
+					assert.Equal(t, value, server.connects[0][key], key)
+				}
+			}
+			if assert.Len(t, server.messages, 1) {
+				subject, payload, _ := strings.Cut(server.messages[0], " ")
+				assert.Equal(t, "rds.engine_version.111111111111.eu-west-1.postgres.deprecated", subject)
+				assert.JSONEq(t, `{"time": "2023-05-10T14:37:00Z", "account": "111111111111", "region": "eu-west-1",
+					"identifier": "orders", "engine": "postgres", "previous_engine_version": "11.4",
+					"engine_version": "11.4", "previous_status": "available", "status": "deprecated",
+					"deprecated": true, "upgrade_targets": []}`, payload)
+			}
+		})
+	}
+}
+
+// TestLoadNATSCredentials tests that the user JWT and the nkey seed are read from a credentials file, and that the
+// invalid seeds are rejected.
+func TestLoadNATSCredentials(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 42
+	encoded := encodeNKeySeed(seed)
+	corrupted := encoded[:10] + "A" + encoded[11:]
+	if corrupted == encoded {
+		corrupted = encoded[:10] + "B" + encoded[11:]
+	}
+	tests := []struct {
+		name    string
+		seed    string
+		wantErr string
+	}{
+		{name: "valid", seed: encoded},
+		{name: "corrupted seed", seed: corrupted, wantErr: "invalid nkey seed"},
+		{name: "missing seed", wantErr: "a user JWT and an nkey seed should be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exporter.creds")
+			content := "-----BEGIN NATS USER JWT-----\neyJ0eXAiOiJKV1QifQ\n------END NATS USER JWT------\n\n"
+			if tt.seed != "" {
+				content += "-----BEGIN USER NKEY SEED-----\n" + tt.seed + "\n------END USER NKEY SEED------\n"
+			}
+			assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			credentials, err := loadNATSCredentials(path)
+			if tt.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "eyJ0eXAiOiJKV1QifQ", credentials.jwt)
+			assert.Equal(t, ed25519.NewKeyFromSeed(seed), credentials.key)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"sync/atomic"
+	"time"
+)
+
+// snapshotClock records the start time of the last successful snapshot, partial ones included. A nil snapshotClock
+// records nothing.
+type snapshotClock struct {
+	unixMilli atomic.Int64
+}
+
+// set records t as the start time of the last successful snapshot.
+func (c *snapshotClock) set(t time.Time) {
+	if c != nil {
+		c.unixMilli.Store(t.UnixMilli())
+	}
+}
+
+// timestampGatherer gathers the metrics of its Gatherer, and stamps the samples of the gauges, which all come from
+// the snapshots, with the start time of the last successful snapshot. The snapshot_panics_total counter is left
+// untouched, and so is the data age, computed at scrape time, and the samples already stamped with the time of the last
+// successful snapshot of their scope. Nothing is stamped until the first snapshot succeeded.
+type timestampGatherer struct {
+	prometheus.Gatherer
+	clock *snapshotClock
+}
+
+// Gather implements prometheus.Gatherer.
+func (g timestampGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	timestamp := g.clock.unixMilli.Load()
+	if timestamp == 0 {
+		return families, err
+	}
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_GAUGE || family.GetName() == dataAgeName {
+			continue
+		}
+		for _, metric := range family.Metric {
+			if metric.TimestampMs == nil {
+				metric.TimestampMs = &timestamp
+			}
+		}
+	}
+	return families, err
+}
@@ -0,0 +1,65 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestInitPromHandlerTimestamps tests that the OpenMetrics format is negotiated, and that the gauge samples are
+// stamped with the start time of the last successful snapshot.
+func TestInitPromHandlerTimestamps(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.MaintenanceWindowGauge.WithLabelValues("cluster-1", "sun:05:00-sun:06:00", "111111111111", "eu-west-1").
+		Set(60)
+	clock := &snapshotClock{}
+	handler := initPromHandler(metrics, clock, nil)
+
+	scrape := func(accept string) string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept", accept)
+		handler.ServeHTTP(rec, req)
+		b, err := io.ReadAll(rec.Body)
+		assert.NoError(t, err)
+		return string(b)
+	}
+	gauge := `aws_custom_rds_maintenance_window_seconds_until{account_id="111111111111",cluster_identifier="cluster-1",maintenance_window="sun:05:00-sun:06:00",region="eu-west-1"}`
+
+	// nothing is stamped before the first successful snapshot
+	assert.Contains(t, scrape("text/plain"), gauge+" 60\n")
+
+	clock.set(time.UnixMilli(1700000000123))
+	text := scrape("text/plain")
+	assert.Contains(t, text, gauge+" 60 1700000000123\n")
+	assert.Contains(t, text, "aws_custom_rds_snapshot_panics_total 0\n")
+
+	openMetrics := scrape("application/openmetrics-text; version=0.0.1")
+	assert.Contains(t, openMetrics, gauge+" 60.0 1.700000000123e+09\n")
+	assert.Contains(t, openMetrics, "# EOF\n")
+}
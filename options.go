@@ -0,0 +1,1074 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	minServerPort        = 1
+	maxServerPort        = 65535
+	minPollInterval      = 10 * time.Second
+	maxPollInterval      = 24 * time.Hour
+	minCatalogRefresh    = time.Minute
+	maxCatalogRefresh    = 7 * 24 * time.Hour
+	minAwsApiTimeout     = time.Second
+	maxAwsApiTimeout     = 5 * time.Minute
+	maxAwsApiConcurrency = 64
+	maxAwsApiRateLimit   = int(time.Second)
+	maxAwsMaxAttempts    = 20
+	minAwsMaxBackoff     = time.Second
+	maxAwsMaxBackoff     = 5 * time.Minute
+)
+
+// redacted replaces the value of secret options when the effective configuration is printed.
+const redacted = "<redacted>"
+
+// Options holds the effective configuration of the exporter. It is loaded by loadOptions, which merges, by increasing
+// precedence, the defaults, the configuration file, the environment variables and the command-line flags.
+type Options struct {
+	ServerPort             int             `yaml:"server_port"`
+	ListenAddress          string          `yaml:"listen_address"`
+	MetricsPath            string          `yaml:"metrics_path"`
+	AdminAddress           string          `yaml:"admin_address"`
+	PollInterval           time.Duration   `yaml:"poll_interval"`
+	PollBackoffMax         time.Duration   `yaml:"poll_backoff_max"`
+	PollSchedule           string          `yaml:"poll_schedule"`
+	BlackoutWindows        string          `yaml:"blackout_windows"`
+	CatalogRefreshInterval time.Duration   `yaml:"catalog_refresh_interval"`
+	CollectorIntervals     string          `yaml:"collector_intervals"`
+	AwsApiTimeout          time.Duration   `yaml:"aws_api_timeout"`
+	AwsApiConcurrency      int             `yaml:"aws_api_concurrency"`
+	AwsApiRateLimit        int             `yaml:"aws_api_rate_limit"`
+	AwsRetryMode           string          `yaml:"aws_retry_mode"`
+	AwsMaxAttempts         int             `yaml:"aws_max_attempts"`
+	AwsMaxBackoff          time.Duration   `yaml:"aws_max_backoff"`
+	Regions                string          `yaml:"regions"`
+	ExcludeRegions         string          `yaml:"exclude_regions"`
+	AssumeRoles            string          `yaml:"assume_roles"`
+	MaxAccountsInFlight    int             `yaml:"max_accounts_in_flight"`
+	MaxRegionsPerAccount   int             `yaml:"max_regions_per_account"`
+	DiscoveryBackend       string          `yaml:"discovery_backend"`
+	TagFilters             string          `yaml:"tag_filters"`
+	GlobalClusters         bool            `yaml:"global_clusters"`
+	InstanceClasses        bool            `yaml:"instance_classes"`
+	Babelfish              bool            `yaml:"babelfish"`
+	CatalogInfo            bool            `yaml:"catalog_info"`
+	DBSnapshots            bool            `yaml:"db_snapshots"`
+	ForcedUpgradeDeadlines bool            `yaml:"forced_upgrade_deadlines"`
+	ReservedInstances      bool            `yaml:"reserved_instances"`
+	HealthEvents           bool            `yaml:"health_events"`
+	TrustedAdvisor         bool            `yaml:"trusted_advisor"`
+	SupportCalendar        bool            `yaml:"support_calendar"`
+	SupportCalendarFile    string          `yaml:"support_calendar_file"`
+	PolicyURL              string          `yaml:"policy_url"`
+	PolicyRefreshInterval  time.Duration   `yaml:"policy_refresh_interval"`
+	ExtendedSupportCost    bool            `yaml:"extended_support_cost"`
+	UpgradeRecommendations bool            `yaml:"upgrade_recommendations"`
+	UpgradePreference      string          `yaml:"upgrade_preference"`
+	PreferDefaultVersion   bool            `yaml:"prefer_default_version"`
+	PlanFormat             string          `yaml:"plan_format"`
+	AutoMinorRemediation   bool            `yaml:"auto_minor_remediation"`
+	RemediationApply       bool            `yaml:"remediation_apply"`
+	RemediationTagFilters  string          `yaml:"remediation_tag_filters"`
+	OwnersFile             string          `yaml:"owners_file"`
+	ExcludeStopped         bool            `yaml:"exclude_stopped"`
+	KubernetesConfig       string          `yaml:"kubernetes_config"`
+	ConfigReload           bool            `yaml:"config_reload"`
+	ReadinessGating        bool            `yaml:"readiness_gating"`
+	SentryDSN              string          `yaml:"sentry_dsn"`
+	OtelEndpoint           string          `yaml:"otel_endpoint"`
+	OtelHeaders            string          `yaml:"otel_headers"`
+	Sink                   string          `yaml:"sink"`
+	SinkURL                string          `yaml:"sink_url"`
+	SinkToken              string          `yaml:"sink_token"`
+	CloudWatchNamespace    string          `yaml:"cloudwatch_namespace"`
+	GraphitePrefix         string          `yaml:"graphite_prefix"`
+	GraphiteTags           bool            `yaml:"graphite_tags"`
+	DatadogTagMap          string          `yaml:"datadog_tag_map"`
+	DryRun                 bool            `yaml:"-"`
+	DumpDir                string          `yaml:"-"`
+	LogLevel               string          `yaml:"log_level"`
+	SignalDumpFile         string          `yaml:"signal_dump_file"`
+	AwsConfigEvent         string          `yaml:"-"`
+	Demo                   bool            `yaml:"demo"`
+	RecordDir              string          `yaml:"record_dir"`
+	ReplayDir              string          `yaml:"replay_dir"`
+	UserAgentSuffix        string          `yaml:"user_agent_suffix"`
+	AwsCABundle            string          `yaml:"aws_ca_bundle"`
+	AwsMinTLSVersion       string          `yaml:"aws_min_tls_version"`
+	SampleTimestamps       bool            `yaml:"sample_timestamps"`
+	DropLabels             string          `yaml:"drop_labels"`
+	HashLabels             string          `yaml:"hash_labels"`
+	MaxSeries              int             `yaml:"max_series"`
+	DeprecationGraceDays   int             `yaml:"deprecation_grace_days"`
+	RelabelConfigs         []relabelConfig `yaml:"relabel_configs"`
+	Sinks                  []sinkConfig    `yaml:"sinks"`
+	Tenants                []tenantConfig  `yaml:"tenants"`
+	APIFilters             apiFilters      `yaml:"api_filters"`
+	DigestSchedule         string          `yaml:"digest_schedule"`
+	DigestTransport        string          `yaml:"digest_transport"`
+	DigestFrom             string          `yaml:"digest_from"`
+	DigestTo               string          `yaml:"digest_to"`
+	DigestGroupByTag       string          `yaml:"digest_group_by_tag"`
+	DigestSMTPAddress      string          `yaml:"digest_smtp_address"`
+	DigestSMTPUsername     string          `yaml:"digest_smtp_username"`
+	DigestSMTPPassword     string          `yaml:"digest_smtp_password"`
+	DigestSESRegion        string          `yaml:"digest_ses_region"`
+	DigestSupportEndDays   int             `yaml:"digest_support_end_days"`
+	EventBridgeBus         string          `yaml:"eventbridge_bus"`
+	RDSEventsQueueURL      string          `yaml:"rds_events_queue_url"`
+	KafkaRESTURL           string          `yaml:"kafka_rest_url"`
+	KafkaInventoryTopic    string          `yaml:"kafka_inventory_topic"`
+	KafkaTransitionsTopic  string          `yaml:"kafka_transitions_topic"`
+	KafkaFormat            string          `yaml:"kafka_format"`
+	NATSURL                string          `yaml:"nats_url"`
+	NATSSubject            string          `yaml:"nats_subject"`
+	NATSCredsFile          string          `yaml:"nats_creds_file"`
+	NATSJetStream          bool            `yaml:"nats_jetstream"`
+	ReportFormats          string          `yaml:"report_formats"`
+	ReportSchedule         string          `yaml:"report_schedule"`
+	ReportS3Bucket         string          `yaml:"report_s3_bucket"`
+	ReportS3Key            string          `yaml:"report_s3_key"`
+	ReportS3Region         string          `yaml:"report_s3_region"`
+	ReportS3SSE            string          `yaml:"report_s3_sse"`
+	ReportS3KMSKeyID       string          `yaml:"report_s3_kms_key_id"`
+
+	// Acknowledgements mute known deprecated resources. Like RelabelConfigs, Sinks, Tenants and APIFilters, they can
+	// only be set in the configuration file.
+	Acknowledgements []acknowledgement `yaml:"acknowledgements"`
+
+	// regions, excludeRegions, roleARNs, tagFilters, remediationTags, sentryDSN, otelEndpoint, otelHeaders, awsRootCAs,
+	// awsMinTLSVersion, dropLabels, hashLabels, digestSchedule, digestTo, reportFormats, reportSchedule, reportS3Key,
+	// supportCalendar, owners, collectorPeriods, pollSchedule, blackouts, natsSubject, natsCredentials and datadogTagMap
+	// are the parsed Regions, ExcludeRegions, AssumeRoles, TagFilters, RemediationTagFilters, SentryDSN, OtelEndpoint,
+	// OtelHeaders, AwsCABundle, AwsMinTLSVersion, DropLabels, HashLabels, DigestSchedule, DigestTo, ReportFormats,
+	// ReportSchedule, ReportS3Key, SupportCalendarFile, OwnersFile, CollectorIntervals, PollSchedule, BlackoutWindows,
+	// NATSSubject, NATSCredsFile and DatadogTagMap, set by validate.
+	// allRegions is true if Regions is "all", in which case regions is empty.
+	allRegions       bool
+	regions          []string
+	excludeRegions   []string
+	roleARNs         []string
+	tagFilters       []tagFilter
+	remediationTags  []tagFilter
+	sentryDSN        *sentryDSN
+	otelEndpoint     string
+	otelHeaders      map[string]string
+	awsRootCAs       *x509.CertPool
+	awsMinTLSVersion uint16
+	dropLabels       []string
+	hashLabels       []string
+	digestSchedule   *cronSchedule
+	digestTo         []string
+	reportFormats    []string
+	reportSchedule   *cronSchedule
+	reportS3Key      *template.Template
+	supportCalendar  supportCalendar
+	owners           ownerMapping
+	collectorPeriods map[string]time.Duration
+	pollSchedule     *cronSchedule
+	blackouts        blackoutWindows
+	natsSubject      *template.Template
+	natsCredentials  *natsCredentials
+	datadogTagMap    map[string]string
+
+	// kubernetesNamespace and kubernetesName are the parts of KubernetesConfig, set by validate. kubernetesNamespace is
+	// empty if the RDSVersionExporterConfig is in the namespace of the pod.
+	kubernetesNamespace, kubernetesName string
+
+	// command is the subcommand the Options are loaded for, if any.
+	command string
+	// checkOnly is true if the Options are only checked, by the config check subcommand, in which case the references
+	// to secrets are parsed but not resolved.
+	checkOnly bool
+	// configFile is the path of the configuration file the Options are loaded from, if any.
+	configFile string
+}
+
+// optionSpec binds an option of Options to its command-line flag and environment variables. When several environment
+// variables are given, they are aliases of each other and are mutually exclusive.
+type optionSpec struct {
+	flag   string
+	envs   []string
+	usage  string
+	secret bool
+	value  flag.Value
+}
+
+// defaultOptions returns the Options used when neither the configuration file, the environment variables nor the
+// command-line flags set them.
+func defaultOptions() *Options {
+	return &Options{
+		ServerPort:             defaultServerPort,
+		MetricsPath:            defaultMetricsPath,
+		PollInterval:           defaultPollInterval,
+		PollBackoffMax:         defaultPollBackoffMax,
+		CatalogRefreshInterval: defaultCatalogRefresh,
+		PolicyRefreshInterval:  defaultPolicyRefresh,
+		AwsApiTimeout:          defaultAwsApiTimeout,
+		AwsApiConcurrency:      defaultAwsApiConcurrency,
+		AwsApiRateLimit:        defaultAwsApiRateLimit,
+		AwsRetryMode:           awsRetryModeStandard,
+		AwsMaxAttempts:         defaultAwsMaxAttempts,
+		AwsMaxBackoff:          defaultAwsMaxBackoff,
+		MaxAccountsInFlight:    defaultMaxAccountsInFlight,
+		MaxRegionsPerAccount:   defaultMaxRegionsPerAccount,
+		DiscoveryBackend:       discoveryBackendDescribe,
+		UpgradePreference:      upgradePreferenceMinor,
+		PlanFormat:             planFormatCLI,
+		LogLevel:               logLevelInfo,
+		AwsMinTLSVersion:       defaultAwsMinTLSVersion,
+		CloudWatchNamespace:    defaultCloudWatchNamespace,
+		DigestTransport:        digestTransportSMTP,
+		DigestSupportEndDays:   defaultDigestSupportEndDays,
+		KafkaFormat:            kafkaFormatJSON,
+		NATSSubject:            defaultNATSSubject,
+		ReportFormats:          reportFormatJSON,
+		ReportS3Key:            defaultReportS3Key,
+	}
+}
+
+// specs returns the optionSpecs of every option, bound to the fields of o.
+func (o *Options) specs() []optionSpec {
+	return []optionSpec{
+		{flag: "server-port", envs: []string{ServerPortEnvName},
+			usage: "the port number that the server listens on", value: (*intValue)(&o.ServerPort)},
+		{flag: "listen-address", envs: []string{ListenAddressEnvName},
+			usage: "the host:port the server listens on, e.g. \"[::]:2112\" or \"127.0.0.1:2112\", instead of the server port",
+			value: (*stringValue)(&o.ListenAddress)},
+		{flag: "metrics-path", envs: []string{MetricsPathEnvName},
+			usage: "the path the metrics are served on", value: (*stringValue)(&o.MetricsPath)},
+		{flag: "admin-address", envs: []string{AdminAddressEnvName},
+			usage: "serve /healthz and /debug/pprof on this host:port instead of the server port",
+			value: (*stringValue)(&o.AdminAddress)},
+		{flag: "poll-interval", envs: []string{PollIntervalEnvName, AwsApiIntervalEnvName},
+			usage: "the interval to update the metrics", value: (*durationValue)(&o.PollInterval)},
+		{flag: "poll-schedule", envs: []string{PollScheduleEnvName},
+			usage: "the cron expression of the snapshots, in UTC, e.g. \"*/10 8-20 * * MON-FRI\", instead of the poll interval",
+			value: (*stringValue)(&o.PollSchedule)},
+		{flag: "blackout-windows", envs: []string{BlackoutWindowsEnvName},
+			usage: "the comma-separated windows without AWS API calls, weekly in UTC, e.g. \"fri:18:00-mon:06:00\", or between two RFC 3339 times",
+			value: (*stringValue)(&o.BlackoutWindows)},
+		{flag: "poll-backoff-max", envs: []string{PollBackoffMaxEnvName},
+			usage: "the longest interval the poll interval backs off to after consecutive failed snapshots, 0 to disable",
+			value: (*durationValue)(&o.PollBackoffMax)},
+		{flag: "catalog-refresh-interval", envs: []string{CatalogRefreshEnvName},
+			usage: "the interval to fetch the engine version catalogs again",
+			value: (*durationValue)(&o.CatalogRefreshInterval)},
+		{flag: "collector-intervals", envs: []string{CollectorIntervalsEnvName},
+			usage: "the intervals to poll the heavy collectors at, e.g. db_snapshots=6h,reserved_instances=24h",
+			value: (*stringValue)(&o.CollectorIntervals)},
+		{flag: "aws-api-timeout", envs: []string{AwsApiTimeoutEnvName},
+			usage: "the timeout of each HTTP request to the AWS API", value: (*durationValue)(&o.AwsApiTimeout)},
+		{flag: "aws-api-concurrency", envs: []string{AwsApiConcurrencyEnvName},
+			usage: "the maximum number of paginated AWS API listings performed in parallel",
+			value: (*intValue)(&o.AwsApiConcurrency)},
+		{flag: "aws-api-rate-limit", envs: []string{AwsApiRateLimitEnvName},
+			usage: "the maximum number of AWS API calls per second, including retries (0: no limit)",
+			value: (*intValue)(&o.AwsApiRateLimit)},
+		{flag: "aws-retry-mode", envs: []string{AwsRetryModeEnvName},
+			usage: `the retry mode of the AWS API calls: "standard", or "adaptive" to slow them down once throttled`,
+			value: (*stringValue)(&o.AwsRetryMode)},
+		{flag: "aws-max-attempts", envs: []string{AwsMaxAttemptsEnvName},
+			usage: "the maximum number of attempts of each AWS API call, including the first one",
+			value: (*intValue)(&o.AwsMaxAttempts)},
+		{flag: "aws-max-backoff", envs: []string{AwsMaxBackoffEnvName},
+			usage: "the maximum delay between two attempts of an AWS API call",
+			value: (*durationValue)(&o.AwsMaxBackoff)},
+		{flag: "regions", envs: []string{RegionsEnvName},
+			usage: "the comma separated AWS regions to scan, or all for every enabled region (default: the region of the AWS configuration)",
+			value: (*stringValue)(&o.Regions)},
+		{flag: "exclude-regions", envs: []string{ExcludeRegionsEnvName},
+			usage: "the comma separated AWS regions not to scan when scanning all of them",
+			value: (*stringValue)(&o.ExcludeRegions)},
+		{flag: "assume-roles", envs: []string{AssumeRolesEnvName},
+			usage: "the comma separated ARNs of the IAM roles to assume, one per AWS account to scan",
+			value: (*stringValue)(&o.AssumeRoles)},
+		{flag: "max-accounts-in-flight", envs: []string{MaxAccountsInFlightEnvName},
+			usage: "the maximum number of AWS accounts scanned in parallel", value: (*intValue)(&o.MaxAccountsInFlight)},
+		{flag: "max-regions-per-account", envs: []string{MaxRegionsPerAccountEnvName},
+			usage: "the maximum number of regions scanned in parallel within each AWS account",
+			value: (*intValue)(&o.MaxRegionsPerAccount)},
+		{flag: "aws-ca-bundle", envs: []string{AwsCABundleEnvName},
+			usage: "a PEM file of additional CA certificates to trust on AWS API connections",
+			value: (*stringValue)(&o.AwsCABundle)},
+		{flag: "aws-min-tls-version", envs: []string{AwsMinTLSVersionEnvName},
+			usage: "the minimum TLS version of AWS API connections: 1.0, 1.1, 1.2 or 1.3",
+			value: (*stringValue)(&o.AwsMinTLSVersion)},
+		{flag: "discovery-backend", envs: []string{DiscoveryBackendEnvName},
+			usage: "how RDS resources are discovered: describe or tagging", value: (*stringValue)(&o.DiscoveryBackend)},
+		{flag: "tag-filters", envs: []string{TagFiltersEnvName},
+			usage: "only export resources matching these tag filters", value: (*stringValue)(&o.TagFilters)},
+		{flag: "global-clusters", envs: []string{GlobalClustersEnvName},
+			usage: "export the Aurora Global Database topology", value: (*boolValue)(&o.GlobalClusters)},
+		{flag: "instance-classes", envs: []string{InstanceClassesEnvName},
+			usage: "check whether instance classes are still orderable", value: (*boolValue)(&o.InstanceClasses)},
+		{flag: "babelfish", envs: []string{BabelfishEnvName},
+			usage: "check whether Babelfish is enabled on the Aurora PostgreSQL clusters", value: (*boolValue)(&o.Babelfish)},
+		{flag: "catalog-info", envs: []string{CatalogInfoEnvName},
+			usage: "export the catalog of every engine, whether it is in use or not", value: (*boolValue)(&o.CatalogInfo)},
+		{flag: "db-snapshots", envs: []string{DBSnapshotsEnvName},
+			usage: "check the engine version of the manual snapshots of clusters and instances",
+			value: (*boolValue)(&o.DBSnapshots)},
+		{flag: "forced-upgrade-deadlines", envs: []string{ForcedUpgradesEnvName},
+			usage: "export when AWS upgrades the resources running a deprecated engine version",
+			value: (*boolValue)(&o.ForcedUpgradeDeadlines)},
+		{flag: "reserved-instances", envs: []string{ReservedInstancesEnvName},
+			usage: "check which instances on a deprecated engine version are covered by a reserved DB instance",
+			value: (*boolValue)(&o.ReservedInstances)},
+		{flag: "health-events", envs: []string{HealthEventsEnvName},
+			usage: "export the upcoming end of support and mandatory upgrade events of the AWS Health API",
+			value: (*boolValue)(&o.HealthEvents)},
+		{flag: "trusted-advisor", envs: []string{TrustedAdvisorEnvName},
+			usage: "export the Trusted Advisor checks of RDS, e.g. the idle instances and the security group risks",
+			value: (*boolValue)(&o.TrustedAdvisor)},
+		{flag: "support-calendar", envs: []string{SupportCalendarEnvName},
+			usage: "export the number of days until the end of the standard support of engine versions",
+			value: (*boolValue)(&o.SupportCalendar)},
+		{flag: "support-calendar-file", envs: []string{SupportCalendarFileEnvName},
+			usage: "the support calendar written by the update-calendar subcommand, instead of the embedded one",
+			value: (*stringValue)(&o.SupportCalendarFile)},
+		{flag: "policy-url", envs: []string{PolicyURLEnvName}, secret: true,
+			usage: "the HTTP(S) or s3://bucket/key URL of a version policy banning engine versions",
+			value: (*stringValue)(&o.PolicyURL)},
+		{flag: "policy-refresh-interval", envs: []string{PolicyRefreshEnvName},
+			usage: "the interval between two downloads of the version policy",
+			value: (*durationValue)(&o.PolicyRefreshInterval)},
+		{flag: "extended-support-cost", envs: []string{ExtendedSupportCostEnvName},
+			usage: "export the estimated monthly cost of the Extended Support of instances and accounts",
+			value: (*boolValue)(&o.ExtendedSupportCost)},
+		{flag: "upgrade-recommendations", envs: []string{RecommendationsEnvName},
+			usage: "export the upgrade target recommended for the resources running a deprecated engine version",
+			value: (*boolValue)(&o.UpgradeRecommendations)},
+		{flag: "upgrade-preference", envs: []string{UpgradePreferenceEnvName},
+			usage: "the upgrade targets recommended first: minor, to stay on the major version, or major",
+			value: (*stringValue)(&o.UpgradePreference)},
+		{flag: "prefer-default-version", envs: []string{PreferDefaultEnvName},
+			usage: "recommend the default minor version rather than the latest one",
+			value: (*boolValue)(&o.PreferDefaultVersion)},
+		{flag: "plan-format", envs: []string{PlanFormatEnvName},
+			usage: "the format of the upgrade plan written by the plan subcommand: cli or json",
+			value: (*stringValue)(&o.PlanFormat)},
+		{flag: "auto-minor-remediation", envs: []string{AutoMinorRemediationEnvName},
+			usage: "enable the automatic minor version upgrades of the instances matching the remediation tag filters",
+			value: (*boolValue)(&o.AutoMinorRemediation)},
+		{flag: "remediation-apply", envs: []string{RemediationApplyEnvName},
+			usage: "apply the remediations with ModifyDBInstance rather than only logging them",
+			value: (*boolValue)(&o.RemediationApply)},
+		{flag: "remediation-tag-filters", envs: []string{RemediationFiltersEnvName},
+			usage: "the tag filters selecting the instances to remediate, e.g. env=dev|staging",
+			value: (*stringValue)(&o.RemediationTagFilters)},
+		{flag: "owners-file", envs: []string{OwnersFileEnvName},
+			usage: "the file mapping resources to their team, owner and Slack channel", value: (*stringValue)(&o.OwnersFile)},
+		{flag: "exclude-stopped", envs: []string{ExcludeStoppedEnvName},
+			usage: "skip the stopped clusters and instances", value: (*boolValue)(&o.ExcludeStopped)},
+		{flag: "kubernetes-config", envs: []string{KubernetesConfigEnvName},
+			usage: "the [namespace/]name of the RDSVersionExporterConfig setting the regions, accounts and filters",
+			value: (*stringValue)(&o.KubernetesConfig)},
+		{flag: "config-reload", envs: []string{ConfigReloadEnvName},
+			usage: "reload the configuration file when it changes", value: (*boolValue)(&o.ConfigReload)},
+		{flag: "readiness-gating", envs: []string{ReadinessGatingEnvName},
+			usage: "respond 503 on /metrics until the first snapshot completed", value: (*boolValue)(&o.ReadinessGating)},
+		{flag: "sample-timestamps", envs: []string{SampleTimestampsEnvName},
+			usage: "stamp the samples with the time of the last successful snapshot of their scope",
+			value: (*boolValue)(&o.SampleTimestamps)},
+		{flag: "drop-labels", envs: []string{DropLabelsEnvName},
+			usage: "the comma separated labels of the per-resource metrics to export empty, e.g. cluster_identifier",
+			value: (*stringValue)(&o.DropLabels)},
+		{flag: "hash-labels", envs: []string{HashLabelsEnvName},
+			usage: "the comma separated labels of the per-resource metrics whose values are hashed",
+			value: (*stringValue)(&o.HashLabels)},
+		{flag: "max-series", envs: []string{MaxSeriesEnvName},
+			usage: "the maximum number of series of each per-resource metric (0: no limit)", value: (*intValue)(&o.MaxSeries)},
+		{flag: "deprecation-grace-days", envs: []string{DeprecationGraceEnvName},
+			usage: "the number of days a version that flipped to deprecated is reported in grace (0: no grace)",
+			value: (*intValue)(&o.DeprecationGraceDays)},
+		{flag: "dry-run",
+			usage: "print the AWS API calls the exporter would perform, without calling AWS",
+			value: (*boolValue)(&o.DryRun)},
+		{flag: "dump-dir",
+			usage: "take a single snapshot, dump the raw AWS API responses into this directory, redacted, then exit",
+			value: (*stringValue)(&o.DumpDir)},
+		{flag: "log-level", envs: []string{LogLevelEnvName},
+			usage: "the log level: info or debug, which logs the request ID of every AWS API call",
+			value: (*stringValue)(&o.LogLevel)},
+		{flag: "signal-dump-file", envs: []string{SignalDumpFileEnvName},
+			usage: "dump the metrics into this file rather than to stdout on SIGUSR1",
+			value: (*stringValue)(&o.SignalDumpFile)},
+		{flag: "aws-config-event", envs: []string{AwsConfigEventEnvName},
+			usage: "put the evaluations of the AWS Config rule invocation event in this JSON file (- for stdin), then exit",
+			value: (*stringValue)(&o.AwsConfigEvent)},
+		{flag: "demo", envs: []string{DemoEnvName},
+			usage: "serve synthetic RDS resources, without AWS credentials", value: (*boolValue)(&o.Demo)},
+		{flag: "record-dir", envs: []string{RecordDirEnvName},
+			usage: "record the raw AWS API responses into this directory", value: (*stringValue)(&o.RecordDir)},
+		{flag: "replay-dir", envs: []string{ReplayDirEnvName},
+			usage: "replay the AWS API responses recorded into this directory, without calling AWS",
+			value: (*stringValue)(&o.ReplayDir)},
+		{flag: "sentry-dsn", envs: []string{SentryDSNEnvName}, secret: true,
+			usage: "report snapshot failures and panics to this Sentry project", value: (*stringValue)(&o.SentryDSN)},
+		{flag: "otel-endpoint", envs: []string{OtelEndpointEnvName},
+			usage: "export OpenTelemetry spans of the snapshots and AWS API calls to this OTLP/HTTP traces endpoint",
+			value: (*stringValue)(&o.OtelEndpoint)},
+		{flag: "otel-headers", envs: []string{OtelHeadersEnvName}, secret: true,
+			usage: "headers sent along with the spans, e.g. \"x-honeycomb-team=<key>\"",
+			value: (*stringValue)(&o.OtelHeaders)},
+		{flag: "sink", envs: []string{SinkEnvName},
+			usage: "the sink the metrics are pushed to after each snapshot: pushgateway, remote_write, cloudwatch, influx, graphite or datadog",
+			value: (*stringValue)(&o.Sink)},
+		{flag: "sink-url", envs: []string{SinkURLEnvName}, secret: true,
+			usage: "the URL of the Pushgateway, of the remote write endpoint, of the InfluxDB write endpoint or of the Datadog API, or the host:port of Graphite",
+			value: (*stringValue)(&o.SinkURL)},
+		{flag: "sink-token", envs: []string{SinkTokenEnvName}, secret: true,
+			usage: "the API token of the InfluxDB sink, or the API key of the Datadog sink",
+			value: (*stringValue)(&o.SinkToken)},
+		{flag: "cloudwatch-namespace", envs: []string{CloudWatchNamespaceEnvName},
+			usage: "the CloudWatch namespace of the metrics pushed to CloudWatch",
+			value: (*stringValue)(&o.CloudWatchNamespace)},
+		{flag: "graphite-prefix", envs: []string{GraphitePrefixEnvName},
+			usage: "the prefix of the paths of the metrics sent to Graphite, e.g. \"infra.rds\"",
+			value: (*stringValue)(&o.GraphitePrefix)},
+		{flag: "graphite-tags", envs: []string{GraphiteTagsEnvName},
+			usage: "send the labels as the tags of the Graphite paths instead of their nodes",
+			value: (*boolValue)(&o.GraphiteTags)},
+		{flag: "datadog-tag-map", envs: []string{DatadogTagMapEnvName},
+			usage: "the comma-separated label=tag renames of the labels into Datadog tags, e.g. \"cluster_identifier=dbclusteridentifier\"",
+			value: (*stringValue)(&o.DatadogTagMap)},
+		{flag: "user-agent-suffix", envs: []string{UserAgentSuffixEnvName},
+			usage: "appended to the User-Agent of AWS API calls", value: (*stringValue)(&o.UserAgentSuffix)},
+		{flag: "digest-schedule", envs: []string{DigestScheduleEnvName},
+			usage: "email a digest of the deprecated resources on this cron schedule, in UTC, e.g. \"0 8 * * MON\"",
+			value: (*stringValue)(&o.DigestSchedule)},
+		{flag: "digest-transport", envs: []string{DigestTransportEnvName},
+			usage: "how the digest is sent: smtp or ses", value: (*stringValue)(&o.DigestTransport)},
+		{flag: "digest-from", envs: []string{DigestFromEnvName},
+			usage: "the sender address of the digest", value: (*stringValue)(&o.DigestFrom)},
+		{flag: "digest-to", envs: []string{DigestToEnvName},
+			usage: "the comma separated recipient addresses of the digest", value: (*stringValue)(&o.DigestTo)},
+		{flag: "digest-group-by-tag", envs: []string{DigestGroupByTagEnvName},
+			usage: "group the resources of the digest by the value of this tag within each account, e.g. team",
+			value: (*stringValue)(&o.DigestGroupByTag)},
+		{flag: "digest-smtp-address", envs: []string{DigestSMTPAddressEnvName},
+			usage: "the host:port of the SMTP server sending the digest", value: (*stringValue)(&o.DigestSMTPAddress)},
+		{flag: "digest-smtp-username", envs: []string{DigestSMTPUsernameEnvName},
+			usage: "the username of the SMTP server", value: (*stringValue)(&o.DigestSMTPUsername)},
+		{flag: "digest-smtp-password", envs: []string{DigestSMTPPasswordEnvName}, secret: true,
+			usage: "the password of the SMTP server", value: (*stringValue)(&o.DigestSMTPPassword)},
+		{flag: "digest-ses-region", envs: []string{DigestSESRegionEnvName},
+			usage: "the region of the SES API sending the digest (default: the region of the AWS configuration)",
+			value: (*stringValue)(&o.DigestSESRegion)},
+		{flag: "digest-support-end-days", envs: []string{DigestSupportEndDaysEnvName},
+			usage: "list the resources whose standard support ends within this number of days in the digest",
+			value: (*intValue)(&o.DigestSupportEndDays)},
+		{flag: "eventbridge-bus", envs: []string{EventBridgeBusEnvName},
+			usage: "publish the engine version status changes on this EventBridge bus, by name or ARN",
+			value: (*stringValue)(&o.EventBridgeBus)},
+		{flag: "kafka-rest-url", envs: []string{KafkaRESTURLEnvName}, secret: true,
+			usage: "publish the inventory and the status changes on Kafka through the REST Proxy at this URL",
+			value: (*stringValue)(&o.KafkaRESTURL)},
+		{flag: "kafka-inventory-topic", envs: []string{KafkaInventoryEnvName},
+			usage: "the Kafka topic of the inventory, a record per resource after each snapshot",
+			value: (*stringValue)(&o.KafkaInventoryTopic)},
+		{flag: "kafka-transitions-topic", envs: []string{KafkaTransitionsEnvName},
+			usage: "the Kafka topic of the engine version status changes",
+			value: (*stringValue)(&o.KafkaTransitionsTopic)},
+		{flag: "kafka-format", envs: []string{KafkaFormatEnvName},
+			usage: "the format of the Kafka records: json or avro",
+			value: (*stringValue)(&o.KafkaFormat)},
+		{flag: "nats-url", envs: []string{NATSURLEnvName}, secret: true,
+			usage: "publish the engine version status changes on the NATS server at this nats:// or tls:// URL",
+			value: (*stringValue)(&o.NATSURL)},
+		{flag: "nats-subject", envs: []string{NATSSubjectEnvName},
+			usage: "the template of the NATS subjects of the status changes",
+			value: (*stringValue)(&o.NATSSubject)},
+		{flag: "nats-creds-file", envs: []string{NATSCredsFileEnvName},
+			usage: "the NATS credentials file, holding the user JWT and nkey seed",
+			value: (*stringValue)(&o.NATSCredsFile)},
+		{flag: "nats-jetstream", envs: []string{NATSJetStreamEnvName},
+			usage: "wait for the JetStream acknowledgement of each NATS message",
+			value: (*boolValue)(&o.NATSJetStream)},
+		{flag: "rds-events-queue-url", envs: []string{RDSEventsQueueURLEnvName},
+			usage: "receive the RDS events from this SQS queue, to trigger snapshots and export maintenance announcements",
+			value: (*stringValue)(&o.RDSEventsQueueURL)},
+		{flag: "report-formats", envs: []string{ReportFormatsEnvName},
+			usage: "the comma separated formats of the inventory reports: json or csv",
+			value: (*stringValue)(&o.ReportFormats)},
+		{flag: "report-schedule", envs: []string{ReportScheduleEnvName},
+			usage: "upload the inventory reports on this cron schedule, in UTC (default: after each snapshot)",
+			value: (*stringValue)(&o.ReportSchedule)},
+		{flag: "report-s3-bucket", envs: []string{ReportS3BucketEnvName},
+			usage: "upload the inventory reports of each account to this S3 bucket", value: (*stringValue)(&o.ReportS3Bucket)},
+		{flag: "report-s3-key", envs: []string{ReportS3KeyEnvName},
+			usage: "the template of the S3 keys of the inventory reports", value: (*stringValue)(&o.ReportS3Key)},
+		{flag: "report-s3-region", envs: []string{ReportS3RegionEnvName},
+			usage: "the region of the S3 bucket (default: the region of the AWS configuration)",
+			value: (*stringValue)(&o.ReportS3Region)},
+		{flag: "report-s3-sse", envs: []string{ReportS3SSEEnvName},
+			usage: "the server-side encryption of the inventory reports: AES256 or aws:kms (default: the bucket's)",
+			value: (*stringValue)(&o.ReportS3SSE)},
+		{flag: "report-s3-kms-key-id", envs: []string{ReportS3KMSKeyIDEnvName},
+			usage: "the KMS key encrypting the inventory reports with aws:kms", value: (*stringValue)(&o.ReportS3KMSKeyID)},
+	}
+}
+
+// loadOptions returns the effective Options, merging by increasing precedence the defaults, the YAML configuration
+// file, the environment variables read with lookupEnv, and the command-line flags parsed from args. The configuration
+// file is given by the -config-file flag or the EXPORTER_CONFIG_FILE environment variable. An error is returned if any
+// value cannot be parsed, or if the effective Options are not valid.
+func loadOptions(args []string, lookupEnv func(string) (string, bool)) (*Options, error) {
+	return defaultOptions().load(args, lookupEnv)
+}
+
+// loadCommandOptions returns the effective Options of a subcommand, e.g. "report", like loadOptions. Subcommands do not
+// serve the metrics, hence do not require the server port.
+func loadCommandOptions(command string, args []string, lookupEnv func(string) (string, bool)) (*Options, error) {
+	o := defaultOptions()
+	o.command = command
+	return o.load(args, lookupEnv)
+}
+
+// load merges the configuration file, the environment variables and the command-line flags into o, and validates it.
+func (o *Options) load(args []string, lookupEnv func(string) (string, bool)) (*Options, error) {
+	specs := o.specs()
+
+	// flags are parsed first to find the configuration file, but applied last
+	name := exporterName
+	if o.command != "" {
+		name += " " + o.command
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile, _ := lookupEnv(ConfigFileEnvName)
+	fs.StringVar(&configFile, "config-file", configFile, "the path of the YAML configuration file")
+	for _, spec := range specs {
+		_, isBool := spec.value.(*boolValue)
+		fs.Var(&rawValue{value: spec.value.String(), isBool: isBool}, spec.flag, spec.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	o.configFile = configFile
+	if configFile != "" {
+		b, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration file; %w", err)
+		}
+		if err := yaml.Unmarshal(b, o); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s; %w", configFile, err)
+		}
+		if err := checkDurationStrings(b, specs); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s; %w", configFile, err)
+		}
+	}
+
+	for _, spec := range specs {
+		var setEnvs []string
+		for _, name := range spec.envs {
+			value, ok := lookupEnv(name)
+			if !ok || len(value) == 0 {
+				continue
+			}
+			setEnvs = append(setEnvs, name)
+			if err := spec.value.Set(value); err != nil {
+				return nil, fmt.Errorf("environment variable %s could not be parsed: %w", name, err)
+			}
+		}
+		if len(setEnvs) > 1 {
+			return nil, fmt.Errorf("environment variables %s are mutually exclusive", strings.Join(setEnvs, " and "))
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, spec := range specs {
+			if err == nil && spec.flag == f.Name {
+				if setErr := spec.value.Set(f.Value.String()); setErr != nil {
+					err = fmt.Errorf("flag -%s could not be parsed: %w", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// checkDurationStrings checks that the durations of a configuration file are duration strings, e.g. "60s": yaml.v2
+// would otherwise read an integer, e.g. 60, as a number of nanoseconds.
+func checkDurationStrings(b []byte, specs []optionSpec) error {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var problems []string
+	for _, spec := range specs {
+		if _, isDuration := spec.value.(*durationValue); !isDuration {
+			continue
+		}
+		key := strings.ReplaceAll(spec.flag, "-", "_")
+		if value, ok := raw[key]; ok && value != nil {
+			if _, isString := value.(string); !isString {
+				problems = append(problems, fmt.Sprintf("%s should be a duration string, e.g. \"60s\", got %v", key,
+					value))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validate checks that the port and the intervals are within their bounds, that enumerated options have a supported
+// value, that mutually exclusive options are not both set, and parses the regions, the roles to assume, the tag filters,
+// the Sentry DSN, the CA bundle, the minimum TLS version and the digest settings. Every problem found is reported in the
+// returned error.
+func (o *Options) validate() error {
+	var problems []string
+	problems = append(problems, o.validateListen()...)
+	for _, d := range []struct {
+		name          string
+		value, lo, hi time.Duration
+	}{
+		{"poll interval", o.PollInterval, minPollInterval, maxPollInterval},
+		{"catalog refresh interval", o.CatalogRefreshInterval, minCatalogRefresh, maxCatalogRefresh},
+		{"policy refresh interval", o.PolicyRefreshInterval, minPolicyRefresh, maxPolicyRefresh},
+		{"AWS API timeout", o.AwsApiTimeout, minAwsApiTimeout, maxAwsApiTimeout},
+		{"AWS max backoff", o.AwsMaxBackoff, minAwsMaxBackoff, maxAwsMaxBackoff},
+	} {
+		if d.value < d.lo || d.value > d.hi {
+			problems = append(problems, fmt.Sprintf("%s should be between %s and %s, got %s", d.name, d.lo, d.hi, d.value))
+		}
+	}
+	if o.PollBackoffMax < 0 || o.PollBackoffMax > maxPollInterval {
+		problems = append(problems, fmt.Sprintf("poll backoff max should be between 0s and %s, got %s",
+			maxPollInterval, o.PollBackoffMax))
+	}
+	for _, c := range []struct {
+		name  string
+		value int
+	}{
+		{"AWS API concurrency", o.AwsApiConcurrency},
+		{"max accounts in flight", o.MaxAccountsInFlight},
+		{"max regions per account", o.MaxRegionsPerAccount},
+	} {
+		if c.value < 1 || c.value > maxAwsApiConcurrency {
+			problems = append(problems, fmt.Sprintf("%s should be between 1 and %d, got %d",
+				c.name, maxAwsApiConcurrency, c.value))
+		}
+	}
+	// the rate limiter ticks once per second divided by the rate, which must not round down to zero
+	if o.AwsApiRateLimit < 0 || o.AwsApiRateLimit > maxAwsApiRateLimit {
+		problems = append(problems, fmt.Sprintf("AWS API rate limit should be between 0 and %d, got %d",
+			maxAwsApiRateLimit, o.AwsApiRateLimit))
+	}
+	if o.AwsRetryMode != awsRetryModeStandard && o.AwsRetryMode != awsRetryModeAdaptive {
+		problems = append(problems, fmt.Sprintf("AWS retry mode should be either %q or %q, got %q",
+			awsRetryModeStandard, awsRetryModeAdaptive, o.AwsRetryMode))
+	}
+	if o.AwsMaxAttempts < 1 || o.AwsMaxAttempts > maxAwsMaxAttempts {
+		problems = append(problems, fmt.Sprintf("AWS max attempts should be between 1 and %d, got %d",
+			maxAwsMaxAttempts, o.AwsMaxAttempts))
+	}
+	if o.DeprecationGraceDays < 0 {
+		problems = append(problems, fmt.Sprintf("deprecation grace days should not be negative, got %d",
+			o.DeprecationGraceDays))
+	}
+	if o.DiscoveryBackend != discoveryBackendDescribe && o.DiscoveryBackend != discoveryBackendTagging {
+		problems = append(problems, fmt.Sprintf("discovery backend should be either %q or %q, got %q",
+			discoveryBackendDescribe, discoveryBackendTagging, o.DiscoveryBackend))
+	}
+	if o.UpgradePreference != upgradePreferenceMinor && o.UpgradePreference != upgradePreferenceMajor {
+		problems = append(problems, fmt.Sprintf("upgrade preference should be either %q or %q, got %q",
+			upgradePreferenceMinor, upgradePreferenceMajor, o.UpgradePreference))
+	}
+	if o.PlanFormat != planFormatCLI && o.PlanFormat != planFormatJSON {
+		problems = append(problems, fmt.Sprintf("plan format should be either %q or %q, got %q",
+			planFormatCLI, planFormatJSON, o.PlanFormat))
+	}
+	if o.RecordDir != "" && o.ReplayDir != "" {
+		problems = append(problems, "record and replay directories are mutually exclusive")
+	}
+	if o.DumpDir != "" && (o.RecordDir != "" || o.ReplayDir != "") {
+		problems = append(problems, "dump directory is mutually exclusive with the record and replay directories")
+	}
+	if o.LogLevel != logLevelInfo && o.LogLevel != logLevelDebug {
+		problems = append(problems, fmt.Sprintf("log level should be either %q or %q, got %q", logLevelInfo,
+			logLevelDebug, o.LogLevel))
+	}
+	for _, dir := range []string{o.RecordDir, o.ReplayDir, o.DumpDir} {
+		if info, err := os.Stat(dir); dir != "" && (err != nil || !info.IsDir()) {
+			problems = append(problems, fmt.Sprintf("%s should be an existing directory", dir))
+		}
+	}
+	o.regions = splitList(o.Regions)
+	o.excludeRegions = splitList(o.ExcludeRegions)
+	if contains(o.regions, allRegions) {
+		if len(o.regions) > 1 {
+			problems = append(problems, fmt.Sprintf("regions should be either %q or a list of regions, got %q",
+				allRegions, o.Regions))
+		}
+		o.allRegions, o.regions = true, nil
+	}
+	if len(o.excludeRegions) > 0 && !o.allRegions {
+		problems = append(problems, fmt.Sprintf("excluded regions require regions to be %q", allRegions))
+	}
+	o.roleARNs = splitList(o.AssumeRoles)
+	for _, roleARN := range o.roleARNs {
+		if !strings.HasPrefix(roleARN, "arn:") || !strings.Contains(roleARN, ":role/") {
+			problems = append(problems, fmt.Sprintf("%q is not the ARN of an IAM role", roleARN))
+		}
+	}
+	if o.TagFilters != "" {
+		tagFilters, err := parseTagFilters(o.TagFilters)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.tagFilters = tagFilters
+	}
+	collectorPeriods, err := parseCollectorIntervals(o.CollectorIntervals)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.collectorPeriods = collectorPeriods
+	if o.PollSchedule != "" {
+		schedule, err := parseCronSchedule(o.PollSchedule)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else if schedule.next(time.Now()).IsZero() {
+			problems = append(problems, fmt.Sprintf("poll schedule %q never matches", o.PollSchedule))
+		}
+		o.pollSchedule = schedule
+	}
+	blackouts, err := parseBlackoutWindows(o.BlackoutWindows)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.blackouts = blackouts
+	o.remediationTags = nil
+	if o.RemediationTagFilters != "" {
+		tagFilters, err := parseTagFilters(o.RemediationTagFilters)
+		if err != nil {
+			problems = append(problems, "remediation "+err.Error())
+		}
+		o.remediationTags = tagFilters
+	}
+	if o.AutoMinorRemediation && o.RemediationTagFilters == "" {
+		problems = append(problems, "auto minor remediation requires remediation tag filters")
+	}
+	tlsVersion, err := parseTLSVersion(o.AwsMinTLSVersion)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.awsMinTLSVersion = tlsVersion
+	if o.AwsCABundle != "" {
+		rootCAs, err := loadCABundle(o.AwsCABundle)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.awsRootCAs = rootCAs
+	}
+	// the secrets are resolved with the AWS TLS settings, before the secret options are parsed; when the options are
+	// only checked, the references are only parsed, without AWS credentials nor network access, and the secret options
+	// still holding them are not parsed
+	resolver := &secretResolver{parseOnly: true}
+	if !o.checkOnly {
+		resolver = newAWSSecretResolver(o)
+	}
+	problems = append(problems, o.resolveSecrets(resolver)...)
+	if o.SentryDSN != "" && !isSecretReference(o.SentryDSN) {
+		dsn, err := parseSentryDSN(o.SentryDSN)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.sentryDSN = dsn
+	}
+	if o.OtelEndpoint != "" {
+		endpoint, err := parseOtelEndpoint(o.OtelEndpoint)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.otelEndpoint = endpoint
+	}
+	if o.OtelHeaders != "" && !isSecretReference(o.OtelHeaders) {
+		headers, err := parseOtelHeaders(o.OtelHeaders)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.otelHeaders = headers
+	}
+	problems = append(problems, o.validateSink()...)
+	problems = append(problems, o.validateKafka()...)
+	problems = append(problems, o.validateNATS()...)
+	problems = append(problems, o.validateTenants()...)
+	problems = append(problems, o.validateLabels()...)
+	problems = append(problems, o.APIFilters.validate()...)
+	for i, ack := range o.Acknowledgements {
+		if err := ack.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("acknowledgement %d: %s", i+1, err))
+		}
+	}
+	calendar, err := loadSupportCalendar(o.SupportCalendarFile)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.supportCalendar = calendar
+	if o.PolicyURL != "" && !isSecretReference(o.PolicyURL) {
+		if err := validatePolicyURL(o.PolicyURL); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	owners, err := loadOwnerMapping(o.OwnersFile)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.owners = owners
+	if o.ConfigReload && o.configFile == "" {
+		problems = append(problems, "config reload requires a configuration file")
+	}
+	o.kubernetesNamespace, o.kubernetesName = "", o.KubernetesConfig
+	if i := strings.Index(o.KubernetesConfig, "/"); i >= 0 {
+		o.kubernetesNamespace, o.kubernetesName = o.KubernetesConfig[:i], o.KubernetesConfig[i+1:]
+		if o.kubernetesNamespace == "" || o.kubernetesName == "" || strings.Contains(o.kubernetesName, "/") {
+			problems = append(problems, fmt.Sprintf("kubernetes config should be [namespace/]name, got %q",
+				o.KubernetesConfig))
+		}
+	}
+	if o.DigestSchedule != "" {
+		problems = append(problems, o.validateDigest()...)
+	}
+	problems = append(problems, o.validateReports()...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateLabels parses the labels to drop and to hash, which should be labels of the version metrics, checks that
+// the series cap is not negative, and compiles the relabeling rules.
+func (o *Options) validateLabels() []string {
+	var problems []string
+	o.dropLabels = splitList(o.DropLabels)
+	o.hashLabels = splitList(o.HashLabels)
+	dropped := make(map[string]bool)
+	for _, label := range o.dropLabels {
+		dropped[label] = true
+	}
+	for _, label := range append(o.dropLabels, o.hashLabels...) {
+		if !contains(versionLabels, label) {
+			problems = append(problems, fmt.Sprintf("label should be one of %s, got %q",
+				strings.Join(versionLabels, ", "), label))
+		}
+	}
+	for _, label := range o.hashLabels {
+		if dropped[label] {
+			problems = append(problems, fmt.Sprintf("label %q cannot be both dropped and hashed", label))
+		}
+	}
+	if o.MaxSeries < 0 {
+		problems = append(problems, fmt.Sprintf("max series should not be negative, got %d", o.MaxSeries))
+	}
+	for i := range o.RelabelConfigs {
+		if err := o.RelabelConfigs[i].compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("relabel config %d: %s", i+1, err))
+		}
+	}
+	return problems
+}
+
+// validateDigest parses the digest schedule and recipients, checks that the transport is supported and configured, and
+// that the window before the end of the standard support is not negative.
+func (o *Options) validateDigest() []string {
+	var problems []string
+	schedule, err := parseCronSchedule(o.DigestSchedule)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if schedule.next(time.Now()).IsZero() {
+		problems = append(problems, fmt.Sprintf("digest schedule %q never matches", o.DigestSchedule))
+	}
+	o.digestSchedule = schedule
+	o.digestTo = splitList(o.DigestTo)
+	if o.DigestFrom == "" || len(o.digestTo) == 0 {
+		problems = append(problems, "digest sender and recipients should be set")
+	}
+	switch o.DigestTransport {
+	case digestTransportSMTP:
+		if _, _, err := net.SplitHostPort(o.DigestSMTPAddress); err != nil {
+			problems = append(problems, fmt.Sprintf("digest SMTP address should be host:port, got %q", o.DigestSMTPAddress))
+		}
+	case digestTransportSES:
+	default:
+		problems = append(problems, fmt.Sprintf("digest transport should be either %q or %q, got %q",
+			digestTransportSMTP, digestTransportSES, o.DigestTransport))
+	}
+	if o.DigestSupportEndDays < 0 {
+		problems = append(problems, fmt.Sprintf("digest support end days should not be negative, got %d",
+			o.DigestSupportEndDays))
+	}
+	return problems
+}
+
+// validateReports parses the report formats, schedule and S3 key template, and checks the S3 encryption settings.
+func (o *Options) validateReports() []string {
+	var problems []string
+	o.reportFormats = splitList(o.ReportFormats)
+	for _, format := range o.reportFormats {
+		if !contains(reportFormats, format) {
+			problems = append(problems, fmt.Sprintf("report format should be one of %s, got %q",
+				strings.Join(reportFormats, ", "), format))
+		}
+	}
+	if o.ReportSchedule != "" {
+		schedule, err := parseCronSchedule(o.ReportSchedule)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		o.reportSchedule = schedule
+	}
+	if o.ReportS3Bucket == "" {
+		return problems
+	}
+	if len(o.reportFormats) == 0 {
+		problems = append(problems, "report formats should be set")
+	}
+	key, err := parseReportS3Key(o.ReportS3Key)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.reportS3Key = key
+	if !contains(reportSSEs, o.ReportS3SSE) {
+		problems = append(problems, fmt.Sprintf("report S3 server-side encryption should be either %q or %q, got %q",
+			s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms, o.ReportS3SSE))
+	}
+	if o.ReportS3KMSKeyID != "" && o.ReportS3SSE != s3.ServerSideEncryptionAwsKms {
+		problems = append(problems, fmt.Sprintf("report S3 KMS key requires the %q server-side encryption",
+			s3.ServerSideEncryptionAwsKms))
+	}
+	return problems
+}
+
+// String returns the effective configuration, one "flag: value" line per option, with the values of secret options
+// redacted.
+func (o *Options) String() string {
+	return formatOptions(o.specs())
+}
+
+// formatOptions formats the given optionSpecs one per line, redacting the non-empty values of secret options.
+func formatOptions(specs []optionSpec) string {
+	var sb strings.Builder
+	for _, spec := range specs {
+		value := spec.value.String()
+		if spec.secret && value != "" {
+			value = redacted
+		}
+		fmt.Fprintf(&sb, "  %s: %s\n", spec.flag, value)
+	}
+	return sb.String()
+}
+
+// intValue, durationValue, boolValue and stringValue implement flag.Value over the fields of Options.
+type (
+	intValue      int
+	durationValue time.Duration
+	boolValue     bool
+	stringValue   string
+)
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *durationValue) Set(s string) error {
+	d, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// IsBoolFlag lets boolean flags be set without a value, e.g. "-global-clusters".
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+// rawValue records the value of a command-line flag, to be set on its option once the configuration file and the
+// environment variables have been applied.
+type rawValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *rawValue) Set(s string) error {
+	v.value = s
+	return nil
+}
+
+func (v *rawValue) String() string { return v.value }
+
+func (v *rawValue) IsBoolFlag() bool { return v.isBool }
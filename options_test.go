@@ -0,0 +1,356 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoadOptions tests the loadOptions function, and the precedence of flags over environment variables over the
+// configuration file over the defaults.
+func TestLoadOptions(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte("server_port: 2112\npoll_interval: 1m\naws_api_concurrency: 8\n"), 0o600)
+	assert.NoError(t, err)
+	acknowledgementsFile := filepath.Join(t.TempDir(), "acknowledgements.yaml")
+	err = os.WriteFile(acknowledgementsFile, []byte("server_port: 2112\nacknowledgements:\n  - identifier: legacy-cms\n"), 0o600)
+	assert.NoError(t, err)
+	integerDurationFile := filepath.Join(t.TempDir(), "integer-duration.yaml")
+	err = os.WriteFile(integerDurationFile, []byte("server_port: 2112\npoll_interval: 60\naws_api_timeout: 10s\n"), 0o600)
+	assert.NoError(t, err)
+	sinksFile := filepath.Join(t.TempDir(), "sinks.yaml")
+	err = os.WriteFile(sinksFile, []byte("server_port: 2112\nsink: cloudwatch\nsinks:\n  - type: cloudwatch\n"+
+		"  - type: influx\n"), 0o600)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		want    func(o *Options)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: func(o *Options) {},
+		},
+		{
+			name: "server port and metrics path",
+			env:  map[string]string{ServerPortEnvName: "9187", MetricsPathEnvName: "/prometheus/metrics"},
+			want: func(o *Options) { o.ServerPort, o.MetricsPath = 9187, "/prometheus/metrics" },
+		},
+		{
+			name: "listen address",
+			args: []string{"-server-port", "9187", "-listen-address", "[::]:2112"},
+			want: func(o *Options) { o.ServerPort, o.ListenAddress = 9187, "[::]:2112" },
+		},
+		{
+			name: "configuration file",
+			args: []string{"-config-file", configFile},
+			want: func(o *Options) {
+				o.configFile = configFile
+				o.ServerPort = 2112
+				o.PollInterval = time.Minute
+				o.AwsApiConcurrency = 8
+			},
+		},
+		{
+			name: "environment variables override the configuration file",
+			env: map[string]string{
+				ConfigFileEnvName:        configFile,
+				AwsApiIntervalEnvName:    "120",
+				AwsApiConcurrencyEnvName: "2",
+				GlobalClustersEnvName:    "true",
+			},
+			want: func(o *Options) {
+				o.configFile = configFile
+				o.ServerPort = 2112
+				o.PollInterval = 2 * time.Minute
+				o.AwsApiConcurrency = 2
+				o.GlobalClusters = true
+			},
+		},
+		{
+			name: "flags override environment variables",
+			args: []string{"-config-file", configFile, "-poll-interval", "30s", "-instance-classes",
+				"-tag-filters", "env=prod"},
+			env: map[string]string{PollIntervalEnvName: "2m", InstanceClassesEnvName: "false"},
+			want: func(o *Options) {
+				o.configFile = configFile
+				o.ServerPort = 2112
+				o.PollInterval = 30 * time.Second
+				o.AwsApiConcurrency = 8
+				o.InstanceClasses = true
+				o.TagFilters = "env=prod"
+			},
+		},
+		{
+			name:    "mutually exclusive environment variables",
+			env:     map[string]string{ServerPortEnvName: "2112", PollIntervalEnvName: "1m", AwsApiIntervalEnvName: "60"},
+			wantErr: "environment variables EXPORTER_POLL_INTERVAL and EXPORTER_AWS_API_INTERVAL_SECONDS are mutually exclusive",
+		},
+		{
+			name:    "unparsable flag",
+			args:    []string{"-server-port", "2112", "-aws-api-concurrency", "many"},
+			wantErr: `flag -aws-api-concurrency could not be parsed: strconv.Atoi: parsing "many": invalid syntax`,
+		},
+		{
+			name: "integer duration in the configuration file",
+			args: []string{"-config-file", integerDurationFile},
+			wantErr: "failed to parse configuration file " + integerDurationFile +
+				`; poll_interval should be a duration string, e.g. "60s", got 60`,
+		},
+		{
+			name: "invalid values",
+			args: []string{"-server-port", "70000", "-poll-interval", "1s", "-discovery-backend", "scan"},
+			wantErr: "invalid configuration: server port should be between 1 and 65535, got 70000; " +
+				"poll interval should be between 10s and 24h0m0s, got 1s; " +
+				`discovery backend should be either "describe" or "tagging", got "scan"`,
+		},
+		{
+			name: "invalid retries",
+			args: []string{"-server-port", "2112", "-aws-retry-mode", "legacy", "-aws-max-attempts", "0",
+				"-aws-max-backoff", "10m"},
+			wantErr: "invalid configuration: AWS max backoff should be between 1s and 5m0s, got 10m0s; " +
+				`AWS retry mode should be either "standard" or "adaptive", got "legacy"; ` +
+				"AWS max attempts should be between 1 and 20, got 0",
+		},
+		{
+			name:    "rate limit too high",
+			args:    []string{"-server-port", "2112", "-aws-api-rate-limit", "2000000000"},
+			wantErr: "invalid configuration: AWS API rate limit should be between 0 and 1000000000, got 2000000000",
+		},
+		{
+			name: "invalid upgrade preference and plan format",
+			args: []string{"-server-port", "2112", "-upgrade-preference", "latest", "-plan-format", "terraform"},
+			wantErr: `invalid configuration: upgrade preference should be either "minor" or "major", got "latest"; ` +
+				`plan format should be either "cli" or "json", got "terraform"`,
+		},
+		{
+			name:    "invalid poll backoff max",
+			args:    []string{"-server-port", "2112", "-poll-backoff-max", "48h"},
+			wantErr: "invalid configuration: poll backoff max should be between 0s and 24h0m0s, got 48h0m0s",
+		},
+		{
+			name:    "pushgateway sink without URL",
+			args:    []string{"-server-port", "2112", "-sink", "pushgateway"},
+			wantErr: "invalid configuration: the pushgateway sink requires a sink URL",
+		},
+		{
+			name:    "graphite sink with a URL",
+			args:    []string{"-server-port", "2112", "-sink", "graphite", "-sink-url", "tcp://graphite:2003"},
+			wantErr: "invalid configuration: the graphite sink URL should be host:port, got \"tcp://graphite:2003\"",
+		},
+		{
+			name:    "kafka topic without REST URL",
+			args:    []string{"-server-port", "2112", "-kafka-inventory-topic", "rds.inventory"},
+			wantErr: "invalid configuration: kafka topics require a kafka REST URL",
+		},
+		{
+			name:    "invalid NATS URL",
+			args:    []string{"-server-port", "2112", "-nats-url", "https://nats.example.com"},
+			wantErr: "invalid configuration: NATS URL should be nats://host:port or tls://host:port, got \"https://nats.example.com\"",
+		},
+		{
+			name:    "datadog sink without API key",
+			args:    []string{"-server-port", "2112", "-sink", "datadog"},
+			wantErr: "invalid configuration: the datadog sink requires a sink token, the API key",
+		},
+		{
+			name:    "invalid poll schedule",
+			args:    []string{"-server-port", "2112", "-poll-schedule", "*/10 8-20 * *"},
+			wantErr: "invalid configuration: invalid cron expression \"*/10 8-20 * *\": expected 5 fields, got 4",
+		},
+		{
+			name:    "invalid blackout window",
+			args:    []string{"-server-port", "2112", "-blackout-windows", "2023-12-22T00:00:00Z/2023-12-20T00:00:00Z"},
+			wantErr: "invalid configuration: invalid blackout window \"2023-12-22T00:00:00Z/2023-12-20T00:00:00Z\": the end should be after the start",
+		},
+		{
+			name:    "invalid policy URL",
+			args:    []string{"-server-port", "2112", "-policy-url", "s3://policies"},
+			wantErr: "invalid configuration: policy URL should be s3://bucket/key, got \"s3://policies\"",
+		},
+		{
+			name:    "remediation without tag filters",
+			args:    []string{"-server-port", "2112", "-auto-minor-remediation"},
+			wantErr: "invalid configuration: auto minor remediation requires remediation tag filters",
+		},
+		{
+			name:    "admin address on the server port",
+			args:    []string{"-server-port", "2112", "-admin-address", "127.0.0.1:2112"},
+			wantErr: `invalid configuration: admin address should not overlap the server address ":2112", got "127.0.0.1:2112"`,
+		},
+		{
+			name:    "metrics path of another endpoint",
+			args:    []string{"-metrics-path", "/debug/metrics"},
+			wantErr: `invalid configuration: metrics path should not be the one of another endpoint, got "/debug/metrics"`,
+		},
+		{
+			name:    "relative metrics path",
+			env:     map[string]string{MetricsPathEnvName: "metrics"},
+			wantErr: `invalid configuration: metrics path should be an absolute path, got "metrics"`,
+		},
+		{
+			name:    "invalid listen address",
+			args:    []string{"-listen-address", "::1:2112"},
+			wantErr: `invalid configuration: listen address should be host:port, got "::1:2112"`,
+		},
+		{
+			name:    "listen address out of range",
+			args:    []string{"-listen-address", "127.0.0.1:70000"},
+			wantErr: `invalid configuration: listen address port should be between 1 and 65535, got "127.0.0.1:70000"`,
+		},
+		{
+			name:    "invalid listen host",
+			args:    []string{"-listen-address", "exporter_host:2112"},
+			wantErr: `invalid configuration: listen address should have an IP address or a host name, got "exporter_host:2112"`,
+		},
+		{
+			name:    "admin address on the listen address",
+			args:    []string{"-listen-address", "[::1]:2112", "-admin-address", "[::]:2112"},
+			wantErr: `invalid configuration: admin address should not overlap the server address "[::1]:2112", got "[::]:2112"`,
+		},
+		{
+			name: "invalid labels",
+			args: []string{"-server-port", "2112", "-drop-labels", "role,team", "-hash-labels", "role",
+				"-max-series", "-1"},
+			wantErr: "invalid configuration: label should be one of cluster_identifier, engine, engine_version, " +
+				"engine_version_major, engine_version_minor, role, license_model, edition, rds_custom, babelfish, " +
+				`maintenance_window, account_id, region, got "team"; ` +
+				`label "role" cannot be both dropped and hashed; max series should not be negative, got -1`,
+		},
+		{
+			name: "all regions",
+			args: []string{"-server-port", "2112", "-regions", "all", "-exclude-regions", "me-south-1, af-south-1"},
+			want: func(o *Options) {
+				o.ServerPort = 2112
+				o.Regions = "all"
+				o.ExcludeRegions = "me-south-1, af-south-1"
+			},
+		},
+		{
+			name:    "invalid regions",
+			args:    []string{"-server-port", "2112", "-regions", "all,eu-west-1", "-exclude-regions", "me-south-1"},
+			wantErr: `invalid configuration: regions should be either "all" or a list of regions, got "all,eu-west-1"`,
+		},
+		{
+			name:    "excluded regions without all regions",
+			args:    []string{"-server-port", "2112", "-regions", "eu-west-1", "-exclude-regions", "me-south-1"},
+			wantErr: `invalid configuration: excluded regions require regions to be "all"`,
+		},
+		{
+			name:    "config reload without configuration file",
+			args:    []string{"-server-port", "2112", "-config-reload"},
+			wantErr: "invalid configuration: config reload requires a configuration file",
+		},
+		{
+			name:    "invalid kubernetes config",
+			args:    []string{"-server-port", "2112", "-kubernetes-config", "monitoring/"},
+			wantErr: `invalid configuration: kubernetes config should be [namespace/]name, got "monitoring/"`,
+		},
+		{
+			name:    "invalid otel endpoint",
+			args:    []string{"-server-port", "2112", "-otel-endpoint", "otel-collector:4318"},
+			wantErr: `invalid configuration: otel endpoint should be an http or https URL, got "otel-collector:4318"`,
+		},
+		{
+			name:    "invalid log level",
+			args:    []string{"-server-port", "2112", "-log-level", "trace"},
+			wantErr: `invalid configuration: log level should be either "info" or "debug", got "trace"`,
+		},
+		{
+			name:    "record and replay",
+			args:    []string{"-server-port", "2112", "-record-dir", os.TempDir(), "-replay-dir", os.TempDir()},
+			wantErr: "invalid configuration: record and replay directories are mutually exclusive",
+		},
+		{
+			name: "invalid digest",
+			args: []string{"-server-port", "2112", "-digest-schedule", "0 8 * *", "-digest-transport", "pigeon",
+				"-digest-support-end-days", "-1"},
+			wantErr: `invalid configuration: invalid cron expression "0 8 * *": expected 5 fields, got 4; ` +
+				"digest sender and recipients should be set; " +
+				`digest transport should be either "smtp" or "ses", got "pigeon"; ` +
+				"digest support end days should not be negative, got -1",
+		},
+		{
+			name: "invalid reports",
+			args: []string{"-server-port", "2112", "-report-formats", "json,xml", "-report-s3-bucket", "reports",
+				"-report-s3-key", "{{.Region}}", "-report-s3-kms-key-id", "alias/reports"},
+			wantErr: `invalid configuration: report format should be one of json, csv, sarif, got "xml"; ` +
+				"invalid report S3 key template; template: report_s3_key:1:2: executing \"report_s3_key\" at <.Region>: " +
+				"can't evaluate field Region in type main.reportKeyData; " +
+				`report S3 KMS key requires the "aws:kms" server-side encryption`,
+		},
+		{
+			name:    "invalid acknowledgements",
+			args:    []string{"-config-file", acknowledgementsFile},
+			wantErr: "invalid configuration: acknowledgement 1: expires, reason should be set",
+		},
+		{
+			name: "invalid sinks",
+			args: []string{"-config-file", sinksFile},
+			wantErr: `invalid configuration: sink 1: the name "cloudwatch" is already used, set another one; ` +
+				"sink 2: the influx sink requires a sink URL",
+		},
+		{
+			name:    "invalid server port",
+			args:    []string{"-server-port", "0"},
+			wantErr: "invalid configuration: server port should be between 1 and 65535, got 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+			o, err := loadOptions(tt.args, lookupEnv)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			want := defaultOptions()
+			tt.want(want)
+			assert.NoError(t, want.validate())
+			assert.Equal(t, want, o)
+		})
+	}
+}
+
+// TestFormatOptions tests the formatOptions function, and that the values of secret options are redacted.
+func TestFormatOptions(t *testing.T) {
+	port, token, empty := intValue(2112), stringValue("s3cr3t"), stringValue("")
+	specs := []optionSpec{
+		{flag: "server-port", value: &port},
+		{flag: "token", secret: true, value: &token},
+		{flag: "empty-token", secret: true, value: &empty},
+	}
+	assert.Equal(t, "  server-port: 2112\n  token: <redacted>\n  empty-token: \n", formatOptions(specs))
+	assert.Contains(t, defaultOptions().String(), "  poll-interval: 5m0s\n")
+}
@@ -0,0 +1,151 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// ownerRule maps RDS resources to their owner. A resource matches the rule if its identifier matches the Identifier
+// regex, if set, and if it has the TagKey tag, if set, with a value matching the TagValue regex, if set. The regexes are
+// anchored at both ends, like the ones of the relabeling rules.
+type ownerRule struct {
+	Identifier   string `yaml:"identifier"`
+	TagKey       string `yaml:"tag_key"`
+	TagValue     string `yaml:"tag_value"`
+	Team         string `yaml:"team"`
+	Owner        string `yaml:"owner"`
+	SlackChannel string `yaml:"slack_channel"`
+
+	// identifier and tagValue are the compiled Identifier and TagValue, set by parseOwnerMapping.
+	identifier *regexp.Regexp
+	tagValue   *regexp.Regexp
+}
+
+// ownerMapping lists the ownerRules of the owner mapping file, in order.
+type ownerMapping []ownerRule
+
+// resourceOwner is the team, owner and Slack channel an RDS resource is mapped to.
+type resourceOwner struct {
+	Team         string
+	Owner        string
+	SlackChannel string
+}
+
+// parseOwnerMapping parses an owner mapping, rejecting the unknown keys, the invalid regexes, the rules matching every
+// resource and the rules mapping to nothing.
+func parseOwnerMapping(data []byte) (ownerMapping, error) {
+	var mapping ownerMapping
+	if err := yaml.UnmarshalStrict(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse owner mapping; %w", err)
+	}
+	for i := range mapping {
+		if err := mapping[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid owner mapping rule %d: %w", i+1, err)
+		}
+	}
+	return mapping, nil
+}
+
+// compile checks the fields of the rule and compiles its regexes.
+func (r *ownerRule) compile() error {
+	if r.Identifier == "" && r.TagKey == "" {
+		return fmt.Errorf("identifier or tag_key should be set")
+	}
+	if r.TagValue != "" && r.TagKey == "" {
+		return fmt.Errorf("tag_value requires tag_key")
+	}
+	if r.Team == "" && r.Owner == "" && r.SlackChannel == "" {
+		return fmt.Errorf("team, owner or slack_channel should be set")
+	}
+	var err error
+	if r.Identifier != "" {
+		if r.identifier, err = regexp.Compile("^(?:" + r.Identifier + ")$"); err != nil {
+			return fmt.Errorf("invalid identifier regex %q; %w", r.Identifier, err)
+		}
+	}
+	if r.TagValue != "" {
+		if r.tagValue, err = regexp.Compile("^(?:" + r.TagValue + ")$"); err != nil {
+			return fmt.Errorf("invalid tag_value regex %q; %w", r.TagValue, err)
+		}
+	}
+	return nil
+}
+
+// loadOwnerMapping reads the owner mapping at path, or returns nil if path is empty.
+func loadOwnerMapping(path string) (ownerMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner mapping; %w", err)
+	}
+	return parseOwnerMapping(b)
+}
+
+// matches reports whether the rule matches an RDS resource.
+func (r ownerRule) matches(rdsInfo RDSInfo) bool {
+	if r.identifier != nil && !r.identifier.MatchString(rdsInfo.ClusterIdentifier) {
+		return false
+	}
+	if r.TagKey != "" {
+		value, ok := rdsInfo.Tags[r.TagKey]
+		if !ok || r.tagValue != nil && !r.tagValue.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolve returns the owner of the first rule matching an RDS resource, if any.
+func (m ownerMapping) resolve(rdsInfo RDSInfo) (resourceOwner, bool) {
+	for _, r := range m {
+		if r.matches(rdsInfo) {
+			return resourceOwner{Team: r.Team, Owner: r.Owner, SlackChannel: r.SlackChannel}, true
+		}
+	}
+	return resourceOwner{}, false
+}
+
+// exportOwner sets the OwnerInfoGauge of an RDS resource to 1 with the owner it is mapped to. Nothing is exported for
+// the resources no rule matches.
+func exportOwner(metrics *Metrics, rdsInfo RDSInfo, mapping ownerMapping) {
+	owner, ok := mapping.resolve(rdsInfo)
+	if !ok {
+		return
+	}
+	metrics.OwnerInfoGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"team":               owner.Team,
+		"owner":              owner.Owner,
+		"slack_channel":      owner.SlackChannel,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(1)
+}
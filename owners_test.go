@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// TestParseOwnerMapping tests that parseOwnerMapping rejects the unknown keys, the invalid regexes and the incomplete
+// rules.
+func TestParseOwnerMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "- identifier: billing-.*\n  team: payments\n- tag_key: service\n  owner: jane\n"},
+		{
+			name:    "unknown key",
+			data:    "- identifier: billing\n  slack: '#payments'\n",
+			wantErr: "failed to parse owner mapping; yaml: unmarshal errors:\n  line 2: field slack not found in type main.ownerRule",
+		},
+		{
+			name:    "no matcher",
+			data:    "- team: payments\n",
+			wantErr: "invalid owner mapping rule 1: identifier or tag_key should be set",
+		},
+		{
+			name:    "tag value without key",
+			data:    "- identifier: billing\n  tag_value: payments\n  team: payments\n",
+			wantErr: "invalid owner mapping rule 1: tag_value requires tag_key",
+		},
+		{
+			name:    "no owner",
+			data:    "- identifier: billing\n",
+			wantErr: "invalid owner mapping rule 1: team, owner or slack_channel should be set",
+		},
+		{
+			name:    "invalid regex",
+			data:    "- identifier: billing\n  team: payments\n- identifier: '(billing'\n  team: payments\n",
+			wantErr: "invalid owner mapping rule 2: invalid identifier regex \"(billing\"; error parsing regexp: missing closing ): `^(?:(billing)$`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseOwnerMapping([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestExportOwner tests that the resources are mapped to the owner of the first rule matching their identifier and
+// tags, and that nothing is exported for the unmatched ones.
+func TestExportOwner(t *testing.T) {
+	mapping, err := parseOwnerMapping([]byte(`
+- identifier: billing-.*
+  team: payments
+  slack_channel: "#payments-oncall"
+- tag_key: service
+  tag_value: cms|blog
+  team: content
+  owner: jane.doe
+- tag_key: team
+  team: unknown
+`))
+	assert.NoError(t, err)
+
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "billing-eu", Region: "eu-west-1", Tags: map[string]string{"service": "cms"}},
+		{ClusterIdentifier: "billing", Region: "eu-west-1"},
+		{ClusterIdentifier: "legacy-cms", Region: "eu-west-1", Tags: map[string]string{"service": "cms"}},
+		{ClusterIdentifier: "users", Region: "eu-west-1", Tags: map[string]string{"service": "users"}},
+		{ClusterIdentifier: "orders", Region: "eu-west-1", Tags: map[string]string{"team": ""}},
+	} {
+		exportOwner(metrics, rdsInfo, mapping)
+	}
+
+	want := `# HELP aws_custom_rds_owner_info Team, owner and Slack channel the resources are mapped to by the owner mapping file
+# TYPE aws_custom_rds_owner_info gauge
+aws_custom_rds_owner_info{account_id="",cluster_identifier="billing-eu",owner="",region="eu-west-1",slack_channel="#payments-oncall",team="payments"} 1
+aws_custom_rds_owner_info{account_id="",cluster_identifier="legacy-cms",owner="jane.doe",region="eu-west-1",slack_channel="",team="content"} 1
+aws_custom_rds_owner_info{account_id="",cluster_identifier="orders",owner="",region="eu-west-1",slack_channel="",team="unknown"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.OwnerInfoGauge, strings.NewReader(want)))
+}
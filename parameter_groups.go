@@ -0,0 +1,60 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// familyAvailable returns true if at least one available version of the catalog of an engine belongs to the given
+// parameter group family.
+func familyAvailable(family string, catalog versionCatalog) bool {
+	for _, info := range catalog {
+		if info.ParameterGroupFamily == family && !deprecatedStatuses[info.Status] {
+			return true
+		}
+	}
+	return false
+}
+
+// exportParameterGroupFamily sets the ParameterGroupFamilyDeprecatedGauge of an RDS resource to 1 if no available
+// version of its engine belongs to the parameter group family of its engine version anymore, and to 0 otherwise. The
+// parameter groups of the resource have to be replaced with ones of another family along its upgrade, which cannot be
+// done in place. Nothing is exported for the versions missing from the catalogs or without a family.
+func exportParameterGroupFamily(metrics *Metrics, rdsInfo RDSInfo, m engineVersions) {
+	catalog := m[rdsInfo.Engine]
+	family := catalog[rdsInfo.EngineVersion].ParameterGroupFamily
+	if family == "" {
+		return
+	}
+	deprecated := 0.0
+	if !familyAvailable(family, catalog) {
+		deprecated = 1
+	}
+	metrics.ParameterGroupFamilyDeprecatedGauge.With(prometheus.Labels{
+		"cluster_identifier":     rdsInfo.ClusterIdentifier,
+		"engine":                 rdsInfo.Engine,
+		"engine_version":         rdsInfo.EngineVersion,
+		"parameter_group_family": family,
+		"account_id":             rdsInfo.Account,
+		"region":                 rdsInfo.Region,
+	}).Set(deprecated)
+}
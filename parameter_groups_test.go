@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportParameterGroupFamily tests that the resources are flagged when no available version belongs to their
+// parameter group family anymore, and only then.
+func TestExportParameterGroupFamily(t *testing.T) {
+	m := engineVersions{"postgres": {
+		"10.21": {Status: "deprecated", ParameterGroupFamily: "postgres10"},
+		"13.7":  {Status: "deprecated", ParameterGroupFamily: "postgres13"},
+		"13.10": {Status: "available", ParameterGroupFamily: "postgres13"},
+		"14.7":  {Status: "available"},
+	}}
+	tests := []struct {
+		name    string
+		rdsInfo RDSInfo
+		family  string
+		want    float64
+	}{
+		{
+			name:    "deprecated family",
+			rdsInfo: RDSInfo{ClusterIdentifier: "db-1", Engine: "postgres", EngineVersion: "10.21", Region: "eu-west-1"},
+			family:  "postgres10",
+			want:    1,
+		},
+		{
+			name:    "deprecated version of an available family",
+			rdsInfo: RDSInfo{ClusterIdentifier: "db-2", Engine: "postgres", EngineVersion: "13.7", Region: "eu-west-1"},
+			family:  "postgres13",
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := NewMetrics()
+			exportParameterGroupFamily(metrics, tt.rdsInfo, m)
+			assert.Equal(t, tt.want, testutil.ToFloat64(metrics.ParameterGroupFamilyDeprecatedGauge.WithLabelValues(
+				tt.rdsInfo.ClusterIdentifier, tt.rdsInfo.Engine, tt.rdsInfo.EngineVersion, tt.family, tt.rdsInfo.Account,
+				tt.rdsInfo.Region)))
+		})
+	}
+
+	// nothing is exported for the versions without a family, or missing from the catalogs
+	metrics := NewMetrics()
+	exportParameterGroupFamily(metrics, RDSInfo{ClusterIdentifier: "db-3", Engine: "postgres", EngineVersion: "14.7"}, m)
+	exportParameterGroupFamily(metrics, RDSInfo{ClusterIdentifier: "db-4", Engine: "mysql", EngineVersion: "8.0.32"}, m)
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.ParameterGroupFamilyDeprecatedGauge))
+}
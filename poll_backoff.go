@@ -0,0 +1,63 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "time"
+
+// pollBackoff backs off the poll interval after consecutive failed snapshots, doubling it after each of them up to the
+// max, and restores it after a successful one, to ease the pressure on the AWS API during its incidents. A partial
+// snapshot, in which some scopes succeeded, is not a failed one: a failing region does not slow down the snapshots of
+// the healthy ones. It is not safe for concurrent use.
+type pollBackoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+}
+
+// newPollBackoff returns the pollBackoff of the poll interval and the poll backoff max of the options. A max of 0, or
+// below the poll interval, disables the backoff.
+func newPollBackoff(options *Options) *pollBackoff {
+	max := options.PollBackoffMax
+	if max < options.PollInterval {
+		max = options.PollInterval
+	}
+	return &pollBackoff{base: options.PollInterval, max: max}
+}
+
+// next records the outcome of a snapshot, and returns the interval until the next one: the poll interval after a
+// successful snapshot, doubled for each consecutive failed one, and capped to the max.
+func (b *pollBackoff) next(err error) time.Duration {
+	if err == nil || partialSnapshot(err) {
+		b.failures = 0
+		return b.base
+	}
+	b.failures++
+	interval := b.base
+	for i := 0; i < b.failures && interval < b.max; i++ {
+		interval *= 2
+	}
+	if interval > b.max {
+		interval = b.max
+	}
+	return interval
+}
@@ -0,0 +1,70 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestPollBackoff tests that the poll interval doubles after each consecutive failed snapshot up to the max, and is
+// restored after a successful one, or a partial one.
+func TestPollBackoff(t *testing.T) {
+	failed := errors.New("Throttling")
+	tests := []struct {
+		name           string
+		pollBackoffMax time.Duration
+		errs           []error
+		want           []time.Duration
+	}{
+		{
+			name:           "backoff",
+			pollBackoffMax: 30 * time.Minute,
+			errs:           []error{failed, failed, failed, failed, nil, failed},
+			want: []time.Duration{10 * time.Minute, 20 * time.Minute, 30 * time.Minute, 30 * time.Minute,
+				5 * time.Minute, 10 * time.Minute},
+		},
+		{
+			name:           "partial snapshots",
+			pollBackoffMax: 30 * time.Minute,
+			errs: []error{&staleScopesError{err: failed, failed: 1, total: 2},
+				&staleScopesError{err: failed, failed: 2, total: 2}, &staleScopesError{err: failed, failed: 1, total: 2}},
+			want: []time.Duration{5 * time.Minute, 10 * time.Minute, 5 * time.Minute},
+		},
+		{
+			name: "disabled",
+			errs: []error{failed, nil},
+			want: []time.Duration{5 * time.Minute, 5 * time.Minute},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newPollBackoff(&Options{PollInterval: 5 * time.Minute, PollBackoffMax: tt.pollBackoffMax})
+			for i, err := range tt.errs {
+				assert.Equal(t, tt.want[i], b.next(err))
+			}
+		})
+	}
+}
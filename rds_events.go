@@ -0,0 +1,327 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The categories of the RDS events acted upon. The other categories, e.g. backup, are only counted.
+const (
+	rdsEventCategoryMaintenance  = "maintenance"
+	rdsEventCategoryFailover     = "failover"
+	rdsEventCategoryNotification = "notification"
+	rdsEventCategoryOther        = "other"
+)
+
+const (
+	// rdsEventsWaitSeconds is the long polling duration of the SQS queue.
+	rdsEventsWaitSeconds = 20
+
+	// rdsEventsRetryDelay is the delay before receiving messages again after a failure.
+	rdsEventsRetryDelay = 30 * time.Second
+)
+
+// rdsEventCategories maps the identifiers of the RDS events acted upon to their category. The messages of RDS event
+// subscriptions do not carry the category of their event, unlike the events that RDS sends to EventBridge.
+var rdsEventCategories = map[string]string{
+	"RDS-EVENT-0026": rdsEventCategoryMaintenance,
+	"RDS-EVENT-0027": rdsEventCategoryMaintenance,
+	"RDS-EVENT-0047": rdsEventCategoryMaintenance,
+	"RDS-EVENT-0013": rdsEventCategoryFailover,
+	"RDS-EVENT-0015": rdsEventCategoryFailover,
+	"RDS-EVENT-0049": rdsEventCategoryFailover,
+	"RDS-EVENT-0050": rdsEventCategoryFailover,
+	"RDS-EVENT-0069": rdsEventCategoryFailover,
+	"RDS-EVENT-0070": rdsEventCategoryFailover,
+	"RDS-EVENT-0071": rdsEventCategoryFailover,
+}
+
+// rdsEvent is an event of an RDS resource, e.g. the announcement of its maintenance.
+type rdsEvent struct {
+	// SourceType is the type of the resource, e.g. "db-instance" or "db-cluster", and SourceIdentifier its identifier.
+	SourceType       string
+	SourceIdentifier string
+
+	// Region is the AWS region of the resource, from its ARN.
+	Region string
+
+	// EventID identifies the kind of event, e.g. "RDS-EVENT-0026", and Category is its category, e.g. "maintenance".
+	EventID  string
+	Category string
+
+	Message string
+	Time    time.Time
+}
+
+// snsNotification is the envelope of the messages that SNS delivers to SQS without raw message delivery.
+type snsNotification struct {
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+}
+
+// rdsSubscriptionEvent is the message of an RDS event subscription.
+type rdsSubscriptionEvent struct {
+	EventSource  string `json:"Event Source"`
+	EventTime    string `json:"Event Time"`
+	SourceID     string `json:"Source ID"`
+	SourceARN    string `json:"Source ARN"`
+	EventID      string `json:"Event ID"`
+	EventMessage string `json:"Event Message"`
+}
+
+// rdsEventBridgeEvent is an event that RDS sends to EventBridge, e.g. "RDS DB Instance Event".
+type rdsEventBridgeEvent struct {
+	Source string `json:"source"`
+	Detail struct {
+		EventCategories  []string `json:"EventCategories"`
+		SourceType       string   `json:"SourceType"`
+		SourceArn        string   `json:"SourceArn"`
+		SourceIdentifier string   `json:"SourceIdentifier"`
+		EventID          string   `json:"EventID"`
+		Message          string   `json:"Message"`
+		Date             string   `json:"Date"`
+	} `json:"detail"`
+}
+
+// parseRDSEvent parses the body of an SQS message holding an RDS event: the message of an RDS event subscription,
+// delivered by SNS with or without its envelope, or an RDS event routed by an EventBridge rule.
+func parseRDSEvent(body string) (rdsEvent, error) {
+	var notification snsNotification
+	if err := json.Unmarshal([]byte(body), &notification); err != nil {
+		return rdsEvent{}, fmt.Errorf("failed to parse RDS event; %w", err)
+	}
+	if notification.Type == "Notification" {
+		body = notification.Message
+	}
+
+	var event rdsEvent
+	var eventBridgeEvent rdsEventBridgeEvent
+	if err := json.Unmarshal([]byte(body), &eventBridgeEvent); err != nil {
+		return rdsEvent{}, fmt.Errorf("failed to parse RDS event; %w", err)
+	}
+	var sourceARN, eventTime string
+	if eventBridgeEvent.Source == "aws.rds" {
+		detail := eventBridgeEvent.Detail
+		event = rdsEvent{
+			SourceType:       normalizeRDSSourceType(detail.SourceType),
+			SourceIdentifier: detail.SourceIdentifier,
+			EventID:          detail.EventID,
+			Category:         rdsEventCategoryOther,
+			Message:          detail.Message,
+		}
+		if len(detail.EventCategories) > 0 {
+			event.Category = detail.EventCategories[0]
+		}
+		sourceARN, eventTime = detail.SourceArn, detail.Date
+	} else {
+		var subscriptionEvent rdsSubscriptionEvent
+		if err := json.Unmarshal([]byte(body), &subscriptionEvent); err != nil {
+			return rdsEvent{}, fmt.Errorf("failed to parse RDS event; %w", err)
+		}
+		// the event identifier is the anchor of a link to the documentation
+		eventID := subscriptionEvent.EventID[strings.LastIndex(subscriptionEvent.EventID, "#")+1:]
+		event = rdsEvent{
+			SourceType:       subscriptionEvent.EventSource,
+			SourceIdentifier: subscriptionEvent.SourceID,
+			EventID:          eventID,
+			Category:         rdsEventCategoryOther,
+			Message:          subscriptionEvent.EventMessage,
+		}
+		if category, ok := rdsEventCategories[eventID]; ok {
+			event.Category = category
+		}
+		sourceARN, eventTime = subscriptionEvent.SourceARN, subscriptionEvent.EventTime
+	}
+	if !strings.HasPrefix(event.EventID, "RDS-EVENT-") {
+		return rdsEvent{}, errors.New("failed to parse RDS event; not an RDS event")
+	}
+
+	if parsed, err := arn.Parse(sourceARN); err == nil {
+		event.Region = parsed.Region
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.000"} {
+		if t, err := time.Parse(layout, eventTime); err == nil {
+			event.Time = t
+			break
+		}
+	}
+	return event, nil
+}
+
+// normalizeRDSSourceType converts the source types of the EventBridge events, e.g. "DB_INSTANCE" or "CLUSTER", to the
+// ones of the RDS event subscriptions, e.g. "db-instance" or "db-cluster".
+func normalizeRDSSourceType(sourceType string) string {
+	sourceType = strings.ToLower(strings.ReplaceAll(sourceType, "_", "-"))
+	if sourceType != "" && !strings.HasPrefix(sourceType, "db-") {
+		sourceType = "db-" + sourceType
+	}
+	return sourceType
+}
+
+// rdsEventListener receives the RDS events from an SQS queue, counts them, records when maintenance is announced, and
+// triggers a snapshot on the events that may change the engine versions, so that they are exported and notified
+// without waiting for the next poll.
+type rdsEventListener struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+	metrics  *Metrics
+	trigger  chan<- struct{}
+	// blackouts are the windows during which the queue is not polled
+	blackouts blackoutWindows
+}
+
+// newRDSEventListener returns the rdsEventListener configured by the options, which triggers snapshots on trigger, or
+// nil if no queue is configured. The SQS client uses the region of the queue URL.
+func newRDSEventListener(options *Options, metrics *Metrics, trigger chan<- struct{}) *rdsEventListener {
+	if options.RDSEventsQueueURL == "" {
+		return nil
+	}
+	config := aws.NewConfig()
+	if region := queueRegion(options.RDSEventsQueueURL); region != "" {
+		config = config.WithRegion(region)
+	}
+	return &rdsEventListener{
+		client:    sqs.New(newSession(options, nil), config),
+		queueURL:  options.RDSEventsQueueURL,
+		metrics:   metrics,
+		trigger:   trigger,
+		blackouts: options.blackouts,
+	}
+}
+
+// queueRegion returns the region of an SQS queue URL, e.g. "https://sqs.eu-west-1.amazonaws.com/123456789012/rds", or
+// an empty string if it cannot be told.
+func queueRegion(queueURL string) string {
+	u, err := url.Parse(queueURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(u.Hostname(), ".")
+	if len(parts) < 3 || parts[0] != "sqs" {
+		return ""
+	}
+	return parts[1]
+}
+
+// run receives the RDS events until the process exits, reporting the failures, except during the blackout windows,
+// when the events are left in the queue. Nothing is done if l is nil.
+func (l *rdsEventListener) run(reporter *errorReporter) {
+	if l == nil {
+		return
+	}
+	for {
+		if l.blackouts.active(time.Now()) {
+			time.Sleep(rdsEventsRetryDelay)
+			continue
+		}
+		if err := l.receive(); err != nil {
+			log.Print(err)
+			reporter.report(err)
+			time.Sleep(rdsEventsRetryDelay)
+		}
+	}
+}
+
+// receive long polls a batch of messages, handles the RDS events they hold, and deletes them, including the ones that
+// cannot be parsed, which would be received again otherwise.
+func (l *rdsEventListener) receive() error {
+	output, err := l.client.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:            Ptr(l.queueURL),
+		MaxNumberOfMessages: Ptr(int64(10)),
+		WaitTimeSeconds:     Ptr(int64(rdsEventsWaitSeconds)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to receive RDS events; %w", err)
+	}
+	if len(output.Messages) == 0 {
+		return nil
+	}
+
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, len(output.Messages))
+	for _, message := range output.Messages {
+		entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            message.MessageId,
+			ReceiptHandle: message.ReceiptHandle,
+		})
+		event, err := parseRDSEvent(aws.StringValue(message.Body))
+		if err != nil {
+			log.Printf("skip: message %s; %s", aws.StringValue(message.MessageId), err)
+			continue
+		}
+		l.handle(event)
+	}
+	deleted, err := l.client.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		QueueUrl: Ptr(l.queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete RDS events; %w", err)
+	}
+	if len(deleted.Failed) > 0 {
+		return fmt.Errorf("failed to delete %d of %d RDS events", len(deleted.Failed), len(entries))
+	}
+	return nil
+}
+
+// handle counts an RDS event, sets the MaintenanceAnnouncedGauge of its resource on maintenance events, and triggers a
+// snapshot on maintenance, failover and notification events. Triggers are coalesced while a snapshot is pending.
+func (l *rdsEventListener) handle(event rdsEvent) {
+	l.metrics.RDSEventsCounter.With(prometheus.Labels{
+		"category":    event.Category,
+		"source_type": event.SourceType,
+		"region":      event.Region,
+	}).Inc()
+	switch event.Category {
+	case rdsEventCategoryMaintenance:
+		eventTime := event.Time
+		if eventTime.IsZero() {
+			eventTime = time.Now()
+		}
+		l.metrics.MaintenanceAnnouncedGauge.With(prometheus.Labels{
+			"source_identifier": event.SourceIdentifier,
+			"source_type":       event.SourceType,
+			"event_id":          event.EventID,
+			"region":            event.Region,
+		}).Set(float64(eventTime.Unix()))
+	case rdsEventCategoryFailover, rdsEventCategoryNotification:
+	default:
+		return
+	}
+	select {
+	case l.trigger <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subscriptionMessage is the message of an RDS event subscription announcing the maintenance of an instance.
+const subscriptionMessage = `{"Event Source":"db-instance","Event Time":"2023-05-02 08:30:00.000",` +
+	`"Identifier Link":"https://console.aws.amazon.com/rds/home?region=eu-west-1#dbinstance:id=legacy-cms",` +
+	`"Source ID":"legacy-cms","Source ARN":"arn:aws:rds:eu-west-1:123456789012:db:legacy-cms",` +
+	`"Event ID":"http://docs.amazonwebservices.com/AmazonRDS/latest/UserGuide/USER_Events.html#RDS-EVENT-0026",` +
+	`"Event Message":"Applying off-line patches to DB instance"}`
+
+// TestParseRDSEvent tests parseRDSEvent with the messages of RDS event subscriptions, with or without their SNS
+// envelope, and with the RDS events routed by EventBridge.
+func TestParseRDSEvent(t *testing.T) {
+	envelope, err := json.Marshal(map[string]string{"Type": "Notification", "Message": subscriptionMessage})
+	assert.NoError(t, err)
+	maintenance := rdsEvent{
+		SourceType:       "db-instance",
+		SourceIdentifier: "legacy-cms",
+		Region:           "eu-west-1",
+		EventID:          "RDS-EVENT-0026",
+		Category:         rdsEventCategoryMaintenance,
+		Message:          "Applying off-line patches to DB instance",
+		Time:             time.Date(2023, 5, 2, 8, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		want    rdsEvent
+		wantErr string
+	}{
+		{name: "raw message delivery", body: subscriptionMessage, want: maintenance},
+		{name: "SNS envelope", body: string(envelope), want: maintenance},
+		{
+			name: "EventBridge",
+			body: `{"version":"0","detail-type":"RDS DB Cluster Event","source":"aws.rds","region":"us-east-1",` +
+				`"detail":{"EventCategories":["failover"],"SourceType":"CLUSTER",` +
+				`"SourceArn":"arn:aws:rds:us-east-1:123456789012:cluster:orders","Date":"2023-05-02T08:30:00.000Z",` +
+				`"Message":"Started cross AZ failover to DB instance: orders-2","SourceIdentifier":"orders",` +
+				`"EventID":"RDS-EVENT-0070"}}`,
+			want: rdsEvent{
+				SourceType:       "db-cluster",
+				SourceIdentifier: "orders",
+				Region:           "us-east-1",
+				EventID:          "RDS-EVENT-0070",
+				Category:         rdsEventCategoryFailover,
+				Message:          "Started cross AZ failover to DB instance: orders-2",
+				Time:             time.Date(2023, 5, 2, 8, 30, 0, 0, time.UTC),
+			},
+		},
+		{name: "not an RDS event", body: `{"source":"aws.ec2"}`, wantErr: "failed to parse RDS event; not an RDS event"},
+		{name: "not JSON", body: "hello", wantErr: "failed to parse RDS event; invalid character 'h' looking for beginning of value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRDSEvent(tt.body)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type MockSQSAPI struct {
+	sqsiface.SQSAPI
+	messages []*sqs.Message
+	deleted  []*sqs.DeleteMessageBatchRequestEntry
+}
+
+func (m *MockSQSAPI) ReceiveMessage(*sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	return &sqs.ReceiveMessageOutput{Messages: m.messages}, nil
+}
+
+func (m *MockSQSAPI) DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	m.deleted = append(m.deleted, input.Entries...)
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+// TestRDSEventListenerReceive tests that receive exports the RDS events, triggers a single snapshot, and deletes every
+// message, including the ones that cannot be parsed.
+func TestRDSEventListenerReceive(t *testing.T) {
+	client := &MockSQSAPI{messages: []*sqs.Message{
+		{MessageId: Ptr("1"), ReceiptHandle: Ptr("r1"), Body: Ptr(subscriptionMessage)},
+		{MessageId: Ptr("2"), ReceiptHandle: Ptr("r2"), Body: Ptr("hello")},
+		{MessageId: Ptr("3"), ReceiptHandle: Ptr("r3"), Body: Ptr(strings.Replace(subscriptionMessage, "0026", "0027", 1))},
+	}}
+	trigger := make(chan struct{}, 1)
+	metrics := NewMetrics()
+	listener := &rdsEventListener{client: client, queueURL: "queue", metrics: metrics, trigger: trigger}
+
+	assert.NoError(t, listener.receive())
+	assert.Len(t, client.deleted, 3)
+	assert.Len(t, trigger, 1)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.RDSEventsCounter.WithLabelValues(
+		rdsEventCategoryMaintenance, "db-instance", "eu-west-1")))
+	assert.Equal(t, float64(time.Date(2023, 5, 2, 8, 30, 0, 0, time.UTC).Unix()),
+		testutil.ToFloat64(metrics.MaintenanceAnnouncedGauge.WithLabelValues(
+			"legacy-cms", "db-instance", "RDS-EVENT-0026", "eu-west-1")))
+}
+
+// TestQueueRegion tests that queueRegion tells the region of SQS queue URLs.
+func TestQueueRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", queueRegion("https://sqs.eu-west-1.amazonaws.com/123456789012/rds-events"))
+	assert.Equal(t, "", queueRegion("http://localhost:9324/queue/rds-events"))
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// readiness tracks whether a snapshot completed successfully, at least partially, see partialSnapshot. Until then, the exported metrics are empty and
+// must not be mistaken for an RDS fleet without any deprecated database.
+type readiness struct {
+	ready atomic.Bool
+}
+
+// setReady marks the exporter as ready.
+func (r *readiness) setReady() {
+	r.ready.Store(true)
+}
+
+// observe marks the exporter as ready if err, the error of a snapshot, is nil or the one of a partial snapshot, and
+// returns whether it is.
+func (r *readiness) observe(err error) bool {
+	if err != nil && !partialSnapshot(err) {
+		return false
+	}
+	r.setReady()
+	return true
+}
+
+// isReady returns true once a snapshot completed successfully, at least partially.
+func (r *readiness) isReady() bool {
+	return r.ready.Load()
+}
+
+// readyzHandler returns an HTTP handler responding 200 once the exporter is ready, and 503 until then.
+func readyzHandler(r *readiness) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !r.isReady() {
+			http.Error(w, "waiting for the first snapshot", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// gateHandler wraps an HTTP handler so that it responds 503 until the exporter is ready.
+func gateHandler(r *readiness, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.isReady() {
+			http.Error(w, "waiting for the first snapshot", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
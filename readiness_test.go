@@ -0,0 +1,74 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestReadiness tests the readyzHandler and gateHandler functions.
+func TestReadiness(t *testing.T) {
+	ready := &readiness{}
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("metrics"))
+	})
+	server := initHttpServer(gateHandler(ready, metrics), defaultMetricsPath, ready, getAddr())
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/metrics").Code)
+
+	ready.setReady()
+
+	assert.Equal(t, http.StatusOK, get("/readyz").Code)
+	rec := get("/metrics")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "metrics", rec.Body.String())
+}
+
+// TestReadinessObserve tests that the exporter is ready once a snapshot succeeded, at least partially.
+func TestReadinessObserve(t *testing.T) {
+	ready := &readiness{}
+	failed := errors.New("failed")
+
+	assert.False(t, ready.observe(failed))
+	assert.False(t, ready.observe(&panicError{value: "boom"}))
+	assert.False(t, ready.observe(&staleScopesError{err: failed, failed: 2, total: 2}))
+	assert.False(t, ready.isReady())
+
+	assert.True(t, ready.observe(&staleScopesError{err: &panicError{value: "boom"}, failed: 1, total: 2}))
+	assert.True(t, ready.isReady())
+
+	ready = &readiness{}
+	assert.True(t, ready.observe(nil))
+	assert.True(t, ready.isReady())
+}
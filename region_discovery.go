@@ -0,0 +1,61 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// allRegions is the value of the regions option scanning every region enabled in each account.
+const allRegions = "all"
+
+// discoverRegions returns the sorted regions enabled in the account of the EC2 client, i.e. the regions that do not
+// require an opt-in and the ones the account opted in to, except the excluded ones.
+func discoverRegions(api ec2iface.EC2API, exclude []string) ([]string, error) {
+	output, err := api.DescribeRegions(&ec2.DescribeRegionsInput{AllRegions: Ptr(false)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe regions; %w", err)
+	}
+	regions := make([]string, 0, len(output.Regions))
+	for _, region := range output.Regions {
+		regions = append(regions, aws.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+	return excludeRegions(regions, exclude), nil
+}
+
+// excludeRegions returns the regions that are not excluded, in order.
+func excludeRegions(regions, exclude []string) []string {
+	kept := make([]string, 0, len(regions))
+	for _, region := range regions {
+		if !contains(exclude, region) {
+			kept = append(kept, region)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockEC2API serves the regions of DescribeRegions, and records its input.
+type MockEC2API struct {
+	ec2iface.EC2API
+	regions []string
+	err     error
+	input   *ec2.DescribeRegionsInput
+}
+
+func (m *MockEC2API) DescribeRegions(input *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error) {
+	m.input = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	output := &ec2.DescribeRegionsOutput{}
+	for _, region := range m.regions {
+		output.Regions = append(output.Regions, &ec2.Region{RegionName: Ptr(region)})
+	}
+	return output, nil
+}
+
+// TestDiscoverRegions tests that discoverRegions lists the enabled regions only, sorted, without the excluded ones.
+func TestDiscoverRegions(t *testing.T) {
+	api := &MockEC2API{regions: []string{"us-east-1", "eu-west-1", "me-south-1", "ap-south-1"}}
+	regions, err := discoverRegions(api, []string{"me-south-1", "eu-central-2"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ap-south-1", "eu-west-1", "us-east-1"}, regions)
+	assert.False(t, *api.input.AllRegions)
+
+	_, err = discoverRegions(&MockEC2API{err: errors.New("UnauthorizedOperation")}, nil)
+	assert.EqualError(t, err, "failed to describe regions; UnauthorizedOperation")
+}
@@ -0,0 +1,206 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The relabeling actions, a subset of the ones of the Prometheus relabel_configs.
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelLabelMap  = "labelmap"
+	relabelLabelDrop = "labeldrop"
+)
+
+// relabelMetricName is the pseudo-label holding the metric name, which relabeling rules can match but not change.
+const relabelMetricName = "__name__"
+
+// relabelConfig is a relabeling rule, with the semantics of the Prometheus relabel_configs: the values of the
+// SourceLabels, joined by the Separator, are matched against the Regex, anchored at both ends, then:
+//   - replace sets the TargetLabel to the Replacement, expanded with the capture groups of the Regex, if it matched;
+//   - keep and drop keep or drop the series depending on whether the Regex matched;
+//   - labelmap renames the labels whose name matches the Regex to the Replacement, expanded the same way;
+//   - labeldrop removes the labels whose name matches the Regex.
+//
+// A label set to an empty value is removed.
+type relabelConfig struct {
+	SourceLabels []string `yaml:"source_labels"`
+	Separator    *string  `yaml:"separator"`
+	Regex        *string  `yaml:"regex"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  *string  `yaml:"replacement"`
+	Action       string   `yaml:"action"`
+
+	// regex is the compiled Regex, set by compile.
+	regex *regexp.Regexp
+}
+
+// compile applies the defaults of the rule, compiles its Regex, and checks that its action is supported and has the
+// fields it requires.
+func (c *relabelConfig) compile() error {
+	if c.Separator == nil {
+		c.Separator = Ptr(";")
+	}
+	if c.Regex == nil {
+		c.Regex = Ptr("(.*)")
+	}
+	if c.Replacement == nil {
+		c.Replacement = Ptr("$1")
+	}
+	if c.Action == "" {
+		c.Action = relabelReplace
+	}
+	regex, err := regexp.Compile("^(?:" + *c.Regex + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid relabeling regex %q; %w", *c.Regex, err)
+	}
+	c.regex = regex
+	switch c.Action {
+	case relabelReplace:
+		if c.TargetLabel == "" || c.TargetLabel == relabelMetricName {
+			return fmt.Errorf("relabeling action %q requires a target label other than %s", c.Action, relabelMetricName)
+		}
+	case relabelKeep, relabelDrop, relabelLabelMap, relabelLabelDrop:
+	default:
+		return fmt.Errorf("relabeling action should be one of %s, got %q", strings.Join([]string{relabelReplace,
+			relabelKeep, relabelDrop, relabelLabelMap, relabelLabelDrop}, ", "), c.Action)
+	}
+	return nil
+}
+
+// relabel applies the compiled rules in order to the labels, in place, and reports whether the series is kept.
+func relabel(labels map[string]string, configs []relabelConfig) bool {
+	for _, c := range configs {
+		values := make([]string, 0, len(c.SourceLabels))
+		for _, name := range c.SourceLabels {
+			values = append(values, labels[name])
+		}
+		value := strings.Join(values, *c.Separator)
+		switch c.Action {
+		case relabelReplace:
+			if match := c.regex.FindStringSubmatchIndex(value); match != nil {
+				labels[c.TargetLabel] = string(c.regex.ExpandString(nil, *c.Replacement, value, match))
+			}
+		case relabelKeep:
+			if !c.regex.MatchString(value) {
+				return false
+			}
+		case relabelDrop:
+			if c.regex.MatchString(value) {
+				return false
+			}
+		case relabelLabelMap:
+			mapped := make(map[string]string)
+			for name, v := range labels {
+				if match := c.regex.FindStringSubmatchIndex(name); match != nil && name != relabelMetricName {
+					mapped[string(c.regex.ExpandString(nil, *c.Replacement, name, match))] = v
+				}
+			}
+			for name, v := range mapped {
+				labels[name] = v
+			}
+		case relabelLabelDrop:
+			for name := range labels {
+				if c.regex.MatchString(name) && name != relabelMetricName {
+					delete(labels, name)
+				}
+			}
+		}
+	}
+	return true
+}
+
+// relabelGatherer gathers the metrics of its Gatherer, and applies the relabeling rules to every series. Dropped
+// series are left out, and the values of the series left with the same labels add up, e.g. the numbers of instances
+// of the identifiers mapped to the same service.
+type relabelGatherer struct {
+	prometheus.Gatherer
+	configs []relabelConfig
+}
+
+// Gather implements prometheus.Gatherer.
+func (g relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	relabeled := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		var metrics []*dto.Metric
+		byKey := make(map[string]*dto.Metric)
+		for _, metric := range family.Metric {
+			labels := map[string]string{relabelMetricName: family.GetName()}
+			for _, pair := range metric.Label {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if !relabel(labels, g.configs) {
+				continue
+			}
+			metric.Label = labelPairs(labels)
+			var key strings.Builder
+			for _, pair := range metric.Label {
+				fmt.Fprintf(&key, "%s=%q,", pair.GetName(), pair.GetValue())
+			}
+			if existing, ok := byKey[key.String()]; ok {
+				addMetricValue(existing, metric)
+				continue
+			}
+			byKey[key.String()] = metric
+			metrics = append(metrics, metric)
+		}
+		if len(metrics) > 0 {
+			family.Metric = metrics
+			relabeled = append(relabeled, family)
+		}
+	}
+	return relabeled, err
+}
+
+// labelPairs returns the non-empty labels as label pairs sorted by name, without the metric name.
+func labelPairs(labels map[string]string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		if name == relabelMetricName || value == "" {
+			continue
+		}
+		pairs = append(pairs, &dto.LabelPair{Name: Ptr(name), Value: Ptr(value)})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	return pairs
+}
+
+// addMetricValue adds the value of the gauge or counter metric to the one of dst.
+func addMetricValue(dst, metric *dto.Metric) {
+	switch {
+	case dst.Gauge != nil && metric.Gauge != nil:
+		dst.Gauge.Value = Ptr(dst.Gauge.GetValue() + metric.Gauge.GetValue())
+	case dst.Counter != nil && metric.Counter != nil:
+		dst.Counter.Value = Ptr(dst.Counter.GetValue() + metric.Counter.GetValue())
+	}
+}
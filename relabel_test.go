@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+// TestRelabelGatherer tests that the relabeling rules rename labels, map identifiers to service names, drop series, and
+// add up the values of the series left with the same labels.
+func TestRelabelGatherer(t *testing.T) {
+	var configs []relabelConfig
+	assert.NoError(t, yaml.UnmarshalStrict([]byte(`
+- source_labels: [__name__, engine]
+  regex: aws_custom_rds_version_deprecated;aurora-.*
+  action: drop
+- source_labels: [cluster_identifier]
+  regex: (billing|orders)-.*
+  target_label: service
+- action: labelmap
+  regex: cluster_(.*)
+- action: labeldrop
+  regex: cluster_identifier|role
+`), &configs))
+	for i := range configs {
+		assert.NoError(t, configs[i].compile())
+	}
+
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_custom_rds_version_deprecated", Help: "help"},
+		[]string{"cluster_identifier", "engine", "role"})
+	gauge.WithLabelValues("billing-1", "postgres", "").Set(1)
+	gauge.WithLabelValues("billing-2", "postgres", "").Set(1)
+	gauge.WithLabelValues("orders-1", "postgres", "writer").Set(0)
+	gauge.WithLabelValues("billing-3", "aurora-postgresql", "").Set(1)
+	r := prometheus.NewRegistry()
+	r.MustRegister(gauge)
+
+	want := `# HELP aws_custom_rds_version_deprecated help
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{engine="postgres",identifier="billing-1",service="billing"} 1
+aws_custom_rds_version_deprecated{engine="postgres",identifier="billing-2",service="billing"} 1
+aws_custom_rds_version_deprecated{engine="postgres",identifier="orders-1",service="orders"} 0
+`
+	gatherer := relabelGatherer{Gatherer: r, configs: configs}
+	assert.NoError(t, testutil.GatherAndCompare(gatherer, strings.NewReader(want)))
+
+	// without the identifiers, the series of the same service add up
+	configs = append(configs, relabelConfig{Action: relabelLabelDrop, Regex: Ptr("identifier")})
+	assert.NoError(t, configs[len(configs)-1].compile())
+	want = `# HELP aws_custom_rds_version_deprecated help
+# TYPE aws_custom_rds_version_deprecated gauge
+aws_custom_rds_version_deprecated{engine="postgres",service="billing"} 2
+aws_custom_rds_version_deprecated{engine="postgres",service="orders"} 0
+`
+	gatherer = relabelGatherer{Gatherer: r, configs: configs}
+	assert.NoError(t, testutil.GatherAndCompare(gatherer, strings.NewReader(want)))
+}
+
+// TestRelabelConfigCompile tests that compile rejects the invalid relabeling rules.
+func TestRelabelConfigCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  relabelConfig
+		wantErr string
+	}{
+		{
+			name:    "invalid regex",
+			config:  relabelConfig{Action: relabelDrop, Regex: Ptr("(")},
+			wantErr: "invalid relabeling regex \"(\"; error parsing regexp: missing closing ): `^(?:()$`",
+		},
+		{
+			name:    "unknown action",
+			config:  relabelConfig{Action: "hashmod"},
+			wantErr: `relabeling action should be one of replace, keep, drop, labelmap, labeldrop, got "hashmod"`,
+		},
+		{
+			name:    "replace without target label",
+			config:  relabelConfig{SourceLabels: []string{"engine"}},
+			wantErr: `relabeling action "replace" requires a target label other than __name__`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.config.compile(), tt.wantErr)
+		})
+	}
+}
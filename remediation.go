@@ -0,0 +1,102 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of the remediations, in the result label of the RemediationsCounter.
+const (
+	remediationResultApplied = "applied"
+	remediationResultDryRun  = "dry_run"
+	remediationResultFailed  = "failed"
+)
+
+// statusAvailable is the status of the instances that can be modified.
+const statusAvailable = "available"
+
+// remediationActionAutoMinorUpgrade is the remediation enabling the automatic minor version upgrades of an instance.
+const remediationActionAutoMinorUpgrade = "enable_auto_minor_version_upgrade"
+
+// remediationPolicy selects the instances whose automatic minor version upgrades are enabled by the exporter: the
+// available instances without them, matching the tag filters. Unless Apply is true, the remediations are only logged.
+type remediationPolicy struct {
+	TagFilters []tagFilter
+	Apply      bool
+}
+
+// newRemediationPolicy returns the remediationPolicy of the options, or nil if the remediation is not enabled. The
+// subcommands, e.g. "report", never remediate.
+func newRemediationPolicy(options *Options) *remediationPolicy {
+	if !options.AutoMinorRemediation || options.command != "" {
+		return nil
+	}
+	return &remediationPolicy{TagFilters: options.remediationTags, Apply: options.RemediationApply}
+}
+
+// selects returns true if the policy selects an RDS resource: an available instance whose automatic minor version
+// upgrades are disabled, matching the tag filters of the policy.
+func (p *remediationPolicy) selects(rdsInfo RDSInfo) bool {
+	return rdsInfo.ResourceType == resourceTypeInstance && rdsInfo.Status == statusAvailable &&
+		!rdsInfo.AutoMinorVersionUpgrade && len(filterRDSInfos([]RDSInfo{rdsInfo}, p.TagFilters)) == 1
+}
+
+// remediateAutoMinorUpgrades enables the automatic minor version upgrades of the given instances with
+// ModifyDBInstance, applied immediately, or only logs it in dry run. Every remediation is logged for audit, with its
+// result, and counted by the RemediationsCounter. A failed remediation does not stop the others, nor fail the snapshot:
+// its error is logged and reported by the Reporter of the config.
+func remediateAutoMinorUpgrades(config *Config, metrics *Metrics, rdsInfos []RDSInfo) {
+	for _, rdsInfo := range rdsInfos {
+		result := remediationResultDryRun
+		if config.Remediation.Apply {
+			result = remediationResultApplied
+			_, err := config.RDS.ModifyDBInstance(&rds.ModifyDBInstanceInput{
+				DBInstanceIdentifier:    aws.String(rdsInfo.ClusterIdentifier),
+				AutoMinorVersionUpgrade: aws.Bool(true),
+				ApplyImmediately:        aws.Bool(true),
+			})
+			if err != nil {
+				result = remediationResultFailed
+				err = fmt.Errorf("failed to enable the automatic minor version upgrades of instance %q in %s; %w",
+					rdsInfo.ClusterIdentifier, describeScope(config), err)
+				log.Print(err)
+				config.Reporter.report(err)
+			}
+		}
+		log.Printf("remediation: action=%s instance=%s engine=%s engine_version=%s account=%s region=%s result=%s",
+			remediationActionAutoMinorUpgrade, rdsInfo.ClusterIdentifier, rdsInfo.Engine, rdsInfo.EngineVersion,
+			rdsInfo.Account, rdsInfo.Region, result)
+		metrics.RemediationsCounter.With(prometheus.Labels{
+			"action":     remediationActionAutoMinorUpgrade,
+			"result":     result,
+			"account_id": rdsInfo.Account,
+			"region":     rdsInfo.Region,
+		}).Inc()
+	}
+}
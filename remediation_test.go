@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// MockModifyRDSAPI records the instances modified by ModifyDBInstance, and fails them with err if it is set.
+type MockModifyRDSAPI struct {
+	MockRDSAPI
+	modified *[]*rds.ModifyDBInstanceInput
+	err      error
+}
+
+func (m MockModifyRDSAPI) ModifyDBInstance(input *rds.ModifyDBInstanceInput) (*rds.ModifyDBInstanceOutput, error) {
+	*m.modified = append(*m.modified, input)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &rds.ModifyDBInstanceOutput{}, nil
+}
+
+// TestRemediationPolicySelects tests that the remediation policy only selects the available instances without
+// automatic minor version upgrades matching its tag filters.
+func TestRemediationPolicySelects(t *testing.T) {
+	policy := &remediationPolicy{TagFilters: []tagFilter{{Key: "env", Values: []string{"dev", "staging"}}}}
+	instance := RDSInfo{ClusterIdentifier: "sessions", ResourceType: resourceTypeInstance, Status: statusAvailable,
+		Tags: map[string]string{"env": "dev"}}
+	tests := []struct {
+		name   string
+		modify func(rdsInfo *RDSInfo)
+		want   bool
+	}{
+		{name: "selected", modify: func(rdsInfo *RDSInfo) {}, want: true},
+		{name: "automatic upgrades", modify: func(rdsInfo *RDSInfo) { rdsInfo.AutoMinorVersionUpgrade = true }},
+		{name: "cluster", modify: func(rdsInfo *RDSInfo) { rdsInfo.ResourceType = resourceTypeCluster }},
+		{name: "stopped", modify: func(rdsInfo *RDSInfo) { rdsInfo.Status = statusStopped }},
+		{name: "tags", modify: func(rdsInfo *RDSInfo) { rdsInfo.Tags = map[string]string{"env": "prod"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdsInfo := instance
+			tt.modify(&rdsInfo)
+			assert.Equal(t, tt.want, policy.selects(rdsInfo))
+		})
+	}
+}
+
+// TestRemediateAutoMinorUpgrades tests that the instances are only modified when the remediations are applied, that
+// every remediation is counted with its result, and that the failed ones are reported.
+func TestRemediateAutoMinorUpgrades(t *testing.T) {
+	rdsInfos := []RDSInfo{
+		{ClusterIdentifier: "sessions", Account: "111111111111", Region: "eu-west-1"},
+		{ClusterIdentifier: "users", Account: "111111111111", Region: "eu-west-1"},
+	}
+	tests := []struct {
+		name         string
+		apply        bool
+		err          error
+		want         string
+		wantReported []string
+	}{
+		{name: "dry run", want: remediationResultDryRun},
+		{name: "apply", apply: true, want: remediationResultApplied},
+		{
+			name:  "failure",
+			apply: true,
+			err:   errors.New("InvalidDBInstanceState"),
+			want:  remediationResultFailed,
+			wantReported: []string{
+				`failed to enable the automatic minor version upgrades of instance "sessions" in region "eu-west-1"; ` +
+					"InvalidDBInstanceState",
+				`failed to enable the automatic minor version upgrades of instance "users" in region "eu-west-1"; ` +
+					"InvalidDBInstanceState",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var event sentryEvent
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+				reported = append(reported, event.Exception[0].Value)
+			}))
+			defer server.Close()
+			dsn, err := parseSentryDSN(fmt.Sprintf("http://abc@%s/42", server.Listener.Addr()))
+			assert.NoError(t, err)
+
+			var modified []*rds.ModifyDBInstanceInput
+			config := &Config{
+				RDS:         MockModifyRDSAPI{modified: &modified, err: tt.err},
+				Region:      "eu-west-1",
+				Remediation: &remediationPolicy{Apply: tt.apply},
+				Reporter:    newErrorReporter(dsn),
+			}
+			metrics := NewMetrics()
+
+			remediateAutoMinorUpgrades(config, metrics, rdsInfos)
+			assert.Equal(t, tt.wantReported, reported)
+			assert.Equal(t, 2.0, testutil.ToFloat64(metrics.RemediationsCounter.WithLabelValues(
+				remediationActionAutoMinorUpgrade, tt.want, "111111111111", "eu-west-1")))
+			if !tt.apply {
+				assert.Empty(t, modified)
+				return
+			}
+			assert.Len(t, modified, 2)
+			assert.Equal(t, "users", aws.StringValue(modified[1].DBInstanceIdentifier))
+			assert.True(t, aws.BoolValue(modified[1].AutoMinorVersionUpgrade))
+			assert.True(t, aws.BoolValue(modified[1].ApplyImmediately))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reportFormatJSON  = "json"
+	reportFormatCSV   = "csv"
+	reportFormatSARIF = "sarif"
+)
+
+// reportFormats are the supported formats of the inventory report.
+var reportFormats = []string{reportFormatJSON, reportFormatCSV, reportFormatSARIF}
+
+// reportRow is an RDS resource of the inventory report.
+type reportRow struct {
+	Account           string            `json:"account"`
+	Region            string            `json:"region"`
+	Identifier        string            `json:"identifier"`
+	Engine            string            `json:"engine"`
+	EngineVersion     string            `json:"engine_version"`
+	Status            string            `json:"status"`
+	Deprecated        bool              `json:"deprecated"`
+	Role              string            `json:"role,omitempty"`
+	MemberOf          string            `json:"member_of,omitempty"`
+	InstanceClass     string            `json:"instance_class,omitempty"`
+	MaintenanceWindow string            `json:"maintenance_window,omitempty"`
+	UpgradeTargets    []string          `json:"upgrade_targets"`
+	Tags              map[string]string `json:"tags,omitempty"`
+}
+
+// reportHeader is the header of the CSV inventory report, in the order of the fields of reportRow.
+var reportHeader = []string{"account", "region", "identifier", "engine", "engine_version", "status", "deprecated",
+	"role", "member_of", "instance_class", "maintenance_window", "upgrade_targets", "tags"}
+
+// newReportRow returns the row of an inventory item.
+func newReportRow(item inventoryItem) reportRow {
+	row := reportRow{
+		Account:           item.Account,
+		Region:            item.Region,
+		Identifier:        item.ClusterIdentifier,
+		Engine:            item.Engine,
+		EngineVersion:     item.EngineVersion,
+		Status:            item.Status,
+		Deprecated:        item.Deprecated,
+		Role:              item.Role,
+		MemberOf:          item.MemberOf,
+		InstanceClass:     item.InstanceClass,
+		MaintenanceWindow: item.MaintenanceWindow,
+		UpgradeTargets:    []string{},
+		Tags:              item.Tags,
+	}
+	for _, target := range item.UpgradeTargets {
+		row.UpgradeTargets = append(row.UpgradeTargets, target.EngineVersion)
+	}
+	return row
+}
+
+// record returns the CSV record of a row. The upgrade targets are space separated, and the tags are "key=value" pairs
+// separated by semicolons, sorted by key.
+func (r reportRow) record() []string {
+	tags := make([]string, 0, len(r.Tags))
+	for key, value := range r.Tags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	return []string{r.Account, r.Region, r.Identifier, r.Engine, r.EngineVersion, r.Status,
+		strconv.FormatBool(r.Deprecated), r.Role, r.MemberOf, r.InstanceClass, r.MaintenanceWindow,
+		strings.Join(r.UpgradeTargets, " "), strings.Join(tags, ";")}
+}
+
+// writeReport writes the inventory report of the given items in the given format: a JSON document holding the
+// generation time and the resources, a CSV table with a header, or a SARIF log of the deprecated resources.
+func writeReport(w io.Writer, format string, items []inventoryItem, now time.Time) error {
+	rows := make([]reportRow, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, newReportRow(item))
+	}
+
+	switch format {
+	case reportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			GeneratedAt time.Time   `json:"generated_at"`
+			Resources   []reportRow `json:"resources"`
+		}{GeneratedAt: now.UTC(), Resources: rows})
+	case reportFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(reportHeader); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := cw.Write(row.record()); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case reportFormatSARIF:
+		return writeSARIF(w, rows)
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// runReport is the "report" subcommand. It takes a single snapshot of the accounts and regions configured by the
+// args, the environment variables and the configuration file, like the exporter, and writes the inventory report to w
+// in the report format. The report of a partial snapshot holds the scopes that succeeded, and its error is returned
+// once it is written.
+func runReport(args []string, w io.Writer) error {
+	options, err := loadCommandOptions("report", args, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if len(options.reportFormats) != 1 {
+		return fmt.Errorf("the report command writes a single report format, got %q", options.ReportFormats)
+	}
+	scopes, err := NewScopes(options)
+	if err != nil {
+		return err
+	}
+	items, start, err := snapshotInventory(scopes)
+	if err != nil && !partialSnapshot(err) {
+		return err
+	}
+	if writeErr := writeReport(w, options.reportFormats[0], items, start); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultReportS3Key is the default template of the S3 keys of the inventory reports.
+const defaultReportS3Key = "{{.Date}}/{{.Account}}/rds-engine-versions-{{.Time}}.{{.Format}}"
+
+// reportContentTypes are the content types of the report formats.
+var reportContentTypes = map[string]string{
+	reportFormatJSON:  "application/json",
+	reportFormatCSV:   "text/csv",
+	reportFormatSARIF: "application/sarif+json",
+}
+
+// reportSSEs are the supported server-side encryptions of the reports uploaded to S3. The empty string leaves the
+// default encryption of the bucket.
+var reportSSEs = []string{"", s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms}
+
+// reportKeyData is the data of the template of the S3 keys of the reports.
+type reportKeyData struct {
+	// Date and Time are the UTC date and time of the upload, e.g. "2023-05-10" and "143700".
+	Date string
+	Time string
+
+	// Account is the ID of the AWS account of the resources of the report, or "default".
+	Account string
+
+	// Format is the format of the report, e.g. "json".
+	Format string
+}
+
+// parseReportS3Key parses the template of the S3 keys of the reports, and checks that it can be executed.
+func parseReportS3Key(s string) (*template.Template, error) {
+	key, err := template.New("report_s3_key").Option("missingkey=error").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report S3 key template; %w", err)
+	}
+	if err := key.Execute(&bytes.Buffer{}, reportKeyData{}); err != nil {
+		return nil, fmt.Errorf("invalid report S3 key template; %w", err)
+	}
+	return key, nil
+}
+
+// reportUploader uploads the inventory report of each account to S3 in every configured format, after each successful
+// snapshot or on a cron schedule, e.g. to feed a data lake.
+type reportUploader struct {
+	client   s3iface.S3API
+	bucket   string
+	key      *template.Template
+	formats  []string
+	sse      string
+	kmsKeyID string
+
+	// schedule is nil when the reports are uploaded after each successful snapshot.
+	schedule *cronSchedule
+	next     time.Time
+}
+
+// newReportUploader returns the reportUploader configured by the options, whose first scheduled upload is after now,
+// or nil if no S3 bucket is configured.
+func newReportUploader(options *Options, now time.Time) *reportUploader {
+	if options.ReportS3Bucket == "" {
+		return nil
+	}
+	config := aws.NewConfig()
+	if options.ReportS3Region != "" {
+		config = config.WithRegion(options.ReportS3Region)
+	}
+	u := &reportUploader{
+		client:   s3.New(newSession(options, nil), config),
+		bucket:   options.ReportS3Bucket,
+		key:      options.reportS3Key,
+		formats:  options.reportFormats,
+		sse:      options.ReportS3SSE,
+		kmsKeyID: options.ReportS3KMSKeyID,
+		schedule: options.reportSchedule,
+	}
+	if u.schedule != nil {
+		u.next = u.schedule.next(now)
+	}
+	return u
+}
+
+// maybeUpload uploads the reports of the inventory, unless a schedule is configured and its time has not passed yet.
+// It is called after each successful snapshot, so that the reports always cover a complete inventory. Nothing is done
+// if u is nil.
+func (u *reportUploader) maybeUpload(now time.Time, inv *inventory) error {
+	if u == nil {
+		return nil
+	}
+	if u.schedule != nil {
+		if u.next.IsZero() || now.Before(u.next) {
+			return nil
+		}
+		u.next = u.schedule.next(now)
+	}
+
+	// the items are sorted by account
+	items := inv.list()
+	for start := 0; start < len(items); {
+		end := start
+		for end < len(items) && items[end].Account == items[start].Account {
+			end++
+		}
+		if err := u.upload(items[start:end], items[start].Account, now); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// upload uploads the reports of the inventory items of an account in every configured format.
+func (u *reportUploader) upload(items []inventoryItem, account string, now time.Time) error {
+	now = now.UTC()
+	for _, format := range u.formats {
+		var key strings.Builder
+		err := u.key.Execute(&key, reportKeyData{
+			Date:    now.Format("2006-01-02"),
+			Time:    now.Format("150405"),
+			Account: account,
+			Format:  format,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to build the report S3 key; %w", err)
+		}
+
+		var body bytes.Buffer
+		if err := writeReport(&body, format, items, now); err != nil {
+			return fmt.Errorf("failed to write the %s report; %w", format, err)
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:      Ptr(u.bucket),
+			Key:         Ptr(key.String()),
+			Body:        bytes.NewReader(body.Bytes()),
+			ContentType: Ptr(reportContentTypes[format]),
+		}
+		if u.sse != "" {
+			input.ServerSideEncryption = Ptr(u.sse)
+		}
+		if u.kmsKeyID != "" {
+			input.SSEKMSKeyId = Ptr(u.kmsKeyID)
+		}
+		if _, err := u.client.PutObject(input); err != nil {
+			return fmt.Errorf("failed to upload the report s3://%s/%s; %w", u.bucket, key.String(), err)
+		}
+	}
+	return nil
+}
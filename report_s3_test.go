@@ -0,0 +1,149 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type MockS3API struct {
+	s3iface.S3API
+	inputs []*s3.PutObjectInput
+	err    error
+}
+
+func (m *MockS3API) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &s3.PutObjectOutput{}, m.err
+}
+
+// TestReportUploaderMaybeUpload tests the maybeUpload method of reportUploader.
+func TestReportUploaderMaybeUpload(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	key, err := parseReportS3Key("reports/dt={{.Date}}/account={{.Account}}/{{.Time}}.{{.Format}}")
+	assert.NoError(t, err)
+	schedule, err := parseCronSchedule("0 6 * * *")
+	assert.NoError(t, err)
+
+	inv := &inventory{}
+	for _, item := range []inventoryItem{
+		{RDSInfo: RDSInfo{ClusterIdentifier: "orders", Account: "222222222222"}},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "billing", Account: "111111111111"}},
+		{RDSInfo: RDSInfo{ClusterIdentifier: "catalog", Account: "111111111111"}},
+	} {
+		inv.add(item)
+	}
+
+	type object struct {
+		key, contentType, sse, kmsKeyID string
+	}
+	tests := []struct {
+		name     string
+		uploader *reportUploader
+		at       time.Time
+		want     []object
+		wantErr  string
+	}{
+		{
+			name:     "after each snapshot",
+			uploader: &reportUploader{formats: []string{reportFormatJSON, reportFormatCSV}},
+			at:       now,
+			want: []object{
+				{key: "reports/dt=2023-05-10/account=111111111111/143700.json", contentType: "application/json"},
+				{key: "reports/dt=2023-05-10/account=111111111111/143700.csv", contentType: "text/csv"},
+				{key: "reports/dt=2023-05-10/account=222222222222/143700.json", contentType: "application/json"},
+				{key: "reports/dt=2023-05-10/account=222222222222/143700.csv", contentType: "text/csv"},
+			},
+		},
+		{
+			name: "encrypted",
+			uploader: &reportUploader{formats: []string{reportFormatCSV}, sse: s3.ServerSideEncryptionAwsKms,
+				kmsKeyID: "alias/reports"},
+			at: now,
+			want: []object{
+				{key: "reports/dt=2023-05-10/account=111111111111/143700.csv", contentType: "text/csv",
+					sse: "aws:kms", kmsKeyID: "alias/reports"},
+				{key: "reports/dt=2023-05-10/account=222222222222/143700.csv", contentType: "text/csv",
+					sse: "aws:kms", kmsKeyID: "alias/reports"},
+			},
+		},
+		{
+			name: "before the schedule",
+			uploader: &reportUploader{formats: []string{reportFormatJSON}, schedule: schedule,
+				next: schedule.next(now)},
+			at: now.Add(time.Hour),
+		},
+		{
+			name: "after the schedule",
+			uploader: &reportUploader{formats: []string{reportFormatJSON}, schedule: schedule,
+				next: schedule.next(now)},
+			at: now.Add(16 * time.Hour),
+			want: []object{
+				{key: "reports/dt=2023-05-11/account=111111111111/063700.json", contentType: "application/json"},
+				{key: "reports/dt=2023-05-11/account=222222222222/063700.json", contentType: "application/json"},
+			},
+		},
+		{
+			name:     "failure",
+			uploader: &reportUploader{formats: []string{reportFormatJSON}, client: &MockS3API{err: errors.New("AccessDenied")}},
+			at:       now,
+			want: []object{
+				{key: "reports/dt=2023-05-10/account=111111111111/143700.json", contentType: "application/json"},
+			},
+			wantErr: "failed to upload the report " +
+				"s3://reports/reports/dt=2023-05-10/account=111111111111/143700.json; AccessDenied",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, ok := tt.uploader.client.(*MockS3API)
+			if !ok {
+				client = &MockS3API{}
+			}
+			tt.uploader.client, tt.uploader.bucket, tt.uploader.key = client, "reports", key
+
+			err := tt.uploader.maybeUpload(tt.at, inv)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			var got []object
+			for _, input := range client.inputs {
+				assert.Equal(t, "reports", *input.Bucket)
+				got = append(got, object{key: *input.Key, contentType: *input.ContentType,
+					sse: aws.StringValue(input.ServerSideEncryption), kmsKeyID: aws.StringValue(input.SSEKMSKeyId)})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// nil uploaders upload nothing
+	assert.NoError(t, (*reportUploader)(nil).maybeUpload(now, inv))
+}
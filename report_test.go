@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteReport tests the writeReport function in every format.
+func TestWriteReport(t *testing.T) {
+	now := time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+	items := []inventoryItem{
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4", Region: "eu-west-1",
+				Account: "111111111111", InstanceClass: "db.r6g.large", MaintenanceWindow: "sun:05:00-sun:06:00",
+				Tags: map[string]string{"team": "checkout", "env": "prod"}},
+			Status:         "deprecated",
+			Deprecated:     true,
+			UpgradeTargets: []upgradeTarget{{EngineVersion: "11.22"}, {EngineVersion: "15.2", Major: true}},
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "orders-1", Engine: "aurora-mysql", EngineVersion: "8.0.mysql_aurora.3.04.0",
+				Region: "eu-west-1", Account: "111111111111", MemberOf: "orders-cluster", Role: "writer"},
+			Status: "available",
+		},
+	}
+	tests := []struct {
+		format  string
+		want    string
+		wantErr string
+	}{
+		{
+			format: reportFormatJSON,
+			want: `{
+  "generated_at": "2023-05-10T14:37:00Z",
+  "resources": [
+    {
+      "account": "111111111111",
+      "region": "eu-west-1",
+      "identifier": "orders",
+      "engine": "postgres",
+      "engine_version": "11.4",
+      "status": "deprecated",
+      "deprecated": true,
+      "instance_class": "db.r6g.large",
+      "maintenance_window": "sun:05:00-sun:06:00",
+      "upgrade_targets": [
+        "11.22",
+        "15.2"
+      ],
+      "tags": {
+        "env": "prod",
+        "team": "checkout"
+      }
+    },
+    {
+      "account": "111111111111",
+      "region": "eu-west-1",
+      "identifier": "orders-1",
+      "engine": "aurora-mysql",
+      "engine_version": "8.0.mysql_aurora.3.04.0",
+      "status": "available",
+      "deprecated": false,
+      "role": "writer",
+      "member_of": "orders-cluster",
+      "upgrade_targets": []
+    }
+  ]
+}
+`,
+		},
+		{
+			format: reportFormatCSV,
+			want: "account,region,identifier,engine,engine_version,status,deprecated,role,member_of,instance_class," +
+				"maintenance_window,upgrade_targets,tags\n" +
+				"111111111111,eu-west-1,orders,postgres,11.4,deprecated,true,,,db.r6g.large,sun:05:00-sun:06:00," +
+				"11.22 15.2,env=prod;team=checkout\n" +
+				"111111111111,eu-west-1,orders-1,aurora-mysql,8.0.mysql_aurora.3.04.0,available,false,writer," +
+				"orders-cluster,,,,\n",
+		},
+		{format: "xml", wantErr: `unknown report format "xml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var b bytes.Buffer
+			err := writeReport(&b, tt.format, items, now)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+// TestRunReport tests the report subcommand, in demo mode.
+func TestRunReport(t *testing.T) {
+	// set by TestMain
+	t.Setenv(AwsApiIntervalEnvName, "")
+
+	var b bytes.Buffer
+	assert.NoError(t, runReport([]string{"-demo", "-regions", "eu-west-1", "-report-formats", "csv"}, &b))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, strings.Join(reportHeader, ","), lines[0])
+	assert.Contains(t, lines, "default,eu-west-1,billing,aurora-postgresql,11.9,deprecated,true,,,,sun:05:00-sun:06:00,,env=prod")
+
+	err := runReport([]string{"-demo", "-report-formats", "json,csv"}, &b)
+	assert.EqualError(t, err, `the report command writes a single report format, got "json,csv"`)
+}
@@ -0,0 +1,167 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reservedStateActive is the state of the reserved DB instances that are billed and cover instances.
+const reservedStateActive = "active"
+
+// ReservedInstanceInfo represents an active reserved DB instance.
+type ReservedInstanceInfo struct {
+	// ID is the ID of the reservation.
+	ID string
+
+	// InstanceClass and Engine are the class and engine of the instances the reservation covers. Engine is the engine
+	// of its product description, e.g. "postgres" for "postgresql" or "oracle-ee" for "oracle-ee(byol)".
+	InstanceClass string
+	Engine        string
+
+	// Count is the number of instances the reservation covers.
+	Count int64
+
+	// End is the time the reservation expires.
+	End time.Time
+}
+
+// reservedEngine returns the engine of the product description of a reserved DB instance, without its license model,
+// e.g. "oracle-se2" for "oracle-se2(li)", and with the name of the engine in the engine catalogs.
+func reservedEngine(productDescription string) string {
+	engine, _, _ := strings.Cut(productDescription, "(")
+	if engine == "postgresql" {
+		return "postgres"
+	}
+	return engine
+}
+
+// getReservedInstances lists the active reserved DB instances of the account and region of the config with
+// DescribeReservedDBInstances.
+// An error is returned if the function fails to retrieve the reserved DB instances.
+func getReservedInstances(config *Config) ([]ReservedInstanceInfo, error) {
+	reserved := make([]ReservedInstanceInfo, 0)
+	var nextMarker *string
+	condition := true
+	for condition {
+		output, err := config.RDS.DescribeReservedDBInstances(&rds.DescribeReservedDBInstancesInput{
+			Marker: nextMarker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe reserved DB instances; %w", err)
+		}
+		if output == nil {
+			break
+		}
+		for _, r := range output.ReservedDBInstances {
+			if aws.StringValue(r.State) != reservedStateActive {
+				continue
+			}
+			reserved = append(reserved, ReservedInstanceInfo{
+				ID:            aws.StringValue(r.ReservedDBInstanceId),
+				InstanceClass: aws.StringValue(r.DBInstanceClass),
+				Engine:        reservedEngine(aws.StringValue(r.ProductDescription)),
+				Count:         aws.Int64Value(r.DBInstanceCount),
+				End:           aws.TimeValue(r.StartTime).Add(time.Duration(aws.Int64Value(r.Duration)) * time.Second),
+			})
+		}
+		nextMarker = output.Marker
+		condition = nextMarker != nil
+	}
+	return reserved, nil
+}
+
+// reservations tracks the instances left to cover by each active reserved DB instance during a snapshot.
+type reservations struct {
+	reserved []ReservedInstanceInfo
+	left     []int64
+}
+
+// newReservations returns the reservations of the given reserved DB instances, none of which covers an instance yet.
+func newReservations(reserved []ReservedInstanceInfo) *reservations {
+	left := make([]int64, len(reserved))
+	for i, r := range reserved {
+		left[i] = r.Count
+	}
+	return &reservations{reserved: reserved, left: left}
+}
+
+// cover returns the first reserved DB instance of the class and engine of an RDS instance with instances left to cover,
+// and counts the instance as covered by it. It returns false if the instance is not covered by any of them.
+func (r *reservations) cover(rdsInfo RDSInfo) (ReservedInstanceInfo, bool) {
+	for i, reserved := range r.reserved {
+		if r.left[i] > 0 && reserved.InstanceClass == rdsInfo.InstanceClass && reserved.Engine == rdsInfo.Engine {
+			r.left[i]--
+			return reserved, true
+		}
+	}
+	return ReservedInstanceInfo{}, false
+}
+
+// exportReservedInstances sets the ReservedInstanceEndGauge of every active reserved DB instance of an account and
+// region to the time it expires.
+func exportReservedInstances(metrics *Metrics, account, region string, reserved []ReservedInstanceInfo) {
+	for _, r := range reserved {
+		metrics.ReservedInstanceEndGauge.With(prometheus.Labels{
+			"reserved_instance_id": r.ID,
+			"instance_class":       r.InstanceClass,
+			"engine":               r.Engine,
+			"instance_count":       strconv.FormatInt(r.Count, 10),
+			"account_id":           account,
+			"region":               region,
+		}).Set(float64(r.End.Unix()))
+	}
+}
+
+// exportReservedCoverage sets the ReservedInstanceDeprecatedGauge to 1 for an RDS instance whose engine version is
+// deprecated and that is covered by one of the reservations: its upgrade may need a change of class or engine that the
+// reservation no longer covers. The reservations are assumed to cover the instances on deprecated versions first.
+// Nothing is exported for the clusters and the instances on available versions.
+func exportReservedCoverage(metrics *Metrics, rdsInfo RDSInfo, m engineVersions, r *reservations) {
+	if r == nil || rdsInfo.InstanceClass == "" {
+		return
+	}
+	if valid, err := validateEngineVersion(rdsInfo, m); err != nil || valid {
+		return
+	}
+	reserved, ok := r.cover(rdsInfo)
+	if !ok {
+		return
+	}
+	metrics.ReservedInstanceDeprecatedGauge.With(prometheus.Labels{
+		"cluster_identifier":   rdsInfo.ClusterIdentifier,
+		"engine":               rdsInfo.Engine,
+		"engine_version":       rdsInfo.EngineVersion,
+		"instance_class":       rdsInfo.InstanceClass,
+		"reserved_instance_id": reserved.ID,
+		"account_id":           rdsInfo.Account,
+		"region":               rdsInfo.Region,
+	}).Set(1)
+}
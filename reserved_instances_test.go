@@ -0,0 +1,83 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGetReservedInstances tests that only the active reserved DB instances are listed, with the engine of their
+// product description and the time they expire.
+func TestGetReservedInstances(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	config := &Config{RDS: &MockRDSAPI{reservedOutput: []*rds.DescribeReservedDBInstancesOutput{{
+		ReservedDBInstances: []*rds.ReservedDBInstance{
+			{ReservedDBInstanceId: Ptr("ri-users"), DBInstanceClass: Ptr("db.r5.large"),
+				ProductDescription: Ptr("postgresql"), DBInstanceCount: Ptr(int64(2)), State: Ptr("active"),
+				StartTime: &start, Duration: Ptr(int64(31536000))},
+			{ReservedDBInstanceId: Ptr("ri-erp"), DBInstanceClass: Ptr("db.r5.xlarge"),
+				ProductDescription: Ptr("oracle-ee(byol)"), DBInstanceCount: Ptr(int64(1)), State: Ptr("active"),
+				StartTime: &start, Duration: Ptr(int64(94608000))},
+			{ReservedDBInstanceId: Ptr("ri-expired"), DBInstanceClass: Ptr("db.m4.large"),
+				ProductDescription: Ptr("mysql"), DBInstanceCount: Ptr(int64(1)), State: Ptr("retired")},
+		},
+	}}}}
+
+	got, err := getReservedInstances(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []ReservedInstanceInfo{
+		{ID: "ri-users", InstanceClass: "db.r5.large", Engine: "postgres", Count: 2, End: start.AddDate(1, 0, 0)},
+		{ID: "ri-erp", InstanceClass: "db.r5.xlarge", Engine: "oracle-ee", Count: 1, End: start.AddDate(3, 0, 0)},
+	}, got)
+}
+
+// TestExportReservedCoverage tests that the instances on deprecated versions are flagged while a reserved DB instance
+// of their class and engine has instances left to cover.
+func TestExportReservedCoverage(t *testing.T) {
+	m := engineVersions{"postgres": {
+		"11.19": {Status: "deprecated"},
+		"15.4":  {Status: "available"},
+	}}
+	r := newReservations([]ReservedInstanceInfo{{ID: "ri-users", InstanceClass: "db.r5.large", Engine: "postgres",
+		Count: 1}})
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "15.4", InstanceClass: "db.r5.large"},
+		{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "11.19", InstanceClass: "db.r5.large"},
+		// the reservation has no instance left to cover
+		{ClusterIdentifier: "users-2", Engine: "postgres", EngineVersion: "11.19", InstanceClass: "db.r5.large"},
+		{ClusterIdentifier: "users-3", Engine: "postgres", EngineVersion: "11.19", InstanceClass: "db.r5.xlarge"},
+	} {
+		rdsInfo.Region = "eu-west-1"
+		exportReservedCoverage(metrics, rdsInfo, m, r)
+	}
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.ReservedInstanceDeprecatedGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ReservedInstanceDeprecatedGauge.WithLabelValues(
+		"users", "postgres", "11.19", "db.r5.large", "ri-users", "", "eu-west-1")))
+}
@@ -0,0 +1,137 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifRuleDeprecated is the rule of the findings of the resources running a deprecated engine version.
+	sarifRuleDeprecated = "rds-deprecated-engine-version"
+
+	// exporterURI is the home page of the exporter, referenced by the SARIF reports.
+	exporterURI = "https://github.com/alexandremahdhaoui/prometheus-exporter-aws-rds-engine-version"
+)
+
+// sarifLog, sarifRun, sarifTool, sarifDriver, sarifRule, sarifMessage, sarifResult and sarifLocation are the parts of
+// the SARIF 2.1.0 format used by the SARIF report.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string             `json:"id"`
+		ShortDescription     sarifMessage       `json:"shortDescription"`
+		FullDescription      sarifMessage       `json:"fullDescription"`
+		DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	}
+	sarifConfiguration struct {
+		Level string `json:"level"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID              string            `json:"ruleId"`
+		Level               string            `json:"level"`
+		Message             sarifMessage      `json:"message"`
+		Locations           []sarifLocation   `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints"`
+		Properties          reportRow         `json:"properties"`
+	}
+	sarifLocation struct {
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+	}
+	sarifLogicalLocation struct {
+		Name               string `json:"name"`
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+)
+
+// writeSARIF writes the SARIF report of the given rows of the inventory report: each resource running a deprecated engine version is
+// a finding of the "rds-deprecated-engine-version" rule, located by its account, region and identifier, with the row of
+// the inventory report as properties.
+func writeSARIF(w io.Writer, rows []reportRow) error {
+	results := make([]sarifResult, 0)
+	for _, row := range rows {
+		if !row.Deprecated {
+			continue
+		}
+		name := fmt.Sprintf("%s/%s/%s", row.Account, row.Region, row.Identifier)
+		results = append(results, sarifResult{
+			RuleID: sarifRuleDeprecated,
+			Level:  "error",
+			Message: sarifMessage{Text: fmt.Sprintf("%s runs the %s engine version %s, whose status is %s.",
+				row.Identifier, row.Engine, row.EngineVersion, row.Status)},
+			Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{
+				{Name: row.Identifier, FullyQualifiedName: name, Kind: "resource"},
+			}}},
+			PartialFingerprints: map[string]string{"resource/v1": name},
+			Properties:          row,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           exporterName,
+				Version:        version,
+				InformationURI: exporterURI,
+				Rules: []sarifRule{{
+					ID:               sarifRuleDeprecated,
+					ShortDescription: sarifMessage{Text: "RDS engine version is deprecated"},
+					FullDescription: sarifMessage{Text: "The engine version of the RDS resource can no longer be used to " +
+						"create new databases, and AWS will eventually upgrade it automatically."},
+					DefaultConfiguration: sarifConfiguration{Level: "error"},
+				}},
+			}},
+			Results: results,
+		}},
+	})
+}
@@ -0,0 +1,71 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestWriteSARIF tests the writeSARIF function.
+func TestWriteSARIF(t *testing.T) {
+	rows := []reportRow{
+		{Account: "111111111111", Region: "eu-west-1", Identifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+			Status: "deprecated", Deprecated: true, UpgradeTargets: []string{"11.22"}},
+		{Account: "111111111111", Region: "eu-west-1", Identifier: "billing", Engine: "postgres", EngineVersion: "15.2",
+			Status: "available", UpgradeTargets: []string{}},
+	}
+	var b bytes.Buffer
+	assert.NoError(t, writeSARIF(&b, rows))
+	assert.JSONEq(t, `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {
+      "name": "prometheus-exporter-aws-rds-engine-version",
+      "version": "dev",
+      "informationUri": "https://github.com/alexandremahdhaoui/prometheus-exporter-aws-rds-engine-version",
+      "rules": [{
+        "id": "rds-deprecated-engine-version",
+        "shortDescription": {"text": "RDS engine version is deprecated"},
+        "fullDescription": {"text": "The engine version of the RDS resource can no longer be used to create new databases, and AWS will eventually upgrade it automatically."},
+        "defaultConfiguration": {"level": "error"}
+      }]
+    }},
+    "results": [{
+      "ruleId": "rds-deprecated-engine-version",
+      "level": "error",
+      "message": {"text": "orders runs the postgres engine version 11.4, whose status is deprecated."},
+      "locations": [{"logicalLocations": [
+        {"name": "orders", "fullyQualifiedName": "111111111111/eu-west-1/orders", "kind": "resource"}
+      ]}],
+      "partialFingerprints": {"resource/v1": "111111111111/eu-west-1/orders"},
+      "properties": {
+        "account": "111111111111", "region": "eu-west-1", "identifier": "orders", "engine": "postgres",
+        "engine_version": "11.4", "status": "deprecated", "deprecated": true, "upgrade_targets": ["11.22"]
+      }
+    }]
+  }]
+}`, b.String())
+}
@@ -0,0 +1,279 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AccountScope holds the Configs of the regions scanned in one AWS account.
+type AccountScope struct {
+	// RoleARN is the IAM role assumed to scan the account. It is empty for the account of the default credentials.
+	RoleARN string
+
+	// ID is the ID of the account, and Alias its alias, if it has one, resolved once when the Scopes are created.
+	ID    string
+	Alias string
+
+	// Regions holds one Config per scanned region of the account.
+	Regions []*Config
+}
+
+// Scopes holds every AWS account and region whose RDS resources are exported, and bounds how many of them are
+// snapshotted concurrently: at most MaxAccountsInFlight accounts at once, and at most MaxRegionsPerAccount regions at
+// once within each of them. Raising them shortens the snapshots, at the expense of a higher risk of API throttling.
+type Scopes struct {
+	Accounts             []AccountScope
+	MaxAccountsInFlight  int
+	MaxRegionsPerAccount int
+
+	// Reporter reports snapshot failures and panics to Sentry. It is nil unless a Sentry DSN is configured.
+	Reporter *errorReporter
+
+	// Tracer exports the spans of the snapshots to an OpenTelemetry collector. It is nil unless an OTel endpoint is
+	// configured.
+	Tracer *tracer
+
+	// Policy is the version policy shared by every Config, refreshed before each snapshot. It is nil unless a policy URL
+	// is configured.
+	Policy *policySource
+}
+
+// NewScopes creates the Configs of every AWS account and region to scan: the account of each role to assume, or the
+// account of the default credentials if there is none, in each region, or in the default region if there is none.
+// When scanning all regions, the regions enabled in each account, but the excluded ones, are discovered with the
+// credentials of the account, and an error is returned if they cannot be. The ID and the alias of each account are
+// resolved with its credentials too; the account is scanned without them if they cannot be.
+// Every Config shares the same session, hence the same AWS API rate limit. In demo mode, the Configs serve synthetic RDS
+// resources instead. In dry run, nothing is resolved, so that AWS is never called: when scanning all regions, each
+// account gets a single Config without a region, standing for its enabled regions.
+func NewScopes(options *Options) (*Scopes, error) {
+	if options.Demo {
+		return newDemoScopes(options), nil
+	}
+	scopes := &Scopes{
+		MaxAccountsInFlight:  options.MaxAccountsInFlight,
+		MaxRegionsPerAccount: options.MaxRegionsPerAccount,
+		Reporter:             newErrorReporter(options.sentryDSN),
+		Tracer:               newTracer(options.otelEndpoint, options.otelHeaders),
+		Policy:               newPolicySource(options),
+	}
+	sess := newSession(options, scopes.Reporter)
+
+	roleARNs, regions := options.roleARNs, options.regions
+	if len(roleARNs) == 0 {
+		roleARNs = []string{""}
+	}
+	if len(regions) == 0 {
+		regions = []string{""}
+	}
+	for _, roleARN := range roleARNs {
+		account := AccountScope{RoleARN: roleARN}
+		accountRegions := regions
+		if options.allRegions && options.DryRun {
+			accountRegions = []string{""}
+		} else if options.allRegions {
+			var err error
+			accountRegions, err = discoverRegions(ec2.New(scopedSession(options, sess, "", roleARN)),
+				options.excludeRegions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to discover the regions of %s; %w", describeAccount(roleARN), err)
+			}
+		}
+		// IAM and STS are global, but their clients need a region of the partition of the account
+		accountRegion := ""
+		if len(accountRegions) > 0 {
+			accountRegion = accountRegions[0]
+		}
+		if !options.DryRun {
+			accountSession := scopedSession(options, sess, accountRegion, roleARN)
+			id, err := callerAccount(sts.New(accountSession))
+			if err != nil {
+				log.Printf("failed to resolve the ID of %s; %v", describeAccount(roleARN), err)
+			}
+			alias, err := accountAlias(iam.New(accountSession))
+			if err != nil {
+				log.Printf("failed to resolve the alias of %s; %v", describeAccount(roleARN), err)
+			}
+			account.ID, account.Alias = id, alias
+		}
+		for _, region := range accountRegions {
+			config := NewConfig(options, sess, region, roleARN)
+			if options.allRegions && region == "" {
+				// in dry run, the Config stands for every enabled region, not for the default one
+				config.Region = ""
+			}
+			config.AccountID, config.AccountAlias = account.ID, account.Alias
+			config.Policy, config.Reporter = scopes.Policy, scopes.Reporter
+			account.Regions = append(account.Regions, config)
+		}
+		scopes.Accounts = append(scopes.Accounts, account)
+	}
+	return scopes, nil
+}
+
+// describeAccount describes the account of a role, or of the default credentials if roleARN is empty, in error
+// messages.
+func describeAccount(roleARN string) string {
+	if roleARN == "" {
+		return "the account of the default credentials"
+	}
+	return "the account of role " + roleARN
+}
+
+// configs returns the Configs of every account and region.
+func (s *Scopes) configs() []*Config {
+	var configs []*Config
+	for _, account := range s.Accounts {
+		configs = append(configs, account.Regions...)
+	}
+	return configs
+}
+
+// newCatalogs returns an empty engineVersions map for each account and region. Engine catalogs are kept per account and
+// region, as the available engine versions differ between regions, and so that concurrent snapshots never share a map.
+func newCatalogs(scopes *Scopes) map[*Config]engineVersions {
+	catalogs := make(map[*Config]engineVersions)
+	for _, config := range scopes.configs() {
+		catalogs[config] = make(engineVersions)
+	}
+	return catalogs
+}
+
+// describeScope describes the account and region of a Config in error messages.
+func describeScope(config *Config) string {
+	if config.RoleARN == "" {
+		return fmt.Sprintf("region %q", config.Region)
+	}
+	return fmt.Sprintf("region %q with role %s", config.Region, config.RoleARN)
+}
+
+// snapshotScopes snapshots every account and region, bounded by the MaxAccountsInFlight and MaxRegionsPerAccount of
+// the scopes. Each region is snapshotted with its own catalogs, into scratch Metrics recorded by the Staleness of the
+// metrics, from which the gauges and the Inventory are rebuilt once every region is snapshotted: the regions that
+// failed keep the series and the inventory items of their last successful snapshot, and are flagged by the
+// ScopeStaleGauge. Every region is snapshotted even if another one failed, so that the healthy regions are exported
+// whatever the others, and the error of the first failed region, in the order of the scopes, is returned as a
+// *staleScopesError counting the failed scopes. A scope whose snapshot panics fails too, and the *panicError is wrapped
+// in the *staleScopesError.
+// The snapshot and the snapshot of each region are traced by the Tracer of the scopes, if any, along with the AWS API
+// calls of each region.
+// The Trusted Advisor checks, which are global to an account, are exported once per account, with the Config of its
+// first region, after its regions are snapshotted; they are a scope of their own.
+// The version policy of the scopes, if any, is refreshed first, and the catalogs are emptied if it changed.
+func snapshotScopes(scopes *Scopes, metrics *Metrics, catalogs map[*Config]engineVersions) error {
+	metrics.Debug.reset()
+	// the catalogs are fetched again once the version policy changed, to ban the versions it bans
+	if scopes.Policy.refresh(metrics, time.Now()) {
+		for _, m := range catalogs {
+			for engine := range m {
+				delete(m, engine)
+			}
+		}
+	}
+
+	var keys []scopeKey
+	root := scopes.Tracer.start("snapshot")
+	accountTasks := make([]func() error, 0, len(scopes.Accounts))
+	for _, account := range scopes.Accounts {
+		account := account
+		for _, config := range account.Regions {
+			keys = append(keys, scopeKey{collector: scopeCollectorRDS, roleARN: config.RoleARN,
+				account: config.account(), region: config.Region})
+		}
+		var taKey scopeKey
+		if len(account.Regions) > 0 && account.Regions[0].TrustedAdvisor {
+			taKey = scopeKey{collector: scopeCollectorTrustedAdvisor, roleARN: account.RoleARN,
+				account: account.Regions[0].account()}
+			keys = append(keys, taKey)
+		}
+		accountTasks = append(accountTasks, func() error {
+			regionTasks := make([]func() error, 0, len(account.Regions))
+			for _, config := range account.Regions {
+				config := config
+				regionTasks = append(regionTasks, func() error {
+					regionSpan := root.child("snapshot region", otlpSpanKindInternal, time.Now())
+					regionSpan.set("cloud.region", config.Region)
+					regionSpan.set("cloud.account.id", config.account())
+					config.trace.set(regionSpan)
+					config.apiErrors.set(metrics)
+					scratch := metrics.scratch()
+					// a panic fails the region like an AWS error, instead of leaving its last result current
+					err := runTask(func() error { return snapshot(config, scratch, catalogs[config]) })
+					config.trace.set(nil)
+					regionSpan.end(err)
+					metrics.Staleness.record(scopeKey{collector: scopeCollectorRDS, roleARN: config.RoleARN,
+						account: config.account(), region: config.Region}, scratch, err, time.Now())
+					metrics.Debug.recordCatalogs(describeScope(config), catalogs[config])
+					metrics.Debug.recordError("snapshot "+describeScope(config), err)
+					if err != nil {
+						return fmt.Errorf("failed to snapshot %s; %w", describeScope(config), err)
+					}
+					return nil
+				})
+			}
+			err := runPool(scopes.MaxRegionsPerAccount, regionTasks)
+			if len(account.Regions) > 0 && account.Regions[0].TrustedAdvisor {
+				regions := make([]string, 0, len(account.Regions))
+				for _, config := range account.Regions {
+					regions = append(regions, config.Region)
+				}
+				scratch := metrics.scratch()
+				taErr := runTask(func() error { return exportTrustedAdvisor(account.Regions[0], scratch, regions) })
+				metrics.Staleness.record(taKey, scratch, taErr, time.Now())
+				metrics.Debug.recordError("trusted advisor "+describeAccount(account.RoleARN), taErr)
+				if taErr != nil && err == nil {
+					err = fmt.Errorf("failed to read the Trusted Advisor checks of %s; %w",
+						describeAccount(account.RoleARN), taErr)
+				}
+			}
+			return err
+		})
+	}
+	err := runPool(scopes.MaxAccountsInFlight, accountTasks)
+	metrics.Staleness.apply(metrics, keys)
+	root.end(err)
+	if err != nil {
+		return &staleScopesError{err: err, failed: metrics.Staleness.failures(), total: len(keys)}
+	}
+	return nil
+}
+
+// snapshotInventory takes a single snapshot of every account and region, and returns its inventory and its start time,
+// for the modes and the subcommands that exit after a snapshot. The inventory of a partial snapshot, see
+// partialSnapshot, is returned along with its error, and holds the resources of the scopes that succeeded.
+func snapshotInventory(scopes *Scopes) ([]inventoryItem, time.Time, error) {
+	metrics := NewMetrics()
+	start := time.Now()
+	err := snapshotScopes(scopes, metrics, newCatalogs(scopes))
+	if err != nil && !partialSnapshot(err) {
+		return nil, start, err
+	}
+	return metrics.Inventory.list(), start, err
+}
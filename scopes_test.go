@@ -0,0 +1,137 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testScopes returns Scopes scanning the given Configs in a single account, one region at a time.
+func testScopes(configs ...*Config) *Scopes {
+	return &Scopes{
+		Accounts:             []AccountScope{{Regions: configs}},
+		MaxAccountsInFlight:  1,
+		MaxRegionsPerAccount: 1,
+	}
+}
+
+// inFlightRDSAPI counts the DescribeDBInstances calls in flight, shared by every account and region.
+type inFlightRDSAPI struct {
+	MockRDSAPI
+	mu       *sync.Mutex
+	inFlight *int
+	max      *int
+}
+
+func (m inFlightRDSAPI) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	m.mu.Lock()
+	*m.inFlight++
+	if *m.inFlight > *m.max {
+		*m.max = *m.inFlight
+	}
+	m.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	*m.inFlight--
+	m.mu.Unlock()
+	return m.MockRDSAPI.DescribeDBInstances(input)
+}
+
+// TestSnapshotScopes tests that snapshotScopes exports the resources of every account and region with their region,
+// bounded by MaxAccountsInFlight and MaxRegionsPerAccount.
+func TestSnapshotScopes(t *testing.T) {
+	mu, inFlight, max := &sync.Mutex{}, new(int), new(int)
+	newConfig := func(region, roleARN string) *Config {
+		return &Config{Region: region, RoleARN: roleARN, Concurrency: 2, RDS: inFlightRDSAPI{
+			MockRDSAPI: MockRDSAPI{
+				instancesOutput: []*rds.DescribeDBInstancesOutput{{DBInstances: []*rds.DBInstance{{
+					DBInstanceIdentifier: Ptr("db-1"),
+					Engine:               Ptr("postgres"),
+					EngineVersion:        Ptr("14.7"),
+				}}}},
+				clustersOutput: []*rds.DescribeDBClustersOutput{{}},
+				engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{DBEngineVersions: []*rds.DBEngineVersion{{
+					Engine:        Ptr("postgres"),
+					EngineVersion: Ptr("14.7"),
+					Status:        Ptr("available"),
+				}}}},
+			},
+			mu: mu, inFlight: inFlight, max: max,
+		}}
+	}
+
+	tests := []struct {
+		name                 string
+		maxAccountsInFlight  int
+		maxRegionsPerAccount int
+		wantMax              int
+	}{
+		{name: "serial", maxAccountsInFlight: 1, maxRegionsPerAccount: 1, wantMax: 1},
+		{name: "regions in parallel", maxAccountsInFlight: 1, maxRegionsPerAccount: 2, wantMax: 2},
+		{name: "accounts and regions in parallel", maxAccountsInFlight: 2, maxRegionsPerAccount: 2, wantMax: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*max = 0
+			roleARN := "arn:aws:iam::123456789012:role/exporter"
+			scopes := &Scopes{
+				Accounts: []AccountScope{
+					{Regions: []*Config{newConfig("eu-west-1", ""), newConfig("us-east-1", "")}},
+					{RoleARN: roleARN, Regions: []*Config{newConfig("eu-west-1", roleARN), newConfig("us-east-1", roleARN)}},
+				},
+				MaxAccountsInFlight:  tt.maxAccountsInFlight,
+				MaxRegionsPerAccount: tt.maxRegionsPerAccount,
+			}
+			metrics := NewMetrics()
+
+			assert.NoError(t, snapshotScopes(scopes, metrics, newCatalogs(scopes)))
+			assert.Equal(t, tt.wantMax, *max)
+			// the instances of each account have their own series in each region
+			assert.Equal(t, 4, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_available"))
+			for _, account := range []string{"default", "123456789012"} {
+				for _, region := range []string{"eu-west-1", "us-east-1"} {
+					assert.Equal(t, 1.0, servedValue(metrics, metrics.AvailableGauge,
+						"db-1", "postgres", "14.7", "14", "7", "", "", "", "false", "false", "", account, region))
+				}
+			}
+		})
+	}
+
+	// errors tell the account and region
+	failing := newConfig("eu-west-1", "arn:aws:iam::123456789012:role/exporter")
+	failing.RDS = &MockRDSAPI{err: errors.New("AccessDenied")}
+	scopes := testScopes(newConfig("us-east-1", ""), failing)
+	err := snapshotScopes(scopes, NewMetrics(), newCatalogs(scopes))
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(),
+		`failed to snapshot region "eu-west-1" with role arn:aws:iam::123456789012:role/exporter; `), err.Error())
+}
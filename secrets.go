@@ -0,0 +1,208 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// The schemes of the references to secrets, which the secret options accept instead of their value:
+// secretsmanager://<secret id or ARN>[#<JSON key>] and ssm://<parameter name or ARN>.
+const (
+	secretsManagerScheme = "secretsmanager://"
+	ssmScheme            = "ssm://"
+)
+
+// secretResolver resolves the references to AWS Secrets Manager secrets and SSM parameters, with the clients of the
+// region of the ARN they reference, or of the default region. A secretResolver that only parses the references, and
+// returns them unresolved, calls neither AWS nor its clients.
+type secretResolver struct {
+	secretsManager func(region string) secretsmanageriface.SecretsManagerAPI
+	ssm            func(region string) ssmiface.SSMAPI
+	parseOnly      bool
+}
+
+// newAWSSecretResolver returns a secretResolver calling AWS with the session of the options, created on the first
+// reference only.
+func newAWSSecretResolver(options *Options) *secretResolver {
+	var sess *session.Session
+	clientConfig := func(region string) (*session.Session, *aws.Config) {
+		if sess == nil {
+			sess = newSession(options, nil)
+		}
+		config := aws.NewConfig()
+		if region != "" {
+			config = config.WithRegion(region)
+		}
+		return sess, config
+	}
+	return &secretResolver{
+		secretsManager: func(region string) secretsmanageriface.SecretsManagerAPI {
+			return secretsmanager.New(clientConfig(region))
+		},
+		ssm: func(region string) ssmiface.SSMAPI {
+			return ssm.New(clientConfig(region))
+		},
+	}
+}
+
+// isSecretReference returns true if the value of an option references a secret.
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretsManagerScheme) || strings.HasPrefix(value, ssmScheme)
+}
+
+// arnRegion returns the region of an ARN, or an empty string if id is not an ARN.
+func arnRegion(id string) string {
+	parsed, err := arn.Parse(id)
+	if err != nil {
+		return ""
+	}
+	return parsed.Region
+}
+
+// parseSecretReference checks that a reference names a secret or a parameter, and a key if it ends with #.
+func parseSecretReference(reference string) error {
+	switch {
+	case strings.HasPrefix(reference, secretsManagerScheme):
+		id, key, hasKey := strings.Cut(strings.TrimPrefix(reference, secretsManagerScheme), "#")
+		if id == "" {
+			return fmt.Errorf("%s should name a secret", reference)
+		}
+		if hasKey && key == "" {
+			return fmt.Errorf("%s should name the key of the secret after #", reference)
+		}
+	case strings.HasPrefix(reference, ssmScheme):
+		if strings.TrimPrefix(reference, ssmScheme) == "" {
+			return fmt.Errorf("%s should name a parameter", reference)
+		}
+	}
+	return nil
+}
+
+// resolve returns the value of the secret a reference refers to. The value of a Secrets Manager secret is its
+// string, or the value of one of its keys if it is a JSON object and the reference ends with #<key>. The value of an
+// SSM parameter is decrypted if it is a SecureString, and must not be empty. If the resolver only parses the
+// references, the reference is returned as is.
+func (r *secretResolver) resolve(reference string) (string, error) {
+	if err := parseSecretReference(reference); err != nil || r.parseOnly {
+		return reference, err
+	}
+	switch {
+	case strings.HasPrefix(reference, secretsManagerScheme):
+		id, key, _ := strings.Cut(strings.TrimPrefix(reference, secretsManagerScheme), "#")
+		output, err := r.secretsManager(arnRegion(id)).GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(id),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get secret %s; %w", id, err)
+		}
+		value := aws.StringValue(output.SecretString)
+		if key == "" {
+			return value, nil
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &values); err != nil {
+			return "", fmt.Errorf("secret %s is not a JSON object; %w", id, err)
+		}
+		keyValue, ok := values[key].(string)
+		if !ok {
+			return "", fmt.Errorf("secret %s has no string key %q", id, key)
+		}
+		return keyValue, nil
+	case strings.HasPrefix(reference, ssmScheme):
+		name := strings.TrimPrefix(reference, ssmScheme)
+		output, err := r.ssm(arnRegion(name)).GetParameter(&ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get parameter %s; %w", name, err)
+		}
+		var value string
+		if output.Parameter != nil {
+			value = aws.StringValue(output.Parameter.Value)
+		}
+		if value == "" {
+			return "", fmt.Errorf("parameter %s has no value", name)
+		}
+		return value, nil
+	}
+	return reference, nil
+}
+
+// resolveSecrets replaces the references to secrets in the secret options with the values of the secrets, and returns
+// the problems of the ones that cannot be resolved.
+func (o *Options) resolveSecrets(resolver *secretResolver) []string {
+	var problems []string
+	for _, spec := range o.specs() {
+		reference := spec.value.String()
+		if !spec.secret || !isSecretReference(reference) {
+			continue
+		}
+		value, err := resolver.resolve(reference)
+		if err == nil {
+			err = spec.value.Set(value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s could not be resolved: %s", spec.flag, err))
+		}
+	}
+	// the URLs and the tokens of the sinks, and the tokens of the tenants, of the configuration file are secret
+	// options too
+	for i := range o.Sinks {
+		problems = append(problems, resolveSecretValues(resolver, fmt.Sprintf("sink %d", i+1),
+			&o.Sinks[i].URL, &o.Sinks[i].Token)...)
+	}
+	for i := range o.Tenants {
+		problems = append(problems, resolveSecretValues(resolver, fmt.Sprintf("tenant %d", i+1), &o.Tenants[i].Token)...)
+	}
+	return problems
+}
+
+// resolveSecretValues replaces the references to secrets among the values of an entry of the configuration file, e.g.
+// "sink 1", with the values of the secrets, and returns the problems of the ones that cannot be resolved.
+func resolveSecretValues(resolver *secretResolver, entry string, values ...*string) []string {
+	var problems []string
+	for _, value := range values {
+		if !isSecretReference(*value) {
+			continue
+		}
+		resolved, err := resolver.resolve(*value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s could not be resolved: %s", entry, err))
+			continue
+		}
+		*value = resolved
+	}
+	return problems
+}
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockSecretsManagerAPI struct {
+	secretsmanageriface.SecretsManagerAPI
+	secrets map[string]string
+}
+
+func (m *MockSecretsManagerAPI) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	secret, ok := m.secrets[aws.StringValue(input.SecretId)]
+	if !ok {
+		return nil, errors.New("ResourceNotFoundException")
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+type MockSSMAPI struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+}
+
+func (m *MockSSMAPI) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	parameter, ok := m.parameters[aws.StringValue(input.Name)]
+	if !ok || !aws.BoolValue(input.WithDecryption) {
+		return nil, errors.New("ParameterNotFound")
+	}
+	if parameter == "" {
+		// a malformed response
+		return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{}}, nil
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(parameter)}}, nil
+}
+
+// TestResolveSecrets tests that the references to secrets in the secret options are replaced with the values of the
+// secrets, in the region of their ARN if any, and that the other options are left untouched.
+func TestResolveSecrets(t *testing.T) {
+	var regions []string
+	resolver := &secretResolver{
+		secretsManager: func(region string) secretsmanageriface.SecretsManagerAPI {
+			regions = append(regions, region)
+			return &MockSecretsManagerAPI{secrets: map[string]string{
+				"exporter/smtp": `{"username":"exporter","password":"s3cr3t"}`,
+				"arn:aws:secretsmanager:us-east-1:123456789012:secret:exporter/sentry-AbCdEf": "https://key@sentry.io/1",
+			}}
+		},
+		ssm: func(region string) ssmiface.SSMAPI {
+			regions = append(regions, region)
+			return &MockSSMAPI{parameters: map[string]string{"/exporter/smtp-password": "s3cr3t", "/exporter/empty": ""}}
+		},
+	}
+	for _, tt := range []struct {
+		name         string
+		options      func(o *Options)
+		want         func(o *Options)
+		wantProblems []string
+		wantRegions  []string
+	}{
+		{
+			name: "secrets manager key",
+			options: func(o *Options) {
+				o.DigestSMTPPassword = "secretsmanager://exporter/smtp#password"
+				o.DigestSMTPUsername = "secretsmanager://exporter/smtp#username"
+			},
+			want: func(o *Options) {
+				o.DigestSMTPPassword = "s3cr3t"
+				// not a secret option
+				o.DigestSMTPUsername = "secretsmanager://exporter/smtp#username"
+			},
+			wantRegions: []string{""},
+		},
+		{
+			name: "secrets manager ARN",
+			options: func(o *Options) {
+				o.SentryDSN = "secretsmanager://arn:aws:secretsmanager:us-east-1:123456789012:secret:exporter/sentry-AbCdEf"
+			},
+			want:        func(o *Options) { o.SentryDSN = "https://key@sentry.io/1" },
+			wantRegions: []string{"us-east-1"},
+		},
+		{
+			name:        "SSM parameter",
+			options:     func(o *Options) { o.DigestSMTPPassword = "ssm:///exporter/smtp-password" },
+			want:        func(o *Options) { o.DigestSMTPPassword = "s3cr3t" },
+			wantRegions: []string{""},
+		},
+		{
+			name: "unresolved",
+			options: func(o *Options) {
+				o.DigestSMTPPassword = "secretsmanager://exporter/smtp#token"
+				o.SentryDSN = "ssm:///exporter/sentry-dsn"
+			},
+			want: func(o *Options) {
+				o.DigestSMTPPassword = "secretsmanager://exporter/smtp#token"
+				o.SentryDSN = "ssm:///exporter/sentry-dsn"
+			},
+			wantProblems: []string{
+				`sentry-dsn could not be resolved: failed to get parameter /exporter/sentry-dsn; ParameterNotFound`,
+				`digest-smtp-password could not be resolved: secret exporter/smtp has no string key "token"`,
+			},
+			wantRegions: []string{"", ""},
+		},
+		{
+			name:         "SSM parameter without value",
+			options:      func(o *Options) { o.DigestSMTPPassword = "ssm:///exporter/empty" },
+			want:         func(o *Options) { o.DigestSMTPPassword = "ssm:///exporter/empty" },
+			wantProblems: []string{`digest-smtp-password could not be resolved: parameter /exporter/empty has no value`},
+			wantRegions:  []string{""},
+		},
+		{
+			name:         "invalid reference",
+			options:      func(o *Options) { o.DigestSMTPPassword = "secretsmanager://" },
+			want:         func(o *Options) { o.DigestSMTPPassword = "secretsmanager://" },
+			wantProblems: []string{`digest-smtp-password could not be resolved: secretsmanager:// should name a secret`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			regions = nil
+			o, want := defaultOptions(), defaultOptions()
+			tt.options(o)
+			tt.want(want)
+			assert.Equal(t, tt.wantProblems, o.resolveSecrets(resolver))
+			assert.Equal(t, want, o)
+			assert.Equal(t, tt.wantRegions, regions)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// metricsDumpWriter is the http.ResponseWriter writing the metrics dumped on a signal.
+type metricsDumpWriter struct {
+	io.Writer
+	header http.Header
+	status int
+}
+
+// Header implements http.ResponseWriter.
+func (w *metricsDumpWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *metricsDumpWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// dumpMetrics writes the metrics served by handler, in the Prometheus text format, to the file at path, replacing it,
+// or to w if path is empty.
+func dumpMetrics(handler http.Handler, path string, w io.Writer) error {
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics dump file; %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	dump := &metricsDumpWriter{Writer: w, header: make(http.Header), status: http.StatusOK}
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	if err != nil {
+		return err
+	}
+	handler.ServeHTTP(dump, req)
+	if dump.status != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", dump.status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDumpMetrics tests that the metrics are dumped in the Prometheus text format, to a writer or into a file.
+func TestDumpMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.SnapshotPanicsCounter.Inc()
+	handler := initPromHandler(metrics, nil, nil)
+	want := "aws_custom_rds_snapshot_panics_total 1\n"
+
+	var buf bytes.Buffer
+	assert.NoError(t, dumpMetrics(handler, "", &buf))
+	assert.Contains(t, buf.String(), want)
+
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Repeat("stale\n", 1000)), 0o600))
+	assert.NoError(t, dumpMetrics(handler, path, nil))
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), want)
+	assert.NotContains(t, string(b), "stale")
+
+	assert.Error(t, dumpMetrics(handler, filepath.Join(t.TempDir(), "missing", "metrics.prom"), nil))
+}
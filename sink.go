@@ -0,0 +1,354 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The sinks the metrics are pushed to after each snapshot, by the modes that do not serve them, e.g. the Lambda mode,
+// and alongside the /metrics endpoint by the exporter.
+const (
+	sinkPushgateway = "pushgateway"
+	sinkRemoteWrite = "remote_write"
+	sinkCloudWatch  = "cloudwatch"
+	sinkInflux      = "influx"
+	sinkGraphite    = "graphite"
+	sinkDatadog     = "datadog"
+)
+
+// defaultCloudWatchNamespace is the CloudWatch namespace of the metrics pushed to CloudWatch, unless configured.
+const defaultCloudWatchNamespace = "RDSEngineVersions"
+
+// cloudWatchBatchSize is the number of metric data sent by each PutMetricData call, well below the 1 MB limit of a
+// request, and cloudWatchMaxDimensions the number of dimensions CloudWatch accepts per metric datum.
+const (
+	cloudWatchBatchSize     = 500
+	cloudWatchMaxDimensions = 30
+)
+
+// metricsSink pushes the metrics of a snapshot to a monitoring system.
+type metricsSink interface {
+	push(gatherer prometheus.Gatherer, now time.Time) error
+}
+
+// newMetricsSink returns the sinkPipeline of the sink of the options, followed by their sinks, or nil if none is set.
+// The metrics pushed to the Pushgateway are grouped by the accountID, if not empty, so that the exporters of several
+// accounts do not replace each other's metrics.
+func newMetricsSink(options *Options, accountID string, metrics *Metrics) metricsSink {
+	var stages []sinkStage
+	if options.Sink != "" {
+		stages = append(stages, sinkStage{
+			name: options.Sink,
+			sink: newSink(options, options.Sink, options.SinkURL, options.SinkToken, accountID),
+		})
+	}
+	for _, config := range options.Sinks {
+		stages = append(stages, sinkStage{
+			name:    config.name(),
+			sink:    newSink(options, config.Type, config.URL, config.Token, accountID),
+			filter:  config.filter,
+			onError: config.OnError,
+			retries: config.Retries,
+		})
+	}
+	if len(stages) == 0 {
+		return nil
+	}
+	return &sinkPipeline{stages: stages, metrics: metrics, retryDelay: sinkRetryDelay}
+}
+
+// newSink returns the metricsSink of a type of sink, with its URL and token, and the other settings of the options.
+func newSink(options *Options, kind, url, token, accountID string) metricsSink {
+	client := &http.Client{Timeout: options.AwsApiTimeout}
+	switch kind {
+	case sinkPushgateway:
+		return &pushgatewaySink{url: url, accountID: accountID, client: client}
+	case sinkRemoteWrite:
+		return &remoteWriteSink{url: url, client: client}
+	case sinkCloudWatch:
+		return &cloudWatchSink{client: cloudwatch.New(newSession(options, nil)), namespace: options.CloudWatchNamespace}
+	case sinkInflux:
+		return &influxSink{url: url, token: token, client: client}
+	case sinkGraphite:
+		return &graphiteSink{address: url, prefix: options.GraphitePrefix, tags: options.GraphiteTags,
+			timeout: options.AwsApiTimeout}
+	case sinkDatadog:
+		if url == "" {
+			url = defaultDatadogURL
+		}
+		return &datadogSink{url: url, apiKey: token, tagMap: options.datadogTagMap, client: client}
+	default:
+		return nil
+	}
+}
+
+// validateSink checks the sink options and the sinks, and parses the Datadog tag map.
+func (o *Options) validateSink() []string {
+	var problems []string
+	tagMap, err := parseDatadogTagMap(o.DatadogTagMap)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	o.datadogTagMap = tagMap
+	if o.Sink != "" {
+		if err := validateSinkTarget(o.Sink, o.SinkURL, o.SinkToken); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	names := map[string]bool{o.Sink: o.Sink != ""}
+	for i := range o.Sinks {
+		config := &o.Sinks[i]
+		if err := config.compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("sink %d: %s", i+1, err))
+			continue
+		}
+		if names[config.name()] {
+			problems = append(problems, fmt.Sprintf("sink %d: the name %q is already used, set another one", i+1,
+				config.name()))
+		}
+		names[config.name()] = true
+	}
+	return problems
+}
+
+// validateSinkTarget checks the URL and the token of a type of sink: the Pushgateway, remote write, InfluxDB and
+// Graphite sinks require a URL, which is the host:port address of the Graphite one, and the Datadog sink an API key.
+func validateSinkTarget(kind, url, token string) error {
+	switch kind {
+	case sinkCloudWatch:
+		return nil
+	case sinkDatadog:
+		if token == "" {
+			return errors.New("the datadog sink requires a sink token, the API key")
+		}
+		return nil
+	case sinkPushgateway, sinkRemoteWrite, sinkInflux, sinkGraphite:
+		if url == "" {
+			return fmt.Errorf("the %s sink requires a sink URL", kind)
+		}
+		if kind == sinkGraphite && !isSecretReference(url) {
+			return validateGraphiteAddress(url)
+		}
+		return nil
+	default:
+		return fmt.Errorf("sink should be %q, %q, %q, %q, %q or %q, got %q", sinkPushgateway, sinkRemoteWrite,
+			sinkCloudWatch, sinkInflux, sinkGraphite, sinkDatadog, kind)
+	}
+}
+
+// pushgatewaySink replaces the metrics of the job of the exporter in a Prometheus Pushgateway, grouped by instance,
+// i.e. the account ID, at each push.
+type pushgatewaySink struct {
+	url       string
+	accountID string
+	client    *http.Client
+}
+
+func (s *pushgatewaySink) push(gatherer prometheus.Gatherer, _ time.Time) error {
+	pusher := push.New(s.url, exporterName).Gatherer(gatherer).Client(s.client)
+	if s.accountID != "" {
+		pusher = pusher.Grouping("instance", s.accountID)
+	}
+	if err := pusher.Push(); err != nil {
+		return fmt.Errorf("failed to push the metrics to the Pushgateway; %w", err)
+	}
+	return nil
+}
+
+// remoteWriteSink sends the metrics to a Prometheus remote write endpoint, e.g. Amazon Managed Service for Prometheus
+// behind a SigV4 proxy, or Grafana Mimir. The samples are stamped with the time of the push.
+type remoteWriteSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *remoteWriteSink) push(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather the metrics; %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url,
+		bytes.NewReader(snappyEncode(encodeWriteRequest(families, now))))
+	if err != nil {
+		return fmt.Errorf("failed to create remote write request; %w", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", exporterName+"/"+version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send the metrics to the remote write endpoint; %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to send the metrics to the remote write endpoint; unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// sampleValue returns the value of a gauge, counter or untyped metric, and false for the other types, which the
+// exporter does not export.
+func sampleValue(m *dto.Metric) (float64, bool) {
+	switch {
+	case m.Gauge != nil:
+		return m.Gauge.GetValue(), true
+	case m.Counter != nil:
+		return m.Counter.GetValue(), true
+	case m.Untyped != nil:
+		return m.Untyped.GetValue(), true
+	default:
+		return 0, false
+	}
+}
+
+// encodeWriteRequest encodes the metric families into the protobuf of a remote write WriteRequest: a TimeSeries per
+// series, whose labels, including the __name__ one, are sorted by name, with a single sample stamped with now unless
+// it has a timestamp.
+func encodeWriteRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	var request []byte
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			value, ok := sampleValue(m)
+			if !ok {
+				continue
+			}
+			labels := map[string]string{"__name__": family.GetName()}
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			names := make([]string, 0, len(labels))
+			for name := range labels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var series []byte
+			for _, name := range names {
+				var label []byte
+				label = appendProtoBytes(label, 1, []byte(name))
+				label = appendProtoBytes(label, 2, []byte(labels[name]))
+				series = appendProtoBytes(series, 1, label)
+			}
+			timestamp := now.UnixMilli()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			var sample []byte
+			sample = binary.AppendUvarint(sample, 1<<3|1) // value, 64-bit
+			sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(value))
+			sample = binary.AppendUvarint(sample, 2<<3|0) // timestamp, varint
+			sample = binary.AppendUvarint(sample, uint64(timestamp))
+			series = appendProtoBytes(series, 2, sample)
+			request = appendProtoBytes(request, 1, series)
+		}
+	}
+	return request
+}
+
+// appendProtoBytes appends a length-delimited protobuf field, e.g. a string or an embedded message, to b.
+func appendProtoBytes(b []byte, field uint64, value []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// snappyEncode encodes data in the snappy block format required by the remote write protocol, as a sequence of
+// literals: the metrics are not compressed, which every snappy decoder accepts, and saves a dependency.
+func snappyEncode(data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(data)))
+	for len(data) > 0 {
+		n := len(data)
+		if n > 1<<16 {
+			n = 1 << 16
+		}
+		// a literal of up to 65536 bytes: tag 61, then its length minus one on two bytes
+		b = append(b, 61<<2, byte(n-1), byte((n-1)>>8))
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	return b
+}
+
+// cloudWatchSink puts the metrics into CloudWatch, as custom metrics of its namespace named after the metrics, whose
+// dimensions are the labels that are not empty, up to the first 30.
+type cloudWatchSink struct {
+	client    cloudwatchiface.CloudWatchAPI
+	namespace string
+}
+
+func (s *cloudWatchSink) push(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather the metrics; %w", err)
+	}
+	var data []*cloudwatch.MetricDatum
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			// CloudWatch rejects the values that are not numbers
+			value, ok := sampleValue(m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			var dimensions []*cloudwatch.Dimension
+			for _, pair := range m.GetLabel() {
+				if pair.GetValue() != "" && len(dimensions) < cloudWatchMaxDimensions {
+					dimensions = append(dimensions, &cloudwatch.Dimension{Name: pair.Name, Value: pair.Value})
+				}
+			}
+			data = append(data, &cloudwatch.MetricDatum{
+				MetricName: family.Name,
+				Dimensions: dimensions,
+				Timestamp:  Ptr(now),
+				Value:      Ptr(value),
+			})
+		}
+	}
+	for start := 0; start < len(data); start += cloudWatchBatchSize {
+		end := start + cloudWatchBatchSize
+		if end > len(data) {
+			end = len(data)
+		}
+		_, err := s.client.PutMetricData(&cloudwatch.PutMetricDataInput{
+			Namespace:  Ptr(s.namespace),
+			MetricData: data[start:end],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to put the metrics into CloudWatch; %w", err)
+		}
+	}
+	return nil
+}
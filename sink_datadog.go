@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// defaultDatadogURL is the API of the Datadog site the metrics are submitted to, unless a sink URL is configured, e.g.
+// "https://api.datadoghq.eu".
+const defaultDatadogURL = "https://api.datadoghq.com"
+
+// datadogBatchSize is the number of series submitted by each request, well below the 5 MB limit of its payload.
+const datadogBatchSize = 1000
+
+// datadogGauge is the type of the gauges in the Datadog metrics API.
+const datadogGauge = 3
+
+// datadogSeries is a series of the Datadog metrics API v2, with a single point.
+type datadogSeries struct {
+	Metric string         `json:"metric"`
+	Type   int            `json:"type"`
+	Points []datadogPoint `json:"points"`
+	Tags   []string       `json:"tags,omitempty"`
+}
+
+// datadogPoint is a point of a series, stamped in seconds.
+type datadogPoint struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// parseDatadogTagMap parses the renaming of the labels into Datadog tags, e.g.
+// "cluster_identifier=dbclusteridentifier,region=region".
+func parseDatadogTagMap(s string) (map[string]string, error) {
+	tagMap := make(map[string]string)
+	for _, item := range splitList(s) {
+		label, tag, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(label) == "" || strings.TrimSpace(tag) == "" {
+			return nil, errors.New("datadog tag map should be a list of label=tag")
+		}
+		tagMap[strings.TrimSpace(label)] = strings.TrimSpace(tag)
+	}
+	return tagMap, nil
+}
+
+// datadogSink submits the metrics to the series endpoint of the Datadog metrics API, as gauges whose tags are their
+// labels that are not empty, "name:value", renamed by the tag map, stamped with the time of the push.
+type datadogSink struct {
+	url    string
+	apiKey string
+	tagMap map[string]string
+	client *http.Client
+}
+
+func (s *datadogSink) push(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather the metrics; %w", err)
+	}
+	series := datadogSeriesOf(families, s.tagMap, now)
+	for start := 0; start < len(series); start += datadogBatchSize {
+		end := start + datadogBatchSize
+		if end > len(series) {
+			end = len(series)
+		}
+		if err := s.submit(series[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// datadogSeriesOf returns the series of the metric families. The values that are not numbers are skipped, as Datadog
+// rejects them.
+func datadogSeriesOf(families []*dto.MetricFamily, tagMap map[string]string, now time.Time) []datadogSeries {
+	var series []datadogSeries
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			value, ok := sampleValue(m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			var tags []string
+			for _, pair := range m.GetLabel() {
+				if pair.GetValue() == "" {
+					continue
+				}
+				name := pair.GetName()
+				if tag, ok := tagMap[name]; ok {
+					name = tag
+				}
+				tags = append(tags, name+":"+pair.GetValue())
+			}
+			timestamp := now.Unix()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs() / 1000
+			}
+			series = append(series, datadogSeries{
+				Metric: family.GetName(),
+				Type:   datadogGauge,
+				Points: []datadogPoint{{Timestamp: timestamp, Value: value}},
+				Tags:   tags,
+			})
+		}
+	}
+	return series
+}
+
+// submit submits a batch of series, which Datadog should accept.
+func (s *datadogSink) submit(series []datadogSeries) error {
+	body, err := json.Marshal(struct {
+		Series []datadogSeries `json:"series"`
+	}{series})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Datadog series; %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(s.url, "/")+"/api/v2/series", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Datadog request; %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", exporterName+"/"+version)
+	req.Header.Set("DD-API-KEY", s.apiKey)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit the metrics to Datadog; %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+	_ = json.Unmarshal(data, &response)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to submit the metrics to Datadog; unexpected status %s: %s", resp.Status,
+			strings.Join(response.Errors, "; "))
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("failed to submit the metrics to Datadog; %s", strings.Join(response.Errors, "; "))
+	}
+	return nil
+}
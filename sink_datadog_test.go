@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDatadogSink tests the headers and the body of the requests to the series endpoint, the renaming of the labels
+// into tags, and that the failures are reported.
+func TestDatadogSink(t *testing.T) {
+	status, response := http.StatusAccepted, `{"errors": []}`
+	var path string
+	var header http.Header
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, header, body = r.URL.Path, r.Header, string(data)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	tagMap, err := parseDatadogTagMap("cluster_identifier=dbclusteridentifier")
+	assert.NoError(t, err)
+	sink := &datadogSink{url: server.URL + "/", apiKey: "key", tagMap: tagMap, client: server.Client()}
+	assert.NoError(t, sink.push(sinkGatherer("orders", "users"), sinkNow))
+	assert.Equal(t, "/api/v2/series", path)
+	assert.Equal(t, "key", header.Get("DD-API-KEY"))
+	assert.JSONEq(t, `{"series": [
+		{"metric": "aws_custom_rds_version_deprecated", "type": 3, "tags": ["dbclusteridentifier:orders"],
+			"points": [{"timestamp": 1683729420, "value": 1}]},
+		{"metric": "aws_custom_rds_version_deprecated", "type": 3, "tags": ["dbclusteridentifier:users"],
+			"points": [{"timestamp": 1683729420, "value": 0}]}
+	]}`, body)
+
+	status, response = http.StatusForbidden, `{"errors": ["Forbidden"]}`
+	assert.EqualError(t, sink.push(sinkGatherer("orders"), sinkNow),
+		"failed to submit the metrics to Datadog; unexpected status 403 Forbidden: Forbidden")
+}
+
+// TestParseDatadogTagMap tests the parsing of the label renames.
+func TestParseDatadogTagMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "empty", s: "", want: map[string]string{}},
+		{
+			name: "renames",
+			s:    "cluster_identifier=dbclusteridentifier, engine=dbengine",
+			want: map[string]string{"cluster_identifier": "dbclusteridentifier", "engine": "dbengine"},
+		},
+		{name: "missing tag", s: "cluster_identifier=", wantErr: "datadog tag map should be a list of label=tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDatadogTagMap(tt.s)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
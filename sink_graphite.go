@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// graphiteSink sends the metrics to Graphite, e.g. carbon-cache or carbon-relay, with the plaintext protocol over TCP.
+// Each series is a path of the prefix, named after its metric and, unless tags are used, followed by the names and
+// the values of its labels that are not empty, stamped with the time of the push in seconds.
+type graphiteSink struct {
+	address string
+	prefix  string
+	tags    bool
+	timeout time.Duration
+}
+
+func (s *graphiteSink) push(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather the metrics; %w", err)
+	}
+	conn, err := net.DialTimeout("tcp", s.address, s.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Graphite; %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+		return fmt.Errorf("failed to send the metrics to Graphite; %w", err)
+	}
+	if _, err := conn.Write(encodeGraphite(families, s.prefix, s.tags, now)); err != nil {
+		return fmt.Errorf("failed to send the metrics to Graphite; %w", err)
+	}
+	return nil
+}
+
+// encodeGraphite encodes the metric families in the Graphite plaintext protocol, a line per series whose labels are
+// sorted by name, either as the tags of the path, e.g. "rds.aws_custom_rds_version_deprecated;region=eu-west-1", or
+// as its nodes, e.g. "rds.aws_custom_rds_version_deprecated.region.eu-west-1". The values that are not numbers are
+// skipped, as Graphite rejects them.
+func encodeGraphite(families []*dto.MetricFamily, prefix string, tags bool, now time.Time) []byte {
+	var b bytes.Buffer
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			value, ok := sampleValue(m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			labels := make([]*dto.LabelPair, 0, len(m.GetLabel()))
+			for _, pair := range m.GetLabel() {
+				if pair.GetValue() != "" {
+					labels = append(labels, pair)
+				}
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+			timestamp := now.Unix()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs() / 1000
+			}
+			if prefix != "" {
+				b.WriteString(prefix)
+				b.WriteByte('.')
+			}
+			b.WriteString(family.GetName())
+			for _, pair := range labels {
+				if tags {
+					b.WriteByte(';')
+					b.WriteString(pair.GetName())
+					b.WriteByte('=')
+					b.WriteString(graphiteTagEscaper.Replace(pair.GetValue()))
+				} else {
+					b.WriteByte('.')
+					b.WriteString(pair.GetName())
+					b.WriteByte('.')
+					b.WriteString(graphiteNodeEscaper.Replace(pair.GetValue()))
+				}
+			}
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatInt(timestamp, 10))
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// graphiteNodeEscaper and graphiteTagEscaper replace the characters that the nodes of a path, and the values of its
+// tags, cannot hold with underscores, e.g. the dots of the engine versions in the nodes.
+var (
+	graphiteNodeEscaper = strings.NewReplacer(".", "_", " ", "_", ";", "_", "\n", "_", "/", "_", "*", "_")
+	graphiteTagEscaper  = strings.NewReplacer(" ", "_", ";", "_", "~", "_", "\n", "_")
+)
+
+// validateGraphiteAddress checks that the URL of the Graphite sink is a host:port address.
+func validateGraphiteAddress(address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("the graphite sink URL should be host:port, got %q", address)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGraphiteSink tests that the metrics are sent over TCP with the plaintext protocol, and that the failures to
+// connect are reported.
+func TestGraphiteSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	sink := &graphiteSink{address: listener.Addr().String(), prefix: "infra.rds", timeout: time.Second}
+	assert.NoError(t, sink.push(sinkGatherer("orders"), sinkNow))
+	assert.Equal(t, "infra.rds.aws_custom_rds_version_deprecated.cluster_identifier.orders 0 1683729420\n",
+		<-received)
+
+	address := listener.Addr().String()
+	assert.NoError(t, listener.Close())
+	sink = &graphiteSink{address: address, timeout: time.Second}
+	err = sink.push(sinkGatherer("orders"), sinkNow)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to connect to Graphite; ")
+	}
+}
+
+// TestEncodeGraphite tests the paths of the series, with their labels as nodes or as tags.
+func TestEncodeGraphite(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_custom_rds_version_deprecated", Help: "help"},
+		[]string{"region", "engine_version", "cluster_identifier"})
+	gauge.WithLabelValues("eu-west-1", "8.0.mysql_aurora.3.02.0", "orders").Set(1)
+	r := prometheus.NewRegistry()
+	r.MustRegister(gauge)
+	families, err := r.Gather()
+	assert.NoError(t, err)
+	tests := []struct {
+		name   string
+		prefix string
+		tags   bool
+		want   string
+	}{
+		{
+			name:   "nodes",
+			prefix: "infra.rds",
+			want: "infra.rds.aws_custom_rds_version_deprecated.cluster_identifier.orders.engine_version." +
+				"8_0_mysql_aurora_3_02_0.region.eu-west-1 1 1683729420\n",
+		},
+		{
+			name: "tags",
+			tags: true,
+			want: "aws_custom_rds_version_deprecated;cluster_identifier=orders;engine_version=8.0.mysql_aurora.3.02.0;" +
+				"region=eu-west-1 1 1683729420\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(encodeGraphite(families, tt.prefix, tt.tags, sinkNow)))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// influxSink writes the metrics in the InfluxDB line protocol to a write endpoint, e.g. the /api/v2/write endpoint of
+// InfluxDB 2, whose URL holds the organization and the bucket, the /write endpoint of InfluxDB 1, or the HTTP listener
+// of Telegraf. Each series is a point of the measurement named after its metric, whose tags are its labels that are
+// not empty, with a single "value" field, stamped with the time of the push in nanoseconds.
+type influxSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *influxSink) push(gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather the metrics; %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(encodeLineProtocol(families, now)))
+	if err != nil {
+		return fmt.Errorf("failed to create InfluxDB write request; %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", exporterName+"/"+version)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write the metrics to InfluxDB; %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to write the metrics to InfluxDB; unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// encodeLineProtocol encodes the metric families in the InfluxDB line protocol, a line per series, whose tags are
+// sorted by key, as InfluxDB recommends. The values that are not numbers are skipped, as InfluxDB rejects them.
+func encodeLineProtocol(families []*dto.MetricFamily, now time.Time) []byte {
+	var b bytes.Buffer
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			value, ok := sampleValue(m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			labels := make([]*dto.LabelPair, 0, len(m.GetLabel()))
+			for _, pair := range m.GetLabel() {
+				if pair.GetValue() != "" {
+					labels = append(labels, pair)
+				}
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+			timestamp := now.UnixNano()
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs() * int64(time.Millisecond)
+			}
+			b.WriteString(lineProtocolMeasurementEscaper.Replace(family.GetName()))
+			for _, pair := range labels {
+				b.WriteByte(',')
+				b.WriteString(lineProtocolTagEscaper.Replace(pair.GetName()))
+				b.WriteByte('=')
+				b.WriteString(lineProtocolTagEscaper.Replace(pair.GetValue()))
+			}
+			b.WriteString(" value=")
+			b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatInt(timestamp, 10))
+			b.WriteByte('\n')
+		}
+	}
+	return b.Bytes()
+}
+
+// lineProtocolMeasurementEscaper and lineProtocolTagEscaper escape the special characters of the measurements, and of
+// the tag keys and values, of the line protocol. The line breaks cannot be escaped, and are replaced by spaces.
+var (
+	lineProtocolMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\ `)
+	lineProtocolTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `)
+)
@@ -0,0 +1,72 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInfluxSink tests the headers and the body of the InfluxDB write requests, and that the failures are reported.
+func TestInfluxSink(t *testing.T) {
+	status := http.StatusNoContent
+	var header http.Header
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		header, body = r.Header, string(data)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &influxSink{url: server.URL + "/api/v2/write?org=platform&bucket=rds", token: "secret", client: server.Client()}
+	assert.NoError(t, sink.push(sinkGatherer("orders", "users"), sinkNow))
+	assert.Equal(t, "Token secret", header.Get("Authorization"))
+	assert.Equal(t, "aws_custom_rds_version_deprecated,cluster_identifier=orders value=1 1683729420000000000\n"+
+		"aws_custom_rds_version_deprecated,cluster_identifier=users value=0 1683729420000000000\n", body)
+
+	status = http.StatusUnauthorized
+	assert.EqualError(t, sink.push(sinkGatherer("orders"), sinkNow),
+		"failed to write the metrics to InfluxDB; unexpected status 401 Unauthorized")
+}
+
+// TestEncodeLineProtocol tests that the tags are sorted and escaped, and that the values that are not numbers are
+// skipped.
+func TestEncodeLineProtocol(t *testing.T) {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_custom_rds_owner_info", Help: "help"},
+		[]string{"team", "cluster_identifier"})
+	gauge.WithLabelValues("data platform", "orders,eu").Set(1)
+	gauge.WithLabelValues("payments=core", "users").Set(math.NaN())
+	r := prometheus.NewRegistry()
+	r.MustRegister(gauge)
+	families, err := r.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, "aws_custom_rds_owner_info,cluster_identifier=orders\\,eu,team=data\\ platform value=1 "+
+		"1683729420000000000\n", string(encodeLineProtocol(families, sinkNow)))
+}
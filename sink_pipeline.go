@@ -0,0 +1,224 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The error handlings of the sinks: the failures to push to a sink are either reported, like the other failures of
+// the exporter, and fail the Lambda invocations, or only logged.
+const (
+	sinkOnErrorReport = "report"
+	sinkOnErrorIgnore = "ignore"
+)
+
+// maxSinkRetries bounds the retries of the sinks, and sinkRetryDelay is the delay before each retry.
+const (
+	maxSinkRetries = 5
+	sinkRetryDelay = 2 * time.Second
+)
+
+// sinkConfig is a sink of the configuration file, pushed to after each snapshot along with the other ones, with its
+// own filter and error handling. Its URL and token are secret options. The settings that are specific to a type of
+// sink, e.g. the Graphite prefix, are the ones of the options.
+type sinkConfig struct {
+	// Name tells the sink in the logs and the metrics, and defaults to its type.
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+
+	// Metrics is the regular expression of the names of the metrics pushed, all of them by default, and Labels the
+	// regular expressions the labels of the series pushed should match, the missing labels being empty.
+	Metrics string            `yaml:"metrics"`
+	Labels  map[string]string `yaml:"labels"`
+
+	// OnError is the error handling of the sink, "report" by default, and Retries the number of times a failed push is
+	// retried.
+	OnError string `yaml:"on_error"`
+	Retries int    `yaml:"retries"`
+
+	// filter is the compiled Metrics and Labels, set by compile. It is nil if they are not set.
+	filter *sinkFilter
+}
+
+// name returns the name of the sink, or its type.
+func (c *sinkConfig) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// compile checks the sink, compiles its filter, and sets its default error handling.
+func (c *sinkConfig) compile() error {
+	if err := validateSinkTarget(c.Type, c.URL, c.Token); err != nil {
+		return err
+	}
+	if c.OnError == "" {
+		c.OnError = sinkOnErrorReport
+	}
+	if c.OnError != sinkOnErrorReport && c.OnError != sinkOnErrorIgnore {
+		return fmt.Errorf("on_error should be either %q or %q, got %q", sinkOnErrorReport, sinkOnErrorIgnore,
+			c.OnError)
+	}
+	if c.Retries < 0 || c.Retries > maxSinkRetries {
+		return fmt.Errorf("retries should be between 0 and %d, got %d", maxSinkRetries, c.Retries)
+	}
+	c.filter = nil
+	if c.Metrics == "" && len(c.Labels) == 0 {
+		return nil
+	}
+	filter := &sinkFilter{labels: make(map[string]*regexp.Regexp, len(c.Labels))}
+	if c.Metrics != "" {
+		metrics, err := regexp.Compile("^(?:" + c.Metrics + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid metrics regex %q; %w", c.Metrics, err)
+		}
+		filter.metrics = metrics
+	}
+	for label, expr := range c.Labels {
+		regex, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid regex %q of label %s; %w", expr, label, err)
+		}
+		filter.labels[label] = regex
+	}
+	c.filter = filter
+	return nil
+}
+
+// sinkFilter selects the series pushed to a sink, by the name of their metric and by their labels.
+type sinkFilter struct {
+	metrics *regexp.Regexp
+	labels  map[string]*regexp.Regexp
+}
+
+// filteredGatherer gathers the series of a gatherer that its filter selects, dropping the metric families left empty.
+type filteredGatherer struct {
+	gatherer prometheus.Gatherer
+	filter   *sinkFilter
+}
+
+func (g filteredGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	var filtered []*dto.MetricFamily
+	for _, family := range families {
+		if g.filter.metrics != nil && !g.filter.metrics.MatchString(family.GetName()) {
+			continue
+		}
+		var selected []*dto.Metric
+		for _, m := range family.GetMetric() {
+			if g.filter.selects(m) {
+				selected = append(selected, m)
+			}
+		}
+		if len(selected) > 0 {
+			filtered = append(filtered, &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type,
+				Metric: selected})
+		}
+	}
+	return filtered, err
+}
+
+// selects reports whether the labels of a series match the regular expressions of the filter.
+func (f *sinkFilter) selects(m *dto.Metric) bool {
+	for label, regex := range f.labels {
+		value := ""
+		for _, pair := range m.GetLabel() {
+			if pair.GetName() == label {
+				value = pair.GetValue()
+				break
+			}
+		}
+		if !regex.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sinkStage is a sink of a sinkPipeline, with its filter and its error handling.
+type sinkStage struct {
+	name    string
+	sink    metricsSink
+	filter  *sinkFilter
+	onError string
+	retries int
+}
+
+// sinkPipeline pushes the metrics of a snapshot to several sinks, each of them with its own filter and error handling,
+// and sets the SinkPushSuccessGauge of each of them. A failed sink does not prevent the next ones from being pushed to.
+type sinkPipeline struct {
+	stages     []sinkStage
+	metrics    *Metrics
+	retryDelay time.Duration
+}
+
+// push pushes the metrics to each sink, and returns the failures of the sinks whose errors are reported, the failures
+// of the other ones being only logged.
+func (p *sinkPipeline) push(gatherer prometheus.Gatherer, now time.Time) error {
+	var failures []string
+	for _, stage := range p.stages {
+		err := p.pushStage(stage, gatherer, now)
+		labels := prometheus.Labels{"sink": stage.name}
+		if err == nil {
+			p.metrics.SinkPushSuccessGauge.With(labels).Set(1)
+			continue
+		}
+		p.metrics.SinkPushSuccessGauge.With(labels).Set(0)
+		if stage.onError == sinkOnErrorIgnore {
+			log.Printf("failed to push to sink %s, ignoring; %v", stage.name, err)
+			continue
+		}
+		failures = append(failures, fmt.Sprintf("sink %s: %s", stage.name, err))
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// pushStage pushes the metrics selected by the filter of a stage to its sink, retrying the failed pushes.
+func (p *sinkPipeline) pushStage(stage sinkStage, gatherer prometheus.Gatherer, now time.Time) error {
+	if stage.filter != nil {
+		gatherer = filteredGatherer{gatherer: gatherer, filter: stage.filter}
+	}
+	err := stage.sink.push(gatherer, now)
+	for i := 0; err != nil && i < stage.retries; i++ {
+		time.Sleep(p.retryDelay)
+		err = stage.sink.push(gatherer, now)
+	}
+	return err
+}
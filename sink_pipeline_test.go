@@ -0,0 +1,149 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySink is a metricsSink failing its first pushes.
+type flakySink struct {
+	failures int
+	pushes   int
+}
+
+func (s *flakySink) push(gatherer prometheus.Gatherer, _ time.Time) error {
+	s.pushes++
+	if s.pushes <= s.failures {
+		return errors.New("unexpected status 503 Service Unavailable")
+	}
+	return nil
+}
+
+// TestSinkConfigCompile tests the defaults and the checks of the sinks of the configuration file.
+func TestSinkConfigCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  sinkConfig
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			config: sinkConfig{Type: sinkCloudWatch},
+			want:   sinkOnErrorReport,
+		},
+		{
+			name:   "ignored errors",
+			config: sinkConfig{Type: sinkInflux, URL: "http://influx:8086/write?db=rds", OnError: "ignore", Retries: 2},
+			want:   sinkOnErrorIgnore,
+		},
+		{
+			name:    "missing URL",
+			config:  sinkConfig{Type: sinkRemoteWrite},
+			wantErr: "the remote_write sink requires a sink URL",
+		},
+		{
+			name:    "invalid error handling",
+			config:  sinkConfig{Type: sinkCloudWatch, OnError: "retry"},
+			wantErr: `on_error should be either "report" or "ignore", got "retry"`,
+		},
+		{
+			name:    "too many retries",
+			config:  sinkConfig{Type: sinkCloudWatch, Retries: 10},
+			wantErr: "retries should be between 0 and 5, got 10",
+		},
+		{
+			name:    "invalid label regex",
+			config:  sinkConfig{Type: sinkCloudWatch, Labels: map[string]string{"engine": "aurora-("}},
+			wantErr: "invalid regex \"aurora-(\" of label engine; error parsing regexp: missing closing ): `^(?:aurora-()$`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.compile()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, tt.config.OnError)
+		})
+	}
+}
+
+// TestSinkPipelineFilter tests that each sink is pushed the series selected by its filter.
+func TestSinkPipelineFilter(t *testing.T) {
+	all, orders, none := &fakeSink{}, &fakeSink{}, &fakeSink{}
+	ordersConfig := sinkConfig{Type: sinkCloudWatch, Metrics: "aws_custom_rds_version_.*",
+		Labels: map[string]string{"cluster_identifier": "orders|users-.*", "region": ""}}
+	noneConfig := sinkConfig{Type: sinkCloudWatch, Metrics: "aws_custom_rds_version"}
+	assert.NoError(t, ordersConfig.compile())
+	assert.NoError(t, noneConfig.compile())
+	pipeline := &sinkPipeline{
+		stages: []sinkStage{
+			{name: "all", sink: all},
+			{name: "orders", sink: orders, filter: ordersConfig.filter},
+			{name: "none", sink: none, filter: noneConfig.filter},
+		},
+		metrics: NewMetrics(),
+	}
+
+	assert.NoError(t, pipeline.push(sinkGatherer("orders", "users-eu", "payments"), sinkNow))
+	assert.Equal(t, []int{3}, all.series)
+	assert.Equal(t, []int{2}, orders.series)
+	assert.Equal(t, []int{0}, none.series)
+}
+
+// TestSinkPipelineErrors tests that the failed pushes are retried, that a failed sink does not prevent the next ones
+// from being pushed to, and that only the failures of the sinks whose errors are reported are returned.
+func TestSinkPipelineErrors(t *testing.T) {
+	metrics := NewMetrics()
+	retried, ignored, reported := &flakySink{failures: 2}, &flakySink{failures: 1}, &flakySink{failures: 1}
+	pipeline := &sinkPipeline{
+		stages: []sinkStage{
+			{name: "retried", sink: retried, onError: sinkOnErrorReport, retries: 2},
+			{name: "ignored", sink: ignored, onError: sinkOnErrorIgnore},
+			{name: "reported", sink: reported, onError: sinkOnErrorReport},
+		},
+		metrics: metrics,
+	}
+
+	assert.EqualError(t, pipeline.push(sinkGatherer("orders"), sinkNow),
+		"sink reported: unexpected status 503 Service Unavailable")
+	assert.Equal(t, 3, retried.pushes)
+	assert.Equal(t, 1, ignored.pushes)
+	assert.Equal(t, 1, reported.pushes)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SinkPushSuccessGauge.WithLabelValues("retried")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.SinkPushSuccessGauge.WithLabelValues("ignored")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.SinkPushSuccessGauge.WithLabelValues("reported")))
+
+	assert.NoError(t, pipeline.push(sinkGatherer("orders"), sinkNow))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SinkPushSuccessGauge.WithLabelValues("reported")))
+}
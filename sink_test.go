@@ -0,0 +1,169 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// sinkNow is the time the metrics of the tests are pushed.
+var sinkNow = time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+
+// sinkGatherer returns a Gatherer of a gauge with a series per cluster identifier, whose value is 1, but for the last
+// one.
+func sinkGatherer(identifiers ...string) prometheus.Gatherer {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "aws_custom_rds_version_deprecated", Help: "help"},
+		[]string{"cluster_identifier", "region"})
+	for i, identifier := range identifiers {
+		value := 1.0
+		if i == len(identifiers)-1 {
+			value = 0
+		}
+		gauge.WithLabelValues(identifier, "").Set(value)
+	}
+	r := prometheus.NewRegistry()
+	r.MustRegister(gauge)
+	return r
+}
+
+// TestPushgatewaySink tests that the metrics replace the group of the job of the exporter and of the account.
+func TestPushgatewaySink(t *testing.T) {
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := &pushgatewaySink{url: server.URL, accountID: "111111111111", client: server.Client()}
+	assert.NoError(t, sink.push(sinkGatherer("orders"), sinkNow))
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/"+exporterName+"/instance/111111111111", path)
+	assert.NotEmpty(t, body)
+}
+
+// TestRemoteWriteSink tests the headers and the body of the remote write requests, and that the failures are
+// reported.
+func TestRemoteWriteSink(t *testing.T) {
+	status := http.StatusNoContent
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, body = r.Header, nil
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &remoteWriteSink{url: server.URL, client: server.Client()}
+	gatherer := sinkGatherer("orders")
+	assert.NoError(t, sink.push(gatherer, sinkNow))
+	assert.Equal(t, "snappy", header.Get("Content-Encoding"))
+	assert.Equal(t, "application/x-protobuf", header.Get("Content-Type"))
+	assert.Equal(t, "0.1.0", header.Get("X-Prometheus-Remote-Write-Version"))
+	families, err := gatherer.Gather()
+	assert.NoError(t, err)
+	assert.Equal(t, snappyEncode(encodeWriteRequest(families, sinkNow)), body)
+
+	status = http.StatusBadRequest
+	assert.EqualError(t, sink.push(gatherer, sinkNow),
+		"failed to send the metrics to the remote write endpoint; unexpected status 400 Bad Request")
+}
+
+// TestEncodeWriteRequest tests the protobuf encoding of a series, whose labels are sorted by name.
+func TestEncodeWriteRequest(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "help",
+		ConstLabels: prometheus.Labels{"job": "rds"}})
+	gauge.Set(1)
+	r := prometheus.NewRegistry()
+	r.MustRegister(gauge)
+	families, err := r.Gather()
+	assert.NoError(t, err)
+
+	want := []byte{
+		0x0a, 0x29, // timeseries, 41 bytes
+		0x0a, 0x0e, 0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_', 0x12, 0x02, 'u', 'p', // __name__="up"
+		0x0a, 0x0a, 0x0a, 0x03, 'j', 'o', 'b', 0x12, 0x03, 'r', 'd', 's', // job="rds"
+		0x12, 0x0b, 0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f, 0x10, 0x01, // value 1 at 1ms
+	}
+	assert.Equal(t, want, encodeWriteRequest(families, time.UnixMilli(1)))
+}
+
+// TestSnappyEncode tests that the data is encoded as literals of at most 65536 bytes, after its length.
+func TestSnappyEncode(t *testing.T) {
+	assert.Equal(t, []byte{0x03, 0xf4, 0x02, 0x00, 'a', 'b', 'c'}, snappyEncode([]byte("abc")))
+	assert.Equal(t, []byte{0x00}, snappyEncode(nil))
+
+	encoded := snappyEncode([]byte(strings.Repeat("a", 1<<16+1)))
+	assert.Equal(t, []byte{0x81, 0x80, 0x04, 0xf4, 0xff, 0xff}, encoded[:6])
+	assert.Equal(t, []byte{0xf4, 0x00, 0x00, 'a'}, encoded[len(encoded)-4:])
+}
+
+type MockCloudWatchAPI struct {
+	cloudwatchiface.CloudWatchAPI
+	inputs []*cloudwatch.PutMetricDataInput
+	err    error
+}
+
+func (m *MockCloudWatchAPI) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput,
+	error) {
+	m.inputs = append(m.inputs, input)
+	return &cloudwatch.PutMetricDataOutput{}, m.err
+}
+
+// TestCloudWatchSink tests that the series are put in batches, with their non-empty labels as dimensions.
+func TestCloudWatchSink(t *testing.T) {
+	identifiers := make([]string, cloudWatchBatchSize+2)
+	for i := range identifiers {
+		identifiers[i] = strings.Repeat("x", i+1)
+	}
+	client := &MockCloudWatchAPI{}
+	sink := &cloudWatchSink{client: client, namespace: defaultCloudWatchNamespace}
+	assert.NoError(t, sink.push(sinkGatherer(identifiers...), sinkNow))
+	assert.Len(t, client.inputs, 2)
+	assert.Len(t, client.inputs[0].MetricData, cloudWatchBatchSize)
+	assert.Len(t, client.inputs[1].MetricData, 2)
+	datum := client.inputs[0].MetricData[0]
+	assert.Equal(t, defaultCloudWatchNamespace, aws.StringValue(client.inputs[0].Namespace))
+	assert.Equal(t, "aws_custom_rds_version_deprecated", aws.StringValue(datum.MetricName))
+	assert.Equal(t, []*cloudwatch.Dimension{{Name: Ptr("cluster_identifier"), Value: Ptr("x")}}, datum.Dimensions)
+	assert.Equal(t, 1.0, aws.Float64Value(datum.Value))
+	assert.Equal(t, sinkNow, aws.TimeValue(datum.Timestamp))
+
+	client.err = errors.New("AccessDenied")
+	assert.EqualError(t, sink.push(sinkGatherer("orders"), sinkNow),
+		"failed to put the metrics into CloudWatch; AccessDenied")
+}
@@ -0,0 +1,493 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The collectors of the scopes, in the collector label of the ScopeStaleGauge and the ScopeLastSuccessGauge.
+const (
+	scopeCollectorRDS            = "rds"
+	scopeCollectorTrustedAdvisor = "trusted_advisor"
+)
+
+// scopeKey identifies a scope of a snapshot: a region of an account, or the Trusted Advisor checks of an account, whose
+// region is empty.
+type scopeKey struct {
+	collector string
+	roleARN   string
+	account   string
+	region    string
+}
+
+// staleScopesError is the error returned by snapshotScopes when scopes failed. The series and the inventory items of
+// their last successful snapshot, if any, are kept, so that the failures are not mistaken for deleted resources, and
+// the other scopes are exported as usual. It counts the failed scopes out of every scope of the snapshot.
+type staleScopesError struct {
+	err    error
+	failed int
+	total  int
+}
+
+func (e *staleScopesError) Error() string {
+	return e.err.Error()
+}
+
+func (e *staleScopesError) Unwrap() error {
+	return e.err
+}
+
+// partialSnapshot reports whether err is the error of a snapshot in which some scopes failed but others succeeded, e.g.
+// a single region of a multi-region snapshot, whose results are partial but current for the scopes that succeeded.
+func partialSnapshot(err error) bool {
+	var stale *staleScopesError
+	return errors.As(err, &stale) && stale.failed < stale.total
+}
+
+// seriesValue is a series of a gauge: its labels and its value, and once merged by apply, the time of the last
+// successful snapshot of its scope.
+type seriesValue struct {
+	labels prometheus.Labels
+	value  float64
+	at     time.Time
+}
+
+// scopeResult is what the last successful snapshot of a scope exported: the series of each gauge of snapshotGauges, in
+// the same order, the inventory items, and the time it succeeded.
+type scopeResult struct {
+	series [][]seriesValue
+	items  []inventoryItem
+	at     time.Time
+}
+
+// scopeResults holds the last successful result of each scope, and whether the last snapshot of each scope failed. The
+// snapshots of the scopes are taken into scratch Metrics, whose series replace the ones of their scope once it
+// succeeded, so that the resources only disappear from the metrics when a successful snapshot no longer finds them.
+type scopeResults struct {
+	mu      sync.Mutex
+	results map[scopeKey]*scopeResult
+	failed  map[scopeKey]bool
+}
+
+// newScopeResults returns an empty scopeResults.
+func newScopeResults() *scopeResults {
+	return &scopeResults{
+		results: make(map[scopeKey]*scopeResult),
+		failed:  make(map[scopeKey]bool),
+	}
+}
+
+// snapshotGaugeFields returns the addresses of the fields of the gauges holding the series of a snapshot, which are
+// exported per scope.
+func (m *Metrics) snapshotGaugeFields() []**prometheus.GaugeVec {
+	return []**prometheus.GaugeVec{
+		&m.AvailableGauge,
+		&m.DeprecatedGauge,
+		&m.AvailableTotalGauge,
+		&m.DeprecatedTotalGauge,
+		&m.DeprecatedRatioGauge,
+		&m.GraceGauge,
+		&m.AcknowledgedGauge,
+		&m.AcknowledgementExpiryGauge,
+		&m.GlobalClusterMemberGauge,
+		&m.InstanceClassDeprecatedGauge,
+		&m.MaintenanceWindowGauge,
+		&m.EngineVersionInfoGauge,
+		&m.EngineCapabilitiesGauge,
+		&m.UpgradeTargetsGauge,
+		&m.VersionStatusInfoGauge,
+		&m.MajorDeprecatedGauge,
+		&m.ParameterGroupFamilyDeprecatedGauge,
+		&m.StorageLegacyGauge,
+		&m.ClusterMemberCountGauge,
+		&m.ClusterMemberInfoGauge,
+		&m.ClusterVersionMismatchGauge,
+		&m.DBSnapshotDeprecatedGauge,
+		&m.ForcedUpgradeDeadlineGauge,
+		&m.StandardSupportDaysGauge,
+		&m.ExtendedSupportCostGauge,
+		&m.ExtendedSupportAccountCostGauge,
+		&m.RecommendedUpgradeGauge,
+		&m.ReservedInstanceEndGauge,
+		&m.ReservedInstanceDeprecatedGauge,
+		&m.HealthEventStartGauge,
+		&m.HealthEventEndGauge,
+		&m.TrustedAdvisorCheckGauge,
+		&m.TrustedAdvisorFlaggedResourceGauge,
+		&m.OwnerInfoGauge,
+		&m.StoppedGauge,
+		&m.IncompatibleStateGauge,
+		&m.DiscoveredGauge,
+		&m.CatalogEnginesGauge,
+		&m.CatalogVersionsGauge,
+	}
+}
+
+// snapshotGauges returns the gauges holding the series of a snapshot.
+func (m *Metrics) snapshotGauges() []*prometheus.GaugeVec {
+	fields := m.snapshotGaugeFields()
+	gauges := make([]*prometheus.GaugeVec, 0, len(fields))
+	for _, field := range fields {
+		gauges = append(gauges, *field)
+	}
+	return gauges
+}
+
+// servedGauges returns the gauges whose series apply builds from the results of the scopes: the snapshot gauges, the
+// ScopeStaleGauge and the ScopeLastSuccessGauge, in this order.
+func (m *Metrics) servedGauges() []*prometheus.GaugeVec {
+	return append(m.snapshotGauges(), m.ScopeStaleGauge, m.ScopeLastSuccessGauge)
+}
+
+// snapshotCollector collects the series of the served gauges as constant metrics, built by apply from the results of
+// the scopes and swapped atomically once they are complete: a scrape, or a push, sees either the previous snapshot or
+// the new one as a whole, and no series outlives the snapshot that exported it, whatever the labels of the gauges. The
+// served gauges are registered through it rather than one by one, and only describe the metrics: until a snapshot is
+// applied, their own series are collected, e.g. the ones of a single snapshot taken into them directly.
+// If timestamps is set, the series of each scope are stamped with the time of its last successful snapshot, so that
+// the series of the scopes that failed do not hold back the ones of the others.
+type snapshotCollector struct {
+	gauges     []*prometheus.GaugeVec
+	view       atomic.Pointer[[]prometheus.Metric]
+	timestamps bool
+}
+
+// newSnapshotCollector returns the snapshotCollector of the served gauges.
+func newSnapshotCollector(gauges []*prometheus.GaugeVec) *snapshotCollector {
+	return &snapshotCollector{gauges: gauges}
+}
+
+// swap replaces the metrics collected with the ones of a snapshot.
+func (c *snapshotCollector) swap(view []prometheus.Metric) {
+	c.view.Store(&view)
+}
+
+// Describe implements prometheus.Collector.
+func (c *snapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range c.gauges {
+		gauge.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	if view := c.view.Load(); view != nil {
+		for _, metric := range *view {
+			ch <- metric
+		}
+		return
+	}
+	for _, gauge := range c.gauges {
+		gauge.Collect(ch)
+	}
+}
+
+// describe returns the description of the metrics of a gauge.
+func describe(gauge *prometheus.GaugeVec) *prometheus.Desc {
+	ch := make(chan *prometheus.Desc, 1)
+	gauge.Describe(ch)
+	return <-ch
+}
+
+// variableLabels returns the n variable labels of desc, in their order: each label of a metric of desc whose label
+// values are their positions holds its position.
+func variableLabels(desc *prometheus.Desc, n int) []string {
+	values := make([]string, n)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	var pb dto.Metric
+	_ = prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 0, values...).Write(&pb)
+	names := make([]string, n)
+	for _, pair := range pb.GetLabel() {
+		if i, err := strconv.Atoi(pair.GetValue()); err == nil {
+			names[i] = pair.GetName()
+		}
+	}
+	return names
+}
+
+// seriesSet merges the series of a gauge across the scopes, in the order they are first added: the values of a series
+// either add up or replace each other, and the series keeps the latest time it was added at.
+type seriesSet struct {
+	additive bool
+	keys     []string
+	series   map[string]*seriesValue
+}
+
+// add adds a series to the set, exported by a scope at the given time, which is zero for the series that are not.
+func (s *seriesSet) add(labels prometheus.Labels, value float64, at time.Time) {
+	// maps are formatted sorted by key
+	key := fmt.Sprint(map[string]string(labels))
+	if existing, ok := s.series[key]; ok {
+		if s.additive {
+			existing.value += value
+		} else {
+			existing.value = value
+		}
+		if at.After(existing.at) {
+			existing.at = at
+		}
+		return
+	}
+	if s.series == nil {
+		s.series = make(map[string]*seriesValue)
+	}
+	s.keys = append(s.keys, key)
+	s.series[key] = &seriesValue{labels: labels, value: value, at: at}
+}
+
+// constMetrics returns the series of the set as constant metrics of desc, stamped with their time if timestamps is
+// set.
+func (s *seriesSet) constMetrics(desc *prometheus.Desc, timestamps bool) []prometheus.Metric {
+	if len(s.keys) == 0 {
+		return nil
+	}
+	names := variableLabels(desc, len(s.series[s.keys[0]].labels))
+	metrics := make([]prometheus.Metric, 0, len(s.keys))
+	for _, key := range s.keys {
+		series := s.series[key]
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = series.labels[name]
+		}
+		metric := prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, series.value, values...)
+		if timestamps && !series.at.IsZero() {
+			metric = prometheus.NewMetricWithTimestamp(series.at, metric)
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// scratch returns Metrics to snapshot a scope into: its snapshot gauges, Inventory and Fleet are new, and it shares the
+// rest with m, e.g. the counters, the SeriesGuard and the Debug inventory.
+func (m *Metrics) scratch() *Metrics {
+	scratch := *m
+	fresh := NewMetrics()
+	freshFields := fresh.snapshotGaugeFields()
+	for i, field := range scratch.snapshotGaugeFields() {
+		*field = *freshFields[i]
+	}
+	scratch.Inventory = fresh.Inventory
+	scratch.Fleet = fresh.Fleet
+	return &scratch
+}
+
+// dataAgeName is the name of the metric of the dataAgeCollector.
+const dataAgeName = "aws_custom_rds_data_age_seconds"
+
+// dataAgeCollector exports the age of the series of each scope, i.e. the number of seconds since its last successful
+// snapshot, computed at scrape time, so that queries can discount the data kept while AWS fails. The scopes that never
+// succeeded have no series.
+type dataAgeCollector struct {
+	results *scopeResults
+	desc    *prometheus.Desc
+	now     func() time.Time
+}
+
+// newDataAgeCollector returns the dataAgeCollector of the results.
+func newDataAgeCollector(results *scopeResults) *dataAgeCollector {
+	return &dataAgeCollector{
+		results: results,
+		desc: prometheus.NewDesc(dataAgeName, "Number of seconds since the last successful snapshot of a scope",
+			[]string{"collector", "account_id", "region"}, nil),
+		now: time.Now,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *dataAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *dataAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	c.results.mu.Lock()
+	defer c.results.mu.Unlock()
+	for key, result := range c.results.results {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, now.Sub(result.at).Seconds(),
+			key.collector, key.account, key.region)
+	}
+}
+
+// collectSeries returns the series of a gauge, sorted by labels, so that the series cap of the SeriesGuard leaves the
+// same series out from one snapshot to the next.
+func collectSeries(gauge *prometheus.GaugeVec) []seriesValue {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		gauge.Collect(ch)
+		close(ch)
+	}()
+	var series []seriesValue
+	for metric := range ch {
+		var pb dto.Metric
+		if err := metric.Write(&pb); err != nil {
+			continue
+		}
+		labels := make(prometheus.Labels, len(pb.GetLabel()))
+		for _, pair := range pb.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		series = append(series, seriesValue{labels: labels, value: pb.GetGauge().GetValue()})
+	}
+	keys := make([]string, len(series))
+	order := make([]int, len(series))
+	for i := range series {
+		// maps are formatted sorted by key
+		keys[i], order[i] = fmt.Sprint(map[string]string(series[i].labels)), i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+	sorted := make([]seriesValue, len(series))
+	for i, j := range order {
+		sorted[i] = series[j]
+	}
+	return sorted
+}
+
+// record records the outcome of the snapshot of a scope into the scratch Metrics: if err is nil, their series and
+// inventory items replace the ones of the scope, otherwise the previous ones are kept and the scope is marked failed.
+func (r *scopeResults) record(key scopeKey, scratch *Metrics, err error, now time.Time) {
+	var result *scopeResult
+	if err == nil {
+		result = &scopeResult{items: scratch.Inventory.list(), at: now}
+		for _, gauge := range scratch.snapshotGauges() {
+			result.series = append(result.series, collectSeries(gauge))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed[key] = err != nil
+	if result != nil {
+		r.results[key] = result
+	}
+}
+
+// failures returns the number of scopes whose last snapshot failed.
+func (r *scopeResults) failures() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, failed := range r.failed {
+		if failed {
+			n++
+		}
+	}
+	return n
+}
+
+// apply rebuilds the series of the served gauges and the Inventory of metrics from the results of the given scopes, in
+// their order, and forgets the other scopes, e.g. the regions no longer scanned after a reload of the configuration
+// file. Each scope is exported by the ScopeStaleGauge, set to 1 if its last snapshot failed, and by the
+// ScopeLastSuccessGauge if it ever succeeded.
+// The series are built as constant metrics swapped into the Snapshot collector of metrics at once, like the items of
+// the Inventory, so that the scrapes see either the previous snapshot or this one.
+// Every per-resource series goes through the SeriesGuard of metrics, if any, and the SeriesOverflowGauge is set to the
+// number of series it left out. The series of the version metrics and of the ExtendedSupportCostGauge that several
+// resources share because the SeriesGuard drops labels add up, and so do the costs of the regions of an account in the
+// ExtendedSupportAccountCostGauge, while the other series shared by several resources or scopes hold the value of the
+// last of them.
+func (r *scopeResults) apply(metrics *Metrics, keys []scopeKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wanted := make(map[scopeKey]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+	for key := range r.results {
+		if !wanted[key] {
+			delete(r.results, key)
+		}
+	}
+	for key := range r.failed {
+		if !wanted[key] {
+			delete(r.failed, key)
+		}
+	}
+
+	gauges := metrics.servedGauges()
+	sets := make([]seriesSet, len(gauges))
+	// the series of each gauge count against the series cap on their own, but the ones of the version metrics, which
+	// share their labels, and are grouped with the DeprecatedGauge
+	groups := make([]int, len(gauges))
+	guard := metrics.SeriesGuard
+	guard.reset()
+	dropsLabels := guard.dropsLabels()
+	for i, gauge := range gauges {
+		groups[i] = i
+		switch gauge {
+		case metrics.ExtendedSupportAccountCostGauge:
+			sets[i].additive = true
+		case metrics.ExtendedSupportCostGauge:
+			sets[i].additive = dropsLabels
+		case metrics.AvailableGauge, metrics.DeprecatedGauge, metrics.GraceGauge, metrics.AcknowledgedGauge:
+			sets[i].additive = dropsLabels
+			groups[i] = -1
+		}
+	}
+	stale, lastSuccess := &sets[len(sets)-2], &sets[len(sets)-1]
+	var items []inventoryItem
+	for _, key := range keys {
+		labels := prometheus.Labels{"collector": key.collector, "account_id": key.account, "region": key.region}
+		failed := 0.0
+		if r.failed[key] {
+			failed = 1
+		}
+		stale.add(labels, failed, time.Time{})
+		result, ok := r.results[key]
+		if !ok {
+			continue
+		}
+		lastSuccess.add(labels, float64(result.at.Unix()), time.Time{})
+		for i, series := range result.series {
+			for _, s := range series {
+				if labels, ok := guard.admit(groups[i], s.labels); ok {
+					sets[i].add(labels, s.value, result.at)
+				}
+			}
+		}
+		items = append(items, result.items...)
+	}
+	metrics.SeriesOverflowGauge.Set(float64(guard.overflowed()))
+
+	var view []prometheus.Metric
+	for i, gauge := range gauges {
+		view = append(view, sets[i].constMetrics(describe(gauge), metrics.Snapshot.timestamps)...)
+	}
+	metrics.Snapshot.swap(view)
+	metrics.Inventory.replace(items)
+}
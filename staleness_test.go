@@ -0,0 +1,307 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stalenessNow is the time the scopes of the tests succeed.
+var stalenessNow = time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)
+
+// stalenessRDSAPI returns a MockRDSAPI listing a postgres 14.7 instance for each identifier.
+func stalenessRDSAPI(identifiers ...string) MockRDSAPI {
+	instances := make([]*rds.DBInstance, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		instances = append(instances, &rds.DBInstance{
+			DBInstanceIdentifier: Ptr(identifier),
+			Engine:               Ptr("postgres"),
+			EngineVersion:        Ptr("14.7"),
+		})
+	}
+	return MockRDSAPI{
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{DBInstances: instances}},
+		clustersOutput:  []*rds.DescribeDBClustersOutput{{}},
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{DBEngineVersions: []*rds.DBEngineVersion{{
+			Engine:        Ptr("postgres"),
+			EngineVersion: Ptr("14.7"),
+			Status:        Ptr("available"),
+		}}}},
+	}
+}
+
+// servedValue returns the value of the series of gauge with the given label values collected by the Snapshot collector
+// of metrics, or NaN if it collects none.
+func servedValue(metrics *Metrics, gauge *prometheus.GaugeVec, values ...string) float64 {
+	desc := describe(gauge)
+	var want dto.Metric
+	_ = prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 0, values...).Write(&want)
+	ch := make(chan prometheus.Metric)
+	go func() {
+		metrics.Snapshot.Collect(ch)
+		close(ch)
+	}()
+	value := math.NaN()
+	for metric := range ch {
+		var pb dto.Metric
+		if metric.Desc() == desc && metric.Write(&pb) == nil &&
+			fmt.Sprint(pb.GetLabel()) == fmt.Sprint(want.GetLabel()) {
+			value = pb.GetGauge().GetValue()
+		}
+	}
+	return value
+}
+
+// TestSnapshotScopesStaleness tests that a region whose snapshot fails keeps the series and the inventory items of its
+// last successful snapshot, flagged as stale, while the resources of the other regions that are gone disappear.
+func TestSnapshotScopesStaleness(t *testing.T) {
+	euWest1 := &Config{Region: "eu-west-1", Concurrency: 1, RDS: stalenessRDSAPI("orders", "users")}
+	usEast1 := &Config{Region: "us-east-1", Concurrency: 1, RDS: stalenessRDSAPI("billing", "legacy")}
+	scopes := testScopes(euWest1, usEast1)
+	metrics := NewMetrics()
+	series := func(identifier, region string) float64 {
+		return servedValue(metrics, metrics.AvailableGauge,
+			identifier, "postgres", "14.7", "14", "7", "", "", "", "false", "false", "", euWest1.account(), region)
+	}
+	stale := func(region string) float64 {
+		return servedValue(metrics, metrics.ScopeStaleGauge, scopeCollectorRDS, euWest1.account(), region)
+	}
+
+	assert.NoError(t, snapshotScopes(scopes, metrics, newCatalogs(scopes)))
+	assert.Equal(t, 4, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_available"))
+	assert.Equal(t, 0.0, stale("eu-west-1"))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_scope_last_success_timestamp_seconds"))
+
+	// eu-west-1 fails and legacy is deleted from us-east-1
+	euWest1.RDS = MockRDSAPI{err: errors.New("Throttling")}
+	usEast1.RDS = stalenessRDSAPI("billing")
+	err := snapshotScopes(scopes, metrics, newCatalogs(scopes))
+	var staleErr *staleScopesError
+	assert.True(t, errors.As(err, &staleErr))
+	assert.True(t, partialSnapshot(err))
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_available"))
+	assert.Equal(t, 1.0, series("orders", "eu-west-1"))
+	assert.Equal(t, 1.0, series("users", "eu-west-1"))
+	assert.Equal(t, 1.0, series("billing", "us-east-1"))
+	assert.Equal(t, 1.0, stale("eu-west-1"))
+	assert.Equal(t, 0.0, stale("us-east-1"))
+	assert.Len(t, metrics.Inventory.list(), 3)
+
+	// users is confirmed gone once eu-west-1 succeeds again
+	euWest1.RDS = stalenessRDSAPI("orders")
+	assert.NoError(t, snapshotScopes(scopes, metrics, newCatalogs(scopes)))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_version_available"))
+	assert.Equal(t, 0.0, stale("eu-west-1"))
+	assert.Len(t, metrics.Inventory.list(), 2)
+}
+
+// TestScopeResultsApply tests that the scopes are forgotten once they are no longer snapshotted, and that a scope
+// that never succeeded is flagged as stale without last success.
+func TestScopeResultsApply(t *testing.T) {
+	metrics := NewMetrics()
+	scratch := metrics.scratch()
+	scratch.StoppedGauge.WithLabelValues("orders", "111111111111", "eu-west-1").Set(1)
+	euWest1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "eu-west-1"}
+	usEast1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "us-east-1"}
+
+	metrics.Staleness.record(euWest1, scratch, nil, stalenessNow)
+	metrics.Staleness.record(usEast1, metrics.scratch(), errors.New("AccessDenied"), stalenessNow)
+	metrics.Staleness.apply(metrics, []scopeKey{euWest1, usEast1})
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_stopped"))
+	assert.Equal(t, 1.0, servedValue(metrics, metrics.ScopeStaleGauge,
+		scopeCollectorRDS, "111111111111", "us-east-1"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_scope_last_success_timestamp_seconds"))
+	assert.Equal(t, float64(stalenessNow.Unix()), servedValue(metrics, metrics.ScopeLastSuccessGauge,
+		scopeCollectorRDS, "111111111111", "eu-west-1"))
+
+	metrics.Staleness.apply(metrics, []scopeKey{usEast1})
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_stopped"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_scope_stale"))
+}
+
+// TestScopeResultsApplyAccountCost tests that the Extended Support cost of an account adds up the costs of its regions.
+func TestScopeResultsApplyAccountCost(t *testing.T) {
+	metrics := NewMetrics()
+	var keys []scopeKey
+	for _, region := range []string{"eu-west-1", "us-east-1"} {
+		key := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: region}
+		scratch := metrics.scratch()
+		scratch.ExtendedSupportAccountCostGauge.WithLabelValues("111111111111", "true").Set(146)
+		metrics.Staleness.record(key, scratch, nil, stalenessNow)
+		keys = append(keys, key)
+	}
+	metrics.Staleness.apply(metrics, keys)
+	assert.Equal(t, 292.0, servedValue(metrics, metrics.ExtendedSupportAccountCostGauge, "111111111111", "true"))
+}
+
+// TestScopeResultsApplySwap tests that the gauges rebuilt by apply are swapped at once: the scrapes taken while the
+// snapshots are applied see every series of a snapshot, and never a partially rebuilt one.
+func TestScopeResultsApplySwap(t *testing.T) {
+	metrics := NewMetrics()
+	gatherer := newGatherer(metrics, nil, nil)
+	scratch := metrics.scratch()
+	for i := 0; i < 50; i++ {
+		scratch.StoppedGauge.WithLabelValues(fmt.Sprintf("orders-%d", i), "111111111111", "eu-west-1").Set(1)
+	}
+	euWest1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "eu-west-1"}
+	metrics.Staleness.record(euWest1, scratch, nil, stalenessNow)
+	metrics.Staleness.apply(metrics, []scopeKey{euWest1})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			metrics.Staleness.apply(metrics, []scopeKey{euWest1})
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		families, err := gatherer.Gather()
+		assert.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() == "aws_custom_rds_stopped" {
+				assert.Len(t, family.GetMetric(), 50)
+			}
+		}
+	}
+	wg.Wait()
+	assert.Equal(t, 50, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_stopped"))
+}
+
+// TestSeriesSet tests that the series of a gauge shared by several scopes add up or replace each other, and that they
+// are built as constant metrics with their labels in the order of the gauge.
+func TestSeriesSet(t *testing.T) {
+	metrics := NewMetrics()
+	euWest1 := prometheus.Labels{"cluster_identifier": "orders", "account_id": "111111111111", "region": "eu-west-1"}
+	usEast1 := prometheus.Labels{"region": "us-east-1", "account_id": "111111111111", "cluster_identifier": "orders"}
+	for _, tt := range []struct {
+		name     string
+		additive bool
+		want     float64
+	}{
+		{name: "replaced", want: 2},
+		{name: "additive", additive: true, want: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			set := seriesSet{additive: tt.additive}
+			set.add(euWest1, 1, stalenessNow)
+			set.add(usEast1, 1, stalenessNow)
+			set.add(prometheus.Labels{"cluster_identifier": "orders", "account_id": "111111111111", "region": "eu-west-1"}, 2,
+				stalenessNow)
+			metrics.Snapshot.swap(set.constMetrics(describe(metrics.StoppedGauge), false))
+			assert.Equal(t, 2, testutil.CollectAndCount(metrics.Snapshot, "aws_custom_rds_stopped"))
+			assert.Equal(t, tt.want, servedValue(metrics, metrics.StoppedGauge, "orders", "111111111111", "eu-west-1"))
+			assert.Equal(t, 1.0, servedValue(metrics, metrics.StoppedGauge, "orders", "111111111111", "us-east-1"))
+		})
+	}
+}
+
+// TestScopeResultsApplyTimestamps tests that the series of each scope are stamped with the time of its last successful
+// snapshot, while the other series are stamped with the start time of the last snapshot.
+func TestScopeResultsApplyTimestamps(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Snapshot.timestamps = true
+	clock := &snapshotClock{}
+	gatherer := newGatherer(metrics, clock, nil)
+	euWest1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "eu-west-1"}
+	usEast1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "us-east-1"}
+	for _, key := range []scopeKey{euWest1, usEast1} {
+		scratch := metrics.scratch()
+		scratch.StoppedGauge.WithLabelValues("orders", key.account, key.region).Set(1)
+		metrics.Staleness.record(key, scratch, nil, stalenessNow)
+	}
+	// us-east-1 fails, and keeps the series of its last successful snapshot
+	metrics.Staleness.record(euWest1, metrics.scratch(), nil, stalenessNow.Add(time.Hour))
+	metrics.Staleness.record(usEast1, metrics.scratch(), errors.New("Throttling"), stalenessNow.Add(time.Hour))
+	metrics.Staleness.apply(metrics, []scopeKey{euWest1, usEast1})
+	clock.set(stalenessNow.Add(time.Hour))
+
+	families, err := gatherer.Gather()
+	assert.NoError(t, err)
+	timestamps := make(map[string]int64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "region" {
+					timestamps[family.GetName()+" "+pair.GetValue()] = metric.GetTimestampMs()
+				}
+			}
+		}
+	}
+	// the data age is computed at scrape time, and not stamped
+	assert.Equal(t, map[string]int64{
+		"aws_custom_rds_data_age_seconds eu-west-1":                     0,
+		"aws_custom_rds_data_age_seconds us-east-1":                     0,
+		"aws_custom_rds_stopped us-east-1":                              stalenessNow.UnixMilli(),
+		"aws_custom_rds_scope_last_success_timestamp_seconds eu-west-1": stalenessNow.Add(time.Hour).UnixMilli(),
+		"aws_custom_rds_scope_last_success_timestamp_seconds us-east-1": stalenessNow.Add(time.Hour).UnixMilli(),
+		"aws_custom_rds_scope_stale eu-west-1":                          stalenessNow.Add(time.Hour).UnixMilli(),
+		"aws_custom_rds_scope_stale us-east-1":                          stalenessNow.Add(time.Hour).UnixMilli(),
+	}, timestamps)
+}
+
+// TestSnapshotInventoryPartial tests that the inventory of a partial snapshot holds the resources of the regions that
+// succeeded, along with the error of the ones that failed.
+func TestSnapshotInventoryPartial(t *testing.T) {
+	euWest1 := &Config{Region: "eu-west-1", Concurrency: 1, RDS: MockRDSAPI{err: errors.New("Throttling")}}
+	usEast1 := &Config{Region: "us-east-1", Concurrency: 1, RDS: stalenessRDSAPI("billing")}
+
+	items, _, err := snapshotInventory(testScopes(euWest1, usEast1))
+	assert.True(t, partialSnapshot(err))
+	assert.Len(t, items, 1)
+
+	euWest1.RDS, usEast1.RDS = MockRDSAPI{err: errors.New("Throttling")}, MockRDSAPI{err: errors.New("Throttling")}
+	items, _, err = snapshotInventory(testScopes(euWest1, usEast1))
+	assert.Error(t, err)
+	assert.False(t, partialSnapshot(err))
+	assert.Nil(t, items)
+}
+
+// TestDataAgeCollector tests that the data age of each scope is the time since its last successful snapshot, even if
+// its last snapshot failed, and that the scopes that never succeeded have none.
+func TestDataAgeCollector(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.DataAge.now = func() time.Time { return stalenessNow.Add(90 * time.Second) }
+	euWest1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "eu-west-1"}
+	usEast1 := scopeKey{collector: scopeCollectorRDS, account: "111111111111", region: "us-east-1"}
+
+	metrics.Staleness.record(euWest1, metrics.scratch(), nil, stalenessNow)
+	metrics.Staleness.record(euWest1, metrics.scratch(), errors.New("Throttling"), stalenessNow.Add(time.Minute))
+	metrics.Staleness.record(usEast1, metrics.scratch(), errors.New("AccessDenied"), stalenessNow)
+	assert.NoError(t, testutil.CollectAndCompare(metrics.DataAge, strings.NewReader(`
+# HELP aws_custom_rds_data_age_seconds Number of seconds since the last successful snapshot of a scope
+# TYPE aws_custom_rds_data_age_seconds gauge
+aws_custom_rds_data_age_seconds{account_id="111111111111",collector="rds",region="eu-west-1"} 90
+`)))
+}
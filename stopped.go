@@ -0,0 +1,52 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// statusStopped is the status of the RDS clusters and instances that are stopped. Their engine version cannot be
+// upgraded until they are started, and AWS starts them automatically after seven days.
+const statusStopped = "stopped"
+
+// excludeStopped returns the RDSInfos that are not stopped.
+func excludeStopped(rdsInfos []RDSInfo) []RDSInfo {
+	kept := make([]RDSInfo, 0, len(rdsInfos))
+	for _, rdsInfo := range rdsInfos {
+		if rdsInfo.Status != statusStopped {
+			kept = append(kept, rdsInfo)
+		}
+	}
+	return kept
+}
+
+// exportStopped sets the StoppedGauge of an RDS resource to 1 if it is stopped. Nothing is exported for the other ones.
+func exportStopped(metrics *Metrics, rdsInfo RDSInfo) {
+	if rdsInfo.Status != statusStopped {
+		return
+	}
+	metrics.StoppedGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(1)
+}
@@ -0,0 +1,66 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshotStopped tests that the stopped instances are flagged, or skipped when ExcludeStopped is enabled.
+func TestSnapshotStopped(t *testing.T) {
+	newConfig := func(excludeStopped bool) *Config {
+		return &Config{Region: "eu-west-1", Concurrency: 1, ExcludeStopped: excludeStopped, RDS: &MockRDSAPI{
+			clustersOutput: []*rds.DescribeDBClustersOutput{{}},
+			instancesOutput: []*rds.DescribeDBInstancesOutput{{DBInstances: []*rds.DBInstance{
+				{DBInstanceIdentifier: Ptr("users"), Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.41"),
+					DBInstanceStatus: Ptr("available")},
+				{DBInstanceIdentifier: Ptr("legacy-cms"), Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.41"),
+					DBInstanceStatus: Ptr("stopped")},
+			}}},
+			engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{DBEngineVersions: []*rds.DBEngineVersion{
+				{Engine: Ptr("mysql"), EngineVersion: Ptr("5.7.41"), Status: Ptr("deprecated")},
+			}}},
+		}}
+	}
+
+	metrics := NewMetrics()
+	assert.NoError(t, snapshot(newConfig(false), metrics, make(engineVersions)))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.DeprecatedGauge))
+	want := `# HELP aws_custom_rds_stopped Stopped clusters and instances, whose engine version cannot be upgraded until they are started
+# TYPE aws_custom_rds_stopped gauge
+aws_custom_rds_stopped{account_id="default",cluster_identifier="legacy-cms",region="eu-west-1"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.StoppedGauge, strings.NewReader(want)))
+
+	metrics = NewMetrics()
+	assert.NoError(t, snapshot(newConfig(true), metrics, make(engineVersions)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.DeprecatedGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.DeprecatedGauge.WithLabelValues(
+		"users", "mysql", "5.7.41", "5.7", "41", "", "", "", "false", "false", "", "default", "eu-west-1")))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.StoppedGauge))
+}
@@ -0,0 +1,59 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// legacyStorageTypes is the set of storage types of RDS instances superseded by newer ones: the "gp2" General Purpose
+// SSD by "gp3", and the "standard" magnetic storage by any SSD storage type.
+var legacyStorageTypes = map[string]bool{
+	"gp2":      true,
+	"standard": true,
+}
+
+// exportStorage sets the StorageLegacyGauge of an RDS instance to 1 if its storage type is a legacy one, and to 0
+// otherwise, labelled with its storage type, provisioned IOPS and allocated storage in GiB. Clusters are skipped, as
+// their storage is described by their member instances.
+func exportStorage(metrics *Metrics, rdsInfo RDSInfo) {
+	if rdsInfo.StorageType == "" {
+		return
+	}
+	legacy := 0.0
+	if legacyStorageTypes[rdsInfo.StorageType] {
+		legacy = 1
+	}
+	metrics.StorageLegacyGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"engine":             rdsInfo.Engine,
+		"engine_version":     rdsInfo.EngineVersion,
+		"storage_type":       rdsInfo.StorageType,
+		"iops":               strconv.FormatInt(rdsInfo.Iops, 10),
+		"allocated_storage":  strconv.FormatInt(rdsInfo.AllocatedStorage, 10),
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(legacy)
+}
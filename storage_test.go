@@ -0,0 +1,65 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExportStorage tests that the storage of the instances is read from DescribeDBInstances, and that the instances
+// with a legacy storage type are flagged.
+func TestExportStorage(t *testing.T) {
+	rdsInfos := handleRDSInstances(&rds.DescribeDBInstancesOutput{DBInstances: []*rds.DBInstance{
+		{
+			DBInstanceIdentifier: aws.String("db-1"), Engine: aws.String("postgres"), EngineVersion: aws.String("13.7"),
+			StorageType: aws.String("gp2"), AllocatedStorage: aws.Int64(100),
+		},
+		{
+			DBInstanceIdentifier: aws.String("db-2"), Engine: aws.String("postgres"), EngineVersion: aws.String("13.7"),
+			StorageType: aws.String("gp3"), Iops: aws.Int64(3000), AllocatedStorage: aws.Int64(400),
+		},
+		{
+			DBInstanceIdentifier: aws.String("db-3"), Engine: aws.String("mysql"), EngineVersion: aws.String("5.7.38"),
+			StorageType: aws.String("standard"), AllocatedStorage: aws.Int64(20),
+		},
+	}})
+	metrics := NewMetrics()
+	for _, rdsInfo := range rdsInfos {
+		exportStorage(metrics, rdsInfo)
+	}
+	// clusters are skipped
+	exportStorage(metrics, RDSInfo{ClusterIdentifier: "cluster-1", Engine: "aurora-postgresql", EngineVersion: "13.7"})
+
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.StorageLegacyGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.StorageLegacyGauge.WithLabelValues(
+		"db-1", "postgres", "13.7", "gp2", "0", "100", "", "")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.StorageLegacyGauge.WithLabelValues(
+		"db-2", "postgres", "13.7", "gp3", "3000", "400", "", "")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.StorageLegacyGauge.WithLabelValues(
+		"db-3", "mysql", "5.7.38", "standard", "0", "20", "", "")))
+}
@@ -0,0 +1,69 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// panicError is returned in place of a panic recovered from a snapshot, or from one of its worker pool tasks.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+// Error implements the error interface.
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// recoverPanic recovers from a panic and stores it as a *panicError in err. It must be called with defer.
+func recoverPanic(err *error) {
+	if v := recover(); v != nil {
+		*err = &panicError{value: v, stack: debug.Stack()}
+	}
+}
+
+// supervisedSnapshot runs snapshotScopes and recovers from the panics it raises, including the ones raised in the worker
+// pool goroutines. A recovered panic is logged with its stack and counted by the SnapshotPanicsCounter, and returned as
+// a *panicError, so that the caller can keep the snapshot loop alive instead of crashing the whole exporter. Panics and
+// snapshot failures are reported by the Reporter of the scopes, if any.
+func supervisedSnapshot(scopes *Scopes, metrics *Metrics, catalogs map[*Config]engineVersions) error {
+	err := func() (err error) {
+		defer recoverPanic(&err)
+		return snapshotScopes(scopes, metrics, catalogs)
+	}()
+
+	var p *panicError
+	if errors.As(err, &p) {
+		log.Printf("recovered from panic during snapshot: %v\n%s", p.value, p.stack)
+		metrics.SnapshotPanicsCounter.Inc()
+	}
+	if err != nil {
+		scopes.Reporter.report(err)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestSupervisedSnapshot tests the supervisedSnapshot function.
+func TestSupervisedSnapshot(t *testing.T) {
+	// an instance without engine makes handleRDSInstances dereference a nil pointer in a worker pool goroutine
+	config := &Config{RDS: &MockRDSAPI{
+		instancesOutput: []*rds.DescribeDBInstancesOutput{{
+			DBInstances: []*rds.DBInstance{{DBInstanceIdentifier: Ptr("db-1")}},
+		}},
+	}}
+	metrics := NewMetrics()
+
+	scopes := testScopes(config)
+	err := supervisedSnapshot(scopes, metrics, newCatalogs(scopes))
+	var p *panicError
+	assert.True(t, errors.As(err, &p))
+	assert.NotEmpty(t, p.stack)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SnapshotPanicsCounter))
+
+	// errors are returned as is, and are not counted as panics
+	config = &Config{RDS: &MockRDSAPI{err: errors.New("failed")}}
+	scopes = testScopes(config)
+	err = supervisedSnapshot(scopes, metrics, newCatalogs(scopes))
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &p))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SnapshotPanicsCounter))
+
+	// a region panicking in its own task fails alone, and the snapshot is partial
+	panicking := &Config{Region: "eu-west-1", CatalogInfo: true, RDS: &MockRDSAPI{
+		engineVersionsOutput: []*rds.DescribeDBEngineVersionsOutput{{
+			DBEngineVersions: []*rds.DBEngineVersion{{Engine: Ptr("postgres")}},
+		}},
+	}}
+	healthy := &Config{Region: "us-east-1", RDS: &MockRDSAPI{}}
+	metrics = NewMetrics()
+	scopes = testScopes(panicking, healthy)
+	err = supervisedSnapshot(scopes, metrics, newCatalogs(scopes))
+	assert.True(t, errors.As(err, &p))
+	assert.True(t, partialSnapshot(err))
+	assert.Equal(t, 1, metrics.Staleness.failures())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.SnapshotPanicsCounter))
+}
@@ -0,0 +1,192 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultSupportCalendarURL is where the update-calendar subcommand downloads the latest support calendar from: the
+// one of the main branch of the repository.
+const defaultSupportCalendarURL = "https://raw.githubusercontent.com/alexandremahdhaoui/" +
+	"prometheus-exporter-aws-rds-engine-version/main/support_calendar.yaml"
+
+// embeddedSupportCalendarData is the support calendar of the exporter, maintained in support_calendar.yaml.
+//
+//go:embed support_calendar.yaml
+var embeddedSupportCalendarData []byte
+
+// embeddedSupportCalendar is the parsed support calendar of the exporter.
+var embeddedSupportCalendar = mustParseSupportCalendar(embeddedSupportCalendarData)
+
+// supportCalendarEntry is the end date of the RDS standard support of a major version of some engines, after which AWS
+// upgrades the resources running it, enrolls them in Extended Support, or blocks the restore of their snapshots.
+type supportCalendarEntry struct {
+	Engines            []string  `yaml:"engines"`
+	Version            string    `yaml:"version"`
+	StandardSupportEnd time.Time `yaml:"standard_support_end"`
+}
+
+// supportCalendar lists the end dates of the RDS standard support of the major versions of the engines.
+type supportCalendar []supportCalendarEntry
+
+// parseSupportCalendar parses a support calendar, rejecting the unknown keys and the incomplete entries.
+func parseSupportCalendar(data []byte) (supportCalendar, error) {
+	var calendar supportCalendar
+	if err := yaml.UnmarshalStrict(data, &calendar); err != nil {
+		return nil, fmt.Errorf("failed to parse support calendar; %w", err)
+	}
+	for i, entry := range calendar {
+		if len(entry.Engines) == 0 || entry.Version == "" || entry.StandardSupportEnd.IsZero() {
+			return nil, fmt.Errorf("invalid support calendar entry %d: engines, version and standard_support_end "+
+				"should be set", i+1)
+		}
+	}
+	return calendar, nil
+}
+
+// mustParseSupportCalendar parses the embedded support calendar. It panics if the calendar is invalid, which is caught
+// by the tests.
+func mustParseSupportCalendar(data []byte) supportCalendar {
+	calendar, err := parseSupportCalendar(data)
+	if err != nil {
+		panic(err)
+	}
+	return calendar
+}
+
+// loadSupportCalendar reads the support calendar at path, e.g. written by the update-calendar subcommand, or returns the
+// embedded one if path is empty.
+func loadSupportCalendar(path string) (supportCalendar, error) {
+	if path == "" {
+		return embeddedSupportCalendar, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read support calendar; %w", err)
+	}
+	return parseSupportCalendar(b)
+}
+
+// standardSupportEnd returns the end date of the standard support of an engine version, if the calendar has an entry
+// for its engine and major version.
+func (c supportCalendar) standardSupportEnd(engine, engineVersion string) (time.Time, bool) {
+	for _, entry := range c {
+		if contains(entry.Engines, engine) && matchesVersion(entry.Version, engineVersion) {
+			return entry.StandardSupportEnd, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchesVersion reports whether an engine version matches the version of an entry: it is the version of the entry,
+// or starts with it followed by a dot.
+func matchesVersion(version, engineVersion string) bool {
+	return engineVersion == version || strings.HasPrefix(engineVersion, version+".")
+}
+
+// exportStandardSupport sets the StandardSupportDaysGauge of an RDS resource to the number of whole days left until
+// the end of the standard support of its engine version, negative once it is over, computed at the given time. Nothing
+// is exported for the versions missing from the calendar.
+func exportStandardSupport(metrics *Metrics, rdsInfo RDSInfo, calendar supportCalendar, now time.Time) {
+	end, ok := calendar.standardSupportEnd(rdsInfo.Engine, rdsInfo.EngineVersion)
+	if !ok {
+		return
+	}
+	metrics.StandardSupportDaysGauge.With(prometheus.Labels{
+		"cluster_identifier": rdsInfo.ClusterIdentifier,
+		"engine":             rdsInfo.Engine,
+		"engine_version":     rdsInfo.EngineVersion,
+		"account_id":         rdsInfo.Account,
+		"region":             rdsInfo.Region,
+	}).Set(math.Floor(end.Sub(now).Hours() / 24))
+}
+
+// runUpdateCalendar is the "update-calendar" subcommand. It downloads the support calendar from the source URL,
+// validates it, and writes it to the output file, for the exporter to load it with the support calendar file option
+// instead of the calendar it was built with. The output file is replaced atomically.
+func runUpdateCalendar(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("update-calendar", flag.ContinueOnError)
+	source := fs.String("source", defaultSupportCalendarURL, "the URL to download the support calendar from")
+	output := fs.String("output", "support_calendar.yaml", "the file to write the support calendar to")
+	timeout := fs.Duration("timeout", defaultAwsApiTimeout, "the timeout of the download")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := downloadSupportCalendar(&http.Client{Timeout: *timeout}, *source)
+	if err != nil {
+		return err
+	}
+	calendar, err := parseSupportCalendar(data)
+	if err != nil {
+		return fmt.Errorf("failed to update support calendar from %s; %w", *source, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".support_calendar-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write support calendar; %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write support calendar; %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write support calendar; %w", err)
+	}
+	if err := os.Rename(tmp.Name(), *output); err != nil {
+		return fmt.Errorf("failed to write support calendar; %w", err)
+	}
+	_, err = fmt.Fprintf(w, "support calendar: %d entries downloaded from %s, written to %s\n",
+		len(calendar), *source, *output)
+	return err
+}
+
+// downloadSupportCalendar returns the body of a GET request to url, which should respond 200.
+func downloadSupportCalendar(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download support calendar; %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download support calendar from %s; unexpected status %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download support calendar; %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEmbeddedSupportCalendar tests that the embedded support calendar is sorted and covers every engine family.
+func TestEmbeddedSupportCalendar(t *testing.T) {
+	calendar := embeddedSupportCalendar
+	assert.True(t, sort.SliceIsSorted(calendar, func(i, j int) bool {
+		if a, b := strings.Join(calendar[i].Engines, ","), strings.Join(calendar[j].Engines, ","); a != b {
+			return a < b
+		}
+		return calendar[i].Version < calendar[j].Version
+	}))
+	for _, engine := range []string{"mysql", "postgres", "mariadb", "oracle-ee", "sqlserver-se"} {
+		found := false
+		for _, entry := range calendar {
+			found = found || contains(entry.Engines, engine)
+		}
+		assert.True(t, found, "no entry for %s", engine)
+	}
+}
+
+// TestStandardSupportEnd tests that the engine versions match the entries of their major version.
+func TestStandardSupportEnd(t *testing.T) {
+	tests := []struct {
+		engine        string
+		engineVersion string
+		want          time.Time
+		wantOk        bool
+	}{
+		{"mysql", "5.7.38", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{"aurora-mysql", "5.7.mysql_aurora.2.07.2", time.Date(2024, 10, 31, 0, 0, 0, 0, time.UTC), true},
+		{"postgres", "11", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{"sqlserver-se", "14.00.3421.10.v1", time.Date(2027, 10, 12, 0, 0, 0, 0, time.UTC), true},
+		{"oracle-se2", "12.1.0.2.v31", time.Date(2022, 7, 31, 0, 0, 0, 0, time.UTC), true},
+		{"postgres", "110.1", time.Time{}, false},
+		{"mysql", "8.4.0", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.engine+" "+tt.engineVersion, func(t *testing.T) {
+			got, ok := embeddedSupportCalendar.standardSupportEnd(tt.engine, tt.engineVersion)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+// TestParseSupportCalendar tests that parseSupportCalendar rejects the unknown keys and the incomplete entries.
+func TestParseSupportCalendar(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "- engines: [postgres]\n  version: \"14\"\n  standard_support_end: 2027-02-28\n"},
+		{
+			name:    "unknown key",
+			data:    "- engine: postgres\n  version: \"14\"\n  standard_support_end: 2027-02-28\n",
+			wantErr: "failed to parse support calendar; yaml: unmarshal errors:\n  line 1: field engine not found in type main.supportCalendarEntry",
+		},
+		{
+			name:    "incomplete",
+			data:    "- engines: [postgres]\n  version: \"14\"\n",
+			wantErr: "invalid support calendar entry 1: engines, version and standard_support_end should be set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSupportCalendar([]byte(tt.data))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestExportStandardSupport tests that exportStandardSupport exports the whole days left until the end of standard
+// support, negative once it is over, and nothing for the versions missing from the calendar.
+func TestExportStandardSupport(t *testing.T) {
+	now := time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "legacy-cms", Engine: "mysql", EngineVersion: "5.7.38", Region: "eu-west-1"},
+		{ClusterIdentifier: "old-cms", Engine: "mysql", EngineVersion: "5.6.51", Region: "eu-west-1"},
+		{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "15.3", Region: "eu-west-1"},
+	} {
+		exportStandardSupport(metrics, rdsInfo, embeddedSupportCalendar, now)
+	}
+
+	want := `# HELP aws_custom_rds_days_until_standard_support_end Number of days left until the end of the standard support of the engine version, negative once over
+# TYPE aws_custom_rds_days_until_standard_support_end gauge
+aws_custom_rds_days_until_standard_support_end{account_id="",cluster_identifier="legacy-cms",engine="mysql",engine_version="5.7.38",region="eu-west-1"} 27
+aws_custom_rds_days_until_standard_support_end{account_id="",cluster_identifier="old-cms",engine="mysql",engine_version="5.6.51",region="eu-west-1"} -703
+`
+	assert.NoError(t, testutil.CollectAndCompare(metrics.StandardSupportDaysGauge, strings.NewReader(want)))
+}
+
+// TestRunUpdateCalendar tests that the update-calendar subcommand writes the downloaded calendar, and leaves the output
+// file untouched when the download fails or the calendar is invalid.
+func TestRunUpdateCalendar(t *testing.T) {
+	calendar := "- engines: [postgres]\n  version: \"14\"\n  standard_support_end: 2027-02-28\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/valid.yaml":
+			_, _ = w.Write([]byte(calendar))
+		case "/invalid.yaml":
+			_, _ = w.Write([]byte("- engines: [postgres]\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	output := filepath.Join(t.TempDir(), "calendar.yaml")
+
+	var b bytes.Buffer
+	assert.NoError(t, runUpdateCalendar([]string{"-source", server.URL + "/valid.yaml", "-output", output}, &b))
+	assert.Equal(t, "support calendar: 1 entries downloaded from "+server.URL+"/valid.yaml, written to "+output+"\n",
+		b.String())
+	loaded, err := loadSupportCalendar(output)
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 1)
+
+	err = runUpdateCalendar([]string{"-source", server.URL + "/missing.yaml", "-output", output}, &b)
+	assert.EqualError(t, err, "failed to download support calendar from "+server.URL+"/missing.yaml; "+
+		"unexpected status 404 Not Found")
+	err = runUpdateCalendar([]string{"-source", server.URL + "/invalid.yaml", "-output", output}, &b)
+	assert.EqualError(t, err, "failed to update support calendar from "+server.URL+"/invalid.yaml; "+
+		"invalid support calendar entry 1: engines, version and standard_support_end should be set")
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, calendar, string(data))
+	entries, err := os.ReadDir(filepath.Dir(output))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// tenantsPath prefixes the metric endpoints of the tenants, e.g. "/metrics/team/payments".
+const tenantsPath = "/metrics/team/"
+
+// tenantName is the pattern of the names of the tenants, which are part of the path of their endpoint.
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tenantConfig is a tenant of the configuration file, e.g. a team, which scrapes the metrics of its own resources on
+// its own endpoint: the resources matching its tag filters, in its accounts. Its token is a secret option.
+type tenantConfig struct {
+	Name       string   `yaml:"name"`
+	TagFilters string   `yaml:"tag_filters"`
+	Accounts   []string `yaml:"accounts"`
+
+	// Token is the bearer token the scrapes of the endpoint should present, if set.
+	Token string `yaml:"token"`
+
+	// tagFilters are the parsed TagFilters, set by compile.
+	tagFilters []tagFilter
+}
+
+// compile checks the tenant and parses its tag filters. A tenant should select its resources by tags, by accounts, or
+// both.
+func (c *tenantConfig) compile() error {
+	if !tenantName.MatchString(c.Name) {
+		return fmt.Errorf("name should match %s, got %q", tenantName, c.Name)
+	}
+	if c.TagFilters == "" && len(c.Accounts) == 0 {
+		return errors.New("tag_filters or accounts should be set")
+	}
+	filters, err := parseTagFilters(c.TagFilters)
+	if err != nil {
+		return err
+	}
+	c.tagFilters = filters
+	return nil
+}
+
+// owns reports whether a resource of the inventory belongs to the tenant.
+func (c *tenantConfig) owns(rdsInfo RDSInfo) bool {
+	if len(c.Accounts) > 0 && !contains(c.Accounts, rdsInfo.Account) {
+		return false
+	}
+	return matchTagFilters(rdsInfo.Tags, c.tagFilters)
+}
+
+// validateTenants checks the tenants of the configuration file, whose names should be unique.
+func (o *Options) validateTenants() []string {
+	var problems []string
+	names := make(map[string]bool)
+	for i := range o.Tenants {
+		tenant := &o.Tenants[i]
+		if err := tenant.compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("tenant %d: %s", i+1, err))
+			continue
+		}
+		if names[tenant.Name] {
+			problems = append(problems, fmt.Sprintf("tenant %d: the name %q is already used", i+1, tenant.Name))
+		}
+		names[tenant.Name] = true
+	}
+	return problems
+}
+
+// tenantGatherer gathers the series of the resources of a tenant: the series whose cluster_identifier, region and
+// account_id labels are the ones of a resource of the inventory owned by the tenant, so that a resource of the same name
+// in another account does not leak. The other series, e.g. the counts per engine or the health of the exporter, are
+// left out, as they may describe the resources of other tenants.
+type tenantGatherer struct {
+	gatherer  prometheus.Gatherer
+	inventory *inventory
+	tenant    *tenantConfig
+}
+
+// tenantResource identifies the resources of a tenant by the labels of their series.
+type tenantResource struct {
+	account, identifier, region string
+}
+
+func (g tenantGatherer) Gather() ([]*dto.MetricFamily, error) {
+	owned := make(map[tenantResource]bool)
+	for _, item := range g.inventory.list() {
+		owned[tenantResource{item.Account, item.ClusterIdentifier, item.Region}] = g.tenant.owns(item.RDSInfo)
+	}
+	families, err := g.gatherer.Gather()
+	var filtered []*dto.MetricFamily
+	for _, family := range families {
+		var selected []*dto.Metric
+		for _, m := range family.GetMetric() {
+			var resource tenantResource
+			labeled := false
+			for _, pair := range m.GetLabel() {
+				switch pair.GetName() {
+				case "cluster_identifier":
+					resource.identifier, labeled = pair.GetValue(), true
+				case "region":
+					resource.region = pair.GetValue()
+				case accountIDLabel:
+					resource.account = pair.GetValue()
+				}
+			}
+			if labeled && owned[resource] {
+				selected = append(selected, m)
+			}
+		}
+		if len(selected) > 0 {
+			filtered = append(filtered, &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type,
+				Metric: selected})
+		}
+	}
+	return filtered, err
+}
+
+// tenantsHandler serves the metrics of each tenant on its endpoint, e.g. "/metrics/team/payments", gathered from
+// the gatherer of the exporter. The scrapes of the tenants with a token should present it as a bearer token: an
+// Authorization header without the Bearer scheme is rejected.
+func tenantsHandler(gatherer prometheus.Gatherer, metrics *Metrics, tenants []tenantConfig) http.Handler {
+	handlers := make(map[string]http.Handler, len(tenants))
+	tokens := make(map[string]string, len(tenants))
+	for i := range tenants {
+		tenant := &tenants[i]
+		handlers[tenant.Name] = promhttp.HandlerFor(
+			tenantGatherer{gatherer: gatherer, inventory: metrics.Inventory, tenant: tenant},
+			promhttp.HandlerOpts{EnableOpenMetrics: true})
+		tokens[tenant.Name] = tenant.Token
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, tenantsPath)
+		handler, ok := handlers[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if token := tokens[name]; token != "" {
+			header := r.Header.Get("Authorization")
+			presented := strings.TrimPrefix(header, "Bearer ")
+			if !strings.HasPrefix(header, "Bearer ") || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+name+`"`)
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,203 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTenantConfigCompile tests the checks of the tenants of the configuration file.
+func TestTenantConfigCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  tenantConfig
+		wantErr string
+	}{
+		{name: "tags", config: tenantConfig{Name: "payments", TagFilters: "team=payments"}},
+		{name: "accounts", config: tenantConfig{Name: "data_platform", Accounts: []string{"111111111111"}}},
+		{
+			name:    "invalid name",
+			config:  tenantConfig{Name: "payments/eu", TagFilters: "team=payments"},
+			wantErr: `name should match ^[A-Za-z0-9_-]+$, got "payments/eu"`,
+		},
+		{
+			name:    "no selection",
+			config:  tenantConfig{Name: "payments"},
+			wantErr: "tag_filters or accounts should be set",
+		},
+		{
+			name:    "invalid tag filters",
+			config:  tenantConfig{Name: "payments", TagFilters: "=payments"},
+			wantErr: `invalid tag filter "=payments": tag key should not be empty`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.compile()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// TestTenantsHandler tests that each tenant is served the series of its own resources, and that the scrapes of the
+// tenants with a token present it.
+func TestTenantsHandler(t *testing.T) {
+	metrics := NewMetrics()
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "orders", Region: "eu-west-1", Account: "111111111111",
+			Tags: map[string]string{"team": "payments"}},
+		{ClusterIdentifier: "users", Region: "eu-west-1", Account: "111111111111",
+			Tags: map[string]string{"team": "identity"}},
+		{ClusterIdentifier: "orders", Region: "us-east-1", Account: "222222222222",
+			Tags: map[string]string{"team": "payments"}},
+	} {
+		metrics.Inventory.add(inventoryItem{RDSInfo: rdsInfo})
+		metrics.StoppedGauge.WithLabelValues(rdsInfo.ClusterIdentifier, rdsInfo.Account, rdsInfo.Region).Set(1)
+	}
+	metrics.DeprecatedTotalGauge.WithLabelValues("postgres", "111111111111", "", "eu-west-1").Set(3)
+	tenants := []tenantConfig{
+		{Name: "payments", TagFilters: "team=payments", Accounts: []string{"111111111111"}},
+		{Name: "identity", TagFilters: "team=identity", Token: "s3cr3t"},
+	}
+	for i := range tenants {
+		assert.NoError(t, tenants[i].compile())
+	}
+	handler := tenantsHandler(newGatherer(metrics, nil, nil), metrics, tenants)
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "tags and accounts",
+			path:     "/metrics/team/payments",
+			wantCode: http.StatusOK,
+			wantBody: `# HELP aws_custom_rds_stopped Stopped clusters and instances, whose engine version cannot be upgraded until they are started
+# TYPE aws_custom_rds_stopped gauge
+aws_custom_rds_stopped{account_id="111111111111",cluster_identifier="orders",region="eu-west-1"} 1
+`,
+		},
+		{
+			name:     "token",
+			path:     "/metrics/team/identity",
+			token:    "s3cr3t",
+			wantCode: http.StatusOK,
+			wantBody: `# HELP aws_custom_rds_stopped Stopped clusters and instances, whose engine version cannot be upgraded until they are started
+# TYPE aws_custom_rds_stopped gauge
+aws_custom_rds_stopped{account_id="111111111111",cluster_identifier="users",region="eu-west-1"} 1
+`,
+		},
+		{
+			name:     "invalid token",
+			path:     "/metrics/team/identity",
+			token:    "guess",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid token\n",
+		},
+		{
+			name:     "token without the bearer scheme",
+			path:     "/metrics/team/identity",
+			header:   "s3cr3t",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "invalid token\n",
+		},
+		{
+			name:     "unknown tenant",
+			path:     "/metrics/team/billing",
+			wantCode: http.StatusNotFound,
+			wantBody: "404 page not found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			body, _ := io.ReadAll(w.Result().Body)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, string(body))
+		})
+	}
+}
+
+// TestTenantGathererAccounts tests that the resources of the same identifier and region in other accounts do not leak
+// into the series of a tenant.
+func TestTenantGathererAccounts(t *testing.T) {
+	inv := &inventory{}
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "orders", Region: "eu-west-1", Account: "111111111111",
+			Tags: map[string]string{"team": "payments"}},
+		{ClusterIdentifier: "orders", Region: "eu-west-1", Account: "222222222222",
+			Tags: map[string]string{"team": "identity"}},
+		{ClusterIdentifier: "users", Region: "eu-west-1", Account: "222222222222",
+			Tags: map[string]string{"team": "payments"}},
+	} {
+		inv.add(inventoryItem{RDSInfo: rdsInfo})
+	}
+	cost := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "cost"},
+		[]string{"cluster_identifier", "region", "account_id"})
+	cost.WithLabelValues("orders", "eu-west-1", "111111111111").Set(1)
+	cost.WithLabelValues("orders", "eu-west-1", "222222222222").Set(1)
+	cost.WithLabelValues("users", "eu-west-1", "222222222222").Set(1)
+	r := prometheus.NewRegistry()
+	r.MustRegister(cost)
+	tenant := &tenantConfig{Name: "payments", TagFilters: "team=payments"}
+	assert.NoError(t, tenant.compile())
+
+	families, err := tenantGatherer{gatherer: r, inventory: inv, tenant: tenant}.Gather()
+	assert.NoError(t, err)
+	got := make(map[string][]string)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var labels []string
+			for _, pair := range m.GetLabel() {
+				labels = append(labels, pair.GetValue())
+			}
+			got[family.GetName()] = append(got[family.GetName()], strings.Join(labels, "/"))
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"cost": {"111111111111/orders/eu-west-1", "222222222222/users/eu-west-1"},
+	}, got)
+}
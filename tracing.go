@@ -0,0 +1,380 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// maxTracedSpans is the number of spans of a snapshot exported at most, the ones beyond are dropped.
+	maxTracedSpans = 10000
+
+	otelExportTimeout = 10 * time.Second
+)
+
+// The OTLP span kinds and status codes set by the tracer.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+// paginationTokens are the request and response fields holding the pagination tokens of the AWS APIs called by the
+// exporter.
+var paginationTokens = []string{"Marker", "NextToken", "PaginationToken"}
+
+// parseOtelEndpoint parses the URL of an OTLP/HTTP traces endpoint, e.g. "http://otel-collector:4318/v1/traces".
+func parseOtelEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("otel endpoint should be an http or https URL, got %q", endpoint)
+	}
+	return u.String(), nil
+}
+
+// parseOtelHeaders parses the headers sent along with the spans, e.g. "x-honeycomb-team=<key>,x-tenant=rds".
+func parseOtelHeaders(headers string) (map[string]string, error) {
+	parsed := make(map[string]string)
+	for _, header := range splitList(headers) {
+		name, value, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.New("otel headers should be a list of name=value")
+		}
+		parsed[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
+
+// tracer exports the spans of each snapshot, of the snapshot of each region, and of each AWS API call, to an
+// OpenTelemetry collector with the OTLP/HTTP protocol in its JSON encoding. The spans of a snapshot are sent at once when
+// the snapshot ends; failing to send them is only logged. A nil tracer traces nothing.
+type tracer struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+// newTracer returns a tracer sending the spans to the given endpoint, or nil if endpoint is empty.
+func newTracer(endpoint string, headers map[string]string) *tracer {
+	if endpoint == "" {
+		return nil
+	}
+	return &tracer{endpoint: endpoint, headers: headers, client: &http.Client{Timeout: otelExportTimeout}}
+}
+
+// span is an operation in progress, which is recorded by its tracer when it ends. A nil span records nothing, and its
+// children are nil too.
+type span struct {
+	tracer   *tracer
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	kind     int
+	start    time.Time
+
+	mu         sync.Mutex
+	attributes map[string]interface{}
+}
+
+// randomID returns n random bytes, hex encoded.
+func randomID(n int) string {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// start starts the root span of a new trace.
+func (t *tracer) start(name string) *span {
+	if t == nil {
+		return nil
+	}
+	return &span{
+		tracer:     t,
+		traceID:    randomID(16),
+		spanID:     randomID(8),
+		name:       name,
+		kind:       otlpSpanKindInternal,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+}
+
+// child starts a span of the given kind under s, at the given time.
+func (s *span) child(name string, kind int, start time.Time) *span {
+	if s == nil {
+		return nil
+	}
+	return &span{
+		tracer:     s.tracer,
+		traceID:    s.traceID,
+		spanID:     randomID(8),
+		parentID:   s.spanID,
+		name:       name,
+		kind:       kind,
+		start:      start,
+		attributes: make(map[string]interface{}),
+	}
+}
+
+// set sets an attribute of the span, either a string or an int64.
+func (s *span) set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// increment increments an int64 attribute of the span, and returns its new value.
+func (s *span) increment(key string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, _ := s.attributes[key].(int64)
+	s.attributes[key] = value + 1
+	return value + 1
+}
+
+// end records the span, with an error status if err is not nil. Ending the root span of a trace exports every span
+// recorded since the previous one ended.
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	recorded := otlpSpan{
+		TraceID:           s.traceID,
+		SpanID:            s.spanID,
+		ParentSpanID:      s.parentID,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Status:            otlpStatus{Code: otlpStatusOK},
+	}
+	if err != nil {
+		recorded.Status = otlpStatus{Code: otlpStatusError, Message: err.Error()}
+	}
+	s.mu.Lock()
+	recorded.Attributes = otlpAttributes(s.attributes)
+	s.mu.Unlock()
+
+	t := s.tracer
+	t.mu.Lock()
+	if len(t.spans) < maxTracedSpans {
+		t.spans = append(t.spans, recorded)
+	}
+	var spans []otlpSpan
+	if s.parentID == "" {
+		spans, t.spans = t.spans, nil
+	}
+	t.mu.Unlock()
+	if spans != nil {
+		go func() {
+			if err := t.send(spans); err != nil {
+				log.Printf("failed to export spans to %s; %v", t.endpoint, err)
+			}
+		}()
+	}
+}
+
+// send posts the spans to the OTLP/HTTP endpoint.
+func (t *tracer) send(spans []otlpSpan) error {
+	body, err := json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name":    exporterName,
+			"service.version": version,
+		})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: exporterName, Version: version}, Spans: spans}},
+	}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// traceScope holds the span of the snapshot of a region in progress, so that the AWS API calls of the clients of its
+// Config are traced as children of it. A nil traceScope traces nothing.
+type traceScope struct {
+	current atomic.Pointer[span]
+}
+
+// set sets the span of the snapshot in progress, or clears it if nil.
+func (s *traceScope) set(current *span) {
+	if s == nil {
+		return
+	}
+	s.current.Store(current)
+}
+
+// recordAWSCall traces a completed AWS API call, with its pagination tokens and its page number among the calls of
+// the same operation, and counts it on the span of the snapshot. It is meant to be pushed on the Complete handlers of
+// the session of a Config.
+func (s *traceScope) recordAWSCall(req *request.Request) {
+	parent := s.current.Load()
+	if parent == nil {
+		return
+	}
+	operation := fmt.Sprintf("%s.%s", req.ClientInfo.ServiceName, req.Operation.Name)
+	call := parent.child(operation, otlpSpanKindClient, req.Time)
+	call.set("rpc.system", "aws-api")
+	call.set("rpc.service", req.ClientInfo.ServiceName)
+	call.set("rpc.method", req.Operation.Name)
+	call.set("aws.request_id", req.RequestID)
+	call.set("aws.retry_count", int64(req.RetryCount))
+	call.set("aws.page", parent.increment("aws.pages."+operation))
+	parent.increment("aws.api_calls")
+	for _, token := range paginationTokens {
+		if values, _ := awsutil.ValuesAtPath(req.Params, token); len(values) > 0 {
+			if marker, ok := values[0].(*string); ok && marker != nil {
+				call.set("aws.marker", *marker)
+			}
+		}
+		if values, _ := awsutil.ValuesAtPath(req.Data, token); len(values) > 0 {
+			if marker, ok := values[0].(*string); ok && marker != nil {
+				call.set("aws.next_marker", *marker)
+			}
+		}
+	}
+	if req.HTTPResponse != nil {
+		call.set("http.status_code", int64(req.HTTPResponse.StatusCode))
+	}
+	call.end(req.Error)
+}
+
+// otlpTraces is the subset of the OTLP ExportTraceServiceRequest sent by the tracer, in its JSON encoding.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+// otlpAnyValue is either a string or an int64, which is encoded as a string in JSON.
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// otlpAttributes returns the attributes of a span, sorted by key.
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyValues := make([]otlpKeyValue, 0, len(attributes))
+	for _, key := range keys {
+		var value otlpAnyValue
+		switch v := attributes[key].(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			intValue := strconv.FormatInt(v, 10)
+			value.IntValue = &intValue
+		}
+		keyValues = append(keyValues, otlpKeyValue{Key: key, Value: value})
+	}
+	return keyValues
+}
@@ -0,0 +1,130 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTracer tests that the spans of a snapshot, of its regions and of their AWS API calls are sent at once when the
+// snapshot ends, with the pagination tokens and the page numbers of the calls.
+func TestTracer(t *testing.T) {
+	received := make(chan otlpTraces, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "s3cr3t", r.Header.Get("X-Api-Key"))
+		var traces otlpTraces
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&traces))
+		received <- traces
+	}))
+	defer server.Close()
+
+	tr := newTracer(server.URL, map[string]string{"X-Api-Key": "s3cr3t"})
+	root := tr.start("snapshot")
+	region := root.child("snapshot region", otlpSpanKindInternal, time.Now())
+	region.set("cloud.region", "eu-west-1")
+	scope := &traceScope{}
+	scope.set(region)
+	for _, marker := range []*string{nil, aws.String("page-2")} {
+		scope.recordAWSCall(&request.Request{
+			ClientInfo: metadata.ClientInfo{ServiceName: "rds"},
+			Operation:  &request.Operation{Name: "DescribeDBInstances"},
+			Params:     &rds.DescribeDBInstancesInput{Marker: marker},
+			Data:       &rds.DescribeDBInstancesOutput{},
+			RequestID:  "req-1",
+			Time:       time.Now(),
+		})
+	}
+	scope.set(nil)
+	// calls outside of a snapshot are not traced
+	scope.recordAWSCall(&request.Request{ClientInfo: metadata.ClientInfo{ServiceName: "sts"},
+		Operation: &request.Operation{Name: "GetCallerIdentity"}})
+	region.end(errors.New("throttled"))
+	root.end(nil)
+
+	var traces otlpTraces
+	select {
+	case traces = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no spans were sent")
+	}
+	assert.Len(t, traces.ResourceSpans, 1)
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 4)
+
+	attributes := func(s otlpSpan) map[string]string {
+		values := make(map[string]string)
+		for _, kv := range s.Attributes {
+			if kv.Value.StringValue != nil {
+				values[kv.Key] = *kv.Value.StringValue
+			} else {
+				values[kv.Key] = *kv.Value.IntValue
+			}
+		}
+		return values
+	}
+	first, second, regionSpan, rootSpan := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, "rds.DescribeDBInstances", first.Name)
+	assert.Equal(t, otlpSpanKindClient, first.Kind)
+	assert.Equal(t, regionSpan.SpanID, first.ParentSpanID)
+	assert.Equal(t, map[string]string{"aws.page": "1", "aws.request_id": "req-1", "aws.retry_count": "0",
+		"rpc.method": "DescribeDBInstances", "rpc.service": "rds", "rpc.system": "aws-api"}, attributes(first))
+	assert.Equal(t, "page-2", attributes(second)["aws.marker"])
+	assert.Equal(t, "2", attributes(second)["aws.page"])
+
+	assert.Equal(t, rootSpan.SpanID, regionSpan.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusError, Message: "throttled"}, regionSpan.Status)
+	assert.Equal(t, map[string]string{"aws.api_calls": "2", "aws.pages.rds.DescribeDBInstances": "2",
+		"cloud.region": "eu-west-1"}, attributes(regionSpan))
+
+	assert.Equal(t, "", rootSpan.ParentSpanID)
+	assert.Equal(t, rootSpan.TraceID, first.TraceID)
+	assert.Equal(t, otlpStatus{Code: otlpStatusOK}, rootSpan.Status)
+
+	// a nil tracer traces nothing
+	var nilTracer *tracer
+	nilRoot := nilTracer.start("snapshot")
+	nilRoot.child("snapshot region", otlpSpanKindInternal, time.Now()).end(nil)
+	nilRoot.end(nil)
+}
+
+// TestParseOtelHeaders tests the parseOtelHeaders function.
+func TestParseOtelHeaders(t *testing.T) {
+	headers, err := parseOtelHeaders("x-honeycomb-team = abc, x-tenant=rds=prod")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-honeycomb-team": "abc", "x-tenant": "rds=prod"}, headers)
+
+	_, err = parseOtelHeaders("x-honeycomb-team")
+	assert.EqualError(t, err, "otel headers should be a list of name=value")
+}
@@ -0,0 +1,174 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/support"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// trustedAdvisorLanguage is the language of the names of the Trusted Advisor checks, which tell the RDS checks apart.
+const trustedAdvisorLanguage = "en"
+
+// supportRegions maps the partitions to the region of the global endpoint of the AWS Support API, which serves the
+// Trusted Advisor checks.
+var supportRegions = map[string]string{
+	endpoints.AwsPartitionID:      "us-east-1",
+	endpoints.AwsCnPartitionID:    "cn-north-1",
+	endpoints.AwsUsGovPartitionID: "us-gov-west-1",
+}
+
+// TrustedAdvisorCheckInfo represents the result of a Trusted Advisor check of RDS, e.g. the idle DB instances or the
+// security group access risks.
+type TrustedAdvisorCheckInfo struct {
+	// ID is the ID of the check, Name its name, and Category its category, e.g. cost_optimizing or security.
+	ID       string
+	Name     string
+	Category string
+
+	// Status is the status of the check: ok, warning, error or not_available.
+	Status string
+
+	// ResourcesFlagged is the number of resources flagged by the check, and FlaggedResources these resources, but the
+	// suppressed ones.
+	ResourcesFlagged int64
+	FlaggedResources []TrustedAdvisorResourceInfo
+}
+
+// TrustedAdvisorResourceInfo represents a resource flagged by a Trusted Advisor check.
+type TrustedAdvisorResourceInfo struct {
+	// ResourceID is the ID of the resource in Trusted Advisor, which is not the one of the resource in AWS.
+	ResourceID string
+
+	// Resource is the second column of the metadata of the resource, which holds its name in the RDS checks, e.g. the
+	// identifier of the DB instance or the name of the security group.
+	Resource string
+
+	// Status is the status of the resource: warning or error.
+	Status string
+
+	// Region is the AWS region of the resource, if any.
+	Region string
+}
+
+// isRDSCheck returns true if a Trusted Advisor check is about RDS, which its English name tells, e.g. "Amazon RDS Idle
+// DB Instances".
+func isRDSCheck(name string) bool {
+	return strings.Contains(name, "RDS")
+}
+
+// getTrustedAdvisorChecks lists the Trusted Advisor checks with DescribeTrustedAdvisorChecks, and returns the result of
+// each check of RDS, read with DescribeTrustedAdvisorCheckResult. The Trusted Advisor checks are global to the account,
+// and require a Business, Enterprise On-Ramp or Enterprise support plan.
+// An error is returned if the function fails to retrieve the checks or their results.
+func getTrustedAdvisorChecks(config *Config) ([]TrustedAdvisorCheckInfo, error) {
+	output, err := config.Support.DescribeTrustedAdvisorChecks(&support.DescribeTrustedAdvisorChecksInput{
+		Language: Ptr(trustedAdvisorLanguage),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe Trusted Advisor checks; %w", err)
+	}
+	checks := make([]TrustedAdvisorCheckInfo, 0)
+	if output == nil {
+		return checks, nil
+	}
+	for _, check := range output.Checks {
+		if !isRDSCheck(aws.StringValue(check.Name)) {
+			continue
+		}
+		result, err := config.Support.DescribeTrustedAdvisorCheckResult(&support.DescribeTrustedAdvisorCheckResultInput{
+			CheckId:  check.Id,
+			Language: Ptr(trustedAdvisorLanguage),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the result of Trusted Advisor check %q; %w",
+				aws.StringValue(check.Name), err)
+		}
+		info := TrustedAdvisorCheckInfo{
+			ID:       aws.StringValue(check.Id),
+			Name:     aws.StringValue(check.Name),
+			Category: aws.StringValue(check.Category),
+		}
+		if result != nil && result.Result != nil {
+			info.Status = aws.StringValue(result.Result.Status)
+			if result.Result.ResourcesSummary != nil {
+				info.ResourcesFlagged = aws.Int64Value(result.Result.ResourcesSummary.ResourcesFlagged)
+			}
+			for _, resource := range result.Result.FlaggedResources {
+				if aws.BoolValue(resource.IsSuppressed) {
+					continue
+				}
+				flagged := TrustedAdvisorResourceInfo{
+					ResourceID: aws.StringValue(resource.ResourceId),
+					Status:     aws.StringValue(resource.Status),
+					Region:     aws.StringValue(resource.Region),
+				}
+				if len(resource.Metadata) > 1 {
+					flagged.Resource = aws.StringValue(resource.Metadata[1])
+				}
+				info.FlaggedResources = append(info.FlaggedResources, flagged)
+			}
+		}
+		checks = append(checks, info)
+	}
+	return checks, nil
+}
+
+// exportTrustedAdvisor sets the TrustedAdvisorCheckGauge to the number of resources flagged by each Trusted Advisor
+// check of RDS of the account of the config, and the TrustedAdvisorFlaggedResourceGauge to 1 for each of these
+// resources in one of the given regions, or in no region.
+func exportTrustedAdvisor(config *Config, metrics *Metrics, regions []string) error {
+	checks, err := getTrustedAdvisorChecks(config)
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		metrics.TrustedAdvisorCheckGauge.With(prometheus.Labels{
+			"check_id":   check.ID,
+			"check_name": check.Name,
+			"category":   check.Category,
+			"status":     check.Status,
+			"account_id": config.account(),
+		}).Set(float64(check.ResourcesFlagged))
+		for _, resource := range check.FlaggedResources {
+			if resource.Region != "" && !contains(regions, resource.Region) {
+				continue
+			}
+			metrics.TrustedAdvisorFlaggedResourceGauge.With(prometheus.Labels{
+				"check_id":    check.ID,
+				"check_name":  check.Name,
+				"resource_id": resource.ResourceID,
+				"resource":    resource.Resource,
+				"status":      resource.Status,
+				"account_id":  config.account(),
+				"region":      resource.Region,
+			}).Set(1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/support"
+	"github.com/aws/aws-sdk-go/service/support/supportiface"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockSupportAPI struct {
+	supportiface.SupportAPI
+	checks  []*support.TrustedAdvisorCheckDescription
+	results map[string]*support.TrustedAdvisorCheckResult
+	err     error
+}
+
+func (m *MockSupportAPI) DescribeTrustedAdvisorChecks(*support.DescribeTrustedAdvisorChecksInput) (
+	*support.DescribeTrustedAdvisorChecksOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &support.DescribeTrustedAdvisorChecksOutput{Checks: m.checks}, nil
+}
+
+func (m *MockSupportAPI) DescribeTrustedAdvisorCheckResult(input *support.DescribeTrustedAdvisorCheckResultInput) (
+	*support.DescribeTrustedAdvisorCheckResultOutput, error) {
+	return &support.DescribeTrustedAdvisorCheckResultOutput{Result: m.results[aws.StringValue(input.CheckId)]}, nil
+}
+
+// TestExportTrustedAdvisor tests that only the Trusted Advisor checks of RDS are exported, along with their flagged
+// resources in the scanned regions, but the suppressed ones.
+func TestExportTrustedAdvisor(t *testing.T) {
+	api := &MockSupportAPI{
+		checks: []*support.TrustedAdvisorCheckDescription{
+			{Id: Ptr("Ti39halfu8"), Name: Ptr("Amazon RDS Idle DB Instances"), Category: Ptr("cost_optimizing")},
+			{Id: Ptr("Qch7DwouX1"), Name: Ptr("Low Utilization Amazon EC2 Instances"), Category: Ptr("cost_optimizing")},
+		},
+		results: map[string]*support.TrustedAdvisorCheckResult{
+			"Ti39halfu8": {
+				Status:           Ptr("warning"),
+				ResourcesSummary: &support.TrustedAdvisorResourcesSummary{ResourcesFlagged: aws.Int64(3)},
+				FlaggedResources: []*support.TrustedAdvisorResourceDetail{
+					{ResourceId: Ptr("r-1"), Status: Ptr("warning"), Region: Ptr("eu-west-1"),
+						Metadata: aws.StringSlice([]string{"eu-west-1", "legacy-cms", "No"})},
+					{ResourceId: Ptr("r-2"), Status: Ptr("warning"), Region: Ptr("us-east-1"),
+						Metadata: aws.StringSlice([]string{"us-east-1", "reporting", "No"})},
+					{ResourceId: Ptr("r-3"), Status: Ptr("warning"), Region: Ptr("eu-west-1"), IsSuppressed: Ptr(true),
+						Metadata: aws.StringSlice([]string{"eu-west-1", "sandbox", "No"})},
+				},
+			},
+		},
+	}
+	metrics := NewMetrics()
+
+	assert.NoError(t, exportTrustedAdvisor(&Config{Support: api}, metrics, []string{"eu-west-1"}))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.TrustedAdvisorCheckGauge))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.TrustedAdvisorCheckGauge.WithLabelValues(
+		"Ti39halfu8", "Amazon RDS Idle DB Instances", "cost_optimizing", "warning", "default")))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.TrustedAdvisorFlaggedResourceGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TrustedAdvisorFlaggedResourceGauge.WithLabelValues(
+		"Ti39halfu8", "Amazon RDS Idle DB Instances", "r-1", "legacy-cms", "warning", "default", "eu-west-1")))
+
+	api = &MockSupportAPI{err: errors.New("SubscriptionRequiredException")}
+	assert.EqualError(t, exportTrustedAdvisor(&Config{Support: api}, NewMetrics(), nil),
+		"failed to describe Trusted Advisor checks; SubscriptionRequiredException")
+}
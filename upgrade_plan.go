@@ -0,0 +1,183 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	planFormatCLI  = "cli"
+	planFormatJSON = "json"
+)
+
+// plannedUpgrade is the upgrade of a deprecated RDS resource to its recommended target version, and the AWS CLI
+// command performing it during the next maintenance window of the resource.
+type plannedUpgrade struct {
+	Identifier    string `json:"identifier"`
+	ResourceType  string `json:"resource_type"`
+	Engine        string `json:"engine"`
+	EngineVersion string `json:"engine_version"`
+	TargetVersion string `json:"target_version"`
+	Major         bool   `json:"major"`
+	Command       string `json:"command"`
+}
+
+// upgradeGroup is the planned upgrades of an account and region applied during the same maintenance window.
+type upgradeGroup struct {
+	Account           string           `json:"account"`
+	Region            string           `json:"region"`
+	MaintenanceWindow string           `json:"maintenance_window"`
+	Upgrades          []plannedUpgrade `json:"upgrades"`
+}
+
+// upgradeCommand returns the AWS CLI command upgrading an RDS resource to the target version during its next
+// maintenance window: modify-db-cluster for the clusters, and modify-db-instance for the instances.
+func upgradeCommand(rdsInfo RDSInfo, target upgradeTarget) string {
+	args := []string{"aws", "rds", "modify-db-instance", "--db-instance-identifier", rdsInfo.ClusterIdentifier}
+	if rdsInfo.ResourceType == resourceTypeCluster {
+		args = []string{"aws", "rds", "modify-db-cluster", "--db-cluster-identifier", rdsInfo.ClusterIdentifier}
+	}
+	args = append(args, "--engine-version", target.EngineVersion)
+	if target.Major {
+		args = append(args, "--allow-major-version-upgrade")
+	}
+	args = append(args, "--no-apply-immediately", "--region", rdsInfo.Region)
+	return strings.Join(args, " ")
+}
+
+// planUpgrades returns the upgrades of the inventory items running a deprecated engine version to the target version
+// recommended by the preferences, grouped by account, region and maintenance window, in this order. The members of
+// clusters are left out, as they are upgraded along with their cluster, and so are the resources without recommended
+// target. The catalogs are keyed by catalogKey.
+func planUpgrades(items []inventoryItem, catalogs map[string]engineVersions,
+	preferences upgradePreferences) []upgradeGroup {
+	plan := make([]upgradeGroup, 0)
+	groups := make(map[string]int)
+	for _, item := range items {
+		if !item.Deprecated || item.MemberOf != "" {
+			continue
+		}
+		m := catalogs[catalogKey(item.Account, item.Region)]
+		target, ok := recommendUpgrade(m[item.Engine][item.EngineVersion], m[item.Engine], preferences)
+		if !ok {
+			continue
+		}
+		key := catalogKey(item.Account, item.Region) + "/" + item.MaintenanceWindow
+		i, ok := groups[key]
+		if !ok {
+			i = len(plan)
+			groups[key] = i
+			plan = append(plan, upgradeGroup{Account: item.Account, Region: item.Region,
+				MaintenanceWindow: item.MaintenanceWindow, Upgrades: []plannedUpgrade{}})
+		}
+		plan[i].Upgrades = append(plan[i].Upgrades, plannedUpgrade{
+			Identifier:    item.ClusterIdentifier,
+			ResourceType:  describeResourceType(item.RDSInfo),
+			Engine:        item.Engine,
+			EngineVersion: item.EngineVersion,
+			TargetVersion: target.EngineVersion,
+			Major:         target.Major,
+			Command:       upgradeCommand(item.RDSInfo, target),
+		})
+	}
+
+	sort.Slice(plan, func(a, b int) bool {
+		if plan[a].Account != plan[b].Account {
+			return plan[a].Account < plan[b].Account
+		}
+		if plan[a].Region != plan[b].Region {
+			return plan[a].Region < plan[b].Region
+		}
+		return plan[a].MaintenanceWindow < plan[b].MaintenanceWindow
+	})
+	return plan
+}
+
+// writeUpgradePlan writes the upgrade plan in the given format: a shell script of AWS CLI commands, with a comment
+// heading each group, or a JSON document holding the generation time and the groups.
+func writeUpgradePlan(w io.Writer, format string, plan []upgradeGroup, now time.Time) error {
+	switch format {
+	case planFormatCLI:
+		if _, err := fmt.Fprintf(w, "#!/bin/sh\n# RDS upgrade plan generated at %s, review before running\n",
+			now.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+		for _, group := range plan {
+			window := group.MaintenanceWindow
+			if window == "" {
+				window = "none"
+			}
+			if _, err := fmt.Fprintf(w, "\n# account %s, region %s, maintenance window %s\n", group.Account,
+				group.Region, window); err != nil {
+				return err
+			}
+			for _, upgrade := range group.Upgrades {
+				if _, err := fmt.Fprintf(w, "# %s %s: %s %s -> %s\n%s\n", upgrade.ResourceType, upgrade.Identifier,
+					upgrade.Engine, upgrade.EngineVersion, upgrade.TargetVersion, upgrade.Command); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case planFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			GeneratedAt time.Time      `json:"generated_at"`
+			Groups      []upgradeGroup `json:"groups"`
+		}{GeneratedAt: now.UTC(), Groups: plan})
+	default:
+		return fmt.Errorf("unknown plan format %q", format)
+	}
+}
+
+// runPlan is the "plan" subcommand, whose only plan is "upgrades". It takes a single snapshot of the accounts and
+// regions configured by the args, the environment variables and the configuration file, like the exporter, and writes
+// to w the plan upgrading each resource running a deprecated engine version to its recommended target version, in
+// the plan format, for humans to review and execute. The plan of a partial snapshot covers the scopes that succeeded,
+// and its error is returned once it is written.
+func runPlan(args []string, w io.Writer) error {
+	if len(args) == 0 || args[0] != "upgrades" {
+		return fmt.Errorf("usage: %s plan upgrades [flags]", exporterName)
+	}
+	options, err := loadCommandOptions("plan", args[1:], os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	items, catalogs, start, err := snapshotCatalogs(options)
+	if err != nil && !partialSnapshot(err) {
+		return err
+	}
+	plan := planUpgrades(items, catalogs, newUpgradePreferences(options))
+	if writeErr := writeUpgradePlan(w, options.PlanFormat, plan, start); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
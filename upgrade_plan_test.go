@@ -0,0 +1,148 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// TestPlanUpgrades tests that the upgrades of the deprecated resources are grouped by account, region and maintenance
+// window, without the cluster members nor the resources without recommended target.
+func TestPlanUpgrades(t *testing.T) {
+	items := []inventoryItem{
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+				Region: "eu-west-1", Account: "111111111111", ResourceType: resourceTypeCluster,
+				MaintenanceWindow: "sun:05:00-sun:06:00"},
+			Deprecated: true,
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "orders-1", Engine: "postgres", EngineVersion: "11.4",
+				Region: "eu-west-1", Account: "111111111111", ResourceType: resourceTypeInstance, MemberOf: "orders",
+				MaintenanceWindow: "sun:05:00-sun:06:00"},
+			Deprecated: true,
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "reporting", Engine: "postgres", EngineVersion: "12.4",
+				Region: "eu-west-1", Account: "111111111111", ResourceType: resourceTypeInstance,
+				MaintenanceWindow: "mon:03:00-mon:04:00"},
+			Deprecated: true,
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "11.22",
+				Region: "eu-west-1", Account: "111111111111", ResourceType: resourceTypeInstance,
+				MaintenanceWindow: "sun:05:00-sun:06:00"},
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "legacy", Engine: "postgres", EngineVersion: "11.4",
+				Region: "us-east-1", Account: "111111111111", ResourceType: resourceTypeInstance},
+			Deprecated: true,
+		},
+	}
+	catalogs := map[string]engineVersions{
+		catalogKey("111111111111", "eu-west-1"): {"postgres": recommendationCatalog},
+	}
+
+	plan := planUpgrades(items, catalogs, upgradePreferences{})
+	assert.Equal(t, []upgradeGroup{
+		{Account: "111111111111", Region: "eu-west-1", MaintenanceWindow: "mon:03:00-mon:04:00",
+			Upgrades: []plannedUpgrade{{
+				Identifier: "reporting", ResourceType: "instance", Engine: "postgres", EngineVersion: "12.4",
+				TargetVersion: "15.5", Major: true,
+				Command: "aws rds modify-db-instance --db-instance-identifier reporting --engine-version 15.5 " +
+					"--allow-major-version-upgrade --no-apply-immediately --region eu-west-1",
+			}}},
+		{Account: "111111111111", Region: "eu-west-1", MaintenanceWindow: "sun:05:00-sun:06:00",
+			Upgrades: []plannedUpgrade{{
+				Identifier: "orders", ResourceType: "cluster", Engine: "postgres", EngineVersion: "11.4",
+				TargetVersion: "11.22",
+				Command: "aws rds modify-db-cluster --db-cluster-identifier orders --engine-version 11.22 " +
+					"--no-apply-immediately --region eu-west-1",
+			}}},
+	}, plan)
+
+	var b bytes.Buffer
+	assert.NoError(t, writeUpgradePlan(&b, planFormatCLI, plan, time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)))
+	assert.Equal(t, "#!/bin/sh\n"+
+		"# RDS upgrade plan generated at 2023-05-10T14:37:00Z, review before running\n"+
+		"\n"+
+		"# account 111111111111, region eu-west-1, maintenance window mon:03:00-mon:04:00\n"+
+		"# instance reporting: postgres 12.4 -> 15.5\n"+
+		"aws rds modify-db-instance --db-instance-identifier reporting --engine-version 15.5 "+
+		"--allow-major-version-upgrade --no-apply-immediately --region eu-west-1\n"+
+		"\n"+
+		"# account 111111111111, region eu-west-1, maintenance window sun:05:00-sun:06:00\n"+
+		"# cluster orders: postgres 11.4 -> 11.22\n"+
+		"aws rds modify-db-cluster --db-cluster-identifier orders --engine-version 11.22 "+
+		"--no-apply-immediately --region eu-west-1\n", b.String())
+}
+
+// TestWriteUpgradePlanJSON tests the JSON format of the upgrade plan.
+func TestWriteUpgradePlanJSON(t *testing.T) {
+	plan := []upgradeGroup{{Account: "111111111111", Region: "eu-west-1", Upgrades: []plannedUpgrade{{
+		Identifier: "legacy", ResourceType: "instance", Engine: "mysql", EngineVersion: "5.7.38",
+		TargetVersion: "5.7.44", Command: "aws rds modify-db-instance --db-instance-identifier legacy",
+	}}}}
+
+	var b bytes.Buffer
+	assert.NoError(t, writeUpgradePlan(&b, planFormatJSON, plan, time.Date(2023, 5, 10, 14, 37, 0, 0, time.UTC)))
+	assert.Equal(t, `{
+  "generated_at": "2023-05-10T14:37:00Z",
+  "groups": [
+    {
+      "account": "111111111111",
+      "region": "eu-west-1",
+      "maintenance_window": "",
+      "upgrades": [
+        {
+          "identifier": "legacy",
+          "resource_type": "instance",
+          "engine": "mysql",
+          "engine_version": "5.7.38",
+          "target_version": "5.7.44",
+          "major": false,
+          "command": "aws rds modify-db-instance --db-instance-identifier legacy"
+        }
+      ]
+    }
+  ]
+}
+`, b.String())
+	assert.EqualError(t, writeUpgradePlan(&b, "terraform", plan, time.Now()), `unknown plan format "terraform"`)
+}
+
+// TestRunPlan tests the plan subcommand, in demo mode.
+func TestRunPlan(t *testing.T) {
+	// set by TestMain
+	t.Setenv(AwsApiIntervalEnvName, "")
+
+	var b bytes.Buffer
+	assert.NoError(t, runPlan([]string{"upgrades", "-demo", "-regions", "eu-west-1", "-plan-format", "json"}, &b))
+	assert.Contains(t, b.String(), `"groups": []`)
+
+	err := runPlan([]string{"-demo"}, &b)
+	assert.EqualError(t, err, "usage: prometheus-exporter-aws-rds-engine-version plan upgrades [flags]")
+}
@@ -0,0 +1,196 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	upgradePreferenceMinor = "minor"
+	upgradePreferenceMajor = "major"
+)
+
+// upgradePreferences pick the upgrade target recommended for an engine version among its valid upgrade targets.
+type upgradePreferences struct {
+	// Major prefers jumping to the latest major version over staying on the major version of the resource.
+	Major bool
+
+	// Default prefers the default minor version, which RDS applies to the resources with automatic minor version
+	// upgrades, over the latest one.
+	Default bool
+}
+
+// newUpgradePreferences returns the upgradePreferences of the options.
+func newUpgradePreferences(options *Options) upgradePreferences {
+	return upgradePreferences{
+		Major:   options.UpgradePreference == upgradePreferenceMajor,
+		Default: options.PreferDefaultVersion,
+	}
+}
+
+// recommendations returns the upgradePreferences of the options if the upgrade recommendations are exported, nil
+// otherwise.
+func recommendations(options *Options) *upgradePreferences {
+	if !options.UpgradeRecommendations {
+		return nil
+	}
+	preferences := newUpgradePreferences(options)
+	return &preferences
+}
+
+// recommendUpgrade returns the upgrade target recommended for an engine version, given the catalog of its engine: the
+// latest target of the preferred kind, minor or major, in the order of the catalog, or the default minor version when
+// it is preferred. The targets of the other kind are only recommended if there is no target of the preferred kind, and
+// the targets the catalog lists as deprecated are never recommended. It returns false if there is no such target.
+func recommendUpgrade(info versionInfo, catalog versionCatalog, preferences upgradePreferences) (upgradeTarget, bool) {
+	var preferred, others []upgradeTarget
+	for _, target := range info.UpgradeTargets {
+		if targetInfo, ok := catalog[target.EngineVersion]; ok && deprecatedStatuses[targetInfo.Status] {
+			continue
+		}
+		if target.Major == preferences.Major {
+			preferred = append(preferred, target)
+		} else {
+			others = append(others, target)
+		}
+	}
+	candidates := preferred
+	if len(candidates) == 0 {
+		candidates = others
+	}
+	if len(candidates) == 0 {
+		return upgradeTarget{}, false
+	}
+	if preferences.Default {
+		for _, target := range candidates {
+			if target.Default {
+				return target, true
+			}
+		}
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// exportRecommendedUpgrade sets the RecommendedUpgradeGauge of an RDS resource whose engine version is deprecated to
+// 1, with the upgrade target recommended by the preferences. Nothing is exported for the versions that are not
+// deprecated, nor for the ones without upgrade target.
+func exportRecommendedUpgrade(metrics *Metrics, rdsInfo RDSInfo, m engineVersions, preferences upgradePreferences) {
+	info, ok := m[rdsInfo.Engine][rdsInfo.EngineVersion]
+	if !ok || !deprecatedStatuses[info.Status] {
+		return
+	}
+	target, ok := recommendUpgrade(info, m[rdsInfo.Engine], preferences)
+	if !ok {
+		return
+	}
+	upgrade := upgradePreferenceMinor
+	if target.Major {
+		upgrade = upgradePreferenceMajor
+	}
+	metrics.RecommendedUpgradeGauge.With(prometheus.Labels{
+		"cluster_identifier":  rdsInfo.ClusterIdentifier,
+		"engine":              rdsInfo.Engine,
+		"engine_version":      rdsInfo.EngineVersion,
+		"recommended_version": target.EngineVersion,
+		"upgrade":             upgrade,
+		"account_id":          rdsInfo.Account,
+		"region":              rdsInfo.Region,
+	}).Set(1)
+}
+
+// writeRecommendations writes to w a table of the inventory items running a deprecated engine version, with the
+// upgrade target recommended by the preferences, "none" if there is none. The catalogs are keyed by catalogKey.
+func writeRecommendations(w io.Writer, items []inventoryItem, catalogs map[string]engineVersions,
+	preferences upgradePreferences) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tREGION\tIDENTIFIER\tENGINE\tVERSION\tSTATUS\tRECOMMENDED\tUPGRADE")
+	for _, item := range items {
+		if !item.Deprecated {
+			continue
+		}
+		m := catalogs[catalogKey(item.Account, item.Region)]
+		recommended, upgrade := "none", ""
+		if target, ok := recommendUpgrade(m[item.Engine][item.EngineVersion], m[item.Engine], preferences); ok {
+			recommended, upgrade = target.EngineVersion, upgradePreferenceMinor
+			if target.Major {
+				upgrade = upgradePreferenceMajor
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", item.Account, item.Region, item.ClusterIdentifier,
+			item.Engine, item.EngineVersion, item.Status, recommended, upgrade)
+	}
+	return tw.Flush()
+}
+
+// catalogKey keys the catalogs of an account and region, e.g. "123456789012/eu-west-1".
+func catalogKey(account, region string) string {
+	return account + "/" + region
+}
+
+// runRecommend is the "recommend" subcommand. It takes a single snapshot of the accounts and regions configured by the
+// args, the environment variables and the configuration file, like the exporter, and writes to w the upgrade target
+// recommended by the upgrade preferences for each resource running a deprecated engine version. The recommendations of
+// a partial snapshot cover the scopes that succeeded, and its error is returned once they are written.
+func runRecommend(args []string, w io.Writer) error {
+	options, err := loadCommandOptions("recommend", args, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	items, catalogs, _, err := snapshotCatalogs(options)
+	if err != nil && !partialSnapshot(err) {
+		return err
+	}
+	if writeErr := writeRecommendations(w, items, catalogs, newUpgradePreferences(options)); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// snapshotCatalogs takes a single snapshot of the accounts and regions of the options, and returns its inventory, the
+// catalogs of each account and region keyed by catalogKey, and its start time. Like snapshotInventory, the results of a
+// partial snapshot are returned along with its error.
+func snapshotCatalogs(options *Options) ([]inventoryItem, map[string]engineVersions, time.Time, error) {
+	scopes, err := NewScopes(options)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	catalogs := newCatalogs(scopes)
+	metrics := NewMetrics()
+	start := time.Now()
+	err = snapshotScopes(scopes, metrics, catalogs)
+	if err != nil && !partialSnapshot(err) {
+		return nil, nil, start, err
+	}
+	keyed := make(map[string]engineVersions, len(catalogs))
+	for config, m := range catalogs {
+		keyed[catalogKey(config.account(), config.Region)] = m
+	}
+	return metrics.Inventory.list(), keyed, start, err
+}
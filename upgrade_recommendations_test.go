@@ -0,0 +1,166 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// recommendationCatalog is the catalog of postgres used by the tests of the upgrade recommendations: 11.4 can be
+// upgraded to minor and major versions, the latest minor one of which is deprecated, and 11.21 is the default minor
+// version.
+var recommendationCatalog = versionCatalog{
+	"11.4": {Status: "deprecated", UpgradeTargets: []upgradeTarget{
+		{EngineVersion: "11.5"},
+		{EngineVersion: "11.21", Default: true},
+		{EngineVersion: "11.22"},
+		{EngineVersion: "11.23"},
+		{EngineVersion: "12.17", Major: true},
+		{EngineVersion: "15.5", Major: true},
+	}},
+	"11.5":  {Status: "available"},
+	"11.21": {Status: "available"},
+	"11.22": {Status: "available"},
+	"11.23": {Status: "deprecated"},
+	"12.17": {Status: "available"},
+	"15.5":  {Status: "available"},
+	"12.4": {Status: "deprecated", UpgradeTargets: []upgradeTarget{
+		{EngineVersion: "15.5", Major: true},
+	}},
+}
+
+// TestRecommendUpgrade tests the recommendUpgrade function with every upgrade preference.
+func TestRecommendUpgrade(t *testing.T) {
+	tests := []struct {
+		name          string
+		engineVersion string
+		preferences   upgradePreferences
+		want          upgradeTarget
+		wantOK        bool
+	}{
+		{
+			name:          "latest minor version",
+			engineVersion: "11.4",
+			want:          upgradeTarget{EngineVersion: "11.22"},
+			wantOK:        true,
+		},
+		{
+			name:          "default minor version",
+			engineVersion: "11.4",
+			preferences:   upgradePreferences{Default: true},
+			want:          upgradeTarget{EngineVersion: "11.21", Default: true},
+			wantOK:        true,
+		},
+		{
+			name:          "latest major version",
+			engineVersion: "11.4",
+			preferences:   upgradePreferences{Major: true, Default: true},
+			want:          upgradeTarget{EngineVersion: "15.5", Major: true},
+			wantOK:        true,
+		},
+		{
+			name:          "major version without minor version",
+			engineVersion: "12.4",
+			want:          upgradeTarget{EngineVersion: "15.5", Major: true},
+			wantOK:        true,
+		},
+		{
+			name:          "no upgrade target",
+			engineVersion: "15.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := recommendUpgrade(recommendationCatalog[tt.engineVersion], recommendationCatalog, tt.preferences)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestExportRecommendedUpgrade tests that the recommended upgrade is only exported for the deprecated engine versions.
+func TestExportRecommendedUpgrade(t *testing.T) {
+	metrics := NewMetrics()
+	m := engineVersions{"postgres": recommendationCatalog}
+	for _, rdsInfo := range []RDSInfo{
+		{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4", Region: "eu-west-1"},
+		{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "11.22", Region: "eu-west-1"},
+		{ClusterIdentifier: "unknown", Engine: "mysql", EngineVersion: "5.7.38", Region: "eu-west-1"},
+	} {
+		exportRecommendedUpgrade(metrics, rdsInfo, m, upgradePreferences{Major: true})
+	}
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.RecommendedUpgradeGauge))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.RecommendedUpgradeGauge.WithLabelValues(
+		"orders", "postgres", "11.4", "15.5", "major", "", "eu-west-1")))
+}
+
+// TestWriteRecommendations tests that the table of the recommendations lists the deprecated resources only.
+func TestWriteRecommendations(t *testing.T) {
+	items := []inventoryItem{
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "orders", Engine: "postgres", EngineVersion: "11.4",
+				Region: "eu-west-1", Account: "111111111111"},
+			Status:     "deprecated",
+			Deprecated: true,
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "users", Engine: "postgres", EngineVersion: "11.22",
+				Region: "eu-west-1", Account: "111111111111"},
+			Status: "available",
+		},
+		{
+			RDSInfo: RDSInfo{ClusterIdentifier: "legacy", Engine: "postgres", EngineVersion: "11.4",
+				Region: "us-east-1", Account: "111111111111"},
+			Status:     "deprecated",
+			Deprecated: true,
+		},
+	}
+	catalogs := map[string]engineVersions{
+		catalogKey("111111111111", "eu-west-1"): {"postgres": recommendationCatalog},
+	}
+
+	var b bytes.Buffer
+	assert.NoError(t, writeRecommendations(&b, items, catalogs, upgradePreferences{}))
+	assert.Equal(t, ""+
+		"ACCOUNT       REGION     IDENTIFIER  ENGINE    VERSION  STATUS      RECOMMENDED  UPGRADE\n"+
+		"111111111111  eu-west-1  orders      postgres  11.4     deprecated  11.22        minor\n"+
+		"111111111111  us-east-1  legacy      postgres  11.4     deprecated  none         \n", b.String())
+}
+
+// TestRunRecommend tests the recommend subcommand, in demo mode.
+func TestRunRecommend(t *testing.T) {
+	// set by TestMain
+	t.Setenv(AwsApiIntervalEnvName, "")
+
+	var b bytes.Buffer
+	assert.NoError(t, runRecommend([]string{"-demo", "-regions", "eu-west-1", "-upgrade-preference", "major"}, &b))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], "ACCOUNT "))
+	assert.Contains(t, b.String(), "billing")
+	assert.NotContains(t, b.String(), "orders")
+}
@@ -26,12 +26,44 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func Ptr[T any](v T) *T {
 	return &v
 }
 
+// contains returns true if the slice s contains the value v.
+func contains[T comparable](s []T, v T) bool {
+	for _, item := range s {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list, trimming the spaces around its items and dropping the empty ones.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvString retrieves the value of an environment variable with the given name. If the variable is not set,
+// defaultValue is returned.
+func getEnvString(name, defaultValue string) string {
+	if value := os.Getenv(name); len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 // getEnvInteger retrieves the value of an environment variable with the given name and returns it as an integer.
 // If the variable is not set, or if its value cannot be parsed as an integer, an error will be returned.
 func getEnvInteger(name string) (int, error) {
@@ -46,3 +78,60 @@ func getEnvInteger(name string) (int, error) {
 	}
 	return parsedInterval, nil
 }
+
+// getEnvIntegerWithDefault retrieves the value of an environment variable with the given name and returns it as an
+// integer. If the variable is not set, defaultValue is returned. If its value cannot be parsed as an integer, an error
+// will be returned.
+func getEnvIntegerWithDefault(name string, defaultValue int) (int, error) {
+	if len(os.Getenv(name)) == 0 {
+		return defaultValue, nil
+	}
+	return getEnvInteger(name)
+}
+
+// getEnvBoolWithDefault retrieves the value of an environment variable with the given name and returns it as a
+// boolean. If the variable is not set, defaultValue is returned. If its value cannot be parsed as a boolean, an error
+// will be returned.
+func getEnvBoolWithDefault(name string, defaultValue bool) (bool, error) {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	parsedValue, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("environment variable %s could not be parsed: %w", name, err)
+	}
+	return parsedValue, nil
+}
+
+// getEnvDuration retrieves the value of the first environment variable set among the given names and returns it as a
+// duration. Values are Go duration strings, e.g. "30s", "5m" or "1h"; plain integers are parsed as a number of seconds
+// for backward compatibility with the "*_SECONDS" variables. If none of the variables is set, defaultValue is returned.
+// An error will be returned if the value cannot be parsed, or if the duration is not positive.
+func getEnvDuration(defaultValue time.Duration, names ...string) (time.Duration, error) {
+	for _, name := range names {
+		value := os.Getenv(name)
+		if len(value) == 0 {
+			continue
+		}
+
+		duration, err := parseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("environment variable %s could not be parsed: %w", name, err)
+		}
+		if duration <= 0 {
+			return 0, fmt.Errorf("environment variable %s should be a positive duration, got %s", name, value)
+		}
+		return duration, nil
+	}
+	return defaultValue, nil
+}
+
+// parseDuration parses a Go duration string, e.g. "30s", "5m" or "1h". Plain integers are parsed as a number of seconds.
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
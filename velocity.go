@@ -0,0 +1,59 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// upgradeVelocity counts, between successive snapshots, the resources that moved off a deprecated engine version and
+// the ones newly created on a deprecated engine version, to tell whether the fleet is getting better or worse. It is
+// not safe for concurrent use.
+type upgradeVelocity struct {
+	tracker transitionTracker
+}
+
+// observe compares the inventory with the one of the previous call, and increments the MovedOffDeprecatedCounter for
+// each resource whose deprecated engine version was upgraded to a version that is not, and the
+// CreatedOnDeprecatedCounter for each new resource running a deprecated engine version. The first call counts nothing.
+// Nothing is done if v is nil.
+func (v *upgradeVelocity) observe(metrics *Metrics, inv *inventory) {
+	if v == nil {
+		return
+	}
+	for _, t := range v.tracker.update(inv.list()) {
+		var counter *prometheus.CounterVec
+		switch {
+		case t.Previous == nil && t.Current.Deprecated:
+			counter = metrics.CreatedOnDeprecatedCounter
+		case t.Previous != nil && t.Current != nil && t.Previous.Deprecated && !t.Current.Deprecated &&
+			t.Previous.EngineVersion != t.Current.EngineVersion:
+			counter = metrics.MovedOffDeprecatedCounter
+		default:
+			continue
+		}
+		counter.With(prometheus.Labels{
+			"engine":     t.Current.Engine,
+			"account_id": t.Current.Account,
+			"region":     t.Current.Region,
+		}).Inc()
+	}
+}
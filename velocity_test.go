@@ -0,0 +1,70 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpgradeVelocity tests that the resources upgraded off a deprecated version and the ones created on a deprecated
+// version are counted between successive snapshots, and nothing else.
+func TestUpgradeVelocity(t *testing.T) {
+	item := func(identifier, version string, deprecated bool) inventoryItem {
+		return inventoryItem{
+			RDSInfo: RDSInfo{ClusterIdentifier: identifier, Engine: "postgres", EngineVersion: version,
+				Account: "123456789012", Region: "eu-west-1"},
+			Deprecated: deprecated,
+		}
+	}
+	snapshots := [][]inventoryItem{
+		{item("db-1", "11.19", true), item("db-2", "11.19", true), item("db-3", "13.7", false)},
+		// db-1 is upgraded, db-2 is deleted, db-3 flips to deprecated, db-4 and db-5 are created
+		{item("db-1", "14.7", false), item("db-3", "13.7", true), item("db-4", "11.19", true),
+			item("db-5", "14.7", false)},
+		// db-3 is upgraded to another deprecated version
+		{item("db-1", "14.7", false), item("db-3", "13.8", true), item("db-4", "11.19", true),
+			item("db-5", "14.7", false)},
+	}
+
+	metrics := NewMetrics()
+	velocity := &upgradeVelocity{}
+	for _, items := range snapshots {
+		inv := &inventory{}
+		for _, it := range items {
+			inv.add(it)
+		}
+		velocity.observe(metrics, inv)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.MovedOffDeprecatedCounter.WithLabelValues(
+		"postgres", "123456789012", "eu-west-1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CreatedOnDeprecatedCounter.WithLabelValues(
+		"postgres", "123456789012", "eu-west-1")))
+
+	// a nil upgradeVelocity counts nothing
+	var nilVelocity *upgradeVelocity
+	nilVelocity.observe(metrics, &inventory{})
+}
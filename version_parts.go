@@ -0,0 +1,64 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// auroraMySQLSeparator separates the MySQL version from the Aurora version in the composite engine versions of
+// Aurora MySQL, e.g. "8.0.mysql_aurora.3.04.0".
+const auroraMySQLSeparator = ".mysql_aurora."
+
+// splitEngineVersion splits an engine version into its major version, i.e. the series the upgrade campaigns are
+// organized by, and its minor version, the rest of it:
+//   - the major versions of MySQL, MariaDB, Aurora MySQL and SQL Server have two parts, e.g. "5.7.41" is "5.7" and "41",
+//     and "15.00.4236.7.v1" is "15.00" and "4236.7.v1";
+//   - the composite versions of Aurora MySQL are split around their Aurora version, e.g. "8.0.mysql_aurora.3.04.0" is
+//     "8.0" and "3.04.0";
+//   - the major versions of PostgreSQL have two parts before PostgreSQL 10, e.g. "9.6.20" is "9.6" and "20", and one
+//     part since, e.g. "13.7" is "13" and "7", like the ones of the other engines, e.g. Oracle and the Custom Engine
+//     Versions of RDS Custom.
+//
+// The minor version is empty if the engine version has no more parts than its major version.
+func splitEngineVersion(engine, version string) (major, minor string) {
+	if mysqlVersion, auroraVersion, ok := strings.Cut(version, auroraMySQLSeparator); ok {
+		return mysqlVersion, auroraVersion
+	}
+	parts := strings.Split(version, ".")
+	majorParts := 1
+	switch {
+	case engine == "mysql", engine == "mariadb", engine == "aurora", engine == "aurora-mysql",
+		strings.HasPrefix(engine, "sqlserver-"), strings.HasPrefix(engine, "custom-sqlserver-"):
+		majorParts = 2
+	case engine == "postgres", engine == "aurora-postgresql":
+		if n, err := strconv.Atoi(parts[0]); err == nil && n < 10 {
+			majorParts = 2
+		}
+	}
+	if len(parts) <= majorParts {
+		return version, ""
+	}
+	return strings.Join(parts[:majorParts], "."), strings.Join(parts[majorParts:], ".")
+}
@@ -0,0 +1,58 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplitEngineVersion tests that engine versions are split into their major and minor versions, according to the
+// versioning scheme of their engine.
+func TestSplitEngineVersion(t *testing.T) {
+	for _, tt := range []struct {
+		engine, version string
+		major, minor    string
+	}{
+		{"postgres", "13.7", "13", "7"},
+		{"postgres", "9.6.20", "9.6", "20"},
+		{"aurora-postgresql", "11.9", "11", "9"},
+		{"mysql", "5.7.41", "5.7", "41"},
+		{"mariadb", "10.6.12", "10.6", "12"},
+		{"aurora-mysql", "8.0.mysql_aurora.3.04.0", "8.0", "3.04.0"},
+		{"aurora-mysql", "5.7.mysql_aurora.2.11.2", "5.7", "2.11.2"},
+		{"aurora", "5.6.10a", "5.6", "10a"},
+		{"sqlserver-se", "15.00.4236.7.v1", "15.00", "4236.7.v1"},
+		{"oracle-ee", "19.0.0.0.ru-2023-01.rur-2023-01.r1", "19", "0.0.0.ru-2023-01.rur-2023-01.r1"},
+		{"custom-oracle-ee", "19.my_cev1", "19", "my_cev1"},
+		{"postgres", "16", "16", ""},
+		{"mysql", "8.0", "8.0", ""},
+	} {
+		t.Run(tt.engine+"/"+tt.version, func(t *testing.T) {
+			major, minor := splitEngineVersion(tt.engine, tt.version)
+			assert.Equal(t, tt.major, major)
+			assert.Equal(t, tt.minor, minor)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v2"
+)
+
+// statusBanned is the status of the engine versions banned by the version policy, which replaces their catalog status.
+const statusBanned = "banned"
+
+// minPolicyRefresh and maxPolicyRefresh bound the policy refresh interval.
+const (
+	minPolicyRefresh = time.Minute
+	maxPolicyRefresh = 24 * time.Hour
+)
+
+// versionPolicy overrides the engine catalogs and the support calendar with the policy of an organization: the banned
+// engine versions are deprecated whatever their catalog status, and the internal end dates of the standard support take
+// precedence over the ones of the support calendar.
+type versionPolicy struct {
+	BannedVersions     []bannedVersion `yaml:"banned_versions"`
+	StandardSupportEnd supportCalendar `yaml:"standard_support_end"`
+}
+
+// bannedVersion bans an engine version of some engines, or every version of a major version, e.g. "5.7".
+type bannedVersion struct {
+	Engines []string `yaml:"engines"`
+	Version string   `yaml:"version"`
+	Reason  string   `yaml:"reason"`
+}
+
+// parseVersionPolicy parses a version policy, rejecting the unknown keys and the incomplete entries.
+func parseVersionPolicy(data []byte) (*versionPolicy, error) {
+	var policy versionPolicy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse version policy; %w", err)
+	}
+	for i, banned := range policy.BannedVersions {
+		if len(banned.Engines) == 0 || banned.Version == "" {
+			return nil, fmt.Errorf("invalid banned version %d: engines and version should be set", i+1)
+		}
+	}
+	for i, entry := range policy.StandardSupportEnd {
+		if len(entry.Engines) == 0 || entry.Version == "" || entry.StandardSupportEnd.IsZero() {
+			return nil, fmt.Errorf("invalid standard support end %d: engines, version and standard_support_end "+
+				"should be set", i+1)
+		}
+	}
+	return &policy, nil
+}
+
+// bans reports whether the policy bans an engine version, like the support calendar matches it.
+func (p *versionPolicy) bans(engine, engineVersion string) bool {
+	for _, banned := range p.BannedVersions {
+		if contains(banned.Engines, engine) && matchesVersion(banned.Version, engineVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// policySource holds the version policy fetched from an HTTP(S) or S3 URL, e.g. maintained by a central platform
+// team for every exporter, and fetches it again once the refresh interval is over. A nil policySource has an empty
+// policy.
+type policySource struct {
+	url      string
+	interval time.Duration
+	fetch    func() ([]byte, error)
+
+	mu        sync.Mutex
+	data      []byte
+	policy    *versionPolicy
+	fetchedAt time.Time
+}
+
+// newPolicySource returns the policySource of the policy URL and refresh interval of the options, or nil if no URL is
+// set. The S3 objects are read with the credentials of the exporter.
+func newPolicySource(options *Options) *policySource {
+	if options.PolicyURL == "" {
+		return nil
+	}
+	source := &policySource{url: options.PolicyURL, interval: options.PolicyRefreshInterval}
+	if bucket, key, ok := parseS3URL(options.PolicyURL); ok {
+		client := s3.New(newSession(options, nil))
+		source.fetch = func() ([]byte, error) { return fetchS3Policy(client, bucket, key) }
+	} else {
+		client := &http.Client{Timeout: options.AwsApiTimeout}
+		source.fetch = func() ([]byte, error) { return fetchHTTPPolicy(client, options.PolicyURL) }
+	}
+	return source
+}
+
+// parseS3URL returns the bucket and the key of an s3://bucket/key URL, and false if url is not one.
+func parseS3URL(url string) (string, string, bool) {
+	if !strings.HasPrefix(url, "s3://") {
+		return "", "", false
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(url, "s3://"), "/")
+	return bucket, key, ok && bucket != "" && key != ""
+}
+
+// validatePolicyURL checks that a policy URL is either an HTTP(S) URL or an s3://bucket/key URL.
+func validatePolicyURL(s string) error {
+	if strings.HasPrefix(s, "s3://") {
+		if _, _, ok := parseS3URL(s); !ok {
+			return fmt.Errorf("policy URL should be s3://bucket/key, got %q", s)
+		}
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("policy URL should be an http, https or s3 URL, got %q", describePolicyURL(s))
+	}
+	return nil
+}
+
+// describePolicyURL describes a policy URL without its query nor its user info, which may hold credentials, e.g. the
+// signature of a presigned URL.
+func describePolicyURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u.String()
+}
+
+// fetchHTTPPolicy returns the body of a GET request to url, which should respond 200.
+func fetchHTTPPolicy(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download version policy; %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download version policy from %s; unexpected status %s",
+			describePolicyURL(url), resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download version policy; %w", err)
+	}
+	return data, nil
+}
+
+// fetchS3Policy returns the content of an S3 object.
+func fetchS3Policy(client s3iface.S3API, bucket, key string) ([]byte, error) {
+	output, err := client.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version policy from s3://%s/%s; %w", bucket, key, err)
+	}
+	defer output.Body.Close()
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version policy from s3://%s/%s; %w", bucket, key, err)
+	}
+	return data, nil
+}
+
+// refresh fetches the policy if it was never fetched or if the refresh interval is over, and reports whether it
+// changed. A policy that cannot be fetched or parsed is logged and recorded in the Debug inventory, and the previous
+// one is kept. The PolicyRefreshSuccessGauge tells whether the last fetch succeeded.
+func (s *policySource) refresh(metrics *Metrics, now time.Time) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetchedAt.IsZero() && now.Sub(s.fetchedAt) < s.interval {
+		return false
+	}
+	s.fetchedAt = now
+	labels := prometheus.Labels{"source": describePolicyURL(s.url)}
+	data, err := s.fetch()
+	var policy *versionPolicy
+	if err == nil {
+		policy, err = parseVersionPolicy(data)
+	}
+	metrics.Debug.recordError("version policy", err)
+	if err != nil {
+		log.Printf("failed to refresh the version policy, keeping the previous one; %v", err)
+		metrics.PolicyRefreshSuccessGauge.With(labels).Set(0)
+		return false
+	}
+	metrics.PolicyRefreshSuccessGauge.With(labels).Set(1)
+	if bytes.Equal(data, s.data) {
+		return false
+	}
+	s.data, s.policy = data, policy
+	return true
+}
+
+// current returns the last policy fetched, or an empty one.
+func (s *policySource) current() *versionPolicy {
+	if s == nil {
+		return &versionPolicy{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy == nil {
+		return &versionPolicy{}
+	}
+	return s.policy
+}
+
+// ban sets the status of the engine versions of the catalogs banned by the policy to "banned".
+func (s *policySource) ban(catalogs engineVersions) {
+	policy := s.current()
+	if len(policy.BannedVersions) == 0 {
+		return
+	}
+	for engine, catalog := range catalogs {
+		for engineVersion, info := range catalog {
+			if policy.bans(engine, engineVersion) && info.Status != statusBanned {
+				info.CatalogStatus, info.Status = info.Status, statusBanned
+				catalog[engineVersion] = info
+			}
+		}
+	}
+}
+
+// calendar returns the internal end dates of the standard support of the policy, followed by the support calendar, so
+// that they take precedence.
+func (s *policySource) calendar(calendar supportCalendar) supportCalendar {
+	policy := s.current()
+	if len(policy.StandardSupportEnd) == 0 {
+		return calendar
+	}
+	return append(append(supportCalendar{}, policy.StandardSupportEnd...), calendar...)
+}
+
+// calendar returns the support calendar of the Config, overridden by its version policy.
+func (c *Config) calendar() supportCalendar {
+	return c.Policy.calendar(c.Calendar)
+}
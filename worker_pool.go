@@ -0,0 +1,105 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// runPool runs the given tasks on a pool of at most `concurrency` workers and waits for all of them to complete.
+// A concurrency lower than 1 is treated as 1, i.e. tasks are run serially.
+//
+// Every task is run even if another one failed. The error of the first failed task, in the order of the tasks slice,
+// is returned, so that the reported error does not depend on scheduling. A panicking task does not crash the program:
+// its panic is recovered and returned as a *panicError, which takes precedence over the errors of the other tasks so
+// that the panic is not hidden behind a plain error.
+func runPool(concurrency int, tasks []func() error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(tasks) {
+		concurrency = len(tasks)
+	}
+
+	errs := make([]error, len(tasks))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = runTask(tasks[i])
+			}
+		}()
+	}
+	for i := range tasks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		var p *panicError
+		if errors.As(err, &p) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runTask runs a task, recovering from its panic if any.
+func runTask(task func() error) (err error) {
+	defer recoverPanic(&err)
+	return task()
+}
+
+// rateLimiter spaces out calls so that at most `rate` calls per second are let through, whatever the number of
+// goroutines calling Wait concurrently.
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+// newRateLimiter returns a rateLimiter letting through at most `rate` calls per second. A nil rateLimiter is returned
+// if rate is lower than 1, which does not limit anything.
+func newRateLimiter(rate int) *rateLimiter {
+	if rate < 1 {
+		return nil
+	}
+	return &rateLimiter{ticker: time.NewTicker(time.Second / time.Duration(rate))}
+}
+
+// Wait blocks until the caller is allowed to perform its call.
+func (r *rateLimiter) Wait() {
+	if r == nil {
+		return
+	}
+	<-r.ticker.C
+}
@@ -0,0 +1,121 @@
+// MIT License
+//
+// Copyright (c) 2023 Alexandre Mahdhaoui
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRunPool tests the runPool function.
+func TestRunPool(t *testing.T) {
+	tests := []struct {
+		desc        string
+		concurrency int
+		nTasks      int
+		failing     map[int]bool
+		panicking   map[int]bool
+		wantErr     error
+	}{
+		{desc: "serial", concurrency: 0, nTasks: 5},
+		{desc: "bounded", concurrency: 3, nTasks: 10},
+		{desc: "more workers than tasks", concurrency: 10, nTasks: 2},
+		{desc: "no task", concurrency: 2, nTasks: 0},
+		{
+			desc:        "first failed task in order is reported",
+			concurrency: 4,
+			nTasks:      8,
+			failing:     map[int]bool{2: true, 5: true},
+			wantErr:     errors.New("task 2 failed"),
+		},
+		{
+			desc:        "panic is reported before the failed tasks",
+			concurrency: 4,
+			nTasks:      8,
+			failing:     map[int]bool{2: true},
+			panicking:   map[int]bool{5: true},
+			wantErr:     errors.New("panic: task 5 panicked"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var inFlight, maxInFlight, done int32
+			tasks := make([]func() error, tt.nTasks)
+			for i := range tasks {
+				i := i
+				tasks[i] = func() error {
+					n := atomic.AddInt32(&inFlight, 1)
+					for {
+						m := atomic.LoadInt32(&maxInFlight)
+						if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+							break
+						}
+					}
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&inFlight, -1)
+					atomic.AddInt32(&done, 1)
+					if tt.panicking[i] {
+						panic(fmt.Sprintf("task %d panicked", i))
+					}
+					if tt.failing[i] {
+						return fmt.Errorf("task %d failed", i)
+					}
+					return nil
+				}
+			}
+
+			err := runPool(tt.concurrency, tasks)
+			if tt.wantErr != nil {
+				assert.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, int32(tt.nTasks), done)
+			limit := int32(tt.concurrency)
+			if limit < 1 {
+				limit = 1
+			}
+			assert.LessOrEqual(t, maxInFlight, limit)
+		})
+	}
+}
+
+// TestRateLimiter tests the rateLimiter type.
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0))
+	// A nil rateLimiter must not block
+	newRateLimiter(0).Wait()
+
+	limiter := newRateLimiter(100)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		limiter.Wait()
+	}
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}